@@ -19,6 +19,136 @@ USE `cc`;
 /*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;
 /*!40111 SET @OLD_SQL_NOTES=@@SQL_NOTES, SQL_NOTES=0 */;
 
+--
+-- Table structure for table `AccessTokens`
+--
+
+DROP TABLE IF EXISTS `AccessTokens`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `AccessTokens` (
+  `TokenID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `Name` varchar(50) COLLATE utf8_unicode_ci NOT NULL,
+  `TokenHash` char(64) COLLATE utf8_unicode_ci NOT NULL,
+  `Scopes` varchar(1000) COLLATE utf8_unicode_ci NOT NULL DEFAULT '',
+  `CreationDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `ExpiryDate` datetime DEFAULT NULL,
+  PRIMARY KEY (`TokenID`),
+  UNIQUE KEY `AccessTokens_TokenHash_UNIQUE` (`TokenHash`),
+  KEY `fk_AccessTokens_Username_idx` (`Username`),
+  CONSTRAINT `fk_AccessTokens_Username` FOREIGN KEY (`Username`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `AuditLog`
+--
+
+DROP TABLE IF EXISTS `AuditLog`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `AuditLog` (
+  `AuditID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `Timestamp` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `Actor` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `Action` varchar(50) COLLATE utf8_unicode_ci NOT NULL,
+  `ProjectID` bigint(20) DEFAULT NULL,
+  `Target` varchar(2083) COLLATE utf8_unicode_ci NOT NULL DEFAULT '',
+  `Detail` varchar(2083) COLLATE utf8_unicode_ci NOT NULL DEFAULT '',
+  `SourceIP` varchar(45) COLLATE utf8_unicode_ci NOT NULL DEFAULT '',
+  PRIMARY KEY (`AuditID`),
+  KEY `AuditLog_ProjectID_idx` (`ProjectID`,`Timestamp`),
+  KEY `AuditLog_Actor_idx` (`Actor`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `Comments`
+--
+
+DROP TABLE IF EXISTS `Comments`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `Comments` (
+  `CommentID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `ThreadID` bigint(20) NOT NULL,
+  `Author` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `Body` text COLLATE utf8_unicode_ci NOT NULL,
+  `CreationDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`CommentID`),
+  KEY `fk_Comments_ThreadID_idx` (`ThreadID`),
+  KEY `fk_Comments_Author_idx` (`Author`),
+  CONSTRAINT `fk_Comments_ThreadID` FOREIGN KEY (`ThreadID`) REFERENCES `CommentThreads` (`ThreadID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_Comments_Author` FOREIGN KEY (`Author`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `CommentThreads`
+--
+
+DROP TABLE IF EXISTS `CommentThreads`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `CommentThreads` (
+  `ThreadID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `FileID` bigint(20) NOT NULL,
+  `StartIndex` int(11) NOT NULL,
+  `EndIndex` int(11) NOT NULL,
+  `FileVersion` bigint(20) NOT NULL,
+  `Orphaned` tinyint(1) NOT NULL DEFAULT '0',
+  `Resolved` tinyint(1) NOT NULL DEFAULT '0',
+  `ResolvedBy` varchar(25) COLLATE utf8_unicode_ci DEFAULT NULL,
+  `CreatedBy` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `CreationDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`ThreadID`),
+  KEY `fk_CommentThreads_FileID_idx` (`FileID`),
+  KEY `fk_CommentThreads_ResolvedBy_idx` (`ResolvedBy`),
+  KEY `fk_CommentThreads_CreatedBy_idx` (`CreatedBy`),
+  CONSTRAINT `fk_CommentThreads_FileID` FOREIGN KEY (`FileID`) REFERENCES `File` (`FileID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_CommentThreads_ResolvedBy` FOREIGN KEY (`ResolvedBy`) REFERENCES `User` (`Username`) ON DELETE SET NULL ON UPDATE CASCADE,
+  CONSTRAINT `fk_CommentThreads_CreatedBy` FOREIGN KEY (`CreatedBy`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `EmailVerifications`
+--
+
+DROP TABLE IF EXISTS `EmailVerifications`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `EmailVerifications` (
+  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `Email` varchar(50) COLLATE utf8_unicode_ci NOT NULL,
+  `TokenHash` char(64) COLLATE utf8_unicode_ci NOT NULL,
+  `CreationDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `ExpiryDate` datetime NOT NULL,
+  PRIMARY KEY (`Username`),
+  UNIQUE KEY `EmailVerifications_TokenHash_UNIQUE` (`TokenHash`),
+  CONSTRAINT `fk_EmailVerifications_Username` FOREIGN KEY (`Username`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `ExternalIdentities`
+--
+
+DROP TABLE IF EXISTS `ExternalIdentities`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `ExternalIdentities` (
+  `Provider` varchar(50) COLLATE utf8_unicode_ci NOT NULL,
+  `Subject` varchar(255) COLLATE utf8_unicode_ci NOT NULL,
+  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `LinkedDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`Provider`,`Subject`),
+  KEY `fk_ExternalIdentities_Username_idx` (`Username`),
+  CONSTRAINT `fk_ExternalIdentities_Username` FOREIGN KEY (`Username`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 --
 -- Table structure for table `File`
 --
@@ -33,15 +163,206 @@ CREATE TABLE `File` (
   `RelativePath` varchar(2083) COLLATE utf8_unicode_ci NOT NULL,
   `ProjectID` bigint(20) NOT NULL,
   `Filename` varchar(50) COLLATE utf8_unicode_ci NOT NULL,
+  `DeletedBy` varchar(25) COLLATE utf8_unicode_ci DEFAULT NULL,
+  `DeletedDate` datetime DEFAULT NULL,
   PRIMARY KEY (`FileID`),
   UNIQUE KEY `FileID_UNIQUE` (`FileID`),
   KEY `fk_File_Username_idx` (`Creator`),
   KEY `fk_File_ProjectID_idx` (`ProjectID`),
+  KEY `File_DeletedDate_idx` (`DeletedDate`),
   CONSTRAINT `fk_File_ProjectID` FOREIGN KEY (`ProjectID`) REFERENCES `Project` (`ProjectID`) ON DELETE NO ACTION ON UPDATE CASCADE,
   CONSTRAINT `fk_File_Username` FOREIGN KEY (`Creator`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
+--
+-- Table structure for table `FileLocks`
+--
+
+DROP TABLE IF EXISTS `FileLocks`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `FileLocks` (
+  `FileID` bigint(20) NOT NULL,
+  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `Socket` varchar(100) COLLATE utf8_unicode_ci NOT NULL,
+  `Enforced` tinyint(1) NOT NULL DEFAULT '0',
+  `ExpiryDate` datetime NOT NULL,
+  PRIMARY KEY (`FileID`),
+  KEY `fk_FileLocks_Username_idx` (`Username`),
+  KEY `FileLocks_Socket_idx` (`Socket`),
+  CONSTRAINT `fk_FileLocks_FileID` FOREIGN KEY (`FileID`) REFERENCES `File` (`FileID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_FileLocks_Username` FOREIGN KEY (`Username`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `GroupMembers`
+--
+
+DROP TABLE IF EXISTS `GroupMembers`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `GroupMembers` (
+  `GroupID` bigint(20) NOT NULL,
+  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `AddedBy` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `AddedDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`GroupID`,`Username`),
+  KEY `fk_GroupMembers_Username_idx` (`Username`),
+  CONSTRAINT `fk_GroupMembers_GroupID` FOREIGN KEY (`GroupID`) REFERENCES `Groups` (`GroupID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_GroupMembers_Username` FOREIGN KEY (`Username`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `GroupPermissions`
+--
+
+DROP TABLE IF EXISTS `GroupPermissions`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `GroupPermissions` (
+  `GroupID` bigint(20) NOT NULL,
+  `ProjectID` bigint(20) NOT NULL,
+  `PermissionLevel` tinyint(1) NOT NULL DEFAULT '0',
+  `GrantedBy` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `GrantedDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`ProjectID`,`GroupID`),
+  KEY `fk_GroupPermissions_GroupID_idx` (`GroupID`),
+  CONSTRAINT `fk_GroupPermissions_GroupID` FOREIGN KEY (`GroupID`) REFERENCES `Groups` (`GroupID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_GroupPermissions_ProjectID` FOREIGN KEY (`ProjectID`) REFERENCES `Project` (`ProjectID`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `Groups`
+--
+
+DROP TABLE IF EXISTS `Groups`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `Groups` (
+  `GroupID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `Name` varchar(50) COLLATE utf8_unicode_ci NOT NULL,
+  `Owner` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `CreationDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`GroupID`),
+  UNIQUE KEY `NameOwner_UNIQUE` (`Name`,`Owner`),
+  KEY `fk_Groups_Username_idx` (`Owner`),
+  CONSTRAINT `fk_Groups_Username` FOREIGN KEY (`Owner`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `Invites`
+--
+
+DROP TABLE IF EXISTS `Invites`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `Invites` (
+  `InviteID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `ProjectID` bigint(20) NOT NULL,
+  `Username` varchar(25) COLLATE utf8_unicode_ci DEFAULT NULL,
+  `Email` varchar(50) COLLATE utf8_unicode_ci DEFAULT NULL,
+  `PermissionLevel` tinyint(1) NOT NULL DEFAULT '0',
+  `InvitedBy` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `CreationDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `ExpiryDate` datetime NOT NULL,
+  PRIMARY KEY (`InviteID`),
+  KEY `fk_Invites_ProjectID_idx` (`ProjectID`),
+  KEY `fk_Invites_Username_idx` (`Username`),
+  KEY `Invites_Email_idx` (`Email`),
+  CONSTRAINT `fk_Invites_ProjectID` FOREIGN KEY (`ProjectID`) REFERENCES `Project` (`ProjectID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_Invites_Username` FOREIGN KEY (`Username`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `LoginFailures`
+--
+
+DROP TABLE IF EXISTS `LoginFailures`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `LoginFailures` (
+  `Kind` varchar(8) COLLATE utf8_unicode_ci NOT NULL,
+  `Subject` varchar(64) COLLATE utf8_unicode_ci NOT NULL,
+  `Failures` int(11) NOT NULL DEFAULT '0',
+  `LastFailure` datetime NOT NULL,
+  `LockedUntil` datetime DEFAULT NULL,
+  PRIMARY KEY (`Kind`,`Subject`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `Notifications`
+--
+
+DROP TABLE IF EXISTS `Notifications`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `Notifications` (
+  `NotificationID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `Resource` varchar(32) COLLATE utf8_unicode_ci NOT NULL,
+  `Method` varchar(32) COLLATE utf8_unicode_ci NOT NULL,
+  `ResourceID` bigint(20) NOT NULL DEFAULT '0',
+  `Data` text COLLATE utf8_unicode_ci NOT NULL,
+  `CreationDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `ReadDate` datetime DEFAULT NULL,
+  PRIMARY KEY (`NotificationID`),
+  KEY `fk_Notifications_Username_idx` (`Username`,`ReadDate`),
+  CONSTRAINT `fk_Notifications_Username` FOREIGN KEY (`Username`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `OwnershipTransfers`
+--
+
+DROP TABLE IF EXISTS `OwnershipTransfers`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `OwnershipTransfers` (
+  `ProjectID` bigint(20) NOT NULL,
+  `FormerOwner` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `NewOwner` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `FormerOwnerLevel` tinyint(1) NOT NULL,
+  `RequestedDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`ProjectID`),
+  KEY `fk_OwnershipTransfers_FormerOwner_idx` (`FormerOwner`),
+  KEY `fk_OwnershipTransfers_NewOwner_idx` (`NewOwner`),
+  CONSTRAINT `fk_OwnershipTransfers_ProjectID` FOREIGN KEY (`ProjectID`) REFERENCES `Project` (`ProjectID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_OwnershipTransfers_FormerOwner` FOREIGN KEY (`FormerOwner`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_OwnershipTransfers_NewOwner` FOREIGN KEY (`NewOwner`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `PathPermissions`
+--
+
+DROP TABLE IF EXISTS `PathPermissions`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `PathPermissions` (
+  `PathPermissionID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `ProjectID` bigint(20) NOT NULL,
+  `PathPrefix` varchar(255) COLLATE utf8_unicode_ci NOT NULL,
+  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `PermissionLevel` tinyint(1) NOT NULL DEFAULT '0',
+  `GrantedBy` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `GrantedDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`PathPermissionID`),
+  KEY `fk_PathPermissions_ProjectID_idx` (`ProjectID`),
+  KEY `fk_PathPermissions_Username_idx` (`Username`),
+  CONSTRAINT `fk_PathPermissions_ProjectID` FOREIGN KEY (`ProjectID`) REFERENCES `Project` (`ProjectID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_PathPermissions_Username` FOREIGN KEY (`Username`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 --
 -- Table structure for table `Permissions`
 --
@@ -74,10 +395,12 @@ CREATE TABLE `Project` (
   `ProjectID` bigint(20) NOT NULL AUTO_INCREMENT,
   `Name` varchar(50) COLLATE utf8_unicode_ci NOT NULL,
   `Owner` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `DeletedDate` datetime DEFAULT NULL,
   PRIMARY KEY (`ProjectID`),
   UNIQUE KEY `ProjectID_UNIQUE` (`ProjectID`),
   UNIQUE KEY `NameOwner_UNIQUE` (`Name`,`Owner`),
   KEY `fk_Project_Username_idx` (`Owner`),
+  KEY `Project_DeletedDate_idx` (`DeletedDate`),
   CONSTRAINT `fk_Project_Username` FOREIGN KEY (`Owner`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -90,46 +413,2367 @@ CREATE TABLE `Project` (
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-  /*!50003 CREATE*/ /*!50017 DEFINER=`root`@`localhost`*/ /*!50003 TRIGGER `cc`.`Project_BEFORE_DELETE` BEFORE DELETE ON `Project` FOR EACH ROW
+  /*!50003 CREATE*/ /*!50017 DEFINER=`root`@`localhost`*/ /*!50003 TRIGGER `cc`.`Project_BEFORE_DELETE` BEFORE DELETE ON `Project` FOR EACH ROW
+  BEGIN
+    DELETE FROM Permissions
+    WHERE Permissions.ProjectID = OLD.ProjectID;
+    DELETE FROM `File`
+    WHERE `File`.ProjectID = OLD.ProjectID;
+  END */;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+
+--
+-- Table structure for table `ProjectRoles`
+--
+
+DROP TABLE IF EXISTS `ProjectRoles`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `ProjectRoles` (
+  `ProjectID` bigint(20) NOT NULL,
+  `Level` tinyint(1) NOT NULL,
+  `Name` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `Capabilities` varchar(255) COLLATE utf8_unicode_ci NOT NULL DEFAULT '',
+  PRIMARY KEY (`ProjectID`,`Level`),
+  UNIQUE KEY `ProjectName_UNIQUE` (`ProjectID`,`Name`),
+  CONSTRAINT `fk_ProjectRoles_ProjectID` FOREIGN KEY (`ProjectID`) REFERENCES `Project` (`ProjectID`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `RecoveryCodes`
+--
+
+DROP TABLE IF EXISTS `RecoveryCodes`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `RecoveryCodes` (
+  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `CodeHash` char(64) COLLATE utf8_unicode_ci NOT NULL,
+  PRIMARY KEY (`Username`,`CodeHash`),
+  CONSTRAINT `fk_RecoveryCodes_Username` FOREIGN KEY (`Username`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `ShareLinks`
+--
+
+DROP TABLE IF EXISTS `ShareLinks`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `ShareLinks` (
+  `ShareLinkID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `ProjectID` bigint(20) NOT NULL,
+  `TokenHash` char(64) COLLATE utf8_unicode_ci NOT NULL,
+  `CreatedBy` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `CreationDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `ExpiryDate` datetime NOT NULL,
+  `MaxUses` int(11) NOT NULL DEFAULT '0',
+  `Uses` int(11) NOT NULL DEFAULT '0',
+  PRIMARY KEY (`ShareLinkID`),
+  UNIQUE KEY `ShareLinks_TokenHash_UNIQUE` (`TokenHash`),
+  KEY `fk_ShareLinks_ProjectID_idx` (`ProjectID`),
+  KEY `fk_ShareLinks_CreatedBy_idx` (`CreatedBy`),
+  CONSTRAINT `fk_ShareLinks_ProjectID` FOREIGN KEY (`ProjectID`) REFERENCES `Project` (`ProjectID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_ShareLinks_CreatedBy` FOREIGN KEY (`CreatedBy`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `Suggestions`
+--
+
+DROP TABLE IF EXISTS `Suggestions`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `Suggestions` (
+  `SuggestionID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `FileID` bigint(20) NOT NULL,
+  `Author` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `Patch` mediumtext COLLATE utf8_unicode_ci NOT NULL,
+  `FileVersion` bigint(20) NOT NULL,
+  `CreationDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`SuggestionID`),
+  KEY `fk_Suggestions_FileID_idx` (`FileID`),
+  KEY `fk_Suggestions_Author_idx` (`Author`),
+  CONSTRAINT `fk_Suggestions_FileID` FOREIGN KEY (`FileID`) REFERENCES `File` (`FileID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_Suggestions_Author` FOREIGN KEY (`Author`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `TwoFactor`
+--
+
+DROP TABLE IF EXISTS `TwoFactor`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `TwoFactor` (
+  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `Secret` varchar(64) COLLATE utf8_unicode_ci NOT NULL,
+  `Enabled` tinyint(1) NOT NULL DEFAULT '0',
+  `LastUsedStep` bigint(20) NOT NULL DEFAULT '0',
+  `CreationDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`Username`),
+  CONSTRAINT `fk_TwoFactor_Username` FOREIGN KEY (`Username`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `User`
+--
+
+DROP TABLE IF EXISTS `User`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `User` (
+  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `Password` varchar(100) COLLATE utf8_unicode_ci NOT NULL,
+  `Email` varchar(50) COLLATE utf8_unicode_ci DEFAULT NULL,
+  `FirstName` varchar(30) COLLATE utf8_unicode_ci NOT NULL,
+  `LastName` varchar(30) COLLATE utf8_unicode_ci NOT NULL,
+  `Bot` tinyint(1) NOT NULL DEFAULT '0',
+  `BotOwner` varchar(25) COLLATE utf8_unicode_ci DEFAULT NULL,
+  `Status` varchar(16) COLLATE utf8_unicode_ci NOT NULL DEFAULT 'active',
+  `HiddenFromSearch` tinyint(1) NOT NULL DEFAULT '0',
+  PRIMARY KEY (`Username`),
+  UNIQUE KEY `Email_UNIQUE` (`Email`),
+  KEY `Email_INDEX` (`Email`),
+  KEY `fk_User_BotOwner_idx` (`BotOwner`),
+  CONSTRAINT `fk_User_BotOwner` FOREIGN KEY (`BotOwner`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `UsernameTombstones`
+--
+
+DROP TABLE IF EXISTS `UsernameTombstones`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `UsernameTombstones` (
+  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `RenamedTo` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `RenamedDate` datetime NOT NULL,
+  PRIMARY KEY (`Username`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `WebhookDeliveries`
+--
+
+DROP TABLE IF EXISTS `WebhookDeliveries`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `WebhookDeliveries` (
+  `DeliveryID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `WebhookID` bigint(20) NOT NULL,
+  `Event` varchar(64) COLLATE utf8_unicode_ci NOT NULL,
+  `Attempts` int(11) NOT NULL,
+  `StatusCode` int(11) NOT NULL DEFAULT '0',
+  `Error` varchar(1000) COLLATE utf8_unicode_ci NOT NULL DEFAULT '',
+  `Success` tinyint(1) NOT NULL,
+  `CompletionDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`DeliveryID`),
+  KEY `fk_WebhookDeliveries_WebhookID_idx` (`WebhookID`),
+  CONSTRAINT `fk_WebhookDeliveries_WebhookID` FOREIGN KEY (`WebhookID`) REFERENCES `Webhooks` (`WebhookID`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `Webhooks`
+--
+
+DROP TABLE IF EXISTS `Webhooks`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `Webhooks` (
+  `WebhookID` bigint(20) NOT NULL AUTO_INCREMENT,
+  `ProjectID` bigint(20) NOT NULL,
+  `URL` varchar(2000) COLLATE utf8_unicode_ci NOT NULL,
+  `Secret` varchar(100) COLLATE utf8_unicode_ci NOT NULL,
+  `Events` varchar(1000) COLLATE utf8_unicode_ci NOT NULL DEFAULT '',
+  `CreatedBy` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
+  `CreationDate` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`WebhookID`),
+  KEY `fk_Webhooks_ProjectID_idx` (`ProjectID`),
+  KEY `fk_Webhooks_CreatedBy_idx` (`CreatedBy`),
+  CONSTRAINT `fk_Webhooks_ProjectID` FOREIGN KEY (`ProjectID`) REFERENCES `Project` (`ProjectID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_Webhooks_CreatedBy` FOREIGN KEY (`CreatedBy`) REFERENCES `User` (`Username`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping events for database 'cc'
+--
+
+--
+-- Dumping routines for database 'cc'
+--
+/*!50003 DROP PROCEDURE IF EXISTS `access_token_authenticate` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `access_token_authenticate`(IN tokenHash char(64))
+  BEGIN
+    SELECT `TokenID`, `Username`, `Name`, `Scopes`, `CreationDate`, `ExpiryDate`
+    FROM `AccessTokens`
+    WHERE `AccessTokens`.`TokenHash` = tokenHash
+      AND (`AccessTokens`.`ExpiryDate` IS NULL OR `AccessTokens`.`ExpiryDate` > UTC_TIMESTAMP());
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `access_token_create` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `access_token_create`(IN username varchar(25), IN tokenName varchar(50),
+                                                                  IN tokenHash char(64), IN scopes varchar(1000),
+                                                                  IN expirySeconds int)
+  BEGIN
+    INSERT INTO `AccessTokens` (`Username`, `Name`, `TokenHash`, `Scopes`, `ExpiryDate`)
+    VALUES (username, tokenName, tokenHash, scopes,
+            IF(expirySeconds > 0, DATE_ADD(UTC_TIMESTAMP(), INTERVAL expirySeconds SECOND), NULL));
+    SELECT LAST_INSERT_ID();
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `access_token_delete` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `access_token_delete`(IN tokenID bigint(20))
+  BEGIN
+    DELETE FROM `AccessTokens`
+    WHERE `AccessTokens`.`TokenID` = tokenID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `access_token_get` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `access_token_get`(IN tokenID bigint(20))
+  BEGIN
+    SELECT `TokenID`, `Username`, `Name`, `Scopes`, `CreationDate`, `ExpiryDate`
+    FROM `AccessTokens`
+    WHERE `AccessTokens`.`TokenID` = tokenID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `audit_log_insert` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `audit_log_insert`(IN actor varchar(25), IN auditAction varchar(50),
+                                                               IN projectID bigint(20), IN target varchar(2083),
+                                                               IN detail varchar(2083), IN sourceIP varchar(45))
+  BEGIN
+    INSERT INTO `AuditLog`
+    (Actor, Action, ProjectID, Target, Detail, SourceIP)
+    VALUES (actor, auditAction, projectID, target, detail, sourceIP);
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `comment_anchor_update` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `comment_anchor_update`(IN threadID bigint(20),
+                                                                    IN fromVersion bigint(20),
+                                                                    IN startIndex int, IN endIndex int,
+                                                                    IN fileVersion bigint(20),
+                                                                    IN orphaned tinyint(1))
+  BEGIN
+    -- only anchors which have not been shifted by another server since they were read are updated
+    UPDATE `CommentThreads`
+    SET `CommentThreads`.`StartIndex` = startIndex, `CommentThreads`.`EndIndex` = endIndex,
+      `CommentThreads`.`FileVersion` = fileVersion, `CommentThreads`.`Orphaned` = orphaned
+    WHERE `CommentThreads`.`ThreadID` = threadID
+          AND `CommentThreads`.`FileVersion` = fromVersion
+          AND `CommentThreads`.`Orphaned` = 0;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `comment_get_file` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `comment_get_file`(IN fileID bigint(20))
+  BEGIN
+    SELECT `Comments`.`CommentID`, `Comments`.`ThreadID`, `Comments`.`Author`, `Comments`.`Body`,
+      `Comments`.`CreationDate`
+    FROM `Comments`
+      JOIN `CommentThreads` ON `Comments`.`ThreadID` = `CommentThreads`.`ThreadID`
+    WHERE `CommentThreads`.`FileID` = fileID
+    ORDER BY `Comments`.`CommentID`;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `comment_reply` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `comment_reply`(IN threadID bigint(20), IN author varchar(25),
+                                                            IN body text)
+  BEGIN
+    INSERT INTO `Comments` (`ThreadID`, `Author`, `Body`)
+    VALUES (threadID, author, body);
+    SELECT LAST_INSERT_ID();
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `comment_thread_create` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `comment_thread_create`(IN fileID bigint(20), IN startIndex int,
+                                                                    IN endIndex int, IN fileVersion bigint(20),
+                                                                    IN author varchar(25), IN body text)
+  BEGIN
+    DECLARE newThreadID bigint(20);
+
+    INSERT INTO `CommentThreads` (`FileID`, `StartIndex`, `EndIndex`, `FileVersion`, `CreatedBy`)
+    VALUES (fileID, startIndex, endIndex, fileVersion, author);
+    SET newThreadID = LAST_INSERT_ID();
+
+    INSERT INTO `Comments` (`ThreadID`, `Author`, `Body`)
+    VALUES (newThreadID, author, body);
+    SELECT newThreadID, LAST_INSERT_ID();
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `comment_thread_get` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `comment_thread_get`(IN threadID bigint(20))
+  BEGIN
+    SELECT `CommentThreads`.`ThreadID`, `CommentThreads`.`FileID`, `CommentThreads`.`StartIndex`,
+      `CommentThreads`.`EndIndex`, `CommentThreads`.`FileVersion`, `CommentThreads`.`Orphaned`,
+      `CommentThreads`.`Resolved`, IFNULL(`CommentThreads`.`ResolvedBy`, ''), `CommentThreads`.`CreatedBy`,
+      `CommentThreads`.`CreationDate`
+    FROM `CommentThreads`
+    WHERE `CommentThreads`.`ThreadID` = threadID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `comment_thread_get_file` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `comment_thread_get_file`(IN fileID bigint(20),
+                                                                      IN includeResolved tinyint(1))
+  BEGIN
+    SELECT `CommentThreads`.`ThreadID`, `CommentThreads`.`FileID`, `CommentThreads`.`StartIndex`,
+      `CommentThreads`.`EndIndex`, `CommentThreads`.`FileVersion`, `CommentThreads`.`Orphaned`,
+      `CommentThreads`.`Resolved`, IFNULL(`CommentThreads`.`ResolvedBy`, ''), `CommentThreads`.`CreatedBy`,
+      `CommentThreads`.`CreationDate`
+    FROM `CommentThreads`
+    WHERE `CommentThreads`.`FileID` = fileID
+          AND (includeResolved = 1 OR `CommentThreads`.`Resolved` = 0)
+    ORDER BY `CommentThreads`.`ThreadID`;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `comment_thread_resolve` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `comment_thread_resolve`(IN threadID bigint(20),
+                                                                     IN resolved tinyint(1),
+                                                                     IN username varchar(25))
+  BEGIN
+    UPDATE `CommentThreads`
+    SET `CommentThreads`.`Resolved` = resolved,
+      `CommentThreads`.`ResolvedBy` = IF(resolved, username, NULL)
+    WHERE `CommentThreads`.`ThreadID` = threadID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `email_change_create` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `email_change_create`(IN username varchar(25),
+                                                                  IN email varchar(50),
+                                                                  IN tokenHash char(64),
+                                                                  IN expirySeconds int)
+  BEGIN
+    REPLACE INTO `EmailVerifications` (`Username`, `Email`, `TokenHash`, `ExpiryDate`)
+    SELECT `User`.`Username`, email, tokenHash, DATE_ADD(UTC_TIMESTAMP(), INTERVAL expirySeconds SECOND)
+    FROM `User`
+    WHERE `User`.`Username` = username AND `User`.`Bot` = 0;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `email_verification_create` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `email_verification_create`(IN username varchar(25),
+                                                                        IN tokenHash char(64),
+                                                                        IN expirySeconds int)
+  BEGIN
+    REPLACE INTO `EmailVerifications` (`Username`, `Email`, `TokenHash`, `ExpiryDate`)
+    SELECT `User`.`Username`, `User`.`Email`, tokenHash, DATE_ADD(UTC_TIMESTAMP(), INTERVAL expirySeconds SECOND)
+    FROM `User`
+    WHERE `User`.`Username` = username AND `User`.`Email` IS NOT NULL AND `User`.`Status` = 'pending';
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `email_verification_delete` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `email_verification_delete`(IN username varchar(25))
+  BEGIN
+    DELETE FROM `EmailVerifications` WHERE `EmailVerifications`.`Username` = username;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `email_verification_lookup` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `email_verification_lookup`(IN tokenHash char(64))
+  BEGIN
+    SELECT `EmailVerifications`.`Username`, `EmailVerifications`.`Email`
+    FROM `EmailVerifications`
+    WHERE `EmailVerifications`.`TokenHash` = tokenHash
+      AND `EmailVerifications`.`ExpiryDate` > UTC_TIMESTAMP()
+    FOR UPDATE;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `external_identity_link` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `external_identity_link`(IN provider varchar(50), IN subject varchar(255),
+                                                                     IN username varchar(25))
+  BEGIN
+    INSERT INTO `ExternalIdentities` (`Provider`, `Subject`, `Username`)
+    VALUES (provider, subject, username);
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `external_identity_lookup` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `external_identity_lookup`(IN provider varchar(50), IN subject varchar(255))
+  BEGIN
+    SELECT `ExternalIdentities`.`Username`
+    FROM `ExternalIdentities`
+    WHERE `ExternalIdentities`.`Provider` = provider AND `ExternalIdentities`.`Subject` = subject;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_create` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_create`(IN username varchar(25), IN filename varchar(50), IN relativePath varchar(2083), IN projectID bigint(20))
+BEGIN
+  IF ( NOT EXISTS ( SELECT `File`.`FileID`
+          FROM `File`
+          WHERE `File`.`ProjectID` =  projectID AND `File`.`RelativePath` = relativePath AND `File`.`Filename` = filename
+            AND `File`.`DeletedDate` IS NULL ) ) THEN
+      BEGIN
+        INSERT INTO `File`
+        (Creator, RelativePath, ProjectID, Filename)
+        VALUES (username, relativePath, projectID, filename);
+        SELECT LAST_INSERT_ID();
+      END;
+    ELSE
+      BEGIN
+        SELECT null;
+      END;
+    END IF;
+END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_delete` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_delete`(IN fileID bigint(20))
+  BEGIN
+    DELETE FROM `File`
+    WHERE `File`.FileID = fileID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_get_info` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_get_info`(IN fileID bigint(20))
+  BEGIN
+    SELECT `File`.`Creator`, `File`.`CreationDate`, `File`.`RelativePath`, `File`.`ProjectID`, `File`.`Filename`
+    FROM File
+    WHERE `File`.`FileID` = fileID AND `File`.`DeletedDate` IS NULL;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_get_trashed_info` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_get_trashed_info`(IN fileID bigint(20))
+  BEGIN
+    SELECT `File`.`FileID`, `File`.`Creator`, `File`.`CreationDate`, `File`.`RelativePath`, `File`.`ProjectID`,
+      `File`.`Filename`, `File`.`DeletedBy`, `File`.`DeletedDate`
+    FROM `File`
+    WHERE `File`.`FileID` = fileID AND `File`.`DeletedDate` IS NOT NULL;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_lock_acquire` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_lock_acquire`(IN fileID bigint(20), IN username varchar(25),
+                                                                IN socketName varchar(100), IN enforced tinyint(1),
+                                                                IN ttlSeconds int)
+  BEGIN
+    -- expired leases are taken over, and the socket's own lease is renewed. The assignments are evaluated in order,
+    -- so `ExpiryDate` is updated last, once `Socket` has been taken over.
+    INSERT INTO `FileLocks` (`FileID`, `Username`, `Socket`, `Enforced`, `ExpiryDate`)
+    VALUES (fileID, username, socketName, enforced, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ttlSeconds SECOND))
+    ON DUPLICATE KEY UPDATE
+      `Enforced` = IF(`Socket` = socketName OR `ExpiryDate` < UTC_TIMESTAMP(), VALUES(`Enforced`), `Enforced`),
+      `Username` = IF(`Socket` = socketName OR `ExpiryDate` < UTC_TIMESTAMP(), VALUES(`Username`), `Username`),
+      `Socket` = IF(`Socket` = socketName OR `ExpiryDate` < UTC_TIMESTAMP(), VALUES(`Socket`), `Socket`),
+      `ExpiryDate` = IF(`Socket` = socketName, VALUES(`ExpiryDate`), `ExpiryDate`);
+    SELECT `FileLocks`.`FileID`, `FileLocks`.`Username`, `FileLocks`.`Socket`, `FileLocks`.`Enforced`,
+      `FileLocks`.`ExpiryDate`
+    FROM `FileLocks`
+    WHERE `FileLocks`.`FileID` = fileID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_lock_get` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_lock_get`(IN fileID bigint(20))
+  BEGIN
+    SELECT `FileLocks`.`FileID`, `FileLocks`.`Username`, `FileLocks`.`Socket`, `FileLocks`.`Enforced`,
+      `FileLocks`.`ExpiryDate`
+    FROM `FileLocks`
+    WHERE `FileLocks`.`FileID` = fileID AND `FileLocks`.`ExpiryDate` >= UTC_TIMESTAMP();
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_lock_release` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_lock_release`(IN fileID bigint(20), IN username varchar(25),
+                                                                IN forced tinyint(1))
+  BEGIN
+    DELETE FROM `FileLocks`
+    WHERE `FileLocks`.`FileID` = fileID
+          AND (forced = 1 OR `FileLocks`.`Username` = username)
+          AND `FileLocks`.`ExpiryDate` >= UTC_TIMESTAMP();
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_lock_release_socket` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_lock_release_socket`(IN socketName varchar(100))
+  BEGIN
+    SELECT `FileLocks`.`FileID`, `FileLocks`.`Username`, `FileLocks`.`Socket`, `FileLocks`.`Enforced`,
+      `FileLocks`.`ExpiryDate`
+    FROM `FileLocks`
+    WHERE `FileLocks`.`Socket` = socketName AND `FileLocks`.`ExpiryDate` >= UTC_TIMESTAMP();
+    DELETE FROM `FileLocks`
+    WHERE `FileLocks`.`Socket` = socketName;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_move` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_move`(IN fileID bigint(20), IN newPath varchar(2083))
+  BEGIN
+    UPDATE `File`
+    SET `File`.RelativePath = newPath
+    WHERE `File`.FileID = fileID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_rename` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_rename`(IN fileID bigint(20), IN newName varchar(50))
+  BEGIN
+    UPDATE `File`
+    SET `File`.Filename = newName
+    WHERE `File`.FileID = fileID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_restore` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_restore`(IN fileID bigint(20))
+  BEGIN
+    UPDATE `File`
+    SET `DeletedBy` = NULL, `DeletedDate` = NULL
+    WHERE `File`.`FileID` = fileID AND `File`.`DeletedDate` IS NOT NULL;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `file_trash` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `file_trash`(IN fileID bigint(20), IN username varchar(25))
+  BEGIN
+    UPDATE `File`
+    SET `DeletedBy` = username, `DeletedDate` = UTC_TIMESTAMP()
+    WHERE `File`.`FileID` = fileID AND `File`.`DeletedDate` IS NULL;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `group_add_member` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `group_add_member`(IN groupID bigint(20), IN username varchar(25), IN addedBy varchar(25))
+  BEGIN
+    INSERT INTO `GroupMembers` (`GroupID`, `Username`, `AddedBy`)
+    VALUES (groupID, username, addedBy);
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `group_create` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `group_create`(IN groupName varchar(50), IN username varchar(25))
+  BEGIN
+    INSERT INTO `Groups` (`Name`, `Owner`)
+    VALUES (groupName, username);
+    SET @groupID = LAST_INSERT_ID();
+    INSERT INTO `GroupMembers` (`GroupID`, `Username`, `AddedBy`)
+    VALUES (@groupID, username, username);
+    SELECT @groupID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `group_get_info` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `group_get_info`(IN groupID bigint(20))
+  BEGIN
+    SELECT `Groups`.`Name`, `Groups`.`Owner`, `Groups`.`CreationDate`
+    FROM `Groups`
+    WHERE `Groups`.`GroupID` = groupID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `group_get_members` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `group_get_members`(IN groupID bigint(20))
+  BEGIN
+    SELECT `GroupMembers`.`Username`
+    FROM `GroupMembers`
+    WHERE `GroupMembers`.`GroupID` = groupID
+    ORDER BY `GroupMembers`.`Username`;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `group_get_projects` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `group_get_projects`(IN groupID bigint(20))
+  BEGIN
+    SELECT `GroupPermissions`.`ProjectID`
+    FROM `GroupPermissions`
+    WHERE `GroupPermissions`.`GroupID` = groupID
+    ORDER BY `GroupPermissions`.`ProjectID`;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `group_remove_member` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `group_remove_member`(IN groupID bigint(20), IN username varchar(25))
+  BEGIN
+    DELETE FROM `GroupMembers`
+    WHERE `GroupMembers`.`GroupID` = groupID AND `GroupMembers`.`Username` = username;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `invite_bind_email` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `invite_bind_email`(IN email varchar(50), IN username varchar(25))
+  BEGIN
+    UPDATE `Invites`
+    SET `Invites`.`Username` = username
+    WHERE `Invites`.`Email` = email AND `Invites`.`Username` IS NULL AND `Invites`.`ExpiryDate` > UTC_TIMESTAMP();
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `invite_create` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `invite_create`(IN projectID bigint(20), IN username varchar(25), IN email varchar(50),
+                                                            IN permissionLevel tinyint(1), IN invitedBy varchar(25), IN expirySeconds int)
+  BEGIN
+    INSERT INTO `Invites` (`ProjectID`, `Username`, `Email`, `PermissionLevel`, `InvitedBy`, `ExpiryDate`)
+    VALUES (projectID, username, email, permissionLevel, invitedBy, DATE_ADD(UTC_TIMESTAMP(), INTERVAL expirySeconds SECOND));
+    SELECT LAST_INSERT_ID();
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `invite_delete` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `invite_delete`(IN inviteID bigint(20))
+  BEGIN
+    DELETE FROM `Invites`
+    WHERE `Invites`.`InviteID` = inviteID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `invite_get` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `invite_get`(IN inviteID bigint(20))
+  BEGIN
+    SELECT `Invites`.`InviteID`, `Invites`.`ProjectID`, `Project`.`Name`, IFNULL(`Invites`.`Username`, ''), IFNULL(`Invites`.`Email`, ''),
+      `Invites`.`PermissionLevel`, `Invites`.`InvitedBy`, `Invites`.`CreationDate`, `Invites`.`ExpiryDate`
+    FROM `Invites` JOIN `Project`
+        ON `Invites`.`ProjectID` = `Project`.`ProjectID`
+    WHERE `Invites`.`InviteID` = inviteID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `login_failure_record` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `login_failure_record`(IN kind varchar(8), IN subject varchar(64),
+                                                                   IN resetSeconds int)
+  BEGIN
+    -- failures are counted again from 1 once resetSeconds have passed since the last one
+    INSERT INTO `LoginFailures` (`Kind`, `Subject`, `Failures`, `LastFailure`)
+    VALUES (kind, subject, 1, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE
+      `Failures` = IF(`LastFailure` < DATE_SUB(UTC_TIMESTAMP(), INTERVAL resetSeconds SECOND), 1, `Failures` + 1),
+      `LastFailure` = UTC_TIMESTAMP();
+    SELECT `Failures`
+    FROM `LoginFailures`
+    WHERE `LoginFailures`.`Kind` = kind AND `LoginFailures`.`Subject` = subject;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `login_failures_clear` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `login_failures_clear`(IN kind varchar(8), IN subject varchar(64))
+  BEGIN
+    DELETE FROM `LoginFailures`
+    WHERE `LoginFailures`.`Kind` = kind AND `LoginFailures`.`Subject` = subject;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `login_failures_get` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `login_failures_get`(IN kind varchar(8), IN subject varchar(64))
+  BEGIN
+    SELECT `Failures`, `LastFailure`, `LockedUntil`
+    FROM `LoginFailures`
+    WHERE `LoginFailures`.`Kind` = kind AND `LoginFailures`.`Subject` = subject;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `login_failures_lock` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `login_failures_lock`(IN kind varchar(8), IN subject varchar(64),
+                                                                  IN lockSeconds int)
+  BEGIN
+    UPDATE `LoginFailures`
+    SET `Failures` = 0, `LockedUntil` = DATE_ADD(UTC_TIMESTAMP(), INTERVAL lockSeconds SECOND)
+    WHERE `LoginFailures`.`Kind` = kind AND `LoginFailures`.`Subject` = subject;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `notification_count_unread` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `notification_count_unread`(IN username varchar(25))
+  BEGIN
+    SELECT COUNT(*)
+    FROM `Notifications`
+    WHERE `Notifications`.`Username` = username AND `Notifications`.`ReadDate` IS NULL;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `notification_get` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `notification_get`(IN username varchar(25),
+                                                               IN unreadOnly tinyint(1),
+                                                               IN numOffset int,
+                                                               IN numLimit int)
+  BEGIN
+    SELECT `Notifications`.`NotificationID`, `Notifications`.`Resource`, `Notifications`.`Method`,
+      `Notifications`.`ResourceID`, `Notifications`.`Data`, `Notifications`.`CreationDate`,
+      `Notifications`.`ReadDate` IS NOT NULL
+    FROM `Notifications`
+    WHERE `Notifications`.`Username` = username
+          AND (unreadOnly = 0 OR `Notifications`.`ReadDate` IS NULL)
+    ORDER BY `Notifications`.`CreationDate` DESC, `Notifications`.`NotificationID` DESC
+    LIMIT numOffset, numLimit;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `notification_insert` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `notification_insert`(IN username varchar(25),
+                                                                  IN resource varchar(32),
+                                                                  IN method varchar(32),
+                                                                  IN resourceID bigint(20),
+                                                                  IN notificationData text)
+  BEGIN
+    INSERT INTO `Notifications` (`Username`, `Resource`, `Method`, `ResourceID`, `Data`)
+    VALUES (username, resource, method, resourceID, notificationData);
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `notification_mark_read` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `notification_mark_read`(IN username varchar(25),
+                                                                     IN notificationID bigint(20))
+  BEGIN
+    UPDATE `Notifications` SET `Notifications`.`ReadDate` = NOW()
+    WHERE `Notifications`.`Username` = username
+          AND (notificationID = 0 OR `Notifications`.`NotificationID` = notificationID)
+          AND `Notifications`.`ReadDate` IS NULL;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_add_path_permission` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_add_path_permission`(IN projectID bigint(20), IN pathPrefix varchar(255), IN username varchar(25), IN permissionLevel tinyint(1), IN grantedBy varchar(25))
+  BEGIN
+    INSERT INTO `PathPermissions` (`ProjectID`, `PathPrefix`, `Username`, `PermissionLevel`, `GrantedBy`)
+    VALUES (projectID, pathPrefix, username, permissionLevel, grantedBy);
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_cancel_transfer` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_cancel_transfer`(IN projectID bigint(20))
+  BEGIN
+    DELETE FROM `OwnershipTransfers`
+    WHERE `OwnershipTransfers`.`ProjectID` = projectID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_clear_path_permissions` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_clear_path_permissions`(IN projectID bigint(20))
+  BEGIN
+    DELETE FROM `PathPermissions`
+    WHERE `PathPermissions`.`ProjectID` = projectID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_create` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_create`(IN projectName varchar(50), IN username varchar(25))
+  BEGIN
+    INSERT INTO Project (`Name`, `Owner`)
+    VALUES (projectName, username);
+    SELECT LAST_INSERT_ID();
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_delete` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_delete`(IN projectID bigint(20),
+                                                             IN revokeUsername varchar(25))
+  BEGIN
+    DELETE FROM Project
+    WHERE Project.ProjectID = projectID AND Project.Owner = revokeUsername;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_delete_role` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_delete_role`(IN projectID bigint(20), IN roleLevel tinyint(1))
+  BEGIN
+    DELETE FROM `ProjectRoles`
+    WHERE `ProjectRoles`.`ProjectID` = projectID AND `ProjectRoles`.`Level` = roleLevel
+          AND NOT EXISTS ( SELECT `Permissions`.`Username`
+                           FROM `Permissions`
+                           WHERE `Permissions`.`ProjectID` = projectID AND `Permissions`.`PermissionLevel` = roleLevel )
+          AND NOT EXISTS ( SELECT `PathPermissions`.`Username`
+                           FROM `PathPermissions`
+                           WHERE `PathPermissions`.`ProjectID` = projectID AND `PathPermissions`.`PermissionLevel` = roleLevel );
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_get_audit_log` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_get_audit_log`(IN projectID bigint(20),
+                                                                    IN filterAction varchar(50),
+                                                                    IN filterActor varchar(25),
+                                                                    IN since timestamp,
+                                                                    IN until timestamp,
+                                                                    IN numOffset int,
+                                                                    IN numLimit int)
+  BEGIN
+    SELECT `AuditLog`.`AuditID`, `AuditLog`.`Timestamp`, `AuditLog`.`Actor`, `AuditLog`.`Action`,
+      `AuditLog`.`Target`, `AuditLog`.`Detail`, `AuditLog`.`SourceIP`
+    FROM `AuditLog`
+    WHERE `AuditLog`.`ProjectID` = projectID
+          AND (filterAction = '' OR `AuditLog`.`Action` = filterAction)
+          AND (filterActor = '' OR `AuditLog`.`Actor` = filterActor)
+          AND (since IS NULL OR `AuditLog`.`Timestamp` >= since)
+          AND (until IS NULL OR `AuditLog`.`Timestamp` < until)
+    ORDER BY `AuditLog`.`Timestamp` DESC, `AuditLog`.`AuditID` DESC
+    LIMIT numOffset, numLimit;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_get_files` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_get_files`(IN projectID bigint(20))
+  BEGIN
+    SELECT `File`.`FileID`, `File`.`Creator`, `File`.`CreationDate`, `File`.`RelativePath`, `File`.`ProjectID`, `File`.`Filename`
+    FROM File
+    WHERE File.ProjectID = projectID AND File.DeletedDate IS NULL;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_get_path_permissions` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_get_path_permissions`(IN projectID bigint(20))
+  BEGIN
+    SELECT `PathPermissions`.`PathPrefix`, `PathPermissions`.`Username`, `PathPermissions`.`PermissionLevel`,
+      `PathPermissions`.`GrantedBy`, `PathPermissions`.`GrantedDate`
+    FROM `PathPermissions`
+    WHERE `PathPermissions`.`ProjectID` = projectID
+    ORDER BY `PathPermissions`.`PathPrefix`, `PathPermissions`.`Username`;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_get_roles` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_get_roles`(IN projectID bigint(20))
+  BEGIN
+    SELECT `ProjectRoles`.`Level`, `ProjectRoles`.`Name`, `ProjectRoles`.`Capabilities`
+    FROM `ProjectRoles`
+    WHERE `ProjectRoles`.`ProjectID` = projectID
+    ORDER BY `ProjectRoles`.`Level`;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_get_transfer` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_get_transfer`(IN projectID bigint(20))
+  BEGIN
+    SELECT `OwnershipTransfers`.`ProjectID`, `Project`.`Name`, `OwnershipTransfers`.`FormerOwner`,
+      `OwnershipTransfers`.`NewOwner`, `OwnershipTransfers`.`FormerOwnerLevel`, `OwnershipTransfers`.`RequestedDate`
+    FROM `OwnershipTransfers` JOIN `Project`
+        ON `OwnershipTransfers`.`ProjectID` = `Project`.`ProjectID`
+    WHERE `OwnershipTransfers`.`ProjectID` = projectID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_get_trashed_files` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_get_trashed_files`(IN projectID bigint(20))
+  BEGIN
+    SELECT `File`.`FileID`, `File`.`Creator`, `File`.`CreationDate`, `File`.`RelativePath`, `File`.`ProjectID`,
+      `File`.`Filename`, `File`.`DeletedBy`, `File`.`DeletedDate`
+    FROM `File`
+    WHERE `File`.`ProjectID` = projectID AND `File`.`DeletedDate` IS NOT NULL
+    ORDER BY `File`.`DeletedDate` DESC;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_grant_group_permissions` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_grant_group_permissions`(IN projectID bigint(20),
+                                                                              IN groupID bigint(20),
+                                                                              IN permissionLevel tinyint(1),
+                                                                              IN grantedByUsername varchar(25))
+  BEGIN
+    insert into `GroupPermissions`
+    (GroupID, ProjectID, PermissionLevel, GrantedBy)
+    values (groupID, projectID, permissionLevel, grantedByUsername)
+    on duplicate key update
+      PermissionLevel = permissionLevel,
+      GrantedBy = grantedByUsername;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_grant_permissions` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_grant_permissions`(IN projectID bigint(20),
+                                                                        IN grantUsername varchar(25),
+                                                                        IN permissionLevel tinyint(1),
+                                                                        IN grantedByUsername varchar(25))
+  BEGIN
+    insert into `Permissions`
+    (Username, ProjectID, PermissionLevel, GrantedBy)
+    values (grantUsername, projectID, permissionLevel, grantedByUsername)
+    on duplicate key update
+      PermissionLevel = permissionLevel,
+      GrantedBy = grantedByUsername;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_lookup` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_lookup`(IN projectID bigint(20))
+  BEGIN
+    SELECT `Project`.`Name`, `Permissions`.`Username`, `Permissions`.`PermissionLevel`, `Permissions`.`GrantedBy`, `Permissions`.`GrantedDate`
+    FROM Project JOIN Permissions
+        ON Project.ProjectID = Permissions.ProjectID
+    WHERE Project.ProjectID = projectID
+    UNION
+    SELECT `Project`.`Name`, `GroupMembers`.`Username`, `GroupPermissions`.`PermissionLevel`, `GroupPermissions`.`GrantedBy`, `GroupPermissions`.`GrantedDate`
+    FROM Project JOIN GroupPermissions
+        ON Project.ProjectID = GroupPermissions.ProjectID
+      JOIN GroupMembers
+        ON GroupPermissions.GroupID = GroupMembers.GroupID
+    WHERE Project.ProjectID = projectID
+    UNION
+    SELECT `Project`.`Name`, `Project`.`Owner`, 10, `Project`.`Owner`, 0
+    FROM `Project`
+    WHERE `Project`.`ProjectID` = projectID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_rename` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_rename`(IN projectID bigint(20), IN newName varchar(50))
+  BEGIN
+    UPDATE Project
+    SET `Name` = newName
+    WHERE Project.ProjectID = projectID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_request_transfer` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_request_transfer`(IN projectID bigint(20),
+                                                                       IN formerOwner varchar(25),
+                                                                       IN newOwner varchar(25),
+                                                                       IN formerOwnerLevel tinyint(1))
+  BEGIN
+    REPLACE INTO `OwnershipTransfers`
+    (ProjectID, FormerOwner, NewOwner, FormerOwnerLevel)
+    VALUES (projectID, formerOwner, newOwner, formerOwnerLevel);
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_restore` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_restore`(IN projectID bigint(20), IN username varchar(25))
+  BEGIN
+    UPDATE `Project`
+    SET `DeletedDate` = NULL
+    WHERE `Project`.`ProjectID` = projectID AND `Project`.`Owner` = username
+          AND `Project`.`DeletedDate` IS NOT NULL;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_revoke_group_permissions` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_revoke_group_permissions`(IN projectID bigint(20),
+                                                                               IN groupID bigint(20))
+  BEGIN
+    DELETE FROM GroupPermissions
+    WHERE GroupPermissions.ProjectID = projectID
+          AND GroupPermissions.GroupID = groupID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_revoke_permissions` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_revoke_permissions`(IN projectID bigint(20),
+                                                                         IN revokeUsername varchar(25))
+  BEGIN
+    DELETE FROM Permissions
+    WHERE Permissions.ProjectID = projectID
+          AND Permissions.Username = revokeUsername;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_set_owner` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_set_owner`(IN projectID bigint(20),
+                                                                IN formerOwner varchar(25),
+                                                                IN newOwner varchar(25),
+                                                                IN newName varchar(50))
+  BEGIN
+    DELETE FROM `PathPermissions`
+    WHERE `PathPermissions`.`ProjectID` = projectID
+          AND `PathPermissions`.`Username` = newOwner;
+    UPDATE `Project`
+    SET `Owner` = newOwner, `Name` = newName
+    WHERE `Project`.`ProjectID` = projectID
+          AND `Project`.`Owner` = formerOwner;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_set_role` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_set_role`(IN projectID bigint(20), IN roleLevel tinyint(1),
+                                                               IN roleName varchar(25), IN capabilities varchar(255))
+  BEGIN
+    INSERT INTO `ProjectRoles`
+    (ProjectID, Level, Name, Capabilities)
+    VALUES (projectID, roleLevel, roleName, capabilities)
+    ON DUPLICATE KEY UPDATE
+      Name = roleName,
+      Capabilities = capabilities;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_share_links` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_share_links`(IN projectID bigint(20))
+  BEGIN
+    SELECT `ShareLinks`.`ShareLinkID`, `ShareLinks`.`ProjectID`, `Project`.`Name`, `ShareLinks`.`CreatedBy`,
+      `ShareLinks`.`CreationDate`, `ShareLinks`.`ExpiryDate`, `ShareLinks`.`MaxUses`, `ShareLinks`.`Uses`
+    FROM `ShareLinks` JOIN `Project`
+        ON `ShareLinks`.`ProjectID` = `Project`.`ProjectID`
+    WHERE `ShareLinks`.`ProjectID` = projectID
+    ORDER BY `ShareLinks`.`CreationDate` DESC;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `project_trash` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `project_trash`(IN projectID bigint(20), IN username varchar(25))
+  BEGIN
+    UPDATE `Project`
+    SET `DeletedDate` = UTC_TIMESTAMP()
+    WHERE `Project`.`ProjectID` = projectID AND `Project`.`Owner` = username
+          AND `Project`.`DeletedDate` IS NULL;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `recovery_code_add` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `recovery_code_add`(IN username varchar(25), IN codeHash char(64))
+  BEGIN
+    INSERT INTO `RecoveryCodes` (`Username`, `CodeHash`)
+    VALUES (username, codeHash);
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `recovery_code_use` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `recovery_code_use`(IN username varchar(25), IN codeHash char(64))
+  BEGIN
+    DELETE FROM `RecoveryCodes`
+    WHERE `RecoveryCodes`.`Username` = username AND `RecoveryCodes`.`CodeHash` = codeHash;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `recovery_codes_clear` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `recovery_codes_clear`(IN username varchar(25))
+  BEGIN
+    DELETE FROM `RecoveryCodes`
+    WHERE `RecoveryCodes`.`Username` = username;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `share_link_create` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `share_link_create`(IN projectID bigint(20), IN tokenHash char(64), IN createdBy varchar(25),
+                                                               IN expirySeconds int, IN maxUses int)
+  BEGIN
+    INSERT INTO `ShareLinks` (`ProjectID`, `TokenHash`, `CreatedBy`, `ExpiryDate`, `MaxUses`)
+    VALUES (projectID, tokenHash, createdBy, DATE_ADD(UTC_TIMESTAMP(), INTERVAL expirySeconds SECOND), maxUses);
+    SELECT LAST_INSERT_ID();
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `share_link_delete` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `share_link_delete`(IN shareLinkID bigint(20))
+  BEGIN
+    DELETE FROM `ShareLinks`
+    WHERE `ShareLinks`.`ShareLinkID` = shareLinkID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `share_link_get` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `share_link_get`(IN shareLinkID bigint(20))
+  BEGIN
+    SELECT `ShareLinks`.`ShareLinkID`, `ShareLinks`.`ProjectID`, `Project`.`Name`, `ShareLinks`.`CreatedBy`,
+      `ShareLinks`.`CreationDate`, `ShareLinks`.`ExpiryDate`, `ShareLinks`.`MaxUses`, `ShareLinks`.`Uses`
+    FROM `ShareLinks` JOIN `Project`
+        ON `ShareLinks`.`ProjectID` = `Project`.`ProjectID`
+    WHERE `ShareLinks`.`ShareLinkID` = shareLinkID AND `Project`.`DeletedDate` IS NULL;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `share_link_redeem` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `share_link_redeem`(IN tokenHash char(64))
+  BEGIN
+    DECLARE redeemed int;
+    UPDATE `ShareLinks` JOIN `Project`
+        ON `ShareLinks`.`ProjectID` = `Project`.`ProjectID`
+    SET `ShareLinks`.`Uses` = `ShareLinks`.`Uses` + 1
+    WHERE `ShareLinks`.`TokenHash` = tokenHash AND `ShareLinks`.`ExpiryDate` > UTC_TIMESTAMP()
+      AND (`ShareLinks`.`MaxUses` = 0 OR `ShareLinks`.`Uses` < `ShareLinks`.`MaxUses`)
+      AND `Project`.`DeletedDate` IS NULL;
+    SET redeemed = ROW_COUNT();
+    SELECT `ShareLinks`.`ShareLinkID`
+    FROM `ShareLinks`
+    WHERE `ShareLinks`.`TokenHash` = tokenHash AND redeemed > 0;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `suggestion_create` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `suggestion_create`(IN fileID bigint(20), IN author varchar(25),
+                                                                IN patch mediumtext, IN fileVersion bigint(20))
+  BEGIN
+    INSERT INTO `Suggestions` (`FileID`, `Author`, `Patch`, `FileVersion`)
+    VALUES (fileID, author, patch, fileVersion);
+    SELECT LAST_INSERT_ID();
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `suggestion_delete` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `suggestion_delete`(IN suggestionID bigint(20))
+  BEGIN
+    DELETE FROM `Suggestions`
+    WHERE `Suggestions`.`SuggestionID` = suggestionID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `suggestion_get` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `suggestion_get`(IN suggestionID bigint(20))
+  BEGIN
+    SELECT `Suggestions`.`SuggestionID`, `Suggestions`.`FileID`, `Suggestions`.`Author`, `Suggestions`.`Patch`,
+      `Suggestions`.`FileVersion`, `Suggestions`.`CreationDate`
+    FROM `Suggestions`
+    WHERE `Suggestions`.`SuggestionID` = suggestionID;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `suggestion_get_file` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `suggestion_get_file`(IN fileID bigint(20))
+  BEGIN
+    SELECT `Suggestions`.`SuggestionID`, `Suggestions`.`FileID`, `Suggestions`.`Author`, `Suggestions`.`Patch`,
+      `Suggestions`.`FileVersion`, `Suggestions`.`CreationDate`
+    FROM `Suggestions`
+    WHERE `Suggestions`.`FileID` = fileID
+    ORDER BY `Suggestions`.`SuggestionID`;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `suggestion_rebase` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `suggestion_rebase`(IN suggestionID bigint(20),
+                                                                IN fromVersion bigint(20),
+                                                                IN patch mediumtext, IN fileVersion bigint(20))
+  BEGIN
+    -- only suggestions which have not been rebased by another server since they were read are updated
+    UPDATE `Suggestions`
+    SET `Suggestions`.`Patch` = patch, `Suggestions`.`FileVersion` = fileVersion
+    WHERE `Suggestions`.`SuggestionID` = suggestionID
+          AND `Suggestions`.`FileVersion` = fromVersion;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `trash_expired_files` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `trash_expired_files`(IN deletedBefore datetime)
+  BEGIN
+    SELECT `File`.`FileID`, `File`.`Creator`, `File`.`CreationDate`, `File`.`RelativePath`, `File`.`ProjectID`,
+      `File`.`Filename`, `File`.`DeletedBy`, `File`.`DeletedDate`
+    FROM `File` JOIN `Project`
+        ON `File`.`ProjectID` = `Project`.`ProjectID`
+    WHERE `File`.`DeletedDate` < deletedBefore AND `Project`.`DeletedDate` IS NULL;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `trash_expired_projects` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `trash_expired_projects`(IN deletedBefore datetime)
+  BEGIN
+    SELECT `Project`.`ProjectID`, `Project`.`Name`, `Project`.`Owner`, `Project`.`DeletedDate`
+    FROM `Project`
+    WHERE `Project`.`DeletedDate` < deletedBefore;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `two_factor_disable` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `two_factor_disable`(IN username varchar(25))
+  BEGIN
+    DELETE FROM `RecoveryCodes`
+    WHERE `RecoveryCodes`.`Username` = username;
+    DELETE FROM `TwoFactor`
+    WHERE `TwoFactor`.`Username` = username;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `two_factor_enable` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `two_factor_enable`(IN username varchar(25), IN step bigint(20))
+  BEGIN
+    UPDATE `TwoFactor`
+    SET `TwoFactor`.`Enabled` = 1, `TwoFactor`.`LastUsedStep` = step
+    WHERE `TwoFactor`.`Username` = username AND `TwoFactor`.`Enabled` = 0;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `two_factor_get` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `two_factor_get`(IN username varchar(25))
+  BEGIN
+    SELECT `TwoFactor`.`Secret`, `TwoFactor`.`Enabled`, `TwoFactor`.`LastUsedStep`
+    FROM `TwoFactor`
+    WHERE `TwoFactor`.`Username` = username;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `two_factor_set_secret` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `two_factor_set_secret`(IN username varchar(25), IN secret varchar(64))
+  BEGIN
+    DELETE FROM `TwoFactor`
+    WHERE `TwoFactor`.`Username` = username AND `TwoFactor`.`Enabled` = 0;
+    INSERT IGNORE INTO `TwoFactor` (`Username`, `Secret`)
+    VALUES (username, secret);
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `two_factor_use_step` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `two_factor_use_step`(IN username varchar(25), IN step bigint(20))
+  BEGIN
+    UPDATE `TwoFactor`
+    SET `TwoFactor`.`LastUsedStep` = step
+    WHERE `TwoFactor`.`Username` = username AND `TwoFactor`.`Enabled` = 1 AND `TwoFactor`.`LastUsedStep` < step;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `user_access_tokens` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_access_tokens`(IN username varchar(25))
+  BEGIN
+    SELECT `TokenID`, `Username`, `Name`, `Scopes`, `CreationDate`, `ExpiryDate`
+    FROM `AccessTokens`
+    WHERE `AccessTokens`.`Username` = username
+    ORDER BY `AccessTokens`.`CreationDate` DESC;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `user_delete` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_delete`(IN username varchar(25))
+  BEGIN
+    DELETE FROM `User`
+    WHERE `User`.Username = username;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `user_get_password` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_get_password`(IN username varchar(25))
   BEGIN
-    DELETE FROM Permissions
-    WHERE Permissions.ProjectID = OLD.ProjectID;
-    DELETE FROM `File`
-    WHERE `File`.ProjectID = OLD.ProjectID;
-  END */;;
+    SELECT Password
+    FROM User where User.Username = username;
+  END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-
---
--- Table structure for table `User`
---
-
-DROP TABLE IF EXISTS `User`;
-/*!40101 SET @saved_cs_client     = @@character_set_client */;
-/*!40101 SET character_set_client = utf8 */;
-CREATE TABLE `User` (
-  `Username` varchar(25) COLLATE utf8_unicode_ci NOT NULL,
-  `Password` varchar(100) COLLATE utf8_unicode_ci NOT NULL,
-  `Email` varchar(50) COLLATE utf8_unicode_ci NOT NULL,
-  `FirstName` varchar(30) COLLATE utf8_unicode_ci NOT NULL,
-  `LastName` varchar(30) COLLATE utf8_unicode_ci NOT NULL,
-  PRIMARY KEY (`Username`),
-  UNIQUE KEY `Email_UNIQUE` (`Email`),
-  KEY `Email_INDEX` (`Email`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
-/*!40101 SET character_set_client = @saved_cs_client */;
-
---
--- Dumping events for database 'cc'
---
-
---
--- Dumping routines for database 'cc'
---
-/*!50003 DROP PROCEDURE IF EXISTS `file_create` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_get_projectids` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -139,29 +2783,17 @@ CREATE TABLE `User` (
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `file_create`(IN username varchar(25), IN filename varchar(50), IN relativePath varchar(2083), IN projectID bigint(20))
-BEGIN
-  IF ( NOT EXISTS ( SELECT `File`.`FileID`
-          FROM `File`
-          WHERE `File`.`ProjectID` =  projectID AND `File`.`RelativePath` = relativePath AND `File`.`Filename` = filename ) ) THEN
-      BEGIN
-        INSERT INTO `File`
-        (Creator, RelativePath, ProjectID, Filename)
-        VALUES (username, relativePath, projectID, filename);
-        SELECT LAST_INSERT_ID();
-      END;
-    ELSE
-      BEGIN
-        SELECT null;
-      END;
-    END IF;
-END ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_get_projectids`(IN username varchar(25))
+  BEGIN
+    SELECT `Project`.`ProjectID` FROM `Project`
+      WHERE `Project`.`Owner` = username;
+  END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `file_delete` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_groups` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -171,17 +2803,20 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `file_delete`(IN fileID bigint(20))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_groups`(IN username varchar(25))
   BEGIN
-    DELETE FROM `File`
-    WHERE `File`.FileID = fileID;
+    SELECT `Groups`.`GroupID`, `Groups`.`Name`, `Groups`.`Owner`, `Groups`.`CreationDate`
+    FROM `Groups` JOIN `GroupMembers`
+        ON `Groups`.`GroupID` = `GroupMembers`.`GroupID`
+    WHERE `GroupMembers`.`Username` = username
+    ORDER BY `Groups`.`GroupID`;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `file_get_info` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_invites` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -191,18 +2826,21 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `file_get_info`(IN fileID bigint(20))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_invites`(IN username varchar(25))
   BEGIN
-    SELECT `File`.`Creator`, `File`.`CreationDate`, `File`.`RelativePath`, `File`.`ProjectID`, `File`.`Filename`
-    FROM File
-    WHERE `File`.`FileID` = fileID;
+    SELECT `Invites`.`InviteID`, `Invites`.`ProjectID`, `Project`.`Name`, IFNULL(`Invites`.`Username`, ''), IFNULL(`Invites`.`Email`, ''),
+      `Invites`.`PermissionLevel`, `Invites`.`InvitedBy`, `Invites`.`CreationDate`, `Invites`.`ExpiryDate`
+    FROM `Invites` JOIN `Project`
+        ON `Invites`.`ProjectID` = `Project`.`ProjectID`
+    WHERE `Invites`.`Username` = username AND `Invites`.`ExpiryDate` > UTC_TIMESTAMP()
+    ORDER BY `Invites`.`InviteID`;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `file_move` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_lookup` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -212,18 +2850,17 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `file_move`(IN fileID bigint(20), IN newPath varchar(2083))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_lookup`(IN username varchar(25))
   BEGIN
-    UPDATE `File`
-    SET `File`.RelativePath = newPath
-    WHERE `File`.FileID = fileID;
+    SELECT FirstName, LastName, IFNULL(Email, ''), Username, Bot, IFNULL(BotOwner, ''), Status, HiddenFromSearch
+    FROM User where User.Username = username;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `file_rename` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_lookup_email` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -233,18 +2870,17 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `file_rename`(IN fileID bigint(20), IN newName varchar(50))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_lookup_email`(IN email varchar(50))
   BEGIN
-    UPDATE `File`
-    SET `File`.Filename = newName
-    WHERE `File`.FileID = fileID;
+    SELECT FirstName, LastName, IFNULL(Email, ''), Username, Bot, IFNULL(BotOwner, ''), Status, HiddenFromSearch
+    FROM User where User.Email = email;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `project_create` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_projects` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -254,18 +2890,33 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `project_create`(IN projectName varchar(50), IN username varchar(25))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_projects`(IN username varchar(25))
   BEGIN
-    INSERT INTO Project (`Name`, `Owner`)
-    VALUES (projectName, username);
-    SELECT LAST_INSERT_ID();
+    SELECT `Project`.`ProjectID`, `Project`.`Name`, MAX(`Levels`.`PermissionLevel`)
+    FROM (
+      SELECT `Permissions`.`ProjectID`, `Permissions`.`PermissionLevel`
+      FROM `Permissions`
+      WHERE `Permissions`.`Username` = username
+      UNION ALL
+      SELECT `GroupPermissions`.`ProjectID`, `GroupPermissions`.`PermissionLevel`
+      FROM `GroupPermissions` JOIN `GroupMembers`
+          ON `GroupPermissions`.`GroupID` = `GroupMembers`.`GroupID`
+      WHERE `GroupMembers`.`Username` = username
+      UNION ALL
+      SELECT `Project`.`ProjectID`, 10
+      FROM `Project`
+      WHERE `Project`.`Owner` = username
+    ) AS Levels JOIN `Project`
+        ON `Levels`.`ProjectID` = `Project`.`ProjectID`
+    WHERE `Project`.`DeletedDate` IS NULL
+    GROUP BY `Project`.`ProjectID`, `Project`.`Name`;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `project_delete` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_project_permission` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -275,18 +2926,58 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `project_delete`(IN projectID bigint(20),
-                                                             IN revokeUsername varchar(25))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_project_permission`(username varchar(25), projectID bigint(20))
+BEGIN
+  SELECT MAX(Levels.PermissionLevel)
+  FROM (
+    SELECT Permissions.PermissionLevel
+    FROM Permissions
+    WHERE Permissions.Username = username and Permissions.ProjectID = projectID
+    UNION ALL
+    SELECT GroupPermissions.PermissionLevel
+    FROM GroupPermissions JOIN GroupMembers
+        ON GroupPermissions.GroupID = GroupMembers.GroupID
+    WHERE GroupMembers.Username = username and GroupPermissions.ProjectID = projectID
+    UNION ALL
+    SELECT 10
+    FROM Project
+    WHERE Project.ProjectID = projectID and Project.Owner = username
+  ) AS Levels JOIN Project
+      ON Project.ProjectID = projectID
+  WHERE Project.DeletedDate IS NULL
+  HAVING MAX(Levels.PermissionLevel) IS NOT NULL;
+END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `user_register` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_register`(IN username varchar(25),
+                                                            IN pass varchar(100),
+                                                            IN email varchar(50),
+                                                            IN firstName varchar(30),
+                                                            IN lastName varchar(30),
+                                                            IN userStatus varchar(16))
   BEGIN
-    DELETE FROM Project
-    WHERE Project.ProjectID = projectID AND Project.Owner = revokeUsername;
+    INSERT INTO User (`Username`, `Password`, `Email`, `FirstName`, `LastName`, `Status`)
+    VALUES (username, pass, email, firstName, lastName, userStatus);
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `project_get_files` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_register_bot` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -296,18 +2987,20 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `project_get_files`(IN projectID bigint(20))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_register_bot`(IN username varchar(25),
+                                                                IN firstName varchar(30),
+                                                                IN lastName varchar(30),
+                                                                IN botOwner varchar(25))
   BEGIN
-    SELECT *
-    FROM File
-    WHERE File.ProjectID = projectID;
+    INSERT INTO User (`Username`, `Password`, `Email`, `FirstName`, `LastName`, `Bot`, `BotOwner`)
+    VALUES (username, '', NULL, firstName, lastName, 1, botOwner);
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `project_grant_permissions` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_rename` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -317,24 +3010,46 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `project_grant_permissions`(IN projectID bigint(20),
-                                                                        IN grantUsername varchar(25),
-                                                                        IN permissionLevel tinyint(1),
-                                                                        IN grantedByUsername varchar(25))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_rename`(IN oldUsername varchar(25), IN newUsername varchar(25))
   BEGIN
-    insert into `Permissions`
-    (Username, ProjectID, PermissionLevel, GrantedBy)
-    values (grantUsername, projectID, permissionLevel, grantedByUsername)
-    on duplicate key update
-      PermissionLevel = permissionLevel,
-      GrantedBy = grantedByUsername;
+    DECLARE renamed int DEFAULT 0;
+
+    -- updates cannot cascade through the self-referencing BotOwner key, so bots are moved to the new name by hand
+    DROP TEMPORARY TABLE IF EXISTS `RenamedBots`;
+    CREATE TEMPORARY TABLE `RenamedBots` (PRIMARY KEY (`Username`))
+      SELECT `User`.`Username` FROM `User` WHERE `User`.`BotOwner` = oldUsername;
+    UPDATE `User` SET `User`.`BotOwner` = NULL WHERE `User`.`BotOwner` = oldUsername;
+
+    UPDATE `User` SET `User`.`Username` = newUsername WHERE `User`.`Username` = oldUsername;
+    SET renamed = ROW_COUNT();
+
+    UPDATE `User` JOIN `RenamedBots` ON `RenamedBots`.`Username` = `User`.`Username`
+    SET `User`.`BotOwner` = newUsername;
+    DROP TEMPORARY TABLE `RenamedBots`;
+
+    -- columns recording who did something are not foreign keys, so that they outlive the user
+    IF renamed > 0 THEN
+      UPDATE `Permissions` SET `Permissions`.`GrantedBy` = newUsername, `Permissions`.`GrantedDate` = `Permissions`.`GrantedDate`
+      WHERE `Permissions`.`GrantedBy` = oldUsername;
+      UPDATE `GroupPermissions` SET `GroupPermissions`.`GrantedBy` = newUsername, `GroupPermissions`.`GrantedDate` = `GroupPermissions`.`GrantedDate`
+      WHERE `GroupPermissions`.`GrantedBy` = oldUsername;
+      UPDATE `PathPermissions` SET `PathPermissions`.`GrantedBy` = newUsername WHERE `PathPermissions`.`GrantedBy` = oldUsername;
+      UPDATE `GroupMembers` SET `GroupMembers`.`AddedBy` = newUsername WHERE `GroupMembers`.`AddedBy` = oldUsername;
+      UPDATE `Invites` SET `Invites`.`InvitedBy` = newUsername WHERE `Invites`.`InvitedBy` = oldUsername;
+      UPDATE `File` SET `File`.`DeletedBy` = newUsername WHERE `File`.`DeletedBy` = oldUsername;
+
+      REPLACE INTO `UsernameTombstones` (`Username`, `RenamedTo`, `RenamedDate`)
+      VALUES (oldUsername, newUsername, UTC_TIMESTAMP());
+    END IF;
+
+    SELECT renamed;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `project_lookup` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_search` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -344,23 +3059,47 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `project_lookup`(IN projectID bigint(20))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_search`(IN searchText varchar(50),
+                                                          IN likeText varchar(100),
+                                                          IN projectID bigint(20),
+                                                          IN numOffset int,
+                                                          IN numLimit int)
   BEGIN
-    SELECT `Project`.`Name`, `Permissions`.`Username`, `Permissions`.`PermissionLevel`, `Permissions`.`GrantedBy`, `Permissions`.`GrantedDate`
-    FROM Project JOIN Permissions
-        ON Project.ProjectID = Permissions.ProjectID
-    WHERE Project.ProjectID = projectID
-    UNION
-    SELECT `Project`.`Name`, `Project`.`Owner`, 10, `Project`.`Owner`, 0
-    FROM `Project`
-    WHERE `Project`.`ProjectID` = projectID;
+    SELECT `Matches`.`Username`, `Matches`.`FirstName`, `Matches`.`LastName`, `Matches`.`Bot`,
+      EXISTS(SELECT 1 FROM `Permissions`
+             WHERE `Permissions`.`ProjectID` = projectID AND `Permissions`.`Username` = `Matches`.`Username`)
+      OR EXISTS(SELECT 1 FROM `GroupMembers`
+                  JOIN `GroupPermissions` ON `GroupPermissions`.`GroupID` = `GroupMembers`.`GroupID`
+                WHERE `GroupPermissions`.`ProjectID` = projectID AND `GroupMembers`.`Username` = `Matches`.`Username`)
+    FROM (
+      -- lower ranks are better matches: the exact username, then prefixes, then substrings, then similar sounding
+      SELECT `User`.`Username`, `User`.`FirstName`, `User`.`LastName`, `User`.`Bot`,
+        CASE
+          WHEN `User`.`Username` = searchText THEN 0
+          WHEN `User`.`Username` LIKE CONCAT(likeText, '%') THEN 1
+          WHEN `User`.`FirstName` LIKE CONCAT(likeText, '%') OR `User`.`LastName` LIKE CONCAT(likeText, '%')
+               OR CONCAT(`User`.`FirstName`, ' ', `User`.`LastName`) LIKE CONCAT(likeText, '%')
+               OR `User`.`Email` LIKE CONCAT(likeText, '%') THEN 2
+          WHEN `User`.`Username` LIKE CONCAT('%', likeText, '%')
+               OR CONCAT(`User`.`FirstName`, ' ', `User`.`LastName`) LIKE CONCAT('%', likeText, '%') THEN 3
+          WHEN SOUNDEX(searchText) <> ''
+               AND (SOUNDEX(`User`.`Username`) = SOUNDEX(searchText) OR SOUNDEX(`User`.`FirstName`) = SOUNDEX(searchText)
+                    OR SOUNDEX(`User`.`LastName`) = SOUNDEX(searchText)) THEN 4
+          ELSE NULL
+        END AS `MatchRank`
+      FROM `User`
+      WHERE `User`.`HiddenFromSearch` = 0
+    ) AS `Matches`
+    WHERE `Matches`.`MatchRank` IS NOT NULL
+    ORDER BY `Matches`.`MatchRank`, `Matches`.`Username`
+    LIMIT numOffset, numLimit;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `project_rename` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_set_email` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -370,18 +3109,16 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `project_rename`(IN projectID bigint(20), IN newName varchar(50))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_set_email`(IN username varchar(25), IN email varchar(50))
   BEGIN
-    UPDATE Project
-    SET `Name` = newName
-    WHERE Project.ProjectID = projectID;
+    UPDATE `User` SET `User`.`Email` = email WHERE `User`.`Username` = username;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `project_revoke_permissions` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_set_hidden_from_search` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -391,19 +3128,16 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `project_revoke_permissions`(IN projectID bigint(20),
-                                                                         IN revokeUsername varchar(25))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_set_hidden_from_search`(IN username varchar(25), IN hidden tinyint(1))
   BEGIN
-    DELETE FROM Permissions
-    WHERE Permissions.ProjectID = projectID
-          AND Permissions.Username = revokeUsername;
+    UPDATE `User` SET `User`.`HiddenFromSearch` = hidden WHERE `User`.`Username` = username;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `user_delete` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_set_status` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -413,17 +3147,17 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `user_delete`(IN username varchar(25))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_set_status`(IN username varchar(25), IN userStatus varchar(16))
   BEGIN
-    DELETE FROM `User`
-    WHERE `User`.Username = username;
+    UPDATE `User` SET `User`.`Status` = userStatus
+    WHERE `User`.`Username` = username AND `User`.`Status` <> userStatus;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `user_get_password` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_trashed_projects` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -433,17 +3167,19 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `user_get_password`(IN username varchar(25))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_trashed_projects`(IN username varchar(25))
   BEGIN
-    SELECT Password
-    FROM User where User.Username = username;
+    SELECT `Project`.`ProjectID`, `Project`.`Name`, `Project`.`Owner`, `Project`.`DeletedDate`
+    FROM `Project`
+    WHERE `Project`.`Owner` = username AND `Project`.`DeletedDate` IS NOT NULL
+    ORDER BY `Project`.`DeletedDate` DESC;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `user_get_projectids` */;
+/*!50003 DROP PROCEDURE IF EXISTS `user_update_profile` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -453,17 +3189,20 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `user_get_projectids`(IN username varchar(25))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `user_update_profile`(IN username varchar(25),
+                                                                  IN firstName varchar(30),
+                                                                  IN lastName varchar(30))
   BEGIN
-    SELECT `Project`.`ProjectID` FROM `Project`
-      WHERE `Project`.`Owner` = username;
+    UPDATE `User`
+    SET `User`.`FirstName` = firstName, `User`.`LastName` = lastName
+    WHERE `User`.`Username` = username;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `user_lookup` */;
+/*!50003 DROP PROCEDURE IF EXISTS `username_tombstone_get` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -473,17 +3212,18 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `user_lookup`(IN username varchar(25))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `username_tombstone_get`(IN username varchar(25))
   BEGIN
-    SELECT FirstName, LastName, Email, Username
-    FROM User where User.Username = username;
+    SELECT `UsernameTombstones`.`RenamedTo`, `UsernameTombstones`.`RenamedDate`
+    FROM `UsernameTombstones`
+    WHERE `UsernameTombstones`.`Username` = username;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `user_projects` */;
+/*!50003 DROP PROCEDURE IF EXISTS `webhook_create` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -493,22 +3233,20 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `user_projects`(IN username varchar(25))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `webhook_create`(IN projectID bigint(20), IN url varchar(2000),
+                                                             IN secret varchar(100), IN events varchar(1000),
+                                                             IN createdBy varchar(25))
   BEGIN
-    SELECT `Project`.`ProjectID`, `Project`.`Name`, `Permissions`.`PermissionLevel`
-    FROM (Permissions LEFT JOIN Project ON Permissions.ProjectID = Project.ProjectID)
-    WHERE Permissions.Username = username
-    UNION
-    SELECT `Project`.`ProjectID`, `Project`.`Name`, 10
-    FROM `Project`
-    WHERE `Project`.`Owner` = username;
+    INSERT INTO `Webhooks` (`ProjectID`, `URL`, `Secret`, `Events`, `CreatedBy`)
+    VALUES (projectID, url, secret, events, createdBy);
+    SELECT LAST_INSERT_ID();
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `user_project_permission` */;
+/*!50003 DROP PROCEDURE IF EXISTS `webhook_delete` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -518,22 +3256,16 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `user_project_permission`(username varchar(25), projectID bigint(20))
-BEGIN
-  SELECT Permissions.PermissionLevel
-    FROM Permissions
-    WHERE Permissions.Username = username and Permissions.ProjectID = projectID
-    UNION
-    SELECT 10
-    FROM Project
-    WHERE Project.ProjectID = projectID and Project.Owner = username;
-END ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `webhook_delete`(IN projectID bigint(20), IN webhookID bigint(20))
+  BEGIN
+    DELETE FROM `Webhooks` WHERE `Webhooks`.`ProjectID` = projectID AND `Webhooks`.`WebhookID` = webhookID;
+  END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
 /*!50003 SET character_set_client  = @saved_cs_client */ ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
-/*!50003 DROP PROCEDURE IF EXISTS `user_register` */;
+/*!50003 DROP PROCEDURE IF EXISTS `webhook_delivery_get` */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -543,13 +3275,92 @@ DELIMITER ;
 /*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
 /*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
 DELIMITER ;;
-CREATE DEFINER=`root`@`localhost` PROCEDURE `user_register`(IN username varchar(25),
-                                                            IN pass varchar(100),
-                                                            IN email varchar(50),
-                                                            IN firstName varchar(30),
-                                                            IN lastName varchar(30))
+CREATE DEFINER=`root`@`localhost` PROCEDURE `webhook_delivery_get`(IN webhookID bigint(20), IN numLimit int)
+  BEGIN
+    SELECT `WebhookDeliveries`.`DeliveryID`, `WebhookDeliveries`.`Event`, `WebhookDeliveries`.`Attempts`,
+      `WebhookDeliveries`.`StatusCode`, `WebhookDeliveries`.`Error`, `WebhookDeliveries`.`Success`,
+      `WebhookDeliveries`.`CompletionDate`
+    FROM `WebhookDeliveries`
+    WHERE `WebhookDeliveries`.`WebhookID` = webhookID
+    ORDER BY `WebhookDeliveries`.`DeliveryID` DESC
+    LIMIT numLimit;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `webhook_delivery_insert` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `webhook_delivery_insert`(IN webhookID bigint(20), IN event varchar(64),
+                                                                      IN attempts int, IN statusCode int,
+                                                                      IN deliveryError varchar(1000),
+                                                                      IN success tinyint(1), IN keepDeliveries int)
+  BEGIN
+    INSERT INTO `WebhookDeliveries` (`WebhookID`, `Event`, `Attempts`, `StatusCode`, `Error`, `Success`)
+    VALUES (webhookID, event, attempts, statusCode, LEFT(deliveryError, 1000), success);
+
+    -- only the latest deliveries of each webhook are kept
+    DELETE FROM `WebhookDeliveries`
+    WHERE `WebhookDeliveries`.`WebhookID` = webhookID
+          AND `WebhookDeliveries`.`DeliveryID` <= (
+            SELECT `Oldest`.`DeliveryID` FROM (
+              SELECT `DeliveryID` FROM `WebhookDeliveries`
+              WHERE `WebhookDeliveries`.`WebhookID` = webhookID
+              ORDER BY `DeliveryID` DESC
+              LIMIT keepDeliveries, 1
+            ) AS `Oldest`
+          );
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `webhook_get_project` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `webhook_get_project`(IN projectID bigint(20))
+  BEGIN
+    SELECT `Webhooks`.`WebhookID`, `Webhooks`.`URL`, `Webhooks`.`Secret`, `Webhooks`.`Events`,
+      `Webhooks`.`CreatedBy`, `Webhooks`.`CreationDate`
+    FROM `Webhooks`
+    WHERE `Webhooks`.`ProjectID` = projectID
+    ORDER BY `Webhooks`.`WebhookID`;
+  END ;;
+DELIMITER ;
+/*!50003 SET sql_mode              = @saved_sql_mode */ ;
+/*!50003 SET character_set_client  = @saved_cs_client */ ;
+/*!50003 SET character_set_results = @saved_cs_results */ ;
+/*!50003 SET collation_connection  = @saved_col_connection */ ;
+/*!50003 DROP PROCEDURE IF EXISTS `webhook_project_ids` */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+/*!50003 SET @saved_cs_results     = @@character_set_results */ ;
+/*!50003 SET @saved_col_connection = @@collation_connection */ ;
+/*!50003 SET character_set_client  = utf8 */ ;
+/*!50003 SET character_set_results = utf8 */ ;
+/*!50003 SET collation_connection  = utf8_general_ci */ ;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+/*!50003 SET sql_mode              = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION' */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` PROCEDURE `webhook_project_ids`()
   BEGIN
-    INSERT INTO User VALUES (username, pass, email, firstName, lastName);
+    SELECT DISTINCT `Webhooks`.`ProjectID` FROM `Webhooks`;
   END ;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
@@ -33,6 +33,18 @@ func LoadConfig() error {
 	})
 	config, err = parseConfig(configDir)
 
+	if err == nil {
+		err = config.ServerConfig.RateLimits.Validate()
+	}
+
+	if err == nil {
+		roles := config.ServerConfig.Roles
+		if len(roles) == 0 {
+			roles = DefaultRoles
+		}
+		err = setRoles(roles)
+	}
+
 	if err == nil {
 		utils.LogInfo("Loaded Configuration", utils.LogFields{
 			"ServerConfig": pretty.Sprint(config.ServerConfig),
@@ -1,6 +1,11 @@
 package config
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 /**
  * Models for the configuration CodeCollaborate Server.
@@ -27,11 +32,286 @@ type ServerCfg struct {
 	TokenValidity   string
 	MinBufferLength int
 	MaxBufferLength int
+	ShutdownTimeout string
+	ReconnectDelay  string
+	TrashRetention  string
+	FileLockTTL     string
+
+	// TrustForwardedFor uses the X-Forwarded-For header as the client's IP address; enable only behind a proxy.
+	TrustForwardedFor bool
+	RateLimits        RateLimitCfg
+
+	// Roles are the project roles users can be granted; DefaultRoles are used if none are configured
+	Roles []Role
+
+	// OIDCProviders are the OpenID Connect identity providers users can log in with, keyed on a short name used in
+	// their login URLs
+	OIDCProviders map[string]OIDCProviderCfg
+
+	// Authentication selects how passwords are checked when users log in
+	Authentication AuthenticationCfg
+
+	// Registration configures email verification and password rules for users registering with User.Register
+	Registration RegistrationCfg
+
+	// Mail configures how emails, such as verification links, are sent
+	Mail MailCfg
+
+	// LoginProtection configures the backoff and lockout applied after failed logins
+	LoginProtection LoginProtectionCfg
+
+	// Administrators are the usernames of the server's administrators, who can unlock users with User.Unlock
+	Administrators []string
+
+	// Webhooks configures how events are delivered to project webhooks
+	Webhooks WebhooksCfg
 
 	// Parsed validity
 	tokenValidityDuration time.Duration
 }
 
+// RateBudget is a token bucket budget; requests are allowed at Rate per second, in bursts of up to Burst.
+// A Rate of 0 disables the limit.
+type RateBudget struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitCfg configures the per-websocket, per-user and per-IP request rate limits
+type RateLimitCfg struct {
+	// Default is the budget shared by all requests from a single websocket, user or IP
+	Default RateBudget
+
+	// Methods are additional budgets for specific methods, keyed on Resource.Method
+	Methods map[string]RateBudget
+
+	// DisconnectAfter is the number of throttled requests per minute tolerated before a websocket is disconnected.
+	// 0 disables disconnection.
+	DisconnectAfter int
+}
+
+// Validate checks that every enabled budget allows at least one request; a Burst of 0 would throttle every request
+func (cfg RateLimitCfg) Validate() error {
+	if cfg.Default.Rate > 0 && cfg.Default.Burst <= 0 {
+		return errors.New("Default rate limit must have a positive burst")
+	}
+	for method, budget := range cfg.Methods {
+		if budget.Rate > 0 && budget.Burst <= 0 {
+			return fmt.Errorf("Rate limit for %q must have a positive burst", method)
+		}
+	}
+	return nil
+}
+
+// OIDCProviderCfg configures an OpenID Connect identity provider
+type OIDCProviderCfg struct {
+	// Issuer is the provider's issuer URL, which its discovery document is served under
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback registered with the provider, i.e. https://<Host>/oidc/<name>/callback
+	RedirectURL string
+	// Scopes are requested in addition to "openid"; defaults to "email" and "profile"
+	Scopes []string
+
+	// AutoRegister creates a local user the first time an unknown identity logs in
+	AutoRegister bool
+	// LinkVerifiedEmail links unknown identities to the local user with the same email address, if the provider
+	// has verified it
+	LinkVerifiedEmail bool
+}
+
+// Authentication backends
+const (
+	// AuthBackendPassword checks passwords against the bcrypt hashes stored in MySQL
+	AuthBackendPassword = "password"
+	// AuthBackendLDAP checks passwords by binding to an LDAP directory as the user
+	AuthBackendLDAP = "ldap"
+)
+
+// AuthenticationCfg configures the authentication backend used by User.Login
+type AuthenticationCfg struct {
+	// Backend is AuthBackendPassword or AuthBackendLDAP; defaults to AuthBackendPassword
+	Backend string
+	LDAP    LDAPCfg
+}
+
+// LDAPCfg configures authentication against an LDAP directory with simple binds
+type LDAPCfg struct {
+	// URL is the address of the directory server, as ldap://host:port or ldaps://host:port
+	URL string
+	// UserDNTemplate is the DN users bind as, with %s replaced by their escaped username,
+	// e.g. "uid=%s,ou=people,dc=example,dc=com"
+	UserDNTemplate string
+	// Timeout bounds each login's exchange with the directory server; defaults to DefaultLDAPTimeout
+	Timeout string
+
+	// EmailAttribute, FirstNameAttribute, LastNameAttribute and GroupAttribute are the attributes of the user's
+	// entry read on login; they default to mail, givenName, sn and memberOf.
+	EmailAttribute     string
+	FirstNameAttribute string
+	LastNameAttribute  string
+	GroupAttribute     string
+
+	// AutoProvision registers a local user the first time a directory user logs in
+	AutoProvision bool
+	// GroupRoles grants project roles to the members of directory groups, keyed on the group's DN. Roles are
+	// granted on login, and never lower a user's existing permissions.
+	GroupRoles map[string][]LDAPGroupRole
+}
+
+// LDAPGroupRole is a role on a project granted to the members of a directory group
+type LDAPGroupRole struct {
+	ProjectID int64
+	Role      string
+}
+
+// Policies for users who have not verified their email address
+const (
+	// UnverifiedAllow lets unverified users do everything verified users can
+	UnverifiedAllow = "allow"
+	// UnverifiedBlockProjects stops unverified users from creating projects
+	UnverifiedBlockProjects = "block-projects"
+	// UnverifiedBlockLogin stops unverified users from logging in
+	UnverifiedBlockLogin = "block-login"
+)
+
+// RegistrationCfg configures how users registering with User.Register are verified
+type RegistrationCfg struct {
+	// RequireEmailVerification registers users as pending, and emails them a token to verify their address with
+	RequireEmailVerification bool
+	// UnverifiedPolicy is one of UnverifiedAllow, UnverifiedBlockProjects or UnverifiedBlockLogin; defaults to
+	// UnverifiedAllow
+	UnverifiedPolicy string
+	// VerificationValidity is how long verification tokens can be used for; defaults to DefaultVerificationValidity
+	VerificationValidity string
+	// VerificationURL is the link emailed to users, with %s replaced by their token. If empty, the token is emailed
+	// on its own, for the user to enter in their client.
+	VerificationURL string
+
+	PasswordPolicy PasswordPolicyCfg
+}
+
+// PasswordPolicyCfg is the strength rules passwords of users registering with User.Register must meet
+type PasswordPolicyCfg struct {
+	// MinLength is the minimum number of characters; defaults to DefaultPasswordMinLength
+	MinLength int
+	// RequireUpper, RequireLower, RequireDigit and RequireSymbol require at least one character of each kind
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// RejectUsername rejects passwords which contain the username
+	RejectUsername bool
+}
+
+// LoginProtectionCfg configures the backoff and lockout applied to usernames and IP addresses after failed logins.
+// Unknown usernames are treated the same as existing ones.
+type LoginProtectionCfg struct {
+	// MaxFailures is the number of consecutive failed logins after which a username is locked; defaults to
+	// DefaultLoginMaxFailures
+	MaxFailures int
+	// IPMaxFailures is the number of consecutive failed logins after which an IP address is locked; defaults to
+	// DefaultLoginIPMaxFailures. It is higher than MaxFailures, since many users may share an address.
+	IPMaxFailures int
+	// Lockout is how long locks last; defaults to DefaultLoginLockout
+	Lockout string
+	// Backoff is the delay required after a username's first failed login, doubling with each further failure up
+	// to MaxBackoff; they default to DefaultLoginBackoff and DefaultLoginMaxBackoff
+	Backoff    string
+	MaxBackoff string
+	// ResetAfter is how long after the last failed login the count restarts; defaults to DefaultLoginResetAfter
+	ResetAfter string
+}
+
+// WebhooksCfg configures the delivery of project events to webhooks. Failed deliveries are retried with exponential
+// backoff.
+type WebhooksCfg struct {
+	// MaxAttempts is the number of times each delivery is attempted; defaults to DefaultWebhookMaxAttempts
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubling with each further retry up to MaxBackoff; they default
+	// to DefaultWebhookBackoff and DefaultWebhookMaxBackoff
+	Backoff    string
+	MaxBackoff string
+	// Timeout is the time allowed for each attempt; defaults to DefaultWebhookTimeout
+	Timeout string
+	// ChangeDebounce is how long a file must go without changes before its File.Change events are delivered, as a
+	// single event; defaults to DefaultWebhookChangeDebounce
+	ChangeDebounce string
+}
+
+// Mail backends
+const (
+	// MailBackendLog logs emails instead of sending them, for development
+	MailBackendLog = "log"
+	// MailBackendSMTP sends emails through an SMTP server
+	MailBackendSMTP = "smtp"
+)
+
+// MailCfg configures how emails are sent
+type MailCfg struct {
+	// Backend is MailBackendLog or MailBackendSMTP; defaults to MailBackendLog
+	Backend string
+	// From is the address emails are sent from
+	From string
+	SMTP SMTPCfg
+}
+
+// SMTPCfg configures the SMTP server emails are sent through. STARTTLS is used if the server supports it.
+type SMTPCfg struct {
+	Host string
+	// Port defaults to 587
+	Port uint16
+	// Username and Password are used to authenticate, if set. Credentials are only sent over TLS.
+	Username string
+	Password string
+	// Timeout bounds the exchange with the server for each email; defaults to DefaultSMTPTimeout
+	Timeout string
+}
+
+// DefaultShutdownTimeout is the time allowed for in-flight work to complete at shutdown, if none is configured
+const DefaultShutdownTimeout = 30 * time.Second
+
+// DefaultReconnectDelay is the delay clients are asked to wait before reconnecting after a shutdown, if none is configured
+const DefaultReconnectDelay = 5 * time.Second
+
+// DefaultTrashRetention is the time deleted projects and files are kept in the trash for, if none is configured
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// DefaultFileLockTTL is how long file locks are held for unless they are renewed, if none is configured
+const DefaultFileLockTTL = 2 * time.Minute
+
+// DefaultLDAPTimeout is the time allowed for each login's exchange with an LDAP server, if none is configured
+const DefaultLDAPTimeout = 10 * time.Second
+
+// DefaultVerificationValidity is the time email verification tokens can be used for, if none is configured
+const DefaultVerificationValidity = 24 * time.Hour
+
+// DefaultPasswordMinLength is the minimum length of passwords, if none is configured
+const DefaultPasswordMinLength = 8
+
+// DefaultSMTPTimeout is the time allowed for sending each email through an SMTP server, if none is configured
+const DefaultSMTPTimeout = 30 * time.Second
+
+// Login protection defaults, used if none are configured
+const (
+	DefaultLoginMaxFailures   = 10
+	DefaultLoginIPMaxFailures = 100
+	DefaultLoginLockout       = 15 * time.Minute
+	DefaultLoginBackoff       = time.Second
+	DefaultLoginMaxBackoff    = time.Minute
+	DefaultLoginResetAfter    = time.Hour
+)
+
+// Webhook delivery defaults, used if none are configured
+const (
+	DefaultWebhookMaxAttempts    = 5
+	DefaultWebhookBackoff        = 10 * time.Second
+	DefaultWebhookMaxBackoff     = 10 * time.Minute
+	DefaultWebhookTimeout        = 10 * time.Second
+	DefaultWebhookChangeDebounce = 5 * time.Second
+)
+
 // TokenValidityDuration parses the given duration, and returns the time.Duration struct, or an error.
 func (cfg ServerCfg) TokenValidityDuration() (time.Duration, error) {
 	if cfg.tokenValidityDuration != 0 {
@@ -43,6 +323,101 @@ func (cfg ServerCfg) TokenValidityDuration() (time.Duration, error) {
 	return cfg.tokenValidityDuration, err
 }
 
+// ShutdownTimeoutDuration parses the shutdown timeout, returning DefaultShutdownTimeout if none was set.
+func (cfg ServerCfg) ShutdownTimeoutDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.ShutdownTimeout, DefaultShutdownTimeout)
+}
+
+// ReconnectDelayDuration parses the reconnect delay, returning DefaultReconnectDelay if none was set.
+func (cfg ServerCfg) ReconnectDelayDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.ReconnectDelay, DefaultReconnectDelay)
+}
+
+// TrashRetentionDuration parses the trash retention period, returning DefaultTrashRetention if none was set.
+func (cfg ServerCfg) TrashRetentionDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.TrashRetention, DefaultTrashRetention)
+}
+
+// FileLockTTLDuration parses the file lock TTL, returning DefaultFileLockTTL if none was set.
+func (cfg ServerCfg) FileLockTTLDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.FileLockTTL, DefaultFileLockTTL)
+}
+
+// TimeoutDuration parses the LDAP timeout, returning DefaultLDAPTimeout if none was set.
+func (cfg LDAPCfg) TimeoutDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.Timeout, DefaultLDAPTimeout)
+}
+
+// VerificationValidityDuration parses the verification token validity, returning DefaultVerificationValidity if none
+// was set.
+func (cfg RegistrationCfg) VerificationValidityDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.VerificationValidity, DefaultVerificationValidity)
+}
+
+// LockoutDuration parses the lockout period, returning DefaultLoginLockout if none was set.
+func (cfg LoginProtectionCfg) LockoutDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.Lockout, DefaultLoginLockout)
+}
+
+// BackoffDuration parses the initial backoff, returning DefaultLoginBackoff if none was set.
+func (cfg LoginProtectionCfg) BackoffDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.Backoff, DefaultLoginBackoff)
+}
+
+// MaxBackoffDuration parses the maximum backoff, returning DefaultLoginMaxBackoff if none was set.
+func (cfg LoginProtectionCfg) MaxBackoffDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.MaxBackoff, DefaultLoginMaxBackoff)
+}
+
+// ResetAfterDuration parses the reset period, returning DefaultLoginResetAfter if none was set.
+func (cfg LoginProtectionCfg) ResetAfterDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.ResetAfter, DefaultLoginResetAfter)
+}
+
+// BackoffDuration parses the initial retry delay, returning DefaultWebhookBackoff if none was set.
+func (cfg WebhooksCfg) BackoffDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.Backoff, DefaultWebhookBackoff)
+}
+
+// MaxBackoffDuration parses the maximum retry delay, returning DefaultWebhookMaxBackoff if none was set.
+func (cfg WebhooksCfg) MaxBackoffDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.MaxBackoff, DefaultWebhookMaxBackoff)
+}
+
+// TimeoutDuration parses the attempt timeout, returning DefaultWebhookTimeout if none was set.
+func (cfg WebhooksCfg) TimeoutDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.Timeout, DefaultWebhookTimeout)
+}
+
+// ChangeDebounceDuration parses the File.Change debounce period, returning DefaultWebhookChangeDebounce if none was
+// set.
+func (cfg WebhooksCfg) ChangeDebounceDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.ChangeDebounce, DefaultWebhookChangeDebounce)
+}
+
+// IsAdministrator returns true if the user is one of the configured Administrators
+func (cfg ServerCfg) IsAdministrator(username string) bool {
+	for _, admin := range cfg.Administrators {
+		if strings.EqualFold(admin, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDurationOr parses the duration, returning the fallback if it is empty
+func parseDurationOr(duration string, fallback time.Duration) (time.Duration, error) {
+	if duration == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(duration)
+}
+
+// TimeoutDuration parses the SMTP timeout, returning DefaultSMTPTimeout if none was set.
+func (cfg SMTPCfg) TimeoutDuration() (time.Duration, error) {
+	return parseDurationOr(cfg.Timeout, DefaultSMTPTimeout)
+}
+
 // ConnCfg represents the information required to make a connection
 type ConnCfg struct {
 	Host       string
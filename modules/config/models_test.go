@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitCfg_Validate(t *testing.T) {
+	assert.Nil(t, RateLimitCfg{}.Validate(), "disabled limits should be valid")
+	assert.Nil(t, RateLimitCfg{
+		Default: RateBudget{Rate: 10, Burst: 20},
+		Methods: map[string]RateBudget{"File.Change": {Rate: 5, Burst: 5}},
+	}.Validate())
+
+	assert.Error(t, RateLimitCfg{Default: RateBudget{Rate: 10}}.Validate(), "a burst of 0 should be rejected")
+	assert.Error(t, RateLimitCfg{
+		Default: RateBudget{Rate: 10, Burst: 20},
+		Methods: map[string]RateBudget{"File.Change": {Rate: 5}},
+	}.Validate(), "a method burst of 0 should be rejected")
+}
+
+func TestDurations(t *testing.T) {
+	cfg := ServerCfg{}
+	shutdownTimeout, err := cfg.ShutdownTimeoutDuration()
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultShutdownTimeout, shutdownTimeout)
+
+	cfg.ShutdownTimeout = "5s"
+	shutdownTimeout, err = cfg.ShutdownTimeoutDuration()
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Second, shutdownTimeout)
+
+	cfg.ShutdownTimeout = "soon"
+	_, err = cfg.ShutdownTimeoutDuration()
+	assert.Error(t, err)
+}
@@ -1,30 +1,164 @@
 package config
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
-// PermissionsByLabel is the permission constants for API access levels
-var PermissionsByLabel = map[string]int8{
-	"read":  1,
-	"write": 4,
-	"admin": 8,
-	"owner": 10,
+// Capabilities which can be granted to a role
+const (
+	CapabilityRead              = "read"
+	CapabilityComment           = "comment"
+	CapabilitySuggest           = "suggest"
+	CapabilityWrite             = "write"
+	CapabilityManageFiles       = "manage_files"
+	CapabilityRenameProject     = "rename_project"
+	CapabilityManagePermissions = "manage_permissions"
+	CapabilityManageRoles       = "manage_roles"
+	CapabilityDeleteProject     = "delete_project"
+	CapabilityViewAudit         = "view_audit"
+	CapabilityManageWebhooks    = "manage_webhooks"
+	CapabilityBreakLocks        = "break_locks"
+)
+
+// Capabilities is the list of all capabilities known to the server
+var Capabilities = []string{
+	CapabilityRead,
+	CapabilityComment,
+	CapabilitySuggest,
+	CapabilityWrite,
+	CapabilityManageFiles,
+	CapabilityRenameProject,
+	CapabilityManagePermissions,
+	CapabilityManageRoles,
+	CapabilityDeleteProject,
+	CapabilityViewAudit,
+	CapabilityManageWebhooks,
+	CapabilityBreakLocks,
+}
+
+// OwnerLevel is the permission level of a project's owner. It is fixed, since the owner is stored on the project
+// itself rather than as a granted permission.
+const OwnerLevel int8 = 10
+
+// Role is a named set of capabilities, stored against a user's permissions on a project by its Level
+type Role struct {
+	Name         string
+	Level        int8
+	Capabilities []string
+}
+
+// Has returns true if the role grants the given capability
+func (role Role) Has(capability string) bool {
+	for _, c := range role.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the role has a name, a positive level, and only known capabilities
+func (role Role) Validate() error {
+	if role.Name == "" {
+		return errors.New("Role must have a name")
+	}
+	if role.Level <= 0 {
+		return fmt.Errorf("Role %q must have a positive level", role.Name)
+	}
+	for _, c := range role.Capabilities {
+		if !isCapability(c) {
+			return fmt.Errorf("Role %q has unknown capability %q", role.Name, c)
+		}
+	}
+	return nil
+}
+
+func isCapability(capability string) bool {
+	for _, c := range Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
 }
 
+// DefaultRoles are the roles used if none are configured in server.cfg
+var DefaultRoles = []Role{
+	{Name: "read", Level: 1, Capabilities: []string{CapabilityRead}},
+	{Name: "commenter", Level: 2, Capabilities: []string{CapabilityRead, CapabilityComment}},
+	{Name: "suggester", Level: 3, Capabilities: []string{CapabilityRead, CapabilityComment, CapabilitySuggest}},
+	{Name: "write", Level: 4, Capabilities: []string{
+		CapabilityRead, CapabilityComment, CapabilityWrite, CapabilityManageFiles, CapabilityRenameProject}},
+	{Name: "maintainer", Level: 6, Capabilities: []string{
+		CapabilityRead, CapabilityComment, CapabilityWrite, CapabilityManageFiles, CapabilityRenameProject,
+		CapabilityViewAudit}},
+	{Name: "admin", Level: 8, Capabilities: []string{
+		CapabilityRead, CapabilityComment, CapabilityWrite, CapabilityManageFiles, CapabilityRenameProject,
+		CapabilityManagePermissions, CapabilityManageRoles, CapabilityViewAudit, CapabilityManageWebhooks,
+		CapabilityBreakLocks}},
+	{Name: "owner", Level: OwnerLevel, Capabilities: Capabilities},
+}
+
+// PermissionsByLabel is the permission constants for API access levels, derived from the configured roles
+var PermissionsByLabel map[string]int8
+
 // Permission is the struct representation of an API permission level
 type Permission struct {
 	Level int8
 	Label string
 }
 
-// internal map in other direction
-var byLevel map[int8]string
+// internal maps of the configured roles
+var rolesByLevel map[int8]Role
+var roles []Role
 
 // initialize maps
 func init() {
-	byLevel = make(map[int8]string)
-	for label, level := range PermissionsByLabel {
-		byLevel[level] = label
+	if err := setRoles(DefaultRoles); err != nil {
+		panic(err)
+	}
+}
+
+// setRoles validates the given roles, and replaces the configured roles with them
+func setRoles(newRoles []Role) error {
+	byLabel := make(map[string]int8)
+	byLevel := make(map[int8]Role)
+	for _, role := range newRoles {
+		if err := role.Validate(); err != nil {
+			return err
+		}
+		if _, ok := byLabel[role.Name]; ok {
+			return fmt.Errorf("Role %q is defined twice", role.Name)
+		}
+		if other, ok := byLevel[role.Level]; ok {
+			return fmt.Errorf("Roles %q and %q have the same level", other.Name, role.Name)
+		}
+		byLabel[role.Name] = role.Level
+		byLevel[role.Level] = role
 	}
+	if owner, ok := byLevel[OwnerLevel]; !ok || owner.Name != "owner" {
+		return fmt.Errorf("The owner role must be defined at level %d", OwnerLevel)
+	}
+
+	PermissionsByLabel = byLabel
+	rolesByLevel = byLevel
+	roles = newRoles
+	return nil
+}
+
+// Roles returns the configured roles
+func Roles() []Role {
+	return roles
+}
+
+// RoleByLevel returns the configured role with the given level, if found
+func RoleByLevel(level int8) (Role, error) {
+	role, ok := rolesByLevel[level]
+	if !ok {
+		return Role{}, ErrNoMatchingPermission
+	}
+	return role, nil
 }
 
 // ErrNoMatchingPermission is returned if a permission that does not exist is attempted to be accessed
@@ -32,12 +166,12 @@ var ErrNoMatchingPermission = errors.New("Not a valid server permission level")
 
 // PermissionByLevel returns the string representation of the provided level, if found
 func PermissionByLevel(level int8) (Permission, error) {
-	label, ok := byLevel[level]
+	role, ok := rolesByLevel[level]
 	if !ok {
 		return Permission{}, ErrNoMatchingPermission
 	}
 	return Permission{
-		Label: label,
+		Label: role.Name,
 		Level: level,
 	}, nil
 }
@@ -21,3 +21,29 @@ func TestGetPermissionLevel(t *testing.T) {
 		assert.Equal(t, level, permission.Level, "unexpected permission label")
 	}
 }
+
+func TestRole_Has(t *testing.T) {
+	role := Role{Name: "commenter", Level: 2, Capabilities: []string{CapabilityRead, CapabilityComment}}
+	assert.True(t, role.Has(CapabilityComment))
+	assert.False(t, role.Has(CapabilityWrite))
+}
+
+func TestSetRoles(t *testing.T) {
+	defer setRoles(DefaultRoles)
+
+	owner := Role{Name: "owner", Level: OwnerLevel, Capabilities: Capabilities}
+	reviewer := Role{Name: "reviewer", Level: 3, Capabilities: []string{CapabilityRead, CapabilityComment}}
+	assert.Nil(t, setRoles([]Role{reviewer, owner}))
+	assert.Equal(t, map[string]int8{"reviewer": 3, "owner": OwnerLevel}, PermissionsByLabel)
+	role, err := RoleByLevel(3)
+	assert.Nil(t, err)
+	assert.Equal(t, reviewer, role)
+	_, err = PermissionByLabel("write")
+	assert.Equal(t, ErrNoMatchingPermission, err, "roles should be replaced")
+
+	assert.NotNil(t, setRoles([]Role{reviewer}), "the owner role is required")
+	assert.NotNil(t, setRoles([]Role{reviewer, reviewer, owner}), "role names must be unique")
+	assert.NotNil(t, setRoles([]Role{reviewer, {Name: "other", Level: 3}, owner}), "role levels must be unique")
+	assert.NotNil(t, setRoles([]Role{{Name: "bad", Level: 3, Capabilities: []string{"fly"}}, owner}), "capabilities must be known")
+	assert.Equal(t, []Role{reviewer, owner}, Roles(), "invalid roles should not be applied")
+}
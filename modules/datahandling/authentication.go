@@ -1,12 +1,20 @@
 package datahandling
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/totp"
 	"github.com/dgrijalva/jwt-go"
 )
 
@@ -14,6 +22,11 @@ type tokenPayload struct {
 	Username     string
 	CreationTime int64
 	Validity     int64
+	// ShareLinkID is set for tokens issued to guests redeeming a share link, and limits them to reading its project
+	ShareLinkID int64 `json:",omitempty"`
+	// TwoFactorChallenge is set for the tokens User.Login issues to users with two-factor authentication enabled.
+	// They cannot be used to authenticate, only exchanged for a real token through User.Verify2FA.
+	TwoFactorChallenge bool `json:",omitempty"`
 }
 
 // Valid is the (unused) method to determine if the token is valid. however, since we need to have a reference
@@ -24,7 +37,45 @@ func (tokenPayload) Valid() error {
 }
 
 func authenticate(abs abstractRequest) error {
-	token, err := jwt.ParseWithClaims(abs.SenderToken, &tokenPayload{}, func(token *jwt.Token) (interface{}, error) {
+	_, err := parseToken(abs)
+	return err
+}
+
+// parseToken validates the sender's token, returning its claims
+func parseToken(abs abstractRequest) (*tokenPayload, error) {
+	claims, err := verifyToken(abs.SenderToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check username is the same
+	if !strings.EqualFold(claims.Username, abs.SenderID) {
+		return nil, errors.New("authenticate - senderID did not match token username")
+	}
+	if claims.TwoFactorChallenge {
+		return nil, errors.New("authenticate - two-factor challenge tokens cannot be used to authenticate")
+	}
+	return claims, nil
+}
+
+// checkNotRenamed rejects tokens issued to a username before it was renamed away, since the username may since have
+// been registered by someone else
+func checkNotRenamed(db dbfs.DBFS, claims *tokenPayload) error {
+	tombstone, err := db.MySQLUsernameTombstoneGet(strings.ToLower(claims.Username))
+	if err == dbfs.ErrNoData {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if claims.CreationTime <= tombstone.RenamedDate.Unix() {
+		return errors.New("authenticate - token was issued before the username was renamed")
+	}
+	return nil
+}
+
+// verifyToken checks the signature and validity period of a token, returning its claims
+func verifyToken(signed string) (*tokenPayload, error) {
+	token, err := jwt.ParseWithClaims(signed, &tokenPayload{}, func(token *jwt.Token) (interface{}, error) {
 		// Don't forget to validate the alg is what you expect:
 		if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
 			return nil, fmt.Errorf("ParseWithClaims - Unexpected signing method: %v", token.Header["alg"])
@@ -32,24 +83,21 @@ func authenticate(abs abstractRequest) error {
 		return &privKey.PublicKey, nil
 	})
 	if err != nil {
-		return fmt.Errorf("authenticate - failed to parse token: %s", err)
+		return nil, fmt.Errorf("authenticate - failed to parse token: %s", err)
 	}
 
 	if claims, ok := token.Claims.(*tokenPayload); ok && token.Valid {
-		// Check username is the same, and token is still valid
-		if !strings.EqualFold(claims.Username, abs.SenderID) {
-			return errors.New("authenticate - senderID did not match token username")
-		}
+		// Check token is still valid
 		if time.Unix(claims.CreationTime, 0).After(time.Now()) {
-			return errors.New("authenticate - token not valid yet")
+			return nil, errors.New("authenticate - token not valid yet")
 		}
 		if !time.Unix(claims.Validity, 0).After(time.Now()) {
-			return errors.New("authenticate - expired token")
+			return nil, errors.New("authenticate - expired token")
 		}
-		return nil
+		return claims, nil
 	}
 
-	return errors.New("authenticate - claims struct was not of tokenPayload type")
+	return nil, errors.New("authenticate - claims struct was not of tokenPayload type")
 }
 
 func newAuthToken(username string) (string, error) {
@@ -66,3 +114,320 @@ func newAuthToken(username string) (string, error) {
 
 	return token.SignedString(privKey)
 }
+
+// NewAuthToken returns the token User.Login responds with, for users who log in outside of the websocket API, such as
+// through an OpenID Connect provider
+func NewAuthToken(username string) (string, error) {
+	return newAuthToken(username)
+}
+
+// challengeValidity is the time users have to complete a two-factor login with User.Verify2FA
+const challengeValidity = 5 * time.Minute
+
+// newChallengeToken returns the token User.Login issues to users with two-factor authentication enabled
+func newChallengeToken(username string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, tokenPayload{
+		Username:           username,
+		CreationTime:       time.Now().Unix(),
+		Validity:           time.Now().Add(challengeValidity).Unix(),
+		TwoFactorChallenge: true,
+	})
+
+	return token.SignedString(privKey)
+}
+
+// parseChallengeToken validates a token issued by newChallengeToken, returning the username it was issued to
+func parseChallengeToken(signed string) (string, error) {
+	claims, err := verifyToken(signed)
+	if err != nil {
+		return "", err
+	}
+	if !claims.TwoFactorChallenge {
+		return "", errors.New("parseChallengeToken - not a two-factor challenge token")
+	}
+	return claims.Username, nil
+}
+
+// recoveryCodeCount is the number of recovery codes issued when two-factor authentication is enabled
+const recoveryCodeCount = 10
+
+// recoveryCodeBytes is the number of random bytes in each recovery code
+const recoveryCodeBytes = 10
+
+// newRecoveryCodes returns a set of single-use recovery codes, and their hashes to be stored
+func newRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		bytes := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(bytes); err != nil {
+			return nil, nil, err
+		}
+		// 16 base32 characters, grouped in fours for readability
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(bytes))
+		code = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code, ignoring its case and formatting
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashSecretToken(code)
+}
+
+// checkSecondFactor checks a TOTP or recovery code for a user with two-factor authentication enabled, returning the
+// method used. Each code can only be used once. Returns errInvalidCredentials if the code is wrong.
+func checkSecondFactor(db dbfs.DBFS, username string, code string) (string, error) {
+	twoFactor, err := db.MySQLUserGet2FA(username)
+	if err == dbfs.ErrNoData || (err == nil && !twoFactor.Enabled) {
+		return "", errInvalidCredentials
+	} else if err != nil {
+		return "", err
+	}
+
+	if step, ok := totp.Validate(twoFactor.Secret, code, time.Now()); ok {
+		err := db.MySQLUserUse2FAStep(username, step)
+		if err == dbfs.ErrNoDbChange {
+			return "", errInvalidCredentials
+		}
+		return "totp", err
+	}
+
+	err = db.MySQLUserUseRecoveryCode(username, hashRecoveryCode(code))
+	if err == dbfs.ErrNoDbChange {
+		return "", errInvalidCredentials
+	}
+	return "recovery code", err
+}
+
+// shareLinkRequests are the methods guests using a share link token can call; all of them only read the project
+var shareLinkRequests = map[string]bool{
+	"Project.GetFiles":    true,
+	"Project.Subscribe":   true,
+	"Project.Unsubscribe": true,
+	"File.Pull":           true,
+	"Comment.List":        true,
+	"Suggestion.List":     true,
+}
+
+// newShareLinkToken returns a token for the guest `username`, which is valid until the share link expires, or for
+// the configured token validity, whichever is sooner.
+func newShareLinkToken(username string, link dbfs.ShareLink) (string, error) {
+	tokenValidityDuration, err := config.GetConfig().ServerConfig.TokenValidityDuration()
+	if err != nil {
+		return "", err
+	}
+	validity := time.Now().Add(tokenValidityDuration)
+	if link.ExpiryDate.Before(validity) {
+		validity = link.ExpiryDate
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, tokenPayload{
+		Username:     username,
+		CreationTime: time.Now().Unix(),
+		Validity:     validity.Unix(),
+		ShareLinkID:  link.ShareLinkID,
+	})
+
+	return token.SignedString(privKey)
+}
+
+// fileAccess returns the sender's access to the files of the given project. Guests using a share link token are
+// given read-only access to the link's project, for as long as the link exists.
+func (abs abstractRequest) fileAccess(projectID int64, db dbfs.DBFS) (*dbfs.FileAccess, error) {
+	if abs.shareLinkID == 0 {
+		return dbfs.NewFileAccess(abs.SenderID, projectID, db)
+	}
+
+	// the link may have been revoked, or its project deleted, since the token was issued
+	link, err := db.MySQLShareLinkGet(abs.shareLinkID)
+	if err != nil {
+		return nil, err
+	}
+	if link.ProjectID != projectID {
+		return nil, ErrAuthenticationFailed
+	}
+	return dbfs.NewShareLinkFileAccess(abs.SenderID, projectID, db)
+}
+
+// secretTokenBytes is the number of random bytes in share link and access tokens
+const secretTokenBytes = 32
+
+// newSecretToken returns a random token, for share links and access tokens
+func newSecretToken() (string, error) {
+	tokenBytes := make([]byte, secretTokenBytes)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(tokenBytes), nil
+}
+
+// hashSecretToken returns the hash a share link or access token is stored as
+func hashSecretToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// accessTokenPrefix distinguishes personal access tokens from the JWTs returned by User.Login
+const accessTokenPrefix = "ccpat_"
+
+const (
+	// accessTokenScopeRead limits a token to the readOnlyRequests
+	accessTokenScopeRead = "read"
+	// accessTokenScopeProject is the prefix of scopes which limit a token to a project, e.g. "project:12"; tokens with
+	// several of them can be used on any of the projects
+	accessTokenScopeProject = "project:"
+)
+
+// readOnlyRequests are the methods tokens with the read scope can call
+var readOnlyRequests = map[string]bool{
+	"File.Pull":                      true,
+	"Group.List":                     true,
+	"Project.GetAuditLog":            true,
+	"Project.GetFiles":               true,
+	"Project.GetOnlineClients":       true,
+	"Project.GetPathPermissions":     true,
+	"Project.GetPermissionConstants": true,
+	"Project.GetShareLinks":          true,
+	"Project.ListTrash":              true,
+	"Project.Lookup":                 true,
+	"Project.Subscribe":              true,
+	"Project.Unsubscribe":            true,
+	"User.GetInvites":                true,
+	"User.ListTokens":                true,
+	"User.Lookup":                    true,
+	"User.Projects":                  true,
+}
+
+type accessTokenScopes struct {
+	readOnly bool
+	// projectIDs are the only projects the token can be used on; if empty, it can be used on any project
+	projectIDs map[int64]bool
+}
+
+// parseAccessTokenScopes parses the scopes of an access token, returning an error for unknown scopes
+func parseAccessTokenScopes(scopes []string) (accessTokenScopes, error) {
+	parsed := accessTokenScopes{projectIDs: make(map[int64]bool)}
+	for _, scope := range scopes {
+		switch {
+		case scope == accessTokenScopeRead:
+			parsed.readOnly = true
+		case strings.HasPrefix(scope, accessTokenScopeProject):
+			projectID, err := strconv.ParseInt(strings.TrimPrefix(scope, accessTokenScopeProject), 10, 64)
+			if err != nil || projectID <= 0 {
+				return parsed, fmt.Errorf("invalid access token scope %q", scope)
+			}
+			parsed.projectIDs[projectID] = true
+		default:
+			return parsed, fmt.Errorf("unknown access token scope %q", scope)
+		}
+	}
+	return parsed, nil
+}
+
+// projectScopedRequest is implemented by the requests which act on projects, and can be sent with project scoped
+// access tokens
+type projectScopedRequest interface {
+	// projectIDs returns the projects the request acts on, looked up from what it targets
+	projectIDs(db dbfs.DBFS) ([]int64, error)
+}
+
+// authenticateAccessToken checks the personal access token the request was sent with, and that the token's scopes
+// allow the method; the scopes are returned so that the request's projects can be checked once it is parsed
+func authenticateAccessToken(abs abstractRequest, db dbfs.DBFS) (accessTokenScopes, error) {
+	token, err := db.MySQLAccessTokenAuthenticate(hashSecretToken(abs.SenderToken))
+	if err != nil {
+		return accessTokenScopes{}, fmt.Errorf("authenticateAccessToken - failed to find token: %s", err)
+	}
+	if !strings.EqualFold(token.Username, abs.SenderID) {
+		return accessTokenScopes{}, errors.New("authenticateAccessToken - senderID did not match token username")
+	}
+
+	scopes, err := parseAccessTokenScopes(token.Scopes)
+	if err != nil {
+		return scopes, err
+	}
+	method := abs.Resource + "." + abs.Method
+	if scopes.readOnly && !readOnlyRequests[method] {
+		return scopes, fmt.Errorf("authenticateAccessToken - %s is not allowed with a read-only token", method)
+	}
+	return scopes, nil
+}
+
+// checkAccessTokenProjects checks that a request sent with a project scoped token only acts on the token's projects.
+// Requests which do not act on a project, such as the User and Group methods, cannot be sent with these tokens.
+func checkAccessTokenProjects(req request, scopes accessTokenScopes, db dbfs.DBFS) error {
+	if len(scopes.projectIDs) == 0 {
+		return nil
+	}
+	scoped, ok := req.(projectScopedRequest)
+	if !ok {
+		return errors.New("checkAccessTokenProjects - request is not allowed with a project scoped token")
+	}
+	projectIDs, err := scoped.projectIDs(db)
+	if err != nil {
+		return err
+	}
+	if len(projectIDs) == 0 {
+		return errors.New("checkAccessTokenProjects - request did not name a project")
+	}
+	for _, projectID := range projectIDs {
+		if !scopes.projectIDs[projectID] {
+			return fmt.Errorf("checkAccessTokenProjects - project %d is not in the token's scopes", projectID)
+		}
+	}
+	return nil
+}
+
+// fileProjectIDs returns the project of a file, which may be in the trash
+func fileProjectIDs(db dbfs.DBFS, fileID int64) ([]int64, error) {
+	fileMeta, err := db.MySQLFileGetInfo(fileID)
+	if err != nil {
+		// File.Restore is sent for files in the trash
+		trashed, trashedErr := db.MySQLFileGetTrashedInfo(fileID)
+		if trashedErr != nil {
+			return nil, err
+		}
+		fileMeta = trashed.FileMeta
+	}
+	return []int64{fileMeta.ProjectID}, nil
+}
+
+// commentThreadProjectIDs returns the project of the file a comment thread is on
+func commentThreadProjectIDs(db dbfs.DBFS, threadID int64) ([]int64, error) {
+	thread, err := db.MySQLCommentThreadGet(threadID)
+	if err != nil {
+		return nil, err
+	}
+	return fileProjectIDs(db, thread.FileID)
+}
+
+// suggestionProjectIDs returns the project of the file a suggestion is for
+func suggestionProjectIDs(db dbfs.DBFS, suggestionID int64) ([]int64, error) {
+	suggestion, err := db.MySQLSuggestionGet(suggestionID)
+	if err != nil {
+		return nil, err
+	}
+	return fileProjectIDs(db, suggestion.FileID)
+}
+
+// inviteProjectIDs returns the project an invite is to
+func inviteProjectIDs(db dbfs.DBFS, inviteID int64) ([]int64, error) {
+	invite, err := db.MySQLInviteGet(inviteID)
+	if err != nil {
+		return nil, err
+	}
+	return []int64{invite.ProjectID}, nil
+}
+
+// shareLinkProjectIDs returns the project a share link is for
+func shareLinkProjectIDs(db dbfs.DBFS, shareLinkID int64) ([]int64, error) {
+	link, err := db.MySQLShareLinkGet(shareLinkID)
+	if err != nil {
+		return nil, err
+	}
+	return []int64{link.ProjectID}, nil
+}
@@ -2,10 +2,15 @@ package datahandling
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
 	"math/rand"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/CodeCollaborate/Server/modules/dbfs"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/kr/pretty"
 	"github.com/stretchr/testify/assert"
@@ -179,3 +184,92 @@ func randomString(strlen int) string {
 	}
 	return string(result)
 }
+
+func TestAuthenticateAccessToken(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.ProjectIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	otherProjectID, _ := db.MySQLProjectCreate("loganga", "other stuff")
+	fileID, _ := db.MySQLFileCreate("loganga", "main.go", ".", projectID)
+	otherFileID, _ := db.MySQLFileCreate("loganga", "main.go", ".", otherProjectID)
+	otherThreadID, _, _ := db.MySQLCommentThreadCreate(dbfs.CommentThread{FileID: otherFileID, CreatedBy: "loganga"}, "hi")
+	otherSuggestionID, _ := db.MySQLSuggestionCreate(dbfs.Suggestion{FileID: otherFileID, Author: "loganga"})
+	otherInviteID, _ := db.MySQLInviteCreate(dbfs.Invite{ProjectID: otherProjectID, Username: "loganga"}, time.Hour)
+
+	createToken := func(scopes []string, expiresIn time.Duration) (string, int64) {
+		token := accessTokenPrefix + randomString(20)
+		tokenID, err := db.MySQLAccessTokenCreate(dbfs.AccessToken{Username: "loganga", Name: "ci", Scopes: scopes},
+			hashSecretToken(token), expiresIn)
+		assert.NoError(t, err)
+		return token, tokenID
+	}
+	fullToken, _ := createToken(nil, 0)
+	expiredToken, expiredID := createToken(nil, time.Hour)
+	readToken, _ := createToken([]string{accessTokenScopeRead}, time.Hour)
+	projectToken, _ := createToken([]string{"project:" + strconv.FormatInt(projectID, 10)}, 0)
+
+	expired := db.AccessTokens[expiredID]
+	expired.ExpiryDate = time.Now().Add(-time.Minute)
+	db.AccessTokens[expiredID] = expired
+
+	tests := []struct {
+		desc     string
+		senderID string
+		token    string
+		method   string
+		data     string
+		valid    bool
+	}{
+		{desc: "Token without scopes", senderID: "loganga", token: fullToken, method: "Project.Create", valid: true},
+		{desc: "Token username does not match senderID", senderID: "notloganga", token: fullToken, method: "Project.Create"},
+		{desc: "Unknown token", senderID: "loganga", token: accessTokenPrefix + "unknown", method: "Project.Create"},
+		{desc: "Expired token", senderID: "loganga", token: expiredToken, method: "Project.Create"},
+		{desc: "Read-only token reading", senderID: "loganga", token: readToken, method: "File.Pull",
+			data: fmt.Sprintf(`{"FileID": %d}`, fileID), valid: true},
+		{desc: "Read-only token writing", senderID: "loganga", token: readToken, method: "File.Change",
+			data: fmt.Sprintf(`{"FileID": %d}`, fileID)},
+		{desc: "Project token on its project", senderID: "loganga", token: projectToken, method: "Project.Rename",
+			data: fmt.Sprintf(`{"ProjectID": %d}`, projectID), valid: true},
+		{desc: "Project token on a file in its project", senderID: "loganga", token: projectToken, method: "File.Change",
+			data: fmt.Sprintf(`{"FileID": %d}`, fileID), valid: true},
+		{desc: "Project token on another project", senderID: "loganga", token: projectToken, method: "Project.Rename",
+			data: fmt.Sprintf(`{"ProjectID": %d}`, otherProjectID)},
+		{desc: "Project token on a file in another project", senderID: "loganga", token: projectToken, method: "File.Pull",
+			data: fmt.Sprintf(`{"FileID": %d}`, otherFileID)},
+		{desc: "Project token looking up several projects", senderID: "loganga", token: projectToken, method: "Project.Lookup",
+			data: fmt.Sprintf(`{"ProjectIDs": [%d, %d]}`, projectID, otherProjectID)},
+		{desc: "Project token on a request without a project", senderID: "loganga", token: projectToken, method: "Project.Create",
+			data: `{"Name": "stuff"}`},
+		{desc: "Project token on a user request naming its project", senderID: "loganga", token: projectToken,
+			method: "User.CreateToken", data: fmt.Sprintf(`{"Name": "ci", "ProjectID": %d}`, projectID)},
+		{desc: "Project token on a group request naming its project", senderID: "loganga", token: projectToken,
+			method: "Group.Create", data: fmt.Sprintf(`{"Name": "team", "ProjectID": %d}`, projectID)},
+		{desc: "Project token on a comment in another project", senderID: "loganga", token: projectToken,
+			method: "Comment.Reply", data: fmt.Sprintf(`{"ThreadID": %d, "ProjectID": %d}`, otherThreadID, projectID)},
+		{desc: "Project token on a suggestion in another project", senderID: "loganga", token: projectToken,
+			method: "Suggestion.Accept", data: fmt.Sprintf(`{"SuggestionID": %d, "FileID": %d}`, otherSuggestionID, fileID)},
+		{desc: "Project token on an invite to another project", senderID: "loganga", token: projectToken,
+			method: "Invite.Accept", data: fmt.Sprintf(`{"InviteID": %d, "ProjectID": %d}`, otherInviteID, projectID)},
+	}
+
+	for _, test := range tests {
+		method := strings.SplitN(test.method, ".", 2)
+		req := abstractRequest{
+			SenderID:    test.senderID,
+			SenderToken: test.token,
+			Resource:    method[0],
+			Method:      method[1],
+			Data:        json.RawMessage(test.data),
+		}
+		if test.data == "" {
+			req.Data = json.RawMessage("{}")
+		}
+		_, err := getFullRequest(&req, db)
+		if test.valid {
+			assert.NoError(t, err, test.desc)
+		} else {
+			assert.Error(t, err, test.desc)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package datahandling
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/ldap"
+	"github.com/CodeCollaborate/Server/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+/**
+ * Authenticators check the passwords of users logging in, against the backend configured in server.cfg.
+ */
+
+// ldapGrantor is recorded as the granter of the project roles given to directory groups
+const ldapGrantor = "(ldap)"
+
+// errInvalidCredentials is returned by an Authenticator when the username or password is wrong
+var errInvalidCredentials = errors.New("Invalid username or password")
+
+// Authenticator checks the credentials of users logging in
+type Authenticator interface {
+	// Authenticate returns nil if the password is correct for the user, or errInvalidCredentials if it is not
+	Authenticate(db dbfs.DBFS, username string, password string) error
+
+	// RegistrationEnabled returns true if users can register with a password through User.Register
+	RegistrationEnabled() bool
+}
+
+// loginAuthenticator is the Authenticator used by User.Login; set by ConfigureAuthenticator
+var loginAuthenticator Authenticator = passwordAuthenticator{}
+
+// ConfigureAuthenticator sets the Authenticator used by User.Login to the configured backend
+func ConfigureAuthenticator(cfg config.AuthenticationCfg) error {
+	switch cfg.Backend {
+	case "", config.AuthBackendPassword:
+		loginAuthenticator = passwordAuthenticator{}
+	case config.AuthBackendLDAP:
+		authenticator, err := newLDAPAuthenticator(cfg.LDAP)
+		if err != nil {
+			return err
+		}
+		loginAuthenticator = authenticator
+	default:
+		return fmt.Errorf("Unknown authentication backend %q", cfg.Backend)
+	}
+	return nil
+}
+
+// passwordAuthenticator checks passwords against the bcrypt hashes stored for users
+type passwordAuthenticator struct{}
+
+// dummyHash is compared against when a user has no password, so that unknown users take as long to reject as known ones
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+func (passwordAuthenticator) Authenticate(db dbfs.DBFS, username string, password string) error {
+	hashed, err := db.MySQLUserGetPass(username)
+	if err != nil {
+		return err
+	}
+
+	// unknown users, and users without a password such as bots, cannot log in
+	if hashed == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return errInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) != nil {
+		return errInvalidCredentials
+	}
+	return nil
+}
+
+func (passwordAuthenticator) RegistrationEnabled() bool {
+	return true
+}
+
+// directory is implemented by ldap.Directory
+type directory interface {
+	Authenticate(username string, password string) (ldap.User, error)
+}
+
+// ldapAuthenticator checks passwords by binding to an LDAP directory as the user. Local users are registered for
+// directory users on their first login, if AutoProvision is enabled.
+type ldapAuthenticator struct {
+	directory directory
+	// groupRoles are the configured GroupRoles, keyed on the lowercased group DN
+	groupRoles map[string][]config.LDAPGroupRole
+	cfg        config.LDAPCfg
+}
+
+func newLDAPAuthenticator(cfg config.LDAPCfg) (*ldapAuthenticator, error) {
+	dir, err := ldap.NewDirectory(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	groupRoles := make(map[string][]config.LDAPGroupRole)
+	for group, roles := range cfg.GroupRoles {
+		for _, role := range roles {
+			perm, err := config.PermissionByLabel(role.Role)
+			if err != nil {
+				return nil, fmt.Errorf("Group %q is mapped to unknown role %q", group, role.Role)
+			}
+			if perm.Level >= config.OwnerLevel {
+				return nil, fmt.Errorf("Group %q cannot be mapped to the owner role", group)
+			}
+		}
+		key := strings.ToLower(group)
+		groupRoles[key] = append(groupRoles[key], roles...)
+	}
+
+	return &ldapAuthenticator{
+		directory:  dir,
+		groupRoles: groupRoles,
+		cfg:        cfg,
+	}, nil
+}
+
+func (a *ldapAuthenticator) Authenticate(db dbfs.DBFS, username string, password string) error {
+	dirUser, err := a.directory.Authenticate(username, password)
+	if err == ldap.ErrInvalidCredentials {
+		return errInvalidCredentials
+	} else if err != nil {
+		return err
+	}
+
+	user, err := db.MySQLUserLookup(username)
+	if err != nil && err != dbfs.ErrNoData {
+		return err
+	}
+	if user.Username == "" {
+		if !a.cfg.AutoProvision {
+			utils.LogInfo("Directory user has no local account", utils.LogFields{
+				"Username": username,
+			})
+			return errInvalidCredentials
+		}
+		if err := a.provision(db, username, dirUser); err != nil {
+			return err
+		}
+	} else if user.Bot {
+		// bots are local accounts, and cannot be impersonated by a directory user of the same name
+		return errInvalidCredentials
+	}
+
+	a.grantGroupRoles(db, username, dirUser.Groups)
+	return nil
+}
+
+// provision registers a local user for a directory user. They have no password, since it is kept by the directory.
+func (a *ldapAuthenticator) provision(db dbfs.DBFS, username string, dirUser ldap.User) error {
+	err := db.MySQLUserRegister(dbfs.UserMeta{
+		Username:  username,
+		FirstName: dirUser.FirstName,
+		LastName:  dirUser.LastName,
+		Email:     dirUser.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	if dirUser.Email != "" {
+		err := db.MySQLInviteBindEmail(dirUser.Email, username)
+		utils.LogError("Failed to bind invites to new user", err, utils.LogFields{
+			"Username": username,
+		})
+	}
+	return db.MySQLAuditLogInsert(dbfs.AuditEntry{Actor: username, Action: "User.Register", Target: username, Detail: "ldap"})
+}
+
+// grantGroupRoles grants the roles mapped to the user's groups, where they are higher than the user already has.
+// Failures are logged rather than failing the login.
+func (a *ldapAuthenticator) grantGroupRoles(db dbfs.DBFS, username string, groups []string) {
+	for _, group := range groups {
+		for _, role := range a.groupRoles[strings.ToLower(group)] {
+			perm, err := config.PermissionByLabel(role.Role)
+			if err != nil {
+				utils.LogError("Directory group is mapped to unknown role", err, utils.LogFields{
+					"Group": group,
+					"Role":  role.Role,
+				})
+				continue
+			}
+
+			current, err := db.MySQLUserProjectPermissionLookup(role.ProjectID, username)
+			if err == nil && current >= perm.Level {
+				continue
+			}
+
+			err = db.MySQLProjectGrantPermission(role.ProjectID, username, perm.Level, ldapGrantor)
+			if err != nil {
+				utils.LogError("Failed to grant directory group role", err, utils.LogFields{
+					"Username":  username,
+					"Group":     group,
+					"ProjectID": role.ProjectID,
+					"Role":      role.Role,
+				})
+				continue
+			}
+
+			err = db.MySQLAuditLogInsert(dbfs.AuditEntry{
+				Actor:     ldapGrantor,
+				Action:    "Project.GrantPermissions",
+				ProjectID: role.ProjectID,
+				Target:    username,
+				Detail:    role.Role + " via " + group,
+			})
+			utils.LogError("Failed to write audit log", err, utils.LogFields{
+				"Username": username,
+			})
+		}
+	}
+}
+
+func (a *ldapAuthenticator) RegistrationEnabled() bool {
+	return false
+}
@@ -0,0 +1,122 @@
+package datahandling
+
+import (
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/ldap"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeDirectory accepts the passwords in `passwords`, returning the users in `users`
+type fakeDirectory struct {
+	passwords map[string]string
+	users     map[string]ldap.User
+}
+
+func (dir fakeDirectory) Authenticate(username string, password string) (ldap.User, error) {
+	if expected, ok := dir.passwords[username]; !ok || expected != password {
+		return ldap.User{}, ldap.ErrInvalidCredentials
+	}
+	return dir.users[username], nil
+}
+
+func TestPasswordAuthenticator(t *testing.T) {
+	db := dbfs.NewDBMock()
+	hashed, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	db.Users["jane"] = dbfs.UserMeta{Username: "jane", Password: string(hashed)}
+	db.Users["janebot"] = dbfs.UserMeta{Username: "janebot", Bot: true, BotOwner: "jane"}
+
+	authenticator := passwordAuthenticator{}
+	assert.NoError(t, authenticator.Authenticate(db, "jane", "correct horse"))
+	assert.Equal(t, errInvalidCredentials, authenticator.Authenticate(db, "jane", "wrong"))
+	assert.Equal(t, errInvalidCredentials, authenticator.Authenticate(db, "janebot", ""),
+		"users without a password should not be able to log in")
+	assert.True(t, authenticator.RegistrationEnabled())
+}
+
+func TestLDAPAuthenticator(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.ProjectIDCounter = 1
+	projectID, err := db.MySQLProjectCreate("owner", "directory project")
+	assert.NoError(t, err)
+	db.Users["janebot"] = dbfs.UserMeta{Username: "janebot", Bot: true, BotOwner: "owner"}
+
+	authenticator := &ldapAuthenticator{
+		directory: fakeDirectory{
+			passwords: map[string]string{"jane": "correct horse", "john": "battery staple", "janebot": "staple"},
+			users: map[string]ldap.User{
+				"jane": {
+					DN:        "uid=jane,dc=example,dc=com",
+					Email:     "jane@example.com",
+					FirstName: "Jane",
+					LastName:  "Doe",
+					Groups:    []string{"CN=Developers,DC=example,DC=com"},
+				},
+			},
+		},
+		groupRoles: map[string][]config.LDAPGroupRole{
+			"cn=developers,dc=example,dc=com": {{ProjectID: projectID, Role: "write"}},
+		},
+		cfg: config.LDAPCfg{AutoProvision: true},
+	}
+	assert.False(t, authenticator.RegistrationEnabled())
+
+	assert.Equal(t, errInvalidCredentials, authenticator.Authenticate(db, "jane", "wrong"))
+	assert.NotContains(t, db.Users, "jane")
+
+	// directory users are registered on their first login, and granted the roles of their groups
+	assert.NoError(t, authenticator.Authenticate(db, "jane", "correct horse"))
+	assert.Equal(t, dbfs.UserMeta{Username: "jane", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com"},
+		db.Users["jane"])
+	level, err := db.MySQLUserProjectPermissionLookup(projectID, "jane")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["write"], level)
+	auditEntries := len(db.AuditLog)
+	assert.Equal(t, 2, auditEntries, "registration and the role grant should be audited")
+
+	// roles already held are not granted again, and higher roles are not lowered
+	assert.NoError(t, authenticator.Authenticate(db, "jane", "correct horse"))
+	assert.Len(t, db.AuditLog, auditEntries)
+	assert.NoError(t, db.MySQLProjectGrantPermission(projectID, "jane", config.PermissionsByLabel["admin"], "owner"))
+	assert.NoError(t, authenticator.Authenticate(db, "jane", "correct horse"))
+	level, err = db.MySQLUserProjectPermissionLookup(projectID, "jane")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["admin"], level)
+
+	// bots cannot be logged into through the directory
+	assert.Equal(t, errInvalidCredentials, authenticator.Authenticate(db, "janebot", "staple"))
+
+	// without provisioning, directory users need an existing local user
+	authenticator.cfg.AutoProvision = false
+	assert.Equal(t, errInvalidCredentials, authenticator.Authenticate(db, "john", "battery staple"))
+	assert.NotContains(t, db.Users, "john")
+	assert.NoError(t, authenticator.Authenticate(db, "jane", "correct horse"))
+}
+
+func TestConfigureAuthenticator(t *testing.T) {
+	configSetup(t)
+	defer ConfigureAuthenticator(config.AuthenticationCfg{})
+
+	ldapCfg := config.LDAPCfg{
+		URL:            "ldap://localhost:389",
+		UserDNTemplate: "uid=%s,dc=example,dc=com",
+	}
+	assert.NoError(t, ConfigureAuthenticator(config.AuthenticationCfg{Backend: config.AuthBackendLDAP, LDAP: ldapCfg}))
+	assert.IsType(t, &ldapAuthenticator{}, loginAuthenticator)
+
+	assert.NoError(t, ConfigureAuthenticator(config.AuthenticationCfg{}))
+	assert.IsType(t, passwordAuthenticator{}, loginAuthenticator)
+
+	assert.Error(t, ConfigureAuthenticator(config.AuthenticationCfg{Backend: "kerberos"}))
+
+	ldapCfg.GroupRoles = map[string][]config.LDAPGroupRole{"cn=staff": {{ProjectID: 1, Role: "nonexistent"}}}
+	assert.Error(t, ConfigureAuthenticator(config.AuthenticationCfg{Backend: config.AuthBackendLDAP, LDAP: ldapCfg}))
+	ldapCfg.GroupRoles = map[string][]config.LDAPGroupRole{"cn=staff": {{ProjectID: 1, Role: "owner"}}}
+	assert.Error(t, ConfigureAuthenticator(config.AuthenticationCfg{Backend: config.AuthBackendLDAP, LDAP: ldapCfg}),
+		"ownership should not be granted through groups")
+}
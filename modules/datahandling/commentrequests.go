@@ -0,0 +1,445 @@
+package datahandling
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+var commentRequestsSetup = false
+
+// initCommentRequests populates the requestMap from requestmap.go with the appropriate constructors for the comment methods
+func initCommentRequests() {
+	if commentRequestsSetup {
+		return
+	}
+
+	authenticatedRequestMap["Comment.Create"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(commentCreateRequest), req)
+	}
+
+	authenticatedRequestMap["Comment.Reply"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(commentReplyRequest), req)
+	}
+
+	authenticatedRequestMap["Comment.Resolve"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(commentResolveRequest), req)
+	}
+
+	authenticatedRequestMap["Comment.List"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(commentListRequest), req)
+	}
+
+	commentRequestsSetup = true
+}
+
+// maxCommentLength is the maximum number of characters in a comment
+const maxCommentLength = 10000
+
+// maxCommentMentions is the maximum number of users notified of a mention in a single comment
+const maxCommentMentions = 20
+
+// mentionRegex matches @mentions of usernames, which must not be preceded by a word character, as in email addresses
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@])@([\w.-]+)`)
+
+// validCommentBody returns true if the comment is not empty, and no longer than maxCommentLength
+func validCommentBody(body string) bool {
+	return strings.TrimSpace(body) != "" && utf8.RuneCountInString(body) <= maxCommentLength
+}
+
+// mentionClosures notifies the users mentioned in the comment, on their own channels. Only users who can read the
+// file are notified, and at most maxCommentMentions of them.
+func mentionClosures(db dbfs.DBFS, fileMeta dbfs.FileMeta, comment dbfs.Comment) []dhClosure {
+	mentioned := make(map[string]bool)
+	for _, match := range mentionRegex.FindAllStringSubmatch(comment.Body, -1) {
+		// a mention at the end of a sentence is followed by a full stop
+		username := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if username != "" && username != comment.Author {
+			mentioned[username] = true
+		}
+	}
+
+	usernames := []string{}
+	for username := range mentioned {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+
+	closures := []dhClosure{}
+	for _, username := range usernames {
+		if len(closures) == maxCommentMentions {
+			break
+		}
+		access, err := dbfs.NewFileAccess(username, fileMeta.ProjectID, db)
+		if err != nil || !access.Can(fileMeta, config.CapabilityRead) {
+			continue
+		}
+
+		not := messages.Notification{
+			Resource:   "Comment",
+			Method:     "Mention",
+			ResourceID: fileMeta.FileID,
+			Data: struct {
+				ProjectID int64
+				ThreadID  int64
+				CommentID int64
+				Author    string
+				Body      string
+			}{
+				ProjectID: fileMeta.ProjectID,
+				ThreadID:  comment.ThreadID,
+				CommentID: comment.CommentID,
+				Author:    comment.Author,
+				Body:      comment.Body,
+			},
+		}.Wrap()
+		closures = append(closures, toUserClosure(not, username))
+	}
+	return closures
+}
+
+// Comment.Create
+type commentCreateRequest struct {
+	FileID     int64
+	StartIndex int
+	EndIndex   int
+	// FileVersion is the version of the file the range refers to; defaults to the latest version
+	FileVersion int64
+	Body        string
+	abstractRequest
+}
+
+func (c *commentCreateRequest) setAbstractRequest(req *abstractRequest) {
+	c.abstractRequest = *req
+}
+
+func (c commentCreateRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return fileProjectIDs(db, c.FileID)
+}
+
+func (c commentCreateRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	fileMeta, err := db.MySQLFileGetInfo(c.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, c.Tag)}}, nil
+	}
+
+	access, err := c.fileAccess(fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityComment) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  c.Resource,
+			"Method":    c.Method,
+			"SenderID":  c.SenderID,
+			"ProjectID": fileMeta.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, c.Tag)}}, nil
+	}
+
+	if !validCommentBody(c.Body) {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(c.Tag, "Comments must not be empty or too long")}}, nil
+	}
+	if c.StartIndex < 0 || c.EndIndex <= c.StartIndex {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(c.Tag, "The range must not be empty")}}, nil
+	}
+
+	version, err := db.CBGetFileVersion(c.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+	if c.FileVersion == 0 {
+		c.FileVersion = version
+	} else if c.FileVersion > version {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(c.Tag, "The file version does not exist yet")}}, nil
+	}
+
+	notifyKeys, err := fileNotificationKeys(access, fileMeta)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+
+	threadID, commentID, err := db.MySQLCommentThreadCreate(dbfs.CommentThread{
+		FileID: c.FileID,
+		CommentAnchor: dbfs.CommentAnchor{
+			StartIndex:  c.StartIndex,
+			EndIndex:    c.EndIndex,
+			FileVersion: c.FileVersion,
+		},
+		CreatedBy: c.SenderID,
+	}, c.Body)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+
+	// bring the anchor up to date, if the range was chosen on an older version
+	if c.FileVersion < version {
+		_, err = dbfs.UpdateCommentAnchors(db, fileMeta)
+		utils.LogError("Failed to update comment anchors", err, utils.LogFields{
+			"FileID": c.FileID,
+		})
+	}
+	thread, err := db.MySQLCommentThreadGet(threadID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+	comment := dbfs.Comment{
+		CommentID:    commentID,
+		ThreadID:     threadID,
+		Author:       c.SenderID,
+		Body:         c.Body,
+		CreationDate: thread.CreationDate,
+	}
+	thread.Comments = []dbfs.Comment{comment}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    c.Tag,
+		Data: struct {
+			Thread dbfs.CommentThread
+		}{
+			Thread: thread,
+		},
+	}.Wrap()
+	not := messages.Notification{
+		Resource:   c.Resource,
+		Method:     c.Method,
+		ResourceID: c.FileID,
+		Data: struct {
+			Thread dbfs.CommentThread
+		}{
+			Thread: thread,
+		},
+	}.Wrap()
+
+	closures := []dhClosure{toSenderClosure{msg: res}}
+	closures = append(closures, toRabbitChannelClosures(not, notifyKeys)...)
+	return append(closures, mentionClosures(db, fileMeta, comment)...), nil
+}
+
+// Comment.Reply
+type commentReplyRequest struct {
+	ThreadID int64
+	Body     string
+	abstractRequest
+}
+
+func (c *commentReplyRequest) setAbstractRequest(req *abstractRequest) {
+	c.abstractRequest = *req
+}
+
+func (c commentReplyRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return commentThreadProjectIDs(db, c.ThreadID)
+}
+
+func (c commentReplyRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	thread, err := db.MySQLCommentThreadGet(c.ThreadID)
+	if err == dbfs.ErrNoData {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, c.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+	fileMeta, err := db.MySQLFileGetInfo(thread.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, c.Tag)}}, nil
+	}
+
+	access, err := c.fileAccess(fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityComment) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  c.Resource,
+			"Method":    c.Method,
+			"SenderID":  c.SenderID,
+			"ProjectID": fileMeta.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, c.Tag)}}, nil
+	}
+
+	if !validCommentBody(c.Body) {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(c.Tag, "Comments must not be empty or too long")}}, nil
+	}
+
+	notifyKeys, err := fileNotificationKeys(access, fileMeta)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+
+	commentID, err := db.MySQLCommentReply(c.ThreadID, c.SenderID, c.Body)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+	comment := dbfs.Comment{
+		CommentID: commentID,
+		ThreadID:  c.ThreadID,
+		Author:    c.SenderID,
+		Body:      c.Body,
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    c.Tag,
+		Data: struct {
+			CommentID int64
+		}{
+			CommentID: commentID,
+		},
+	}.Wrap()
+	not := messages.Notification{
+		Resource:   c.Resource,
+		Method:     c.Method,
+		ResourceID: thread.FileID,
+		Data: struct {
+			Comment dbfs.Comment
+		}{
+			Comment: comment,
+		},
+	}.Wrap()
+
+	closures := []dhClosure{toSenderClosure{msg: res}}
+	closures = append(closures, toRabbitChannelClosures(not, notifyKeys)...)
+	return append(closures, mentionClosures(db, fileMeta, comment)...), nil
+}
+
+// Comment.Resolve
+type commentResolveRequest struct {
+	ThreadID int64
+	// Reopen reopens a resolved thread, instead of resolving it
+	Reopen bool
+	abstractRequest
+}
+
+func (c *commentResolveRequest) setAbstractRequest(req *abstractRequest) {
+	c.abstractRequest = *req
+}
+
+func (c commentResolveRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return commentThreadProjectIDs(db, c.ThreadID)
+}
+
+func (c commentResolveRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	thread, err := db.MySQLCommentThreadGet(c.ThreadID)
+	if err == dbfs.ErrNoData {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, c.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+	fileMeta, err := db.MySQLFileGetInfo(thread.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, c.Tag)}}, nil
+	}
+
+	access, err := c.fileAccess(fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityComment) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  c.Resource,
+			"Method":    c.Method,
+			"SenderID":  c.SenderID,
+			"ProjectID": fileMeta.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, c.Tag)}}, nil
+	}
+
+	resolved := !c.Reopen
+	if thread.Resolved == resolved {
+		// nothing to change, or notify anyone of
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, c.Tag)}}, nil
+	}
+
+	notifyKeys, err := fileNotificationKeys(access, fileMeta)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+
+	err = db.MySQLCommentThreadResolve(c.ThreadID, resolved, c.SenderID)
+	if err != nil && err != dbfs.ErrNoDbChange {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, c.Tag)
+	not := messages.Notification{
+		Resource:   c.Resource,
+		Method:     c.Method,
+		ResourceID: thread.FileID,
+		Data: struct {
+			ThreadID int64
+			Resolved bool
+		}{
+			ThreadID: c.ThreadID,
+			Resolved: resolved,
+		},
+	}.Wrap()
+
+	return append([]dhClosure{toSenderClosure{msg: res}}, toRabbitChannelClosures(not, notifyKeys)...), nil
+}
+
+// Comment.List
+type commentListRequest struct {
+	FileID          int64
+	IncludeResolved bool
+	abstractRequest
+}
+
+func (c *commentListRequest) setAbstractRequest(req *abstractRequest) {
+	c.abstractRequest = *req
+}
+
+func (c commentListRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return fileProjectIDs(db, c.FileID)
+}
+
+func (c commentListRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	fileMeta, err := db.MySQLFileGetInfo(c.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, c.Tag)}}, nil
+	}
+
+	access, err := c.fileAccess(fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityRead) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  c.Resource,
+			"Method":    c.Method,
+			"SenderID":  c.SenderID,
+			"ProjectID": fileMeta.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, c.Tag)}}, nil
+	}
+
+	// anchors are normally moved as changes are made, but catch up on any that were missed; stale anchors are still
+	// listed, along with the version they refer to
+	_, err = dbfs.UpdateCommentAnchors(db, fileMeta)
+	utils.LogError("Failed to update comment anchors", err, utils.LogFields{
+		"FileID": c.FileID,
+	})
+
+	threads, err := db.MySQLCommentThreadsGet(c.FileID, c.IncludeResolved)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+	comments, err := db.MySQLCommentsGet(c.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, c.Tag)}}, err
+	}
+
+	byThread := make(map[int64][]dbfs.Comment)
+	for _, comment := range comments {
+		byThread[comment.ThreadID] = append(byThread[comment.ThreadID], comment)
+	}
+	for i := range threads {
+		threads[i].Comments = byThread[threads[i].ThreadID]
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    c.Tag,
+		Data: struct {
+			Threads []dbfs.CommentThread
+		}{
+			Threads: threads,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
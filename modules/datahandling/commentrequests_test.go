@@ -0,0 +1,201 @@
+package datahandling
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentCreateRequest_Process(t *testing.T) {
+	db, fileMeta := fileSetup(t, map[string]string{"commenter": "commenter", "reader": "read"})
+
+	req := commentCreateRequest{
+		FileID:     fileMeta.FileID,
+		StartIndex: 5,
+		EndIndex:   10,
+		Body:       "@Reader, @loganga and @nobody: should this be here? cc @commenter.",
+	}
+	setBaseFields(&req)
+	req.Resource = "Comment"
+	req.Method = "Create"
+	req.SenderID = "reader"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "readers should not be able to comment")
+
+	req.SenderID = "commenter"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	require.Equal(t, 4, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	thread := reflect.ValueOf(resp.Data).FieldByName("Thread").Interface().(dbfs.CommentThread)
+	assert.Equal(t, dbfs.CommentAnchor{StartIndex: 5, EndIndex: 10, FileVersion: 2}, thread.CommentAnchor,
+		"the anchor should default to the latest version")
+	assert.Equal(t, "commenter", thread.CreatedBy)
+	require.Equal(t, 1, len(thread.Comments))
+	assert.Equal(t, req.Body, thread.Comments[0].Body)
+
+	notification := closures[1].(toRabbitChannelClosure)
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(fileMeta.ProjectID), notification.key)
+	assert.Equal(t, fileMeta.FileID, notification.msg.ServerMessage.(messages.Notification).ResourceID)
+
+	// mentions go to the users that can read the file, but not to the author
+	mentioned := []string{}
+	for _, closure := range closures[2:] {
+		mention := closure.(toRabbitChannelClosure)
+		assert.Equal(t, "Mention", mention.msg.ServerMessage.(messages.Notification).Method)
+		mentioned = append(mentioned, mention.inbox)
+	}
+	assert.Equal(t, []string{"loganga", "reader"}, mentioned)
+
+	// anchors chosen on older versions are moved to the latest one
+	req.FileVersion = 1
+	req.Body = "older"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	thread = reflect.ValueOf(resp.Data).FieldByName("Thread").Interface().(dbfs.CommentThread)
+	assert.Equal(t, dbfs.CommentAnchor{StartIndex: 25, EndIndex: 30, FileVersion: 2}, thread.CommentAnchor)
+
+	// empty comments and ranges, and versions which don't exist yet are rejected
+	for _, invalid := range []commentCreateRequest{
+		{StartIndex: 5, EndIndex: 10, Body: " "},
+		{StartIndex: 5, EndIndex: 10, Body: strings.Repeat("a", maxCommentLength+1)},
+		{StartIndex: 5, EndIndex: 5, Body: "empty"},
+		{StartIndex: -1, EndIndex: 5, Body: "negative"},
+		{StartIndex: 5, EndIndex: 10, FileVersion: 3, Body: "future"},
+	} {
+		invalid.FileID = fileMeta.FileID
+		invalid.abstractRequest = req.abstractRequest
+		closures, err = invalid.process(db)
+		assert.Nil(t, err)
+		resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+		assert.Equal(t, messages.StatusFail, resp.Status, "unexpected response status for %+v", invalid)
+	}
+	assert.Equal(t, 2, len(db.CommentThreads))
+}
+
+func TestCommentThreadRequests_Process(t *testing.T) {
+	db, fileMeta := fileSetup(t, map[string]string{"commenter": "commenter", "reader": "read"})
+	threadID, _, _ := db.MySQLCommentThreadCreate(dbfs.CommentThread{
+		FileID:        fileMeta.FileID,
+		CommentAnchor: dbfs.CommentAnchor{StartIndex: 5, EndIndex: 10, FileVersion: 2},
+		CreatedBy:     "loganga",
+	}, "first")
+
+	reply := commentReplyRequest{ThreadID: threadID, Body: "thanks @loganga"}
+	setBaseFields(&reply)
+	reply.Resource = "Comment"
+	reply.Method = "Reply"
+	reply.SenderID = "commenter"
+
+	closures, err := reply.process(db)
+	assert.Nil(t, err)
+	require.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, rabbitmq.RabbitUserQueueName("loganga"), closures[2].(toRabbitChannelClosure).key)
+	assert.Equal(t, 2, len(db.Comments[threadID]))
+
+	reply.ThreadID = threadID + 1
+	closures, err = reply.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNotFound, resp.Status, "unexpected response status")
+
+	resolve := commentResolveRequest{ThreadID: threadID}
+	setBaseFields(&resolve)
+	resolve.Resource = "Comment"
+	resolve.Method = "Resolve"
+	resolve.SenderID = "reader"
+
+	closures, err = resolve.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "readers should not be able to resolve threads")
+
+	resolve.SenderID = "commenter"
+	closures, err = resolve.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	assert.True(t, db.CommentThreads[threadID].Resolved)
+	assert.Equal(t, "commenter", db.CommentThreads[threadID].ResolvedBy)
+
+	// resolving it again changes nothing
+	closures, err = resolve.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(closures), "unexpected number of returned closures")
+
+	list := commentListRequest{FileID: fileMeta.FileID}
+	setBaseFields(&list)
+	list.Resource = "Comment"
+	list.Method = "List"
+	list.SenderID = "reader"
+
+	closures, err = list.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Empty(t, reflect.ValueOf(resp.Data).FieldByName("Threads").Interface().([]dbfs.CommentThread),
+		"resolved threads should not be listed by default")
+
+	list.IncludeResolved = true
+	closures, err = list.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	threads := reflect.ValueOf(resp.Data).FieldByName("Threads").Interface().([]dbfs.CommentThread)
+	require.Equal(t, 1, len(threads))
+	assert.Equal(t, 2, len(threads[0].Comments))
+
+	resolve.Reopen = true
+	closures, err = resolve.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	assert.False(t, db.CommentThreads[threadID].Resolved)
+}
+
+func TestCommentAnchorClosure_Call(t *testing.T) {
+	db, fileMeta := fileSetup(t, map[string]string{"commenter": "commenter", "reader": "read"})
+	threadID, _, _ := db.MySQLCommentThreadCreate(dbfs.CommentThread{
+		FileID:        fileMeta.FileID,
+		CommentAnchor: dbfs.CommentAnchor{StartIndex: 5, EndIndex: 10, FileVersion: 2},
+		CreatedBy:     "loganga",
+	}, "first")
+
+	req := fileChangeRequest{FileID: fileMeta.FileID, Changes: "v2:\n4:-7:aaaaaaa:\n20"}
+	setBaseFields(&req)
+	req.Resource = "File"
+	req.Method = "Change"
+	req.SenderID = "loganga"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	closure := closures[len(closures)-2].(commentAnchorClosure)
+
+	messageChan := make(chan rabbitmq.AMQPMessage, 1)
+	err = closure.call(DataHandler{Db: db, MessageChan: messageChan})
+	assert.Nil(t, err)
+	assert.True(t, db.CommentThreads[threadID].Orphaned, "threads should be orphaned when their text is deleted")
+	assert.EqualValues(t, 3, db.CommentThreads[threadID].FileVersion)
+
+	// the orphaned threads are announced on the channels the change is sent on
+	require.Equal(t, 1, len(messageChan))
+	msg := <-messageChan
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(fileMeta.ProjectID), msg.RoutingKey)
+	assert.Contains(t, string(msg.Message), "Orphan")
+
+	// nothing is announced once the anchors are up to date
+	err = closure.call(DataHandler{Db: db, MessageChan: messageChan})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(messageChan))
+}
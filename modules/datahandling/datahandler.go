@@ -11,6 +11,7 @@ import (
 	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
 	"github.com/CodeCollaborate/Server/modules/dbfs"
 	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/modules/ratelimit"
 	"github.com/CodeCollaborate/Server/utils"
 )
 
@@ -32,6 +33,21 @@ type DataHandler struct {
 	MessageChan chan<- rabbitmq.AMQPMessage
 	WebsocketID uint64
 	Db          dbfs.DBFS
+
+	// RemoteAddr is the IP address of the client
+	RemoteAddr string
+	// Limiter rate limits incoming requests; if nil, requests are not limited
+	Limiter *ratelimit.Limiter
+	// Disconnect closes the websocket; called when a client is repeatedly throttled
+	Disconnect func()
+}
+
+// Handler handles the messages received from a websocket
+type Handler interface {
+	// Handle processes the message, marking the waitgroup done once it has been handled
+	Handle(messageType int, message []byte, wg *sync.WaitGroup) error
+	// ReleaseLocks releases the file locks held by the websocket, once it has disconnected
+	ReleaseLocks()
 }
 
 // Handle takes the MessageType and message in byte-array form,
@@ -39,9 +55,17 @@ type DataHandler struct {
 // the waitgroup allows the websocket manager to know when all requests have completed processing
 func (dh DataHandler) Handle(messageType int, message []byte, wg *sync.WaitGroup) error {
 	defer wg.Done()
+	_, _, err := dh.handle(message)
+	return err
+}
+
+// handle parses and processes the message, calling the resulting closures. The parsed request and the closures are
+// returned; the request is nil if the message could not be parsed.
+func (dh DataHandler) handle(message []byte) (*abstractRequest, []dhClosure, error) {
 
-	// Ignore any request that has a password JSON field
-	if !strings.Contains(strings.ToLower(string(message)), "\"password\":") {
+	// Ignore any request that has a password or 2FA code JSON field
+	lowered := strings.ToLower(string(message))
+	if !strings.Contains(lowered, "\"password\":") && !strings.Contains(lowered, "\"code\":") {
 		utils.LogDebug("Received Message", utils.LogFields{
 			"Message": string(message),
 		})
@@ -50,17 +74,35 @@ func (dh DataHandler) Handle(messageType int, message []byte, wg *sync.WaitGroup
 	req, err := createAbstractRequest(message)
 	if err != nil {
 		utils.LogError("Failed to parse json", err, nil) // Do not log request since passwords may be sent
-		return err
+		return nil, nil, err
 	}
 
 	req.SenderID = strings.ToLower(req.SenderID)
-
-	// automatically determines if the request is authenticated or not
-	fullRequest, err := getFullRequest(req)
+	req.remoteAddr = dh.RemoteAddr
+	req.socket = rabbitmq.RabbitWebsocketQueueName(dh.WebsocketID)
 
 	var closures []dhClosure
 
-	if err != nil {
+	// requests which are over their limits are rejected before they are authenticated
+	throttled, retryAfter := dh.precheck(req)
+	var fullRequest request
+	if !throttled {
+		// automatically determines if the request is authenticated or not
+		fullRequest, err = getFullRequest(req, dh.Db)
+
+		_, unauthenticatedMethod := unauthenticatedRequestMap[req.Resource+"."+req.Method]
+		throttled, retryAfter = dh.throttle(req, err == nil && !unauthenticatedMethod)
+	}
+
+	if throttled {
+		utils.LogDebug("Request throttled", utils.LogFields{
+			"Resource":   req.Resource,
+			"Method":     req.Method,
+			"SenderID":   req.SenderID,
+			"RetryAfter": retryAfter,
+		})
+		closures = []dhClosure{toSenderClosure{msg: newThrottledResponse(req.Tag, retryAfter)}}
+	} else if err != nil {
 		// Ignore requests where there
 		if req.Resource == "User" && (req.Method == "Register" || req.Method == "Login") {
 			utils.LogError("getFullRequest failed for Register/Login", err, nil)
@@ -103,5 +145,29 @@ func (dh DataHandler) Handle(messageType int, message []byte, wg *sync.WaitGroup
 		}
 	}
 
-	return err
+	return req, closures, err
+}
+
+// ReleaseLocks releases the file locks held by the websocket, notifying the files' subscribers. It is called once the
+// websocket has disconnected, before its message channel is closed.
+func (dh DataHandler) ReleaseLocks() {
+	locks, err := dh.Db.MySQLFileLocksReleaseSocket(rabbitmq.RabbitWebsocketQueueName(dh.WebsocketID))
+	if err != nil {
+		utils.LogError("Failed to release file locks", err, utils.LogFields{
+			"WebsocketID": dh.WebsocketID,
+		})
+		return
+	}
+
+	for _, lock := range locks {
+		closures, err := fileUnlockClosures(dh.Db, lock, lock.Username)
+		if err == nil {
+			for _, closure := range closures {
+				err = closure.call(dh)
+			}
+		}
+		utils.LogError("Failed to notify subscribers of released file lock", err, utils.LogFields{
+			"FileID": lock.FileID,
+		})
+	}
 }
@@ -5,6 +5,9 @@ import (
 	"errors"
 
 	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/mail"
+	"github.com/CodeCollaborate/Server/modules/metrics"
 	"github.com/CodeCollaborate/Server/modules/rabbitmq"
 	"github.com/CodeCollaborate/Server/utils"
 )
@@ -41,6 +44,7 @@ func (cont toSenderClosure) call(dh DataHandler) error {
 		utils.LogError("AMQP Publisher message queue full; failed to add new message", errors.New("Channel buffer full"), utils.LogFields{
 			"AMQP Message": msg,
 		})
+		metrics.AMQPBufferFullDrops.Inc("toSender")
 		return errors.New("Channel buffer full")
 	}
 	return nil
@@ -49,10 +53,36 @@ func (cont toSenderClosure) call(dh DataHandler) error {
 type toRabbitChannelClosure struct {
 	msg *messages.ServerMessageWrapper
 	key string
+	// inbox is the user whose inbox the notification is also stored in, so that they can read it if they are offline
+	inbox string
+}
+
+// toUserClosure creates a toRabbitChannelClosure sending the notification to the user's channel, and storing it in
+// their inbox
+func toUserClosure(msg *messages.ServerMessageWrapper, username string) toRabbitChannelClosure {
+	return toRabbitChannelClosure{msg: msg, key: rabbitmq.RabbitUserQueueName(username), inbox: username}
+}
+
+// toRabbitChannelClosures creates a toRabbitChannelClosure sending the message to each of the given routing keys
+func toRabbitChannelClosures(msg *messages.ServerMessageWrapper, keys []string) []dhClosure {
+	closures := make([]dhClosure, len(keys))
+	for i, key := range keys {
+		closures[i] = toRabbitChannelClosure{msg: msg, key: key}
+	}
+	return closures
 }
 
 // toRabbitChannelClosure.call is the function that will forward a server message to a channel based on the given routing key
 func (cont toRabbitChannelClosure) call(dh DataHandler) error {
+	if cont.inbox != "" {
+		// live sockets are still sent the notification if it cannot be stored
+		if err := storeNotification(dh.Db, cont.inbox, cont.msg); err != nil {
+			utils.LogError("Failed to store notification", err, utils.LogFields{
+				"Username": cont.inbox,
+			})
+		}
+	}
+
 	msgJSON, err := json.Marshal(cont.msg)
 	if err != nil {
 		return err
@@ -75,6 +105,7 @@ func (cont toRabbitChannelClosure) call(dh DataHandler) error {
 		utils.LogError("AMQP Publisher message queue full; failed to add new message", errors.New("Channel buffer full"), utils.LogFields{
 			"AMQP Message": msg,
 		})
+		metrics.AMQPBufferFullDrops.Inc("toRabbitChannel")
 		return errors.New("Channel buffer full")
 	}
 
@@ -115,8 +146,131 @@ func (cont rabbitCommandClosure) call(dh DataHandler) error {
 		utils.LogError("AMQP Publisher message queue full; failed to add new message", errors.New("Channel buffer full"), utils.LogFields{
 			"AMQP Message": msg,
 		})
+		metrics.AMQPBufferFullDrops.Inc("rabbitCommand")
 		return errors.New("Channel buffer full")
 	}
 
 	return nil
 }
+
+type auditClosure struct {
+	entry dbfs.AuditEntry
+}
+
+// auditClosure.call is the function that will record the entry in the audit log, along with the address of the client
+func (cont auditClosure) call(dh DataHandler) error {
+	cont.entry.SourceIP = dh.RemoteAddr
+	return dh.Db.MySQLAuditLogInsert(cont.entry)
+}
+
+type commentAnchorClosure struct {
+	meta dbfs.FileMeta
+	// keys are the routing keys threads orphaned by the change are announced on
+	keys []string
+}
+
+// commentAnchorClosure.call is the function that will shift the file's comment anchors through the latest changes,
+// notifying subscribers of any threads whose text was deleted
+func (cont commentAnchorClosure) call(dh DataHandler) error {
+	orphaned, err := dbfs.UpdateCommentAnchors(dh.Db, cont.meta)
+	if len(orphaned) == 0 {
+		return err
+	}
+
+	not := messages.Notification{
+		Resource:   "Comment",
+		Method:     "Orphan",
+		ResourceID: cont.meta.FileID,
+		Data: struct {
+			ThreadIDs []int64
+		}{
+			ThreadIDs: orphaned,
+		},
+	}.Wrap()
+	for _, key := range cont.keys {
+		if notErr := (toRabbitChannelClosure{msg: not, key: key}).call(dh); notErr != nil {
+			return notErr
+		}
+	}
+	return err
+}
+
+type suggestionRebaseClosure struct {
+	meta dbfs.FileMeta
+}
+
+// suggestionRebaseClosure.call is the function that will rebase the file's pending suggestions onto the latest changes
+func (cont suggestionRebaseClosure) call(dh DataHandler) error {
+	return dbfs.RebaseSuggestions(dh.Db, cont.meta)
+}
+
+type mailClosure struct {
+	msg mail.Message
+}
+
+// mailClosure.call is the function that will send the email through the configured mailer
+func (cont mailClosure) call(dh DataHandler) error {
+	return mailer.Send(cont.msg)
+}
+
+type inboxClosure struct {
+	msg      *messages.ServerMessageWrapper
+	username string
+}
+
+// inboxClosure.call is the function that will store a notification in the user's inbox, without sending it to them
+func (cont inboxClosure) call(dh DataHandler) error {
+	return storeNotification(dh.Db, cont.username, cont.msg)
+}
+
+// storeNotification stores the wrapped notification in the inbox of the user
+func storeNotification(db dbfs.DBFS, username string, msg *messages.ServerMessageWrapper) error {
+	not, ok := msg.ServerMessage.(messages.Notification)
+	if !ok {
+		return errors.New("Only notifications can be stored in an inbox")
+	}
+	data, err := json.Marshal(not.Data)
+	if err != nil {
+		return err
+	}
+	return db.MySQLNotificationInsert(username, dbfs.Notification{
+		Resource:   not.Resource,
+		Method:     not.Method,
+		ResourceID: not.ResourceID,
+		Data:       data,
+	})
+}
+
+type notificationBacklogClosure struct {
+	username string
+}
+
+// notificationBacklogClosure.call is the function that will send the user's unread notifications to the client, once
+// they have logged in
+func (cont notificationBacklogClosure) call(dh DataHandler) error {
+	notifications, err := dh.Db.MySQLNotificationsGet(cont.username, true, 0, maxNotificationLimit)
+	if err != nil {
+		return err
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+	unread, err := dh.Db.MySQLNotificationsCountUnread(cont.username)
+	if err != nil {
+		return err
+	}
+
+	not := messages.Notification{
+		Resource: "User",
+		Method:   "Notifications",
+		Data: struct {
+			Notifications []dbfs.Notification
+			// Unread is the total number of unread notifications, which may be more than were sent
+			Unread int
+		}{
+			Notifications: notifications,
+			Unread:        unread,
+		},
+	}.Wrap()
+	return toSenderClosure{msg: not}.call(dh)
+}
@@ -27,6 +27,15 @@ type abstractRequest struct {
 	Method      string
 	Timestamp   int64
 	Data        json.RawMessage // date is a byte for now because we don't want it to unmarshal it yet
+
+	// shareLinkID is set if the request was authenticated with a share link token
+	shareLinkID int64
+	// accessToken is set if the request was authenticated with a personal access token
+	accessToken bool
+	// remoteAddr is the IP address of the client, set by the DataHandler
+	remoteAddr string
+	// socket is the name of the queue of the client's websocket, set by the DataHandler
+	socket string
 }
 
 // CreateAbstractRequest is the testable parsing into abstractRequests
@@ -2,6 +2,8 @@ package datahandling
 
 import (
 	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/dbfs"
 )
 
 func TestCreateValidAbstractRequest(t *testing.T) {
@@ -42,7 +44,7 @@ func TestCreateValidAbstractRequest(t *testing.T) {
 	if req.Data == nil {
 		t.Fail()
 	}
-	fullReq, err := getFullRequest(req)
+	fullReq, err := getFullRequest(req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1,6 +1,9 @@
 package datahandling
 
 import (
+	"path/filepath"
+
+	"github.com/CodeCollaborate/Server/modules/config"
 	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
 	"github.com/CodeCollaborate/Server/modules/dbfs"
 	"github.com/CodeCollaborate/Server/modules/rabbitmq"
@@ -41,6 +44,10 @@ func initFileRequests() {
 		return commonJSON(new(fileDeleteRequest), req)
 	}
 
+	authenticatedRequestMap["File.Restore"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(fileRestoreRequest), req)
+	}
+
 	authenticatedRequestMap["File.Change"] = func(req *abstractRequest) (request, error) {
 		return commonJSON(new(fileChangeRequest), req)
 	}
@@ -49,9 +56,46 @@ func initFileRequests() {
 		return commonJSON(new(filePullRequest), req)
 	}
 
+	authenticatedRequestMap["File.Lock"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(fileLockRequest), req)
+	}
+
+	authenticatedRequestMap["File.Unlock"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(fileUnlockRequest), req)
+	}
+
 	fileRequestsSetup = true
 }
 
+// fileNotificationKeys returns the routing keys notifications about the given files are sent to. Files without
+// path permission rules are broadcast to the project; otherwise each user that can read any of the files is
+// notified on their own queue, so that users without access never receive them.
+func fileNotificationKeys(access *dbfs.FileAccess, files ...dbfs.FileMeta) ([]string, error) {
+	restricted := false
+	for _, file := range files {
+		restricted = restricted || access.Restricted(file)
+	}
+	if !restricted {
+		return []string{rabbitmq.RabbitProjectQueueName(access.ProjectID)}, nil
+	}
+
+	keys := []string{}
+	notified := make(map[string]bool)
+	for _, file := range files {
+		readers, err := access.Readers(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, username := range readers {
+			if !notified[username] {
+				notified[username] = true
+				keys = append(keys, rabbitmq.RabbitUserQueueName(username))
+			}
+		}
+	}
+	return keys, nil
+}
+
 // File.Create
 type fileCreateRequest struct {
 	Name         string
@@ -65,9 +109,18 @@ func (f *fileCreateRequest) setAbstractRequest(req *abstractRequest) {
 	f.abstractRequest = *req
 }
 
+func (f fileCreateRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{f.ProjectID}, nil
+}
+
 func (f fileCreateRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
-	hasPermission, err := dbfs.PermissionAtLeast(f.SenderID, f.ProjectID, "write", db)
-	if err != nil || !hasPermission {
+	newFile := dbfs.FileMeta{
+		ProjectID:    f.ProjectID,
+		RelativePath: f.RelativePath,
+		Filename:     f.Name,
+	}
+	access, err := dbfs.NewFileAccess(f.SenderID, f.ProjectID, db)
+	if err != nil || !access.Can(newFile, config.CapabilityWrite) {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  f.Resource,
 			"Method":    f.Method,
@@ -77,6 +130,11 @@ func (f fileCreateRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
 	}
 
+	notifyKeys, err := fileNotificationKeys(access, newFile)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
 	fileID, err := db.MySQLFileCreate(f.SenderID, f.Name, f.RelativePath, f.ProjectID)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
@@ -117,7 +175,7 @@ func (f fileCreateRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		},
 	}.Wrap()
 
-	return []dhClosure{toSenderClosure{msg: res}, toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(f.ProjectID)}}, nil
+	return append([]dhClosure{toSenderClosure{msg: res}}, toRabbitChannelClosures(not, notifyKeys)...), nil
 }
 
 // File.Rename
@@ -131,14 +189,21 @@ func (f *fileRenameRequest) setAbstractRequest(req *abstractRequest) {
 	f.abstractRequest = *req
 }
 
+func (f fileRenameRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return fileProjectIDs(db, f.FileID)
+}
+
 func (f fileRenameRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 	fileMeta, err := db.MySQLFileGetInfo(f.FileID)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
 	}
 
-	hasPermission, err := dbfs.PermissionAtLeast(f.SenderID, fileMeta.ProjectID, "write", db)
-	if err != nil || !hasPermission {
+	renamedFile := fileMeta
+	renamedFile.Filename = f.NewName
+
+	access, err := dbfs.NewFileAccess(f.SenderID, fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityManageFiles) || !access.Can(renamedFile, config.CapabilityManageFiles) {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  f.Resource,
 			"Method":    f.Method,
@@ -148,6 +213,12 @@ func (f fileRenameRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
 	}
 
+	// notify users that could see the file under either name
+	notifyKeys, err := fileNotificationKeys(access, fileMeta, renamedFile)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
 	err = db.MySQLFileRename(f.FileID, f.NewName)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
@@ -170,7 +241,17 @@ func (f fileRenameRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		},
 	}.Wrap()
 
-	return []dhClosure{toSenderClosure{msg: res}, toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(fileMeta.ProjectID)}}, nil
+	closures := []dhClosure{toSenderClosure{msg: res}}
+	closures = append(closures, toRabbitChannelClosures(not, notifyKeys)...)
+	return append(closures,
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     f.SenderID,
+			Action:    "File.Rename",
+			ProjectID: fileMeta.ProjectID,
+			Target:    filepath.Join(fileMeta.RelativePath, fileMeta.Filename),
+			Detail:    f.NewName,
+		}},
+	), nil
 }
 
 // File.Move
@@ -184,14 +265,21 @@ func (f *fileMoveRequest) setAbstractRequest(req *abstractRequest) {
 	f.abstractRequest = *req
 }
 
+func (f fileMoveRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return fileProjectIDs(db, f.FileID)
+}
+
 func (f fileMoveRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 	fileMeta, err := db.MySQLFileGetInfo(f.FileID)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
 	}
 
-	hasPermission, err := dbfs.PermissionAtLeast(f.SenderID, fileMeta.ProjectID, "write", db)
-	if err != nil || !hasPermission {
+	movedFile := fileMeta
+	movedFile.RelativePath = f.NewPath
+
+	access, err := dbfs.NewFileAccess(f.SenderID, fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityManageFiles) || !access.Can(movedFile, config.CapabilityManageFiles) {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  f.Resource,
 			"Method":    f.Method,
@@ -201,6 +289,12 @@ func (f fileMoveRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
 	}
 
+	// notify users that could see the file in either location
+	notifyKeys, err := fileNotificationKeys(access, fileMeta, movedFile)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
 	err = db.MySQLFileMove(f.FileID, f.NewPath)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
@@ -223,7 +317,17 @@ func (f fileMoveRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		},
 	}.Wrap()
 
-	return []dhClosure{toSenderClosure{msg: res}, toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(fileMeta.ProjectID)}}, nil
+	closures := []dhClosure{toSenderClosure{msg: res}}
+	closures = append(closures, toRabbitChannelClosures(not, notifyKeys)...)
+	return append(closures,
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     f.SenderID,
+			Action:    "File.Move",
+			ProjectID: fileMeta.ProjectID,
+			Target:    filepath.Join(fileMeta.RelativePath, fileMeta.Filename),
+			Detail:    f.NewPath,
+		}},
+	), nil
 }
 
 // File.Delete
@@ -236,14 +340,18 @@ func (f *fileDeleteRequest) setAbstractRequest(req *abstractRequest) {
 	f.abstractRequest = *req
 }
 
+func (f fileDeleteRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return fileProjectIDs(db, f.FileID)
+}
+
 func (f fileDeleteRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 	fileMeta, err := db.MySQLFileGetInfo(f.FileID)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
 	}
 
-	hasPermission, err := dbfs.PermissionAtLeast(f.SenderID, fileMeta.ProjectID, "write", db)
-	if err != nil || !hasPermission {
+	access, err := dbfs.NewFileAccess(f.SenderID, fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityManageFiles) {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  f.Resource,
 			"Method":    f.Method,
@@ -253,17 +361,18 @@ func (f fileDeleteRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
 	}
 
-	err = db.MySQLFileDelete(f.FileID)
+	notifyKeys, err := fileNotificationKeys(access, fileMeta)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
 	}
 
-	err = db.FileDelete(fileMeta.RelativePath, fileMeta.Filename, fileMeta.ProjectID)
+	// files are moved to the trash, and purged once the retention period has passed
+	err = db.MySQLFileTrash(f.FileID, f.SenderID)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
 	}
 
-	err = db.CBDeleteFile(f.FileID)
+	err = db.FileTrash(fileMeta)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
 	}
@@ -276,10 +385,111 @@ func (f fileDeleteRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		Data:       struct{}{},
 	}.Wrap()
 
-	return []dhClosure{
-		toSenderClosure{msg: res},
-		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(fileMeta.ProjectID)},
-	}, nil
+	closures := []dhClosure{toSenderClosure{msg: res}}
+	closures = append(closures, toRabbitChannelClosures(not, notifyKeys)...)
+	return append(closures,
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     f.SenderID,
+			Action:    "File.Delete",
+			ProjectID: fileMeta.ProjectID,
+			Target:    filepath.Join(fileMeta.RelativePath, fileMeta.Filename),
+		}},
+	), nil
+}
+
+// File.Restore
+type fileRestoreRequest struct {
+	FileID int64
+	abstractRequest
+}
+
+func (f *fileRestoreRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f fileRestoreRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return fileProjectIDs(db, f.FileID)
+}
+
+func (f fileRestoreRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	trashed, err := db.MySQLFileGetTrashedInfo(f.FileID)
+	if err != nil {
+		if err == dbfs.ErrNoData {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+		}
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+	fileMeta := trashed.FileMeta
+
+	access, err := dbfs.NewFileAccess(f.SenderID, fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityManageFiles) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  f.Resource,
+			"Method":    f.Method,
+			"SenderID":  f.SenderID,
+			"ProjectID": fileMeta.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
+	}
+
+	// a new file may have been created at the same path since this one was deleted
+	files, err := db.MySQLProjectGetFiles(fileMeta.ProjectID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+	for _, file := range files {
+		if file.RelativePath == fileMeta.RelativePath && file.Filename == fileMeta.Filename {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNameConflict, f.Tag)}}, nil
+		}
+	}
+
+	notifyKeys, err := fileNotificationKeys(access, fileMeta)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	err = db.MySQLFileRestore(f.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	err = db.FileRestore(fileMeta)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServPartialFail, f.Tag)}}, err
+	}
+
+	version, err := db.CBGetFileVersion(f.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServPartialFail, f.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, f.Tag)
+	not := messages.Notification{
+		Resource:   f.Resource,
+		Method:     f.Method,
+		ResourceID: fileMeta.ProjectID,
+		Data: struct {
+			File File
+		}{
+			File: File{
+				FileID:       fileMeta.FileID,
+				Filename:     fileMeta.Filename,
+				RelativePath: fileMeta.RelativePath,
+				Version:      version,
+			},
+		},
+	}.Wrap()
+
+	closures := []dhClosure{toSenderClosure{msg: res}}
+	closures = append(closures, toRabbitChannelClosures(not, notifyKeys)...)
+	return append(closures,
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     f.SenderID,
+			Action:    "File.Restore",
+			ProjectID: fileMeta.ProjectID,
+			Target:    filepath.Join(fileMeta.RelativePath, fileMeta.Filename),
+		}},
+	), nil
 }
 
 // File.Change
@@ -293,6 +503,10 @@ func (f *fileChangeRequest) setAbstractRequest(req *abstractRequest) {
 	f.abstractRequest = *req
 }
 
+func (f fileChangeRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return fileProjectIDs(db, f.FileID)
+}
+
 func (f fileChangeRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 	// This has to be before the CouchBase append, to make sure that the the two databases are kept in sync.
 	// Specifically, this prevents CouchBase from incrementing a version number without the notifications being sent out.
@@ -301,8 +515,9 @@ func (f fileChangeRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
 	}
 
-	hasPermission, err := dbfs.PermissionAtLeast(f.SenderID, fileMeta.ProjectID, "write", db)
-	if err != nil || !hasPermission {
+	access, err := dbfs.NewFileAccess(f.SenderID, fileMeta.ProjectID, db)
+	canWrite := err == nil && access.Can(fileMeta, config.CapabilityWrite)
+	if !canWrite && (err != nil || !access.Can(fileMeta, config.CapabilitySuggest)) {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  f.Resource,
 			"Method":    f.Method,
@@ -312,6 +527,20 @@ func (f fileChangeRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
 	}
 
+	notifyKeys, err := fileNotificationKeys(access, fileMeta)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	if !canWrite {
+		// changes from suggesters are held for review by a writer, rather than applied
+		return f.suggest(db, fileMeta, notifyKeys)
+	}
+
+	if rejection, err := checkFileLock(db, f.FileID, f.socket, f.Tag); rejection != nil {
+		return []dhClosure{toSenderClosure{msg: rejection}}, err
+	}
+
 	// TODO (normal/optional): verify changes are valid changes
 	changes, version, missing, numchanges, err := db.CBAppendFileChange(fileMeta, f.Changes)
 	if err != nil {
@@ -336,10 +565,22 @@ func (f fileChangeRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 			MissingPatches: missing,
 		},
 	}.Wrap()
+
+	// Trigger scrunching if longer than maxBufferLength
+	if numchanges > dbfs.MaxBufferLength {
+		dbfs.ScrunchInBackground(db, fileMeta)
+	}
+
+	return append([]dhClosure{toSenderClosure{msg: res}}, fileChangedClosures(fileMeta, version, changes, notifyKeys)...), nil
+}
+
+// fileChangedClosures notifies the file's subscribers of a change applied to it, and brings the file's comment
+// anchors and pending suggestions up to date with the change
+func fileChangedClosures(fileMeta dbfs.FileMeta, version int64, changes string, notifyKeys []string) []dhClosure {
 	not := messages.Notification{
-		Resource:   f.Resource,
-		Method:     f.Method,
-		ResourceID: f.FileID,
+		Resource:   "File",
+		Method:     "Change",
+		ResourceID: fileMeta.FileID,
 		Data: struct {
 			FileVersion int64
 			Changes     string
@@ -349,14 +590,8 @@ func (f fileChangeRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		},
 	}.Wrap()
 
-	// Trigger scrunching if longer than maxBufferLength
-	if numchanges > dbfs.MaxBufferLength {
-		go func() {
-			db.ScrunchFile(fileMeta)
-		}()
-	}
-
-	return []dhClosure{toSenderClosure{msg: res}, toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(fileMeta.ProjectID)}}, nil
+	closures := toRabbitChannelClosures(not, notifyKeys)
+	return append(closures, commentAnchorClosure{meta: fileMeta, keys: notifyKeys}, suggestionRebaseClosure{meta: fileMeta})
 }
 
 // File.Pull
@@ -369,14 +604,18 @@ func (f *filePullRequest) setAbstractRequest(req *abstractRequest) {
 	f.abstractRequest = *req
 }
 
+func (f filePullRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return fileProjectIDs(db, f.FileID)
+}
+
 func (f filePullRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 	fileMeta, err := db.MySQLFileGetInfo(f.FileID)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
 	}
 
-	hasPermission, err := dbfs.PermissionAtLeast(f.SenderID, fileMeta.ProjectID, "read", db)
-	if err != nil || !hasPermission {
+	access, err := f.fileAccess(fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityRead) {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  f.Resource,
 			"Method":    f.Method,
@@ -405,3 +644,209 @@ func (f filePullRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 
 	return []dhClosure{toSenderClosure{msg: res}}, nil
 }
+
+// checkFileLock returns the response rejecting a change to the file from the given websocket, if another websocket
+// holds an enforced lock on it. This includes the other websockets of the lock's holder.
+func checkFileLock(db dbfs.DBFS, fileID int64, socket string, tag int64) (*messages.ServerMessageWrapper, error) {
+	lock, err := db.MySQLFileLockGet(fileID)
+	if err == dbfs.ErrNoData {
+		return nil, nil
+	} else if err != nil {
+		return messages.NewEmptyResponse(messages.StatusServFail, tag), err
+	}
+
+	if lock.Enforced && lock.Socket != socket {
+		return newRejectedResponse(tag, "The file is locked by "+lock.Username), nil
+	}
+	return nil, nil
+}
+
+// fileUnlockClosures notifies the subscribers of the file that the lock on it was released
+func fileUnlockClosures(db dbfs.DBFS, lock dbfs.FileLock, unlockedBy string) ([]dhClosure, error) {
+	fileMeta, err := db.MySQLFileGetInfo(lock.FileID)
+	if err != nil {
+		return nil, err
+	}
+	access, err := dbfs.NewFileAccess(lock.Username, fileMeta.ProjectID, db)
+	if err != nil {
+		return nil, err
+	}
+	notifyKeys, err := fileNotificationKeys(access, fileMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	not := messages.Notification{
+		Resource:   "File",
+		Method:     "Unlock",
+		ResourceID: lock.FileID,
+		Data: struct {
+			Username   string
+			UnlockedBy string
+		}{
+			Username:   lock.Username,
+			UnlockedBy: unlockedBy,
+		},
+	}.Wrap()
+	return toRabbitChannelClosures(not, notifyKeys), nil
+}
+
+// File.Lock
+type fileLockRequest struct {
+	FileID int64
+	// Enforced locks reject changes from every other websocket; other locks are only advisory
+	Enforced bool
+	abstractRequest
+}
+
+func (f *fileLockRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f fileLockRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return fileProjectIDs(db, f.FileID)
+}
+
+// process locks the file for the sender's websocket, or renews its lock on it. Locks must be renewed by the same
+// websocket before their TTL runs out, and are released when it disconnects.
+func (f fileLockRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	fileMeta, err := db.MySQLFileGetInfo(f.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+	}
+
+	access, err := dbfs.NewFileAccess(f.SenderID, fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityWrite) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  f.Resource,
+			"Method":    f.Method,
+			"SenderID":  f.SenderID,
+			"ProjectID": fileMeta.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
+	}
+
+	ttl, err := config.GetConfig().ServerConfig.FileLockTTLDuration()
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+	notifyKeys, err := fileNotificationKeys(access, fileMeta)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+
+	// renewals are not announced, unless they change whether the lock is enforced
+	previous, err := db.MySQLFileLockGet(f.FileID)
+	if err != nil && err != dbfs.ErrNoData {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+	renewal := err == nil && previous.Socket == f.socket && previous.Enforced == f.Enforced
+
+	lock, err := db.MySQLFileLockAcquire(dbfs.FileLock{
+		FileID:   f.FileID,
+		Username: f.SenderID,
+		Socket:   f.socket,
+		Enforced: f.Enforced,
+	}, ttl)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+	if lock.Socket != f.socket {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, "The file is locked by "+lock.Username)}}, nil
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			Lock dbfs.FileLock
+		}{
+			Lock: lock,
+		},
+	}.Wrap()
+	if renewal {
+		return []dhClosure{toSenderClosure{msg: res}}, nil
+	}
+
+	not := messages.Notification{
+		Resource:   f.Resource,
+		Method:     f.Method,
+		ResourceID: f.FileID,
+		Data: struct {
+			Lock dbfs.FileLock
+		}{
+			Lock: lock,
+		},
+	}.Wrap()
+
+	return append([]dhClosure{toSenderClosure{msg: res}}, toRabbitChannelClosures(not, notifyKeys)...), nil
+}
+
+// File.Unlock
+type fileUnlockRequest struct {
+	FileID int64
+	// Force breaks a lock held by another user
+	Force bool
+	abstractRequest
+}
+
+func (f *fileUnlockRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f fileUnlockRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return fileProjectIDs(db, f.FileID)
+}
+
+func (f fileUnlockRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	fileMeta, err := db.MySQLFileGetInfo(f.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+	}
+
+	access, err := dbfs.NewFileAccess(f.SenderID, fileMeta.ProjectID, db)
+	if err != nil || (f.Force && !access.Can(fileMeta, config.CapabilityBreakLocks)) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  f.Resource,
+			"Method":    f.Method,
+			"SenderID":  f.SenderID,
+			"ProjectID": fileMeta.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
+	}
+
+	lock, err := db.MySQLFileLockGet(f.FileID)
+	if err == dbfs.ErrNoData {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+	if lock.Username != f.SenderID && !f.Force {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, "The file is locked by "+lock.Username)}}, nil
+	}
+
+	err = db.MySQLFileLockRelease(f.FileID, f.SenderID, f.Force)
+	if err == dbfs.ErrNoDbChange {
+		// released, or expired, since it was read
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+
+	notifications, err := fileUnlockClosures(db, lock, f.SenderID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+
+	closures := append([]dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, f.Tag)}}, notifications...)
+	if lock.Username != f.SenderID {
+		closures = append(closures, auditClosure{entry: dbfs.AuditEntry{
+			Actor:     f.SenderID,
+			Action:    "File.Unlock",
+			ProjectID: fileMeta.ProjectID,
+			Target:    filepath.Join(fileMeta.RelativePath, fileMeta.Filename),
+			Detail:    lock.Username,
+		}})
+	}
+	return closures, nil
+}
@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/CodeCollaborate/Server/modules/config"
 	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
 	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var geneMeta = dbfs.UserMeta{
@@ -42,7 +46,7 @@ func TestFileCreateRequest_Process(t *testing.T) {
 	}
 
 	// didn't call extra db functions
-	assert.Equal(t, 4, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 5, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
 	if len(closures) != 2 ||
@@ -93,12 +97,13 @@ func TestFileRenameRequest_Process(t *testing.T) {
 	}
 
 	// didn't call extra db functions
-	assert.Equal(t, 4, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 5, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
-	if len(closures) != 2 ||
+	if len(closures) != 3 ||
 		reflect.TypeOf(closures[0]).String() != "datahandling.toSenderClosure" ||
-		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" {
+		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" ||
+		reflect.TypeOf(closures[2]).String() != "datahandling.auditClosure" {
 		t.Fatalf("did not properly process, recieved %d closure(s)", len(closures))
 	}
 
@@ -150,12 +155,13 @@ func TestFileMoveRequest_Process(t *testing.T) {
 	}
 
 	// didn't call extra db functions
-	assert.Equal(t, 4, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 5, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
-	if len(closures) != 2 ||
+	if len(closures) != 3 ||
 		reflect.TypeOf(closures[0]).String() != "datahandling.toSenderClosure" ||
-		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" {
+		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" ||
+		reflect.TypeOf(closures[2]).String() != "datahandling.auditClosure" {
 		t.Fatalf("did not properly process, recieved %d closure(s)", len(closures))
 	}
 
@@ -209,9 +215,10 @@ func TestFileDeleteRequest_Process(t *testing.T) {
 	assert.Equal(t, 5, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
-	if len(closures) != 2 ||
+	if len(closures) != 3 ||
 		reflect.TypeOf(closures[0]).String() != "datahandling.toSenderClosure" ||
-		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" {
+		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" ||
+		reflect.TypeOf(closures[2]).String() != "datahandling.auditClosure" {
 		t.Fatalf("did not properly process, recieved %d closure(s)", len(closures))
 	}
 
@@ -234,7 +241,57 @@ func TestFileDeleteRequest_Process(t *testing.T) {
 	if _, ok := db.Files[fileid]; ok {
 		t.Fatal("File still exists")
 	}
+	assert.Contains(t, db.TrashedFiles, fileid, "deleted files should be moved to the trash")
+
+}
+
+func TestFileRestoreRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.FileIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "hi")
+	db.MySQLProjectGrantPermission(projectID, "reader", config.PermissionsByLabel["read"], "loganga")
+	fileID, _ := db.MySQLFileCreate("loganga", "new file", "src", projectID)
+	db.FileVersion[fileID] = 3
+	db.MySQLFileTrash(fileID, "loganga")
+
+	req := fileRestoreRequest{FileID: fileID}
+	setBaseFields(&req)
+	req.Resource = "File"
+	req.Method = "Restore"
+	req.SenderID = "reader"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+
+	// a file created at the same path blocks the restore
+	req.SenderID = "loganga"
+	newFileID, _ := db.MySQLFileCreate("loganga", "new file", "src", projectID)
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNameConflict, resp.Status, "unexpected response status")
+
+	db.MySQLFileDelete(newFileID)
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	not := closures[1].(toRabbitChannelClosure).msg.ServerMessage.(messages.Notification)
+	file := reflect.ValueOf(not.Data).FieldByName("File").Interface().(File)
+	assert.Equal(t, int64(3), file.Version, "the file should keep its version")
+	assert.Empty(t, db.TrashedFiles)
+	if assert.Len(t, db.Files[projectID], 1) {
+		assert.Equal(t, fileID, db.Files[projectID][0].FileID)
+	}
 
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNotFound, resp.Status, "files which are not in the trash can't be restored")
 }
 
 func TestFileChangeRequest_Process(t *testing.T) {
@@ -262,12 +319,14 @@ func TestFileChangeRequest_Process(t *testing.T) {
 	}
 
 	// didn't call extra db functions
-	assert.Equal(t, 3, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 5, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
-	if len(closures) != 2 ||
+	if len(closures) != 4 ||
 		reflect.TypeOf(closures[0]).String() != "datahandling.toSenderClosure" ||
-		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" {
+		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" ||
+		reflect.TypeOf(closures[2]).String() != "datahandling.commentAnchorClosure" ||
+		reflect.TypeOf(closures[3]).String() != "datahandling.suggestionRebaseClosure" {
 		t.Fatalf("did not properly process, recieved %d closure(s)", len(closures))
 	}
 
@@ -312,7 +371,7 @@ func TestFileChangeRequest_Process(t *testing.T) {
 	}
 
 	// didn't call extra db functions
-	assert.Equal(t, 3, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 5, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
 	if len(closures) != 1 ||
@@ -354,7 +413,7 @@ func TestFilePullRequest_Process(t *testing.T) {
 	}
 
 	// didn't call extra db functions
-	if db.FunctionCallCount != 3 {
+	if db.FunctionCallCount != 4 {
 		t.Fatal("did not call correct number of db functions")
 	}
 
@@ -376,3 +435,199 @@ func TestFilePullRequest_Process(t *testing.T) {
 		t.Fatalf("wrong file changes, expected: %v, got: %v", changes, fileChanges)
 	}
 }
+
+func TestFileChangeRequest_PathPermissions(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.MySQLUserRegister(geneMeta)
+	projectID, _ := db.MySQLProjectCreate("loganga", "hi")
+	db.MySQLProjectGrantPermission(projectID, "contractor", config.PermissionsByLabel["write"], "loganga")
+	db.MySQLProjectGrantPermission(projectID, "developer", config.PermissionsByLabel["write"], "loganga")
+	fileID, _ := db.MySQLFileCreate("loganga", "main.go", "backend", projectID)
+	db.CBInsertNewFile(fileID, newFileVersion, []string{})
+	db.PathPermissions[projectID] = []dbfs.PathPermission{
+		{PathPrefix: "backend", Username: "contractor", PermissionLevel: 0},
+	}
+
+	req := *new(fileChangeRequest)
+	setBaseFields(&req)
+	req.Resource = "File"
+	req.Method = "Change"
+	req.FileID = fileID
+	req.Changes = "v0:\n0:+1:a:\n10"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+
+	// restricted files are only sent to the users that can read them
+	keys := []string{}
+	for _, closure := range closures[1 : len(closures)-2] {
+		keys = append(keys, closure.(toRabbitChannelClosure).key)
+	}
+	assert.Equal(t, []string{rabbitmq.RabbitUserQueueName("developer"), rabbitmq.RabbitUserQueueName("loganga")}, keys)
+	assert.Equal(t, keys, closures[len(closures)-2].(commentAnchorClosure).keys)
+
+	req.SenderID = "contractor"
+	req.Changes = "v1:\n0:+1:b:\n11"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "users should not change files they can't read")
+}
+
+func TestFileLockRequests_Process(t *testing.T) {
+	db, fileMeta := fileSetup(t, map[string]string{"writer": "write", "other": "write"})
+
+	lock := fileLockRequest{FileID: fileMeta.FileID, Enforced: true}
+	setBaseFields(&lock)
+	lock.Resource = "File"
+	lock.Method = "Lock"
+	lock.SenderID = "writer"
+	lock.socket = "WS-host-1"
+
+	closures, err := lock.process(db)
+	assert.Nil(t, err)
+	require.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	held := reflect.ValueOf(resp.Data).FieldByName("Lock").Interface().(dbfs.FileLock)
+	assert.Equal(t, "writer", held.Username)
+	assert.True(t, held.ExpiryDate.After(time.Now()))
+	notification := closures[1].(toRabbitChannelClosure)
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(fileMeta.ProjectID), notification.key)
+	assert.Equal(t, "Lock", notification.msg.ServerMessage.(messages.Notification).Method)
+
+	// renewing the lock is not announced
+	closures, err = lock.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(closures), "unexpected number of returned closures")
+
+	// other websockets can neither take the lock, nor change the file while it is held, even the holder's own
+	lock.SenderID = "other"
+	lock.socket = "WS-host-2"
+	closures, err = lock.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "locked files should not be locked by others")
+
+	lock.SenderID = "writer"
+	lock.socket = "WS-host-3"
+	closures, err = lock.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "locks should only be renewed by the websocket holding them")
+	assert.Equal(t, "WS-host-1", db.FileLocks[fileMeta.FileID].Socket)
+
+	change := fileChangeRequest{FileID: fileMeta.FileID, Changes: "v2:\n0:+1:a:\n20"}
+	setBaseFields(&change)
+	change.Resource = "File"
+	change.Method = "Change"
+	change.SenderID = "other"
+	change.socket = "WS-host-2"
+
+	closures, err = change.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "locked files should not be changed by others")
+
+	change.SenderID = "writer"
+	change.socket = "WS-host-3"
+	closures, err = change.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "locked files should not be changed by the holder's other websockets")
+	assert.Equal(t, 1, len(db.FileChanges[fileMeta.FileID]))
+
+	change.socket = "WS-host-1"
+	closures, err = change.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "lock holders should be able to change the file")
+
+	unlock := fileUnlockRequest{FileID: fileMeta.FileID}
+	setBaseFields(&unlock)
+	unlock.Resource = "File"
+	unlock.Method = "Unlock"
+	unlock.SenderID = "other"
+
+	closures, err = unlock.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "locks should not be released by others")
+
+	unlock.Force = true
+	closures, err = unlock.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "writers should not break locks")
+
+	// admins can break the lock, which is audited
+	unlock.SenderID = "loganga"
+	closures, err = unlock.process(db)
+	assert.Nil(t, err)
+	require.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	notification = closures[1].(toRabbitChannelClosure)
+	assert.Equal(t, "Unlock", notification.msg.ServerMessage.(messages.Notification).Method)
+	assert.Equal(t, "writer", closures[2].(auditClosure).entry.Detail)
+	assert.Empty(t, db.FileLocks)
+
+	closures, err = unlock.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNotFound, resp.Status, "unexpected response status")
+
+	// advisory locks don't stop others from changing the file
+	lock.SenderID = "writer"
+	lock.socket = "WS-host-1"
+	lock.Enforced = false
+	closures, err = lock.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+
+	change.SenderID = "other"
+	change.socket = "WS-host-2"
+	change.Changes = "v3:\n0:+1:b:\n21"
+	closures, err = change.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "advisory locks should not reject changes")
+
+	// expired locks can be taken over
+	held = db.FileLocks[fileMeta.FileID]
+	held.ExpiryDate = time.Now().Add(-time.Second)
+	db.FileLocks[fileMeta.FileID] = held
+	lock.SenderID = "other"
+	lock.socket = "WS-host-2"
+	closures, err = lock.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "expired locks should be taken over")
+	assert.Equal(t, "other", db.FileLocks[fileMeta.FileID].Username)
+}
+
+func TestDataHandler_ReleaseLocks(t *testing.T) {
+	db, fileMeta := fileSetup(t, map[string]string{"writer": "write", "other": "write"})
+	db.MySQLFileLockAcquire(dbfs.FileLock{
+		FileID:   fileMeta.FileID,
+		Username: "writer",
+		Socket:   rabbitmq.RabbitWebsocketQueueName(1),
+		Enforced: true,
+	}, time.Minute)
+
+	messageChan := make(chan rabbitmq.AMQPMessage, 1)
+	DataHandler{Db: db, MessageChan: messageChan, WebsocketID: 2}.ReleaseLocks()
+	assert.Equal(t, 1, len(db.FileLocks), "locks should only be released for their own socket")
+	assert.Equal(t, 0, len(messageChan))
+
+	DataHandler{Db: db, MessageChan: messageChan, WebsocketID: 1}.ReleaseLocks()
+	assert.Empty(t, db.FileLocks)
+	require.Equal(t, 1, len(messageChan))
+	msg := <-messageChan
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(fileMeta.ProjectID), msg.RoutingKey)
+	assert.Contains(t, string(msg.Message), "Unlock")
+}
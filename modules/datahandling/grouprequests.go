@@ -0,0 +1,296 @@
+package datahandling
+
+import (
+	"strings"
+
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+var groupRequestsSetup = false
+
+// initGroupRequests populates the requestMap from requestmap.go with the appropriate constructors for the group methods
+func initGroupRequests() {
+	if groupRequestsSetup {
+		return
+	}
+
+	authenticatedRequestMap["Group.Create"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(groupCreateRequest), req)
+	}
+
+	authenticatedRequestMap["Group.AddMember"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(groupAddMemberRequest), req)
+	}
+
+	authenticatedRequestMap["Group.RemoveMember"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(groupRemoveMemberRequest), req)
+	}
+
+	authenticatedRequestMap["Group.List"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(groupListRequest), req)
+	}
+
+	groupRequestsSetup = true
+}
+
+// isGroupMember returns true if the user is a member of the group with the given groupID
+func isGroupMember(groupID int64, username string, db dbfs.DBFS) (bool, error) {
+	members, err := db.MySQLGroupGetMembers(groupID)
+	if err != nil {
+		return false, err
+	}
+	for _, member := range members {
+		if member == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Group.Create
+type groupCreateRequest struct {
+	Name string
+	abstractRequest
+}
+
+func (g *groupCreateRequest) setAbstractRequest(req *abstractRequest) {
+	g.abstractRequest = *req
+}
+
+func (g groupCreateRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	g.Name = strings.TrimSpace(g.Name)
+	if g.Name == "" {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, g.Tag)}}, nil
+	}
+
+	groupID, err := db.MySQLGroupCreate(g.SenderID, g.Name)
+	if err != nil {
+		// group names are unique for each owner
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, g.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    g.Tag,
+		Data: struct {
+			GroupID int64
+		}{
+			GroupID: groupID,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:  g.SenderID,
+			Action: "Group.Create",
+			Target: g.Name,
+		}},
+	}, nil
+}
+
+// Group.AddMember
+type groupAddMemberRequest struct {
+	GroupID  int64
+	Username string
+	abstractRequest
+}
+
+func (g *groupAddMemberRequest) setAbstractRequest(req *abstractRequest) {
+	g.abstractRequest = *req
+}
+
+func (g groupAddMemberRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	group, err := db.MySQLGroupGetInfo(g.GroupID)
+	if err != nil || group.Owner != g.SenderID {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource": g.Resource,
+			"Method":   g.Method,
+			"SenderID": g.SenderID,
+			"GroupID":  g.GroupID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, g.Tag)}}, nil
+	}
+
+	g.Username = strings.ToLower(g.Username)
+
+	err = db.MySQLGroupAddMember(g.GroupID, g.Username, g.SenderID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, g.Tag)}}, err
+	}
+
+	projectIDs, err := db.MySQLGroupGetProjects(g.GroupID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServPartialFail, g.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, g.Tag)
+	not := messages.Notification{
+		Resource:   g.Resource,
+		Method:     g.Method,
+		ResourceID: g.GroupID,
+		Data: struct {
+			Username   string
+			ProjectIDs []int64
+		}{
+			Username:   g.Username,
+			ProjectIDs: projectIDs,
+		},
+	}.Wrap()
+
+	closures := []dhClosure{
+		toSenderClosure{msg: res},
+		toUserClosure(not, g.Username),
+	}
+	// Subscribe the new member's live sockets to the projects the group has access to
+	for _, projectID := range projectIDs {
+		closures = append(closures, rabbitCommandClosure{
+			Command: "Subscribe",
+			Tag:     -1,
+			Key:     rabbitmq.RabbitUserQueueName(g.Username),
+			Data: rabbitmq.RabbitQueueData{
+				Key: rabbitmq.RabbitProjectQueueName(projectID),
+			},
+		})
+	}
+
+	return append(closures, auditClosure{entry: dbfs.AuditEntry{
+		Actor:  g.SenderID,
+		Action: "Group.AddMember",
+		Target: g.Username,
+		Detail: group.Name,
+	}}), nil
+}
+
+// Group.RemoveMember
+type groupRemoveMemberRequest struct {
+	GroupID  int64
+	Username string
+	abstractRequest
+}
+
+func (g *groupRemoveMemberRequest) setAbstractRequest(req *abstractRequest) {
+	g.abstractRequest = *req
+}
+
+func (g groupRemoveMemberRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	g.Username = strings.ToLower(g.Username)
+
+	// allow case where user is leaving the group themselves
+	group, err := db.MySQLGroupGetInfo(g.GroupID)
+	if err != nil || (group.Owner != g.SenderID && g.Username != g.SenderID) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource": g.Resource,
+			"Method":   g.Method,
+			"SenderID": g.SenderID,
+			"GroupID":  g.GroupID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, g.Tag)}}, nil
+	}
+
+	if g.Username == group.Owner {
+		// the owner can't leave their own group
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusWrongRequest, g.Tag)}}, nil
+	}
+
+	err = db.MySQLGroupRemoveMember(g.GroupID, g.Username)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, g.Tag)}}, err
+	}
+
+	projectIDs, err := db.MySQLGroupGetProjects(g.GroupID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServPartialFail, g.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, g.Tag)
+	not := messages.Notification{
+		Resource:   g.Resource,
+		Method:     g.Method,
+		ResourceID: g.GroupID,
+		Data: struct {
+			Username string
+		}{
+			Username: g.Username,
+		},
+	}.Wrap()
+
+	closures := []dhClosure{
+		toSenderClosure{msg: res},
+		toUserClosure(not, g.Username),
+	}
+	// Unsubscribe the member's live sockets from the projects they no longer have access to
+	for _, projectID := range projectIDs {
+		if _, err := db.MySQLUserProjectPermissionLookup(projectID, g.Username); err == nil {
+			continue
+		}
+		closures = append(closures, rabbitCommandClosure{
+			Command: "Unsubscribe",
+			Tag:     -1,
+			Key:     rabbitmq.RabbitUserQueueName(g.Username),
+			Data: rabbitmq.RabbitQueueData{
+				Key: rabbitmq.RabbitProjectQueueName(projectID),
+			},
+		})
+	}
+
+	return append(closures, auditClosure{entry: dbfs.AuditEntry{
+		Actor:  g.SenderID,
+		Action: "Group.RemoveMember",
+		Target: g.Username,
+		Detail: group.Name,
+	}}), nil
+}
+
+// Group.List
+type groupListRequest struct {
+	abstractRequest
+}
+
+func (g *groupListRequest) setAbstractRequest(req *abstractRequest) {
+	g.abstractRequest = *req
+}
+
+// groupListResult is a single group returned by Group.List
+type groupListResult struct {
+	GroupID int64
+	Name    string
+	Owner   string
+	Members []string
+}
+
+func (g groupListRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	groups, err := db.MySQLUserGroups(g.SenderID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, g.Tag)}}, err
+	}
+
+	results := make([]groupListResult, len(groups))
+	for i, group := range groups {
+		members, err := db.MySQLGroupGetMembers(group.GroupID)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, g.Tag)}}, err
+		}
+		results[i] = groupListResult{
+			GroupID: group.GroupID,
+			Name:    group.Name,
+			Owner:   group.Owner,
+			Members: members,
+		}
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    g.Tag,
+		Data: struct {
+			Groups []groupListResult
+		}{
+			Groups: results,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
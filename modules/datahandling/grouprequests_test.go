@@ -0,0 +1,142 @@
+package datahandling
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupCreateRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.GroupIDCounter = 1
+
+	req := *new(groupCreateRequest)
+	setBaseFields(&req)
+	req.Resource = "Group"
+	req.Method = "Create"
+	req.Name = " backend team "
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	groupID := reflect.ValueOf(resp.Data).FieldByName("GroupID").Interface().(int64)
+	assert.Equal(t, "backend team", db.Groups[groupID].Name)
+	assert.Equal(t, []string{"loganga"}, db.GroupMembers[groupID], "the creator should be the first member")
+
+	closures, _ = req.process(db)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "group names should be unique for each owner")
+}
+
+func TestGroupAddMemberRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.GroupIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	groupID, _ := db.MySQLGroupCreate("loganga", "backend team")
+	db.MySQLProjectGrantGroupPermission(projectID, groupID, config.PermissionsByLabel["write"], "loganga")
+
+	req := *new(groupAddMemberRequest)
+	setBaseFields(&req)
+	req.Resource = "Group"
+	req.Method = "AddMember"
+	req.GroupID = groupID
+	req.Username = "NotLoganga"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(closures), "unexpected number of returned closures")
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+
+	subscribe := closures[2].(rabbitCommandClosure)
+	assert.Equal(t, "Subscribe", subscribe.Command)
+	assert.Equal(t, rabbitmq.RabbitUserQueueName("notloganga"), subscribe.Key)
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(projectID), subscribe.Data.(rabbitmq.RabbitQueueData).Key)
+
+	level, err := db.MySQLUserProjectPermissionLookup(projectID, "notloganga")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["write"], level, "members should have the group's permissions")
+
+	// only the owner can add members
+	req.SenderID = "notloganga"
+	req.Username = "someone"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+}
+
+func TestGroupRemoveMemberRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.GroupIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	otherProjectID, _ := db.MySQLProjectCreate("loganga", "other stuff")
+	groupID, _ := db.MySQLGroupCreate("loganga", "backend team")
+	db.MySQLGroupAddMember(groupID, "notloganga", "loganga")
+	db.MySQLProjectGrantGroupPermission(projectID, groupID, config.PermissionsByLabel["write"], "loganga")
+	db.MySQLProjectGrantGroupPermission(otherProjectID, groupID, config.PermissionsByLabel["write"], "loganga")
+	db.MySQLProjectGrantPermission(otherProjectID, "notloganga", config.PermissionsByLabel["read"], "loganga")
+
+	req := *new(groupRemoveMemberRequest)
+	setBaseFields(&req)
+	req.Resource = "Group"
+	req.Method = "RemoveMember"
+	req.GroupID = groupID
+	req.Username = "loganga"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusWrongRequest, resp.Status, "the owner should not be able to leave their group")
+
+	// members can leave groups themselves
+	req.SenderID = "notloganga"
+	req.Username = "notloganga"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, 4, len(closures), "only projects without direct access should be unsubscribed")
+
+	unsubscribe := closures[2].(rabbitCommandClosure)
+	assert.Equal(t, "Unsubscribe", unsubscribe.Command)
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(projectID), unsubscribe.Data.(rabbitmq.RabbitQueueData).Key)
+
+	_, err = db.MySQLUserProjectPermissionLookup(projectID, "notloganga")
+	assert.Error(t, err, "removed members should lose the group's permissions")
+	level, err := db.MySQLUserProjectPermissionLookup(otherProjectID, "notloganga")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["read"], level, "direct permissions should be kept")
+}
+
+func TestGroupListRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.GroupIDCounter = 1
+	groupID, _ := db.MySQLGroupCreate("notloganga", "backend team")
+	db.MySQLGroupAddMember(groupID, "loganga", "notloganga")
+	db.MySQLGroupCreate("notloganga", "frontend team")
+
+	req := *new(groupListRequest)
+	setBaseFields(&req)
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	groups := reflect.ValueOf(resp.Data).FieldByName("Groups").Interface().([]groupListResult)
+	if assert.Len(t, groups, 1, "only groups the sender is a member of should be listed") {
+		assert.Equal(t, "backend team", groups[0].Name)
+		assert.Equal(t, []string{"notloganga", "loganga"}, groups[0].Members)
+	}
+}
@@ -0,0 +1,239 @@
+package datahandling
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+var inviteRequestsSetup = false
+
+// initInviteRequests populates the requestMap from requestmap.go with the appropriate constructors for the invite methods
+func initInviteRequests() {
+	if inviteRequestsSetup {
+		return
+	}
+
+	authenticatedRequestMap["Invite.Accept"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(inviteAcceptRequest), req)
+	}
+
+	authenticatedRequestMap["Invite.Decline"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(inviteDeclineRequest), req)
+	}
+
+	authenticatedRequestMap["Invite.Revoke"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(inviteRevokeRequest), req)
+	}
+
+	inviteRequestsSetup = true
+}
+
+// Invite.Accept
+type inviteAcceptRequest struct {
+	InviteID int64
+	abstractRequest
+}
+
+func (i *inviteAcceptRequest) setAbstractRequest(req *abstractRequest) {
+	i.abstractRequest = *req
+}
+
+func (i inviteAcceptRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return inviteProjectIDs(db, i.InviteID)
+}
+
+func (i inviteAcceptRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	invite, err := db.MySQLInviteGet(i.InviteID)
+	if err != nil || invite.Username != i.SenderID {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, i.Tag)}}, nil
+	}
+	if invite.ExpiryDate.Before(time.Now()) {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, i.Tag)}}, nil
+	}
+
+	// Permissions are only applied now, so make sure the inviter can still grant them
+	inviterRole, err := dbfs.UserRole(invite.InvitedBy, invite.ProjectID, db)
+	if err != nil || !inviterRole.Has(config.CapabilityManagePermissions) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  i.Resource,
+			"Method":    i.Method,
+			"SenderID":  i.SenderID,
+			"InvitedBy": invite.InvitedBy,
+			"ProjectID": invite.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, i.Tag)}}, nil
+	}
+	requestRole, err := dbfs.ProjectRole(invite.ProjectID, invite.PermissionLevel, db)
+	if err != nil || !canGrant(inviterRole, requestRole) {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, i.Tag)}}, nil
+	}
+
+	err = db.MySQLProjectGrantPermission(invite.ProjectID, i.SenderID, invite.PermissionLevel, invite.InvitedBy)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, i.Tag)}}, err
+	}
+
+	err = db.MySQLInviteDelete(i.InviteID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServPartialFail, i.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    i.Tag,
+		Data: struct {
+			ProjectID int64
+		}{
+			ProjectID: invite.ProjectID,
+		},
+	}.Wrap()
+	not := messages.Notification{
+		Resource:   "Project",
+		Method:     "GrantPermissions",
+		ResourceID: invite.ProjectID,
+		Data: struct {
+			GrantUsername   string
+			PermissionLevel int8
+		}{
+			GrantUsername:   i.SenderID,
+			PermissionLevel: invite.PermissionLevel,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(invite.ProjectID)},
+		// Subscribe the user's live sockets to the project they just joined
+		rabbitCommandClosure{
+			Command: "Subscribe",
+			Tag:     -1,
+			Key:     rabbitmq.RabbitUserQueueName(i.SenderID),
+			Data: rabbitmq.RabbitQueueData{
+				Key: rabbitmq.RabbitProjectQueueName(invite.ProjectID),
+			},
+		},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     i.SenderID,
+			Action:    "Invite.Accept",
+			ProjectID: invite.ProjectID,
+			Target:    i.SenderID,
+			Detail:    strconv.Itoa(int(invite.PermissionLevel)),
+		}},
+	}, nil
+}
+
+// Invite.Decline
+type inviteDeclineRequest struct {
+	InviteID int64
+	abstractRequest
+}
+
+func (i *inviteDeclineRequest) setAbstractRequest(req *abstractRequest) {
+	i.abstractRequest = *req
+}
+
+func (i inviteDeclineRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return inviteProjectIDs(db, i.InviteID)
+}
+
+func (i inviteDeclineRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	invite, err := db.MySQLInviteGet(i.InviteID)
+	if err != nil || invite.Username != i.SenderID {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, i.Tag)}}, nil
+	}
+
+	err = db.MySQLInviteDelete(i.InviteID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, i.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, i.Tag)
+	not := messages.Notification{
+		Resource:   i.Resource,
+		Method:     i.Method,
+		ResourceID: i.InviteID,
+		Data: struct {
+			ProjectID int64
+			Username  string
+		}{
+			ProjectID: invite.ProjectID,
+			Username:  i.SenderID,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		toUserClosure(not, invite.InvitedBy),
+	}, nil
+}
+
+// Invite.Revoke
+type inviteRevokeRequest struct {
+	InviteID int64
+	abstractRequest
+}
+
+func (i *inviteRevokeRequest) setAbstractRequest(req *abstractRequest) {
+	i.abstractRequest = *req
+}
+
+func (i inviteRevokeRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return inviteProjectIDs(db, i.InviteID)
+}
+
+func (i inviteRevokeRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	invite, err := db.MySQLInviteGet(i.InviteID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, i.Tag)}}, nil
+	}
+
+	// allow the inviter to withdraw their own invites, and anyone managing permissions to withdraw any invite
+	if invite.InvitedBy != i.SenderID {
+		senderRole, err := dbfs.UserRole(i.SenderID, invite.ProjectID, db)
+		if err != nil || !senderRole.Has(config.CapabilityManagePermissions) {
+			utils.LogError("API permission error", err, utils.LogFields{
+				"Resource":  i.Resource,
+				"Method":    i.Method,
+				"SenderID":  i.SenderID,
+				"ProjectID": invite.ProjectID,
+			})
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, i.Tag)}}, nil
+		}
+	}
+
+	err = db.MySQLInviteDelete(i.InviteID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, i.Tag)}}, err
+	}
+
+	closures := []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, i.Tag)}}
+	target := invite.Email
+	if invite.Username != "" {
+		target = invite.Username
+
+		not := messages.Notification{
+			Resource:   i.Resource,
+			Method:     i.Method,
+			ResourceID: i.InviteID,
+			Data: struct {
+				ProjectID int64
+			}{
+				ProjectID: invite.ProjectID,
+			},
+		}.Wrap()
+		closures = append(closures, toUserClosure(not, invite.Username))
+	}
+
+	return append(closures, auditClosure{entry: dbfs.AuditEntry{
+		Actor:     i.SenderID,
+		Action:    "Invite.Revoke",
+		ProjectID: invite.ProjectID,
+		Target:    target,
+	}}), nil
+}
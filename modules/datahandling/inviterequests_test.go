@@ -0,0 +1,154 @@
+package datahandling
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInviteAcceptRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.InviteIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("notloganga", "new stuff")
+	inviteID, _ := db.MySQLInviteCreate(dbfs.Invite{
+		ProjectID:       projectID,
+		Username:        "loganga",
+		PermissionLevel: config.PermissionsByLabel["write"],
+		InvitedBy:       "notloganga",
+	}, time.Hour)
+	expiredID, _ := db.MySQLInviteCreate(dbfs.Invite{
+		ProjectID:       projectID,
+		Username:        "loganga",
+		PermissionLevel: config.PermissionsByLabel["write"],
+		InvitedBy:       "notloganga",
+	}, -time.Hour)
+
+	req := *new(inviteAcceptRequest)
+	setBaseFields(&req)
+	req.Resource = "Invite"
+	req.Method = "Accept"
+	req.InviteID = expiredID
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "expired invites should not be accepted")
+
+	req.SenderID = "someone"
+	req.InviteID = inviteID
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNotFound, resp.Status, "users should not accept invites sent to others")
+
+	req.SenderID = "loganga"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, projectID, reflect.ValueOf(resp.Data).FieldByName("ProjectID").Interface().(int64))
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(projectID), closures[1].(toRabbitChannelClosure).key)
+	subscribe := closures[2].(rabbitCommandClosure)
+	assert.Equal(t, "Subscribe", subscribe.Command)
+	assert.Equal(t, rabbitmq.RabbitUserQueueName("loganga"), subscribe.Key)
+
+	level, err := db.MySQLUserProjectPermissionLookup(projectID, "loganga")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["write"], level, "the invited permissions should be applied")
+	_, ok := db.Invites[inviteID]
+	assert.False(t, ok, "accepted invites should be removed")
+}
+
+func TestInviteAcceptRequest_InviterLostAccess(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	projectID, _ := db.MySQLProjectCreate("owner", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "notloganga", config.PermissionsByLabel["admin"], "owner")
+	inviteID, _ := db.MySQLInviteCreate(dbfs.Invite{
+		ProjectID:       projectID,
+		Username:        "loganga",
+		PermissionLevel: config.PermissionsByLabel["write"],
+		InvitedBy:       "notloganga",
+	}, time.Hour)
+	db.MySQLProjectRevokePermission(projectID, "notloganga", "owner")
+
+	req := inviteAcceptRequest{InviteID: inviteID}
+	setBaseFields(&req)
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "invites from users who lost access should not be honoured")
+	_, err = db.MySQLUserProjectPermissionLookup(projectID, "loganga")
+	assert.Error(t, err)
+}
+
+func TestInviteDeclineRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	projectID, _ := db.MySQLProjectCreate("notloganga", "new stuff")
+	inviteID, _ := db.MySQLInviteCreate(dbfs.Invite{
+		ProjectID:       projectID,
+		Username:        "loganga",
+		PermissionLevel: config.PermissionsByLabel["read"],
+		InvitedBy:       "notloganga",
+	}, time.Hour)
+
+	req := inviteDeclineRequest{InviteID: inviteID}
+	setBaseFields(&req)
+	req.Resource = "Invite"
+	req.Method = "Decline"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, rabbitmq.RabbitUserQueueName("notloganga"), closures[1].(toRabbitChannelClosure).key,
+		"the inviter should be notified")
+	assert.Empty(t, db.Invites)
+	_, err = db.MySQLUserProjectPermissionLookup(projectID, "loganga")
+	assert.Error(t, err, "declined invites should not grant permissions")
+}
+
+func TestInviteRevokeRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "reader", config.PermissionsByLabel["read"], "loganga")
+	inviteID, _ := db.MySQLInviteCreate(dbfs.Invite{
+		ProjectID:       projectID,
+		Username:        "notloganga",
+		PermissionLevel: config.PermissionsByLabel["read"],
+		InvitedBy:       "loganga",
+	}, time.Hour)
+
+	req := inviteRevokeRequest{InviteID: inviteID}
+	setBaseFields(&req)
+	req.Resource = "Invite"
+	req.Method = "Revoke"
+	req.SenderID = "reader"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+
+	req.SenderID = "loganga"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, rabbitmq.RabbitUserQueueName("notloganga"), closures[1].(toRabbitChannelClosure).key,
+		"the invitee should be notified")
+	assert.Empty(t, db.Invites)
+}
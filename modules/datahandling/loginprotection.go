@@ -0,0 +1,182 @@
+package datahandling
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+/**
+ * Login protection slows down, and then locks out, usernames and IP addresses after repeated failed logins, so that
+ * passwords and two-factor codes cannot be guessed. Unknown usernames are counted like any other, so that responses
+ * do not reveal which users exist.
+ */
+
+// loginPolicy is a parsed config.LoginProtectionCfg
+type loginPolicy struct {
+	maxFailures   int
+	ipMaxFailures int
+	lockout       time.Duration
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	resetAfter    time.Duration
+}
+
+// loginProtection is the policy applied to logins; set by ConfigureLoginProtection
+var loginProtection = loginPolicy{
+	maxFailures:   config.DefaultLoginMaxFailures,
+	ipMaxFailures: config.DefaultLoginIPMaxFailures,
+	lockout:       config.DefaultLoginLockout,
+	backoff:       config.DefaultLoginBackoff,
+	maxBackoff:    config.DefaultLoginMaxBackoff,
+	resetAfter:    config.DefaultLoginResetAfter,
+}
+
+// ConfigureLoginProtection checks the login protection configuration, and sets the policy applied to logins
+func ConfigureLoginProtection(cfg config.LoginProtectionCfg) error {
+	policy := loginPolicy{
+		maxFailures:   cfg.MaxFailures,
+		ipMaxFailures: cfg.IPMaxFailures,
+	}
+	if policy.maxFailures == 0 {
+		policy.maxFailures = config.DefaultLoginMaxFailures
+	}
+	if policy.ipMaxFailures == 0 {
+		policy.ipMaxFailures = config.DefaultLoginIPMaxFailures
+	}
+	if policy.maxFailures < 0 || policy.ipMaxFailures < 0 {
+		return fmt.Errorf("MaxFailures and IPMaxFailures cannot be negative")
+	}
+
+	var err error
+	if policy.lockout, err = cfg.LockoutDuration(); err != nil {
+		return err
+	}
+	if policy.backoff, err = cfg.BackoffDuration(); err != nil {
+		return err
+	}
+	if policy.maxBackoff, err = cfg.MaxBackoffDuration(); err != nil {
+		return err
+	}
+	if policy.resetAfter, err = cfg.ResetAfterDuration(); err != nil {
+		return err
+	}
+
+	loginProtection = policy
+	return nil
+}
+
+// backoffAfter returns the delay required after the given number of consecutive failures
+func (p loginPolicy) backoffAfter(failures int) time.Duration {
+	delay := p.backoff
+	for i := 1; i < failures && delay < p.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.maxBackoff {
+		return p.maxBackoff
+	}
+	return delay
+}
+
+// loginRetryAfter returns how long must pass before the username can attempt to log in from the IP address, or 0 if
+// it can attempt to now
+func loginRetryAfter(db dbfs.DBFS, username string, ip string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	waitUntil := func(t time.Time) {
+		if t.After(now) && t.Sub(now) > wait {
+			wait = t.Sub(now)
+		}
+	}
+
+	userFailures, err := db.MySQLLoginFailuresGet(dbfs.LoginSubjectUser, username)
+	if err != nil && err != dbfs.ErrNoData {
+		return 0, err
+	}
+	waitUntil(userFailures.LockedUntil)
+	if userFailures.Failures > 0 && userFailures.LastFailure.After(now.Add(-loginProtection.resetAfter)) {
+		waitUntil(userFailures.LastFailure.Add(loginProtection.backoffAfter(userFailures.Failures)))
+	}
+
+	// addresses are only locked, not slowed down, since they may be shared by many users
+	if ip != "" {
+		ipFailures, err := db.MySQLLoginFailuresGet(dbfs.LoginSubjectIP, ip)
+		if err != nil && err != dbfs.ErrNoData {
+			return 0, err
+		}
+		waitUntil(ipFailures.LockedUntil)
+	}
+	return wait, nil
+}
+
+// loginBlocked returns the closures responding to a login attempt by the username which must wait, or nil if the
+// attempt may go ahead. The password is not checked while blocked, so that it cannot be guessed during a lockout.
+func loginBlocked(db dbfs.DBFS, abs abstractRequest, username string) ([]dhClosure, error) {
+	wait, err := loginRetryAfter(db, username, abs.remoteAddr)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, abs.Tag)}}, err
+	}
+	if wait > 0 {
+		return []dhClosure{toSenderClosure{msg: newThrottledResponse(abs.Tag, wait)}}, nil
+	}
+	return nil, nil
+}
+
+// recordLoginFailure counts a failed login for the username and the sender's IP address, locking them once they
+// reach their limits. It returns the closures auditing any lockouts; failures are logged rather than returned.
+func recordLoginFailure(db dbfs.DBFS, abs abstractRequest, username string) []dhClosure {
+	closures := countLoginFailure(db, username, dbfs.LoginSubjectUser, username, loginProtection.maxFailures)
+	if abs.remoteAddr != "" {
+		closures = append(closures, countLoginFailure(db, username, dbfs.LoginSubjectIP, abs.remoteAddr, loginProtection.ipMaxFailures)...)
+	}
+	return closures
+}
+
+func countLoginFailure(db dbfs.DBFS, username string, kind string, subject string, maxFailures int) []dhClosure {
+	failures, err := db.MySQLLoginFailureRecord(kind, subject, loginProtection.resetAfter)
+	if err != nil {
+		utils.LogError("Failed to record failed login", err, utils.LogFields{
+			"Kind":    kind,
+			"Subject": subject,
+		})
+		return nil
+	}
+	if failures < maxFailures {
+		return nil
+	}
+
+	err = db.MySQLLoginFailuresLock(kind, subject, loginProtection.lockout)
+	if err != nil {
+		utils.LogError("Failed to lock out login", err, utils.LogFields{
+			"Kind":    kind,
+			"Subject": subject,
+		})
+		return nil
+	}
+	utils.LogWarn("Locked out login after repeated failures", utils.LogFields{
+		"Kind":     kind,
+		"Subject":  subject,
+		"Failures": failures,
+	})
+	return []dhClosure{auditClosure{entry: dbfs.AuditEntry{
+		Actor:  username,
+		Action: "User.Lockout",
+		Target: subject,
+		Detail: fmt.Sprintf("%s locked for %s after %d failed logins", kind, loginProtection.lockout, failures),
+	}}}
+}
+
+// clearLoginFailures forgets the failed logins of a user who has logged in. Their IP address' failures are kept, so
+// that logging in to one account cannot be used to keep guessing the passwords of others.
+func clearLoginFailures(db dbfs.DBFS, username string) {
+	err := db.MySQLLoginFailuresClear(dbfs.LoginSubjectUser, username)
+	if err != nil && err != dbfs.ErrNoDbChange {
+		utils.LogError("Failed to clear failed logins", err, utils.LogFields{
+			"Username": username,
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package datahandling
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// disableLoginProtection stops failed logins from being slowed down or locked out, for tests that fail logins
+// repeatedly. The returned function restores the policy.
+func disableLoginProtection() func() {
+	old := loginProtection
+	loginProtection = loginPolicy{maxFailures: math.MaxInt32, ipMaxFailures: math.MaxInt32, resetAfter: time.Hour}
+	return func() {
+		loginProtection = old
+	}
+}
+
+func TestConfigureLoginProtection(t *testing.T) {
+	old := loginProtection
+	defer func() {
+		loginProtection = old
+	}()
+
+	assert.NoError(t, ConfigureLoginProtection(config.LoginProtectionCfg{}))
+	assert.Equal(t, config.DefaultLoginMaxFailures, loginProtection.maxFailures)
+	assert.Equal(t, config.DefaultLoginLockout, loginProtection.lockout)
+
+	assert.NoError(t, ConfigureLoginProtection(config.LoginProtectionCfg{MaxFailures: 3, Backoff: "2s"}))
+	assert.Equal(t, 3, loginProtection.maxFailures)
+	assert.Equal(t, 2*time.Second, loginProtection.backoff)
+
+	assert.Error(t, ConfigureLoginProtection(config.LoginProtectionCfg{Lockout: "forever"}))
+	assert.Error(t, ConfigureLoginProtection(config.LoginProtectionCfg{MaxFailures: -1}))
+}
+
+func TestLoginPolicy_BackoffAfter(t *testing.T) {
+	policy := loginPolicy{backoff: time.Second, maxBackoff: 10 * time.Second}
+	assert.Equal(t, time.Second, policy.backoffAfter(1))
+	assert.Equal(t, 2*time.Second, policy.backoffAfter(2))
+	assert.Equal(t, 8*time.Second, policy.backoffAfter(4))
+	assert.Equal(t, 10*time.Second, policy.backoffAfter(5))
+	assert.Equal(t, 10*time.Second, policy.backoffAfter(1000))
+}
+
+func TestUserLoginRequest_ProcessLockout(t *testing.T) {
+	configSetup(t)
+	old := loginProtection
+	defer func() {
+		loginProtection = old
+	}()
+	loginProtection = loginPolicy{maxFailures: 3, ipMaxFailures: 5, lockout: time.Hour, backoff: time.Hour, maxBackoff: time.Hour, resetAfter: time.Hour}
+
+	db := dbfs.NewDBMock()
+	hashed, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	db.Users["loganga"] = dbfs.UserMeta{Username: "loganga", Password: string(hashed)}
+	status := func(closures []dhClosure) int {
+		return closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status
+	}
+
+	login := userLoginRequest{Username: "loganga", Password: "wrong"}
+	setBaseFields(&login)
+	login.remoteAddr = "10.0.0.1"
+	closures, err := login.process(db)
+	assert.Equal(t, errInvalidCredentials, err)
+	assert.Equal(t, messages.StatusUnauthorized, status(closures))
+	assert.Equal(t, 1, db.LoginFailures["user:loganga"].Failures)
+	assert.Equal(t, 1, db.LoginFailures["ip:10.0.0.1"].Failures)
+
+	// backoff is enforced before the password is checked
+	login.Password = "correct horse"
+	closures, err = login.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusTooManyRequests, status(closures), "the correct password should wait out the backoff")
+	assert.Equal(t, 1, db.LoginFailures["user:loganga"].Failures)
+
+	// once the backoff has passed, a successful login clears the user's failures, but not the address'
+	failures := db.LoginFailures["user:loganga"]
+	failures.LastFailure = time.Now().Add(-2 * time.Hour)
+	db.LoginFailures["user:loganga"] = failures
+	closures, err = login.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	assert.NotContains(t, db.LoginFailures, "user:loganga")
+	assert.Contains(t, db.LoginFailures, "ip:10.0.0.1")
+
+	// reaching MaxFailures locks the user out, and audits it
+	login.Password = "wrong"
+	loginProtection.backoff = 0
+	for i := 1; i < loginProtection.maxFailures; i++ {
+		closures, err = login.process(db)
+		assert.Equal(t, errInvalidCredentials, err)
+		assert.Len(t, closures, 2)
+	}
+	closures, err = login.process(db)
+	assert.Equal(t, errInvalidCredentials, err)
+	if assert.Len(t, closures, 3) {
+		lockout := closures[2].(auditClosure).entry
+		assert.Equal(t, "User.Lockout", lockout.Action)
+		assert.Equal(t, "loganga", lockout.Target)
+	}
+	assert.True(t, db.LoginFailures["user:loganga"].LockedUntil.After(time.Now()))
+
+	login.Password = "correct horse"
+	closures, err = login.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusTooManyRequests, status(closures), "locked out users should not be able to log in")
+}
+
+func TestUserLoginRequest_ProcessUnknownUser(t *testing.T) {
+	configSetup(t)
+	old := loginProtection
+	defer func() {
+		loginProtection = old
+	}()
+	loginProtection = loginPolicy{maxFailures: 2, ipMaxFailures: 3, lockout: time.Hour, resetAfter: time.Hour}
+
+	db := dbfs.NewDBMock()
+	hashed, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	db.Users["loganga"] = dbfs.UserMeta{Username: "loganga", Password: string(hashed)}
+
+	// unknown users are answered, and counted, exactly like known ones
+	unknown := userLoginRequest{Username: "nobody", Password: "wrong"}
+	setBaseFields(&unknown)
+	unknown.remoteAddr = "10.0.0.2"
+	known := unknown
+	known.Username = "loganga"
+
+	unknownClosures, unknownErr := unknown.process(db)
+	knownClosures, knownErr := known.process(db)
+	assert.Equal(t, knownErr, unknownErr)
+	assert.Equal(t, knownClosures[0], unknownClosures[0])
+	assert.Equal(t, 1, db.LoginFailures["user:nobody"].Failures)
+
+	// the address is locked out once it reaches IPMaxFailures, whichever users it guesses at
+	other := unknown
+	other.Username = "someoneelse"
+	closures, err := other.process(db)
+	assert.Equal(t, errInvalidCredentials, err)
+	if assert.Len(t, closures, 3) {
+		assert.Equal(t, "10.0.0.2", closures[2].(auditClosure).entry.Target)
+	}
+
+	fresh := known
+	fresh.Password = "correct horse"
+	closures, err = fresh.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusTooManyRequests, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status)
+
+	fresh.remoteAddr = "10.0.0.3"
+	closures, err = fresh.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status,
+		"other addresses should not be locked out")
+}
+
+func TestUserUnlockRequest_Process(t *testing.T) {
+	configSetup(t)
+	cfg := config.GetConfig()
+	oldAdmins := cfg.ServerConfig.Administrators
+	defer func() {
+		cfg.ServerConfig.Administrators = oldAdmins
+	}()
+	cfg.ServerConfig.Administrators = []string{"Admin"}
+
+	db := dbfs.NewDBMock()
+	db.LoginFailures["user:loganga"] = dbfs.LoginFailures{LockedUntil: time.Now().Add(time.Hour)}
+	db.LoginFailures["ip:10.0.0.1"] = dbfs.LoginFailures{LockedUntil: time.Now().Add(time.Hour)}
+	status := func(closures []dhClosure) int {
+		return closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status
+	}
+
+	unlock := userUnlockRequest{Username: "LoganGa", IP: "10.0.0.1"}
+	setBaseFields(&unlock)
+	closures, err := unlock.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusUnauthorized, status(closures), "only administrators should be able to unlock users")
+	assert.Len(t, db.LoginFailures, 2)
+
+	unlock.SenderID = "admin"
+	closures, err = unlock.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	assert.Equal(t, dbfs.AuditEntry{Actor: "admin", Action: "User.Unlock", Target: "loganga", Detail: "10.0.0.1"},
+		closures[1].(auditClosure).entry)
+	assert.Empty(t, db.LoginFailures)
+
+	closures, err = unlock.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusNotFound, status(closures))
+}
@@ -62,6 +62,12 @@ const StatusNotFound int = 404
 // StatusVersionOutOfDate represents a state in which the client has an outdated version of the resource
 const StatusVersionOutOfDate int = 409 // (409 = conflict)
 
+// StatusNameConflict represents a request that failed because the name it would use is already taken
+const StatusNameConflict int = 422
+
+// StatusTooManyRequests represents a request that was rejected because the sender exceeded their rate limit
+const StatusTooManyRequests int = 429
+
 // StatusPartialFail represents a partial failure in processing the request
 const StatusPartialFail int = 499
 
@@ -0,0 +1,59 @@
+package datahandling
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/metrics"
+)
+
+// metricsHandler wraps a DataHandler, recording the count and latency of the requests it handles.
+// Releasing locks is passed through to the wrapped DataHandler.
+type metricsHandler struct {
+	DataHandler
+}
+
+// WithMetrics wraps the given DataHandler, instrumenting it with metrics
+func WithMetrics(dh DataHandler) Handler {
+	return metricsHandler{DataHandler: dh}
+}
+
+// Handle handles the message using the wrapped DataHandler, recording how long the request took, and the status
+// of its response
+func (m metricsHandler) Handle(messageType int, message []byte, wg *sync.WaitGroup) error {
+	defer wg.Done()
+	start := time.Now()
+
+	req, closures, err := m.DataHandler.handle(message)
+	if req != nil {
+		recordRequestMetrics(req, closures, time.Since(start))
+	}
+	return err
+}
+
+// recordRequestMetrics records the count and latency of a request, labelled by the Resource.Method,
+// and the status of the response sent back to the sender.
+func recordRequestMetrics(req *abstractRequest, closures []dhClosure, duration time.Duration) {
+	method := req.Resource + "." + req.Method
+	if _, authenticated := authenticatedRequestMap[method]; !authenticated {
+		if _, unauthenticated := unauthenticatedRequestMap[method]; !unauthenticated {
+			// Do not create a label for every unknown method a client sends
+			method = "Unknown"
+		}
+	}
+
+	status := "None"
+	for _, closure := range closures {
+		if toSender, ok := closure.(toSenderClosure); ok {
+			if response, ok := toSender.msg.ServerMessage.(messages.Response); ok {
+				status = strconv.Itoa(response.Status)
+				break
+			}
+		}
+	}
+
+	metrics.RequestsTotal.Inc(method, status)
+	metrics.RequestDuration.Observe(duration.Seconds(), method, status)
+}
@@ -0,0 +1,43 @@
+package datahandling
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/metrics"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMetrics_Handle(t *testing.T) {
+	configSetup(t)
+	messageChan := make(chan rabbitmq.AMQPMessage, 1)
+	dh := DataHandler{
+		MessageChan: messageChan,
+		WebsocketID: 1,
+		Db:          dbfs.NewDBMock(),
+	}
+	request := `{"Tag": 1, "Resource": "Project", "Method": "GetPermissionConstants", "SenderID": "loganga", "SenderToken": "` +
+		testToken(t, "loganga") + `", "Data": {}}`
+	success := strconv.Itoa(messages.StatusSuccess)
+	count := metrics.RequestsTotal.Value("Project.GetPermissionConstants", success)
+	observed := metrics.RequestDuration.Count("Project.GetPermissionConstants", success)
+
+	assert.Equal(t, messages.StatusSuccess, handleAndGetStatus(t, dh, messageChan, request))
+	assert.Equal(t, count, metrics.RequestsTotal.Value("Project.GetPermissionConstants", success),
+		"Requests should only be recorded by the metrics wrapper")
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	assert.NoError(t, WithMetrics(dh).Handle(1, []byte(request), wg))
+	assert.Equal(t, 1, len(messageChan), "Requests should be passed through to the wrapped DataHandler")
+	assert.Equal(t, count+1, metrics.RequestsTotal.Value("Project.GetPermissionConstants", success))
+	assert.Equal(t, observed+1, metrics.RequestDuration.Count("Project.GetPermissionConstants", success))
+
+	wg.Add(1)
+	assert.Error(t, WithMetrics(dh).Handle(1, []byte("not json"), wg))
+	assert.Equal(t, count+1, metrics.RequestsTotal.Value("Project.GetPermissionConstants", success))
+}
@@ -1,6 +1,12 @@
 package datahandling
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
 	"time"
 
 	"strings"
@@ -9,6 +15,7 @@ import (
 	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
 	"github.com/CodeCollaborate/Server/modules/dbfs"
 	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/modules/webhooks"
 	"github.com/CodeCollaborate/Server/utils"
 )
 
@@ -42,6 +49,38 @@ func initProjectRequests() {
 		return commonJSON(new(projectRevokePermissionsRequest), req)
 	}
 
+	authenticatedRequestMap["Project.Invite"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectInviteRequest), req)
+	}
+
+	authenticatedRequestMap["Project.TransferOwnership"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectTransferOwnershipRequest), req)
+	}
+
+	authenticatedRequestMap["Project.AcceptOwnership"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectAcceptOwnershipRequest), req)
+	}
+
+	authenticatedRequestMap["Project.DeclineOwnership"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectDeclineOwnershipRequest), req)
+	}
+
+	authenticatedRequestMap["Project.CreateShareLink"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectCreateShareLinkRequest), req)
+	}
+
+	authenticatedRequestMap["Project.RevokeShareLink"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectRevokeShareLinkRequest), req)
+	}
+
+	authenticatedRequestMap["Project.GetShareLinks"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectGetShareLinksRequest), req)
+	}
+
+	unauthenticatedRequestMap["Project.RedeemShareLink"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectRedeemShareLinkRequest), req)
+	}
+
 	authenticatedRequestMap["Project.GetOnlineClients"] = func(req *abstractRequest) (request, error) {
 		return commonJSON(new(projectGetOnlineClientsRequest), req)
 	}
@@ -66,6 +105,46 @@ func initProjectRequests() {
 		return commonJSON(new(projectDeleteRequest), req)
 	}
 
+	authenticatedRequestMap["Project.Restore"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectRestoreRequest), req)
+	}
+
+	authenticatedRequestMap["Project.ListTrash"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectListTrashRequest), req)
+	}
+
+	authenticatedRequestMap["Project.GetAuditLog"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectGetAuditLogRequest), req)
+	}
+
+	authenticatedRequestMap["Project.SetRole"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectSetRoleRequest), req)
+	}
+
+	authenticatedRequestMap["Project.DeleteRole"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectDeleteRoleRequest), req)
+	}
+
+	authenticatedRequestMap["Project.GetPathPermissions"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectGetPathPermissionsRequest), req)
+	}
+
+	authenticatedRequestMap["Project.SetPathPermissions"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectSetPathPermissionsRequest), req)
+	}
+
+	authenticatedRequestMap["Project.AddWebhook"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectAddWebhookRequest), req)
+	}
+
+	authenticatedRequestMap["Project.ListWebhooks"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectListWebhooksRequest), req)
+	}
+
+	authenticatedRequestMap["Project.RemoveWebhook"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(projectRemoveWebhookRequest), req)
+	}
+
 	projectRequestsSetup = true
 }
 
@@ -80,6 +159,21 @@ func (p *projectCreateRequest) setAbstractRequest(req *abstractRequest) {
 }
 
 func (p projectCreateRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	if unverifiedBlocked(config.UnverifiedBlockProjects) {
+		unverified, err := isUnverified(db, p.SenderID)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+		}
+		if unverified {
+			utils.LogError("API permission error", nil, utils.LogFields{
+				"Resource": p.Resource,
+				"Method":   p.Method,
+				"SenderID": p.SenderID,
+			})
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+		}
+	}
+
 	projectID, err := db.MySQLProjectCreate(p.SenderID, p.Name)
 	if err != nil {
 		//if err == project already exists {
@@ -112,8 +206,12 @@ func (p *projectRenameRequest) setAbstractRequest(req *abstractRequest) {
 	p.abstractRequest = *req
 }
 
+func (p projectRenameRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
 func (p projectRenameRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
-	hasPermission, err := dbfs.PermissionAtLeast(p.SenderID, p.ProjectID, "write", db)
+	hasPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityRenameProject, db)
 	if err != nil || !hasPermission {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  p.Resource,
@@ -141,11 +239,17 @@ func (p projectRenameRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		},
 	}.Wrap()
 
-	return []dhClosure{toSenderClosure{msg: res}, toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)}}, nil
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)},
+		auditClosure{entry: dbfs.AuditEntry{Actor: p.SenderID, Action: "Project.Rename", ProjectID: p.ProjectID, Detail: p.NewName}},
+	}, nil
 }
 
 // Project.GetPermissionConstants
 type projectGetPermissionConstantsRequest struct {
+	// ProjectID optionally requests the project's custom roles as well
+	ProjectID int64
 	abstractRequest
 }
 
@@ -153,31 +257,72 @@ func (p *projectGetPermissionConstantsRequest) setAbstractRequest(req *abstractR
 	p.abstractRequest = *req
 }
 
+func (p projectGetPermissionConstantsRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
 func (p projectGetPermissionConstantsRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	roles := config.Roles()
+	if p.ProjectID != 0 {
+		hasPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityRead, db)
+		if err != nil || !hasPermission {
+			utils.LogError("API permission error", err, utils.LogFields{
+				"Resource":  p.Resource,
+				"Method":    p.Method,
+				"SenderID":  p.SenderID,
+				"ProjectID": p.ProjectID,
+			})
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+		}
+
+		projectRoles, err := db.MySQLProjectGetRoles(p.ProjectID)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+		}
+		roles = append(append([]config.Role{}, roles...), projectRoles...)
+	}
+
 	res := messages.Response{
 		Status: messages.StatusSuccess,
 		Tag:    p.Tag,
 		Data: struct {
-			Constants map[string]int8
+			Constants    map[string]int8
+			Roles        []config.Role
+			Capabilities []string
 		}{
-			Constants: config.PermissionsByLabel,
+			Constants:    config.PermissionsByLabel,
+			Roles:        roles,
+			Capabilities: config.Capabilities,
 		},
 	}.Wrap()
 
 	return []dhClosure{toSenderClosure{msg: res}}, nil
 }
 
+// canGrant returns true if a user holding granterRole may grant role to others, which is the case if the role
+// has no capabilities that the granter does not hold
+func canGrant(granterRole config.Role, role config.Role) bool {
+	for _, capability := range role.Capabilities {
+		if !granterRole.Has(capability) {
+			return false
+		}
+	}
+	return true
+}
+
 // Project.GrantPermissions
 type projectGrantPermissionsRequest struct {
-	ProjectID       int64
-	GrantUsername   string
+	ProjectID     int64
+	GrantUsername string
+	// GrantGroupID is set instead of GrantUsername to grant the permission to every member of a group
+	GrantGroupID    int64
 	PermissionLevel int8
 	abstractRequest
 }
 
 func (p projectGrantPermissionsRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
-	hasPermission, err := dbfs.PermissionAtLeast(p.SenderID, p.ProjectID, "admin", db)
-	if err != nil || !hasPermission {
+	senderRole, err := dbfs.UserRole(p.SenderID, p.ProjectID, db)
+	if err != nil || !senderRole.Has(config.CapabilityManagePermissions) {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  p.Resource,
 			"Method":    p.Method,
@@ -196,19 +341,23 @@ func (p projectGrantPermissionsRequest) process(db dbfs.DBFS) ([]dhClosure, erro
 
 	// TODO: Add if User exists check
 
-	requestPerm, err := config.PermissionByLevel(p.PermissionLevel)
+	requestRole, err := dbfs.ProjectRole(p.ProjectID, p.PermissionLevel, db)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
 	}
 
-	ownerPerm, err := config.PermissionByLabel("owner")
-	if err != nil {
-		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, nil
+	if requestRole.Level == config.OwnerLevel {
+		// ownership is changed through Project.TransferOwnership
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusWrongRequest, p.Tag)}}, nil
+	}
+
+	// Prevent users from granting capabilities they do not hold themselves
+	if !canGrant(senderRole, requestRole) {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
 	}
 
-	if requestPerm.Level == ownerPerm.Level {
-		// TODO(shapiro): implement changing ownership
-		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnimplemented, p.Tag)}}, nil
+	if p.GrantGroupID != 0 {
+		return p.grantGroup(db)
 	}
 
 	err = db.MySQLProjectGrantPermission(p.ProjectID, p.GrantUsername, p.PermissionLevel, p.SenderID)
@@ -233,22 +382,89 @@ func (p projectGrantPermissionsRequest) process(db dbfs.DBFS) ([]dhClosure, erro
 	return []dhClosure{
 		toSenderClosure{msg: res},
 		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)},
-		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitUserQueueName(p.GrantUsername)}}, nil
+		toUserClosure(not, p.GrantUsername),
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     p.SenderID,
+			Action:    "Project.GrantPermissions",
+			ProjectID: p.ProjectID,
+			Target:    p.GrantUsername,
+			Detail:    strconv.Itoa(int(p.PermissionLevel)),
+		}},
+	}, nil
 }
 
 func (p *projectGrantPermissionsRequest) setAbstractRequest(req *abstractRequest) {
 	p.abstractRequest = *req
 }
 
+func (p projectGrantPermissionsRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// grantGroup grants the permission to the members of the group, notifying each of them
+func (p projectGrantPermissionsRequest) grantGroup(db dbfs.DBFS) ([]dhClosure, error) {
+	// Users may only grant access to groups they are a member of
+	group, err := db.MySQLGroupGetInfo(p.GrantGroupID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, p.Tag)}}, nil
+	}
+	isMember, err := isGroupMember(group.GroupID, p.SenderID, db)
+	if err != nil || !isMember {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	err = db.MySQLProjectGrantGroupPermission(p.ProjectID, group.GroupID, p.PermissionLevel, p.SenderID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+
+	members, err := db.MySQLGroupGetMembers(group.GroupID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServPartialFail, p.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)
+	not := messages.Notification{
+		Resource:   p.Resource,
+		Method:     p.Method,
+		ResourceID: p.ProjectID,
+		Data: struct {
+			GrantGroupID    int64
+			PermissionLevel int8
+		}{
+			GrantGroupID:    group.GroupID,
+			PermissionLevel: p.PermissionLevel,
+		},
+	}.Wrap()
+
+	closures := []dhClosure{
+		toSenderClosure{msg: res},
+		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)},
+	}
+	for _, member := range members {
+		closures = append(closures, toUserClosure(not, member))
+	}
+
+	return append(closures, auditClosure{entry: dbfs.AuditEntry{
+		Actor:     p.SenderID,
+		Action:    "Project.GrantPermissions",
+		ProjectID: p.ProjectID,
+		Target:    "group:" + group.Name,
+		Detail:    strconv.Itoa(int(p.PermissionLevel)),
+	}}), nil
+}
+
 // Project.RevokePermissions
 type projectRevokePermissionsRequest struct {
 	ProjectID      int64
 	RevokeUsername string
+	// RevokeGroupID is set instead of RevokeUsername to revoke the permissions of a group
+	RevokeGroupID int64
 	abstractRequest
 }
 
 func (p projectRevokePermissionsRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
-	hasPermission, err := dbfs.PermissionAtLeast(p.SenderID, p.ProjectID, "admin", db)
+	hasPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityManagePermissions, db)
 	if err != nil {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  p.Resource,
@@ -259,6 +475,13 @@ func (p projectRevokePermissionsRequest) process(db dbfs.DBFS) ([]dhClosure, err
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
 	}
 
+	if p.RevokeGroupID != 0 {
+		if !hasPermission {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+		}
+		return p.revokeGroup(db)
+	}
+
 	p.RevokeUsername = strings.ToLower(p.RevokeUsername)
 
 	// allow case where user is removing themselves from a project
@@ -306,168 +529,1602 @@ func (p projectRevokePermissionsRequest) process(db dbfs.DBFS) ([]dhClosure, err
 		},
 	}.Wrap()
 
-	unsubscribeCommand := rabbitCommandClosure{
-		Command: "Unsubscribe",
-		Tag:     -1,
-		Key:     rabbitmq.RabbitUserQueueName(p.RevokeUsername),
-		Data: rabbitmq.RabbitQueueData{
-			Key: rabbitmq.RabbitProjectQueueName(p.ProjectID),
-		},
-	}
-
-	return []dhClosure{
+	closures := []dhClosure{
 		toSenderClosure{msg: res},
 		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)},
-		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitUserQueueName(p.RevokeUsername)},
-		unsubscribeCommand}, nil
+		toUserClosure(not, p.RevokeUsername),
+	}
+
+	// users may still have access through one of their groups
+	if _, err := db.MySQLUserProjectPermissionLookup(p.ProjectID, p.RevokeUsername); err != nil {
+		closures = append(closures, rabbitCommandClosure{
+			Command: "Unsubscribe",
+			Tag:     -1,
+			Key:     rabbitmq.RabbitUserQueueName(p.RevokeUsername),
+			Data: rabbitmq.RabbitQueueData{
+				Key: rabbitmq.RabbitProjectQueueName(p.ProjectID),
+			},
+		})
+	}
+
+	return append(closures, auditClosure{entry: dbfs.AuditEntry{
+		Actor:     p.SenderID,
+		Action:    "Project.RevokePermissions",
+		ProjectID: p.ProjectID,
+		Target:    p.RevokeUsername,
+	}}), nil
 }
 
 func (p *projectRevokePermissionsRequest) setAbstractRequest(req *abstractRequest) {
 	p.abstractRequest = *req
 }
 
-// Project.GetOnlineClients
-type projectGetOnlineClientsRequest struct {
-	ProjectID int64
-	abstractRequest
+func (p projectRevokePermissionsRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
 }
 
-func (p projectGetOnlineClientsRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
-	// TODO: implement on redis (and actually implement redis)
-	utils.LogWarn("ProjectGetOnlineClients not implemented", nil)
+// revokeGroup revokes the permissions of the group, unsubscribing the members that no longer have access
+func (p projectRevokePermissionsRequest) revokeGroup(db dbfs.DBFS) ([]dhClosure, error) {
+	group, err := db.MySQLGroupGetInfo(p.RevokeGroupID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, p.Tag)}}, nil
+	}
 
-	return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnimplemented, p.Tag)}}, nil
-}
+	err = db.MySQLProjectRevokeGroupPermission(p.ProjectID, group.GroupID, p.SenderID)
+	if err != nil {
+		if err == dbfs.ErrNoDbChange {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+		}
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
 
-func (p *projectGetOnlineClientsRequest) setAbstractRequest(req *abstractRequest) {
-	p.abstractRequest = *req
+	members, err := db.MySQLGroupGetMembers(group.GroupID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServPartialFail, p.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)
+	not := messages.Notification{
+		Resource:   p.Resource,
+		Method:     p.Method,
+		ResourceID: p.ProjectID,
+		Data: struct {
+			RevokeGroupID int64
+		}{
+			RevokeGroupID: group.GroupID,
+		},
+	}.Wrap()
+
+	closures := []dhClosure{
+		toSenderClosure{msg: res},
+		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)},
+	}
+	for _, member := range members {
+		if _, err := db.MySQLUserProjectPermissionLookup(p.ProjectID, member); err == nil {
+			continue
+		}
+		closures = append(closures,
+			toUserClosure(not, member),
+			rabbitCommandClosure{
+				Command: "Unsubscribe",
+				Tag:     -1,
+				Key:     rabbitmq.RabbitUserQueueName(member),
+				Data: rabbitmq.RabbitQueueData{
+					Key: rabbitmq.RabbitProjectQueueName(p.ProjectID),
+				},
+			})
+	}
+
+	return append(closures, auditClosure{entry: dbfs.AuditEntry{
+		Actor:     p.SenderID,
+		Action:    "Project.RevokePermissions",
+		ProjectID: p.ProjectID,
+		Target:    "group:" + group.Name,
+	}}), nil
 }
 
-// Project.Lookup
-type projectLookupRequest struct {
-	ProjectIDs []int64
+// Project.Invite
+type projectInviteRequest struct {
+	ProjectID int64
+	// Either Username or Email is set. Invites to an email address are bound to the account registered with it.
+	Username        string
+	Email           string
+	PermissionLevel int8
+	// ExpiryDays is the number of days the invite is valid for; defaults to defaultInviteExpiryDays
+	ExpiryDays int
 	abstractRequest
 }
 
-// this request returns a slice of results for the projects we found, so we need the object that goes in that slice
-type projectLookupResult struct {
-	ProjectID   int64
-	Name        string
-	Permissions map[string](dbfs.ProjectPermission)
-}
+const defaultInviteExpiryDays = 7
+const maxInviteExpiryDays = 30
 
-func (p projectLookupRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
-	/*
-		We could do
-			data := make([]interface{}, len(p.ProjectIDs))
-		but it seems like poor practice and makes the object oriented side of my brain cry
-	*/
-	resultData := make([]projectLookupResult, len(p.ProjectIDs))
+func (p projectInviteRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	senderRole, err := dbfs.UserRole(p.SenderID, p.ProjectID, db)
+	if err != nil || !senderRole.Has(config.CapabilityManagePermissions) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
 
-	var errOut error
-	i := 0
-	for _, id := range p.ProjectIDs {
-		// it's better to do a cheap lookup and then an expensive one if required than an expensive one every time
-		hasPermission, err := dbfs.PermissionAtLeast(p.SenderID, id, "read", db)
-		if err != nil || !hasPermission {
-			utils.LogError("API permission error", err, utils.LogFields{
-				"Resource":  p.Resource,
-				"Method":    p.Method,
-				"SenderID":  p.SenderID,
-				"ProjectID": id,
-			})
-			errOut = ErrAuthenticationFailed
-			continue
-		}
+	p.Username = strings.ToLower(p.Username)
+	p.Email = strings.TrimSpace(p.Email)
+	if (p.Username == "") == (p.Email == "") {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+	}
+	if p.Username == p.SenderID {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
 
-		lookupResult, err := projectLookup(p.SenderID, id, db)
-		if err != nil {
-			errOut = err
-		} else {
-			resultData[i] = lookupResult
-			i++
-		}
+	if p.ExpiryDays == 0 {
+		p.ExpiryDays = defaultInviteExpiryDays
+	}
+	if p.ExpiryDays < 0 || p.ExpiryDays > maxInviteExpiryDays {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
 	}
-	// shrink to cut off remainder left by errors
-	resultData = resultData[:i]
 
-	if errOut != nil {
-		if len(resultData) == 0 {
-			res := messages.Response{
-				Status: messages.StatusFail,
-				Tag:    p.Tag,
-				Data: struct {
-					Projects []projectLookupResult
-				}{
-					Projects: resultData,
-				},
-			}.Wrap()
-			return []dhClosure{toSenderClosure{msg: res}}, nil
+	requestRole, err := dbfs.ProjectRole(p.ProjectID, p.PermissionLevel, db)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+	}
+	if requestRole.Level == config.OwnerLevel {
+		// ownership is changed through Project.TransferOwnership
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusWrongRequest, p.Tag)}}, nil
+	}
+
+	// Prevent users from granting capabilities they do not hold themselves
+	if !canGrant(senderRole, requestRole) {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	// Existing members should have their permissions changed instead
+	if p.Username != "" {
+		if _, err := db.MySQLUserProjectPermissionLookup(p.ProjectID, p.Username); err == nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
 		}
-		res := messages.Response{
-			Status: messages.StatusPartialFail,
-			Tag:    p.Tag,
-			Data: struct {
-				Projects []projectLookupResult
-			}{
-				Projects: resultData,
-			},
-		}.Wrap()
-		return []dhClosure{toSenderClosure{msg: res}}, nil
+	}
+
+	inviteID, err := db.MySQLInviteCreate(dbfs.Invite{
+		ProjectID:       p.ProjectID,
+		Username:        p.Username,
+		Email:           p.Email,
+		PermissionLevel: p.PermissionLevel,
+		InvitedBy:       p.SenderID,
+	}, time.Duration(p.ExpiryDays)*24*time.Hour)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
 	}
 
 	res := messages.Response{
 		Status: messages.StatusSuccess,
 		Tag:    p.Tag,
 		Data: struct {
-			Projects []projectLookupResult
+			InviteID int64
 		}{
-			Projects: resultData,
+			InviteID: inviteID,
 		},
 	}.Wrap()
 
-	return []dhClosure{toSenderClosure{msg: res}}, nil
+	closures := []dhClosure{toSenderClosure{msg: res}}
+	target := p.Email
+	if p.Username != "" {
+		target = p.Username
+
+		invite, err := db.MySQLInviteGet(inviteID)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServPartialFail, p.Tag)}}, err
+		}
+		not := messages.Notification{
+			Resource:   p.Resource,
+			Method:     p.Method,
+			ResourceID: p.ProjectID,
+			Data: struct {
+				Invite dbfs.Invite
+			}{
+				Invite: invite,
+			},
+		}.Wrap()
+		closures = append(closures, toUserClosure(not, p.Username))
+	}
+
+	return append(closures, auditClosure{entry: dbfs.AuditEntry{
+		Actor:     p.SenderID,
+		Action:    "Project.Invite",
+		ProjectID: p.ProjectID,
+		Target:    target,
+		Detail:    strconv.Itoa(int(p.PermissionLevel)),
+	}}), nil
+}
+
+func (p *projectInviteRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectInviteRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.TransferOwnership
+type projectTransferOwnershipRequest struct {
+	ProjectID int64
+	NewOwner  string
+	// FormerOwnerLevel is the permission level the sender keeps once the new owner accepts the transfer
+	FormerOwnerLevel int8
+	abstractRequest
+}
+
+func (p projectTransferOwnershipRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	senderRole, err := dbfs.UserRole(p.SenderID, p.ProjectID, db)
+	if err != nil || senderRole.Level != config.OwnerLevel {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	p.NewOwner = strings.ToLower(p.NewOwner)
+	if p.NewOwner == "" || p.NewOwner == p.SenderID {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+	}
+
+	formerOwnerRole, err := dbfs.ProjectRole(p.ProjectID, p.FormerOwnerLevel, db)
+	if err != nil || formerOwnerRole.Level == config.OwnerLevel {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+	}
+
+	err = db.MySQLProjectRequestTransfer(dbfs.OwnershipTransfer{
+		ProjectID:        p.ProjectID,
+		FormerOwner:      p.SenderID,
+		NewOwner:         p.NewOwner,
+		FormerOwnerLevel: p.FormerOwnerLevel,
+	})
+	if err != nil {
+		// the new owner must be a registered user
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, err
+	}
+
+	transfer, err := db.MySQLProjectGetTransfer(p.ProjectID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServPartialFail, p.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)
+	not := messages.Notification{
+		Resource:   p.Resource,
+		Method:     p.Method,
+		ResourceID: p.ProjectID,
+		Data: struct {
+			Transfer dbfs.OwnershipTransfer
+		}{
+			Transfer: transfer,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		toUserClosure(not, p.NewOwner),
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     p.SenderID,
+			Action:    "Project.TransferOwnership",
+			ProjectID: p.ProjectID,
+			Target:    p.NewOwner,
+			Detail:    strconv.Itoa(int(p.FormerOwnerLevel)),
+		}},
+	}, nil
+}
+
+func (p *projectTransferOwnershipRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectTransferOwnershipRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.AcceptOwnership
+type projectAcceptOwnershipRequest struct {
+	ProjectID int64
+	// NewName renames the project as it is transferred, for when the new owner already owns a project with its name
+	NewName string
+	abstractRequest
+}
+
+func (p projectAcceptOwnershipRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	transfer, err := db.MySQLProjectGetTransfer(p.ProjectID)
+	if err != nil || transfer.NewOwner != p.SenderID {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, p.Tag)}}, nil
+	}
+
+	name := strings.TrimSpace(p.NewName)
+	if name == "" {
+		name = transfer.ProjectName
+	}
+
+	// the former owner's role may have been deleted since the transfer was requested
+	if _, err := dbfs.ProjectRole(p.ProjectID, transfer.FormerOwnerLevel, db); err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+	}
+
+	err = db.MySQLProjectTransferOwnership(p.ProjectID, name)
+	if err != nil {
+		if err == dbfs.ErrNameConflict {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNameConflict, p.Tag)}}, nil
+		}
+		if err == dbfs.ErrNoDbChange {
+			// the project changed hands since the transfer was requested
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, err
+		}
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)
+	not := messages.Notification{
+		Resource:   p.Resource,
+		Method:     p.Method,
+		ResourceID: p.ProjectID,
+		Data: struct {
+			Name             string
+			Owner            string
+			FormerOwner      string
+			FormerOwnerLevel int8
+		}{
+			Name:             name,
+			Owner:            p.SenderID,
+			FormerOwner:      transfer.FormerOwner,
+			FormerOwnerLevel: transfer.FormerOwnerLevel,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)},
+		// the new owner may not have been a member of the project before
+		rabbitCommandClosure{
+			Command: "Subscribe",
+			Tag:     -1,
+			Key:     rabbitmq.RabbitUserQueueName(p.SenderID),
+			Data: rabbitmq.RabbitQueueData{
+				Key: rabbitmq.RabbitProjectQueueName(p.ProjectID),
+			},
+		},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     p.SenderID,
+			Action:    "Project.AcceptOwnership",
+			ProjectID: p.ProjectID,
+			Target:    transfer.FormerOwner,
+			Detail:    strconv.Itoa(int(transfer.FormerOwnerLevel)),
+		}},
+	}, nil
+}
+
+func (p *projectAcceptOwnershipRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectAcceptOwnershipRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.DeclineOwnership
+type projectDeclineOwnershipRequest struct {
+	ProjectID int64
+	abstractRequest
+}
+
+func (p projectDeclineOwnershipRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	// allow the owner to withdraw a transfer they requested
+	transfer, err := db.MySQLProjectGetTransfer(p.ProjectID)
+	if err != nil || (transfer.NewOwner != p.SenderID && transfer.FormerOwner != p.SenderID) {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, p.Tag)}}, nil
+	}
+
+	err = db.MySQLProjectCancelTransfer(p.ProjectID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, err
+	}
+
+	otherUser := transfer.FormerOwner
+	if p.SenderID == transfer.FormerOwner {
+		otherUser = transfer.NewOwner
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)
+	not := messages.Notification{
+		Resource:   p.Resource,
+		Method:     p.Method,
+		ResourceID: p.ProjectID,
+		Data: struct {
+			Username string
+		}{
+			Username: p.SenderID,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		toUserClosure(not, otherUser),
+	}, nil
+}
+
+func (p *projectDeclineOwnershipRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectDeclineOwnershipRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.CreateShareLink
+type projectCreateShareLinkRequest struct {
+	ProjectID int64
+	// ExpiryDays is the number of days the link is valid for; defaults to defaultShareLinkExpiryDays
+	ExpiryDays int
+	// MaxUses is the number of times the link can be redeemed; 0 is unlimited
+	MaxUses int
+	abstractRequest
+}
+
+const defaultShareLinkExpiryDays = 7
+const maxShareLinkExpiryDays = 30
+
+func (p projectCreateShareLinkRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	hasPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityManagePermissions, db)
+	if err != nil || !hasPermission {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	if p.ExpiryDays == 0 {
+		p.ExpiryDays = defaultShareLinkExpiryDays
+	}
+	if p.ExpiryDays < 0 || p.ExpiryDays > maxShareLinkExpiryDays || p.MaxUses < 0 {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+
+	shareLinkID, err := db.MySQLShareLinkCreate(dbfs.ShareLink{
+		ProjectID: p.ProjectID,
+		CreatedBy: p.SenderID,
+		MaxUses:   p.MaxUses,
+	}, hashSecretToken(token), time.Duration(p.ExpiryDays)*24*time.Hour)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    p.Tag,
+		Data: struct {
+			ShareLinkID int64
+			Token       string
+		}{
+			ShareLinkID: shareLinkID,
+			Token:       token,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     p.SenderID,
+			Action:    "Project.CreateShareLink",
+			ProjectID: p.ProjectID,
+			Target:    strconv.FormatInt(shareLinkID, 10),
+			Detail:    strconv.Itoa(p.MaxUses),
+		}},
+	}, nil
+}
+
+func (p *projectCreateShareLinkRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectCreateShareLinkRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.RevokeShareLink
+type projectRevokeShareLinkRequest struct {
+	ShareLinkID int64
+	abstractRequest
+}
+
+func (p projectRevokeShareLinkRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	link, err := db.MySQLShareLinkGet(p.ShareLinkID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, p.Tag)}}, nil
+	}
+
+	hasPermission, err := dbfs.HasCapability(p.SenderID, link.ProjectID, config.CapabilityManagePermissions, db)
+	if err != nil || !hasPermission {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": link.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	// tokens already issued for the link are rejected once it is deleted
+	err = db.MySQLShareLinkDelete(p.ShareLinkID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, err
+	}
+
+	return []dhClosure{
+		toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     p.SenderID,
+			Action:    "Project.RevokeShareLink",
+			ProjectID: link.ProjectID,
+			Target:    strconv.FormatInt(p.ShareLinkID, 10),
+		}},
+	}, nil
+}
+
+func (p *projectRevokeShareLinkRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectRevokeShareLinkRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return shareLinkProjectIDs(db, p.ShareLinkID)
+}
+
+// Project.GetShareLinks
+type projectGetShareLinksRequest struct {
+	ProjectID int64
+	abstractRequest
+}
+
+func (p projectGetShareLinksRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	hasPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityManagePermissions, db)
+	if err != nil || !hasPermission {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	links, err := db.MySQLProjectGetShareLinks(p.ProjectID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    p.Tag,
+		Data: struct {
+			ShareLinks []dbfs.ShareLink
+		}{
+			ShareLinks: links,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
+
+func (p *projectGetShareLinksRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectGetShareLinksRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.RedeemShareLink
+type projectRedeemShareLinkRequest struct {
+	Token string
+	abstractRequest
+}
+
+// shareLinkGuestSuffixBytes is the number of random bytes used to tell apart the guests redeeming the same link
+const shareLinkGuestSuffixBytes = 4
+
+func (p projectRedeemShareLinkRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	shareLinkID, err := db.MySQLShareLinkRedeem(hashSecretToken(p.Token))
+	if err != nil {
+		if err == dbfs.ErrNoData {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+		}
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+	link, err := db.MySQLShareLinkGet(shareLinkID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	// each guest gets their own username, so that they are rate limited separately
+	suffix := make([]byte, shareLinkGuestSuffixBytes)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+	username := fmt.Sprintf("guest-%d-%s", shareLinkID, hex.EncodeToString(suffix))
+
+	signed, err := newShareLinkToken(username, link)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    p.Tag,
+		Data: struct {
+			Username    string
+			Token       string
+			ProjectID   int64
+			ProjectName string
+		}{
+			Username:    username,
+			Token:       signed,
+			ProjectID:   link.ProjectID,
+			ProjectName: link.ProjectName,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     username,
+			Action:    "Project.RedeemShareLink",
+			ProjectID: link.ProjectID,
+			Target:    strconv.FormatInt(shareLinkID, 10),
+		}},
+	}, nil
+}
+
+func (p *projectRedeemShareLinkRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+// Project.GetOnlineClients
+type projectGetOnlineClientsRequest struct {
+	ProjectID int64
+	abstractRequest
+}
+
+func (p projectGetOnlineClientsRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	// TODO: implement on redis (and actually implement redis)
+	utils.LogWarn("ProjectGetOnlineClients not implemented", nil)
+
+	return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnimplemented, p.Tag)}}, nil
+}
+
+func (p *projectGetOnlineClientsRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectGetOnlineClientsRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.Lookup
+type projectLookupRequest struct {
+	ProjectIDs []int64
+	abstractRequest
+}
+
+// this request returns a slice of results for the projects we found, so we need the object that goes in that slice
+type projectLookupResult struct {
+	ProjectID   int64
+	Name        string
+	Permissions map[string](dbfs.ProjectPermission)
+}
+
+func (p projectLookupRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	/*
+		We could do
+			data := make([]interface{}, len(p.ProjectIDs))
+		but it seems like poor practice and makes the object oriented side of my brain cry
+	*/
+	resultData := make([]projectLookupResult, len(p.ProjectIDs))
+
+	var errOut error
+	i := 0
+	for _, id := range p.ProjectIDs {
+		// it's better to do a cheap lookup and then an expensive one if required than an expensive one every time
+		hasPermission, err := dbfs.HasCapability(p.SenderID, id, config.CapabilityRead, db)
+		if err != nil || !hasPermission {
+			utils.LogError("API permission error", err, utils.LogFields{
+				"Resource":  p.Resource,
+				"Method":    p.Method,
+				"SenderID":  p.SenderID,
+				"ProjectID": id,
+			})
+			errOut = ErrAuthenticationFailed
+			continue
+		}
+
+		lookupResult, err := projectLookup(p.SenderID, id, db)
+		if err != nil {
+			errOut = err
+		} else {
+			resultData[i] = lookupResult
+			i++
+		}
+	}
+	// shrink to cut off remainder left by errors
+	resultData = resultData[:i]
+
+	if errOut != nil {
+		if len(resultData) == 0 {
+			res := messages.Response{
+				Status: messages.StatusFail,
+				Tag:    p.Tag,
+				Data: struct {
+					Projects []projectLookupResult
+				}{
+					Projects: resultData,
+				},
+			}.Wrap()
+			return []dhClosure{toSenderClosure{msg: res}}, nil
+		}
+		res := messages.Response{
+			Status: messages.StatusPartialFail,
+			Tag:    p.Tag,
+			Data: struct {
+				Projects []projectLookupResult
+			}{
+				Projects: resultData,
+			},
+		}.Wrap()
+		return []dhClosure{toSenderClosure{msg: res}}, nil
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    p.Tag,
+		Data: struct {
+			Projects []projectLookupResult
+		}{
+			Projects: resultData,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
+
+func projectLookup(senderID string, projectID int64, db dbfs.DBFS) (projectLookupResult, error) {
+	var result projectLookupResult
+
+	name, permissions, err := db.MySQLProjectLookup(projectID, senderID)
+
+	if err != nil {
+		return result, err
+	}
+
+	result = projectLookupResult{
+		ProjectID:   projectID,
+		Name:        name,
+		Permissions: permissions,
+	}
+
+	return result, nil
+}
+
+func (p *projectLookupRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectLookupRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return p.ProjectIDs, nil
+}
+
+// Project.GetFiles
+type projectGetFilesRequest struct {
+	ProjectID int64
+	abstractRequest
+}
+
+type fileLookupResult struct {
+	FileID       int64
+	Filename     string
+	Creator      string
+	CreationDate time.Time
+	RelativePath string
+	Version      int64
+}
+
+func (p projectGetFilesRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	access, err := p.fileAccess(p.ProjectID, db)
+	if err != nil {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	files, err := db.MySQLProjectGetFiles(p.ProjectID)
+	if err != nil {
+		res := messages.Response{
+			Status: messages.StatusFail,
+			Tag:    p.Tag,
+			Data: struct {
+				Files []fileLookupResult
+			}{
+				Files: make([]fileLookupResult, 0),
+			},
+		}.Wrap()
+
+		return []dhClosure{toSenderClosure{msg: res}}, nil
+	}
+
+	resultData := make([]fileLookupResult, len(files))
+
+	i := 0
+	var errOut error
+	for _, file := range files {
+		// files the sender can't read are left out entirely
+		if !access.Can(file, config.CapabilityRead) {
+			continue
+		}
+
+		version, err := db.CBGetFileVersion(file.FileID)
+		if err != nil {
+			errOut = err
+		} else {
+			resultData[i] = fileLookupResult{
+				FileID:       file.FileID,
+				Filename:     file.Filename,
+				Creator:      file.Creator,
+				CreationDate: file.CreationDate,
+				RelativePath: file.RelativePath,
+				Version:      version}
+			i++
+		}
+	}
+	// shrink to cut off remainder left by errors
+	resultData = resultData[:i]
+
+	if errOut != nil {
+		if len(resultData) == 0 {
+			res := messages.Response{
+				Status: messages.StatusFail,
+				Tag:    p.Tag,
+				Data: struct {
+					Files []fileLookupResult
+				}{
+					Files: resultData,
+				},
+			}.Wrap()
+			return []dhClosure{toSenderClosure{msg: res}}, nil
+		}
+		res := messages.Response{
+			Status: messages.StatusPartialFail,
+			Tag:    p.Tag,
+			Data: struct {
+				Files []fileLookupResult
+			}{
+				Files: resultData,
+			},
+		}.Wrap()
+		return []dhClosure{toSenderClosure{msg: res}}, nil
+	}
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    p.Tag,
+		Data: struct {
+			Files []fileLookupResult
+		}{
+			Files: resultData,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
+
+func (p *projectGetFilesRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectGetFilesRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.Subscribe
+type projectSubscribeRequest struct {
+	ProjectID int64
+	abstractRequest
+}
+
+func (p projectSubscribeRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	access, err := p.fileAccess(p.ProjectID, db)
+	if err != nil || !access.Role.Has(config.CapabilityRead) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	cmdClosure := rabbitCommandClosure{
+		Command: "Subscribe",
+		Tag:     p.Tag,
+		Data: rabbitmq.RabbitQueueData{
+			Key: rabbitmq.RabbitProjectQueueName(p.ProjectID),
+		},
+	}
+	return []dhClosure{cmdClosure}, nil
+}
+
+func (p *projectSubscribeRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectSubscribeRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.Unsubscribe
+type projectUnsubscribeRequest struct {
+	ProjectID int64
+	abstractRequest
+}
+
+func (p projectUnsubscribeRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	cmdClosure := rabbitCommandClosure{
+		Command: "Unsubscribe",
+		Tag:     p.Tag,
+		Data: rabbitmq.RabbitQueueData{
+			Key: rabbitmq.RabbitProjectQueueName(p.ProjectID),
+		},
+	}
+	return []dhClosure{cmdClosure}, nil
+}
+
+func (p *projectUnsubscribeRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectUnsubscribeRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.Delete
+type projectDeleteRequest struct {
+	ProjectID int64
+	abstractRequest
+}
+
+func (p projectDeleteRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	hasPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityDeleteProject, db)
+	if err != nil {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+	}
+
+	if !hasPermission {
+		hasCurrentProjectPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityRead, db)
+		if err != nil {
+			utils.LogError("API permission error", err, utils.LogFields{
+				"Resource":  p.Resource,
+				"Method":    p.Method,
+				"SenderID":  p.SenderID,
+				"ProjectID": p.ProjectID,
+			})
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+		}
+
+		if hasCurrentProjectPermission {
+			// replace this delete request with a self revoke permissions request
+			realRequest := projectRevokePermissionsRequest{
+				ProjectID:       p.ProjectID,
+				RevokeUsername:  p.SenderID,
+				abstractRequest: p.abstractRequest,
+			}
+			return realRequest.process(db)
+		}
+
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	// members are looked up first, so that those who are offline can be told the project was deleted
+	name, permissions, err := db.MySQLProjectLookup(p.ProjectID, p.SenderID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+	members := make([]string, 0, len(permissions))
+	for username := range permissions {
+		if username != p.SenderID {
+			members = append(members, username)
+		}
+	}
+	sort.Strings(members)
+
+	// projects are moved to the trash, and purged once the retention period has passed
+	err = db.MySQLProjectTrash(p.ProjectID, p.SenderID)
+	if err != nil {
+		if err == dbfs.ErrNoDbChange {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, err
+		}
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)
+	not := messages.Notification{
+		Resource:   p.Resource,
+		Method:     p.Method,
+		ResourceID: p.ProjectID,
+		Data: struct {
+			Name string
+		}{
+			Name: name,
+		},
+	}.Wrap()
+
+	closures := []dhClosure{
+		toSenderClosure{msg: res},
+		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)},
+		auditClosure{entry: dbfs.AuditEntry{Actor: p.SenderID, Action: "Project.Delete", ProjectID: p.ProjectID}},
+	}
+	// live sockets were sent the notification through the project channel
+	for _, username := range members {
+		closures = append(closures, inboxClosure{msg: not, username: username})
+	}
+	return closures, nil
+}
+
+func (p *projectDeleteRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectDeleteRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.Restore
+type projectRestoreRequest struct {
+	ProjectID int64
+	abstractRequest
+}
+
+func (p *projectRestoreRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectRestoreRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+func (p projectRestoreRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	// only the owner can restore the project; the procedure checks this
+	err := db.MySQLProjectRestore(p.ProjectID, p.SenderID)
+	if err != nil {
+		if err == dbfs.ErrNoDbChange {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, p.Tag)}}, nil
+		}
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+
+	name, permissions, err := db.MySQLProjectLookup(p.ProjectID, p.SenderID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServPartialFail, p.Tag)}}, err
+	}
+	members := make([]string, 0, len(permissions))
+	for username := range permissions {
+		members = append(members, username)
+	}
+	sort.Strings(members)
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)
+	not := messages.Notification{
+		Resource:   p.Resource,
+		Method:     p.Method,
+		ResourceID: p.ProjectID,
+		Data: struct {
+			Name string
+		}{
+			Name: name,
+		},
+	}.Wrap()
+
+	closures := []dhClosure{toSenderClosure{msg: res}}
+	// members' sockets stopped following the project when it was deleted, so notify and resubscribe them directly
+	for _, username := range members {
+		closures = append(closures,
+			toUserClosure(not, username),
+			rabbitCommandClosure{
+				Command: "Subscribe",
+				Tag:     -1,
+				Key:     rabbitmq.RabbitUserQueueName(username),
+				Data: rabbitmq.RabbitQueueData{
+					Key: rabbitmq.RabbitProjectQueueName(p.ProjectID),
+				},
+			},
+		)
+	}
+
+	return append(closures, auditClosure{entry: dbfs.AuditEntry{
+		Actor:     p.SenderID,
+		Action:    "Project.Restore",
+		ProjectID: p.ProjectID,
+	}}), nil
+}
+
+// Project.ListTrash
+type projectListTrashRequest struct {
+	// ProjectID is the project to list the trashed files of; if 0, the sender's trashed projects are listed instead
+	ProjectID int64
+	abstractRequest
+}
+
+func (p *projectListTrashRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectListTrashRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+func (p projectListTrashRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	projects := []dbfs.TrashedProject{}
+	files := []dbfs.TrashedFile{}
+
+	if p.ProjectID == 0 {
+		var err error
+		projects, err = db.MySQLUserTrashedProjects(p.SenderID)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, err
+		}
+	} else {
+		access, err := dbfs.NewFileAccess(p.SenderID, p.ProjectID, db)
+		if err != nil {
+			utils.LogError("API permission error", err, utils.LogFields{
+				"Resource":  p.Resource,
+				"Method":    p.Method,
+				"SenderID":  p.SenderID,
+				"ProjectID": p.ProjectID,
+			})
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+		}
+
+		trashedFiles, err := db.MySQLProjectGetTrashedFiles(p.ProjectID)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, err
+		}
+		for _, file := range trashedFiles {
+			// files the sender can't read are left out entirely
+			if access.Can(file.FileMeta, config.CapabilityRead) {
+				files = append(files, file)
+			}
+		}
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    p.Tag,
+		Data: struct {
+			Projects []dbfs.TrashedProject
+			Files    []dbfs.TrashedFile
+		}{
+			Projects: projects,
+			Files:    files,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
+
+// Project.GetAuditLog
+type projectGetAuditLogRequest struct {
+	ProjectID int64
+	Action    string
+	Actor     string
+	Since     time.Time
+	Until     time.Time
+	Offset    int
+	Limit     int
+	abstractRequest
+}
+
+// defaultAuditLogLimit is the number of entries returned if the request does not specify a limit
+const defaultAuditLogLimit = 50
+
+// maxAuditLogLimit is the maximum number of entries returned by a single request
+const maxAuditLogLimit = 500
+
+func (p projectGetAuditLogRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	hasPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityViewAudit, db)
+	if err != nil || !hasPermission {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	if p.Offset < 0 || p.Limit < 0 {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+	}
+	if p.Limit == 0 {
+		p.Limit = defaultAuditLogLimit
+	} else if p.Limit > maxAuditLogLimit {
+		p.Limit = maxAuditLogLimit
+	}
+
+	entries, err := db.MySQLProjectGetAuditLog(p.ProjectID, dbfs.AuditFilter{
+		Action: p.Action,
+		Actor:  strings.ToLower(p.Actor),
+		Since:  p.Since,
+		Until:  p.Until,
+		Offset: p.Offset,
+		Limit:  p.Limit,
+	})
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    p.Tag,
+		Data: struct {
+			Entries []dbfs.AuditEntry
+		}{
+			Entries: entries,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
+
+func (p *projectGetAuditLogRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectGetAuditLogRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.SetRole
+type projectSetRoleRequest struct {
+	ProjectID    int64
+	Name         string
+	Level        int8
+	Capabilities []string
+	abstractRequest
+}
+
+func (p projectSetRoleRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	senderRole, err := dbfs.UserRole(p.SenderID, p.ProjectID, db)
+	if err != nil || !senderRole.Has(config.CapabilityManageRoles) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	role := config.Role{
+		Name:         strings.ToLower(p.Name),
+		Level:        p.Level,
+		Capabilities: p.Capabilities,
+	}
+	if role.Capabilities == nil {
+		role.Capabilities = []string{}
+	}
+
+	// Custom roles may not shadow the roles configured for the server
+	_, levelErr := config.RoleByLevel(role.Level)
+	_, labelErr := config.PermissionByLabel(role.Name)
+	if err := role.Validate(); err != nil || levelErr == nil || labelErr == nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+	}
+
+	// Prevent users from defining roles more powerful than their own
+	for _, capability := range role.Capabilities {
+		if !senderRole.Has(capability) {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+		}
+	}
+
+	err = db.MySQLProjectSetRole(p.ProjectID, role)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)
+	not := messages.Notification{
+		Resource:   p.Resource,
+		Method:     p.Method,
+		ResourceID: p.ProjectID,
+		Data: struct {
+			Role config.Role
+		}{
+			Role: role,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     p.SenderID,
+			Action:    "Project.SetRole",
+			ProjectID: p.ProjectID,
+			Target:    role.Name,
+			Detail:    strings.Join(role.Capabilities, ","),
+		}},
+	}, nil
+}
+
+func (p *projectSetRoleRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectSetRoleRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.DeleteRole
+type projectDeleteRoleRequest struct {
+	ProjectID int64
+	Level     int8
+	abstractRequest
+}
+
+func (p projectDeleteRoleRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	hasPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityManageRoles, db)
+	if err != nil || !hasPermission {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	// Roles still held by users cannot be deleted
+	err = db.MySQLProjectDeleteRole(p.ProjectID, p.Level)
+	if err != nil {
+		if err == dbfs.ErrNoDbChange {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+		}
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)
+	not := messages.Notification{
+		Resource:   p.Resource,
+		Method:     p.Method,
+		ResourceID: p.ProjectID,
+		Data: struct {
+			Level int8
+		}{
+			Level: p.Level,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     p.SenderID,
+			Action:    "Project.DeleteRole",
+			ProjectID: p.ProjectID,
+			Detail:    strconv.Itoa(int(p.Level)),
+		}},
+	}, nil
+}
+
+func (p *projectDeleteRoleRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
+
+func (p projectDeleteRoleRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.GetPathPermissions
+type projectGetPathPermissionsRequest struct {
+	ProjectID int64
+	abstractRequest
+}
+
+func (p projectGetPathPermissionsRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	senderRole, err := dbfs.UserRole(p.SenderID, p.ProjectID, db)
+	if err != nil {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
+
+	rules, err := db.MySQLProjectGetPathPermissions(p.ProjectID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+
+	// Users that can't manage permissions may only see the rules which apply to them
+	if !senderRole.Has(config.CapabilityManagePermissions) {
+		ownRules := []dbfs.PathPermission{}
+		for _, rule := range rules {
+			if rule.Username == p.SenderID {
+				ownRules = append(ownRules, rule)
+			}
+		}
+		rules = ownRules
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    p.Tag,
+		Data: struct {
+			Rules []dbfs.PathPermission
+		}{
+			Rules: rules,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
 }
 
-func projectLookup(senderID string, projectID int64, db dbfs.DBFS) (projectLookupResult, error) {
-	var result projectLookupResult
+func (p *projectGetPathPermissionsRequest) setAbstractRequest(req *abstractRequest) {
+	p.abstractRequest = *req
+}
 
-	name, permissions, err := db.MySQLProjectLookup(projectID, senderID)
+func (p projectGetPathPermissionsRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// pathPermissionRule is a single rule of a Project.SetPathPermissions request
+type pathPermissionRule struct {
+	PathPrefix      string
+	Username        string
+	PermissionLevel int8
+}
+
+// Project.SetPathPermissions
+type projectSetPathPermissionsRequest struct {
+	ProjectID int64
+	Rules     []pathPermissionRule
+	abstractRequest
+}
+
+func (p projectSetPathPermissionsRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	senderRole, err := dbfs.UserRole(p.SenderID, p.ProjectID, db)
+	if err != nil || !senderRole.Has(config.CapabilityManagePermissions) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  p.Resource,
+			"Method":    p.Method,
+			"SenderID":  p.SenderID,
+			"ProjectID": p.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+	}
 
+	_, members, err := db.MySQLProjectLookup(p.ProjectID, p.SenderID)
 	if err != nil {
-		return result, err
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
 	}
 
-	result = projectLookupResult{
-		ProjectID:   projectID,
-		Name:        name,
-		Permissions: permissions,
+	rules := make([]dbfs.PathPermission, len(p.Rules))
+	seen := make(map[string]bool)
+	for i, rule := range p.Rules {
+		prefix, err := dbfs.CleanPathPrefix(rule.PathPrefix)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, err
+		}
+
+		// Rules may only name members of the project, and the owner can't be restricted
+		member, ok := members[rule.Username]
+		if !ok || member.PermissionLevel == config.OwnerLevel || rule.PermissionLevel == config.OwnerLevel || seen[rule.Username+"|"+prefix] {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+		}
+		seen[rule.Username+"|"+prefix] = true
+
+		// A level of 0 denies all access; otherwise, prevent users from granting more than they hold
+		if rule.PermissionLevel != 0 {
+			role, err := dbfs.ProjectRole(p.ProjectID, rule.PermissionLevel, db)
+			if err != nil {
+				return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
+			}
+			for _, capability := range role.Capabilities {
+				if !senderRole.Has(capability) {
+					return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
+				}
+			}
+		}
+
+		rules[i] = dbfs.PathPermission{
+			PathPrefix:      prefix,
+			Username:        rule.Username,
+			PermissionLevel: rule.PermissionLevel,
+		}
 	}
 
-	return result, nil
+	err = db.MySQLProjectSetPathPermissions(p.ProjectID, rules, p.SenderID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)
+	not := messages.Notification{
+		Resource:   p.Resource,
+		Method:     p.Method,
+		ResourceID: p.ProjectID,
+		Data:       struct{}{},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     p.SenderID,
+			Action:    "Project.SetPathPermissions",
+			ProjectID: p.ProjectID,
+			Detail:    strconv.Itoa(len(rules)),
+		}},
+	}, nil
 }
 
-func (p *projectLookupRequest) setAbstractRequest(req *abstractRequest) {
+func (p *projectSetPathPermissionsRequest) setAbstractRequest(req *abstractRequest) {
 	p.abstractRequest = *req
 }
 
-// Project.GetFiles
-type projectGetFilesRequest struct {
+func (p projectSetPathPermissionsRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.AddWebhook
+type projectAddWebhookRequest struct {
 	ProjectID int64
+	URL       string
+	// Secret is the key deliveries are signed with; one is generated if it is not given
+	Secret string
+	// Events are the events delivered to the webhook; all events are delivered if none are given
+	Events []string
 	abstractRequest
 }
 
-type fileLookupResult struct {
-	FileID       int64
-	Filename     string
-	Creator      string
-	CreationDate time.Time
-	RelativePath string
-	Version      int64
-}
+// maxProjectWebhooks is the maximum number of webhooks a project can have
+const maxProjectWebhooks = 20
 
-func (p projectGetFilesRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
-	hasPermission, err := dbfs.PermissionAtLeast(p.SenderID, p.ProjectID, "read", db)
+// Limits of the Webhooks table
+const (
+	maxWebhookURLLength    = 2000
+	maxWebhookSecretLength = 100
+)
+
+func (p projectAddWebhookRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	hasPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityManageWebhooks, db)
 	if err != nil || !hasPermission {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  p.Resource,
@@ -478,92 +2135,111 @@ func (p projectGetFilesRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
 	}
 
-	files, err := db.MySQLProjectGetFiles(p.ProjectID)
-	if err != nil {
-		res := messages.Response{
-			Status: messages.StatusFail,
-			Tag:    p.Tag,
-			Data: struct {
-				Files []fileLookupResult
-			}{
-				Files: make([]fileLookupResult, 0),
-			},
-		}.Wrap()
+	webhookURL, err := url.Parse(p.URL)
+	if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" ||
+		len(p.URL) > maxWebhookURLLength {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(p.Tag, "The URL must be an absolute http or https URL")}}, nil
+	}
+	if len(p.Secret) > maxWebhookSecretLength {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(p.Tag, "The secret is too long")}}, nil
+	}
 
-		return []dhClosure{toSenderClosure{msg: res}}, nil
+	events := []string{}
+	seen := make(map[string]bool)
+	for _, event := range p.Events {
+		if !webhooks.IsEvent(event) {
+			return []dhClosure{toSenderClosure{msg: newRejectedResponse(p.Tag, "Unsupported event: "+event)}}, nil
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
 	}
 
-	resultData := make([]fileLookupResult, len(files))
+	existing, err := db.MySQLWebhooksGet(p.ProjectID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
+	if len(existing) >= maxProjectWebhooks {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(p.Tag, "The project has too many webhooks")}}, nil
+	}
 
-	i := 0
-	var errOut error
-	for _, file := range files {
-		version, err := db.CBGetFileVersion(file.FileID)
+	if p.Secret == "" {
+		p.Secret, err = newSecretToken()
 		if err != nil {
-			errOut = err
-		} else {
-			resultData[i] = fileLookupResult{
-				FileID:       file.FileID,
-				Filename:     file.Filename,
-				Creator:      file.Creator,
-				CreationDate: file.CreationDate,
-				RelativePath: file.RelativePath,
-				Version:      version}
-			i++
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
 		}
 	}
-	// shrink to cut off remainder left by errors
-	resultData = resultData[:i]
 
-	if errOut != nil {
-		if len(resultData) == 0 {
-			res := messages.Response{
-				Status: messages.StatusFail,
-				Tag:    p.Tag,
-				Data: struct {
-					Files []fileLookupResult
-				}{
-					Files: resultData,
-				},
-			}.Wrap()
-			return []dhClosure{toSenderClosure{msg: res}}, nil
-		}
-		res := messages.Response{
-			Status: messages.StatusPartialFail,
-			Tag:    p.Tag,
-			Data: struct {
-				Files []fileLookupResult
-			}{
-				Files: resultData,
-			},
-		}.Wrap()
-		return []dhClosure{toSenderClosure{msg: res}}, nil
+	webhookID, err := db.MySQLWebhookCreate(dbfs.Webhook{
+		ProjectID: p.ProjectID,
+		URL:       p.URL,
+		Secret:    p.Secret,
+		Events:    events,
+		CreatedBy: p.SenderID,
+	})
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
 	}
+
 	res := messages.Response{
 		Status: messages.StatusSuccess,
 		Tag:    p.Tag,
 		Data: struct {
-			Files []fileLookupResult
+			WebhookID int64
+			Secret    string
 		}{
-			Files: resultData,
+			WebhookID: webhookID,
+			Secret:    p.Secret,
 		},
 	}.Wrap()
 
-	return []dhClosure{toSenderClosure{msg: res}}, nil
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		// binding the webhooks queue again is harmless, if the project already had webhooks
+		rabbitCommandClosure{
+			Command: "Subscribe",
+			Tag:     -1,
+			Key:     rabbitmq.RabbitWebhookQueueName,
+			Data: rabbitmq.RabbitQueueData{
+				Key: rabbitmq.RabbitProjectQueueName(p.ProjectID),
+			},
+		},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     p.SenderID,
+			Action:    "Project.AddWebhook",
+			ProjectID: p.ProjectID,
+			Target:    strconv.FormatInt(webhookID, 10),
+			Detail:    p.URL,
+		}},
+	}, nil
 }
 
-func (p *projectGetFilesRequest) setAbstractRequest(req *abstractRequest) {
+func (p *projectAddWebhookRequest) setAbstractRequest(req *abstractRequest) {
 	p.abstractRequest = *req
 }
 
-// Project.Subscribe
-type projectSubscribeRequest struct {
+func (p projectAddWebhookRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.ListWebhooks
+type projectListWebhooksRequest struct {
 	ProjectID int64
 	abstractRequest
 }
 
-func (p projectSubscribeRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
-	hasPermission, err := dbfs.PermissionAtLeast(p.SenderID, p.ProjectID, "read", db)
+// webhookDeliveriesListed is the number of recent deliveries listed with each webhook
+const webhookDeliveriesListed = 10
+
+// webhookWithDeliveries is a webhook, listed with its recent deliveries
+type webhookWithDeliveries struct {
+	dbfs.Webhook
+	Deliveries []dbfs.WebhookDelivery
+}
+
+func (p projectListWebhooksRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	hasPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityManageWebhooks, db)
 	if err != nil || !hasPermission {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  p.Resource,
@@ -574,104 +2250,101 @@ func (p projectSubscribeRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
 	}
 
-	cmdClosure := rabbitCommandClosure{
-		Command: "Subscribe",
-		Tag:     p.Tag,
-		Data: rabbitmq.RabbitQueueData{
-			Key: rabbitmq.RabbitProjectQueueName(p.ProjectID),
-		},
+	projectWebhooks, err := db.MySQLWebhooksGet(p.ProjectID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
 	}
-	return []dhClosure{cmdClosure}, nil
-}
 
-func (p *projectSubscribeRequest) setAbstractRequest(req *abstractRequest) {
-	p.abstractRequest = *req
-}
-
-// Project.Unsubscribe
-type projectUnsubscribeRequest struct {
-	ProjectID int64
-	abstractRequest
-}
+	// secrets are left out, as dbfs.Webhook does not marshal them
+	listed := make([]webhookWithDeliveries, len(projectWebhooks))
+	for i, webhook := range projectWebhooks {
+		deliveries, err := db.MySQLWebhookDeliveriesGet(webhook.WebhookID, webhookDeliveriesListed)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+		}
+		listed[i] = webhookWithDeliveries{Webhook: webhook, Deliveries: deliveries}
+	}
 
-func (p projectUnsubscribeRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
-	cmdClosure := rabbitCommandClosure{
-		Command: "Unsubscribe",
-		Tag:     p.Tag,
-		Data: rabbitmq.RabbitQueueData{
-			Key: rabbitmq.RabbitProjectQueueName(p.ProjectID),
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    p.Tag,
+		Data: struct {
+			Webhooks []webhookWithDeliveries
+		}{
+			Webhooks: listed,
 		},
-	}
-	return []dhClosure{cmdClosure}, nil
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
 }
 
-func (p *projectUnsubscribeRequest) setAbstractRequest(req *abstractRequest) {
+func (p *projectListWebhooksRequest) setAbstractRequest(req *abstractRequest) {
 	p.abstractRequest = *req
 }
 
-// Project.Delete
-type projectDeleteRequest struct {
+func (p projectListWebhooksRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
+
+// Project.RemoveWebhook
+type projectRemoveWebhookRequest struct {
 	ProjectID int64
+	WebhookID int64
 	abstractRequest
 }
 
-func (p projectDeleteRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
-	hasPermission, err := dbfs.PermissionAtLeast(p.SenderID, p.ProjectID, "owner", db)
-	if err != nil {
+func (p projectRemoveWebhookRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	hasPermission, err := dbfs.HasCapability(p.SenderID, p.ProjectID, config.CapabilityManageWebhooks, db)
+	if err != nil || !hasPermission {
 		utils.LogError("API permission error", err, utils.LogFields{
 			"Resource":  p.Resource,
 			"Method":    p.Method,
 			"SenderID":  p.SenderID,
 			"ProjectID": p.ProjectID,
 		})
-		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
-	}
-
-	if !hasPermission {
-		hasCurrentProjectPermission, err := dbfs.PermissionAtLeast(p.SenderID, p.ProjectID, "read", db)
-		if err != nil {
-			utils.LogError("API permission error", err, utils.LogFields{
-				"Resource":  p.Resource,
-				"Method":    p.Method,
-				"SenderID":  p.SenderID,
-				"ProjectID": p.ProjectID,
-			})
-			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, nil
-		}
-
-		if hasCurrentProjectPermission {
-			// replace this delete request with a self revoke permissions request
-			realRequest := projectRevokePermissionsRequest{
-				ProjectID:       p.ProjectID,
-				RevokeUsername:  p.SenderID,
-				abstractRequest: p.abstractRequest,
-			}
-			return realRequest.process(db)
-		}
-
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, p.Tag)}}, nil
 	}
 
-	err = db.MySQLProjectDelete(p.ProjectID, p.SenderID)
-	if err != nil {
-		if err == dbfs.ErrNoDbChange {
-			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, p.Tag)}}, err
-		}
+	err = db.MySQLWebhookDelete(p.ProjectID, p.WebhookID)
+	if err == dbfs.ErrNoDbChange {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, p.Tag)}}, nil
+	} else if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, p.Tag)}}, err
+	}
 
+	closures := []dhClosure{
+		toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:     p.SenderID,
+			Action:    "Project.RemoveWebhook",
+			ProjectID: p.ProjectID,
+			Target:    strconv.FormatInt(p.WebhookID, 10),
+		}},
 	}
 
-	res := messages.NewEmptyResponse(messages.StatusSuccess, p.Tag)
-	not := messages.Notification{
-		Resource:   p.Resource,
-		Method:     p.Method,
-		ResourceID: p.ProjectID,
-		Data:       struct{}{},
-	}.Wrap()
+	// stop consuming the project's notifications once it has no webhooks left to deliver them to
+	remaining, err := db.MySQLWebhooksGet(p.ProjectID)
+	if err != nil {
+		return closures, err
+	}
+	if len(remaining) == 0 {
+		closures = append(closures, rabbitCommandClosure{
+			Command: "Unsubscribe",
+			Tag:     -1,
+			Key:     rabbitmq.RabbitWebhookQueueName,
+			Data: rabbitmq.RabbitQueueData{
+				Key: rabbitmq.RabbitProjectQueueName(p.ProjectID),
+			},
+		})
+	}
 
-	return []dhClosure{toSenderClosure{msg: res}, toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(p.ProjectID)}}, nil
+	return closures, nil
 }
 
-func (p *projectDeleteRequest) setAbstractRequest(req *abstractRequest) {
+func (p *projectRemoveWebhookRequest) setAbstractRequest(req *abstractRequest) {
 	p.abstractRequest = *req
 }
+
+func (p projectRemoveWebhookRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return []int64{p.ProjectID}, nil
+}
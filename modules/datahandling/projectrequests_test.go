@@ -1,8 +1,11 @@
 package datahandling
 
 import (
+	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/CodeCollaborate/Server/modules/config"
 	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
@@ -73,6 +76,34 @@ func TestProjectCreateRequest_Process(t *testing.T) {
 
 }
 
+func TestProjectCreateRequest_ProcessUnverified(t *testing.T) {
+	configSetup(t)
+	req := projectCreateRequest{Name: "new stuff"}
+	setBaseFields(&req)
+
+	db := dbfs.NewDBMock()
+	pending := geneMeta
+	pending.Status = dbfs.UserStatusPending
+	db.Users["loganga"] = pending
+
+	// unverified users can create projects unless the policy says otherwise
+	closures, err := req.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status)
+
+	config.GetConfig().ServerConfig.Registration.UnverifiedPolicy = config.UnverifiedBlockProjects
+	req.Name = "more stuff"
+	closures, err = req.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusUnauthorized, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status)
+	assert.Len(t, db.Projects["loganga"], 1)
+
+	db.Users["loganga"] = geneMeta
+	closures, err = req.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status)
+}
+
 func TestProjectRenameRequest_Process(t *testing.T) {
 	configSetup(t)
 	req := *new(projectRenameRequest)
@@ -102,9 +133,10 @@ func TestProjectRenameRequest_Process(t *testing.T) {
 	assert.Equal(t, 2, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
-	if len(closures) != 2 ||
+	if len(closures) != 3 ||
 		reflect.TypeOf(closures[0]).String() != "datahandling.toSenderClosure" ||
-		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" {
+		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" ||
+		reflect.TypeOf(closures[2]).String() != "datahandling.auditClosure" {
 		t.Fatalf("did not properly process, recieved %d closure(s)", len(closures))
 	}
 
@@ -185,10 +217,11 @@ func TestProjectGrantPermissionsRequest_Process(t *testing.T) {
 	assert.Equal(t, 2, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
-	if len(closures) != 3 ||
+	if len(closures) != 4 ||
 		reflect.TypeOf(closures[0]).String() != "datahandling.toSenderClosure" ||
 		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" ||
-		reflect.TypeOf(closures[2]).String() != "datahandling.toRabbitChannelClosure" {
+		reflect.TypeOf(closures[2]).String() != "datahandling.toRabbitChannelClosure" ||
+		reflect.TypeOf(closures[3]).String() != "datahandling.auditClosure" {
 		t.Fatalf("did not properly process, recieved %d closure(s)", len(closures))
 	}
 
@@ -244,10 +277,10 @@ func TestProjectRevokePermissionsRequest_Process(t *testing.T) {
 	}
 
 	// didn't call extra db functions
-	assert.Equal(t, 2, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 3, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
-	if len(closures) != 4 {
+	if len(closures) != 5 {
 		t.Fatalf("did not properly process, recieved %d closure(s)", len(closures))
 	}
 
@@ -255,6 +288,7 @@ func TestProjectRevokePermissionsRequest_Process(t *testing.T) {
 	assert.IsType(t, toRabbitChannelClosure{}, closures[1], "expected 2nd closure to be sent to project")
 	assert.IsType(t, toRabbitChannelClosure{}, closures[2], "expected 3rd closure to be sent to revokee")
 	assert.IsType(t, rabbitCommandClosure{}, closures[3], "expected 4th closure to be rabbit command")
+	assert.IsType(t, auditClosure{}, closures[4], "expected 5th closure to be audited")
 
 	// did the server return success status
 	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
@@ -380,7 +414,7 @@ func TestProjectGetFilesRequest_Process(t *testing.T) {
 	}
 
 	// didn't call extra db functions
-	assert.Equal(t, 5, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 6, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
 	if len(closures) != 1 ||
@@ -490,12 +524,13 @@ func TestProjectDeleteRequest_process(t *testing.T) {
 	}
 
 	// didn't call extra db functions
-	assert.Equal(t, 2, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 3, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
-	if len(closures) != 2 ||
+	if len(closures) != 3 ||
 		reflect.TypeOf(closures[0]).String() != "datahandling.toSenderClosure" ||
-		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" {
+		reflect.TypeOf(closures[1]).String() != "datahandling.toRabbitChannelClosure" ||
+		reflect.TypeOf(closures[2]).String() != "datahandling.auditClosure" {
 		t.Fatalf("did not properly process, recieved %d closure(s)", len(closures))
 	}
 
@@ -510,6 +545,7 @@ func TestProjectDeleteRequest_process(t *testing.T) {
 	if not.ResourceID != projID {
 		t.Fatalf("Incorrect projectID was returned, expected %d, recieved %d", projID, not.ResourceID)
 	}
+	assert.Contains(t, db.TrashedProjects, projID, "deleted projects should be moved to the trash")
 }
 
 func TestProjectDeleteTurnsIntoRevokeRequest(t *testing.T) {
@@ -532,7 +568,7 @@ func TestProjectDeleteTurnsIntoRevokeRequest(t *testing.T) {
 	db.Users["notloganga"] = notgenemeta
 
 	projectID, err := db.MySQLProjectCreate("loganga", "new stuff")
-	db.MySQLProjectGrantPermission(projectID, notgenemeta.Username, 5, geneMeta.Username)
+	db.MySQLProjectGrantPermission(projectID, notgenemeta.Username, config.PermissionsByLabel["write"], geneMeta.Username)
 	db.FunctionCallCount = 0
 
 	req.ProjectID = projectID
@@ -544,10 +580,10 @@ func TestProjectDeleteTurnsIntoRevokeRequest(t *testing.T) {
 	}
 
 	// didn't call extra db functions
-	assert.Equal(t, 4, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 5, db.FunctionCallCount, "did not call correct number of db functions")
 
 	// are we notifying the right people
-	if len(closures) != 4 {
+	if len(closures) != 5 {
 		t.Fatalf("did not properly process, recieved %d closure(s)", len(closures))
 	}
 
@@ -555,6 +591,7 @@ func TestProjectDeleteTurnsIntoRevokeRequest(t *testing.T) {
 	assert.IsType(t, toRabbitChannelClosure{}, closures[1], "expected 2nd closure to be sent to project")
 	assert.IsType(t, toRabbitChannelClosure{}, closures[2], "expected 3rd closure to be sent to revokee")
 	assert.IsType(t, rabbitCommandClosure{}, closures[3], "expected 4th closure to be rabbit command")
+	assert.IsType(t, auditClosure{}, closures[4], "expected 5th closure to be audited")
 
 	// did the server return success status
 	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
@@ -602,3 +639,905 @@ func TestProjectDeleteTurnsIntoRevokeRequest(t *testing.T) {
 		t.Fatal("Database was not properly modified")
 	}
 }
+
+func TestProjectGetAuditLogRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.Users["loganga"] = geneMeta
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "notloganga", 1, "loganga")
+
+	dh := DataHandler{
+		MessageChan: make(chan rabbitmq.AMQPMessage, 1),
+		WebsocketID: 1,
+		Db:          db,
+		RemoteAddr:  "10.0.0.1",
+	}
+
+	rename := projectRenameRequest{ProjectID: projectID, NewName: "newer stuff"}
+	setBaseFields(&rename)
+	closures, err := rename.process(db)
+	assert.Nil(t, err)
+	for _, closure := range closures {
+		if audit, ok := closure.(auditClosure); ok {
+			assert.Nil(t, audit.call(dh))
+		}
+	}
+	db.MySQLAuditLogInsert(dbfs.AuditEntry{Actor: "loganga", Action: "Project.GrantPermissions", ProjectID: projectID, Target: "notloganga"})
+	db.MySQLAuditLogInsert(dbfs.AuditEntry{Actor: "loganga", Action: "Project.Rename", ProjectID: projectID + 1})
+
+	req := *new(projectGetAuditLogRequest)
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "GetAuditLog"
+	req.ProjectID = projectID
+	req.Action = "Project.Rename"
+
+	db.FunctionCallCount = 0
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 1, len(closures), "unexpected number of returned closures")
+
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	entries := reflect.ValueOf(resp.Data).FieldByName("Entries").Interface().([]dbfs.AuditEntry)
+	if assert.Len(t, entries, 1, "only the matching project and action should be returned") {
+		assert.Equal(t, "loganga", entries[0].Actor)
+		assert.Equal(t, "newer stuff", entries[0].Detail)
+		assert.Equal(t, "10.0.0.1", entries[0].SourceIP, "source IP should be recorded from the websocket")
+	}
+
+	// pagination
+	req.Action = ""
+	req.Offset = 1
+	req.Limit = 1
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	entries = reflect.ValueOf(resp.Data).FieldByName("Entries").Interface().([]dbfs.AuditEntry)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "Project.Rename", entries[0].Action, "entries should be returned newest first")
+	}
+
+	// readers may not see the audit log
+	req.SenderID = "notloganga"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+}
+
+func TestProjectSetRoleRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.Users["loganga"] = geneMeta
+	db.ProjectIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "notloganga", config.PermissionsByLabel["admin"], "loganga")
+
+	req := *new(projectSetRoleRequest)
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "SetRole"
+	req.ProjectID = projectID
+	req.Name = "Reviewer"
+	req.Level = 20
+	req.Capabilities = []string{config.CapabilityRead, config.CapabilityComment}
+
+	db.FunctionCallCount = 0
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	assert.IsType(t, toRabbitChannelClosure{}, closures[1], "role changes should be sent to the project")
+	assert.IsType(t, auditClosure{}, closures[2], "role changes should be audited")
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, []config.Role{{Name: "reviewer", Level: 20, Capabilities: req.Capabilities}}, db.ProjectRoles[projectID])
+
+	// the role can now be granted
+	grant := projectGrantPermissionsRequest{ProjectID: projectID, GrantUsername: "reviewer1", PermissionLevel: 20}
+	setBaseFields(&grant)
+	closures, err = grant.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "custom roles should be grantable")
+
+	// the project's roles are returned with the permission constants
+	constants := projectGetPermissionConstantsRequest{ProjectID: projectID}
+	setBaseFields(&constants)
+	closures, err = constants.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	roles := reflect.ValueOf(resp.Data).FieldByName("Roles").Interface().([]config.Role)
+	assert.Len(t, roles, len(config.Roles())+1)
+	assert.Equal(t, "reviewer", roles[len(roles)-1].Name)
+
+	// server roles cannot be shadowed
+	req.Name = "write"
+	req.Level = 21
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "server role names should be rejected")
+	req.Name = "other"
+	req.Level = config.PermissionsByLabel["write"]
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "server role levels should be rejected")
+
+	// admins cannot create roles more powerful than their own
+	req.SenderID = "notloganga"
+	req.Level = 22
+	req.Capabilities = []string{config.CapabilityDeleteProject}
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+}
+
+func TestProjectDeleteRoleRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.Users["loganga"] = geneMeta
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.ProjectRoles[projectID] = []config.Role{
+		{Name: "reviewer", Level: 20, Capabilities: []string{config.CapabilityRead}},
+	}
+	db.MySQLProjectGrantPermission(projectID, "notloganga", 20, "loganga")
+
+	req := *new(projectDeleteRoleRequest)
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "DeleteRole"
+	req.ProjectID = projectID
+	req.Level = 20
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "roles held by users should not be deleted")
+
+	db.MySQLProjectRevokePermission(projectID, "notloganga", "loganga")
+	db.FunctionCallCount = 0
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, db.FunctionCallCount, "did not call correct number of db functions")
+	assert.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Empty(t, db.ProjectRoles[projectID])
+}
+
+func TestProjectGrantPermissionsRequest_Escalation(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.Users["loganga"] = geneMeta
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.ProjectRoles[projectID] = []config.Role{
+		{Name: "people-manager", Level: 20, Capabilities: []string{config.CapabilityRead, config.CapabilityManagePermissions}},
+	}
+	db.MySQLProjectGrantPermission(projectID, "notloganga", 20, "loganga")
+
+	req := projectGrantPermissionsRequest{
+		ProjectID:       projectID,
+		GrantUsername:   "someone",
+		PermissionLevel: config.PermissionsByLabel["write"],
+	}
+	setBaseFields(&req)
+	req.SenderID = "notloganga"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "users should not grant capabilities they do not have")
+
+	req.PermissionLevel = config.PermissionsByLabel["read"]
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+}
+
+func TestProjectSetPathPermissionsRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.Users["loganga"] = geneMeta
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "contractor", config.PermissionsByLabel["read"], "loganga")
+
+	req := *new(projectSetPathPermissionsRequest)
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "SetPathPermissions"
+	req.ProjectID = projectID
+	req.Rules = []pathPermissionRule{
+		{PathPrefix: "frontend/", Username: "contractor", PermissionLevel: config.PermissionsByLabel["write"]},
+		{PathPrefix: "frontend/secrets", Username: "contractor", PermissionLevel: 0},
+	}
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, "Project.SetPathPermissions", closures[2].(auditClosure).entry.Action)
+	if assert.Len(t, db.PathPermissions[projectID], 2) {
+		assert.Equal(t, "frontend", db.PathPermissions[projectID][0].PathPrefix, "prefixes should be cleaned")
+		assert.Equal(t, "loganga", db.PathPermissions[projectID][0].GrantedBy)
+	}
+
+	// the owner can't be restricted, and rules can't name users outside of the project
+	for _, username := range []string{"loganga", "stranger"} {
+		req.Rules = []pathPermissionRule{{PathPrefix: "backend", Username: username, PermissionLevel: 0}}
+		closures, err = req.process(db)
+		resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+		assert.Equal(t, messages.StatusFail, resp.Status, "rule for %s should be rejected", username)
+	}
+
+	req.Rules = []pathPermissionRule{{PathPrefix: "../outside", Username: "contractor", PermissionLevel: 0}}
+	closures, _ = req.process(db)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "prefixes outside the project should be rejected")
+	assert.Len(t, db.PathPermissions[projectID], 2, "rejected requests should not change the rules")
+
+	req.SenderID = "contractor"
+	req.Rules = []pathPermissionRule{}
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "users without manage_permissions should not set rules")
+
+	getReq := *new(projectGetPathPermissionsRequest)
+	setBaseFields(&getReq)
+	getReq.ProjectID = projectID
+	db.PathPermissions[projectID] = append(db.PathPermissions[projectID], dbfs.PathPermission{PathPrefix: "docs", Username: "someone"})
+
+	closures, err = getReq.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	rules := reflect.ValueOf(resp.Data).FieldByName("Rules").Interface().([]dbfs.PathPermission)
+	assert.Len(t, rules, 3)
+
+	getReq.SenderID = "contractor"
+	closures, err = getReq.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	rules = reflect.ValueOf(resp.Data).FieldByName("Rules").Interface().([]dbfs.PathPermission)
+	assert.Len(t, rules, 2, "users should only see their own rules")
+}
+
+func TestProjectGetFilesRequest_PathPermissions(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.Users["loganga"] = geneMeta
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "contractor", config.PermissionsByLabel["write"], "loganga")
+	db.MySQLFileCreate("loganga", "app.js", "frontend", projectID)
+	db.MySQLFileCreate("loganga", "main.go", "backend", projectID)
+	db.PathPermissions[projectID] = []dbfs.PathPermission{
+		{PathPrefix: "backend", Username: "contractor", PermissionLevel: 0},
+	}
+
+	req := *new(projectGetFilesRequest)
+	setBaseFields(&req)
+	req.SenderID = "contractor"
+	req.ProjectID = projectID
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	files := reflect.ValueOf(resp.Data).FieldByName("Files").Interface().([]fileLookupResult)
+	if assert.Len(t, files, 1, "files the user can't read should not be listed") {
+		assert.Equal(t, "app.js", files[0].Filename)
+	}
+}
+
+func TestProjectGrantPermissionsRequest_Group(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.GroupIDCounter = 1
+	db.Users["loganga"] = geneMeta
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	groupID, _ := db.MySQLGroupCreate("loganga", "backend team")
+	db.MySQLGroupAddMember(groupID, "notloganga", "loganga")
+	otherGroupID, _ := db.MySQLGroupCreate("someone", "strangers")
+
+	req := projectGrantPermissionsRequest{
+		ProjectID:       projectID,
+		GrantGroupID:    otherGroupID,
+		PermissionLevel: config.PermissionsByLabel["write"],
+	}
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "GrantPermissions"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "users should only grant groups they are a member of")
+
+	req.GrantGroupID = groupID
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	// response, project notification, a notification for each member, and the audit entry
+	assert.Equal(t, 5, len(closures), "unexpected number of returned closures")
+
+	level, err := db.MySQLUserProjectPermissionLookup(projectID, "notloganga")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["write"], level)
+
+	projects, _ := db.MySQLUserProjects("notloganga")
+	if assert.Len(t, projects, 1, "projects granted to groups should be listed for their members") {
+		assert.Equal(t, "new stuff", projects[0].Name)
+	}
+
+	_, permissions, err := db.MySQLProjectLookup(projectID, "notloganga")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["write"], permissions["notloganga"].PermissionLevel)
+
+	revokeReq := projectRevokePermissionsRequest{
+		ProjectID:     projectID,
+		RevokeGroupID: groupID,
+	}
+	setBaseFields(&revokeReq)
+	revokeReq.Resource = "Project"
+	revokeReq.Method = "RevokePermissions"
+
+	closures, err = revokeReq.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	// response, project notification, notification and unsubscribe for notloganga, and the audit entry
+	assert.Equal(t, 5, len(closures), "the owner should not be unsubscribed")
+	assert.Equal(t, "Unsubscribe", closures[3].(rabbitCommandClosure).Command)
+
+	_, err = db.MySQLUserProjectPermissionLookup(projectID, "notloganga")
+	assert.Error(t, err, "members should lose access once the group is revoked")
+}
+
+func TestProjectInviteRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.InviteIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "member", config.PermissionsByLabel["read"], "loganga")
+
+	req := projectInviteRequest{
+		ProjectID:       projectID,
+		Username:        "NotLoganga",
+		PermissionLevel: config.PermissionsByLabel["write"],
+	}
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "Invite"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	inviteID := reflect.ValueOf(resp.Data).FieldByName("InviteID").Interface().(int64)
+	assert.Equal(t, rabbitmq.RabbitUserQueueName("notloganga"), closures[1].(toRabbitChannelClosure).key)
+
+	invite := db.Invites[inviteID]
+	assert.Equal(t, "notloganga", invite.Username)
+	assert.WithinDuration(t, time.Now().Add(defaultInviteExpiryDays*24*time.Hour), invite.ExpiryDate, time.Minute)
+	_, err = db.MySQLUserProjectPermissionLookup(projectID, "notloganga")
+	assert.Error(t, err, "permissions should not be applied until the invite is accepted")
+
+	// invites to unregistered users are sent by email
+	req.Username = ""
+	req.Email = "someone@codecollaborate.com"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+
+	req.Username = "notloganga"
+	closures, _ = req.process(db)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "only one of username or email should be accepted")
+
+	req.Email = ""
+	req.Username = "member"
+	closures, _ = req.process(db)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "existing members should not be invited")
+
+	req.Username = "notloganga"
+	req.ExpiryDays = maxInviteExpiryDays + 1
+	closures, _ = req.process(db)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "invites should not outlive the maximum expiry")
+
+	req.ExpiryDays = 0
+	req.SenderID = "member"
+	closures, _ = req.process(db)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+}
+
+func TestProjectTransferOwnershipRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.ProjectIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "admin", config.PermissionsByLabel["admin"], "loganga")
+
+	req := projectTransferOwnershipRequest{
+		ProjectID:        projectID,
+		NewOwner:         "NotLoganga",
+		FormerOwnerLevel: config.PermissionsByLabel["write"],
+	}
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "TransferOwnership"
+
+	req.SenderID = "admin"
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "only the owner should transfer ownership")
+
+	req.SenderID = "loganga"
+	req.FormerOwnerLevel = config.OwnerLevel
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "the former owner must be demoted")
+
+	req.FormerOwnerLevel = config.PermissionsByLabel["write"]
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, rabbitmq.RabbitUserQueueName("notloganga"), closures[1].(toRabbitChannelClosure).key)
+
+	level, err := db.MySQLUserProjectPermissionLookup(projectID, "loganga")
+	assert.NoError(t, err)
+	assert.Equal(t, config.OwnerLevel, level, "ownership should not change until the transfer is accepted")
+}
+
+func TestProjectAcceptOwnershipRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.ProjectIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectCreate("notloganga", "new stuff")
+	db.MySQLProjectRequestTransfer(dbfs.OwnershipTransfer{
+		ProjectID:        projectID,
+		FormerOwner:      "loganga",
+		NewOwner:         "notloganga",
+		FormerOwnerLevel: config.PermissionsByLabel["write"],
+	})
+
+	req := projectAcceptOwnershipRequest{ProjectID: projectID}
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "AcceptOwnership"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNotFound, resp.Status, "only the new owner should accept the transfer")
+
+	req.SenderID = "notloganga"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNameConflict, resp.Status, "the new owner already owns a project with this name")
+
+	req.NewName = "new stuff (loganga)"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(projectID), closures[1].(toRabbitChannelClosure).key,
+		"the transfer should be broadcast on the project channel")
+	not := closures[1].(toRabbitChannelClosure).msg.ServerMessage.(messages.Notification)
+	assert.Equal(t, "notloganga", reflect.ValueOf(not.Data).FieldByName("Owner").Interface().(string))
+
+	level, err := db.MySQLUserProjectPermissionLookup(projectID, "notloganga")
+	assert.NoError(t, err)
+	assert.Equal(t, config.OwnerLevel, level)
+	level, err = db.MySQLUserProjectPermissionLookup(projectID, "loganga")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["write"], level, "the former owner should be demoted to the chosen role")
+	name, _, _ := db.MySQLProjectLookup(projectID, "notloganga")
+	assert.Equal(t, "new stuff (loganga)", name)
+
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNotFound, resp.Status, "transfers should only be accepted once")
+}
+
+func TestProjectDeclineOwnershipRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	transfer := dbfs.OwnershipTransfer{
+		ProjectID:        projectID,
+		FormerOwner:      "loganga",
+		NewOwner:         "notloganga",
+		FormerOwnerLevel: config.PermissionsByLabel["write"],
+	}
+	db.MySQLProjectRequestTransfer(transfer)
+
+	req := projectDeclineOwnershipRequest{ProjectID: projectID}
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "DeclineOwnership"
+	req.SenderID = "notloganga"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, rabbitmq.RabbitUserQueueName("loganga"), closures[1].(toRabbitChannelClosure).key)
+	assert.Empty(t, db.Transfers)
+
+	// the owner can withdraw the transfer too
+	db.MySQLProjectRequestTransfer(transfer)
+	req.SenderID = "loganga"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, rabbitmq.RabbitUserQueueName("notloganga"), closures[1].(toRabbitChannelClosure).key)
+	assert.Empty(t, db.Transfers)
+}
+
+func TestProjectRestoreRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.ProjectIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "notloganga", config.PermissionsByLabel["write"], "loganga")
+	db.MySQLProjectTrash(projectID, "loganga")
+
+	req := projectRestoreRequest{ProjectID: projectID}
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "Restore"
+	req.SenderID = "notloganga"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNotFound, resp.Status, "only the owner should be able to restore the project")
+
+	req.SenderID = "loganga"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, rabbitmq.RabbitUserQueueName("notloganga"), closures[3].(toRabbitChannelClosure).key)
+	subscribe := closures[4].(rabbitCommandClosure)
+	assert.Equal(t, "Subscribe", subscribe.Command)
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(projectID), subscribe.Data.(rabbitmq.RabbitQueueData).Key)
+
+	level, err := db.MySQLUserProjectPermissionLookup(projectID, "notloganga")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["write"], level, "members should keep their permissions")
+	assert.Empty(t, db.TrashedProjects)
+}
+
+func TestProjectListTrashRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.ProjectIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	trashedProjectID, _ := db.MySQLProjectCreate("loganga", "old stuff")
+	db.MySQLProjectGrantPermission(projectID, "notloganga", config.PermissionsByLabel["read"], "loganga")
+	db.PathPermissions[projectID] = []dbfs.PathPermission{
+		{PathPrefix: "secrets", Username: "notloganga", PermissionLevel: 0},
+	}
+	publicFileID, _ := db.MySQLFileCreate("loganga", "main.go", ".", projectID)
+	secretFileID, _ := db.MySQLFileCreate("loganga", "keys.json", "secrets", projectID)
+	db.MySQLFileTrash(publicFileID, "loganga")
+	db.MySQLFileTrash(secretFileID, "loganga")
+	db.MySQLProjectTrash(trashedProjectID, "loganga")
+
+	req := projectListTrashRequest{}
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "ListTrash"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	projects := reflect.ValueOf(resp.Data).FieldByName("Projects").Interface().([]dbfs.TrashedProject)
+	if assert.Len(t, projects, 1) {
+		assert.Equal(t, trashedProjectID, projects[0].ProjectID)
+	}
+
+	req.ProjectID = projectID
+	req.SenderID = "notloganga"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	files := reflect.ValueOf(resp.Data).FieldByName("Files").Interface().([]dbfs.TrashedFile)
+	if assert.Len(t, files, 1, "files the sender can't read should not be listed") {
+		assert.Equal(t, publicFileID, files[0].FileID)
+		assert.Equal(t, "loganga", files[0].DeletedBy)
+	}
+
+	req.SenderID = "someone"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+}
+
+func TestProjectCreateShareLinkRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.ShareLinkIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "notloganga", config.PermissionsByLabel["write"], "loganga")
+
+	req := projectCreateShareLinkRequest{ProjectID: projectID, MaxUses: 2}
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "CreateShareLink"
+	req.SenderID = "notloganga"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+
+	req.SenderID = "loganga"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	shareLinkID := reflect.ValueOf(resp.Data).FieldByName("ShareLinkID").Interface().(int64)
+	token := reflect.ValueOf(resp.Data).FieldByName("Token").Interface().(string)
+
+	link := db.ShareLinks[shareLinkID]
+	assert.Equal(t, 2, link.MaxUses)
+	assert.WithinDuration(t, time.Now().Add(defaultShareLinkExpiryDays*24*time.Hour), link.ExpiryDate, time.Minute)
+	assert.Equal(t, shareLinkID, db.ShareLinkHashes[hashSecretToken(token)], "only the hash of the token should be stored")
+
+	req.ExpiryDays = maxShareLinkExpiryDays + 1
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "unexpected response status")
+}
+
+func TestProjectRedeemShareLinkRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.ShareLinkIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	shareLinkID, _ := db.MySQLShareLinkCreate(dbfs.ShareLink{
+		ProjectID: projectID,
+		CreatedBy: "loganga",
+		MaxUses:   1,
+	}, hashSecretToken("secret"), time.Hour)
+
+	req := projectRedeemShareLinkRequest{Token: "wrong"}
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "RedeemShareLink"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+
+	req.Token = "secret"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, projectID, reflect.ValueOf(resp.Data).FieldByName("ProjectID").Interface().(int64))
+	username := reflect.ValueOf(resp.Data).FieldByName("Username").Interface().(string)
+	token := reflect.ValueOf(resp.Data).FieldByName("Token").Interface().(string)
+
+	claims, err := parseToken(abstractRequest{SenderID: username, SenderToken: token})
+	assert.NoError(t, err)
+	assert.Equal(t, shareLinkID, claims.ShareLinkID, "the token should be scoped to the share link")
+	assert.False(t, time.Unix(claims.Validity, 0).After(db.ShareLinks[shareLinkID].ExpiryDate),
+		"the token should not outlive the share link")
+
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "links should not be redeemed more than MaxUses times")
+}
+
+func TestProjectRevokeShareLinkRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.ShareLinkIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "notloganga", config.PermissionsByLabel["read"], "loganga")
+	shareLinkID, _ := db.MySQLShareLinkCreate(dbfs.ShareLink{
+		ProjectID: projectID,
+		CreatedBy: "loganga",
+	}, hashSecretToken("secret"), time.Hour)
+
+	req := projectRevokeShareLinkRequest{ShareLinkID: shareLinkID}
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "RevokeShareLink"
+	req.SenderID = "notloganga"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+
+	req.SenderID = "loganga"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Empty(t, db.ShareLinks)
+}
+
+func TestProjectWebhookRequests_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	db.MySQLProjectGrantPermission(projectID, "notloganga", config.PermissionsByLabel["write"], "loganga")
+
+	add := projectAddWebhookRequest{
+		ProjectID: projectID,
+		URL:       "https://example.com/hook",
+		Events:    []string{"File.Create", "File.Create", "Project.Rename"},
+	}
+	setBaseFields(&add)
+	add.Resource = "Project"
+	add.Method = "AddWebhook"
+	add.SenderID = "notloganga"
+
+	closures, err := add.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+
+	add.SenderID = "loganga"
+	closures, err = add.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	webhookID := reflect.ValueOf(resp.Data).FieldByName("WebhookID").Interface().(int64)
+	secret := reflect.ValueOf(resp.Data).FieldByName("Secret").Interface().(string)
+	assert.NotEmpty(t, secret, "a secret should be generated if none is given")
+	subscribe := closures[1].(rabbitCommandClosure)
+	assert.Equal(t, "Subscribe", subscribe.Command)
+	assert.Equal(t, rabbitmq.RabbitWebhookQueueName, subscribe.Key)
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(projectID), subscribe.Data.(rabbitmq.RabbitQueueData).Key)
+	assert.Equal(t, "Project.AddWebhook", closures[2].(auditClosure).entry.Action)
+
+	webhook := db.Webhooks[webhookID]
+	assert.Equal(t, secret, webhook.Secret)
+	assert.Equal(t, []string{"File.Create", "Project.Rename"}, webhook.Events, "events should be deduplicated")
+
+	// invalid URLs, secrets and events are rejected
+	for _, invalid := range []projectAddWebhookRequest{
+		{URL: "ftp://example.com/hook"},
+		{URL: "/hook"},
+		{URL: "https://example.com/hook", Secret: strings.Repeat("s", maxWebhookSecretLength+1)},
+		{URL: "https://example.com/hook", Events: []string{"User.Login"}},
+	} {
+		invalid.ProjectID = projectID
+		invalid.abstractRequest = add.abstractRequest
+		closures, err = invalid.process(db)
+		assert.Nil(t, err)
+		resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+		assert.Equal(t, messages.StatusFail, resp.Status, "unexpected response status for %+v", invalid)
+	}
+	assert.Equal(t, 1, len(db.Webhooks))
+
+	db.MySQLWebhookDeliveryInsert(dbfs.WebhookDelivery{WebhookID: webhookID, Event: "File.Create", Attempts: 1, Success: true})
+
+	list := projectListWebhooksRequest{ProjectID: projectID}
+	setBaseFields(&list)
+	list.Resource = "Project"
+	list.Method = "ListWebhooks"
+	list.SenderID = "loganga"
+
+	closures, err = list.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	listed := reflect.ValueOf(resp.Data).FieldByName("Webhooks").Interface().([]webhookWithDeliveries)
+	assert.Equal(t, 1, len(listed))
+	assert.Equal(t, 1, len(listed[0].Deliveries))
+	listJSON, _ := json.Marshal(resp)
+	assert.NotContains(t, string(listJSON), secret, "secrets should not be listed")
+
+	remove := projectRemoveWebhookRequest{ProjectID: projectID, WebhookID: webhookID}
+	setBaseFields(&remove)
+	remove.Resource = "Project"
+	remove.Method = "RemoveWebhook"
+	remove.SenderID = "notloganga"
+
+	closures, err = remove.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+
+	remove.SenderID = "loganga"
+	closures, err = remove.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, "Project.RemoveWebhook", closures[1].(auditClosure).entry.Action)
+	assert.Equal(t, "Unsubscribe", closures[2].(rabbitCommandClosure).Command,
+		"the project's notifications should no longer be consumed once it has no webhooks")
+	assert.Empty(t, db.Webhooks)
+
+	closures, err = remove.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNotFound, resp.Status, "unexpected response status")
+}
+
+func TestProjectGetFilesRequest_ShareLink(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.ProjectIDCounter = 1
+	db.ShareLinkIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	otherProjectID, _ := db.MySQLProjectCreate("loganga", "other stuff")
+	db.MySQLFileCreate("loganga", "main.go", ".", projectID)
+	db.MySQLFileCreate("loganga", "keys.json", "secrets", projectID)
+	db.PathPermissions[projectID] = []dbfs.PathPermission{
+		{PathPrefix: "secrets", Username: "notloganga", PermissionLevel: 0},
+	}
+	shareLinkID, _ := db.MySQLShareLinkCreate(dbfs.ShareLink{
+		ProjectID: projectID,
+		CreatedBy: "loganga",
+	}, hashSecretToken("secret"), time.Hour)
+
+	req := projectGetFilesRequest{ProjectID: projectID}
+	setBaseFields(&req)
+	req.Resource = "Project"
+	req.Method = "GetFiles"
+	req.SenderID = "guest-1-00000000"
+	req.shareLinkID = shareLinkID
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	files := reflect.ValueOf(resp.Data).FieldByName("Files").Interface().([]fileLookupResult)
+	if assert.Len(t, files, 1, "guests should not see files with path permission rules") {
+		assert.Equal(t, "main.go", files[0].Filename)
+	}
+
+	req.ProjectID = otherProjectID
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "share links should only grant access to their project")
+
+	db.MySQLShareLinkDelete(shareLinkID)
+	req.ProjectID = projectID
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "revoked share links should no longer grant access")
+}
@@ -0,0 +1,119 @@
+package datahandling
+
+import (
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/modules/ratelimit"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+/**
+ * Rate limiting of requests, by websocket, by user, and by remote IP.
+ */
+
+// throttledResponseData is sent with a StatusTooManyRequests response
+type throttledResponseData struct {
+	// RetryAfter is the number of milliseconds the client should wait before retrying
+	RetryAfter int64
+}
+
+// precheck checks the request against the budgets of its websocket, remote IP, and claimed sender before it is
+// authenticated, returning true and the time the client should wait if it should be rejected. No tokens are taken,
+// so floods are rejected without looking up credentials, while a forged SenderID cannot use up another user's budget.
+func (dh DataHandler) precheck(req *abstractRequest) (bool, time.Duration) {
+	if dh.Limiter == nil {
+		return false, 0
+	}
+
+	keys := []string{rabbitmq.RabbitWebsocketQueueName(dh.WebsocketID)}
+	if dh.RemoteAddr != "" {
+		keys = append(keys, "IP-"+dh.RemoteAddr)
+	}
+	if _, unauthenticated := unauthenticatedRequestMap[req.Resource+"."+req.Method]; !unauthenticated && req.SenderID != "" {
+		keys = append(keys, "User-"+req.SenderID)
+	}
+
+	allowed, retryAfter := dh.Limiter.Check(rateBudgets(req, keys))
+	if !allowed {
+		dh.recordThrottled(req)
+	}
+	return !allowed, retryAfter
+}
+
+// throttle checks the authenticated request against the configured rate limits, returning true and the time the
+// client should wait if it should be rejected. Every request counts against its websocket; authenticated requests
+// also count against the sender, and unauthenticated (or failed) requests against the remote IP. Tokens are only
+// taken if every budget allows the request.
+// Websockets that are repeatedly throttled are disconnected.
+func (dh DataHandler) throttle(req *abstractRequest, authenticated bool) (bool, time.Duration) {
+	if dh.Limiter == nil {
+		return false, 0
+	}
+
+	keys := []string{rabbitmq.RabbitWebsocketQueueName(dh.WebsocketID)}
+	if authenticated {
+		keys = append(keys, "User-"+req.SenderID)
+	} else if dh.RemoteAddr != "" {
+		keys = append(keys, "IP-"+dh.RemoteAddr)
+	}
+
+	allowed, retryAfter := dh.Limiter.AllowAll(rateBudgets(req, keys))
+	if !allowed {
+		dh.recordThrottled(req)
+	}
+	return !allowed, retryAfter
+}
+
+// rateBudgets returns the budgets of the buckets for the request under each of the given keys: the default budget,
+// and the budget for its method, if one is configured
+func rateBudgets(req *abstractRequest, keys []string) map[string]ratelimit.Budget {
+	cfg := config.GetConfig().ServerConfig.RateLimits
+	method := req.Resource + "." + req.Method
+
+	budgets := make(map[string]ratelimit.Budget)
+	for _, key := range keys {
+		budgets[key] = ratelimit.Budget(cfg.Default)
+		if methodBudget, ok := cfg.Methods[method]; ok {
+			budgets[key+"|"+method] = ratelimit.Budget(methodBudget)
+		}
+	}
+	return budgets
+}
+
+// recordThrottled counts a throttled request against the websocket, disconnecting it once the abuse is sustained
+func (dh DataHandler) recordThrottled(req *abstractRequest) {
+	cfg := config.GetConfig().ServerConfig.RateLimits
+	if cfg.DisconnectAfter <= 0 {
+		return
+	}
+
+	// Each throttled request uses up a token; once they run out, the abuse is considered sustained.
+	abuseBudget := ratelimit.Budget{
+		Rate:  float64(cfg.DisconnectAfter) / time.Minute.Seconds(),
+		Burst: cfg.DisconnectAfter,
+	}
+	if allowed, _ := dh.Limiter.Allow(rabbitmq.RabbitWebsocketQueueName(dh.WebsocketID)+"|throttled", abuseBudget); !allowed {
+		utils.LogWarn("Disconnecting websocket for sustained abuse", utils.LogFields{
+			"WebsocketID": dh.WebsocketID,
+			"RemoteAddr":  dh.RemoteAddr,
+			"SenderID":    req.SenderID,
+		})
+		if dh.Disconnect != nil {
+			dh.Disconnect()
+		}
+	}
+}
+
+// newThrottledResponse creates the response sent when a request has been throttled
+func newThrottledResponse(tag int64, retryAfter time.Duration) *messages.ServerMessageWrapper {
+	return messages.Response{
+		Status: messages.StatusTooManyRequests,
+		Tag:    tag,
+		Data: throttledResponseData{
+			RetryAfter: int64(retryAfter / time.Millisecond),
+		},
+	}.Wrap()
+}
@@ -0,0 +1,118 @@
+package datahandling
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/modules/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+// handleAndGetStatus handles the given request, returning the status of the response sent back to the sender
+func handleAndGetStatus(t *testing.T, dh DataHandler, messageChan chan rabbitmq.AMQPMessage, message string) int {
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	dh.Handle(1, []byte(message), wg)
+
+	select {
+	case msg := <-messageChan:
+		response := struct {
+			ServerMessage struct {
+				Status int
+			}
+		}{}
+		if err := json.Unmarshal(msg.Message, &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.ServerMessage.Status
+	default:
+		t.Fatal("No response was sent")
+	}
+	return 0
+}
+
+func TestDataHandler_Throttle(t *testing.T) {
+	configSetup(t)
+	cfg := config.GetConfig()
+	oldLimits := cfg.ServerConfig.RateLimits
+	defer func() {
+		cfg.ServerConfig.RateLimits = oldLimits
+	}()
+	defer disableLoginProtection()()
+	cfg.ServerConfig.RateLimits = config.RateLimitCfg{
+		Default: config.RateBudget{Rate: 0.001, Burst: 10},
+		Methods: map[string]config.RateBudget{
+			"Project.GetPermissionConstants": {Rate: 0.001, Burst: 2},
+		},
+		DisconnectAfter: 2,
+	}
+
+	disconnected := false
+	messageChan := make(chan rabbitmq.AMQPMessage, 1)
+	db := dbfs.NewDBMock()
+	dh := DataHandler{
+		MessageChan: messageChan,
+		WebsocketID: 1,
+		Db:          db,
+		RemoteAddr:  "127.0.0.1",
+		Limiter:     ratelimit.NewLimiter(),
+		Disconnect: func() {
+			disconnected = true
+		},
+	}
+
+	request := `{"Tag": 1, "Resource": "Project", "Method": "GetPermissionConstants", "SenderID": "loganga", "SenderToken": "` +
+		testToken(t, "loganga") + `", "Data": {}}`
+
+	assert.Equal(t, messages.StatusSuccess, handleAndGetStatus(t, dh, messageChan, request))
+	assert.Equal(t, messages.StatusSuccess, handleAndGetStatus(t, dh, messageChan, request))
+	calls := db.FunctionCallCount
+	assert.Equal(t, messages.StatusTooManyRequests, handleAndGetStatus(t, dh, messageChan, request), "Method budget should be exhausted")
+	assert.Equal(t, calls, db.FunctionCallCount, "Throttled requests should be rejected before they are authenticated")
+
+	// a different socket for the same user shares the user's budget
+	otherSocket := dh
+	otherSocket.WebsocketID = 2
+	assert.Equal(t, messages.StatusTooManyRequests, handleAndGetStatus(t, otherSocket, messageChan, request), "User budget should be shared across sockets")
+	assert.False(t, disconnected)
+
+	assert.Equal(t, messages.StatusTooManyRequests, handleAndGetStatus(t, dh, messageChan, request))
+	assert.False(t, disconnected, "Socket should tolerate DisconnectAfter throttled requests")
+	assert.Equal(t, messages.StatusTooManyRequests, handleAndGetStatus(t, dh, messageChan, request))
+	assert.True(t, disconnected, "Socket should be disconnected after repeated throttling")
+
+	// unauthenticated requests count against the remote IP, regardless of socket
+	login := `{"Tag": 2, "Resource": "User", "Method": "Login", "Data": {"Username": "loganga", "Password": "wrong"}}`
+	for i := uint64(0); i < 8; i++ {
+		socket := dh
+		socket.WebsocketID = 10 + i
+		assert.Equal(t, messages.StatusUnauthorized, handleAndGetStatus(t, socket, messageChan, login))
+	}
+	socket := dh
+	socket.WebsocketID = 20
+	assert.Equal(t, messages.StatusUnauthorized, handleAndGetStatus(t, socket, messageChan, login))
+	assert.Equal(t, messages.StatusUnauthorized, handleAndGetStatus(t, socket, messageChan, login))
+	assert.Equal(t, messages.StatusTooManyRequests, handleAndGetStatus(t, socket, messageChan, login), "IP budget should be shared across sockets")
+}
+
+func TestDataHandler_ThrottleDisabled(t *testing.T) {
+	configSetup(t)
+
+	messageChan := make(chan rabbitmq.AMQPMessage, 1)
+	dh := DataHandler{
+		MessageChan: messageChan,
+		WebsocketID: 1,
+		Db:          dbfs.NewDBMock(),
+	}
+	request := `{"Tag": 1, "Resource": "Project", "Method": "GetPermissionConstants", "SenderID": "loganga", "SenderToken": "` +
+		testToken(t, "loganga") + `", "Data": {}}`
+
+	for i := 0; i < 200; i++ {
+		assert.Equal(t, messages.StatusSuccess, handleAndGetStatus(t, dh, messageChan, request), "Requests should not be limited without a limiter")
+	}
+}
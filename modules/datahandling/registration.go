@@ -0,0 +1,177 @@
+package datahandling
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/mail"
+)
+
+/**
+ * Registration holds the email verification and password rules for users registering with User.Register.
+ */
+
+// maxPasswordBytes is the length bcrypt truncates passwords to
+const maxPasswordBytes = 72
+
+// mailer sends the emails queued by requests as mailClosures; set by ConfigureRegistration
+var mailer mail.Mailer = mail.LogMailer{}
+
+// ConfigureRegistration checks the registration configuration, and sets the Mailer used to send emails to the
+// configured backend
+func ConfigureRegistration(cfg config.ServerCfg) error {
+	switch cfg.Registration.UnverifiedPolicy {
+	case "", config.UnverifiedAllow, config.UnverifiedBlockProjects, config.UnverifiedBlockLogin:
+	default:
+		return fmt.Errorf("Unknown unverified user policy %q", cfg.Registration.UnverifiedPolicy)
+	}
+	if _, err := cfg.Registration.VerificationValidityDuration(); err != nil {
+		return err
+	}
+	url := cfg.Registration.VerificationURL
+	if url != "" && (strings.Count(url, "%s") != 1 || strings.Count(url, "%") != 1) {
+		return fmt.Errorf("VerificationURL must contain %%s exactly once, got %q", url)
+	}
+
+	configured, err := mail.NewMailer(cfg.Mail)
+	if err != nil {
+		return err
+	}
+	mailer = configured
+	return nil
+}
+
+// checkPasswordPolicy returns an error describing the first rule of the policy the password breaks, or nil if it
+// meets all of them
+func checkPasswordPolicy(policy config.PasswordPolicyCfg, username string, password string) error {
+	minLength := policy.MinLength
+	if minLength == 0 {
+		minLength = config.DefaultPasswordMinLength
+	}
+	if len([]rune(password)) < minLength {
+		return fmt.Errorf("Passwords must be at least %d characters long", minLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("Passwords cannot be longer than %d bytes", maxPasswordBytes)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c) || unicode.IsSpace(c):
+			symbol = true
+		}
+	}
+	switch {
+	case policy.RequireUpper && !upper:
+		return errors.New("Passwords must contain an uppercase letter")
+	case policy.RequireLower && !lower:
+		return errors.New("Passwords must contain a lowercase letter")
+	case policy.RequireDigit && !digit:
+		return errors.New("Passwords must contain a digit")
+	case policy.RequireSymbol && !symbol:
+		return errors.New("Passwords must contain a symbol")
+	case policy.RejectUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)):
+		return errors.New("Passwords cannot contain the username")
+	}
+	return nil
+}
+
+// unverifiedBlocked returns true if the configured UnverifiedPolicy stops unverified users from doing the given
+// action, which is one of config.UnverifiedBlockProjects or config.UnverifiedBlockLogin
+func unverifiedBlocked(action string) bool {
+	switch config.GetConfig().ServerConfig.Registration.UnverifiedPolicy {
+	case config.UnverifiedBlockLogin:
+		// users who cannot log in cannot create projects either
+		return true
+	case config.UnverifiedBlockProjects:
+		return action == config.UnverifiedBlockProjects
+	default:
+		return false
+	}
+}
+
+// isUnverified returns true if the user has not yet verified their email address
+func isUnverified(db dbfs.DBFS, username string) (bool, error) {
+	user, err := db.MySQLUserLookup(username)
+	if err != nil {
+		return false, err
+	}
+	return user.Status == dbfs.UserStatusPending, nil
+}
+
+// newEmailVerification creates a verification token for the pending user, returning the closure which emails it to
+// them
+func newEmailVerification(db dbfs.DBFS, user dbfs.UserMeta) (dhClosure, error) {
+	validity, err := config.GetConfig().ServerConfig.Registration.VerificationValidityDuration()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	err = db.MySQLEmailVerificationCreate(user.Username, hashSecretToken(token), validity)
+	if err != nil {
+		return nil, err
+	}
+	return verificationMail(user, token, validity), nil
+}
+
+// newEmailChangeVerification creates a verification token for a new email address of the user, returning the closure
+// which emails it to the new address. The user's address is only changed once the token is redeemed.
+func newEmailChangeVerification(db dbfs.DBFS, user dbfs.UserMeta, email string) (dhClosure, error) {
+	validity, err := config.GetConfig().ServerConfig.Registration.VerificationValidityDuration()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	err = db.MySQLEmailChangeCreate(user.Username, email, hashSecretToken(token), validity)
+	if err != nil {
+		return nil, err
+	}
+	user.Email = email
+	return verificationMail(user, token, validity), nil
+}
+
+// verificationMail returns the closure which emails the verification token to the user's email address
+func verificationMail(user dbfs.UserMeta, token string, validity time.Duration) dhClosure {
+	cfg := config.GetConfig().ServerConfig
+	name := user.FirstName
+	if name == "" {
+		name = user.Username
+	}
+	instructions := "enter this verification token in your client"
+	link := token
+	if cfg.Registration.VerificationURL != "" {
+		instructions = "open this link"
+		link = fmt.Sprintf(cfg.Registration.VerificationURL, token)
+	}
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"To verify the email address of your %s account, %s within %s:\n\n"+
+		"%s\n\n"+
+		"If you did not ask for this, you can ignore this email.\n",
+		name, cfg.Name, instructions, validity, link)
+
+	return mailClosure{msg: mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address for " + cfg.Name,
+		Body:    body,
+	}}
+}
@@ -0,0 +1,82 @@
+package datahandling
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/mail"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMailer records the emails it is asked to send
+type fakeMailer struct {
+	sent []mail.Message
+}
+
+func (m *fakeMailer) Send(msg mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	strict := config.PasswordPolicyCfg{
+		MinLength:      10,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSymbol:  true,
+		RejectUsername: true,
+	}
+
+	tests := []struct {
+		desc     string
+		policy   config.PasswordPolicyCfg
+		password string
+		valid    bool
+	}{
+		{desc: "Default length", password: "abcdefgh", valid: true},
+		{desc: "Shorter than the default length", password: "abcdefg"},
+		{desc: "Length counts characters", policy: config.PasswordPolicyCfg{MinLength: 4}, password: "äöüß", valid: true},
+		{desc: "Longer than bcrypt allows", password: strings.Repeat("a", 73)},
+		{desc: "Meets every rule", policy: strict, password: "Correct-h0rse", valid: true},
+		{desc: "Too short", policy: strict, password: "Co-h0rse"},
+		{desc: "No uppercase", policy: strict, password: "correct-h0rse"},
+		{desc: "No lowercase", policy: strict, password: "CORRECT-H0RSE"},
+		{desc: "No digit", policy: strict, password: "Correct-horse"},
+		{desc: "No symbol", policy: strict, password: "Correcth0rse"},
+		{desc: "Contains the username", policy: strict, password: "Loganga-h0rse"},
+	}
+
+	for _, test := range tests {
+		err := checkPasswordPolicy(test.policy, "loganga", test.password)
+		if test.valid {
+			assert.NoError(t, err, test.desc)
+		} else {
+			assert.Error(t, err, test.desc)
+		}
+	}
+}
+
+func TestConfigureRegistration(t *testing.T) {
+	defer ConfigureRegistration(config.ServerCfg{})
+
+	assert.NoError(t, ConfigureRegistration(config.ServerCfg{
+		Registration: config.RegistrationCfg{
+			UnverifiedPolicy: config.UnverifiedBlockLogin,
+			VerificationURL:  "https://example.com/verify?token=%s",
+		},
+	}))
+	assert.IsType(t, mail.LogMailer{}, mailer)
+
+	assert.Error(t, ConfigureRegistration(config.ServerCfg{
+		Registration: config.RegistrationCfg{UnverifiedPolicy: "block-everything"},
+	}))
+	assert.Error(t, ConfigureRegistration(config.ServerCfg{
+		Registration: config.RegistrationCfg{VerificationValidity: "a day"},
+	}))
+	assert.Error(t, ConfigureRegistration(config.ServerCfg{
+		Registration: config.RegistrationCfg{VerificationURL: "https://example.com/verify?user=%s&token=%s"},
+	}))
+	assert.Error(t, ConfigureRegistration(config.ServerCfg{Mail: config.MailCfg{Backend: "carrier-pigeon"}}))
+}
@@ -2,8 +2,10 @@ package datahandling
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
 )
 
 /**
@@ -20,19 +22,52 @@ func init() {
 	initProjectRequests()
 	initUserRequests()
 	initFileRequests()
+	initGroupRequests()
+	initInviteRequests()
+	initCommentRequests()
+	initSuggestionRequests()
 }
 
-func getFullRequest(req *abstractRequest) (request, error) {
+func getFullRequest(req *abstractRequest, db dbfs.DBFS) (request, error) {
 	if _, contains := unauthenticatedRequestMap[(*req).Resource+"."+(*req).Method]; contains {
 		// unauthenticated request
 		return unauthenticatedRequest(req)
 	}
 
 	// authenticated request
-	if config.GetConfig().ServerConfig.DisableAuth || authenticate(*req) == nil {
+	if config.GetConfig().ServerConfig.DisableAuth {
 		return authenticatedRequest(req)
 	}
-	return nil, ErrAuthenticationFailed
+	if strings.HasPrefix(req.SenderToken, accessTokenPrefix) {
+		scopes, err := authenticateAccessToken(*req, db)
+		if err != nil {
+			return nil, ErrAuthenticationFailed
+		}
+		req.accessToken = true
+		fullRequest, err := authenticatedRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkAccessTokenProjects(fullRequest, scopes, db); err != nil {
+			return nil, ErrAuthenticationFailed
+		}
+		return fullRequest, nil
+	}
+	claims, err := parseToken(*req)
+	if err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+	if err := checkNotRenamed(db, claims); err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+	if claims.ShareLinkID != 0 {
+		// guests using a share link can only read the project
+		if !shareLinkRequests[req.Resource+"."+req.Method] {
+			return nil, ErrAuthenticationFailed
+		}
+		req.shareLinkID = claims.ShareLinkID
+	}
+	return authenticatedRequest(req)
 }
 
 // authenticatedRequest returns fully parsed Request from the given authenticated AbstractRequest
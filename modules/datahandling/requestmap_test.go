@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/CodeCollaborate/Server/modules/dbfs"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -27,7 +29,7 @@ func TestProjectCreateRequest(t *testing.T) {
 		"\"Name\": \"Namey\"" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -48,7 +50,7 @@ func TestProjectRenameRequest(t *testing.T) {
 		"\"ProjectID\": 12345" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -66,7 +68,7 @@ func TestProjectGetPermissionConstantsRequest(t *testing.T) {
 	req.SenderToken = testToken(t, TestSenderID)
 	req.Data = json.RawMessage("{}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -88,7 +90,7 @@ func TestProjectGrantPermissionsRequest(t *testing.T) {
 		"\"PermissionLevel\": 1" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -109,7 +111,7 @@ func TestProjectRevokePermissionsRequest(t *testing.T) {
 		"\"RevokeUsername\": \"loganga\"" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -129,7 +131,7 @@ func TestProjectGetOnlineClientsRequest(t *testing.T) {
 		"\"ProjectID\": 12345" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -146,7 +148,7 @@ func TestProjectLookupRequest(t *testing.T) {
 	req.SenderID = TestSenderID
 	req.SenderToken = testToken(t, TestSenderID)
 	req.Data = json.RawMessage("{\"ProjectIds\": [12345, 38292]}")
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -166,7 +168,7 @@ func TestProjectGetFilesRequest(t *testing.T) {
 		"\"ProjectID\": 12345" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -186,7 +188,7 @@ func TestProjectSubscribeRequest(t *testing.T) {
 		"\"ProjectID\": 12345" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -206,7 +208,7 @@ func TestProjectUnsubscribeRequest(t *testing.T) {
 		"\"ProjectID\": 12345" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -226,7 +228,7 @@ func TestProjectDeleteRequest(t *testing.T) {
 		"\"ProjectID\": 12345" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -236,6 +238,251 @@ func TestProjectDeleteRequest(t *testing.T) {
 	}
 }
 
+func TestProjectGetAuditLogRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "GetAuditLog"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{" +
+		"\"ProjectID\": 12345, " +
+		"\"Action\": \"Project.Rename\", " +
+		"\"Since\": \"2016-01-02T15:04:05Z\", " +
+		"\"Limit\": 10" +
+		"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectGetAuditLogRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+	assert.Equal(t, 2016, newRequest.(*projectGetAuditLogRequest).Since.Year())
+}
+
+func TestProjectSetRoleRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "SetRole"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{" +
+		"\"ProjectID\": 12345, " +
+		"\"Name\": \"reviewer\", " +
+		"\"Level\": 20, " +
+		"\"Capabilities\": [\"read\", \"comment\"]" +
+		"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectSetRoleRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestProjectAddWebhookRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "AddWebhook"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{" +
+		"\"ProjectID\": 12345, " +
+		"\"URL\": \"https://example.com/hook\", " +
+		"\"Events\": [\"File.Create\", \"File.Delete\"]" +
+		"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectAddWebhookRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+	assert.Equal(t, []string{"File.Create", "File.Delete"}, newRequest.(*projectAddWebhookRequest).Events)
+}
+
+func TestProjectListWebhooksRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "ListWebhooks"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ProjectID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectListWebhooksRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestProjectRemoveWebhookRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "RemoveWebhook"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ProjectID\": 12345, \"WebhookID\": 3}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectRemoveWebhookRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestProjectDeleteRoleRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "DeleteRole"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{" +
+		"\"ProjectID\": 12345, " +
+		"\"Level\": 20" +
+		"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectDeleteRoleRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestProjectGetPathPermissionsRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "GetPathPermissions"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{" +
+		"\"ProjectID\": 12345" +
+		"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectGetPathPermissionsRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestProjectSetPathPermissionsRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "SetPathPermissions"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{" +
+		"\"ProjectID\": 12345, " +
+		"\"Rules\": [{\"PathPrefix\": \"backend/\", \"Username\": \"contractor\", \"PermissionLevel\": 1}]" +
+		"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectSetPathPermissionsRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+	if len(newRequest.(*projectSetPathPermissionsRequest).Rules) != 1 {
+		t.Fatal("rules were not parsed")
+	}
+}
+
+// Group functions
+
+func TestGroupCreateRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Group"
+	req.Method = "Create"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"Name\": \"backend team\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.groupCreateRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestGroupAddMemberRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Group"
+	req.Method = "AddMember"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"GroupID\": 12345, \"Username\": \"notloganga\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.groupAddMemberRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestGroupRemoveMemberRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Group"
+	req.Method = "RemoveMember"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"GroupID\": 12345, \"Username\": \"notloganga\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.groupRemoveMemberRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestGroupListRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Group"
+	req.Method = "List"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.groupListRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
 // File functions
 
 func TestFileCreateRequest(t *testing.T) {
@@ -251,7 +498,7 @@ func TestFileCreateRequest(t *testing.T) {
 		"\"FileBytes\": [2]" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -272,7 +519,7 @@ func TestFileRenameRequest(t *testing.T) {
 		"\"FileID\": 12345" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -293,7 +540,7 @@ func TestFileMoveRequest(t *testing.T) {
 		"\"FileID\": 12345" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -313,7 +560,7 @@ func TestFileDeleteRequest(t *testing.T) {
 		"\"FileID\": 12345" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -335,7 +582,7 @@ func TestFileChangeRequest(t *testing.T) {
 		"\"Changes\": \"ok\"" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -355,7 +602,7 @@ func TestFilePullRequest(t *testing.T) {
 		"\"FileID\": 12345" +
 		"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -365,6 +612,44 @@ func TestFilePullRequest(t *testing.T) {
 	}
 }
 
+func TestFileLockRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "File"
+	req.Method = "Lock"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"FileID\": 12345, \"Enforced\": true}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.fileLockRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+	assert.True(t, newRequest.(*fileLockRequest).Enforced)
+}
+
+func TestFileUnlockRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "File"
+	req.Method = "Unlock"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"FileID\": 12345, \"Force\": true}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.fileUnlockRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+	assert.True(t, newRequest.(*fileUnlockRequest).Force)
+}
+
 // User functions
 
 func TestUserLookupRequest(t *testing.T) {
@@ -376,7 +661,7 @@ func TestUserLookupRequest(t *testing.T) {
 	req.Data = json.RawMessage(
 		"{\"Usernames\": [\"jshap70\"]" +
 			"}")
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -386,6 +671,23 @@ func TestUserLookupRequest(t *testing.T) {
 	}
 }
 
+func TestUserSearchRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "Search"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"Query\": \"gene\", \"ProjectID\": 12345, \"Limit\": 10}")
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userSearchRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
 func TestUserProjectsRequest(t *testing.T) {
 	req := *new(abstractRequest)
 	req.Resource = "User"
@@ -393,7 +695,7 @@ func TestUserProjectsRequest(t *testing.T) {
 	req.SenderID = TestSenderID
 	req.SenderToken = testToken(t, TestSenderID)
 	req.Data = json.RawMessage("{}")
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -410,7 +712,7 @@ func TestUserDeleteRequest(t *testing.T) {
 	req.SenderID = TestSenderID
 	req.SenderToken = testToken(t, TestSenderID)
 	req.Data = json.RawMessage("{}")
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	assert.Nil(t, err, "error getting User.Delete request")
 
 	assert.IsType(t, &userDeleteRequest{}, newRequest, "returned wrong request type")
@@ -434,7 +736,7 @@ func TestUserRegisterRequest(t *testing.T) {
 			"\"Password\":\"correct horse battery staple\"" +
 			"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -453,7 +755,7 @@ func TestUserLoginRequest(t *testing.T) {
 			"\"Password\":\"correct horse battery staple\"" +
 			"}")
 
-	newRequest, err := getFullRequest(&req)
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -462,3 +764,733 @@ func TestUserLoginRequest(t *testing.T) {
 		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
 	}
 }
+
+// Invite functions
+
+func TestProjectInviteRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "Invite"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ProjectID\": 12345, \"Username\": \"notloganga\", \"PermissionLevel\": 1}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectInviteRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserGetInvitesRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "GetInvites"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userGetInvitesRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestInviteAcceptRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Invite"
+	req.Method = "Accept"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"InviteID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.inviteAcceptRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestInviteDeclineRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Invite"
+	req.Method = "Decline"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"InviteID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.inviteDeclineRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestInviteRevokeRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Invite"
+	req.Method = "Revoke"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"InviteID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.inviteRevokeRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+// Ownership transfer functions
+
+func TestProjectTransferOwnershipRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "TransferOwnership"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ProjectID\": 12345, \"NewOwner\": \"notloganga\", \"FormerOwnerLevel\": 4}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectTransferOwnershipRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestProjectAcceptOwnershipRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "AcceptOwnership"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ProjectID\": 12345, \"NewName\": \"renamed\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectAcceptOwnershipRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestProjectDeclineOwnershipRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "DeclineOwnership"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ProjectID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectDeclineOwnershipRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+// Trash functions
+
+func TestProjectRestoreRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "Restore"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ProjectID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectRestoreRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestProjectListTrashRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "ListTrash"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ProjectID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectListTrashRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestFileRestoreRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "File"
+	req.Method = "Restore"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"FileID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.fileRestoreRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+// Share link functions
+
+func TestProjectCreateShareLinkRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "CreateShareLink"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ProjectID\": 12345, \"ExpiryDays\": 1, \"MaxUses\": 10}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectCreateShareLinkRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestProjectRevokeShareLinkRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "RevokeShareLink"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ShareLinkID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectRevokeShareLinkRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestProjectGetShareLinksRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "GetShareLinks"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ProjectID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectGetShareLinksRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestProjectRedeemShareLinkRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Project"
+	req.Method = "RedeemShareLink"
+	req.Data = json.RawMessage("{\"Token\": \"abcdef\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.projectRedeemShareLinkRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestShareLinkTokenScope(t *testing.T) {
+	configSetup(t)
+	token, err := newShareLinkToken("guest-1-00000000", dbfs.ShareLink{
+		ShareLinkID: 1,
+		ExpiryDate:  time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	req := *new(abstractRequest)
+	req.Resource = "File"
+	req.Method = "Pull"
+	req.SenderID = "guest-1-00000000"
+	req.SenderToken = token
+	req.Data = json.RawMessage("{\"FileID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if assert.NoError(t, err, "share link tokens should be accepted for read requests") {
+		assert.Equal(t, int64(1), newRequest.(*filePullRequest).shareLinkID)
+	}
+
+	req.Method = "Change"
+	req.Data = json.RawMessage("{\"FileID\": 12345, \"BaseFileVersion\": 0, \"Changes\": []}")
+	_, err = getFullRequest(&req, dbfs.NewDBMock())
+	assert.Equal(t, ErrAuthenticationFailed, err, "share link tokens should be rejected for writes")
+}
+
+// Access token functions
+
+func TestUserRegisterBotRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "RegisterBot"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"Username\": \"formatter\", \"FirstName\": \"Format\", \"LastName\": \"Bot\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userRegisterBotRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserCreateTokenRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "CreateToken"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"Name\": \"ci\", \"Scopes\": [\"read\"], \"ExpiryDays\": 90}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userCreateTokenRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserListTokensRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "ListTokens"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userListTokensRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserRevokeTokenRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "RevokeToken"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"TokenID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userRevokeTokenRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserEnable2FARequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "Enable2FA"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userEnable2FARequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserConfirm2FARequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "Confirm2FA"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"Code\": \"123456\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userConfirm2FARequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserDisable2FARequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "Disable2FA"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"Password\": \"secret\", \"Code\": \"123456\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userDisable2FARequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserVerify2FARequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "Verify2FA"
+	req.SenderID = TestSenderID
+	req.Data = json.RawMessage("{\"Challenge\": \"challenge\", \"Code\": \"123456\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userVerify2FARequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserVerifyEmailRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "VerifyEmail"
+	req.Data = json.RawMessage("{\"Token\": \"token\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userVerifyEmailRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserResendVerificationRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "ResendVerification"
+	req.Data = json.RawMessage("{\"Username\": \"loganga\", \"Password\": \"secret\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userResendVerificationRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserUpdateRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "Update"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"FirstName\": \"Gene\", \"LastName\": \"Logan\", \"Email\": \"gene@example.com\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userUpdateRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserRenameRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "Rename"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"NewUsername\": \"genelogan\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userRenameRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserGetNotificationsRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "GetNotifications"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"UnreadOnly\": true, \"Offset\": 10, \"Limit\": 10}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userGetNotificationsRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserMarkReadRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "MarkRead"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"NotificationIDs\": [1, 2]}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userMarkReadRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestUserUnlockRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "Unlock"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"Username\": \"loganga\", \"IP\": \"10.0.0.1\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.userUnlockRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestAccessTokenRequest(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.MySQLAccessTokenCreate(dbfs.AccessToken{Username: TestSenderID, Name: "ci", Scopes: []string{"read"}},
+		hashSecretToken(accessTokenPrefix+"secret"), 0)
+
+	req := *new(abstractRequest)
+	req.Resource = "User"
+	req.Method = "Projects"
+	req.SenderID = TestSenderID
+	req.SenderToken = accessTokenPrefix + "secret"
+	req.Data = json.RawMessage("{}")
+
+	newRequest, err := getFullRequest(&req, db)
+	if assert.NoError(t, err, "access tokens should be accepted") {
+		assert.Equal(t, "*datahandling.userProjectsRequest", reflect.TypeOf(newRequest).String())
+	}
+
+	req.Resource = "Project"
+	req.Method = "Create"
+	req.Data = json.RawMessage("{\"Name\": \"Namey\"}")
+	_, err = getFullRequest(&req, db)
+	assert.Equal(t, ErrAuthenticationFailed, err, "read-only access tokens should be rejected for writes")
+}
+
+func TestCommentCreateRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Comment"
+	req.Method = "Create"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{" +
+		"\"FileID\": 12345, " +
+		"\"StartIndex\": 5, " +
+		"\"EndIndex\": 10, " +
+		"\"FileVersion\": 3, " +
+		"\"Body\": \"hi @loganga\"" +
+		"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.commentCreateRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+	assert.Equal(t, 10, newRequest.(*commentCreateRequest).EndIndex)
+	assert.EqualValues(t, 3, newRequest.(*commentCreateRequest).FileVersion)
+}
+
+func TestCommentReplyRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Comment"
+	req.Method = "Reply"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ThreadID\": 12345, \"Body\": \"agreed\"}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.commentReplyRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestCommentResolveRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Comment"
+	req.Method = "Resolve"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"ThreadID\": 12345, \"Reopen\": true}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.commentResolveRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+	assert.True(t, newRequest.(*commentResolveRequest).Reopen)
+}
+
+func TestCommentListRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Comment"
+	req.Method = "List"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"FileID\": 12345, \"IncludeResolved\": true}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.commentListRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestSuggestionAcceptRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Suggestion"
+	req.Method = "Accept"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"SuggestionID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.suggestionAcceptRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+	assert.EqualValues(t, 12345, newRequest.(*suggestionAcceptRequest).SuggestionID)
+}
+
+func TestSuggestionRejectRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Suggestion"
+	req.Method = "Reject"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"SuggestionID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.suggestionRejectRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
+
+func TestSuggestionListRequest(t *testing.T) {
+	req := *new(abstractRequest)
+	req.Resource = "Suggestion"
+	req.Method = "List"
+	req.SenderID = TestSenderID
+	req.SenderToken = testToken(t, TestSenderID)
+	req.Data = json.RawMessage("{\"FileID\": 12345}")
+
+	newRequest, err := getFullRequest(&req, dbfs.NewDBMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(newRequest).String() != "*datahandling.suggestionListRequest" {
+		t.Fatalf("wrong request type, got: %s", reflect.TypeOf(newRequest))
+	}
+}
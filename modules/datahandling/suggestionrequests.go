@@ -0,0 +1,329 @@
+package datahandling
+
+import (
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/patching"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+var suggestionRequestsSetup = false
+
+// initSuggestionRequests populates the requestMap from requestmap.go with the appropriate constructors for the suggestion methods
+func initSuggestionRequests() {
+	if suggestionRequestsSetup {
+		return
+	}
+
+	authenticatedRequestMap["Suggestion.Accept"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(suggestionAcceptRequest), req)
+	}
+
+	authenticatedRequestMap["Suggestion.Reject"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(suggestionRejectRequest), req)
+	}
+
+	authenticatedRequestMap["Suggestion.List"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(suggestionListRequest), req)
+	}
+
+	suggestionRequestsSetup = true
+}
+
+// suggest stores the changes of a File.Change request from a suggester as a pending suggestion, rebased onto the
+// latest version of the file
+func (f fileChangeRequest) suggest(db dbfs.DBFS, fileMeta dbfs.FileMeta, notifyKeys []string) ([]dhClosure, error) {
+	patch, err := patching.NewPatchFromString(f.Changes)
+	if err != nil || len(patch.Changes) == 0 {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, "Suggestions must be valid, non-empty patches")}}, nil
+	}
+
+	changes, _, version, _, err := db.PullChanges(fileMeta)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+	if patch.BaseVersion > version {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusVersionOutOfDate, f.Tag)}}, nil
+	}
+	if patch.BaseVersion < version {
+		patches, err := patching.GetPatches(changes)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+		}
+		patch, err = dbfs.RebaseSuggestion(patch, patches)
+		if err == dbfs.ErrVersionOutOfDate {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusVersionOutOfDate, f.Tag)}}, nil
+		} else if err != nil {
+			return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, "Suggestions must be valid, non-empty patches")}}, nil
+		}
+	}
+
+	suggestion := dbfs.Suggestion{
+		FileID:      f.FileID,
+		Author:      f.SenderID,
+		Patch:       patch.String(),
+		FileVersion: patch.BaseVersion,
+	}
+	suggestion.SuggestionID, err = db.MySQLSuggestionCreate(suggestion)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			Suggestion dbfs.Suggestion
+		}{
+			Suggestion: suggestion,
+		},
+	}.Wrap()
+	not := messages.Notification{
+		Resource:   "Suggestion",
+		Method:     "Create",
+		ResourceID: f.FileID,
+		Data: struct {
+			Suggestion dbfs.Suggestion
+		}{
+			Suggestion: suggestion,
+		},
+	}.Wrap()
+
+	return append([]dhClosure{toSenderClosure{msg: res}}, toRabbitChannelClosures(not, notifyKeys)...), nil
+}
+
+// suggestionReviewed notifies the file's subscribers that the suggestion was accepted or rejected. The notification
+// is also stored in the inbox of its author, so that they learn of it even if they were offline.
+func suggestionReviewed(not *messages.ServerMessageWrapper, suggestion dbfs.Suggestion, reviewer string, notifyKeys []string) []dhClosure {
+	closures := toRabbitChannelClosures(not, notifyKeys)
+	if suggestion.Author != reviewer {
+		closures = append(closures, inboxClosure{msg: not, username: suggestion.Author})
+	}
+	return closures
+}
+
+// Suggestion.Accept
+type suggestionAcceptRequest struct {
+	SuggestionID int64
+	abstractRequest
+}
+
+func (s *suggestionAcceptRequest) setAbstractRequest(req *abstractRequest) {
+	s.abstractRequest = *req
+}
+
+func (s suggestionAcceptRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return suggestionProjectIDs(db, s.SuggestionID)
+}
+
+func (s suggestionAcceptRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	suggestion, err := db.MySQLSuggestionGet(s.SuggestionID)
+	if err == dbfs.ErrNoData {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, s.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, s.Tag)}}, err
+	}
+	fileMeta, err := db.MySQLFileGetInfo(suggestion.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, s.Tag)}}, nil
+	}
+
+	access, err := dbfs.NewFileAccess(s.SenderID, fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityWrite) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  s.Resource,
+			"Method":    s.Method,
+			"SenderID":  s.SenderID,
+			"ProjectID": fileMeta.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, s.Tag)}}, nil
+	}
+
+	notifyKeys, err := fileNotificationKeys(access, fileMeta)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, s.Tag)}}, err
+	}
+
+	if rejection, err := checkFileLock(db, fileMeta.FileID, s.socket, s.Tag); rejection != nil {
+		return []dhClosure{toSenderClosure{msg: rejection}}, err
+	}
+
+	// removing the suggestion first ensures it is only applied once, if it is accepted by several writers at once
+	err = db.MySQLSuggestionDelete(s.SuggestionID)
+	if err == dbfs.ErrNoDbChange {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, s.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, s.Tag)}}, err
+	}
+
+	// the patch is transformed against any changes made since it was last rebased, as a writer's changes are
+	changes, version, _, numchanges, err := db.CBAppendFileChange(fileMeta, suggestion.Patch)
+	if err != nil {
+		if _, restoreErr := db.MySQLSuggestionCreate(suggestion); restoreErr != nil {
+			utils.LogError("Failed to restore suggestion", restoreErr, utils.LogFields{
+				"SuggestionID": s.SuggestionID,
+			})
+		}
+		if err == dbfs.ErrVersionOutOfDate {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusVersionOutOfDate, s.Tag)}}, nil
+		}
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, s.Tag)}}, err
+	}
+
+	// Trigger scrunching if longer than maxBufferLength
+	if numchanges > dbfs.MaxBufferLength {
+		dbfs.ScrunchInBackground(db, fileMeta)
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    s.Tag,
+		Data: struct {
+			FileVersion int64
+			Changes     string
+		}{
+			FileVersion: version,
+			Changes:     changes,
+		},
+	}.Wrap()
+	not := messages.Notification{
+		Resource:   s.Resource,
+		Method:     s.Method,
+		ResourceID: fileMeta.FileID,
+		Data: struct {
+			SuggestionID int64
+			FileVersion  int64
+			AcceptedBy   string
+		}{
+			SuggestionID: s.SuggestionID,
+			FileVersion:  version,
+			AcceptedBy:   s.SenderID,
+		},
+	}.Wrap()
+
+	closures := append([]dhClosure{toSenderClosure{msg: res}}, fileChangedClosures(fileMeta, version, changes, notifyKeys)...)
+	return append(closures, suggestionReviewed(not, suggestion, s.SenderID, notifyKeys)...), nil
+}
+
+// Suggestion.Reject
+type suggestionRejectRequest struct {
+	SuggestionID int64
+	abstractRequest
+}
+
+func (s *suggestionRejectRequest) setAbstractRequest(req *abstractRequest) {
+	s.abstractRequest = *req
+}
+
+func (s suggestionRejectRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return suggestionProjectIDs(db, s.SuggestionID)
+}
+
+func (s suggestionRejectRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	suggestion, err := db.MySQLSuggestionGet(s.SuggestionID)
+	if err == dbfs.ErrNoData {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, s.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, s.Tag)}}, err
+	}
+	fileMeta, err := db.MySQLFileGetInfo(suggestion.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, s.Tag)}}, nil
+	}
+
+	// authors can withdraw their own suggestions
+	access, err := dbfs.NewFileAccess(s.SenderID, fileMeta.ProjectID, db)
+	if err != nil || !(access.Can(fileMeta, config.CapabilityWrite) || suggestion.Author == s.SenderID) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  s.Resource,
+			"Method":    s.Method,
+			"SenderID":  s.SenderID,
+			"ProjectID": fileMeta.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, s.Tag)}}, nil
+	}
+
+	notifyKeys, err := fileNotificationKeys(access, fileMeta)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, s.Tag)}}, err
+	}
+
+	err = db.MySQLSuggestionDelete(s.SuggestionID)
+	if err == dbfs.ErrNoDbChange {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, s.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, s.Tag)}}, err
+	}
+
+	res := messages.NewEmptyResponse(messages.StatusSuccess, s.Tag)
+	not := messages.Notification{
+		Resource:   s.Resource,
+		Method:     s.Method,
+		ResourceID: fileMeta.FileID,
+		Data: struct {
+			SuggestionID int64
+			RejectedBy   string
+		}{
+			SuggestionID: s.SuggestionID,
+			RejectedBy:   s.SenderID,
+		},
+	}.Wrap()
+
+	return append([]dhClosure{toSenderClosure{msg: res}}, suggestionReviewed(not, suggestion, s.SenderID, notifyKeys)...), nil
+}
+
+// Suggestion.List
+type suggestionListRequest struct {
+	FileID int64
+	abstractRequest
+}
+
+func (s *suggestionListRequest) setAbstractRequest(req *abstractRequest) {
+	s.abstractRequest = *req
+}
+
+func (s suggestionListRequest) projectIDs(db dbfs.DBFS) ([]int64, error) {
+	return fileProjectIDs(db, s.FileID)
+}
+
+func (s suggestionListRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	fileMeta, err := db.MySQLFileGetInfo(s.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, s.Tag)}}, nil
+	}
+
+	access, err := s.fileAccess(fileMeta.ProjectID, db)
+	if err != nil || !access.Can(fileMeta, config.CapabilityRead) {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource":  s.Resource,
+			"Method":    s.Method,
+			"SenderID":  s.SenderID,
+			"ProjectID": fileMeta.ProjectID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, s.Tag)}}, nil
+	}
+
+	// suggestions are normally rebased as changes are made, but catch up on any that were missed
+	err = dbfs.RebaseSuggestions(db, fileMeta)
+	utils.LogError("Failed to rebase suggestions", err, utils.LogFields{
+		"FileID": s.FileID,
+	})
+
+	suggestions, err := db.MySQLSuggestionsGet(s.FileID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, s.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    s.Tag,
+		Data: struct {
+			Suggestions []dbfs.Suggestion
+		}{
+			Suggestions: suggestions,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
@@ -0,0 +1,157 @@
+package datahandling
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileChangeRequest_ProcessSuggestion(t *testing.T) {
+	db, fileMeta := fileSetup(t, map[string]string{"suggester": "suggester", "writer": "write", "reader": "read"})
+
+	req := fileChangeRequest{FileID: fileMeta.FileID, Changes: "v2:\n5:+2:xy:\n20"}
+	setBaseFields(&req)
+	req.Resource = "File"
+	req.Method = "Change"
+	req.SenderID = "reader"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "readers should not be able to suggest changes")
+
+	req.SenderID = "suggester"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	require.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	suggestion := reflect.ValueOf(resp.Data).FieldByName("Suggestion").Interface().(dbfs.Suggestion)
+	assert.Equal(t, "suggester", suggestion.Author)
+	assert.EqualValues(t, 2, suggestion.FileVersion)
+
+	notification := closures[1].(toRabbitChannelClosure)
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(fileMeta.ProjectID), notification.key)
+	assert.Equal(t, "Suggestion", notification.msg.ServerMessage.(messages.Notification).Resource)
+
+	// the file itself is not changed
+	assert.EqualValues(t, 2, db.FileVersion[fileMeta.FileID])
+	assert.Equal(t, 1, len(db.FileChanges[fileMeta.FileID]))
+	assert.Equal(t, req.Changes, db.Suggestions[suggestion.SuggestionID].Patch)
+
+	// suggestions on older versions are rebased onto the latest one
+	db.FileChanges[fileMeta.FileID] = append(db.FileChanges[fileMeta.FileID], "v2:\n0:+3:abc:\n20")
+	db.FileVersion[fileMeta.FileID] = 3
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	suggestion = reflect.ValueOf(resp.Data).FieldByName("Suggestion").Interface().(dbfs.Suggestion)
+	assert.Equal(t, "v3:\n8:+2:xy:\n23", suggestion.Patch)
+
+	req.Changes = "v4:\n5:+2:xy:\n23"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusVersionOutOfDate, resp.Status, "unexpected response status")
+
+	req.Changes = "v3::\n23"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "empty suggestions should be rejected")
+	assert.Equal(t, 2, len(db.Suggestions))
+}
+
+func TestSuggestionRequests_Process(t *testing.T) {
+	db, fileMeta := fileSetup(t, map[string]string{"suggester": "suggester", "writer": "write", "reader": "read"})
+	suggestionID, _ := db.MySQLSuggestionCreate(dbfs.Suggestion{
+		FileID:      fileMeta.FileID,
+		Author:      "suggester",
+		Patch:       "v2:\n5:+2:xy:\n20",
+		FileVersion: 2,
+	})
+	rejectedID, _ := db.MySQLSuggestionCreate(dbfs.Suggestion{
+		FileID:      fileMeta.FileID,
+		Author:      "suggester",
+		Patch:       "v2:\n0:-1:a:\n20",
+		FileVersion: 2,
+	})
+
+	list := suggestionListRequest{FileID: fileMeta.FileID}
+	setBaseFields(&list)
+	list.Resource = "Suggestion"
+	list.Method = "List"
+	list.SenderID = "reader"
+
+	closures, err := list.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Equal(t, 2, len(reflect.ValueOf(resp.Data).FieldByName("Suggestions").Interface().([]dbfs.Suggestion)))
+
+	accept := suggestionAcceptRequest{SuggestionID: suggestionID}
+	setBaseFields(&accept)
+	accept.Resource = "Suggestion"
+	accept.Method = "Accept"
+	accept.SenderID = "suggester"
+
+	closures, err = accept.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "suggesters should not accept suggestions")
+
+	accept.SenderID = "writer"
+	closures, err = accept.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.EqualValues(t, 3, reflect.ValueOf(resp.Data).FieldByName("FileVersion").Interface())
+	assert.EqualValues(t, 3, db.FileVersion[fileMeta.FileID])
+	assert.Equal(t, "v2:\n5:+2:xy:\n20", db.FileChanges[fileMeta.FileID][1])
+	_, pending := db.Suggestions[suggestionID]
+	assert.False(t, pending, "accepted suggestions should no longer be pending")
+
+	// subscribers are sent the change, then told it was accepted; the author's inbox is sent the acceptance too
+	require.Equal(t, 6, len(closures), "unexpected number of returned closures")
+	assert.Equal(t, "File", closures[1].(toRabbitChannelClosure).msg.ServerMessage.(messages.Notification).Resource)
+	assert.IsType(t, commentAnchorClosure{}, closures[2])
+	assert.IsType(t, suggestionRebaseClosure{}, closures[3])
+	assert.Equal(t, "Accept", closures[4].(toRabbitChannelClosure).msg.ServerMessage.(messages.Notification).Method)
+	assert.Equal(t, "suggester", closures[5].(inboxClosure).username)
+
+	// the other suggestions are rebased onto the accepted one
+	assert.Nil(t, closures[3].call(DataHandler{Db: db}))
+	assert.Equal(t, "v3:\n0:-1:a:\n22", db.Suggestions[rejectedID].Patch)
+
+	closures, err = accept.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNotFound, resp.Status, "suggestions should only be accepted once")
+
+	reject := suggestionRejectRequest{SuggestionID: rejectedID}
+	setBaseFields(&reject)
+	reject.Resource = "Suggestion"
+	reject.Method = "Reject"
+	reject.SenderID = "reader"
+
+	closures, err = reject.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "readers should not reject suggestions")
+
+	// authors can withdraw their own suggestions, without being notified of it
+	reject.SenderID = "suggester"
+	closures, err = reject.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	require.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	assert.Equal(t, "Reject", closures[1].(toRabbitChannelClosure).msg.ServerMessage.(messages.Notification).Method)
+	assert.Empty(t, db.Suggestions)
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/stretchr/testify/require"
 )
 
 func configSetup(t *testing.T) {
@@ -18,3 +20,20 @@ func configSetup(t *testing.T) {
 func testToken(t *testing.T, username string) string {
 	return signedTokenOrDie(t, username, time.Now().Unix(), time.Now().Add(1*time.Minute).Unix(), privKey)
 }
+
+// fileSetup creates a project owned by "loganga", granting each of the users the role labelled in roles, with a file
+// "main.go" on version 2 holding 20 characters
+func fileSetup(t *testing.T, roles map[string]string) (*dbfs.DatabaseMock, dbfs.FileMeta) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	for username, role := range roles {
+		db.MySQLProjectGrantPermission(projectID, username, config.PermissionsByLabel[role], "loganga")
+	}
+	fileID, _ := db.MySQLFileCreate("loganga", "main.go", "", projectID)
+	db.CBInsertNewFile(fileID, 2, []string{"v1:\n0:+20:aaaaaaaaaaaaaaaaaaaa:\n20"})
+
+	fileMeta, err := db.MySQLFileGetInfo(fileID)
+	require.Nil(t, err)
+	return db, fileMeta
+}
@@ -1,11 +1,16 @@
 package datahandling
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/CodeCollaborate/Server/modules/config"
 	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
 	"github.com/CodeCollaborate/Server/modules/dbfs"
 	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/modules/totp"
 	"github.com/CodeCollaborate/Server/utils"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -30,14 +35,82 @@ func initUserRequests() {
 		return commonJSON(new(userDeleteRequest), req)
 	}
 
+	authenticatedRequestMap["User.Update"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userUpdateRequest), req)
+	}
+
+	authenticatedRequestMap["User.Rename"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userRenameRequest), req)
+	}
+
 	authenticatedRequestMap["User.Lookup"] = func(req *abstractRequest) (request, error) {
 		return commonJSON(new(userLookupRequest), req)
 	}
 
+	authenticatedRequestMap["User.Search"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userSearchRequest), req)
+	}
+
 	authenticatedRequestMap["User.Projects"] = func(req *abstractRequest) (request, error) {
 		return commonJSON(new(userProjectsRequest), req)
 	}
 
+	authenticatedRequestMap["User.GetInvites"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userGetInvitesRequest), req)
+	}
+
+	authenticatedRequestMap["User.GetNotifications"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userGetNotificationsRequest), req)
+	}
+
+	authenticatedRequestMap["User.MarkRead"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userMarkReadRequest), req)
+	}
+
+	authenticatedRequestMap["User.RegisterBot"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userRegisterBotRequest), req)
+	}
+
+	authenticatedRequestMap["User.CreateToken"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userCreateTokenRequest), req)
+	}
+
+	authenticatedRequestMap["User.ListTokens"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userListTokensRequest), req)
+	}
+
+	authenticatedRequestMap["User.RevokeToken"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userRevokeTokenRequest), req)
+	}
+
+	unauthenticatedRequestMap["User.VerifyEmail"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userVerifyEmailRequest), req)
+	}
+
+	unauthenticatedRequestMap["User.ResendVerification"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userResendVerificationRequest), req)
+	}
+
+	unauthenticatedRequestMap["User.Verify2FA"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userVerify2FARequest), req)
+	}
+
+	authenticatedRequestMap["User.Enable2FA"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userEnable2FARequest), req)
+	}
+
+	authenticatedRequestMap["User.Confirm2FA"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userConfirm2FARequest), req)
+	}
+
+	authenticatedRequestMap["User.Disable2FA"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userDisable2FARequest), req)
+	}
+
+	authenticatedRequestMap["User.Unlock"] = func(req *abstractRequest) (request, error) {
+		return commonJSON(new(userUnlockRequest), req)
+	}
+
 	userRequestsSetup = true
 }
 
@@ -58,6 +131,24 @@ func (f *userRegisterRequest) setAbstractRequest(req *abstractRequest) {
 func (f userRegisterRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 	f.Username = strings.ToLower(f.Username)
 
+	// users are registered on their first login instead, if the authentication backend keeps their passwords
+	if !loginAuthenticator.RegistrationEnabled() {
+		utils.LogError("API permission error", nil, utils.LogFields{
+			"Resource": f.Resource,
+			"Method":   f.Method,
+			"Username": f.Username,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
+	}
+
+	cfg := config.GetConfig().ServerConfig.Registration
+	if err := checkPasswordPolicy(cfg.PasswordPolicy, f.Username, f.Password); err != nil {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, err.Error())}}, nil
+	}
+	if cfg.RequireEmailVerification && f.Email == "" {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, "An email address is required")}}, nil
+	}
+
 	hashed, err := bcrypt.GenerateFromPassword([]byte(f.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
@@ -69,9 +160,11 @@ func (f userRegisterRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		LastName:  f.LastName,
 		Email:     f.Email,
 		Password:  string(hashed),
+		Status:    dbfs.UserStatusActive,
+	}
+	if cfg.RequireEmailVerification {
+		newUser.Status = dbfs.UserStatusPending
 	}
-
-	// TODO (non-immediate/required): password validation
 
 	err = db.MySQLUserRegister(newUser)
 
@@ -81,7 +174,76 @@ func (f userRegisterRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		}
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
 	}
-	return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, f.Tag)}}, err
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			// Status is "pending" if the user must verify their email address, with User.VerifyEmail
+			Status string
+		}{
+			Status: newUser.Status,
+		},
+	}.Wrap()
+	closures := []dhClosure{
+		toSenderClosure{msg: res},
+		auditClosure{entry: dbfs.AuditEntry{Actor: f.Username, Action: "User.Register", Target: f.Username}},
+	}
+
+	if newUser.Status == dbfs.UserStatusPending {
+		// invites are bound once the address is verified, so they cannot be claimed by registering with someone else's
+		verification, err := newEmailVerification(db, newUser)
+		if err != nil {
+			// the user can ask for another with User.ResendVerification
+			utils.LogError("Failed to create email verification", err, utils.LogFields{
+				"Resource": f.Resource,
+				"Method":   f.Method,
+				"Username": f.Username,
+			})
+			return closures, nil
+		}
+		return append(closures, verification), nil
+	}
+
+	// Invites sent to the email address before the user registered are bound once they verify it, even if the server
+	// doesn't require them to
+	if f.Email != "" {
+		verification, err := newEmailChangeVerification(db, newUser, f.Email)
+		if err != nil {
+			utils.LogError("Failed to create email verification", err, utils.LogFields{
+				"Resource": f.Resource,
+				"Method":   f.Method,
+				"Username": f.Username,
+			})
+			return closures, nil
+		}
+		closures = append(closures, verification)
+	}
+	return closures, nil
+}
+
+// bindInvites binds the invites sent to the email address to the user, logging any failure
+func bindInvites(db dbfs.DBFS, abs abstractRequest, email string, username string) {
+	if err := db.MySQLInviteBindEmail(email, username); err != nil {
+		utils.LogError("Failed to bind invites to new user", err, utils.LogFields{
+			"Resource": abs.Resource,
+			"Method":   abs.Method,
+			"Username": username,
+		})
+	}
+}
+
+// newRejectedResponse is a StatusFail response, giving the reason the request was rejected
+func newRejectedResponse(tag int64, reason string) *messages.ServerMessageWrapper {
+	return messages.Response{
+		Status: messages.StatusFail,
+		Tag:    tag,
+		Data: struct {
+			Reason string
+		}{
+			Reason: reason,
+		},
+	}.Wrap()
 }
 
 // User.Login
@@ -97,28 +259,77 @@ func (f *userLoginRequest) setAbstractRequest(req *abstractRequest) {
 
 func (f userLoginRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 	f.Username = strings.ToLower(f.Username)
+	if closures, err := loginBlocked(db, f.abstractRequest, f.Username); closures != nil {
+		return closures, err
+	}
 
-	hashed, err := db.MySQLUserGetPass(f.Username)
-	if err != nil {
+	err := loginAuthenticator.Authenticate(db, f.Username, f.Password)
+	if err == errInvalidCredentials {
+		failedAudit := auditClosure{entry: dbfs.AuditEntry{Actor: f.Username, Action: "User.LoginFailed", Target: f.Username}}
+		closures := []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}, failedAudit}
+		return append(closures, recordLoginFailure(db, f.abstractRequest, f.Username)...), err
+	} else if err != nil {
 		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
 	}
 
-	if hashed == "" {
-		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, err
+	if unverifiedBlocked(config.UnverifiedBlockLogin) {
+		unverified, err := isUnverified(db, f.Username)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+		}
+		if unverified {
+			res := messages.Response{
+				Status: messages.StatusUnauthorized,
+				Tag:    f.Tag,
+				Data: struct {
+					// EmailUnverified tells the client to verify the email address with User.VerifyEmail
+					EmailUnverified bool
+				}{
+					EmailUnverified: true,
+				},
+			}.Wrap()
+			failedAudit := auditClosure{entry: dbfs.AuditEntry{Actor: f.Username, Action: "User.LoginFailed", Target: f.Username, Detail: "email unverified"}}
+			return []dhClosure{toSenderClosure{msg: res}, failedAudit}, nil
+		}
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(f.Password)); err != nil {
-		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, err
+	// users with two-factor authentication enabled complete their login with User.Verify2FA
+	twoFactor, err := db.MySQLUserGet2FA(f.Username)
+	if err != nil && err != dbfs.ErrNoData {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+	if err == nil && twoFactor.Enabled {
+		challenge, err := newChallengeToken(f.Username)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+		}
+
+		res := messages.Response{
+			Status: messages.StatusSuccess,
+			Tag:    f.Tag,
+			Data: struct {
+				Challenge string
+			}{
+				Challenge: challenge,
+			},
+		}.Wrap()
+		return []dhClosure{toSenderClosure{msg: res}}, nil
 	}
 
-	signed, err := newAuthToken(f.Username)
+	clearLoginFailures(db, f.Username)
+	return loginClosures(f.Username, f.Tag, "")
+}
+
+// loginClosures issues a token to a user who has logged in, and subscribes them to their own username channel
+func loginClosures(username string, tag int64, detail string) ([]dhClosure, error) {
+	signed, err := newAuthToken(username)
 	if err != nil {
-		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, tag)}}, err
 	}
 
 	res := messages.Response{
 		Status: messages.StatusSuccess,
-		Tag:    f.Tag,
+		Tag:    tag,
 		Data: struct {
 			Token string
 		}{
@@ -133,9 +344,12 @@ func (f userLoginRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 			Command: "Subscribe",
 			Tag:     -1,
 			Data: rabbitmq.RabbitQueueData{
-				Key: rabbitmq.RabbitUserQueueName(f.Username),
+				Key: rabbitmq.RabbitUserQueueName(username),
 			},
 		},
+		// then send the notifications they missed while offline
+		notificationBacklogClosure{username: username},
+		auditClosure{entry: dbfs.AuditEntry{Actor: username, Action: "User.Login", Target: username, Detail: detail}},
 	}, nil
 }
 
@@ -168,6 +382,216 @@ func (f userDeleteRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 		closures = append(closures, toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(projectID)})
 	}
 
+	closures = append(closures, auditClosure{entry: dbfs.AuditEntry{Actor: f.SenderID, Action: "User.Delete", Target: f.SenderID}})
+
+	return closures, nil
+}
+
+// User.Update
+type userUpdateRequest struct {
+	// FirstName, LastName and Email are left unchanged if they are empty
+	FirstName string
+	LastName  string
+	Email     string
+	// HiddenFromSearch excludes the user from User.Search results; it is left unchanged if it is omitted
+	HiddenFromSearch *bool
+	abstractRequest
+}
+
+func (f *userUpdateRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userUpdateRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	user, err := db.MySQLUserLookup(f.SenderID)
+	if err != nil || user.Username == "" {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	firstName, lastName := user.FirstName, user.LastName
+	if f.FirstName != "" {
+		firstName = f.FirstName
+	}
+	if f.LastName != "" {
+		lastName = f.LastName
+	}
+	emailChanged := f.Email != "" && !strings.EqualFold(f.Email, user.Email)
+	if emailChanged {
+		if user.Bot {
+			return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, "Bots cannot have an email address")}}, nil
+		}
+		existing, err := db.MySQLUserLookupEmail(f.Email)
+		if err != nil && err != dbfs.ErrNoData {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+		}
+		if existing.Username != "" && existing.Username != user.Username {
+			return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, "The email address is already in use")}}, nil
+		}
+	}
+
+	err = db.MySQLUserUpdateProfile(f.SenderID, firstName, lastName)
+	if err != nil && err != dbfs.ErrNoDbChange {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+	if f.HiddenFromSearch != nil {
+		err = db.MySQLUserSetHiddenFromSearch(f.SenderID, *f.HiddenFromSearch)
+		if err != nil && err != dbfs.ErrNoDbChange {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+		}
+	}
+
+	// changed addresses must be verified first, if the server verifies them at registration. Otherwise they are changed
+	// straight away, but the invites sent to them are still only bound once they are verified.
+	var closures []dhClosure
+	emailPending := false
+	if emailChanged {
+		verification, err := newEmailChangeVerification(db, user, f.Email)
+		if err != nil {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+		}
+		closures = append(closures, verification)
+
+		if config.GetConfig().ServerConfig.Registration.RequireEmailVerification {
+			emailPending = true
+		} else {
+			err = db.MySQLUserSetEmail(f.SenderID, f.Email)
+			if err != nil {
+				return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+			}
+		}
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			// EmailPending is set if the new email address will be set once it is verified with User.VerifyEmail
+			EmailPending bool
+		}{
+			EmailPending: emailPending,
+		},
+	}.Wrap()
+	var details []string
+	if emailChanged {
+		details = append(details, "email: "+f.Email)
+	}
+	if f.HiddenFromSearch != nil {
+		details = append(details, fmt.Sprintf("hidden from search: %t", *f.HiddenFromSearch))
+	}
+	detail := strings.Join(details, ", ")
+	closures = append([]dhClosure{toSenderClosure{msg: res}}, closures...)
+	return append(closures, auditClosure{entry: dbfs.AuditEntry{Actor: f.SenderID, Action: "User.Update", Target: f.SenderID, Detail: detail}}), nil
+}
+
+// maxUsernameLength is the length of the User.Username column
+const maxUsernameLength = 25
+
+// User.Rename
+type userRenameRequest struct {
+	NewUsername string
+	abstractRequest
+}
+
+func (f *userRenameRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userRenameRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	f.NewUsername = strings.ToLower(f.NewUsername)
+
+	// directory users are matched to local users by their username, which the directory keeps
+	if !loginAuthenticator.RegistrationEnabled() {
+		utils.LogError("API permission error", nil, utils.LogFields{
+			"Resource": f.Resource,
+			"Method":   f.Method,
+			"SenderID": f.SenderID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
+	}
+	if f.NewUsername == "" || len(f.NewUsername) > maxUsernameLength || strings.ContainsAny(f.NewUsername, " \t\r\n") {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, fmt.Sprintf("Usernames must be 1 to %d characters, without spaces", maxUsernameLength))}}, nil
+	}
+	if f.NewUsername == f.SenderID {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, "The username is unchanged")}}, nil
+	}
+	existing, err := db.MySQLUserLookup(f.NewUsername)
+	if err != nil && err != dbfs.ErrNoData {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+	if existing.Username != "" {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, "The username is taken")}}, nil
+	}
+
+	// the projects are looked up first, to tell their members about the rename
+	projects, err := db.MySQLUserProjects(f.SenderID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	err = db.MySQLUserRename(f.SenderID, f.NewUsername)
+	if err == dbfs.ErrNoDbChange {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	// tokens issued to the old username are no longer accepted, so the sender is given a new one
+	signed, err := newAuthToken(f.NewUsername)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServPartialFail, f.Tag)}}, err
+	}
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			Username string
+			Token    string
+		}{
+			Username: f.NewUsername,
+			Token:    signed,
+		},
+	}.Wrap()
+	not := messages.Notification{
+		Resource: f.Resource,
+		Method:   f.Method,
+		Data: struct {
+			OldUsername string
+			NewUsername string
+		}{
+			OldUsername: f.SenderID,
+			NewUsername: f.NewUsername,
+		},
+	}.Wrap()
+
+	oldQueue := rabbitmq.RabbitUserQueueName(f.SenderID)
+	closures := []dhClosure{
+		toSenderClosure{msg: res},
+		toRabbitChannelClosure{msg: not, key: oldQueue},
+	}
+	for _, project := range projects {
+		closures = append(closures, toRabbitChannelClosure{msg: not, key: rabbitmq.RabbitProjectQueueName(project.ProjectID)})
+	}
+
+	// move the user's live sockets over to the new username channel
+	closures = append(closures,
+		rabbitCommandClosure{
+			Command: "Subscribe",
+			Tag:     -1,
+			Key:     oldQueue,
+			Data: rabbitmq.RabbitQueueData{
+				Key: rabbitmq.RabbitUserQueueName(f.NewUsername),
+			},
+		},
+		rabbitCommandClosure{
+			Command: "Unsubscribe",
+			Tag:     -1,
+			Key:     oldQueue,
+			Data: rabbitmq.RabbitQueueData{
+				Key: oldQueue,
+			},
+		},
+		auditClosure{entry: dbfs.AuditEntry{Actor: f.SenderID, Action: "User.Rename", Target: f.NewUsername, Detail: f.SenderID}},
+	)
 	return closures, nil
 }
 
@@ -228,6 +652,74 @@ func (f userLookupRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 	return []dhClosure{toSenderClosure{msg: res}}, erro
 }
 
+// defaultUserSearchLimit is the number of users returned if the request does not specify a limit
+const defaultUserSearchLimit = 20
+
+// maxUserSearchLimit is the maximum number of users returned by a single request
+const maxUserSearchLimit = 100
+
+// maxUserSearchQueryLength is the longest query accepted by User.Search
+const maxUserSearchQueryLength = 50
+
+// User.Search
+type userSearchRequest struct {
+	Query string
+	// ProjectID, if set, is the project the sender is sharing; each result reports whether it already has access
+	ProjectID int64
+	Offset    int
+	Limit     int
+	abstractRequest
+}
+
+func (f *userSearchRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userSearchRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	// members are only revealed to those who can see the project
+	if f.ProjectID != 0 {
+		if _, err := db.MySQLUserProjectPermissionLookup(f.ProjectID, f.SenderID); err != nil {
+			utils.LogError("API permission error", err, utils.LogFields{
+				"Resource":  f.Resource,
+				"Method":    f.Method,
+				"SenderID":  f.SenderID,
+				"ProjectID": f.ProjectID,
+			})
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
+		}
+	}
+
+	query := strings.TrimSpace(f.Query)
+	if query == "" || len(query) > maxUserSearchQueryLength {
+		return []dhClosure{toSenderClosure{msg: newRejectedResponse(f.Tag, fmt.Sprintf("Queries must be 1 to %d characters", maxUserSearchQueryLength))}}, nil
+	}
+	if f.Offset < 0 || f.Limit < 0 {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, nil
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultUserSearchLimit
+	} else if f.Limit > maxUserSearchLimit {
+		f.Limit = maxUserSearchLimit
+	}
+
+	users, err := db.MySQLUserSearch(query, f.ProjectID, f.Offset, f.Limit)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			Users []dbfs.UserSearchResult
+		}{
+			Users: users,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
+
 // User.Projects
 type userProjectsRequest struct {
 	abstractRequest
@@ -287,3 +779,619 @@ func (f userProjectsRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
 
 	return []dhClosure{toSenderClosure{msg: res}}, nil
 }
+
+// User.GetInvites
+type userGetInvitesRequest struct {
+	abstractRequest
+}
+
+func (f *userGetInvitesRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userGetInvitesRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	invites, err := db.MySQLUserGetInvites(f.SenderID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			Invites []dbfs.Invite
+		}{
+			Invites: invites,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
+
+// defaultNotificationLimit is the number of notifications returned if the request does not specify a limit
+const defaultNotificationLimit = 50
+
+// maxNotificationLimit is the maximum number of notifications returned by a single request, or sent after logging in
+const maxNotificationLimit = 200
+
+// User.GetNotifications
+type userGetNotificationsRequest struct {
+	UnreadOnly bool
+	Offset     int
+	Limit      int
+	abstractRequest
+}
+
+func (f *userGetNotificationsRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userGetNotificationsRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	if f.Offset < 0 || f.Limit < 0 {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, nil
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultNotificationLimit
+	} else if f.Limit > maxNotificationLimit {
+		f.Limit = maxNotificationLimit
+	}
+
+	notifications, err := db.MySQLNotificationsGet(f.SenderID, f.UnreadOnly, f.Offset, f.Limit)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+	unread, err := db.MySQLNotificationsCountUnread(f.SenderID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			Notifications []dbfs.Notification
+			Unread        int
+		}{
+			Notifications: notifications,
+			Unread:        unread,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
+
+// User.MarkRead
+type userMarkReadRequest struct {
+	// NotificationIDs are the notifications to mark as read; all of the sender's notifications are marked if it is
+	// empty
+	NotificationIDs []int64
+	abstractRequest
+}
+
+func (f *userMarkReadRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userMarkReadRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	marked, err := db.MySQLNotificationsMarkRead(f.SenderID, f.NotificationIDs)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			// Marked is the number of notifications which were unread
+			Marked int
+		}{
+			Marked: marked,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
+
+// User.RegisterBot
+type userRegisterBotRequest struct {
+	Username  string
+	FirstName string
+	LastName  string
+	abstractRequest
+}
+
+func (f *userRegisterBotRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userRegisterBotRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	f.Username = strings.ToLower(f.Username)
+	if f.Username == "" {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, nil
+	}
+
+	// bots cannot register bots of their own
+	sender, err := db.MySQLUserLookup(f.SenderID)
+	if err != nil || sender.Bot {
+		utils.LogError("API permission error", err, utils.LogFields{
+			"Resource": f.Resource,
+			"Method":   f.Method,
+			"SenderID": f.SenderID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
+	}
+
+	err = db.MySQLUserRegisterBot(dbfs.UserMeta{
+		Username:  f.Username,
+		FirstName: f.FirstName,
+		LastName:  f.LastName,
+		BotOwner:  f.SenderID,
+	})
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	return []dhClosure{
+		toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, f.Tag)},
+		auditClosure{entry: dbfs.AuditEntry{Actor: f.SenderID, Action: "User.RegisterBot", Target: f.Username}},
+	}, nil
+}
+
+// canManageTokens returns whether the sender can manage the access tokens of the user `username`; users manage
+// their own tokens, and those of the bots they registered.
+func canManageTokens(senderID string, username string, db dbfs.DBFS) bool {
+	if username == senderID {
+		return true
+	}
+	user, err := db.MySQLUserLookup(username)
+	return err == nil && user.Bot && user.BotOwner == senderID
+}
+
+// User.CreateToken
+type userCreateTokenRequest struct {
+	// Username is the bot to create the token for; defaults to the sender
+	Username string
+	Name     string
+	Scopes   []string
+	// ExpiryDays is the number of days the token is valid for; 0 never expires
+	ExpiryDays int
+	abstractRequest
+}
+
+const maxAccessTokenNameLength = 50
+
+func (f *userCreateTokenRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userCreateTokenRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	f.Username = strings.ToLower(f.Username)
+	if f.Username == "" {
+		f.Username = f.SenderID
+	}
+	// tokens can only be created from a login, so that a token cannot be used to mint one with broader scopes
+	if f.accessToken || !canManageTokens(f.SenderID, f.Username, db) {
+		utils.LogError("API permission error", nil, utils.LogFields{
+			"Resource": f.Resource,
+			"Method":   f.Method,
+			"SenderID": f.SenderID,
+			"Username": f.Username,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
+	}
+
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" || len(f.Name) > maxAccessTokenNameLength || f.ExpiryDays < 0 {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, nil
+	}
+	if _, err := parseAccessTokenScopes(f.Scopes); err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	secret, err := newSecretToken()
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+	token := accessTokenPrefix + secret
+
+	tokenID, err := db.MySQLAccessTokenCreate(dbfs.AccessToken{
+		Username: f.Username,
+		Name:     f.Name,
+		Scopes:   f.Scopes,
+	}, hashSecretToken(token), time.Duration(f.ExpiryDays)*24*time.Hour)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusServFail, f.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			TokenID int64
+			Token   string
+		}{
+			TokenID: tokenID,
+			Token:   token,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:  f.SenderID,
+			Action: "User.CreateToken",
+			Target: f.Username,
+			Detail: strings.Join(f.Scopes, " "),
+		}},
+	}, nil
+}
+
+// User.ListTokens
+type userListTokensRequest struct {
+	// Username is the bot to list the tokens of; defaults to the sender
+	Username string
+	abstractRequest
+}
+
+func (f *userListTokensRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userListTokensRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	f.Username = strings.ToLower(f.Username)
+	if f.Username == "" {
+		f.Username = f.SenderID
+	}
+	if !canManageTokens(f.SenderID, f.Username, db) {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
+	}
+
+	tokens, err := db.MySQLUserGetAccessTokens(f.Username)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			Tokens []dbfs.AccessToken
+		}{
+			Tokens: tokens,
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
+
+// User.RevokeToken
+type userRevokeTokenRequest struct {
+	TokenID int64
+	abstractRequest
+}
+
+func (f *userRevokeTokenRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userRevokeTokenRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	token, err := db.MySQLAccessTokenGet(f.TokenID)
+	if err != nil || !canManageTokens(f.SenderID, token.Username, db) {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+	}
+
+	err = db.MySQLAccessTokenDelete(f.TokenID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	return []dhClosure{
+		toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, f.Tag)},
+		auditClosure{entry: dbfs.AuditEntry{
+			Actor:  f.SenderID,
+			Action: "User.RevokeToken",
+			Target: token.Username,
+			Detail: strconv.FormatInt(f.TokenID, 10),
+		}},
+	}, nil
+}
+
+// User.Verify2FA
+type userVerify2FARequest struct {
+	// Challenge is the token User.Login responded with
+	Challenge string
+	// Code is a TOTP code, or one of the user's recovery codes
+	Code string
+	abstractRequest
+}
+
+func (f *userVerify2FARequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userVerify2FARequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	username, err := parseChallengeToken(f.Challenge)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, err
+	}
+
+	if closures, err := loginBlocked(db, f.abstractRequest, username); closures != nil {
+		return closures, err
+	}
+
+	method, err := checkSecondFactor(db, username, f.Code)
+	if err == errInvalidCredentials {
+		failedAudit := auditClosure{entry: dbfs.AuditEntry{Actor: username, Action: "User.LoginFailed", Target: username, Detail: "2fa"}}
+		closures := []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}, failedAudit}
+		return append(closures, recordLoginFailure(db, f.abstractRequest, username)...), err
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	clearLoginFailures(db, username)
+	return loginClosures(username, f.Tag, "2fa: "+method)
+}
+
+// User.Enable2FA
+type userEnable2FARequest struct {
+	abstractRequest
+}
+
+func (f *userEnable2FARequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userEnable2FARequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	// 2FA is not enabled until the user confirms they have the secret, with User.Confirm2FA
+	err = db.MySQLUserSet2FASecret(f.SenderID, secret)
+	if err == dbfs.ErrNoDbChange {
+		// already enabled; it must be disabled with User.Disable2FA first
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			Secret string
+			// URI is the otpauth:// provisioning URI, for display as a QR code
+			URI string
+		}{
+			Secret: secret,
+			URI:    totp.URI(config.GetConfig().ServerConfig.Name, f.SenderID, secret),
+		},
+	}.Wrap()
+
+	return []dhClosure{toSenderClosure{msg: res}}, nil
+}
+
+// User.Confirm2FA
+type userConfirm2FARequest struct {
+	Code string
+	abstractRequest
+}
+
+func (f *userConfirm2FARequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userConfirm2FARequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	twoFactor, err := db.MySQLUserGet2FA(f.SenderID)
+	if err == dbfs.ErrNoData || (err == nil && twoFactor.Enabled) {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	step, ok := totp.Validate(twoFactor.Secret, f.Code, time.Now())
+	if !ok {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+	err = db.MySQLUserEnable2FA(f.SenderID, step, hashes)
+	if err == dbfs.ErrNoDbChange {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	res := messages.Response{
+		Status: messages.StatusSuccess,
+		Tag:    f.Tag,
+		Data: struct {
+			// RecoveryCodes can each be used once in place of a TOTP code; they are not shown again
+			RecoveryCodes []string
+		}{
+			RecoveryCodes: codes,
+		},
+	}.Wrap()
+
+	return []dhClosure{
+		toSenderClosure{msg: res},
+		auditClosure{entry: dbfs.AuditEntry{Actor: f.SenderID, Action: "User.Enable2FA", Target: f.SenderID}},
+	}, nil
+}
+
+// User.Disable2FA
+type userDisable2FARequest struct {
+	Password string
+	// Code is a TOTP code, or one of the user's recovery codes
+	Code string
+	abstractRequest
+}
+
+func (f *userDisable2FARequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userDisable2FARequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	if closures, err := loginBlocked(db, f.abstractRequest, f.SenderID); closures != nil {
+		return closures, err
+	}
+	failedClosures := func() []dhClosure {
+		closures := []dhClosure{
+			toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)},
+			auditClosure{entry: dbfs.AuditEntry{Actor: f.SenderID, Action: "User.Disable2FAFailed", Target: f.SenderID}},
+		}
+		return append(closures, recordLoginFailure(db, f.abstractRequest, f.SenderID)...)
+	}
+
+	err := loginAuthenticator.Authenticate(db, f.SenderID, f.Password)
+	if err == errInvalidCredentials {
+		return failedClosures(), err
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	_, err = checkSecondFactor(db, f.SenderID, f.Code)
+	if err == errInvalidCredentials {
+		return failedClosures(), err
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	err = db.MySQLUserDisable2FA(f.SenderID)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	return []dhClosure{
+		toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, f.Tag)},
+		auditClosure{entry: dbfs.AuditEntry{Actor: f.SenderID, Action: "User.Disable2FA", Target: f.SenderID}},
+	}, nil
+}
+
+// User.VerifyEmail
+type userVerifyEmailRequest struct {
+	// Token is the verification token emailed to the user
+	Token string
+	abstractRequest
+}
+
+func (f *userVerifyEmailRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userVerifyEmailRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	username, err := db.MySQLEmailVerificationRedeem(hashSecretToken(f.Token))
+	if err == dbfs.ErrNoData {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	// now that the address is verified, redeem the invites sent to it
+	user, err := db.MySQLUserLookup(username)
+	if err != nil {
+		utils.LogError("Failed to look up verified user", err, utils.LogFields{
+			"Resource": f.Resource,
+			"Method":   f.Method,
+			"Username": username,
+		})
+	} else if user.Email != "" {
+		bindInvites(db, f.abstractRequest, user.Email, username)
+	}
+
+	return []dhClosure{
+		toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, f.Tag)},
+		auditClosure{entry: dbfs.AuditEntry{Actor: username, Action: "User.VerifyEmail", Target: username, Detail: user.Email}},
+	}, nil
+}
+
+// User.ResendVerification
+type userResendVerificationRequest struct {
+	// Username and Password are required, since users may not be able to log in until they have verified their email
+	Username string
+	Password string
+	abstractRequest
+}
+
+func (f *userResendVerificationRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userResendVerificationRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	f.Username = strings.ToLower(f.Username)
+	if closures, err := loginBlocked(db, f.abstractRequest, f.Username); closures != nil {
+		return closures, err
+	}
+
+	err := loginAuthenticator.Authenticate(db, f.Username, f.Password)
+	if err == errInvalidCredentials {
+		closures := []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}
+		return append(closures, recordLoginFailure(db, f.abstractRequest, f.Username)...), err
+	} else if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+
+	user, err := db.MySQLUserLookup(f.Username)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+	if user.Status != dbfs.UserStatusPending {
+		// already verified
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+	}
+
+	verification, err := newEmailVerification(db, user)
+	if err != nil {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+	}
+	return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, f.Tag)}, verification}, nil
+}
+
+// User.Unlock
+type userUnlockRequest struct {
+	// Username and IP are the user and address to clear failed logins and lockouts for; either may be empty
+	Username string
+	IP       string
+	abstractRequest
+}
+
+func (f *userUnlockRequest) setAbstractRequest(req *abstractRequest) {
+	f.abstractRequest = *req
+}
+
+func (f userUnlockRequest) process(db dbfs.DBFS) ([]dhClosure, error) {
+	if !config.GetConfig().ServerConfig.IsAdministrator(f.SenderID) {
+		utils.LogError("API permission error", nil, utils.LogFields{
+			"Resource": f.Resource,
+			"Method":   f.Method,
+			"SenderID": f.SenderID,
+		})
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusUnauthorized, f.Tag)}}, nil
+	}
+	f.Username = strings.ToLower(f.Username)
+
+	cleared := false
+	for kind, subject := range map[string]string{dbfs.LoginSubjectUser: f.Username, dbfs.LoginSubjectIP: f.IP} {
+		if subject == "" {
+			continue
+		}
+		err := db.MySQLLoginFailuresClear(kind, subject)
+		if err == nil {
+			cleared = true
+		} else if err != dbfs.ErrNoDbChange {
+			return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusFail, f.Tag)}}, err
+		}
+	}
+	if !cleared {
+		return []dhClosure{toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusNotFound, f.Tag)}}, nil
+	}
+
+	return []dhClosure{
+		toSenderClosure{msg: messages.NewEmptyResponse(messages.StatusSuccess, f.Tag)},
+		auditClosure{entry: dbfs.AuditEntry{Actor: f.SenderID, Action: "User.Unlock", Target: f.Username, Detail: f.IP}},
+	}, nil
+}
@@ -1,13 +1,21 @@
 package datahandling
 
 import (
+	"encoding/json"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/CodeCollaborate/Server/modules/config"
 	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
 	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/mail"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/modules/totp"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestUserRegisterRequest_Process(t *testing.T) {
@@ -27,25 +35,47 @@ func TestUserRegisterRequest_Process(t *testing.T) {
 	db := dbfs.NewDBMock()
 	datahanly.Db = db
 
+	projectID, _ := db.MySQLProjectCreate("notloganga", "new stuff")
+	inviteID, _ := db.MySQLInviteCreate(dbfs.Invite{
+		ProjectID:       projectID,
+		Email:           req.Email,
+		PermissionLevel: config.PermissionsByLabel["write"],
+		InvitedBy:       "notloganga",
+	}, time.Hour)
+	db.FunctionCallCount = 0
+
 	closures, err := req.process(db)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// didn't call extra db functions
-	if db.FunctionCallCount != 1 {
+	if db.FunctionCallCount != 2 {
 		t.Fatal("did not call correct number of db functions")
 	}
 	// did gene it actually added
 	if _, ok := db.Users["loganga"]; !ok {
 		t.Fatal("did not correctly call db function")
 	}
+	assert.Empty(t, db.Invites[inviteID].Username, "invites should not be bound until the email is verified")
 
 	// are we notifying the right people
-	if len(closures) != 1 ||
-		reflect.TypeOf(closures[0]).String() != "datahandling.toSenderClosure" {
+	if len(closures) != 3 ||
+		reflect.TypeOf(closures[0]).String() != "datahandling.toSenderClosure" ||
+		reflect.TypeOf(closures[1]).String() != "datahandling.auditClosure" ||
+		reflect.TypeOf(closures[2]).String() != "datahandling.mailClosure" {
 		t.Fatalf("did not properly process, recieved %d closure(s)", len(closures))
 	}
+	assert.Equal(t, req.Email, closures[2].(mailClosure).msg.To)
+
+	// the invites are bound once the address is verified
+	token := strings.Split(closures[2].(mailClosure).msg.Body, "\n\n")[2]
+	verify := userVerifyEmailRequest{Token: token}
+	setBaseFields(&verify)
+	closures, err = verify.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status)
+	assert.Equal(t, "loganga", db.Invites[inviteID].Username, "invites to the user's email should be bound to them")
 	// did the server return success status
 	cont := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status
 	if cont != messages.StatusSuccess {
@@ -58,7 +88,48 @@ func TestUserRegisterRequest_Process(t *testing.T) {
 	}
 }
 
-// userLoginRequest.process is unimplemented
+func TestUserLoginRequest_Process(t *testing.T) {
+	configSetup(t)
+	defer func() { loginAuthenticator = passwordAuthenticator{} }()
+	req := *new(userLoginRequest)
+	setBaseFields(&req)
+
+	req.Resource = "User"
+	req.Method = "Login"
+	req.Username = "Jane"
+	req.Password = "correct horse"
+
+	db := dbfs.NewDBMock()
+	loginAuthenticator = &ldapAuthenticator{
+		directory: fakeDirectory{passwords: map[string]string{"jane": "correct horse"}},
+		cfg:       config.LDAPCfg{AutoProvision: true},
+	}
+
+	closures, err := req.process(db)
+	assert.NoError(t, err)
+	if assert.Len(t, closures, 4) {
+		assert.Equal(t, messages.StatusSuccess, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status)
+		assert.IsType(t, rabbitCommandClosure{}, closures[1])
+		assert.IsType(t, notificationBacklogClosure{}, closures[2])
+		assert.Equal(t, "User.Login", closures[3].(auditClosure).entry.Action)
+	}
+	assert.Contains(t, db.Users, "jane", "usernames should be lowercased before authenticating")
+
+	req.Password = "wrong"
+	closures, err = req.process(db)
+	assert.Equal(t, errInvalidCredentials, err)
+	if assert.Len(t, closures, 2) {
+		assert.Equal(t, messages.StatusUnauthorized, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status)
+		assert.Equal(t, "User.LoginFailed", closures[1].(auditClosure).entry.Action)
+	}
+
+	// users of the directory cannot register with a password
+	register := userRegisterRequest{Username: "john", Password: "battery staple"}
+	closures, err = register.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusUnauthorized, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status)
+	assert.NotContains(t, db.Users, "john")
+}
 
 func TestUserDeleteRequest_Process(t *testing.T) {
 	configSetup(t)
@@ -77,8 +148,9 @@ func TestUserDeleteRequest_Process(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 2, db.FunctionCallCount, "unexpected db calls for user delete")
 
-	assert.Equal(t, 1, len(closures), "unexpected number of returned closures")
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
 	assert.IsType(t, toSenderClosure{}, closures[0], "incorrect closure type")
+	assert.IsType(t, auditClosure{}, closures[1], "incorrect closure type")
 
 	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
 
@@ -102,10 +174,11 @@ func TestUserDeleteRequest_Process(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 2, db.FunctionCallCount, "unexpected db calls for user delete")
 
-	assert.Equal(t, 3, len(closures), "unexpected number of returned closures")
+	assert.Equal(t, 4, len(closures), "unexpected number of returned closures")
 	assert.IsType(t, toSenderClosure{}, closures[0], "incorrect closure type")
 	assert.IsType(t, toRabbitChannelClosure{}, closures[1], "incorrect closure type")
 	assert.IsType(t, toRabbitChannelClosure{}, closures[2], "incorrect closure type")
+	assert.IsType(t, auditClosure{}, closures[3], "incorrect closure type")
 
 	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
 	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
@@ -121,6 +194,150 @@ func TestUserDeleteRequest_Process(t *testing.T) {
 	assert.Equal(t, projectID2, not2.ResourceID, "unexpected projectID deleted")
 }
 
+func TestUserUpdateRequest_Process(t *testing.T) {
+	configSetup(t)
+	fake := &fakeMailer{}
+	mailer = fake
+	defer func() { mailer = mail.LogMailer{} }()
+	status := func(closures []dhClosure) int {
+		return closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status
+	}
+
+	db := dbfs.NewDBMock()
+	db.Users["loganga"] = dbfs.UserMeta{Username: "loganga", FirstName: "Gene", LastName: "Logan", Email: "gene@example.com"}
+	db.Users["notloganga"] = dbfs.UserMeta{Username: "notloganga", Email: "other@example.com"}
+
+	update := userUpdateRequest{FirstName: "Eugene"}
+	setBaseFields(&update)
+	closures, err := update.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	assert.Equal(t, "User.Update", closures[1].(auditClosure).entry.Action)
+	assert.Equal(t, dbfs.UserMeta{Username: "loganga", FirstName: "Eugene", LastName: "Logan", Email: "gene@example.com"},
+		db.Users["loganga"], "empty fields should be left unchanged")
+
+	update.Email = "other@example.com"
+	closures, err = update.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusFail, status(closures), "addresses of other users should be rejected")
+	assert.Equal(t, "gene@example.com", db.Users["loganga"].Email)
+
+	// without email verification, addresses are changed straight away, but invites sent to them aren't bound until
+	// they are verified
+	projectID, _ := db.MySQLProjectCreate("notloganga", "new stuff")
+	inviteID, _ := db.MySQLInviteCreate(dbfs.Invite{
+		ProjectID:       projectID,
+		Email:           "eugene@example.com",
+		PermissionLevel: config.PermissionsByLabel["write"],
+		InvitedBy:       "notloganga",
+	}, time.Hour)
+	update.Email = "eugene@example.com"
+	closures, err = update.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	assert.Equal(t, "eugene@example.com", db.Users["loganga"].Email)
+	assert.Empty(t, db.Invites[inviteID].Username, "invites should not be bound until the email is verified")
+	if assert.Len(t, closures, 3) {
+		assert.Equal(t, "eugene@example.com", closures[1].(mailClosure).msg.To)
+	}
+
+	verify := userVerifyEmailRequest{Token: strings.Split(closures[1].(mailClosure).msg.Body, "\n\n")[2]}
+	setBaseFields(&verify)
+	closures, err = verify.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	assert.Equal(t, "loganga", db.Invites[inviteID].Username)
+	assert.Empty(t, fake.sent)
+
+	// with it, they are changed once the new address is verified
+	config.GetConfig().ServerConfig.Registration.RequireEmailVerification = true
+	update.Email = "gene.logan@example.com"
+	closures, err = update.process(db)
+	assert.NoError(t, err)
+	res := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, res.Status)
+	assert.True(t, res.Data.(struct{ EmailPending bool }).EmailPending)
+	assert.Equal(t, "eugene@example.com", db.Users["loganga"].Email, "the address should not change until it is verified")
+	if assert.Len(t, closures, 3) {
+		assert.NoError(t, closures[1].(mailClosure).call(DataHandler{}))
+	}
+	if assert.Len(t, fake.sent, 1) {
+		assert.Equal(t, "gene.logan@example.com", fake.sent[0].To)
+	}
+
+	var tokenHash string
+	for hash, verification := range db.EmailVerifications {
+		assert.Equal(t, "gene.logan@example.com", verification.Email)
+		tokenHash = hash
+	}
+	username, err := db.MySQLEmailVerificationRedeem(tokenHash)
+	assert.NoError(t, err)
+	assert.Equal(t, "loganga", username)
+	assert.Equal(t, "gene.logan@example.com", db.Users["loganga"].Email)
+	assert.Equal(t, dbfs.UserStatusActive, db.Users["loganga"].Status)
+}
+
+func TestUserRenameRequest_Process(t *testing.T) {
+	configSetup(t)
+	status := func(closures []dhClosure) int {
+		return closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status
+	}
+
+	db := dbfs.NewDBMock()
+	db.MySQLUserRegister(geneMeta)
+	db.Users["notloganga"] = dbfs.UserMeta{Username: "notloganga"}
+	db.Users["loganga-bot"] = dbfs.UserMeta{Username: "loganga-bot", Bot: true, BotOwner: "loganga"}
+	db.ProjectIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("loganga", "new stuff")
+	oldToken := testToken(t, "loganga")
+
+	rename := userRenameRequest{NewUsername: "NotLoganga"}
+	setBaseFields(&rename)
+	closures, err := rename.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusFail, status(closures), "taken usernames should be rejected")
+	rename.NewUsername = "much too long to be a username"
+	closures, err = rename.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusFail, status(closures))
+
+	rename.NewUsername = "GeneLogan"
+	closures, err = rename.process(db)
+	assert.NoError(t, err)
+	if !assert.Len(t, closures, 6) {
+		return
+	}
+	res := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, res.Status)
+	data := res.Data.(struct {
+		Username string
+		Token    string
+	})
+	assert.Equal(t, "genelogan", data.Username)
+	assert.Equal(t, rabbitmq.RabbitUserQueueName("loganga"), closures[1].(toRabbitChannelClosure).key)
+	assert.Equal(t, rabbitmq.RabbitProjectQueueName(projectID), closures[2].(toRabbitChannelClosure).key)
+	assert.Equal(t, "Subscribe", closures[3].(rabbitCommandClosure).Command)
+	assert.Equal(t, rabbitmq.RabbitQueueData{Key: rabbitmq.RabbitUserQueueName("genelogan")}, closures[3].(rabbitCommandClosure).Data)
+	assert.Equal(t, "Unsubscribe", closures[4].(rabbitCommandClosure).Command)
+	assert.Equal(t, rabbitmq.RabbitQueueData{Key: rabbitmq.RabbitUserQueueName("loganga")}, closures[4].(rabbitCommandClosure).Data)
+	assert.Equal(t, dbfs.AuditEntry{Actor: "loganga", Action: "User.Rename", Target: "genelogan", Detail: "loganga"},
+		closures[5].(auditClosure).entry)
+
+	assert.NotContains(t, db.Users, "loganga")
+	assert.Equal(t, "genelogan", db.Users["loganga-bot"].BotOwner)
+	assert.Len(t, db.Projects["genelogan"], 1)
+
+	// tokens issued to the old username are rejected, even once it is registered again
+	db.MySQLUserRegister(geneMeta)
+	req := abstractRequest{Resource: "User", Method: "Projects", SenderID: "loganga", SenderToken: oldToken, Data: json.RawMessage("{}")}
+	_, err = getFullRequest(&req, db)
+	assert.Equal(t, ErrAuthenticationFailed, err)
+
+	req = abstractRequest{Resource: "User", Method: "Projects", SenderID: "genelogan", SenderToken: data.Token, Data: json.RawMessage("{}")}
+	_, err = getFullRequest(&req, db)
+	assert.NoError(t, err)
+}
+
 func TestUserLookupRequest_Process(t *testing.T) {
 	configSetup(t)
 	req := *new(userLookupRequest)
@@ -272,3 +489,572 @@ func TestUserProjectsRequest_Process(t *testing.T) {
 	assert.Nil(t, err, "did not get permission")
 	assert.Equal(t, ownerPerm.Level, projects[1].Permissions[notgene.Username].PermissionLevel, "not all permissions returned for project")
 }
+
+func TestUserGetInvitesRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.InviteIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("notloganga", "new stuff")
+	inviteID, _ := db.MySQLInviteCreate(dbfs.Invite{
+		ProjectID:       projectID,
+		Username:        "loganga",
+		PermissionLevel: config.PermissionsByLabel["read"],
+		InvitedBy:       "notloganga",
+	}, time.Hour)
+	db.MySQLInviteCreate(dbfs.Invite{
+		ProjectID:       projectID,
+		Username:        "loganga",
+		PermissionLevel: config.PermissionsByLabel["write"],
+		InvitedBy:       "notloganga",
+	}, -time.Hour)
+
+	req := *new(userGetInvitesRequest)
+	setBaseFields(&req)
+	req.Resource = "User"
+	req.Method = "GetInvites"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	invites := reflect.ValueOf(resp.Data).FieldByName("Invites").Interface().([]dbfs.Invite)
+	if assert.Len(t, invites, 1, "expired invites should not be returned") {
+		assert.Equal(t, inviteID, invites[0].InviteID)
+		assert.Equal(t, "new stuff", invites[0].ProjectName)
+	}
+}
+
+func TestUserRegisterBotRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.MySQLUserRegister(dbfs.UserMeta{Username: "loganga"})
+
+	req := *new(userRegisterBotRequest)
+	setBaseFields(&req)
+	req.Resource = "User"
+	req.Method = "RegisterBot"
+	req.Username = "Formatter"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.True(t, db.Users["formatter"].Bot)
+	assert.Equal(t, "loganga", db.Users["formatter"].BotOwner)
+
+	// bots cannot log in with a password
+	login := userLoginRequest{Username: "formatter", Password: ""}
+	setBaseFields(&login)
+	closures, _ = login.process(db)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "bots should not be able to log in")
+
+	// bots cannot register bots
+	req.SenderID = "formatter"
+	req.Username = "linter"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "unexpected response status")
+}
+
+func TestUserCreateTokenRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.AccessTokenIDCounter = 1
+	db.MySQLUserRegister(dbfs.UserMeta{Username: "loganga"})
+	db.MySQLUserRegisterBot(dbfs.UserMeta{Username: "formatter", BotOwner: "loganga"})
+	db.MySQLUserRegisterBot(dbfs.UserMeta{Username: "linter", BotOwner: "notloganga"})
+
+	req := *new(userCreateTokenRequest)
+	setBaseFields(&req)
+	req.Resource = "User"
+	req.Method = "CreateToken"
+	req.Username = "formatter"
+	req.Name = "ci"
+	req.Scopes = []string{"read", "project:12"}
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	tokenID := reflect.ValueOf(resp.Data).FieldByName("TokenID").Interface().(int64)
+	token := reflect.ValueOf(resp.Data).FieldByName("Token").Interface().(string)
+	assert.Equal(t, tokenID, db.AccessTokenHashes[hashSecretToken(token)], "only the hash of the token should be stored")
+	assert.Equal(t, "formatter", db.AccessTokens[tokenID].Username)
+	assert.True(t, db.AccessTokens[tokenID].ExpiryDate.IsZero(), "tokens should not expire by default")
+
+	req.Username = "linter"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "users should only create tokens for their own bots")
+
+	req.Username = ""
+	req.Scopes = nil
+	req.accessToken = true
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusUnauthorized, resp.Status, "tokens should not be able to create tokens")
+
+	req.accessToken = false
+	req.Scopes = []string{"write"}
+	closures, _ = req.process(db)
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusFail, resp.Status, "unknown scopes should be rejected")
+	assert.Len(t, db.AccessTokens, 1)
+}
+
+func TestUserRevokeTokenRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.AccessTokenIDCounter = 1
+	db.MySQLUserRegisterBot(dbfs.UserMeta{Username: "formatter", BotOwner: "loganga"})
+	tokenID, _ := db.MySQLAccessTokenCreate(dbfs.AccessToken{Username: "formatter", Name: "ci"}, "hash", 0)
+
+	req := userRevokeTokenRequest{TokenID: tokenID}
+	setBaseFields(&req)
+	req.Resource = "User"
+	req.Method = "RevokeToken"
+	req.SenderID = "notloganga"
+
+	closures, err := req.process(db)
+	assert.Nil(t, err)
+	resp := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusNotFound, resp.Status, "unexpected response status")
+
+	req.SenderID = "loganga"
+	closures, err = req.process(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(closures), "unexpected number of returned closures")
+	resp = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	assert.Equal(t, messages.StatusSuccess, resp.Status, "unexpected response status")
+	assert.Empty(t, db.AccessTokens)
+
+	_, err = db.MySQLAccessTokenAuthenticate("hash")
+	assert.Equal(t, dbfs.ErrNoData, err, "revoked tokens should no longer authenticate")
+}
+
+func TestUserTwoFactorRequests_Process(t *testing.T) {
+	configSetup(t)
+	defer disableLoginProtection()()
+	db := dbfs.NewDBMock()
+	hashed, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	db.Users["loganga"] = dbfs.UserMeta{Username: "loganga", Password: string(hashed)}
+	status := func(closures []dhClosure) int {
+		return closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status
+	}
+
+	enable := userEnable2FARequest{}
+	setBaseFields(&enable)
+	enable.SenderID = "loganga"
+	closures, err := enable.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	secret := db.TwoFactor["loganga"].Secret
+	assert.NotEmpty(t, secret)
+	assert.False(t, db.TwoFactor["loganga"].Enabled, "2FA should not be enabled until it is confirmed")
+
+	// logins are not challenged before 2FA is confirmed
+	login := userLoginRequest{Username: "loganga", Password: "correct horse"}
+	setBaseFields(&login)
+	closures, err = login.process(db)
+	assert.NoError(t, err)
+	assert.Len(t, closures, 4)
+
+	confirm := userConfirm2FARequest{Code: "000000"}
+	setBaseFields(&confirm)
+	confirm.SenderID = "loganga"
+	closures, err = confirm.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusUnauthorized, status(closures))
+
+	step := totp.Step(time.Now())
+	confirm.Code, _ = totp.Code(secret, step)
+	closures, err = confirm.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	recoveryCodes := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Data.(struct {
+		RecoveryCodes []string
+	}).RecoveryCodes
+	assert.Len(t, recoveryCodes, recoveryCodeCount)
+	assert.Equal(t, "User.Enable2FA", closures[1].(auditClosure).entry.Action)
+	assert.True(t, db.TwoFactor["loganga"].Enabled)
+
+	// logins now respond with a challenge, instead of a token
+	closures, err = login.process(db)
+	assert.NoError(t, err)
+	if assert.Len(t, closures, 1) {
+		assert.Equal(t, messages.StatusSuccess, status(closures))
+	}
+	challenge := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Data.(struct {
+		Challenge string
+	}).Challenge
+	_, err = parseToken(abstractRequest{SenderID: "loganga", SenderToken: challenge})
+	assert.Error(t, err, "challenges should not authenticate requests")
+
+	verify := userVerify2FARequest{Challenge: challenge, Code: confirm.Code}
+	setBaseFields(&verify)
+	closures, err = verify.process(db)
+	assert.Equal(t, errInvalidCredentials, err, "codes should only be usable once")
+	assert.Equal(t, messages.StatusUnauthorized, status(closures))
+	assert.Equal(t, "User.LoginFailed", closures[1].(auditClosure).entry.Action)
+
+	verify.Code, _ = totp.Code(secret, step+1)
+	closures, err = verify.process(db)
+	assert.NoError(t, err)
+	if assert.Len(t, closures, 4) {
+		assert.Equal(t, messages.StatusSuccess, status(closures))
+		assert.Equal(t, "User.Login", closures[3].(auditClosure).entry.Action)
+	}
+
+	// recovery codes can be used in place of TOTP codes, once each
+	verify.Code = strings.ToUpper(recoveryCodes[0])
+	closures, err = verify.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	closures, err = verify.process(db)
+	assert.Equal(t, errInvalidCredentials, err)
+	assert.Len(t, db.RecoveryCodes["loganga"], recoveryCodeCount-1)
+
+	verify.Challenge = testToken(t, "loganga")
+	verify.Code = recoveryCodes[1]
+	closures, err = verify.process(db)
+	assert.Error(t, err, "login tokens should not be accepted as challenges")
+	assert.Equal(t, messages.StatusUnauthorized, status(closures))
+
+	// already enabled
+	closures, err = enable.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusFail, status(closures))
+
+	disable := userDisable2FARequest{Password: "wrong", Code: recoveryCodes[1]}
+	setBaseFields(&disable)
+	disable.SenderID = "loganga"
+	closures, err = disable.process(db)
+	assert.Equal(t, errInvalidCredentials, err)
+	assert.Equal(t, messages.StatusUnauthorized, status(closures))
+
+	disable.Password = "correct horse"
+	disable.Code = "000000"
+	closures, err = disable.process(db)
+	assert.Equal(t, errInvalidCredentials, err)
+	assert.Equal(t, messages.StatusUnauthorized, status(closures))
+	assert.True(t, db.TwoFactor["loganga"].Enabled)
+
+	disable.Code = recoveryCodes[1]
+	closures, err = disable.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	assert.Equal(t, "User.Disable2FA", closures[1].(auditClosure).entry.Action)
+	assert.NotContains(t, db.TwoFactor, "loganga")
+	assert.Empty(t, db.RecoveryCodes["loganga"])
+}
+
+func TestUserEmailVerification_Process(t *testing.T) {
+	configSetup(t)
+	defer disableLoginProtection()()
+	registration := &config.GetConfig().ServerConfig.Registration
+	registration.RequireEmailVerification = true
+	registration.UnverifiedPolicy = config.UnverifiedBlockLogin
+	registration.VerificationURL = "https://example.com/verify?token=%s"
+	fake := &fakeMailer{}
+	mailer = fake
+	defer func() { mailer = mail.LogMailer{} }()
+	status := func(closures []dhClosure) int {
+		return closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status
+	}
+	// sendMail runs the mailClosures, returning the token in the last email sent
+	sendMail := func(closures []dhClosure) string {
+		for _, closure := range closures {
+			if closure, ok := closure.(mailClosure); ok {
+				assert.NoError(t, closure.call(DataHandler{}))
+			}
+		}
+		if len(fake.sent) == 0 {
+			return ""
+		}
+		body := fake.sent[len(fake.sent)-1].Body
+		start := strings.Index(body, "?token=") + len("?token=")
+		return body[start : start+strings.Index(body[start:], "\n")]
+	}
+
+	db := dbfs.NewDBMock()
+	db.ProjectIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("notloganga", "new stuff")
+	inviteID, _ := db.MySQLInviteCreate(dbfs.Invite{
+		ProjectID:       projectID,
+		Email:           geneMeta.Email,
+		PermissionLevel: config.PermissionsByLabel["write"],
+		InvitedBy:       "notloganga",
+	}, time.Hour)
+
+	register := userRegisterRequest{Username: "loganga", Email: geneMeta.Email, Password: "short"}
+	setBaseFields(&register)
+	closures, err := register.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusFail, status(closures), "weak passwords should be rejected")
+	assert.NotContains(t, db.Users, "loganga")
+
+	register.Password = "correct horse"
+	register.Email = ""
+	closures, err = register.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusFail, status(closures), "an email address should be required")
+
+	register.Email = geneMeta.Email
+	closures, err = register.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	assert.Equal(t, dbfs.UserStatusPending, db.Users["loganga"].Status)
+	firstToken := sendMail(closures)
+	assert.NotEmpty(t, firstToken)
+	assert.Equal(t, geneMeta.Email, fake.sent[0].To)
+	assert.Empty(t, db.Invites[inviteID].Username, "invites should not be bound until the email is verified")
+
+	login := userLoginRequest{Username: "loganga", Password: "correct horse"}
+	setBaseFields(&login)
+	closures, err = login.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusUnauthorized, status(closures), "unverified users should not be able to log in")
+
+	resend := userResendVerificationRequest{Username: "loganga", Password: "wrong"}
+	setBaseFields(&resend)
+	closures, err = resend.process(db)
+	assert.Equal(t, errInvalidCredentials, err)
+	assert.Equal(t, messages.StatusUnauthorized, status(closures))
+
+	resend.Password = "correct horse"
+	closures, err = resend.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	secondToken := sendMail(closures)
+	assert.NotEqual(t, firstToken, secondToken)
+
+	verify := userVerifyEmailRequest{Token: firstToken}
+	setBaseFields(&verify)
+	closures, err = verify.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusNotFound, status(closures), "resending should replace the earlier token")
+
+	verify.Token = secondToken
+	closures, err = verify.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+	assert.Equal(t, "User.VerifyEmail", closures[1].(auditClosure).entry.Action)
+	assert.Equal(t, dbfs.UserStatusActive, db.Users["loganga"].Status)
+	assert.Equal(t, "loganga", db.Invites[inviteID].Username)
+
+	closures, err = verify.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusNotFound, status(closures), "tokens should only be usable once")
+
+	closures, err = login.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, status(closures))
+
+	closures, err = resend.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusNotFound, status(closures), "verified users should not be sent tokens")
+}
+
+func TestUserSearchRequest_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.Users["loganga"] = dbfs.UserMeta{Username: "loganga", FirstName: "Gene", LastName: "Logan"}
+	db.Users["genevieve"] = dbfs.UserMeta{Username: "genevieve", FirstName: "Genevieve", LastName: "Shapiro"}
+	db.Users["jshap70"] = dbfs.UserMeta{Username: "jshap70", FirstName: "Joel", LastName: "Shapiro", Email: "joel@example.com"}
+	db.Users["hiddengene"] = dbfs.UserMeta{Username: "hiddengene", HiddenFromSearch: true}
+	db.Projects["loganga"] = []dbfs.ProjectMeta{{ProjectID: 1, Name: "shared", PermissionLevel: 10}}
+	db.Projects["jshap70"] = []dbfs.ProjectMeta{{ProjectID: 1, Name: "shared", PermissionLevel: 5}}
+	response := func(closures []dhClosure) messages.Response {
+		return closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response)
+	}
+	usernames := func(closures []dhClosure) []string {
+		names := []string{}
+		for _, user := range response(closures).Data.(struct{ Users []dbfs.UserSearchResult }).Users {
+			names = append(names, user.Username)
+		}
+		return names
+	}
+
+	search := userSearchRequest{Query: "gene"}
+	setBaseFields(&search)
+	closures, err := search.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusSuccess, response(closures).Status)
+	assert.Equal(t, []string{"genevieve", "loganga"}, usernames(closures), "prefixes of usernames should rank first, and hidden users be excluded")
+
+	search.Query = "JOEL@"
+	closures, err = search.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"jshap70"}, usernames(closures), "emails should be searched case-insensitively")
+
+	// access to the project is reported, so that the UI can gray out current members
+	search.Query = "shapiro"
+	search.ProjectID = 1
+	closures, err = search.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []dbfs.UserSearchResult{
+		{Username: "genevieve", FirstName: "Genevieve", LastName: "Shapiro"},
+		{Username: "jshap70", FirstName: "Joel", LastName: "Shapiro", HasAccess: true},
+	}, response(closures).Data.(struct{ Users []dbfs.UserSearchResult }).Users)
+
+	search.Offset = 1
+	search.Limit = 1
+	closures, err = search.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"jshap70"}, usernames(closures))
+
+	search.Offset = -1
+	closures, err = search.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusFail, response(closures).Status)
+
+	search.Offset = 0
+	search.Query = "  "
+	closures, err = search.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusFail, response(closures).Status)
+
+	search.Query = "gene"
+	search.ProjectID = 2
+	closures, err = search.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusUnauthorized, response(closures).Status, "only members should see who has access to a project")
+
+	// users can hide themselves from search
+	hidden := true
+	update := userUpdateRequest{HiddenFromSearch: &hidden}
+	setBaseFields(&update)
+	closures, err = update.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, "hidden from search: true", closures[len(closures)-1].(auditClosure).entry.Detail)
+	assert.True(t, db.Users["loganga"].HiddenFromSearch)
+
+	search.ProjectID = 0
+	closures, err = search.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"genevieve"}, usernames(closures))
+}
+
+func TestUserNotifications_Process(t *testing.T) {
+	configSetup(t)
+	db := dbfs.NewDBMock()
+	db.Users["loganga"] = geneMeta
+	db.Users["notloganga"] = dbfs.UserMeta{Username: "notloganga"}
+	projectID, err := db.MySQLProjectCreate("loganga", "new stuff")
+	assert.NoError(t, err)
+	messageChan := make(chan rabbitmq.AMQPMessage, 10)
+	dh := DataHandler{Db: db, MessageChan: messageChan, WebsocketID: 1}
+	run := func(closures []dhClosure) {
+		for _, closure := range closures {
+			assert.NoError(t, closure.call(dh))
+		}
+	}
+
+	// notifications to a user's channel are kept in their inbox
+	perm, _ := config.PermissionByLabel("write")
+	grant := projectGrantPermissionsRequest{ProjectID: projectID, GrantUsername: "notloganga", PermissionLevel: perm.Level}
+	setBaseFields(&grant)
+	grant.Resource = "Project"
+	grant.Method = "GrantPermissions"
+	closures, err := grant.process(db)
+	assert.NoError(t, err)
+	run(closures)
+	if assert.Len(t, db.Notifications["notloganga"], 1) {
+		stored := db.Notifications["notloganga"][0]
+		assert.Equal(t, "GrantPermissions", stored.Method)
+		assert.Equal(t, projectID, stored.ResourceID)
+		assert.JSONEq(t, fmt.Sprintf(`{"GrantUsername": "notloganga", "PermissionLevel": %d}`, perm.Level), string(stored.Data))
+	}
+	assert.Empty(t, db.Notifications["loganga"], "notifications to the project channel should not be stored")
+
+	// as are deletions of shared projects, which are only sent to the project channel
+	del := projectDeleteRequest{ProjectID: projectID}
+	setBaseFields(&del)
+	del.Resource = "Project"
+	del.Method = "Delete"
+	closures, err = del.process(db)
+	assert.NoError(t, err)
+	run(closures)
+	assert.Len(t, db.Notifications["notloganga"], 2)
+	assert.Empty(t, db.Notifications["loganga"], "the sender should not be told about their own deletion")
+
+	// the unread backlog is sent after logging in
+	for len(messageChan) > 0 {
+		<-messageChan
+	}
+	login, err := loginClosures("notloganga", 1, "")
+	assert.NoError(t, err)
+	run(login)
+	var backlog struct {
+		ServerMessage struct {
+			Resource string
+			Method   string
+			Data     struct {
+				Notifications []dbfs.Notification
+				Unread        int
+			}
+		}
+	}
+	assert.Len(t, messageChan, 3)
+	<-messageChan
+	<-messageChan
+	assert.NoError(t, json.Unmarshal((<-messageChan).Message, &backlog))
+	assert.Equal(t, "Notifications", backlog.ServerMessage.Method)
+	assert.Equal(t, 2, backlog.ServerMessage.Data.Unread)
+	if assert.Len(t, backlog.ServerMessage.Data.Notifications, 2) {
+		assert.Equal(t, "Delete", backlog.ServerMessage.Data.Notifications[0].Method, "newer notifications should be first")
+	}
+
+	get := userGetNotificationsRequest{UnreadOnly: true, Limit: 1}
+	setBaseFields(&get)
+	get.SenderID = "notloganga"
+	closures, err = get.process(db)
+	assert.NoError(t, err)
+	data := closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Data.(struct {
+		Notifications []dbfs.Notification
+		Unread        int
+	})
+	assert.Equal(t, 2, data.Unread)
+	if assert.Len(t, data.Notifications, 1) {
+		assert.Equal(t, "Delete", data.Notifications[0].Method)
+	}
+
+	markRead := userMarkReadRequest{NotificationIDs: []int64{data.Notifications[0].NotificationID}}
+	setBaseFields(&markRead)
+	markRead.SenderID = "notloganga"
+	closures, err = markRead.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Data.(struct{ Marked int }).Marked)
+
+	get.Limit = 0
+	closures, err = get.process(db)
+	assert.NoError(t, err)
+	data = closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Data.(struct {
+		Notifications []dbfs.Notification
+		Unread        int
+	})
+	assert.Equal(t, 1, data.Unread)
+	if assert.Len(t, data.Notifications, 1) {
+		assert.Equal(t, "GrantPermissions", data.Notifications[0].Method)
+	}
+
+	// an empty list marks every notification, and nothing is sent after logging in once they are read
+	markRead.NotificationIDs = nil
+	closures, err = markRead.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Data.(struct{ Marked int }).Marked)
+	run(login)
+	assert.Len(t, messageChan, 2)
+
+	get.Offset = -1
+	closures, err = get.process(db)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.StatusFail, closures[0].(toSenderClosure).msg.ServerMessage.(messages.Response).Status)
+}
@@ -0,0 +1,106 @@
+package dbfs
+
+import (
+	"strings"
+
+	"github.com/CodeCollaborate/Server/modules/patching"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+// UpdateCommentAnchors shifts the anchors of the file's comment threads through the changes made to the file since
+// they were last moved, returning the IDs of the threads which were orphaned by the changes.
+func UpdateCommentAnchors(db DBFS, meta FileMeta) ([]int64, error) {
+	threads, err := db.MySQLCommentThreadsGet(meta.FileID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	stale := []CommentThread{}
+	for _, thread := range threads {
+		if !thread.Orphaned {
+			stale = append(stale, thread)
+		}
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	changes, _, version, _, err := db.PullChanges(meta)
+	if err != nil {
+		return nil, err
+	}
+	patches, err := patching.GetPatches(changes)
+	if err != nil {
+		return nil, err
+	}
+
+	orphaned := []int64{}
+	for _, thread := range stale {
+		if thread.FileVersion >= version {
+			continue
+		}
+
+		anchor := ShiftCommentAnchor(thread.CommentAnchor, patches)
+		err := db.MySQLCommentAnchorUpdate(thread.ThreadID, thread.FileVersion, anchor)
+		if err == ErrNoDbChange {
+			// another request has already moved it
+			continue
+		} else if err != nil {
+			return orphaned, err
+		}
+		if anchor.Orphaned {
+			orphaned = append(orphaned, thread.ThreadID)
+		}
+	}
+	return orphaned, nil
+}
+
+// ShiftCommentAnchor moves the anchor through the patches applied to the file since the anchor's version, in order.
+// The anchor is orphaned if its text is deleted, or if the patches it needs have already been scrunched.
+func ShiftCommentAnchor(anchor CommentAnchor, patches []*patching.Patch) CommentAnchor {
+	for _, patch := range patches {
+		if anchor.Orphaned {
+			break
+		}
+		if patch.BaseVersion < anchor.FileVersion {
+			continue
+		}
+		if patch.BaseVersion > anchor.FileVersion {
+			utils.LogWarn("Changes needed to shift comment anchor have been scrunched", utils.LogFields{
+				"AnchorVersion": anchor.FileVersion,
+				"PatchVersion":  patch.BaseVersion,
+			})
+			anchor.Orphaned = true
+			break
+		}
+		anchor = shiftCommentAnchor(anchor, patch)
+	}
+	return anchor
+}
+
+// shiftCommentAnchor moves the anchor through a single patch. The anchored range is treated as a deletion made
+// concurrently with the patch, and transformed against it in the same way as conflicting changes are; what is left
+// of the deletion is the anchored text remaining after the patch.
+func shiftCommentAnchor(anchor CommentAnchor, patch *patching.Patch) CommentAnchor {
+	anchor.FileVersion = patch.BaseVersion + 1
+	if anchor.StartIndex < 0 || anchor.EndIndex <= anchor.StartIndex || anchor.EndIndex > patch.DocLength {
+		anchor.Orphaned = true
+		return anchor
+	}
+
+	anchored := patching.NewPatch(patch.BaseVersion, patching.Diffs{
+		patching.NewDiff(false, anchor.StartIndex, strings.Repeat(" ", anchor.EndIndex-anchor.StartIndex)),
+	}, patch.DocLength)
+	result, err := patching.TransformPatches(anchored, patch)
+	if err != nil || len(result.PatchXPrime.Changes) == 0 {
+		anchor.Orphaned = true
+		return anchor
+	}
+
+	// text inserted within the range is included in it, so the range spans from the first to the last remaining part
+	remaining := result.PatchXPrime.Changes
+	last := remaining[len(remaining)-1]
+	anchor.StartIndex = remaining[0].StartIndex
+	anchor.EndIndex = last.StartIndex + last.Length()
+	return anchor
+}
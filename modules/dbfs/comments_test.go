@@ -0,0 +1,116 @@
+package dbfs
+
+import (
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/patching"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShiftCommentAnchor(t *testing.T) {
+	tests := []struct {
+		desc     string
+		patches  []string
+		expected CommentAnchor
+	}{
+		{
+			desc:     "insertion before the anchor",
+			patches:  []string{"v1:\n0:+3:abc:\n20"},
+			expected: CommentAnchor{StartIndex: 8, EndIndex: 13, FileVersion: 2},
+		},
+		{
+			desc:     "insertion within the anchor",
+			patches:  []string{"v1:\n7:+2:ab:\n20"},
+			expected: CommentAnchor{StartIndex: 5, EndIndex: 12, FileVersion: 2},
+		},
+		{
+			desc:     "insertion at the start of the anchor",
+			patches:  []string{"v1:\n5:+2:ab:\n20"},
+			expected: CommentAnchor{StartIndex: 7, EndIndex: 12, FileVersion: 2},
+		},
+		{
+			desc:     "insertion at the end of the anchor",
+			patches:  []string{"v1:\n10:+2:ab:\n20"},
+			expected: CommentAnchor{StartIndex: 5, EndIndex: 10, FileVersion: 2},
+		},
+		{
+			desc:     "deletion overlapping the start of the anchor",
+			patches:  []string{"v1:\n3:-4:abcd:\n20"},
+			expected: CommentAnchor{StartIndex: 3, EndIndex: 6, FileVersion: 2},
+		},
+		{
+			desc:     "deletion after the anchor",
+			patches:  []string{"v1:\n12:-4:abcd:\n20"},
+			expected: CommentAnchor{StartIndex: 5, EndIndex: 10, FileVersion: 2},
+		},
+		{
+			desc:     "deletion of the anchored text",
+			patches:  []string{"v1:\n4:-7:abcdefg:\n20"},
+			expected: CommentAnchor{FileVersion: 2, StartIndex: 5, EndIndex: 10, Orphaned: true},
+		},
+		{
+			desc: "patches before the anchor's version are skipped",
+			patches: []string{
+				"v0:\n0:+20:aaaaaaaaaaaaaaaaaaaa:\n0",
+				"v1:\n0:+1:a:\n20",
+				"v2:\n0:-2:aa:\n21",
+			},
+			expected: CommentAnchor{StartIndex: 4, EndIndex: 9, FileVersion: 3},
+		},
+		{
+			desc:     "scrunched patches",
+			patches:  []string{"v2:\n0:+1:a:\n20"},
+			expected: CommentAnchor{StartIndex: 5, EndIndex: 10, FileVersion: 1, Orphaned: true},
+		},
+		{
+			desc:     "anchor beyond the end of the file",
+			patches:  []string{"v1:\n0:+1:a:\n8"},
+			expected: CommentAnchor{StartIndex: 5, EndIndex: 10, FileVersion: 2, Orphaned: true},
+		},
+	}
+
+	for _, test := range tests {
+		patches, err := patching.GetPatches(test.patches)
+		require.NoError(t, err, test.desc)
+
+		anchor := ShiftCommentAnchor(CommentAnchor{StartIndex: 5, EndIndex: 10, FileVersion: 1}, patches)
+		assert.Equal(t, test.expected, anchor, test.desc)
+	}
+}
+
+func TestUpdateCommentAnchors(t *testing.T) {
+	db := NewDBMock()
+	meta := FileMeta{FileID: 7}
+	shifted, _, _ := db.MySQLCommentThreadCreate(CommentThread{
+		FileID:        meta.FileID,
+		CommentAnchor: CommentAnchor{StartIndex: 5, EndIndex: 10, FileVersion: 1},
+		CreatedBy:     "loganga",
+	}, "shifted")
+	deleted, _, _ := db.MySQLCommentThreadCreate(CommentThread{
+		FileID:        meta.FileID,
+		CommentAnchor: CommentAnchor{StartIndex: 12, EndIndex: 14, FileVersion: 1},
+		CreatedBy:     "loganga",
+	}, "deleted")
+	current, _, _ := db.MySQLCommentThreadCreate(CommentThread{
+		FileID:        meta.FileID,
+		CommentAnchor: CommentAnchor{StartIndex: 0, EndIndex: 2, FileVersion: 3},
+		CreatedBy:     "loganga",
+	}, "already up to date")
+
+	db.FileChanges[meta.FileID] = []string{"v1:\n0:+2:ab:\n20", "v2:\n13:-4:abcd:\n22"}
+	db.FileVersion[meta.FileID] = 3
+
+	orphaned, err := UpdateCommentAnchors(db, meta)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{deleted}, orphaned)
+	assert.Equal(t, CommentAnchor{StartIndex: 7, EndIndex: 12, FileVersion: 3}, db.CommentThreads[shifted].CommentAnchor)
+	assert.True(t, db.CommentThreads[deleted].Orphaned)
+	assert.Equal(t, CommentAnchor{StartIndex: 0, EndIndex: 2, FileVersion: 3}, db.CommentThreads[current].CommentAnchor)
+
+	// anchors are only moved once
+	orphaned, err = UpdateCommentAnchors(db, meta)
+	assert.NoError(t, err)
+	assert.Empty(t, orphaned)
+	assert.Equal(t, CommentAnchor{StartIndex: 7, EndIndex: 12, FileVersion: 3}, db.CommentThreads[shifted].CommentAnchor)
+}
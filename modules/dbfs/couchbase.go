@@ -90,6 +90,9 @@ func (di *DatabaseImpl) openCouchBase() (*couchbaseConn, error) {
 func (di *DatabaseImpl) CloseCouchbase() error {
 	if di.couchbaseDB != nil && di.couchbaseDB.bucket != nil {
 		di.couchbaseDB.bucket.Close()
+		if di.couchbaseDB.scrunchingLocksBucket != nil {
+			di.couchbaseDB.scrunchingLocksBucket.Close()
+		}
 		di.couchbaseDB = nil
 	} else {
 		return ErrDbNotInitialized
@@ -98,6 +101,18 @@ func (di *DatabaseImpl) CloseCouchbase() error {
 	return nil
 }
 
+// CBPing opens the Couchbase buckets if needed, returning an error if either of them are unavailable
+func (di *DatabaseImpl) CBPing() error {
+	cb, err := di.openCouchBase()
+	if err != nil {
+		return err
+	}
+	if cb.bucket == nil || cb.scrunchingLocksBucket == nil {
+		return ErrDbNotInitialized
+	}
+	return nil
+}
+
 // CBInsertNewFile inserts a new document into couchbase with CBFile.FileID == fileID
 func (di *DatabaseImpl) cbInsertNewFile(file cbFile) error {
 	cb, err := di.openCouchBase()
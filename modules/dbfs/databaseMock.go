@@ -3,6 +3,8 @@ package dbfs
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/CodeCollaborate/Server/modules/config"
@@ -20,8 +22,71 @@ type DatabaseMock struct {
 	FileVersion map[int64]int64
 	FileChanges map[int64][]string
 
-	ProjectIDCounter int64
-	FileIDCounter    int64
+	ProjectRoles    map[int64][]config.Role
+	PathPermissions map[int64][]PathPermission
+	AuditLog        []AuditEntry
+
+	Groups       map[int64]GroupMeta
+	GroupMembers map[int64][]string
+	// GroupPermissions maps ProjectIDs to the permission levels granted to each GroupID
+	GroupPermissions map[int64]map[int64]int8
+
+	Invites   map[int64]Invite
+	Transfers map[int64]OwnershipTransfer
+
+	ShareLinks map[int64]ShareLink
+	// ShareLinkHashes maps the token hashes of share links to their ShareLinkIDs
+	ShareLinkHashes map[string]int64
+
+	// ExternalIdentities maps identity providers to the usernames linked to each subject
+	ExternalIdentities map[string]map[string]string
+
+	TwoFactor map[string]TwoFactor
+	// RecoveryCodes maps usernames to the hashes of their unused recovery codes
+	RecoveryCodes map[string][]string
+	// EmailVerifications maps the hashes of email verification tokens to the verifications
+	EmailVerifications map[string]EmailVerification
+	// LoginFailures is keyed on the kind and subject, as "kind:subject"
+	LoginFailures map[string]LoginFailures
+	// UsernameTombstones is keyed on the old username
+	UsernameTombstones map[string]UsernameTombstone
+
+	// Notifications maps usernames to their inboxes, oldest first
+	Notifications map[string][]Notification
+
+	CommentThreads map[int64]CommentThread
+	// Comments maps ThreadIDs to their comments, oldest first
+	Comments map[int64][]Comment
+
+	Suggestions map[int64]Suggestion
+
+	FileLocks map[int64]FileLock
+
+	Webhooks map[int64]Webhook
+	// WebhookDeliveries maps WebhookIDs to their deliveries, oldest first
+	WebhookDeliveries map[int64][]WebhookDelivery
+
+	AccessTokens map[int64]AccessToken
+	// AccessTokenHashes maps the hashes of access tokens to their TokenIDs
+	AccessTokenHashes map[string]int64
+
+	TrashedProjects map[int64]TrashedProject
+	// TrashedProjectMembers holds the entries removed from Projects when a project is trashed, keyed on username
+	TrashedProjectMembers map[int64]map[string]ProjectMeta
+	TrashedFiles          map[int64]TrashedFile
+
+	ProjectIDCounter      int64
+	FileIDCounter         int64
+	GroupIDCounter        int64
+	InviteIDCounter       int64
+	ShareLinkIDCounter    int64
+	AccessTokenIDCounter  int64
+	NotificationIDCounter int64
+	ThreadIDCounter       int64
+	CommentIDCounter      int64
+	SuggestionIDCounter   int64
+	WebhookIDCounter      int64
+	DeliveryIDCounter     int64
 
 	File *[]byte
 	Swp  *[]byte
@@ -40,6 +105,47 @@ func NewDBMock() *DatabaseMock {
 		Files:       make(map[int64]([]FileMeta)),
 		FileVersion: make(map[int64]int64),
 		FileChanges: make(map[int64][]string),
+
+		ProjectRoles:    make(map[int64][]config.Role),
+		PathPermissions: make(map[int64][]PathPermission),
+
+		Groups:           make(map[int64]GroupMeta),
+		GroupMembers:     make(map[int64][]string),
+		GroupPermissions: make(map[int64]map[int64]int8),
+
+		Invites:   make(map[int64]Invite),
+		Transfers: make(map[int64]OwnershipTransfer),
+
+		ShareLinks:      make(map[int64]ShareLink),
+		ShareLinkHashes: make(map[string]int64),
+
+		ExternalIdentities: make(map[string]map[string]string),
+
+		TwoFactor:     make(map[string]TwoFactor),
+		RecoveryCodes: make(map[string][]string),
+
+		EmailVerifications: make(map[string]EmailVerification),
+		LoginFailures:      make(map[string]LoginFailures),
+		UsernameTombstones: make(map[string]UsernameTombstone),
+
+		Notifications: make(map[string][]Notification),
+
+		CommentThreads: make(map[int64]CommentThread),
+		Comments:       make(map[int64][]Comment),
+
+		Suggestions: make(map[int64]Suggestion),
+
+		FileLocks: make(map[int64]FileLock),
+
+		Webhooks:          make(map[int64]Webhook),
+		WebhookDeliveries: make(map[int64][]WebhookDelivery),
+
+		AccessTokens:      make(map[int64]AccessToken),
+		AccessTokenHashes: make(map[string]int64),
+
+		TrashedProjects:       make(map[int64]TrashedProject),
+		TrashedProjectMembers: make(map[int64]map[string]ProjectMeta),
+		TrashedFiles:          make(map[int64]TrashedFile),
 	}
 }
 
@@ -51,6 +157,12 @@ func (dm *DatabaseMock) CloseCouchbase() error {
 	return nil
 }
 
+// CBPing is a mock of the real implementation
+func (dm *DatabaseMock) CBPing() error {
+	dm.FunctionCallCount++
+	return nil
+}
+
 // CBInsertNewFile is a mock of the real implementation
 func (dm *DatabaseMock) CBInsertNewFile(fileID int64, version int64, changes []string) error {
 	dm.FileVersion[fileID] = version
@@ -164,16 +276,40 @@ func (dm *DatabaseMock) CloseMySQL() error {
 	return nil
 }
 
+// MySQLPing is a mock of the real implementation
+func (dm *DatabaseMock) MySQLPing() error {
+	dm.FunctionCallCount++
+	return nil
+}
+
 // MySQLUserRegister is a mock of the real implementation
 func (dm *DatabaseMock) MySQLUserRegister(user UserMeta) error {
 	if _, ok := dm.Users[user.Username]; ok {
 		return ErrNoDbChange
 	}
+	for _, existing := range dm.Users {
+		if user.Email != "" && existing.Email == user.Email {
+			return ErrNoDbChange
+		}
+	}
 	dm.Users[user.Username] = user
 	dm.FunctionCallCount++
 	return nil
 }
 
+// MySQLUserRegisterBot is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserRegisterBot(bot UserMeta) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.Users[bot.Username]; ok {
+		return ErrNoDbChange
+	}
+	bot.Password = ""
+	bot.Email = ""
+	bot.Bot = true
+	dm.Users[bot.Username] = bot
+	return nil
+}
+
 // MySQLUserGetPass is a mock of the real implementation
 func (dm *DatabaseMock) MySQLUserGetPass(username string) (string, error) {
 	dm.FunctionCallCount++
@@ -238,10 +374,253 @@ func (dm *DatabaseMock) MySQLUserLookup(username string) (user UserMeta, err err
 	return user, err
 }
 
+// MySQLUserLookupEmail is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserLookupEmail(email string) (UserMeta, error) {
+	dm.FunctionCallCount++
+	for _, user := range dm.Users {
+		if email != "" && user.Email == email {
+			return user, nil
+		}
+	}
+	return UserMeta{}, ErrNoData
+}
+
+// MySQLUserUpdateProfile is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserUpdateProfile(username string, firstName string, lastName string) error {
+	dm.FunctionCallCount++
+	user, ok := dm.Users[username]
+	if !ok || (user.FirstName == firstName && user.LastName == lastName) {
+		return ErrNoDbChange
+	}
+	user.FirstName = firstName
+	user.LastName = lastName
+	dm.Users[username] = user
+	return nil
+}
+
+// MySQLUserSetEmail is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserSetEmail(username string, email string) error {
+	dm.FunctionCallCount++
+	user, ok := dm.Users[username]
+	if !ok || user.Email == email {
+		return ErrNoDbChange
+	}
+	for _, existing := range dm.Users {
+		if email != "" && existing.Email == email {
+			return fmt.Errorf("Duplicate entry '%s' for key 'Email_UNIQUE'", email)
+		}
+	}
+	user.Email = email
+	dm.Users[username] = user
+	return nil
+}
+
+// MySQLUserSetHiddenFromSearch is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserSetHiddenFromSearch(username string, hidden bool) error {
+	dm.FunctionCallCount++
+	user, ok := dm.Users[username]
+	if !ok || user.HiddenFromSearch == hidden {
+		return ErrNoDbChange
+	}
+	user.HiddenFromSearch = hidden
+	dm.Users[username] = user
+	return nil
+}
+
+// MySQLUserSearch is a mock of the real implementation. It does not match users by how their names sound.
+func (dm *DatabaseMock) MySQLUserSearch(query string, projectID int64, offset int, limit int) ([]UserSearchResult, error) {
+	dm.FunctionCallCount++
+	query = strings.ToLower(query)
+	matchRank := func(user UserMeta) int {
+		username := strings.ToLower(user.Username)
+		fullName := strings.ToLower(user.FirstName + " " + user.LastName)
+		switch {
+		case username == query:
+			return 0
+		case strings.HasPrefix(username, query):
+			return 1
+		case strings.HasPrefix(strings.ToLower(user.FirstName), query), strings.HasPrefix(strings.ToLower(user.LastName), query),
+			strings.HasPrefix(fullName, query), user.Email != "" && strings.HasPrefix(strings.ToLower(user.Email), query):
+			return 2
+		case strings.Contains(username, query), strings.Contains(fullName, query):
+			return 3
+		}
+		return -1
+	}
+
+	_, permissions, _ := dm.MySQLProjectLookup(projectID, "")
+	ranks := make(map[string]int)
+	results := []UserSearchResult{}
+	for _, user := range dm.Users {
+		rank := matchRank(user)
+		if user.HiddenFromSearch || rank < 0 {
+			continue
+		}
+		_, hasAccess := permissions[user.Username]
+		ranks[user.Username] = rank
+		results = append(results, UserSearchResult{
+			Username:  user.Username,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Bot:       user.Bot,
+			HasAccess: hasAccess,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if ranks[results[i].Username] != ranks[results[j].Username] {
+			return ranks[results[i].Username] < ranks[results[j].Username]
+		}
+		return results[i].Username < results[j].Username
+	})
+
+	if offset >= len(results) {
+		return []UserSearchResult{}, nil
+	}
+	results = results[offset:]
+	if limit < len(results) {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// MySQLUserRename is a mock of the real implementation. It moves the user's account, projects, bots, two-factor
+// authentication and access tokens to the new username.
+func (dm *DatabaseMock) MySQLUserRename(oldUsername string, newUsername string) error {
+	dm.FunctionCallCount++
+	user, ok := dm.Users[oldUsername]
+	if !ok {
+		return ErrNoDbChange
+	}
+	if _, ok := dm.Users[newUsername]; ok {
+		return fmt.Errorf("Duplicate entry '%s' for key 'PRIMARY'", newUsername)
+	}
+
+	delete(dm.Users, oldUsername)
+	user.Username = newUsername
+	dm.Users[newUsername] = user
+	for username, bot := range dm.Users {
+		if bot.BotOwner == oldUsername {
+			bot.BotOwner = newUsername
+			dm.Users[username] = bot
+		}
+	}
+	if projects, ok := dm.Projects[oldUsername]; ok {
+		delete(dm.Projects, oldUsername)
+		dm.Projects[newUsername] = projects
+	}
+	if twoFactor, ok := dm.TwoFactor[oldUsername]; ok {
+		delete(dm.TwoFactor, oldUsername)
+		dm.TwoFactor[newUsername] = twoFactor
+	}
+	if codes, ok := dm.RecoveryCodes[oldUsername]; ok {
+		delete(dm.RecoveryCodes, oldUsername)
+		dm.RecoveryCodes[newUsername] = codes
+	}
+	if inbox, ok := dm.Notifications[oldUsername]; ok {
+		delete(dm.Notifications, oldUsername)
+		dm.Notifications[newUsername] = inbox
+	}
+	for threadID, thread := range dm.CommentThreads {
+		if thread.CreatedBy == oldUsername {
+			thread.CreatedBy = newUsername
+		}
+		if thread.ResolvedBy == oldUsername {
+			thread.ResolvedBy = newUsername
+		}
+		dm.CommentThreads[threadID] = thread
+		for i, comment := range dm.Comments[threadID] {
+			if comment.Author == oldUsername {
+				dm.Comments[threadID][i].Author = newUsername
+			}
+		}
+	}
+	for suggestionID, suggestion := range dm.Suggestions {
+		if suggestion.Author == oldUsername {
+			suggestion.Author = newUsername
+			dm.Suggestions[suggestionID] = suggestion
+		}
+	}
+	for fileID, lock := range dm.FileLocks {
+		if lock.Username == oldUsername {
+			lock.Username = newUsername
+			dm.FileLocks[fileID] = lock
+		}
+	}
+	for tokenID, token := range dm.AccessTokens {
+		if token.Username == oldUsername {
+			token.Username = newUsername
+			dm.AccessTokens[tokenID] = token
+		}
+	}
+
+	dm.UsernameTombstones[oldUsername] = UsernameTombstone{
+		Username:    oldUsername,
+		RenamedTo:   newUsername,
+		RenamedDate: time.Now(),
+	}
+	return nil
+}
+
+// MySQLUsernameTombstoneGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUsernameTombstoneGet(username string) (UsernameTombstone, error) {
+	dm.FunctionCallCount++
+	tombstone, ok := dm.UsernameTombstones[username]
+	if !ok {
+		return UsernameTombstone{}, ErrNoData
+	}
+	return tombstone, nil
+}
+
 // MySQLUserProjects is a mock of the real implementation
 func (dm *DatabaseMock) MySQLUserProjects(username string) ([]ProjectMeta, error) {
 	dm.FunctionCallCount++
-	return dm.Projects[username], nil
+	projects := append([]ProjectMeta{}, dm.Projects[username]...)
+	for projectID := range dm.GroupPermissions {
+		found := false
+		for i, project := range projects {
+			if project.ProjectID == projectID {
+				found = true
+				if level, ok := dm.groupPermissionLevel(projectID, username); ok && level > project.PermissionLevel {
+					projects[i].PermissionLevel = level
+				}
+			}
+		}
+		if level, ok := dm.groupPermissionLevel(projectID, username); ok && !found {
+			projects = append(projects, ProjectMeta{
+				ProjectID:       projectID,
+				Name:            dm.projectName(projectID),
+				PermissionLevel: level,
+			})
+		}
+	}
+	return projects, nil
+}
+
+// groupPermissionLevel returns the highest level granted on the project to the groups the user is a member of
+func (dm *DatabaseMock) groupPermissionLevel(projectID int64, username string) (int8, bool) {
+	var level int8
+	found := false
+	for groupID, groupLevel := range dm.GroupPermissions[projectID] {
+		for _, member := range dm.GroupMembers[groupID] {
+			if member == username && (!found || groupLevel > level) {
+				level = groupLevel
+				found = true
+			}
+		}
+	}
+	return level, found
+}
+
+// projectName returns the name of the project with the given projectID
+func (dm *DatabaseMock) projectName(projectID int64) string {
+	for _, projects := range dm.Projects {
+		for _, project := range projects {
+			if project.ProjectID == projectID {
+				return project.Name
+			}
+		}
+	}
+	return ""
 }
 
 // MySQLProjectCreate is a mock of the real implementation
@@ -262,24 +641,98 @@ func (dm *DatabaseMock) MySQLProjectCreate(username string, projectName string)
 // MySQLProjectDelete is a mock of the real implementation
 func (dm *DatabaseMock) MySQLProjectDelete(projectID int64, senderID string) error {
 	dm.FunctionCallCount++
-	// so this is kinda horrible, but it is easy to follow what's going on
 	for username, projects := range dm.Projects {
-		var index = int64(-1)
 		for i, proj := range projects {
 			if proj.ProjectID == projectID {
-				index = int64(i)
+				dm.Projects[username] = append(projects[:i:i], projects[i+1:]...)
+				break
 			}
 		}
-		if int64(len(dm.Projects[username])) > index+1 {
-			dm.Projects[username] = append(dm.Projects[username][:index], dm.Projects[username][(index+1):]...)
-		} else {
-			dm.Projects[username] = dm.Projects[username][:index]
+	}
+	delete(dm.Files, projectID)
+	delete(dm.TrashedProjects, projectID)
+	delete(dm.TrashedProjectMembers, projectID)
+	for fileID, file := range dm.TrashedFiles {
+		if file.ProjectID == projectID {
+			delete(dm.TrashedFiles, fileID)
+		}
+	}
+	return nil
+}
+
+// MySQLProjectTrash is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectTrash(projectID int64, senderID string) error {
+	dm.FunctionCallCount++
+	isOwner := false
+	for _, proj := range dm.Projects[senderID] {
+		if proj.ProjectID == projectID && proj.PermissionLevel == config.OwnerLevel {
+			isOwner = true
+		}
+	}
+	if !isOwner {
+		return ErrNoDbChange
+	}
+
+	dm.TrashedProjects[projectID] = TrashedProject{
+		ProjectID:   projectID,
+		Name:        dm.projectName(projectID),
+		Owner:       senderID,
+		DeletedDate: time.Now(),
+	}
+	members := make(map[string]ProjectMeta)
+	for username, projects := range dm.Projects {
+		for i, proj := range projects {
+			if proj.ProjectID == projectID {
+				members[username] = proj
+				dm.Projects[username] = append(projects[:i:i], projects[i+1:]...)
+				break
+			}
 		}
-		delete(dm.Files, index)
 	}
+	dm.TrashedProjectMembers[projectID] = members
+	return nil
+}
+
+// MySQLProjectRestore is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectRestore(projectID int64, senderID string) error {
+	dm.FunctionCallCount++
+	project, ok := dm.TrashedProjects[projectID]
+	if !ok || project.Owner != senderID {
+		return ErrNoDbChange
+	}
+
+	for username, proj := range dm.TrashedProjectMembers[projectID] {
+		dm.Projects[username] = append(dm.Projects[username], proj)
+	}
+	delete(dm.TrashedProjects, projectID)
+	delete(dm.TrashedProjectMembers, projectID)
 	return nil
 }
 
+// MySQLUserTrashedProjects is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserTrashedProjects(username string) ([]TrashedProject, error) {
+	dm.FunctionCallCount++
+	projects := []TrashedProject{}
+	for _, project := range dm.TrashedProjects {
+		if project.Owner == username {
+			projects = append(projects, project)
+		}
+	}
+	return projects, nil
+}
+
+// MySQLExpiredTrashedProjects is a mock of the real implementation
+func (dm *DatabaseMock) MySQLExpiredTrashedProjects(deletedBefore time.Time) ([]TrashedProject, error) {
+	dm.FunctionCallCount++
+	projects := []TrashedProject{}
+	for _, project := range dm.TrashedProjects {
+		if project.DeletedDate.Before(deletedBefore) {
+			projects = append(projects, project)
+		}
+	}
+	return projects, nil
+}
+
 // MySQLProjectGetFiles is a mock of the real implementation
 func (dm *DatabaseMock) MySQLProjectGetFiles(projectID int64) ([]FileMeta, error) {
 	dm.FunctionCallCount++
@@ -292,28 +745,24 @@ func (dm *DatabaseMock) MySQLProjectGrantPermission(projectID int64, grantUserna
 	found := false
 
 	// check if you're changing permission rather than adding
-	for _, proj := range dm.Projects[grantUsername] {
+	for i, proj := range dm.Projects[grantUsername] {
 		if proj.ProjectID == projectID {
-			proj.PermissionLevel = permissionLevel
+			dm.Projects[grantUsername][i].PermissionLevel = permissionLevel
 			found = true
 			break
 		}
 	}
 	if !found {
-		// add if not found
-		for _, proj := range dm.Projects[grantedByUsername] {
-			if proj.ProjectID == projectID {
-				dm.Projects[grantUsername] = append(dm.Projects[grantUsername], ProjectMeta{
-					PermissionLevel: permissionLevel,
-					ProjectID:       projectID,
-					Name:            proj.Name,
-				})
-				found = true
-			}
-		}
-		if !found {
+		// add if not found; like the real implementation, the granter does not need to be a member
+		name := dm.projectName(projectID)
+		if name == "" {
 			return ErrNoDbChange
 		}
+		dm.Projects[grantUsername] = append(dm.Projects[grantUsername], ProjectMeta{
+			PermissionLevel: permissionLevel,
+			ProjectID:       projectID,
+			Name:            name,
+		})
 	}
 	return nil
 }
@@ -343,12 +792,17 @@ func (dm *DatabaseMock) MySQLProjectRevokePermission(projectID int64, revokeUser
 // MySQLUserProjectPermissionLookup returns the permission level of `username` on the project with the given projectID
 func (dm *DatabaseMock) MySQLUserProjectPermissionLookup(projectID int64, username string) (int8, error) {
 	dm.FunctionCallCount++
+	level, found := dm.groupPermissionLevel(projectID, username)
 	for _, proj := range dm.Projects[username] {
-		if proj.ProjectID == projectID {
-			return proj.PermissionLevel, nil
+		if proj.ProjectID == projectID && (!found || proj.PermissionLevel > level) {
+			level = proj.PermissionLevel
+			found = true
 		}
 	}
-	return 0, ErrNoData
+	if !found {
+		return 0, ErrNoData
+	}
+	return level, nil
 }
 
 // MySQLProjectRename is a mock of the real implementation
@@ -386,99 +840,1236 @@ func (dm *DatabaseMock) MySQLProjectLookup(projectID int64, username string) (na
 			}
 		}
 	}
+	for groupID, level := range dm.GroupPermissions[projectID] {
+		for _, member := range dm.GroupMembers[groupID] {
+			if existing, ok := permissions[member]; !ok || level > existing.PermissionLevel {
+				permissions[member] = ProjectPermission{
+					PermissionLevel: level,
+					Username:        member,
+				}
+			}
+		}
+	}
 	return name, permissions, err
 }
 
-// MySQLFileCreate is a mock of the real implementation
-func (dm *DatabaseMock) MySQLFileCreate(username string, filename string, relativePath string, projectID int64) (int64, error) {
+// MySQLProjectGetRoles is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectGetRoles(projectID int64) ([]config.Role, error) {
 	dm.FunctionCallCount++
-	dm.FileIDCounter++
-	dm.Files[projectID] = append(
-		dm.Files[projectID],
-		FileMeta{
-			ProjectID:    projectID,
-			CreationDate: time.Now(),
-			Creator:      username,
-			FileID:       dm.FileIDCounter,
-			Filename:     filename,
-			RelativePath: relativePath,
-		})
-	return dm.FileIDCounter, nil
+	return append([]config.Role{}, dm.ProjectRoles[projectID]...), nil
 }
 
-// MySQLFileDelete is a mock of the real implementation
-func (dm *DatabaseMock) MySQLFileDelete(fileID int64) error {
+// MySQLProjectSetRole is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectSetRole(projectID int64, role config.Role) error {
 	dm.FunctionCallCount++
-	for projectID, files := range dm.Files {
-		for i, file := range files {
-			if file.FileID == fileID {
-				if len(dm.Files[projectID]) > i+1 {
-					dm.Files[projectID] = append(
-						dm.Files[projectID][:i],
-						dm.Files[projectID][i+1:]...)
-				} else {
-					dm.Files[projectID] = dm.Files[projectID][:i]
-				}
-				delete(dm.FileVersion, fileID)
-				return nil
-			}
+	for i, existing := range dm.ProjectRoles[projectID] {
+		if existing.Level == role.Level {
+			dm.ProjectRoles[projectID][i] = role
+			return nil
 		}
-
 	}
-	return ErrNoDbChange
+	dm.ProjectRoles[projectID] = append(dm.ProjectRoles[projectID], role)
+	return nil
 }
 
-// MySQLFileMove is a mock of the real implementation
-func (dm *DatabaseMock) MySQLFileMove(fileID int64, newPath string) error {
+// MySQLProjectDeleteRole is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectDeleteRole(projectID int64, level int8) error {
 	dm.FunctionCallCount++
-	for _, files := range dm.Files {
-		for _, file := range files {
-			if file.FileID == fileID {
-				file.RelativePath = newPath
-				return nil
+	for _, projects := range dm.Projects {
+		for _, project := range projects {
+			if project.ProjectID == projectID && project.PermissionLevel == level {
+				return ErrNoDbChange
 			}
 		}
-
+	}
+	for _, rule := range dm.PathPermissions[projectID] {
+		if rule.PermissionLevel == level {
+			return ErrNoDbChange
+		}
+	}
+	for i, role := range dm.ProjectRoles[projectID] {
+		if role.Level == level {
+			dm.ProjectRoles[projectID] = append(dm.ProjectRoles[projectID][:i], dm.ProjectRoles[projectID][i+1:]...)
+			return nil
+		}
 	}
 	return ErrNoDbChange
 }
 
-// MySQLFileRename is a mock of the real implementation
-func (dm *DatabaseMock) MySQLFileRename(fileID int64, newName string) error {
+// MySQLProjectGetPathPermissions is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectGetPathPermissions(projectID int64) ([]PathPermission, error) {
 	dm.FunctionCallCount++
-	for _, files := range dm.Files {
-		for _, file := range files {
-			if file.FileID == fileID {
-				file.Filename = newName
-				return nil
-			}
-		}
+	return append([]PathPermission{}, dm.PathPermissions[projectID]...), nil
+}
 
+// MySQLProjectSetPathPermissions is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectSetPathPermissions(projectID int64, rules []PathPermission, grantedByUsername string) error {
+	dm.FunctionCallCount++
+	newRules := make([]PathPermission, len(rules))
+	for i, rule := range rules {
+		rule.GrantedBy = grantedByUsername
+		rule.GrantedDate = time.Now()
+		newRules[i] = rule
 	}
-	return ErrNoDbChange
+	dm.PathPermissions[projectID] = newRules
+	return nil
 }
 
-// MySQLFileGetInfo is a mock of the real implementation
-func (dm *DatabaseMock) MySQLFileGetInfo(fileID int64) (filey FileMeta, err error) {
+// MySQLGroupCreate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLGroupCreate(username string, groupName string) (int64, error) {
 	dm.FunctionCallCount++
-	for _, files := range dm.Files {
-		for _, file := range files {
-			if file.FileID == fileID {
-				return file, err
-			}
+	for _, group := range dm.Groups {
+		if group.Owner == username && group.Name == groupName {
+			return -1, ErrNoDbChange
 		}
+	}
 
+	group := GroupMeta{
+		GroupID:      dm.GroupIDCounter,
+		Name:         groupName,
+		Owner:        username,
+		CreationDate: time.Now(),
 	}
-	return filey, err
+	dm.GroupIDCounter++
+	dm.Groups[group.GroupID] = group
+	dm.GroupMembers[group.GroupID] = []string{username}
+	return group.GroupID, nil
 }
 
-// FileWrite is a mock of the real implementation
-func (dm *DatabaseMock) FileWrite(relpath string, filename string, projectID int64, raw []byte) (string, error) {
+// MySQLGroupGetInfo is a mock of the real implementation
+func (dm *DatabaseMock) MySQLGroupGetInfo(groupID int64) (GroupMeta, error) {
 	dm.FunctionCallCount++
-	dm.File = &raw
+	group, ok := dm.Groups[groupID]
+	if !ok {
+		return GroupMeta{GroupID: groupID}, ErrNoData
+	}
+	return group, nil
+}
+
+// MySQLGroupAddMember is a mock of the real implementation
+func (dm *DatabaseMock) MySQLGroupAddMember(groupID int64, username string, addedByUsername string) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.Groups[groupID]; !ok {
+		return ErrNoDbChange
+	}
+	for _, member := range dm.GroupMembers[groupID] {
+		if member == username {
+			return ErrNoDbChange
+		}
+	}
+	dm.GroupMembers[groupID] = append(dm.GroupMembers[groupID], username)
+	return nil
+}
+
+// MySQLGroupRemoveMember is a mock of the real implementation
+func (dm *DatabaseMock) MySQLGroupRemoveMember(groupID int64, username string) error {
+	dm.FunctionCallCount++
+	for i, member := range dm.GroupMembers[groupID] {
+		if member == username {
+			dm.GroupMembers[groupID] = append(dm.GroupMembers[groupID][:i], dm.GroupMembers[groupID][i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoDbChange
+}
+
+// MySQLGroupGetMembers is a mock of the real implementation
+func (dm *DatabaseMock) MySQLGroupGetMembers(groupID int64) ([]string, error) {
+	dm.FunctionCallCount++
+	return append([]string{}, dm.GroupMembers[groupID]...), nil
+}
+
+// MySQLGroupGetProjects is a mock of the real implementation
+func (dm *DatabaseMock) MySQLGroupGetProjects(groupID int64) ([]int64, error) {
+	dm.FunctionCallCount++
+	projectIDs := []int64{}
+	for projectID, groups := range dm.GroupPermissions {
+		if _, ok := groups[groupID]; ok {
+			projectIDs = append(projectIDs, projectID)
+		}
+	}
+	return projectIDs, nil
+}
+
+// MySQLUserGroups is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserGroups(username string) ([]GroupMeta, error) {
+	dm.FunctionCallCount++
+	groups := []GroupMeta{}
+	for groupID, members := range dm.GroupMembers {
+		for _, member := range members {
+			if member == username {
+				groups = append(groups, dm.Groups[groupID])
+			}
+		}
+	}
+	return groups, nil
+}
+
+// MySQLProjectGrantGroupPermission is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectGrantGroupPermission(projectID int64, groupID int64, permissionLevel int8, grantedByUsername string) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.Groups[groupID]; !ok {
+		return ErrNoDbChange
+	}
+	if dm.GroupPermissions[projectID] == nil {
+		dm.GroupPermissions[projectID] = make(map[int64]int8)
+	}
+	dm.GroupPermissions[projectID][groupID] = permissionLevel
+	return nil
+}
+
+// MySQLProjectRevokeGroupPermission is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectRevokeGroupPermission(projectID int64, groupID int64, revokedByUsername string) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.GroupPermissions[projectID][groupID]; !ok {
+		return ErrNoDbChange
+	}
+	delete(dm.GroupPermissions[projectID], groupID)
+	return nil
+}
+
+// MySQLInviteCreate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLInviteCreate(invite Invite, expiresIn time.Duration) (int64, error) {
+	dm.FunctionCallCount++
+	invite.InviteID = dm.InviteIDCounter
+	invite.ProjectName = dm.projectName(invite.ProjectID)
+	invite.CreationDate = time.Now()
+	invite.ExpiryDate = invite.CreationDate.Add(expiresIn)
+	dm.InviteIDCounter++
+	dm.Invites[invite.InviteID] = invite
+	return invite.InviteID, nil
+}
+
+// MySQLInviteGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLInviteGet(inviteID int64) (Invite, error) {
+	dm.FunctionCallCount++
+	invite, ok := dm.Invites[inviteID]
+	if !ok {
+		return Invite{}, ErrNoData
+	}
+	return invite, nil
+}
+
+// MySQLUserGetInvites is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserGetInvites(username string) ([]Invite, error) {
+	dm.FunctionCallCount++
+	invites := []Invite{}
+	for _, invite := range dm.Invites {
+		if invite.Username == username && invite.ExpiryDate.After(time.Now()) {
+			invites = append(invites, invite)
+		}
+	}
+	return invites, nil
+}
+
+// MySQLInviteDelete is a mock of the real implementation
+func (dm *DatabaseMock) MySQLInviteDelete(inviteID int64) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.Invites[inviteID]; !ok {
+		return ErrNoDbChange
+	}
+	delete(dm.Invites, inviteID)
+	return nil
+}
+
+// MySQLInviteBindEmail is a mock of the real implementation
+func (dm *DatabaseMock) MySQLInviteBindEmail(email string, username string) error {
+	dm.FunctionCallCount++
+	for inviteID, invite := range dm.Invites {
+		if invite.Email == email && invite.Username == "" && invite.ExpiryDate.After(time.Now()) {
+			invite.Username = username
+			dm.Invites[inviteID] = invite
+		}
+	}
+	return nil
+}
+
+// MySQLProjectRequestTransfer is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectRequestTransfer(transfer OwnershipTransfer) error {
+	dm.FunctionCallCount++
+	transfer.ProjectName = dm.projectName(transfer.ProjectID)
+	transfer.RequestedDate = time.Now()
+	dm.Transfers[transfer.ProjectID] = transfer
+	return nil
+}
+
+// MySQLProjectGetTransfer is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectGetTransfer(projectID int64) (OwnershipTransfer, error) {
+	dm.FunctionCallCount++
+	transfer, ok := dm.Transfers[projectID]
+	if !ok {
+		return OwnershipTransfer{}, ErrNoData
+	}
+	return transfer, nil
+}
+
+// MySQLProjectCancelTransfer is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectCancelTransfer(projectID int64) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.Transfers[projectID]; !ok {
+		return ErrNoDbChange
+	}
+	delete(dm.Transfers, projectID)
+	return nil
+}
+
+// MySQLProjectTransferOwnership is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectTransferOwnership(projectID int64, newName string) error {
+	dm.FunctionCallCount++
+	transfer, ok := dm.Transfers[projectID]
+	if !ok {
+		return ErrNoData
+	}
+
+	formerOwnerIndex := -1
+	for i, proj := range dm.Projects[transfer.FormerOwner] {
+		if proj.ProjectID == projectID && proj.PermissionLevel == config.OwnerLevel {
+			formerOwnerIndex = i
+		}
+	}
+	if formerOwnerIndex < 0 {
+		return ErrNoDbChange
+	}
+	for _, proj := range dm.Projects[transfer.NewOwner] {
+		if proj.ProjectID != projectID && proj.PermissionLevel == config.OwnerLevel && proj.Name == newName {
+			return ErrNameConflict
+		}
+	}
+
+	// the mock stores project names against each member, so rename every copy
+	for username, projects := range dm.Projects {
+		for i := range projects {
+			if projects[i].ProjectID == projectID {
+				dm.Projects[username][i].Name = newName
+			}
+		}
+	}
+	dm.Projects[transfer.FormerOwner][formerOwnerIndex].PermissionLevel = transfer.FormerOwnerLevel
+
+	found := false
+	for i, proj := range dm.Projects[transfer.NewOwner] {
+		if proj.ProjectID == projectID {
+			dm.Projects[transfer.NewOwner][i].PermissionLevel = config.OwnerLevel
+			found = true
+		}
+	}
+	if !found {
+		dm.Projects[transfer.NewOwner] = append(dm.Projects[transfer.NewOwner], ProjectMeta{
+			PermissionLevel: config.OwnerLevel,
+			ProjectID:       projectID,
+			Name:            newName,
+		})
+	}
+
+	var rules []PathPermission
+	for _, rule := range dm.PathPermissions[projectID] {
+		if rule.Username != transfer.NewOwner {
+			rules = append(rules, rule)
+		}
+	}
+	dm.PathPermissions[projectID] = rules
+
+	delete(dm.Transfers, projectID)
+	return nil
+}
+
+// MySQLUserSet2FASecret is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserSet2FASecret(username string, secret string) error {
+	dm.FunctionCallCount++
+	if dm.TwoFactor[username].Enabled {
+		return ErrNoDbChange
+	}
+	dm.TwoFactor[username] = TwoFactor{Secret: secret}
+	return nil
+}
+
+// MySQLUserGet2FA is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserGet2FA(username string) (TwoFactor, error) {
+	dm.FunctionCallCount++
+	twoFactor, ok := dm.TwoFactor[username]
+	if !ok {
+		return TwoFactor{}, ErrNoData
+	}
+	return twoFactor, nil
+}
+
+// MySQLUserEnable2FA is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserEnable2FA(username string, step int64, recoveryCodeHashes []string) error {
+	dm.FunctionCallCount++
+	twoFactor, ok := dm.TwoFactor[username]
+	if !ok || twoFactor.Enabled {
+		return ErrNoDbChange
+	}
+	twoFactor.Enabled = true
+	twoFactor.LastUsedStep = step
+	dm.TwoFactor[username] = twoFactor
+	dm.RecoveryCodes[username] = append([]string{}, recoveryCodeHashes...)
+	return nil
+}
+
+// MySQLUserUse2FAStep is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserUse2FAStep(username string, step int64) error {
+	dm.FunctionCallCount++
+	twoFactor, ok := dm.TwoFactor[username]
+	if !ok || !twoFactor.Enabled || twoFactor.LastUsedStep >= step {
+		return ErrNoDbChange
+	}
+	twoFactor.LastUsedStep = step
+	dm.TwoFactor[username] = twoFactor
+	return nil
+}
+
+// MySQLUserUseRecoveryCode is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserUseRecoveryCode(username string, codeHash string) error {
+	dm.FunctionCallCount++
+	for i, hash := range dm.RecoveryCodes[username] {
+		if hash == codeHash {
+			dm.RecoveryCodes[username] = append(dm.RecoveryCodes[username][:i], dm.RecoveryCodes[username][i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoDbChange
+}
+
+// MySQLUserDisable2FA is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserDisable2FA(username string) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.TwoFactor[username]; !ok {
+		return ErrNoDbChange
+	}
+	delete(dm.TwoFactor, username)
+	delete(dm.RecoveryCodes, username)
+	return nil
+}
+
+// MySQLEmailVerificationCreate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLEmailVerificationCreate(username string, tokenHash string, expiresIn time.Duration) error {
+	dm.FunctionCallCount++
+	user, ok := dm.Users[username]
+	if !ok || user.Email == "" || user.Status != UserStatusPending {
+		return ErrNoDbChange
+	}
+	for hash, verification := range dm.EmailVerifications {
+		if verification.Username == username {
+			delete(dm.EmailVerifications, hash)
+		}
+	}
+	dm.EmailVerifications[tokenHash] = EmailVerification{
+		Username:   username,
+		Email:      user.Email,
+		ExpiryDate: time.Now().Add(expiresIn),
+	}
+	return nil
+}
+
+// MySQLEmailChangeCreate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLEmailChangeCreate(username string, email string, tokenHash string, expiresIn time.Duration) error {
+	dm.FunctionCallCount++
+	user, ok := dm.Users[username]
+	if !ok || user.Bot {
+		return ErrNoDbChange
+	}
+	for hash, verification := range dm.EmailVerifications {
+		if verification.Username == username {
+			delete(dm.EmailVerifications, hash)
+		}
+	}
+	dm.EmailVerifications[tokenHash] = EmailVerification{
+		Username:   username,
+		Email:      email,
+		ExpiryDate: time.Now().Add(expiresIn),
+	}
+	return nil
+}
+
+// MySQLEmailVerificationRedeem is a mock of the real implementation
+func (dm *DatabaseMock) MySQLEmailVerificationRedeem(tokenHash string) (string, error) {
+	dm.FunctionCallCount++
+	verification, ok := dm.EmailVerifications[tokenHash]
+	if !ok || !verification.ExpiryDate.After(time.Now()) {
+		return "", ErrNoData
+	}
+	user, ok := dm.Users[verification.Username]
+	if !ok {
+		return "", ErrNoData
+	}
+
+	user.Email = verification.Email
+	user.Status = UserStatusActive
+	dm.Users[user.Username] = user
+	delete(dm.EmailVerifications, tokenHash)
+	return user.Username, nil
+}
+
+// MySQLLoginFailuresGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLLoginFailuresGet(kind string, subject string) (LoginFailures, error) {
+	dm.FunctionCallCount++
+	failures, ok := dm.LoginFailures[kind+":"+subject]
+	if !ok {
+		return LoginFailures{}, ErrNoData
+	}
+	return failures, nil
+}
+
+// MySQLLoginFailureRecord is a mock of the real implementation
+func (dm *DatabaseMock) MySQLLoginFailureRecord(kind string, subject string, resetAfter time.Duration) (int, error) {
+	dm.FunctionCallCount++
+	key := kind + ":" + subject
+	failures, ok := dm.LoginFailures[key]
+	if !ok || failures.LastFailure.Before(time.Now().Add(-resetAfter)) {
+		failures.Failures = 0
+	}
+	failures.Failures++
+	failures.LastFailure = time.Now()
+	dm.LoginFailures[key] = failures
+	return failures.Failures, nil
+}
+
+// MySQLLoginFailuresLock is a mock of the real implementation
+func (dm *DatabaseMock) MySQLLoginFailuresLock(kind string, subject string, lockFor time.Duration) error {
+	dm.FunctionCallCount++
+	key := kind + ":" + subject
+	failures, ok := dm.LoginFailures[key]
+	if !ok {
+		return ErrNoDbChange
+	}
+	failures.Failures = 0
+	failures.LockedUntil = time.Now().Add(lockFor)
+	dm.LoginFailures[key] = failures
+	return nil
+}
+
+// MySQLLoginFailuresClear is a mock of the real implementation
+func (dm *DatabaseMock) MySQLLoginFailuresClear(kind string, subject string) error {
+	dm.FunctionCallCount++
+	key := kind + ":" + subject
+	if _, ok := dm.LoginFailures[key]; !ok {
+		return ErrNoDbChange
+	}
+	delete(dm.LoginFailures, key)
+	return nil
+}
+
+// MySQLNotificationInsert is a mock of the real implementation
+func (dm *DatabaseMock) MySQLNotificationInsert(username string, notification Notification) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.Users[username]; !ok {
+		return fmt.Errorf("Cannot add or update a child row: a foreign key constraint fails (`Notifications`)")
+	}
+	dm.NotificationIDCounter++
+	notification.NotificationID = dm.NotificationIDCounter
+	notification.CreationDate = time.Now()
+	notification.Read = false
+	dm.Notifications[username] = append(dm.Notifications[username], notification)
+	return nil
+}
+
+// MySQLNotificationsGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLNotificationsGet(username string, unreadOnly bool, offset int, limit int) ([]Notification, error) {
+	dm.FunctionCallCount++
+	notifications := []Notification{}
+	// newest first
+	inbox := dm.Notifications[username]
+	for i := len(inbox) - 1; i >= 0; i-- {
+		if !unreadOnly || !inbox[i].Read {
+			notifications = append(notifications, inbox[i])
+		}
+	}
+
+	if offset >= len(notifications) {
+		return []Notification{}, nil
+	}
+	notifications = notifications[offset:]
+	if limit < len(notifications) {
+		notifications = notifications[:limit]
+	}
+	return notifications, nil
+}
+
+// MySQLNotificationsCountUnread is a mock of the real implementation
+func (dm *DatabaseMock) MySQLNotificationsCountUnread(username string) (int, error) {
+	dm.FunctionCallCount++
+	unread := 0
+	for _, notification := range dm.Notifications[username] {
+		if !notification.Read {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+// MySQLNotificationsMarkRead is a mock of the real implementation
+func (dm *DatabaseMock) MySQLNotificationsMarkRead(username string, notificationIDs []int64) (int, error) {
+	dm.FunctionCallCount++
+	marked := 0
+	inbox := dm.Notifications[username]
+	for i := range inbox {
+		if inbox[i].Read {
+			continue
+		}
+		matches := len(notificationIDs) == 0
+		for _, notificationID := range notificationIDs {
+			matches = matches || inbox[i].NotificationID == notificationID
+		}
+		if matches {
+			inbox[i].Read = true
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+// MySQLCommentThreadCreate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLCommentThreadCreate(thread CommentThread, body string) (int64, int64, error) {
+	dm.FunctionCallCount++
+	dm.ThreadIDCounter++
+	thread.ThreadID = dm.ThreadIDCounter
+	thread.CreationDate = time.Now()
+	thread.Comments = nil
+	dm.CommentThreads[thread.ThreadID] = thread
+
+	commentID, err := dm.MySQLCommentReply(thread.ThreadID, thread.CreatedBy, body)
+	return thread.ThreadID, commentID, err
+}
+
+// MySQLCommentReply is a mock of the real implementation
+func (dm *DatabaseMock) MySQLCommentReply(threadID int64, author string, body string) (int64, error) {
+	dm.FunctionCallCount++
+	if _, ok := dm.CommentThreads[threadID]; !ok {
+		return -1, fmt.Errorf("Cannot add or update a child row: a foreign key constraint fails (`Comments`)")
+	}
+	dm.CommentIDCounter++
+	dm.Comments[threadID] = append(dm.Comments[threadID], Comment{
+		CommentID:    dm.CommentIDCounter,
+		ThreadID:     threadID,
+		Author:       author,
+		Body:         body,
+		CreationDate: time.Now(),
+	})
+	return dm.CommentIDCounter, nil
+}
+
+// MySQLCommentThreadResolve is a mock of the real implementation
+func (dm *DatabaseMock) MySQLCommentThreadResolve(threadID int64, resolved bool, username string) error {
+	dm.FunctionCallCount++
+	thread, ok := dm.CommentThreads[threadID]
+	if !ok || thread.Resolved == resolved {
+		return ErrNoDbChange
+	}
+	thread.Resolved = resolved
+	thread.ResolvedBy = ""
+	if resolved {
+		thread.ResolvedBy = username
+	}
+	dm.CommentThreads[threadID] = thread
+	return nil
+}
+
+// MySQLCommentThreadGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLCommentThreadGet(threadID int64) (CommentThread, error) {
+	dm.FunctionCallCount++
+	thread, ok := dm.CommentThreads[threadID]
+	if !ok {
+		return CommentThread{}, ErrNoData
+	}
+	return thread, nil
+}
+
+// MySQLCommentThreadsGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLCommentThreadsGet(fileID int64, includeResolved bool) ([]CommentThread, error) {
+	dm.FunctionCallCount++
+	threads := []CommentThread{}
+	for _, thread := range dm.CommentThreads {
+		if thread.FileID == fileID && (includeResolved || !thread.Resolved) {
+			threads = append(threads, thread)
+		}
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		return threads[i].ThreadID < threads[j].ThreadID
+	})
+	return threads, nil
+}
+
+// MySQLCommentsGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLCommentsGet(fileID int64) ([]Comment, error) {
+	dm.FunctionCallCount++
+	comments := []Comment{}
+	for threadID, thread := range dm.CommentThreads {
+		if thread.FileID == fileID {
+			comments = append(comments, dm.Comments[threadID]...)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].CommentID < comments[j].CommentID
+	})
+	return comments, nil
+}
+
+// MySQLCommentAnchorUpdate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLCommentAnchorUpdate(threadID int64, fromVersion int64, anchor CommentAnchor) error {
+	dm.FunctionCallCount++
+	thread, ok := dm.CommentThreads[threadID]
+	if !ok || thread.FileVersion != fromVersion || thread.Orphaned {
+		return ErrNoDbChange
+	}
+	thread.CommentAnchor = anchor
+	dm.CommentThreads[threadID] = thread
+	return nil
+}
+
+// MySQLSuggestionCreate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLSuggestionCreate(suggestion Suggestion) (int64, error) {
+	dm.FunctionCallCount++
+	dm.SuggestionIDCounter++
+	suggestion.SuggestionID = dm.SuggestionIDCounter
+	suggestion.CreationDate = time.Now()
+	dm.Suggestions[suggestion.SuggestionID] = suggestion
+	return suggestion.SuggestionID, nil
+}
+
+// MySQLSuggestionGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLSuggestionGet(suggestionID int64) (Suggestion, error) {
+	dm.FunctionCallCount++
+	suggestion, ok := dm.Suggestions[suggestionID]
+	if !ok {
+		return Suggestion{}, ErrNoData
+	}
+	return suggestion, nil
+}
+
+// MySQLSuggestionsGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLSuggestionsGet(fileID int64) ([]Suggestion, error) {
+	dm.FunctionCallCount++
+	suggestions := []Suggestion{}
+	for _, suggestion := range dm.Suggestions {
+		if suggestion.FileID == fileID {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].SuggestionID < suggestions[j].SuggestionID
+	})
+	return suggestions, nil
+}
+
+// MySQLSuggestionRebase is a mock of the real implementation
+func (dm *DatabaseMock) MySQLSuggestionRebase(suggestionID int64, fromVersion int64, patch string, version int64) error {
+	dm.FunctionCallCount++
+	suggestion, ok := dm.Suggestions[suggestionID]
+	if !ok || suggestion.FileVersion != fromVersion {
+		return ErrNoDbChange
+	}
+	suggestion.Patch = patch
+	suggestion.FileVersion = version
+	dm.Suggestions[suggestionID] = suggestion
+	return nil
+}
+
+// MySQLSuggestionDelete is a mock of the real implementation
+func (dm *DatabaseMock) MySQLSuggestionDelete(suggestionID int64) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.Suggestions[suggestionID]; !ok {
+		return ErrNoDbChange
+	}
+	delete(dm.Suggestions, suggestionID)
+	return nil
+}
+
+// MySQLFileLockAcquire is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileLockAcquire(lock FileLock, ttl time.Duration) (FileLock, error) {
+	dm.FunctionCallCount++
+	held, ok := dm.FileLocks[lock.FileID]
+	if ok && held.Socket != lock.Socket && !held.ExpiryDate.Before(time.Now()) {
+		return held, nil
+	}
+	lock.ExpiryDate = time.Now().Add(ttl)
+	dm.FileLocks[lock.FileID] = lock
+	return lock, nil
+}
+
+// MySQLFileLockGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileLockGet(fileID int64) (FileLock, error) {
+	dm.FunctionCallCount++
+	lock, ok := dm.FileLocks[fileID]
+	if !ok || lock.ExpiryDate.Before(time.Now()) {
+		return FileLock{}, ErrNoData
+	}
+	return lock, nil
+}
+
+// MySQLFileLockRelease is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileLockRelease(fileID int64, username string, force bool) error {
+	dm.FunctionCallCount++
+	lock, ok := dm.FileLocks[fileID]
+	if !ok || lock.ExpiryDate.Before(time.Now()) || !(force || lock.Username == username) {
+		return ErrNoDbChange
+	}
+	delete(dm.FileLocks, fileID)
+	return nil
+}
+
+// MySQLFileLocksReleaseSocket is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileLocksReleaseSocket(socket string) ([]FileLock, error) {
+	dm.FunctionCallCount++
+	released := []FileLock{}
+	for fileID, lock := range dm.FileLocks {
+		if lock.Socket != socket {
+			continue
+		}
+		delete(dm.FileLocks, fileID)
+		if !lock.ExpiryDate.Before(time.Now()) {
+			released = append(released, lock)
+		}
+	}
+	sort.Slice(released, func(i, j int) bool {
+		return released[i].FileID < released[j].FileID
+	})
+	return released, nil
+}
+
+// MySQLWebhookCreate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLWebhookCreate(webhook Webhook) (int64, error) {
+	dm.FunctionCallCount++
+	dm.WebhookIDCounter++
+	webhook.WebhookID = dm.WebhookIDCounter
+	webhook.CreationDate = time.Now()
+	dm.Webhooks[webhook.WebhookID] = webhook
+	return webhook.WebhookID, nil
+}
+
+// MySQLWebhooksGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLWebhooksGet(projectID int64) ([]Webhook, error) {
+	dm.FunctionCallCount++
+	webhooks := []Webhook{}
+	for _, webhook := range dm.Webhooks {
+		if webhook.ProjectID == projectID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].WebhookID < webhooks[j].WebhookID
+	})
+	return webhooks, nil
+}
+
+// MySQLWebhookDelete is a mock of the real implementation
+func (dm *DatabaseMock) MySQLWebhookDelete(projectID int64, webhookID int64) error {
+	dm.FunctionCallCount++
+	webhook, ok := dm.Webhooks[webhookID]
+	if !ok || webhook.ProjectID != projectID {
+		return ErrNoDbChange
+	}
+	delete(dm.Webhooks, webhookID)
+	delete(dm.WebhookDeliveries, webhookID)
+	return nil
+}
+
+// MySQLWebhookProjectIDs is a mock of the real implementation
+func (dm *DatabaseMock) MySQLWebhookProjectIDs() ([]int64, error) {
+	dm.FunctionCallCount++
+	seen := make(map[int64]bool)
+	projectIDs := []int64{}
+	for _, webhook := range dm.Webhooks {
+		if !seen[webhook.ProjectID] {
+			seen[webhook.ProjectID] = true
+			projectIDs = append(projectIDs, webhook.ProjectID)
+		}
+	}
+	return projectIDs, nil
+}
+
+// MySQLWebhookDeliveryInsert is a mock of the real implementation
+func (dm *DatabaseMock) MySQLWebhookDeliveryInsert(delivery WebhookDelivery) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.Webhooks[delivery.WebhookID]; !ok {
+		return fmt.Errorf("Cannot add or update a child row: a foreign key constraint fails (`WebhookDeliveries`)")
+	}
+	dm.DeliveryIDCounter++
+	delivery.DeliveryID = dm.DeliveryIDCounter
+	delivery.CompletionDate = time.Now()
+	deliveries := append(dm.WebhookDeliveries[delivery.WebhookID], delivery)
+	if len(deliveries) > WebhookDeliveriesKept {
+		deliveries = deliveries[len(deliveries)-WebhookDeliveriesKept:]
+	}
+	dm.WebhookDeliveries[delivery.WebhookID] = deliveries
+	return nil
+}
+
+// MySQLWebhookDeliveriesGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLWebhookDeliveriesGet(webhookID int64, limit int) ([]WebhookDelivery, error) {
+	dm.FunctionCallCount++
+	deliveries := []WebhookDelivery{}
+	// newest first
+	history := dm.WebhookDeliveries[webhookID]
+	for i := len(history) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		deliveries = append(deliveries, history[i])
+	}
+	return deliveries, nil
+}
+
+// MySQLExternalIdentityLookup is a mock of the real implementation
+func (dm *DatabaseMock) MySQLExternalIdentityLookup(provider string, subject string) (string, error) {
+	dm.FunctionCallCount++
+	username, ok := dm.ExternalIdentities[provider][subject]
+	if !ok {
+		return "", ErrNoData
+	}
+	return username, nil
+}
+
+// MySQLExternalIdentityLink is a mock of the real implementation
+func (dm *DatabaseMock) MySQLExternalIdentityLink(provider string, subject string, username string) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.ExternalIdentities[provider][subject]; ok {
+		return ErrNoDbChange
+	}
+	if dm.ExternalIdentities[provider] == nil {
+		dm.ExternalIdentities[provider] = make(map[string]string)
+	}
+	dm.ExternalIdentities[provider][subject] = username
+	return nil
+}
+
+// MySQLShareLinkCreate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLShareLinkCreate(link ShareLink, tokenHash string, expiresIn time.Duration) (int64, error) {
+	dm.FunctionCallCount++
+	link.ShareLinkID = dm.ShareLinkIDCounter
+	link.ProjectName = dm.projectName(link.ProjectID)
+	link.CreationDate = time.Now()
+	link.ExpiryDate = link.CreationDate.Add(expiresIn)
+	link.Uses = 0
+	dm.ShareLinkIDCounter++
+	dm.ShareLinks[link.ShareLinkID] = link
+	dm.ShareLinkHashes[tokenHash] = link.ShareLinkID
+	return link.ShareLinkID, nil
+}
+
+// MySQLShareLinkGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLShareLinkGet(shareLinkID int64) (ShareLink, error) {
+	dm.FunctionCallCount++
+	link, ok := dm.ShareLinks[shareLinkID]
+	if _, trashed := dm.TrashedProjects[link.ProjectID]; !ok || trashed {
+		return ShareLink{}, ErrNoData
+	}
+	return link, nil
+}
+
+// MySQLShareLinkRedeem is a mock of the real implementation
+func (dm *DatabaseMock) MySQLShareLinkRedeem(tokenHash string) (int64, error) {
+	dm.FunctionCallCount++
+	shareLinkID, ok := dm.ShareLinkHashes[tokenHash]
+	if !ok {
+		return -1, ErrNoData
+	}
+	link, ok := dm.ShareLinks[shareLinkID]
+	if _, trashed := dm.TrashedProjects[link.ProjectID]; !ok || trashed {
+		return -1, ErrNoData
+	}
+	if !link.ExpiryDate.After(time.Now()) || (link.MaxUses != 0 && link.Uses >= link.MaxUses) {
+		return -1, ErrNoData
+	}
+	link.Uses++
+	dm.ShareLinks[shareLinkID] = link
+	return shareLinkID, nil
+}
+
+// MySQLShareLinkDelete is a mock of the real implementation
+func (dm *DatabaseMock) MySQLShareLinkDelete(shareLinkID int64) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.ShareLinks[shareLinkID]; !ok {
+		return ErrNoDbChange
+	}
+	delete(dm.ShareLinks, shareLinkID)
+	for hash, id := range dm.ShareLinkHashes {
+		if id == shareLinkID {
+			delete(dm.ShareLinkHashes, hash)
+		}
+	}
+	return nil
+}
+
+// MySQLProjectGetShareLinks is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectGetShareLinks(projectID int64) ([]ShareLink, error) {
+	dm.FunctionCallCount++
+	links := []ShareLink{}
+	for _, link := range dm.ShareLinks {
+		if link.ProjectID == projectID {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// MySQLAccessTokenCreate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLAccessTokenCreate(token AccessToken, tokenHash string, expiresIn time.Duration) (int64, error) {
+	dm.FunctionCallCount++
+	token.TokenID = dm.AccessTokenIDCounter
+	token.CreationDate = time.Now()
+	token.ExpiryDate = time.Time{}
+	if expiresIn > 0 {
+		token.ExpiryDate = token.CreationDate.Add(expiresIn)
+	}
+	dm.AccessTokenIDCounter++
+	dm.AccessTokens[token.TokenID] = token
+	dm.AccessTokenHashes[tokenHash] = token.TokenID
+	return token.TokenID, nil
+}
+
+// MySQLAccessTokenGet is a mock of the real implementation
+func (dm *DatabaseMock) MySQLAccessTokenGet(tokenID int64) (AccessToken, error) {
+	dm.FunctionCallCount++
+	token, ok := dm.AccessTokens[tokenID]
+	if !ok {
+		return AccessToken{}, ErrNoData
+	}
+	return token, nil
+}
+
+// MySQLAccessTokenAuthenticate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLAccessTokenAuthenticate(tokenHash string) (AccessToken, error) {
+	dm.FunctionCallCount++
+	tokenID, ok := dm.AccessTokenHashes[tokenHash]
+	if !ok {
+		return AccessToken{}, ErrNoData
+	}
+	token, ok := dm.AccessTokens[tokenID]
+	if !ok || (!token.ExpiryDate.IsZero() && !token.ExpiryDate.After(time.Now())) {
+		return AccessToken{}, ErrNoData
+	}
+	return token, nil
+}
+
+// MySQLAccessTokenDelete is a mock of the real implementation
+func (dm *DatabaseMock) MySQLAccessTokenDelete(tokenID int64) error {
+	dm.FunctionCallCount++
+	if _, ok := dm.AccessTokens[tokenID]; !ok {
+		return ErrNoDbChange
+	}
+	delete(dm.AccessTokens, tokenID)
+	for hash, id := range dm.AccessTokenHashes {
+		if id == tokenID {
+			delete(dm.AccessTokenHashes, hash)
+		}
+	}
+	return nil
+}
+
+// MySQLUserGetAccessTokens is a mock of the real implementation
+func (dm *DatabaseMock) MySQLUserGetAccessTokens(username string) ([]AccessToken, error) {
+	dm.FunctionCallCount++
+	tokens := []AccessToken{}
+	for _, token := range dm.AccessTokens {
+		if token.Username == username {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+// MySQLAuditLogInsert is a mock of the real implementation
+func (dm *DatabaseMock) MySQLAuditLogInsert(entry AuditEntry) error {
+	dm.FunctionCallCount++
+	entry.AuditID = int64(len(dm.AuditLog) + 1)
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	dm.AuditLog = append(dm.AuditLog, entry)
+	return nil
+}
+
+// MySQLProjectGetAuditLog is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectGetAuditLog(projectID int64, filter AuditFilter) ([]AuditEntry, error) {
+	dm.FunctionCallCount++
+	entries := []AuditEntry{}
+	// newest first
+	for i := len(dm.AuditLog) - 1; i >= 0; i-- {
+		entry := dm.AuditLog[i]
+		if entry.ProjectID != projectID ||
+			(filter.Action != "" && entry.Action != filter.Action) ||
+			(filter.Actor != "" && entry.Actor != filter.Actor) ||
+			(!filter.Since.IsZero() && entry.Timestamp.Before(filter.Since)) ||
+			(!filter.Until.IsZero() && !entry.Timestamp.Before(filter.Until)) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if filter.Offset >= len(entries) {
+		return []AuditEntry{}, nil
+	}
+	entries = entries[filter.Offset:]
+	if filter.Limit < len(entries) {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+// MySQLFileCreate is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileCreate(username string, filename string, relativePath string, projectID int64) (int64, error) {
+	dm.FunctionCallCount++
+	dm.FileIDCounter++
+	dm.Files[projectID] = append(
+		dm.Files[projectID],
+		FileMeta{
+			ProjectID:    projectID,
+			CreationDate: time.Now(),
+			Creator:      username,
+			FileID:       dm.FileIDCounter,
+			Filename:     filename,
+			RelativePath: relativePath,
+		})
+	return dm.FileIDCounter, nil
+}
+
+// MySQLFileDelete is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileDelete(fileID int64) error {
+	dm.FunctionCallCount++
+	for projectID, files := range dm.Files {
+		for i, file := range files {
+			if file.FileID == fileID {
+				if len(dm.Files[projectID]) > i+1 {
+					dm.Files[projectID] = append(
+						dm.Files[projectID][:i],
+						dm.Files[projectID][i+1:]...)
+				} else {
+					dm.Files[projectID] = dm.Files[projectID][:i]
+				}
+				delete(dm.FileVersion, fileID)
+				return nil
+			}
+		}
+
+	}
+	if _, ok := dm.TrashedFiles[fileID]; ok {
+		delete(dm.TrashedFiles, fileID)
+		delete(dm.FileVersion, fileID)
+		return nil
+	}
+	return ErrNoDbChange
+}
+
+// MySQLFileTrash is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileTrash(fileID int64, deletedBy string) error {
+	dm.FunctionCallCount++
+	for projectID, files := range dm.Files {
+		for i, file := range files {
+			if file.FileID == fileID {
+				dm.Files[projectID] = append(files[:i:i], files[i+1:]...)
+				dm.TrashedFiles[fileID] = TrashedFile{
+					FileMeta:    file,
+					DeletedBy:   deletedBy,
+					DeletedDate: time.Now(),
+				}
+				return nil
+			}
+		}
+	}
+	return ErrNoDbChange
+}
+
+// MySQLFileRestore is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileRestore(fileID int64) error {
+	dm.FunctionCallCount++
+	file, ok := dm.TrashedFiles[fileID]
+	if !ok {
+		return ErrNoDbChange
+	}
+	dm.Files[file.ProjectID] = append(dm.Files[file.ProjectID], file.FileMeta)
+	delete(dm.TrashedFiles, fileID)
+	return nil
+}
+
+// MySQLFileGetTrashedInfo is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileGetTrashedInfo(fileID int64) (TrashedFile, error) {
+	dm.FunctionCallCount++
+	file, ok := dm.TrashedFiles[fileID]
+	if !ok {
+		return TrashedFile{}, ErrNoData
+	}
+	return file, nil
+}
+
+// MySQLProjectGetTrashedFiles is a mock of the real implementation
+func (dm *DatabaseMock) MySQLProjectGetTrashedFiles(projectID int64) ([]TrashedFile, error) {
+	dm.FunctionCallCount++
+	files := []TrashedFile{}
+	for _, file := range dm.TrashedFiles {
+		if file.ProjectID == projectID {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// MySQLExpiredTrashedFiles is a mock of the real implementation
+func (dm *DatabaseMock) MySQLExpiredTrashedFiles(deletedBefore time.Time) ([]TrashedFile, error) {
+	dm.FunctionCallCount++
+	files := []TrashedFile{}
+	for _, file := range dm.TrashedFiles {
+		if _, projectTrashed := dm.TrashedProjects[file.ProjectID]; !projectTrashed && file.DeletedDate.Before(deletedBefore) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// MySQLFileMove is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileMove(fileID int64, newPath string) error {
+	dm.FunctionCallCount++
+	for _, files := range dm.Files {
+		for _, file := range files {
+			if file.FileID == fileID {
+				file.RelativePath = newPath
+				return nil
+			}
+		}
+
+	}
+	return ErrNoDbChange
+}
+
+// MySQLFileRename is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileRename(fileID int64, newName string) error {
+	dm.FunctionCallCount++
+	for _, files := range dm.Files {
+		for _, file := range files {
+			if file.FileID == fileID {
+				file.Filename = newName
+				return nil
+			}
+		}
+
+	}
+	return ErrNoDbChange
+}
+
+// MySQLFileGetInfo is a mock of the real implementation
+func (dm *DatabaseMock) MySQLFileGetInfo(fileID int64) (filey FileMeta, err error) {
+	dm.FunctionCallCount++
+	for _, files := range dm.Files {
+		for _, file := range files {
+			if file.FileID == fileID {
+				return file, err
+			}
+		}
+
+	}
+	return filey, err
+}
+
+// FileWrite is a mock of the real implementation
+func (dm *DatabaseMock) FileWrite(relpath string, filename string, projectID int64, raw []byte) (string, error) {
+	dm.FunctionCallCount++
+	dm.File = &raw
 	return "./this_path_shouldnt_be_used_anywhere", nil
 }
 
+// FileCheckWritable is a mock of the real implementation
+func (dm *DatabaseMock) FileCheckWritable() error {
+	dm.FunctionCallCount++
+	return nil
+}
+
 // FileDelete is a mock of the real implementation
 func (dm *DatabaseMock) FileDelete(relpath string, filename string, projectID int64) error {
 	dm.FunctionCallCount++
@@ -486,6 +2077,30 @@ func (dm *DatabaseMock) FileDelete(relpath string, filename string, projectID in
 	return nil
 }
 
+// FileTrash is a mock of the real implementation
+func (dm *DatabaseMock) FileTrash(meta FileMeta) error {
+	dm.FunctionCallCount++
+	return nil
+}
+
+// FileRestore is a mock of the real implementation
+func (dm *DatabaseMock) FileRestore(meta FileMeta) error {
+	dm.FunctionCallCount++
+	return nil
+}
+
+// FilePurge is a mock of the real implementation
+func (dm *DatabaseMock) FilePurge(meta FileMeta) error {
+	dm.FunctionCallCount++
+	return nil
+}
+
+// FileDeleteProject is a mock of the real implementation
+func (dm *DatabaseMock) FileDeleteProject(projectID int64) error {
+	dm.FunctionCallCount++
+	return nil
+}
+
 // FileRead is a mock of the real implementation
 func (dm *DatabaseMock) FileRead(relpath string, filename string, projectID int64) (*[]byte, error) {
 	dm.FunctionCallCount++
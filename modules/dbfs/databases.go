@@ -1,5 +1,11 @@
 package dbfs
 
+import (
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+)
+
 // Dbfs is the globally used dbfs object for the server
 var Dbfs DBFS
 
@@ -32,6 +38,9 @@ type DBFS interface {
 	// YOU PROBABLY DON'T NEED TO RUN THIS EVER
 	CloseCouchbase() error
 
+	// CBPing checks that the Couchbase buckets can be opened
+	CBPing() error
+
 	// CBInsertNewFile inserts a new document with the given arguments
 	CBInsertNewFile(fileID int64, version int64, changes []string) error
 
@@ -51,9 +60,15 @@ type DBFS interface {
 	// YOU PROBABLY DON'T NEED TO RUN THIS EVER
 	CloseMySQL() error
 
+	// MySQLPing checks that the MySQL database can be reached
+	MySQLPing() error
+
 	// MySQLUserRegister registers a new user in MySQL
 	MySQLUserRegister(user UserMeta) error
 
+	// MySQLUserRegisterBot registers a bot account owned by bot.BotOwner, which cannot log in with a password
+	MySQLUserRegisterBot(bot UserMeta) error
+
 	// MySQLUserGetPass is used to get the key and hash of a stored password to verify that a value is correct
 	MySQLUserGetPass(username string) (password string, err error)
 
@@ -63,15 +78,54 @@ type DBFS interface {
 	// MySQLUserLookup returns user information about a user with the username 'username'
 	MySQLUserLookup(username string) (user UserMeta, err error)
 
+	// MySQLUserLookupEmail returns user information about the user with the given email address
+	MySQLUserLookupEmail(email string) (user UserMeta, err error)
+
+	// MySQLUserUpdateProfile sets the first and last name of the user `username`. Returns ErrNoDbChange if the user
+	// does not exist, or the names are unchanged.
+	MySQLUserUpdateProfile(username string, firstName string, lastName string) error
+
+	// MySQLUserSetEmail sets the email address of the user `username`, without verifying it
+	MySQLUserSetEmail(username string, email string) error
+
+	// MySQLUserSetHiddenFromSearch sets whether the user `username` is excluded from search results. Returns
+	// ErrNoDbChange if the user does not exist, or the setting is unchanged.
+	MySQLUserSetHiddenFromSearch(username string, hidden bool) error
+
+	// MySQLUserSearch returns the users whose username, names or email address start with, contain, or sound like the
+	// query, best matches first. HasAccess is set for the users with permissions on the project with the given
+	// projectID.
+	MySQLUserSearch(query string, projectID int64, offset int, limit int) ([]UserSearchResult, error)
+
+	// MySQLUserRename changes the username of a user, along with every reference to it, and records a tombstone for
+	// the old username. Returns ErrNoDbChange if the user does not exist.
+	MySQLUserRename(oldUsername string, newUsername string) error
+
+	// MySQLUsernameTombstoneGet returns the latest rename away from the username. Returns ErrNoData if it has never
+	// been renamed.
+	MySQLUsernameTombstoneGet(username string) (UsernameTombstone, error)
+
 	// MySQLUserProjects returns the projectID, the project name, and the permission level the user `username` has on that project
 	MySQLUserProjects(username string) (projects []ProjectMeta, err error)
 
 	// MySQLProjectCreate create a new project in MySQL
 	MySQLProjectCreate(username string, projectName string) (projectID int64, err error)
 
-	// MySQLProjectDelete deletes a project from MySQL
+	// MySQLProjectDelete permanently deletes a project, and all of its files, from MySQL
 	MySQLProjectDelete(projectID int64, senderID string) error
 
+	// MySQLProjectTrash moves the project owned by `senderID` to the trash, hiding it from its members
+	MySQLProjectTrash(projectID int64, senderID string) error
+
+	// MySQLProjectRestore moves the project owned by `senderID` out of the trash
+	MySQLProjectRestore(projectID int64, senderID string) error
+
+	// MySQLUserTrashedProjects returns the projects owned by `username` which are in the trash, most recent first
+	MySQLUserTrashedProjects(username string) ([]TrashedProject, error)
+
+	// MySQLExpiredTrashedProjects returns the projects which were moved to the trash before the given time
+	MySQLExpiredTrashedProjects(deletedBefore time.Time) ([]TrashedProject, error)
+
 	// MySQLProjectGetFiles returns the Files from the project with projectID = projectID
 	MySQLProjectGetFiles(projectID int64) (files []FileMeta, err error)
 
@@ -82,7 +136,8 @@ type DBFS interface {
 	// DOES NOT WORK FOR OWNER (which is kinda a good thing)
 	MySQLProjectRevokePermission(projectID int64, revokeUsername string, revokedByUsername string) error
 
-	// MySQLUserProjectPermissionLookup returns the permission level of `username` on the project with the given projectID
+	// MySQLUserProjectPermissionLookup returns the permission level of `username` on the project with the given projectID.
+	// This is the highest of the levels granted to the user directly, and to the groups they are a member of.
 	MySQLUserProjectPermissionLookup(projectID int64, username string) (int8, error)
 
 	// MySQLProjectRename allows for you to rename projects
@@ -92,13 +147,290 @@ type DBFS interface {
 	// NOTE: There's an important to do on the DatabaseImpl version of this
 	MySQLProjectLookup(projectID int64, username string) (name string, permissions map[string]ProjectPermission, err error)
 
+	// MySQLProjectGetRoles returns the custom roles defined for the project with the given projectID
+	MySQLProjectGetRoles(projectID int64) ([]config.Role, error)
+
+	// MySQLProjectSetRole creates or replaces the custom role with the given level on the project
+	MySQLProjectSetRole(projectID int64, role config.Role) error
+
+	// MySQLProjectDeleteRole deletes the custom role with the given level from the project, if no users hold it
+	MySQLProjectDeleteRole(projectID int64, level int8) error
+
+	// MySQLProjectGetPathPermissions returns the path permission rules for the project with the given projectID
+	MySQLProjectGetPathPermissions(projectID int64) ([]PathPermission, error)
+
+	// MySQLProjectSetPathPermissions replaces the path permission rules for the project with the given projectID
+	MySQLProjectSetPathPermissions(projectID int64, rules []PathPermission, grantedByUsername string) error
+
+	// MySQLGroupCreate creates a new group owned by `username`, who is added as its first member
+	MySQLGroupCreate(username string, groupName string) (groupID int64, err error)
+
+	// MySQLGroupGetInfo returns the metadata of the group with the given groupID
+	MySQLGroupGetInfo(groupID int64) (GroupMeta, error)
+
+	// MySQLGroupAddMember adds the user to the group with the given groupID
+	MySQLGroupAddMember(groupID int64, username string, addedByUsername string) error
+
+	// MySQLGroupRemoveMember removes the user from the group with the given groupID
+	MySQLGroupRemoveMember(groupID int64, username string) error
+
+	// MySQLGroupGetMembers returns the usernames of the members of the group with the given groupID
+	MySQLGroupGetMembers(groupID int64) ([]string, error)
+
+	// MySQLGroupGetProjects returns the IDs of the projects the group with the given groupID has been granted
+	MySQLGroupGetProjects(groupID int64) ([]int64, error)
+
+	// MySQLUserGroups returns the groups the user `username` is a member of
+	MySQLUserGroups(username string) ([]GroupMeta, error)
+
+	// MySQLProjectGrantGroupPermission gives the members of the group the permission `permissionLevel` on project `projectID`
+	MySQLProjectGrantGroupPermission(projectID int64, groupID int64, permissionLevel int8, grantedByUsername string) error
+
+	// MySQLProjectRevokeGroupPermission removes the group's permissions from the project
+	MySQLProjectRevokeGroupPermission(projectID int64, groupID int64, revokedByUsername string) error
+
+	// MySQLInviteCreate creates a pending invite to a project, which expires after the given duration
+	MySQLInviteCreate(invite Invite, expiresIn time.Duration) (inviteID int64, err error)
+
+	// MySQLInviteGet returns the invite with the given inviteID, whether or not it has expired
+	MySQLInviteGet(inviteID int64) (Invite, error)
+
+	// MySQLUserGetInvites returns the unexpired invites for the user `username`
+	MySQLUserGetInvites(username string) ([]Invite, error)
+
+	// MySQLInviteDelete deletes the invite with the given inviteID
+	MySQLInviteDelete(inviteID int64) error
+
+	// MySQLInviteBindEmail assigns the unexpired invites sent to the email address to the user `username`
+	MySQLInviteBindEmail(email string, username string) error
+
+	// MySQLProjectRequestTransfer records a pending transfer of the project's ownership, replacing any existing one
+	MySQLProjectRequestTransfer(transfer OwnershipTransfer) error
+
+	// MySQLProjectGetTransfer returns the pending ownership transfer for the project with the given projectID
+	MySQLProjectGetTransfer(projectID int64) (OwnershipTransfer, error)
+
+	// MySQLProjectCancelTransfer deletes the pending ownership transfer for the project with the given projectID
+	MySQLProjectCancelTransfer(projectID int64) error
+
+	// MySQLProjectTransferOwnership applies the pending ownership transfer for the project, renaming it to `newName`.
+	// Returns ErrNameConflict if the new owner already owns a project with that name.
+	MySQLProjectTransferOwnership(projectID int64, newName string) error
+
+	// MySQLAccessTokenCreate creates an access token for token.Username, which expires after the given duration,
+	// or never if it is 0
+	MySQLAccessTokenCreate(token AccessToken, tokenHash string, expiresIn time.Duration) (tokenID int64, err error)
+
+	// MySQLAccessTokenGet returns the access token with the given tokenID, whether or not it has expired.
+	// Returns ErrNoData if there is no such token.
+	MySQLAccessTokenGet(tokenID int64) (AccessToken, error)
+
+	// MySQLAccessTokenAuthenticate returns the unexpired access token with the given token hash.
+	// Returns ErrNoData if there is no such token.
+	MySQLAccessTokenAuthenticate(tokenHash string) (AccessToken, error)
+
+	// MySQLAccessTokenDelete deletes the access token with the given tokenID
+	MySQLAccessTokenDelete(tokenID int64) error
+
+	// MySQLUserGetAccessTokens returns the access tokens of the given user, newest first
+	MySQLUserGetAccessTokens(username string) ([]AccessToken, error)
+
+	// MySQLUserSet2FASecret sets the TOTP secret of the user `username`, replacing any they have not yet confirmed.
+	// Returns ErrNoDbChange if they already have two-factor authentication enabled.
+	MySQLUserSet2FASecret(username string, secret string) error
+
+	// MySQLUserGet2FA returns the two-factor authentication settings of the user `username`.
+	// Returns ErrNoData if they have not set a TOTP secret.
+	MySQLUserGet2FA(username string) (TwoFactor, error)
+
+	// MySQLUserEnable2FA enables two-factor authentication for the user `username`, once they have confirmed their
+	// secret with a code for the given time step, and replaces their recovery codes.
+	// Returns ErrNoDbChange if they have no unconfirmed secret.
+	MySQLUserEnable2FA(username string, step int64, recoveryCodeHashes []string) error
+
+	// MySQLUserUse2FAStep records the use of a code for the given time step.
+	// Returns ErrNoDbChange if a code for the same or a later step has already been used.
+	MySQLUserUse2FAStep(username string, step int64) error
+
+	// MySQLUserUseRecoveryCode deletes the recovery code with the given hash, so that it cannot be used again.
+	// Returns ErrNoDbChange if the user has no such recovery code.
+	MySQLUserUseRecoveryCode(username string, codeHash string) error
+
+	// MySQLUserDisable2FA deletes the TOTP secret and recovery codes of the user `username`
+	MySQLUserDisable2FA(username string) error
+
+	// MySQLEmailVerificationCreate stores the hash of a token verifying the current email address of the pending
+	// user `username`, replacing any earlier token. Returns ErrNoDbChange if the user is not pending, or has no email.
+	MySQLEmailVerificationCreate(username string, tokenHash string, expiresIn time.Duration) error
+
+	// MySQLEmailChangeCreate stores the hash of a token verifying a new email address for the user `username`,
+	// replacing any earlier token. The address is only set once the token is redeemed.
+	MySQLEmailChangeCreate(username string, email string, tokenHash string, expiresIn time.Duration) error
+
+	// MySQLEmailVerificationRedeem sets the email address the token was created for, activates the user, and deletes
+	// the token. Returns ErrNoData if the token is unknown or expired.
+	MySQLEmailVerificationRedeem(tokenHash string) (username string, err error)
+
+	// MySQLLoginFailuresGet returns the failed logins counted for the subject, a username or IP address depending on
+	// kind. Returns ErrNoData if none have been counted.
+	MySQLLoginFailuresGet(kind string, subject string) (LoginFailures, error)
+
+	// MySQLLoginFailureRecord counts a failed login for the subject, returning the number of consecutive failures.
+	// The count restarts if resetAfter has passed since the last failure.
+	MySQLLoginFailureRecord(kind string, subject string, resetAfter time.Duration) (failures int, err error)
+
+	// MySQLLoginFailuresLock blocks logins for the subject for the given duration, and resets its failure count
+	MySQLLoginFailuresLock(kind string, subject string, lockFor time.Duration) error
+
+	// MySQLLoginFailuresClear forgets the failed logins and any lock of the subject. Returns ErrNoDbChange if there
+	// were none.
+	MySQLLoginFailuresClear(kind string, subject string) error
+
+	// MySQLNotificationInsert stores a notification in the inbox of the user `username`
+	MySQLNotificationInsert(username string, notification Notification) error
+
+	// MySQLNotificationsGet returns the notifications in the inbox of the user `username`, newest first
+	MySQLNotificationsGet(username string, unreadOnly bool, offset int, limit int) ([]Notification, error)
+
+	// MySQLNotificationsCountUnread returns the number of unread notifications in the inbox of the user `username`
+	MySQLNotificationsCountUnread(username string) (int, error)
+
+	// MySQLNotificationsMarkRead marks the notifications with the given IDs in the inbox of the user `username` as
+	// read, or all of them if no IDs are given. Returns the number of notifications which were unread.
+	MySQLNotificationsMarkRead(username string, notificationIDs []int64) (int, error)
+
+	// MySQLCommentThreadCreate starts a thread anchored to thread.FileID, with body as its first comment
+	MySQLCommentThreadCreate(thread CommentThread, body string) (threadID int64, commentID int64, err error)
+
+	// MySQLCommentReply adds a comment to the thread with the given threadID
+	MySQLCommentReply(threadID int64, author string, body string) (commentID int64, err error)
+
+	// MySQLCommentThreadResolve resolves or reopens the thread with the given threadID.
+	// Returns ErrNoDbChange if the thread does not exist, or is already in that state.
+	MySQLCommentThreadResolve(threadID int64, resolved bool, username string) error
+
+	// MySQLCommentThreadGet returns the thread with the given threadID, without its comments.
+	// Returns ErrNoData if the thread does not exist.
+	MySQLCommentThreadGet(threadID int64) (CommentThread, error)
+
+	// MySQLCommentThreadsGet returns the threads of the file with the given fileID, without their comments
+	MySQLCommentThreadsGet(fileID int64, includeResolved bool) ([]CommentThread, error)
+
+	// MySQLCommentsGet returns the comments of all threads of the file with the given fileID, oldest first
+	MySQLCommentsGet(fileID int64) ([]Comment, error)
+
+	// MySQLCommentAnchorUpdate moves the anchor of the thread with the given threadID, if it is still at fromVersion.
+	// Returns ErrNoDbChange if it has already been moved.
+	MySQLCommentAnchorUpdate(threadID int64, fromVersion int64, anchor CommentAnchor) error
+
+	// MySQLSuggestionCreate stores the suggestion as pending review
+	MySQLSuggestionCreate(suggestion Suggestion) (suggestionID int64, err error)
+
+	// MySQLSuggestionGet returns the suggestion with the given suggestionID.
+	// Returns ErrNoData if the suggestion does not exist.
+	MySQLSuggestionGet(suggestionID int64) (Suggestion, error)
+
+	// MySQLSuggestionsGet returns the pending suggestions for the file with the given fileID, oldest first
+	MySQLSuggestionsGet(fileID int64) ([]Suggestion, error)
+
+	// MySQLSuggestionRebase replaces the patch of the suggestion with the given suggestionID, if it is still based on
+	// fromVersion. Returns ErrNoDbChange if it has already been rebased.
+	MySQLSuggestionRebase(suggestionID int64, fromVersion int64, patch string, version int64) error
+
+	// MySQLSuggestionDelete removes the suggestion with the given suggestionID once it has been accepted or rejected.
+	// Returns ErrNoDbChange if the suggestion does not exist.
+	MySQLSuggestionDelete(suggestionID int64) error
+
+	// MySQLFileLockAcquire locks the file with the given lock.FileID for lock.Username's websocket lock.Socket, unless
+	// another websocket holds an unexpired lock on it; locks already held by lock.Socket are renewed. Returns the lock
+	// held on the file afterwards, which is the other websocket's if the file could not be locked.
+	MySQLFileLockAcquire(lock FileLock, ttl time.Duration) (FileLock, error)
+
+	// MySQLFileLockGet returns the unexpired lock on the file with the given fileID.
+	// Returns ErrNoData if the file is not locked.
+	MySQLFileLockGet(fileID int64) (FileLock, error)
+
+	// MySQLFileLockRelease releases the lock on the file with the given fileID, if it is held by `username` or
+	// force is set. Returns ErrNoDbChange if there was no such lock.
+	MySQLFileLockRelease(fileID int64, username string, force bool) error
+
+	// MySQLFileLocksReleaseSocket releases all locks held by the websocket with the given queue name, returning the
+	// unexpired locks which were released
+	MySQLFileLocksReleaseSocket(socket string) ([]FileLock, error)
+
+	// MySQLWebhookCreate registers a webhook for the project with the given webhook.ProjectID
+	MySQLWebhookCreate(webhook Webhook) (webhookID int64, err error)
+
+	// MySQLWebhooksGet returns the webhooks of the project with the given projectID, including their secrets
+	MySQLWebhooksGet(projectID int64) ([]Webhook, error)
+
+	// MySQLWebhookDelete deletes the webhook with the given webhookID from the project with the given projectID.
+	// Returns ErrNoDbChange if the project has no such webhook.
+	MySQLWebhookDelete(projectID int64, webhookID int64) error
+
+	// MySQLWebhookProjectIDs returns the IDs of the projects with webhooks
+	MySQLWebhookProjectIDs() ([]int64, error)
+
+	// MySQLWebhookDeliveryInsert records the outcome of a delivery. Only the latest WebhookDeliveriesKept deliveries of
+	// each webhook are kept.
+	MySQLWebhookDeliveryInsert(delivery WebhookDelivery) error
+
+	// MySQLWebhookDeliveriesGet returns the latest deliveries to the webhook with the given webhookID, newest first
+	MySQLWebhookDeliveriesGet(webhookID int64, limit int) ([]WebhookDelivery, error)
+
+	// MySQLExternalIdentityLookup returns the user linked to the given subject of an identity provider.
+	// Returns ErrNoData if the identity is not linked to any user.
+	MySQLExternalIdentityLookup(provider string, subject string) (username string, err error)
+
+	// MySQLExternalIdentityLink links the given subject of an identity provider to the user `username`
+	MySQLExternalIdentityLink(provider string, subject string, username string) error
+
+	// MySQLShareLinkCreate creates a share link to a project, which expires after the given duration
+	MySQLShareLinkCreate(link ShareLink, tokenHash string, expiresIn time.Duration) (shareLinkID int64, err error)
+
+	// MySQLShareLinkGet returns the share link with the given shareLinkID, whether or not it has expired.
+	// Links to projects in the trash are not returned.
+	MySQLShareLinkGet(shareLinkID int64) (ShareLink, error)
+
+	// MySQLShareLinkRedeem counts a use of the share link with the given token hash, returning its shareLinkID.
+	// Returns ErrNoData if there is no such link, or if it has expired or been used up.
+	MySQLShareLinkRedeem(tokenHash string) (shareLinkID int64, err error)
+
+	// MySQLShareLinkDelete deletes the share link with the given shareLinkID
+	MySQLShareLinkDelete(shareLinkID int64) error
+
+	// MySQLProjectGetShareLinks returns the share links to the project with the given projectID, newest first
+	MySQLProjectGetShareLinks(projectID int64) ([]ShareLink, error)
+
+	// MySQLAuditLogInsert appends an entry to the audit log
+	MySQLAuditLogInsert(entry AuditEntry) error
+
+	// MySQLProjectGetAuditLog returns the audit log entries for the project with the given projectID, newest first
+	MySQLProjectGetAuditLog(projectID int64, filter AuditFilter) ([]AuditEntry, error)
+
 	// MySQLFileCreate create a new file in MySQL
 	MySQLFileCreate(username string, filename string, relativePath string, projectID int64) (fileID int64, err error)
 
-	// MySQLFileDelete deletes a file from the MySQL database
+	// MySQLFileDelete permanently deletes a file from the MySQL database
 	// this does not delete the actual file
 	MySQLFileDelete(fileID int64) error
 
+	// MySQLFileTrash moves a file to the trash, hiding it from its project
+	MySQLFileTrash(fileID int64, deletedBy string) error
+
+	// MySQLFileRestore moves a file out of the trash
+	MySQLFileRestore(fileID int64) error
+
+	// MySQLFileGetTrashedInfo returns the meta data about the given file, if it is in the trash
+	MySQLFileGetTrashedInfo(fileID int64) (TrashedFile, error)
+
+	// MySQLProjectGetTrashedFiles returns the files from the project which are in the trash, most recent first
+	MySQLProjectGetTrashedFiles(projectID int64) ([]TrashedFile, error)
+
+	// MySQLExpiredTrashedFiles returns the files which were moved to the trash before the given time, excluding
+	// those in projects which are themselves in the trash
+	MySQLExpiredTrashedFiles(deletedBefore time.Time) ([]TrashedFile, error)
+
 	// MySQLFileMove updates MySQL with the  new path of the file with FileID == 'fileID'
 	MySQLFileMove(fileID int64, newPath string) error
 
@@ -114,10 +446,26 @@ type DBFS interface {
 	// returns that path so it can be put in MySQL
 	FileWrite(relpath string, filename string, projectID int64, raw []byte) (string, error)
 
+	// FileCheckWritable checks that the project directory exists and is writable
+	FileCheckWritable() error
+
 	// FileDelete deletes the file with the given metadata from the file system
 	// Couple this with dbfs.MySQLFileDelete and dbfs.CBDeleteFile
 	FileDelete(relpath string, filename string, projectID int64) error
 
+	// FileTrash moves the file with the given metadata to the trash on the file system
+	// Couple this with dbfs.MySQLFileTrash
+	FileTrash(meta FileMeta) error
+
+	// FileRestore moves the file with the given metadata out of the trash on the file system
+	FileRestore(meta FileMeta) error
+
+	// FilePurge deletes the trashed file with the given metadata from the file system
+	FilePurge(meta FileMeta) error
+
+	// FileDeleteProject deletes all files of the project, including trashed ones, from the file system
+	FileDeleteProject(projectID int64) error
+
 	// FileMove moves a file form the starting path to the end path
 	FileMove(startRelpath string, startFilename string, endRelpath string, endFilename string, projectID int64) error
 
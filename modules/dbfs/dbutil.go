@@ -1,6 +1,7 @@
 package dbfs
 
 import (
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -31,6 +32,9 @@ var ErrDbNotInitialized = errors.New("The database was not propperly initialized
 // ErrMaliciousRequest : The request attempted to directly tamper with our filesystem / database
 var ErrMaliciousRequest = errors.New("The request attempted to directly tamper with our filesystem / database")
 
+// ErrNameConflict : The request would give a user two projects with the same name
+var ErrNameConflict = errors.New("The user already owns a project with that name")
+
 // ProjectPermission is the type which represents the permission relationship on projects
 type ProjectPermission struct {
 	Username        string
@@ -46,6 +50,72 @@ type ProjectMeta struct {
 	PermissionLevel int8
 }
 
+// TrashedProject is a project which has been moved to the trash
+type TrashedProject struct {
+	ProjectID   int64
+	Name        string
+	Owner       string
+	DeletedDate time.Time
+}
+
+// PathPermission is the type which represents a row in the MySQL `PathPermissions` table. It overrides the
+// project-level role of a user for the files under PathPrefix.
+type PathPermission struct {
+	PathPrefix string
+	Username   string
+	// PermissionLevel is the level of the role granted under the prefix, or 0 for no access
+	PermissionLevel int8
+	GrantedBy       string
+	GrantedDate     time.Time
+}
+
+// GroupMeta is the type which represents a row in the MySQL `Groups` table
+type GroupMeta struct {
+	GroupID      int64
+	Name         string
+	Owner        string
+	CreationDate time.Time
+}
+
+// Invite is the type which represents a row in the MySQL `Invites` table
+type Invite struct {
+	InviteID    int64
+	ProjectID   int64
+	ProjectName string
+	// Username is the invited user. Invites sent to an email address have no username until it is registered.
+	Username        string
+	Email           string
+	PermissionLevel int8
+	InvitedBy       string
+	CreationDate    time.Time
+	ExpiryDate      time.Time
+}
+
+// ShareLink is the type which represents a row in the MySQL `ShareLinks` table. The link's token is only ever
+// given to its creator; just its hash is stored.
+type ShareLink struct {
+	ShareLinkID  int64
+	ProjectID    int64
+	ProjectName  string
+	CreatedBy    string
+	CreationDate time.Time
+	ExpiryDate   time.Time
+	// MaxUses is the number of times the link can be redeemed; 0 is unlimited
+	MaxUses int
+	Uses    int
+}
+
+// OwnershipTransfer is the type which represents a row in the MySQL `OwnershipTransfers` table
+type OwnershipTransfer struct {
+	ProjectID   int64
+	ProjectName string
+	FormerOwner string
+	NewOwner    string
+	// FormerOwnerLevel is the permission level the former owner keeps on the project once the transfer is accepted
+	FormerOwnerLevel int8
+	RequestedDate    time.Time
+}
+
 // FileMeta is the type that contains all the metadata about a file
 type FileMeta struct {
 	FileID       int64
@@ -56,6 +126,13 @@ type FileMeta struct {
 	Filename     string
 }
 
+// TrashedFile is a file which has been moved to the trash
+type TrashedFile struct {
+	FileMeta
+	DeletedBy   string
+	DeletedDate time.Time
+}
+
 // UserMeta is the type that contains all the metadata about a user
 type UserMeta struct {
 	Username  string
@@ -63,17 +140,244 @@ type UserMeta struct {
 	Email     string
 	FirstName string
 	LastName  string
+	// Bot is set for accounts which cannot log in with a password, and only use access tokens
+	Bot bool
+	// BotOwner is the user who registered the bot account
+	BotOwner string
+	// Status is UserStatusActive, or UserStatusPending until the user has verified their email address. Users
+	// registered with an empty Status are active.
+	Status string
+	// HiddenFromSearch excludes the user from MySQLUserSearch results; they can still be looked up by username
+	HiddenFromSearch bool
+}
+
+// UserSearchResult is a user matching a search, as returned by MySQLUserSearch. Email addresses can be searched on,
+// but are not returned.
+type UserSearchResult struct {
+	Username  string
+	FirstName string
+	LastName  string
+	Bot       bool
+	// HasAccess is set if the user has permissions on the project searched from, either directly or through a group
+	HasAccess bool
+}
+
+// Account statuses
+const (
+	UserStatusActive  = "active"
+	UserStatusPending = "pending"
+)
+
+// Kinds of subjects failed logins are counted for
+const (
+	LoginSubjectUser = "user"
+	LoginSubjectIP   = "ip"
+)
+
+// LoginFailures is the type which represents a row in the MySQL `LoginFailures` table, which counts the recent failed
+// logins for a username or IP address
+type LoginFailures struct {
+	Failures    int
+	LastFailure time.Time
+	// LockedUntil is the time logins are blocked until, or the zero time if they are not blocked
+	LockedUntil time.Time
+}
+
+// Notification is the type which represents a row in the MySQL `Notifications` table: a notification sent to a
+// user's channel, kept so that users who were offline can read it later
+type Notification struct {
+	NotificationID int64
+	Resource       string
+	Method         string
+	ResourceID     int64
+	// Data is the JSON encoded data of the notification
+	Data         json.RawMessage
+	CreationDate time.Time
+	Read         bool
+}
+
+// CommentAnchor is the range of a file's text a comment thread is attached to, as of the given version of the file
+type CommentAnchor struct {
+	StartIndex  int
+	EndIndex    int
+	FileVersion int64
+	// Orphaned is set once the anchored text has been deleted; orphaned anchors are no longer shifted
+	Orphaned bool
+}
+
+// CommentThread is the type which represents a row in the MySQL `CommentThreads` table
+type CommentThread struct {
+	ThreadID int64
+	FileID   int64
+	CommentAnchor
+	Resolved     bool
+	ResolvedBy   string
+	CreatedBy    string
+	CreationDate time.Time
+	Comments     []Comment
+}
+
+// Comment is the type which represents a row in the MySQL `Comments` table
+type Comment struct {
+	CommentID    int64
+	ThreadID     int64
+	Author       string
+	Body         string
+	CreationDate time.Time
+}
+
+// Suggestion is the type which represents a row in the MySQL `Suggestions` table: a change proposed by a user who
+// cannot edit the file, pending review by one who can
+type Suggestion struct {
+	SuggestionID int64
+	FileID       int64
+	Author       string
+	// Patch is the proposed change, rebased onto FileVersion
+	Patch        string
+	FileVersion  int64
+	CreationDate time.Time
+}
+
+// FileLock is the type which represents a row in the MySQL `FileLocks` table: a lease on a file, held by one of a
+// user's websockets until it expires, is released, or the websocket disconnects. Only that websocket can renew the
+// lock, though the user can release it from any of theirs.
+type FileLock struct {
+	FileID   int64
+	Username string
+	// Socket is the name of the queue of the websocket holding the lock
+	Socket string `json:"-"`
+	// Enforced locks reject changes from every websocket but the one holding them; other locks are only advisory
+	Enforced   bool
+	ExpiryDate time.Time
+}
+
+// WebhookDeliveriesKept is the number of deliveries kept in the history of each webhook
+const WebhookDeliveriesKept = 100
+
+// Webhook is the type which represents a row in the MySQL `Webhooks` table: a URL which a project's events are
+// POSTed to
+type Webhook struct {
+	WebhookID int64
+	ProjectID int64
+	URL       string
+	// Secret is the key deliveries are signed with; it is only shown to the user who created the webhook
+	Secret string `json:"-"`
+	// Events are the events delivered to the webhook, as Resource.Method; webhooks without events receive all of them
+	Events       []string
+	CreatedBy    string
+	CreationDate time.Time
+}
+
+// WebhookDelivery is the type which represents a row in the MySQL `WebhookDeliveries` table, recording the outcome
+// of delivering an event to a webhook
+type WebhookDelivery struct {
+	DeliveryID int64
+	WebhookID  int64
+	Event      string
+	Attempts   int
+	// StatusCode is the HTTP status of the last attempt, or 0 if it did not get a response
+	StatusCode     int
+	Error          string
+	Success        bool
+	CompletionDate time.Time
+}
+
+// UsernameTombstone is the type which represents a row in the MySQL `UsernameTombstones` table, recording that a
+// user was renamed, so that tokens issued to the old username can be rejected
+type UsernameTombstone struct {
+	Username    string
+	RenamedTo   string
+	RenamedDate time.Time
+}
+
+// EmailVerification is the type which represents a row in the MySQL `EmailVerifications` table. Only the hash of the
+// token is stored.
+type EmailVerification struct {
+	Username   string
+	Email      string
+	ExpiryDate time.Time
+}
+
+// AccessToken is the type which represents a row in the MySQL `AccessTokens` table. Like share links, just the hash
+// of the token is stored.
+type AccessToken struct {
+	TokenID  int64
+	Username string
+	Name     string
+	// Scopes limit what the token can be used for; a token without scopes has all of its user's access
+	Scopes       []string
+	CreationDate time.Time
+	// ExpiryDate is the zero time for tokens which do not expire
+	ExpiryDate time.Time
+}
+
+// TwoFactor is the type which represents a row in the MySQL `TwoFactor` table, holding a user's TOTP secret
+type TwoFactor struct {
+	Secret string
+	// Enabled is false until the user has confirmed they have the secret
+	Enabled bool
+	// LastUsedStep is the time step of the last code used, so that each code can only be used once
+	LastUsedStep int64
+}
+
+// AuditEntry is the type which represents a row in the MySQL `AuditLog` table
+type AuditEntry struct {
+	AuditID   int64
+	Timestamp time.Time
+	Actor     string
+	Action    string
+	// ProjectID is the project the action was performed on, or 0 if it was not on a project
+	ProjectID int64
+	// Target is the user or file the action was performed on
+	Target string
+	// Detail holds action-specific information, such as a new name or permission level
+	Detail   string
+	SourceIP string
+}
+
+// AuditFilter restricts which audit log entries are returned. Empty fields are not filtered on.
+type AuditFilter struct {
+	Action string
+	Actor  string
+	Since  time.Time
+	Until  time.Time
+	Offset int
+	Limit  int
 }
 
-// PermissionAtLeast is a helper to verify a user has at least the given permission on the given project
-func PermissionAtLeast(username string, projectID int64, label string, db DBFS) (bool, error) {
-	required, err := config.PermissionByLabel(label)
+// ProjectRole returns the role with the given level on the given project. Roles configured for the server take
+// precedence; levels they do not use are looked up in the project's custom roles.
+func ProjectRole(projectID int64, level int8, db DBFS) (config.Role, error) {
+	if role, err := config.RoleByLevel(level); err == nil {
+		return role, nil
+	}
+
+	projectRoles, err := db.MySQLProjectGetRoles(projectID)
 	if err != nil {
-		return false, err
+		return config.Role{}, err
+	}
+	for _, role := range projectRoles {
+		if role.Level == level {
+			return role, nil
+		}
+	}
+	return config.Role{}, config.ErrNoMatchingPermission
+}
+
+// UserRole returns the role the user holds on the given project
+func UserRole(username string, projectID int64, db DBFS) (config.Role, error) {
+	level, err := db.MySQLUserProjectPermissionLookup(projectID, username)
+	if err != nil {
+		return config.Role{}, err
 	}
-	actual, err := db.MySQLUserProjectPermissionLookup(projectID, username)
+	return ProjectRole(projectID, level, db)
+}
+
+// HasCapability is a helper to verify a user's role on the given project grants the given capability
+func HasCapability(username string, projectID int64, capability string, db DBFS) (bool, error) {
+	role, err := UserRole(username, projectID, db)
 	if err != nil {
 		return false, err
 	}
-	return required.Level <= actual, nil
+	return role.Has(capability), nil
 }
@@ -0,0 +1,40 @@
+package dbfs
+
+import (
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasCapability(t *testing.T) {
+	testConfigSetup(t)
+	db := NewDBMock()
+	projectID, _ := db.MySQLProjectCreate("owner", "project")
+	db.MySQLProjectGrantPermission(projectID, "writer", config.PermissionsByLabel["write"], "owner")
+	db.MySQLProjectGrantPermission(projectID, "reviewer", 20, "owner")
+	db.ProjectRoles[projectID] = []config.Role{
+		{Name: "reviewer", Level: 20, Capabilities: []string{config.CapabilityRead, config.CapabilityComment}},
+	}
+
+	hasCapability, err := HasCapability("owner", projectID, config.CapabilityDeleteProject, db)
+	assert.NoError(t, err)
+	assert.True(t, hasCapability, "the owner should have every capability")
+
+	hasCapability, err = HasCapability("writer", projectID, config.CapabilityWrite, db)
+	assert.NoError(t, err)
+	assert.True(t, hasCapability)
+	hasCapability, err = HasCapability("writer", projectID, config.CapabilityManagePermissions, db)
+	assert.NoError(t, err)
+	assert.False(t, hasCapability)
+
+	hasCapability, err = HasCapability("reviewer", projectID, config.CapabilityComment, db)
+	assert.NoError(t, err)
+	assert.True(t, hasCapability, "custom project roles should be used for levels without a server role")
+	hasCapability, err = HasCapability("reviewer", projectID, config.CapabilityWrite, db)
+	assert.NoError(t, err)
+	assert.False(t, hasCapability)
+
+	_, err = HasCapability("stranger", projectID, config.CapabilityRead, db)
+	assert.Error(t, err, "users without a role on the project should not have any capabilities")
+}
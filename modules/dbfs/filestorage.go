@@ -34,6 +34,22 @@ func (di *DatabaseImpl) FileWrite(relpath string, filename string, projectID int
 	return fileLocation, err
 }
 
+// FileCheckWritable checks that the project directory exists, and files can be written to it
+func (di *DatabaseImpl) FileCheckWritable() error {
+	projectPath := config.GetConfig().ServerConfig.ProjectPath
+	err := os.MkdirAll(projectPath, 0744)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(projectPath, ".writecheck")
+	if err != nil {
+		return err
+	}
+	tmpFile.Close()
+	return os.Remove(tmpFile.Name())
+}
+
 // FileDelete deletes the file with the given metadata from the file system
 // Couple this with dbfs.MySQLFileDelete and dbfs.CBDeleteFile
 func (di *DatabaseImpl) FileDelete(relpath string, filename string, projectID int64) error {
@@ -45,6 +61,74 @@ func (di *DatabaseImpl) FileDelete(relpath string, filename string, projectID in
 	return os.Remove(fileLocation)
 }
 
+// trashDirName is the directory in the ProjectPath which holds trashed files. It can't clash with a project's
+// directory, since those are named by ProjectID
+const trashDirName = ".trash"
+
+// getTrashFilepath returns the location a trashed file is kept at. Trashed files are stored by FileID, since a
+// new file may be created at the path of a trashed one.
+func (di *DatabaseImpl) getTrashFilepath(meta FileMeta) string {
+	projectFolderParentPath := config.GetConfig().ServerConfig.ProjectPath
+	return filepath.Join(projectFolderParentPath, trashDirName, strconv.FormatInt(meta.ProjectID, 10),
+		strconv.FormatInt(meta.FileID, 10))
+}
+
+// FileTrash moves the file with the given metadata to the trash on the file system
+// Couple this with dbfs.MySQLFileTrash
+func (di *DatabaseImpl) FileTrash(meta FileMeta) error {
+	relFilePath, err := di.getFilepath(meta.RelativePath, meta.Filename, meta.ProjectID)
+	if err != nil {
+		return err
+	}
+	fileLocation := filepath.Join(relFilePath, meta.Filename)
+	trashLocation := di.getTrashFilepath(meta)
+
+	err = os.MkdirAll(filepath.Dir(trashLocation), 0744)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(fileLocation, trashLocation)
+	if err != nil {
+		return err
+	}
+
+	// a leftover swap file would otherwise be picked up by a new file created at the same path
+	err = os.Remove(di.getSwpLocation(fileLocation))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// FileRestore moves the file with the given metadata out of the trash on the file system
+func (di *DatabaseImpl) FileRestore(meta FileMeta) error {
+	relFilePath, err := di.getFilepath(meta.RelativePath, meta.Filename, meta.ProjectID)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(relFilePath, 0744)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(di.getTrashFilepath(meta), filepath.Join(relFilePath, meta.Filename))
+}
+
+// FilePurge deletes the trashed file with the given metadata from the file system
+func (di *DatabaseImpl) FilePurge(meta FileMeta) error {
+	return os.Remove(di.getTrashFilepath(meta))
+}
+
+// FileDeleteProject deletes all files of the project, including trashed ones, from the file system
+func (di *DatabaseImpl) FileDeleteProject(projectID int64) error {
+	projectFolderParentPath := config.GetConfig().ServerConfig.ProjectPath
+	err := os.RemoveAll(filepath.Join(projectFolderParentPath, strconv.FormatInt(projectID, 10)))
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(projectFolderParentPath, trashDirName, strconv.FormatInt(projectID, 10)))
+}
+
 // FileRead returns the project file from the calculated location on the disk
 func (di *DatabaseImpl) FileRead(relpath string, filename string, projectID int64) (*[]byte, error) {
 	relFilePath, err := di.getFilepath(relpath, filename, projectID)
@@ -134,6 +134,45 @@ func TestDatabaseImpl_FileDelete(t *testing.T) {
 
 }
 
+func TestDatabaseImpl_FileTrash(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	defer os.RemoveAll(config.GetConfig().ServerConfig.ProjectPath)
+
+	projectParentPath := filepath.Clean(config.GetConfig().ServerConfig.ProjectPath)
+	filepath1 := filepath.Join(projectParentPath, "10", "hi", "myFile1.txt")
+	meta := FileMeta{FileID: 3, ProjectID: 10, RelativePath: "hi", Filename: "myFile1.txt"}
+
+	fileText := []byte("Hello World!\nWelcome to my file\n")
+	_, err := di.FileWrite(meta.RelativePath, meta.Filename, meta.ProjectID, fileText)
+	assert.NoError(t, err)
+
+	assert.NoError(t, di.FileTrash(meta))
+	_, err = os.Stat(filepath1)
+	assert.True(t, os.IsNotExist(err), "trashed files should be moved out of the project")
+
+	// a new file can be created at the path of the trashed one
+	_, err = di.FileWrite(meta.RelativePath, meta.Filename, meta.ProjectID, []byte("new file"))
+	assert.NoError(t, err)
+	assert.NoError(t, di.FileDelete(meta.RelativePath, meta.Filename, meta.ProjectID))
+
+	assert.NoError(t, di.FileRestore(meta))
+	data, err := di.FileRead(meta.RelativePath, meta.Filename, meta.ProjectID)
+	assert.NoError(t, err)
+	assert.Equal(t, fileText, *data)
+
+	assert.NoError(t, di.FileTrash(meta))
+	assert.NoError(t, di.FilePurge(meta))
+	assert.Error(t, di.FileRestore(meta), "purged files should not be restorable")
+
+	_, err = di.FileWrite(meta.RelativePath, meta.Filename, meta.ProjectID, fileText)
+	assert.NoError(t, err)
+	assert.NoError(t, di.FileDeleteProject(meta.ProjectID))
+	_, err = os.Stat(filepath.Join(projectParentPath, "10"))
+	assert.True(t, os.IsNotExist(err), "the project's directory should be removed")
+}
+
 func TestDatabaseImpl_FileMove(t *testing.T) {
 	testConfigSetup(t)
 	di := new(DatabaseImpl)
@@ -0,0 +1,41 @@
+package dbfs
+
+import (
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/metrics"
+)
+
+// metricsDBFS wraps a DBFS implementation, recording metrics for scrunching and file changes.
+// All other calls are passed through to the wrapped implementation.
+type metricsDBFS struct {
+	DBFS
+}
+
+// WithMetrics wraps the given DBFS, instrumenting it with metrics
+func WithMetrics(db DBFS) DBFS {
+	return metricsDBFS{DBFS: db}
+}
+
+// ScrunchFile scrunches the file using the wrapped DBFS, recording how long it took
+func (m metricsDBFS) ScrunchFile(meta FileMeta) error {
+	start := time.Now()
+	err := m.DBFS.ScrunchFile(meta)
+	metrics.ScrunchDuration.Observe(time.Since(start).Seconds())
+	return err
+}
+
+// CBAppendFileChange appends the change using the wrapped DBFS, recording transforms, conflicts,
+// and the number of patches tracked for the file
+func (m metricsDBFS) CBAppendFileChange(file FileMeta, patches string) (string, int64, []string, int, error) {
+	patch, version, missing, numChanges, err := m.DBFS.CBAppendFileChange(file, patches)
+	if err == ErrVersionOutOfDate {
+		metrics.FileChangeConflicts.Inc()
+	} else if err == nil {
+		if len(missing) > 0 {
+			metrics.FileChangeTransforms.Inc()
+		}
+		metrics.PatchesPerFile.Observe(float64(numChanges))
+	}
+	return patch, version, missing, numChanges, err
+}
@@ -0,0 +1,38 @@
+package dbfs
+
+import (
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMetrics_CBAppendFileChange(t *testing.T) {
+	mock := NewDBMock()
+	db := WithMetrics(mock)
+	meta := FileMeta{FileID: 1}
+	mock.FileVersion[meta.FileID] = 1
+
+	conflicts := metrics.FileChangeConflicts.Value()
+	patchCount := metrics.PatchesPerFile.Count()
+
+	_, _, _, _, err := db.CBAppendFileChange(meta, "v1:\n0:+1:a:\n0")
+	assert.NoError(t, err)
+	assert.Equal(t, patchCount+1, metrics.PatchesPerFile.Count(), "Successful change should record the number of patches")
+	assert.Equal(t, conflicts, metrics.FileChangeConflicts.Value())
+
+	_, _, _, _, err = db.CBAppendFileChange(meta, "v10:\n0:+1:a:\n0")
+	assert.Equal(t, ErrVersionOutOfDate, err)
+	assert.Equal(t, conflicts+1, metrics.FileChangeConflicts.Value(), "Out of date change should be counted as a conflict")
+
+	assert.Equal(t, 2, mock.FunctionCallCount, "Calls should be passed through to the wrapped DBFS")
+}
+
+func TestWithMetrics_ScrunchFile(t *testing.T) {
+	mock := NewDBMock()
+	db := WithMetrics(mock)
+
+	count := metrics.ScrunchDuration.Count()
+	db.ScrunchFile(FileMeta{FileID: 1})
+	assert.Equal(t, count+1, metrics.ScrunchDuration.Count())
+}
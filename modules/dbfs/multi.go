@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/CodeCollaborate/Server/modules/patching"
@@ -22,6 +23,29 @@ var MaxBufferLength = MinBufferLength * 10
 // consider it failed and could retry
 var ScrunchingExpiryLength = uint32((5 * time.Minute).Seconds())
 
+// scrunchesInProgress tracks the scrunches started by ScrunchInBackground
+var scrunchesInProgress sync.WaitGroup
+
+// ScrunchInBackground scrunches the given file on a new goroutine. The scrunch is tracked, so that
+// WaitForScrunching can wait for it to complete before the server shuts down.
+func ScrunchInBackground(db DBFS, meta FileMeta) {
+	scrunchesInProgress.Add(1)
+	go func() {
+		defer scrunchesInProgress.Done()
+
+		err := db.ScrunchFile(meta)
+		utils.LogError("Failed to scrunch file", err, utils.LogFields{
+			"FileID": meta.FileID,
+		})
+	}()
+}
+
+// WaitForScrunching waits for all scrunches started by ScrunchInBackground to complete,
+// returning an error if they did not complete within the given timeout.
+func WaitForScrunching(timeout time.Duration) error {
+	return utils.WaitTimeout(&scrunchesInProgress, timeout)
+}
+
 // ScrunchFile scrunches all but the last minBufferLength items into the file on disk
 // It then removes the changes from Couchbase
 func (di *DatabaseImpl) ScrunchFile(meta FileMeta) error {
@@ -2,15 +2,15 @@ package dbfs
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql" // required to load into local namespace to
-	// initialize sql driver mapping in sql.Open("mysql", ...)
 	"github.com/CodeCollaborate/Server/modules/config"
 	"github.com/CodeCollaborate/Server/utils"
+	"github.com/go-sql-driver/mysql" // also initializes the sql driver mapping in sql.Open("mysql", ...)
 )
 
 type mysqlConn struct {
@@ -79,6 +79,15 @@ func (di *DatabaseImpl) CloseMySQL() error {
 	return ErrDbNotInitialized
 }
 
+// MySQLPing checks that the MySQL database can be reached, connecting if needed
+func (di *DatabaseImpl) MySQLPing() error {
+	mysql, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+	return mysql.db.Ping()
+}
+
 /**
 STORED PROCEDURES
 */
@@ -90,7 +99,33 @@ func (di *DatabaseImpl) MySQLUserRegister(user UserMeta) error {
 		return err
 	}
 
-	result, err := mysqlConn.db.Exec("CALL user_register(?,?,?,?,?)", user.Username, user.Password, user.Email, user.FirstName, user.LastName)
+	// users registered through an identity provider may not have an email address
+	email := sql.NullString{String: user.Email, Valid: user.Email != ""}
+	status := user.Status
+	if status == "" {
+		status = UserStatusActive
+	}
+	result, err := mysqlConn.db.Exec("CALL user_register(?,?,?,?,?,?)", user.Username, user.Password, email, user.FirstName, user.LastName, status)
+	if err != nil {
+		return err
+	}
+	numRows, err := result.RowsAffected()
+
+	if err != nil || numRows == 0 {
+		return ErrNoDbChange
+	}
+
+	return nil
+}
+
+// MySQLUserRegisterBot registers a bot account owned by bot.BotOwner, which cannot log in with a password
+func (di *DatabaseImpl) MySQLUserRegisterBot(bot UserMeta) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL user_register_bot(?,?,?,?)", bot.Username, bot.FirstName, bot.LastName, bot.BotOwner)
 	if err != nil {
 		return err
 	}
@@ -176,7 +211,7 @@ func (di *DatabaseImpl) MySQLUserLookup(username string) (user UserMeta, err err
 
 	result := false
 	for rows.Next() {
-		err = rows.Scan(&user.FirstName, &user.LastName, &user.Email, &user.Username)
+		err = rows.Scan(&user.FirstName, &user.LastName, &user.Email, &user.Username, &user.Bot, &user.BotOwner, &user.Status, &user.HiddenFromSearch)
 		if err != nil {
 			return user, err
 		}
@@ -188,6 +223,172 @@ func (di *DatabaseImpl) MySQLUserLookup(username string) (user UserMeta, err err
 	return user, nil
 }
 
+// MySQLUserLookupEmail returns user information about the user with the given email address
+func (di *DatabaseImpl) MySQLUserLookupEmail(email string) (user UserMeta, err error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return user, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL user_lookup_email(?)", email)
+	if err != nil {
+		return user, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return user, ErrNoData
+	}
+	err = rows.Scan(&user.FirstName, &user.LastName, &user.Email, &user.Username, &user.Bot, &user.BotOwner, &user.Status, &user.HiddenFromSearch)
+	return user, err
+}
+
+// MySQLUserUpdateProfile sets the first and last name of the user `username`. Returns ErrNoDbChange if the user
+// does not exist, or the names are unchanged.
+func (di *DatabaseImpl) MySQLUserUpdateProfile(username string, firstName string, lastName string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL user_update_profile(?, ?, ?)", username, firstName, lastName)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLUserSetEmail sets the email address of the user `username`, without verifying it
+func (di *DatabaseImpl) MySQLUserSetEmail(username string, email string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL user_set_email(?, ?)", username, email)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLUserSetHiddenFromSearch sets whether the user `username` is excluded from search results. Returns
+// ErrNoDbChange if the user does not exist, or the setting is unchanged.
+func (di *DatabaseImpl) MySQLUserSetHiddenFromSearch(username string, hidden bool) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL user_set_hidden_from_search(?, ?)", username, hidden)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, so that they are matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// MySQLUserSearch returns the users whose username, names or email address start with, contain, or sound like the
+// query, best matches first. HasAccess is set for the users with permissions on the project with the given
+// projectID.
+func (di *DatabaseImpl) MySQLUserSearch(query string, projectID int64, offset int, limit int) ([]UserSearchResult, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL user_search(?, ?, ?, ?, ?)", query, likeEscaper.Replace(query), projectID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []UserSearchResult{}
+	for rows.Next() {
+		result := UserSearchResult{}
+		err = rows.Scan(&result.Username, &result.FirstName, &result.LastName, &result.Bot, &result.HasAccess)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// MySQLUserRename changes the username of a user, along with every reference to it, and records a tombstone for
+// the old username. Returns ErrNoDbChange if the user does not exist.
+func (di *DatabaseImpl) MySQLUserRename(oldUsername string, newUsername string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	tx, err := mysqlConn.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("CALL user_rename(?, ?)", oldUsername, newUsername)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	renamed := 0
+	for rows.Next() {
+		if err = rows.Scan(&renamed); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+	}
+	rows.Close()
+	if renamed == 0 {
+		tx.Rollback()
+		return ErrNoDbChange
+	}
+
+	return tx.Commit()
+}
+
+// MySQLUsernameTombstoneGet returns the latest rename away from the username. Returns ErrNoData if it has never
+// been renamed.
+func (di *DatabaseImpl) MySQLUsernameTombstoneGet(username string) (UsernameTombstone, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return UsernameTombstone{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL username_tombstone_get(?)", username)
+	if err != nil {
+		return UsernameTombstone{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return UsernameTombstone{}, ErrNoData
+	}
+	tombstone := UsernameTombstone{Username: username}
+	err = rows.Scan(&tombstone.RenamedTo, &tombstone.RenamedDate)
+	return tombstone, err
+}
+
 // MySQLUserProjects returns the projectID, the project name, and the permission level the user `username` has on that project
 func (di *DatabaseImpl) MySQLUserProjects(username string) ([]ProjectMeta, error) {
 	mysqlConn, err := di.getMySQLConn()
@@ -235,7 +436,7 @@ func (di *DatabaseImpl) MySQLProjectCreate(username string, projectName string)
 	return projectID, nil
 }
 
-// MySQLProjectDelete deletes a project from MySQL
+// MySQLProjectDelete permanently deletes a project, and all of its files, from MySQL
 func (di *DatabaseImpl) MySQLProjectDelete(projectID int64, senderID string) error {
 	mysqlConn, err := di.getMySQLConn()
 	if err != nil {
@@ -254,6 +455,88 @@ func (di *DatabaseImpl) MySQLProjectDelete(projectID int64, senderID string) err
 	return nil
 }
 
+// MySQLProjectTrash moves the project owned by `senderID` to the trash, hiding it from its members
+func (di *DatabaseImpl) MySQLProjectTrash(projectID int64, senderID string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL project_trash(?,?)", projectID, senderID)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLProjectRestore moves the project owned by `senderID` out of the trash
+func (di *DatabaseImpl) MySQLProjectRestore(projectID int64, senderID string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL project_restore(?,?)", projectID, senderID)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLUserTrashedProjects returns the projects owned by `username` which are in the trash, most recent first
+func (di *DatabaseImpl) MySQLUserTrashedProjects(username string) ([]TrashedProject, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL user_trashed_projects(?)", username)
+	if err != nil {
+		return nil, err
+	}
+	return scanTrashedProjects(rows)
+}
+
+// MySQLExpiredTrashedProjects returns the projects which were moved to the trash before the given time
+func (di *DatabaseImpl) MySQLExpiredTrashedProjects(deletedBefore time.Time) ([]TrashedProject, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL trash_expired_projects(?)", deletedBefore.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanTrashedProjects(rows)
+}
+
+// scanTrashedProjects reads the rows returned by the procedures which list trashed projects
+func scanTrashedProjects(rows *sql.Rows) ([]TrashedProject, error) {
+	defer rows.Close()
+
+	projects := []TrashedProject{}
+	for rows.Next() {
+		project := TrashedProject{}
+		err := rows.Scan(&project.ProjectID, &project.Name, &project.Owner, &project.DeletedDate)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
 // MySQLProjectGetFiles returns the Files from the project with projectID = projectID
 func (di *DatabaseImpl) MySQLProjectGetFiles(projectID int64) (files []FileMeta, err error) {
 	mysqlConn, err := di.getMySQLConn()
@@ -403,7 +686,10 @@ func (di *DatabaseImpl) MySQLProjectLookup(projectID int64, username string) (na
 		if !hasAccess && perm.PermissionLevel > 0 && perm.Username == username {
 			hasAccess = true
 		}
-		permissions[perm.Username] = perm
+		// users may be granted access both directly and through groups; keep the highest
+		if existing, ok := permissions[perm.Username]; !ok || perm.PermissionLevel > existing.PermissionLevel {
+			permissions[perm.Username] = perm
+		}
 		result = true
 	}
 
@@ -414,48 +700,63 @@ func (di *DatabaseImpl) MySQLProjectLookup(projectID int64, username string) (na
 	return name, permissions, err
 }
 
-// MySQLFileCreate create a new file in MySQL
-func (di *DatabaseImpl) MySQLFileCreate(username string, filename string, relativePath string, projectID int64) (int64, error) {
-	filename = filepath.Clean(filename)
-	if strings.Contains(filename, filePathSeparator) || strings.Contains(filename, "..") {
-		return -1, ErrMaliciousRequest
-	}
-
-	relativePath = filepath.Clean(relativePath)
-	if strings.HasPrefix(relativePath, "..") {
-		return -1, ErrMaliciousRequest
-	}
-
+// MySQLProjectGetRoles returns the custom roles defined for the project with the given projectID
+func (di *DatabaseImpl) MySQLProjectGetRoles(projectID int64) ([]config.Role, error) {
 	mysqlConn, err := di.getMySQLConn()
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
-	rows, err := mysqlConn.db.Query("CALL file_create(?,?,?,?)", username, filename, relativePath, projectID)
+	rows, err := mysqlConn.db.Query("CALL project_get_roles(?)", projectID)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
-	var fileID int64
+	roles := []config.Role{}
 	for rows.Next() {
-		err = rows.Scan(&fileID)
+		role := config.Role{}
+		var capabilities string
+		err = rows.Scan(&role.Level, &role.Name, &capabilities)
 		if err != nil {
-			return -1, ErrNoDbChange
+			return nil, err
 		}
+		role.Capabilities = []string{}
+		if capabilities != "" {
+			role.Capabilities = strings.Split(capabilities, ",")
+		}
+		roles = append(roles, role)
 	}
 
-	return fileID, nil
+	return roles, nil
 }
 
-// MySQLFileDelete deletes a file from the MySQL database
-// this does not delete the actual file
-func (di *DatabaseImpl) MySQLFileDelete(fileID int64) error {
+// MySQLProjectSetRole creates or replaces the custom role with the given level on the project
+func (di *DatabaseImpl) MySQLProjectSetRole(projectID int64, role config.Role) error {
 	mysqlConn, err := di.getMySQLConn()
 	if err != nil {
 		return err
 	}
 
-	result, err := mysqlConn.db.Exec("CALL file_delete(?)", fileID)
+	result, err := mysqlConn.db.Exec("CALL project_set_role(?, ?, ?, ?)", projectID, role.Level, role.Name, strings.Join(role.Capabilities, ","))
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLProjectDeleteRole deletes the custom role with the given level from the project, if no users hold it
+func (di *DatabaseImpl) MySQLProjectDeleteRole(projectID int64, level int8) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL project_delete_role(?, ?)", projectID, level)
 	if err != nil {
 		return err
 	}
@@ -467,6 +768,1854 @@ func (di *DatabaseImpl) MySQLFileDelete(fileID int64) error {
 	return nil
 }
 
+// MySQLProjectGetPathPermissions returns the path permission rules for the project with the given projectID
+func (di *DatabaseImpl) MySQLProjectGetPathPermissions(projectID int64) ([]PathPermission, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL project_get_path_permissions(?)", projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []PathPermission{}
+	for rows.Next() {
+		rule := PathPermission{}
+		err = rows.Scan(&rule.PathPrefix, &rule.Username, &rule.PermissionLevel, &rule.GrantedBy, &rule.GrantedDate)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// MySQLProjectSetPathPermissions replaces the path permission rules for the project with the given projectID.
+// The rules are replaced in a single transaction, so that a partially applied set is never enforced.
+func (di *DatabaseImpl) MySQLProjectSetPathPermissions(projectID int64, rules []PathPermission, grantedByUsername string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	tx, err := mysqlConn.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("CALL project_clear_path_permissions(?)", projectID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, rule := range rules {
+		_, err = tx.Exec("CALL project_add_path_permission(?, ?, ?, ?, ?)",
+			projectID, rule.PathPrefix, rule.Username, rule.PermissionLevel, grantedByUsername)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// MySQLGroupCreate creates a new group owned by `username`, who is added as its first member
+func (di *DatabaseImpl) MySQLGroupCreate(username string, groupName string) (groupID int64, err error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return -1, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL group_create(?,?)", groupName, username)
+	if err != nil {
+		return -1, err
+	}
+	for rows.Next() {
+		err = rows.Scan(&groupID)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	return groupID, nil
+}
+
+// MySQLGroupGetInfo returns the metadata of the group with the given groupID
+func (di *DatabaseImpl) MySQLGroupGetInfo(groupID int64) (GroupMeta, error) {
+	group := GroupMeta{GroupID: groupID}
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return group, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL group_get_info(?)", groupID)
+	if err != nil {
+		return group, err
+	}
+
+	result := false
+	for rows.Next() {
+		err = rows.Scan(&group.Name, &group.Owner, &group.CreationDate)
+		if err != nil {
+			return group, err
+		}
+		result = true
+	}
+	if !result {
+		return group, ErrNoData
+	}
+
+	return group, nil
+}
+
+// MySQLGroupAddMember adds the user to the group with the given groupID
+func (di *DatabaseImpl) MySQLGroupAddMember(groupID int64, username string, addedByUsername string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL group_add_member(?, ?, ?)", groupID, username, addedByUsername)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLGroupRemoveMember removes the user from the group with the given groupID
+func (di *DatabaseImpl) MySQLGroupRemoveMember(groupID int64, username string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL group_remove_member(?, ?)", groupID, username)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLGroupGetMembers returns the usernames of the members of the group with the given groupID
+func (di *DatabaseImpl) MySQLGroupGetMembers(groupID int64) ([]string, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL group_get_members(?)", groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []string{}
+	for rows.Next() {
+		var username string
+		err = rows.Scan(&username)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, username)
+	}
+
+	return members, nil
+}
+
+// MySQLGroupGetProjects returns the IDs of the projects the group with the given groupID has been granted
+func (di *DatabaseImpl) MySQLGroupGetProjects(groupID int64) ([]int64, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL group_get_projects(?)", groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	projectIDs := []int64{}
+	for rows.Next() {
+		var projectID int64
+		err = rows.Scan(&projectID)
+		if err != nil {
+			return nil, err
+		}
+		projectIDs = append(projectIDs, projectID)
+	}
+
+	return projectIDs, nil
+}
+
+// MySQLUserGroups returns the groups the user `username` is a member of
+func (di *DatabaseImpl) MySQLUserGroups(username string) ([]GroupMeta, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL user_groups(?)", username)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []GroupMeta{}
+	for rows.Next() {
+		group := GroupMeta{}
+		err = rows.Scan(&group.GroupID, &group.Name, &group.Owner, &group.CreationDate)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// MySQLProjectGrantGroupPermission gives the members of the group the permission `permissionLevel` on project `projectID`
+func (di *DatabaseImpl) MySQLProjectGrantGroupPermission(projectID int64, groupID int64, permissionLevel int8, grantedByUsername string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL project_grant_group_permissions(?, ?, ?, ?)", projectID, groupID, permissionLevel, grantedByUsername)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLProjectRevokeGroupPermission removes the group's permissions from the project
+func (di *DatabaseImpl) MySQLProjectRevokeGroupPermission(projectID int64, groupID int64, revokedByUsername string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL project_revoke_group_permissions(?, ?)", projectID, groupID)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLInviteCreate creates a pending invite to a project, which expires after the given duration
+func (di *DatabaseImpl) MySQLInviteCreate(invite Invite, expiresIn time.Duration) (inviteID int64, err error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return -1, err
+	}
+
+	username := sql.NullString{String: invite.Username, Valid: invite.Username != ""}
+	email := sql.NullString{String: invite.Email, Valid: invite.Email != ""}
+	rows, err := mysqlConn.db.Query("CALL invite_create(?, ?, ?, ?, ?, ?)",
+		invite.ProjectID, username, email, invite.PermissionLevel, invite.InvitedBy, int64(expiresIn/time.Second))
+	if err != nil {
+		return -1, err
+	}
+	for rows.Next() {
+		err = rows.Scan(&inviteID)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	return inviteID, nil
+}
+
+// scanInvites reads the invites returned by the invite_get and user_invites procedures
+func scanInvites(rows *sql.Rows) ([]Invite, error) {
+	invites := []Invite{}
+	for rows.Next() {
+		invite := Invite{}
+		err := rows.Scan(&invite.InviteID, &invite.ProjectID, &invite.ProjectName, &invite.Username, &invite.Email,
+			&invite.PermissionLevel, &invite.InvitedBy, &invite.CreationDate, &invite.ExpiryDate)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, nil
+}
+
+// MySQLInviteGet returns the invite with the given inviteID, whether or not it has expired
+func (di *DatabaseImpl) MySQLInviteGet(inviteID int64) (Invite, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return Invite{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL invite_get(?)", inviteID)
+	if err != nil {
+		return Invite{}, err
+	}
+
+	invites, err := scanInvites(rows)
+	if err != nil {
+		return Invite{}, err
+	}
+	if len(invites) == 0 {
+		return Invite{}, ErrNoData
+	}
+	return invites[0], nil
+}
+
+// MySQLUserGetInvites returns the unexpired invites for the user `username`
+func (di *DatabaseImpl) MySQLUserGetInvites(username string) ([]Invite, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL user_invites(?)", username)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanInvites(rows)
+}
+
+// MySQLInviteDelete deletes the invite with the given inviteID
+func (di *DatabaseImpl) MySQLInviteDelete(inviteID int64) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL invite_delete(?)", inviteID)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLInviteBindEmail assigns the unexpired invites sent to the email address to the user `username`
+func (di *DatabaseImpl) MySQLInviteBindEmail(email string, username string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	_, err = mysqlConn.db.Exec("CALL invite_bind_email(?, ?)", email, username)
+	return err
+}
+
+// MySQLProjectRequestTransfer records a pending transfer of the project's ownership, replacing any existing one
+func (di *DatabaseImpl) MySQLProjectRequestTransfer(transfer OwnershipTransfer) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	_, err = mysqlConn.db.Exec("CALL project_request_transfer(?, ?, ?, ?)",
+		transfer.ProjectID, transfer.FormerOwner, transfer.NewOwner, transfer.FormerOwnerLevel)
+	return err
+}
+
+// MySQLProjectGetTransfer returns the pending ownership transfer for the project with the given projectID
+func (di *DatabaseImpl) MySQLProjectGetTransfer(projectID int64) (OwnershipTransfer, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return OwnershipTransfer{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL project_get_transfer(?)", projectID)
+	if err != nil {
+		return OwnershipTransfer{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return OwnershipTransfer{}, ErrNoData
+	}
+	transfer := OwnershipTransfer{}
+	err = rows.Scan(&transfer.ProjectID, &transfer.ProjectName, &transfer.FormerOwner, &transfer.NewOwner,
+		&transfer.FormerOwnerLevel, &transfer.RequestedDate)
+	if err != nil {
+		return OwnershipTransfer{}, err
+	}
+	return transfer, nil
+}
+
+// MySQLProjectCancelTransfer deletes the pending ownership transfer for the project with the given projectID
+func (di *DatabaseImpl) MySQLProjectCancelTransfer(projectID int64) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL project_cancel_transfer(?)", projectID)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// mysqlErrDuplicateEntry is the MySQL error number for a unique key violation
+const mysqlErrDuplicateEntry = 1062
+
+// MySQLProjectTransferOwnership applies the pending ownership transfer for the project, renaming it to `newName`.
+// The project's owner and the permissions of both users are updated in a single transaction, so the project always
+// has exactly one owner. Returns ErrNameConflict if the new owner already owns a project with that name.
+func (di *DatabaseImpl) MySQLProjectTransferOwnership(projectID int64, newName string) error {
+	transfer, err := di.MySQLProjectGetTransfer(projectID)
+	if err != nil {
+		return err
+	}
+
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	tx, err := mysqlConn.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("CALL project_set_owner(?, ?, ?, ?)",
+		projectID, transfer.FormerOwner, transfer.NewOwner, newName)
+	if err != nil {
+		tx.Rollback()
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlErrDuplicateEntry {
+			return ErrNameConflict
+		}
+		return err
+	}
+	// the project has changed hands since the transfer was requested
+	if numrows, err := result.RowsAffected(); err != nil || numrows == 0 {
+		tx.Rollback()
+		return ErrNoDbChange
+	}
+
+	if _, err = tx.Exec("CALL project_revoke_permissions(?, ?)", projectID, transfer.NewOwner); err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("CALL project_grant_permissions(?, ?, ?, ?)",
+		projectID, transfer.FormerOwner, transfer.FormerOwnerLevel, transfer.NewOwner)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err = tx.Exec("CALL project_cancel_transfer(?)", projectID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MySQLUserSet2FASecret sets the TOTP secret of the user `username`, replacing any they have not yet confirmed.
+// Returns ErrNoDbChange if they already have two-factor authentication enabled.
+func (di *DatabaseImpl) MySQLUserSet2FASecret(username string, secret string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL two_factor_set_secret(?, ?)", username, secret)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLUserGet2FA returns the two-factor authentication settings of the user `username`.
+// Returns ErrNoData if they have not set a TOTP secret.
+func (di *DatabaseImpl) MySQLUserGet2FA(username string) (TwoFactor, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return TwoFactor{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL two_factor_get(?)", username)
+	if err != nil {
+		return TwoFactor{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return TwoFactor{}, ErrNoData
+	}
+	twoFactor := TwoFactor{}
+	err = rows.Scan(&twoFactor.Secret, &twoFactor.Enabled, &twoFactor.LastUsedStep)
+	return twoFactor, err
+}
+
+// MySQLUserEnable2FA enables two-factor authentication for the user `username`, once they have confirmed their
+// secret with a code for the given time step, and replaces their recovery codes. This is done in a single
+// transaction, so that 2FA is never enabled without recovery codes.
+// Returns ErrNoDbChange if they have no unconfirmed secret.
+func (di *DatabaseImpl) MySQLUserEnable2FA(username string, step int64, recoveryCodeHashes []string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	tx, err := mysqlConn.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("CALL two_factor_enable(?, ?)", username, step)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if numrows, err := result.RowsAffected(); err != nil || numrows == 0 {
+		tx.Rollback()
+		return ErrNoDbChange
+	}
+
+	if _, err = tx.Exec("CALL recovery_codes_clear(?)", username); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err = tx.Exec("CALL recovery_code_add(?, ?)", username, codeHash); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// MySQLUserUse2FAStep records the use of a code for the given time step.
+// Returns ErrNoDbChange if a code for the same or a later step has already been used.
+func (di *DatabaseImpl) MySQLUserUse2FAStep(username string, step int64) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL two_factor_use_step(?, ?)", username, step)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLUserUseRecoveryCode deletes the recovery code with the given hash, so that it cannot be used again.
+// Returns ErrNoDbChange if the user has no such recovery code.
+func (di *DatabaseImpl) MySQLUserUseRecoveryCode(username string, codeHash string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL recovery_code_use(?, ?)", username, codeHash)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLUserDisable2FA deletes the TOTP secret and recovery codes of the user `username`
+func (di *DatabaseImpl) MySQLUserDisable2FA(username string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL two_factor_disable(?)", username)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLEmailVerificationCreate stores the hash of a token verifying the current email address of the pending
+// user `username`, replacing any earlier token. Returns ErrNoDbChange if the user is not pending, or has no email.
+func (di *DatabaseImpl) MySQLEmailVerificationCreate(username string, tokenHash string, expiresIn time.Duration) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL email_verification_create(?, ?, ?)", username, tokenHash, int64(expiresIn/time.Second))
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLEmailChangeCreate stores the hash of a token verifying a new email address for the user `username`,
+// replacing any earlier token. The address is only set once the token is redeemed.
+func (di *DatabaseImpl) MySQLEmailChangeCreate(username string, email string, tokenHash string, expiresIn time.Duration) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL email_change_create(?, ?, ?, ?)", username, email, tokenHash, int64(expiresIn/time.Second))
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLEmailVerificationRedeem sets the email address the token was created for, activates the user, and deletes
+// the token. Returns ErrNoData if the token is unknown or expired.
+func (di *DatabaseImpl) MySQLEmailVerificationRedeem(tokenHash string) (string, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := mysqlConn.db.Begin()
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := tx.Query("CALL email_verification_lookup(?)", tokenHash)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	username, email := "", ""
+	for rows.Next() {
+		if err = rows.Scan(&username, &email); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return "", err
+		}
+	}
+	rows.Close()
+	if username == "" {
+		tx.Rollback()
+		return "", ErrNoData
+	}
+
+	if _, err = tx.Exec("CALL user_set_email(?, ?)", username, email); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	if _, err = tx.Exec("CALL user_set_status(?, ?)", username, UserStatusActive); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	if _, err = tx.Exec("CALL email_verification_delete(?)", username); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	return username, tx.Commit()
+}
+
+// MySQLLoginFailuresGet returns the failed logins counted for the subject, a username or IP address depending on
+// kind. Returns ErrNoData if none have been counted.
+func (di *DatabaseImpl) MySQLLoginFailuresGet(kind string, subject string) (LoginFailures, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return LoginFailures{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL login_failures_get(?, ?)", kind, subject)
+	if err != nil {
+		return LoginFailures{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return LoginFailures{}, ErrNoData
+	}
+	failures := LoginFailures{}
+	lockedUntil := mysql.NullTime{}
+	if err := rows.Scan(&failures.Failures, &failures.LastFailure, &lockedUntil); err != nil {
+		return LoginFailures{}, err
+	}
+	failures.LockedUntil = lockedUntil.Time
+	return failures, nil
+}
+
+// MySQLLoginFailureRecord counts a failed login for the subject, returning the number of consecutive failures.
+// The count restarts if resetAfter has passed since the last failure.
+func (di *DatabaseImpl) MySQLLoginFailureRecord(kind string, subject string, resetAfter time.Duration) (int, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL login_failure_record(?, ?, ?)", kind, subject, int64(resetAfter/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	failures := 0
+	for rows.Next() {
+		if err := rows.Scan(&failures); err != nil {
+			return 0, err
+		}
+	}
+	return failures, nil
+}
+
+// MySQLLoginFailuresLock blocks logins for the subject for the given duration, and resets its failure count
+func (di *DatabaseImpl) MySQLLoginFailuresLock(kind string, subject string, lockFor time.Duration) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL login_failures_lock(?, ?, ?)", kind, subject, int64(lockFor/time.Second))
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLLoginFailuresClear forgets the failed logins and any lock of the subject. Returns ErrNoDbChange if there
+// were none.
+func (di *DatabaseImpl) MySQLLoginFailuresClear(kind string, subject string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL login_failures_clear(?, ?)", kind, subject)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLNotificationInsert stores a notification in the inbox of the user `username`
+func (di *DatabaseImpl) MySQLNotificationInsert(username string, notification Notification) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	_, err = mysqlConn.db.Exec("CALL notification_insert(?, ?, ?, ?, ?)", username, notification.Resource,
+		notification.Method, notification.ResourceID, string(notification.Data))
+	return err
+}
+
+// MySQLNotificationsGet returns the notifications in the inbox of the user `username`, newest first
+func (di *DatabaseImpl) MySQLNotificationsGet(username string, unreadOnly bool, offset int, limit int) ([]Notification, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL notification_get(?, ?, ?, ?)", username, unreadOnly, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := []Notification{}
+	for rows.Next() {
+		notification := Notification{}
+		var data []byte
+		err = rows.Scan(&notification.NotificationID, &notification.Resource, &notification.Method,
+			&notification.ResourceID, &data, &notification.CreationDate, &notification.Read)
+		if err != nil {
+			return nil, err
+		}
+		notification.Data = json.RawMessage(data)
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+// MySQLNotificationsCountUnread returns the number of unread notifications in the inbox of the user `username`
+func (di *DatabaseImpl) MySQLNotificationsCountUnread(username string) (int, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var unread int
+	err = mysqlConn.db.QueryRow("CALL notification_count_unread(?)", username).Scan(&unread)
+	return unread, err
+}
+
+// MySQLNotificationsMarkRead marks the notifications with the given IDs in the inbox of the user `username` as
+// read, or all of them if no IDs are given. Returns the number of notifications which were unread.
+func (di *DatabaseImpl) MySQLNotificationsMarkRead(username string, notificationIDs []int64) (int, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(notificationIDs) == 0 {
+		// the procedure marks every notification when given no ID
+		notificationIDs = []int64{0}
+	}
+	marked := 0
+	for _, notificationID := range notificationIDs {
+		result, err := mysqlConn.db.Exec("CALL notification_mark_read(?, ?)", username, notificationID)
+		if err != nil {
+			return marked, err
+		}
+		numrows, err := result.RowsAffected()
+		if err != nil {
+			return marked, err
+		}
+		marked += int(numrows)
+	}
+	return marked, nil
+}
+
+// MySQLCommentThreadCreate starts a thread anchored to thread.FileID, with body as its first comment
+func (di *DatabaseImpl) MySQLCommentThreadCreate(thread CommentThread, body string) (int64, int64, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return -1, -1, err
+	}
+
+	var threadID, commentID int64
+	err = mysqlConn.db.QueryRow("CALL comment_thread_create(?, ?, ?, ?, ?, ?)", thread.FileID, thread.StartIndex,
+		thread.EndIndex, thread.FileVersion, thread.CreatedBy, body).Scan(&threadID, &commentID)
+	if err != nil {
+		return -1, -1, err
+	}
+	return threadID, commentID, nil
+}
+
+// MySQLCommentReply adds a comment to the thread with the given threadID
+func (di *DatabaseImpl) MySQLCommentReply(threadID int64, author string, body string) (int64, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return -1, err
+	}
+
+	var commentID int64
+	err = mysqlConn.db.QueryRow("CALL comment_reply(?, ?, ?)", threadID, author, body).Scan(&commentID)
+	if err != nil {
+		return -1, err
+	}
+	return commentID, nil
+}
+
+// MySQLCommentThreadResolve resolves or reopens the thread with the given threadID.
+// Returns ErrNoDbChange if the thread does not exist, or is already in that state.
+func (di *DatabaseImpl) MySQLCommentThreadResolve(threadID int64, resolved bool, username string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL comment_thread_resolve(?, ?, ?)", threadID, resolved, username)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLCommentThreadGet returns the thread with the given threadID, without its comments.
+// Returns ErrNoData if the thread does not exist.
+func (di *DatabaseImpl) MySQLCommentThreadGet(threadID int64) (CommentThread, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return CommentThread{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL comment_thread_get(?)", threadID)
+	if err != nil {
+		return CommentThread{}, err
+	}
+
+	threads, err := scanCommentThreads(rows)
+	if err != nil {
+		return CommentThread{}, err
+	}
+	if len(threads) == 0 {
+		return CommentThread{}, ErrNoData
+	}
+	return threads[0], nil
+}
+
+// MySQLCommentThreadsGet returns the threads of the file with the given fileID, without their comments
+func (di *DatabaseImpl) MySQLCommentThreadsGet(fileID int64, includeResolved bool) ([]CommentThread, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL comment_thread_get_file(?, ?)", fileID, includeResolved)
+	if err != nil {
+		return nil, err
+	}
+	return scanCommentThreads(rows)
+}
+
+// scanCommentThreads reads the threads returned by the comment_thread_get and comment_thread_get_file procedures
+func scanCommentThreads(rows *sql.Rows) ([]CommentThread, error) {
+	threads := []CommentThread{}
+	for rows.Next() {
+		thread := CommentThread{}
+		err := rows.Scan(&thread.ThreadID, &thread.FileID, &thread.StartIndex, &thread.EndIndex, &thread.FileVersion,
+			&thread.Orphaned, &thread.Resolved, &thread.ResolvedBy, &thread.CreatedBy, &thread.CreationDate)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, thread)
+	}
+	return threads, nil
+}
+
+// MySQLCommentsGet returns the comments of all threads of the file with the given fileID, oldest first
+func (di *DatabaseImpl) MySQLCommentsGet(fileID int64) ([]Comment, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL comment_get_file(?)", fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := []Comment{}
+	for rows.Next() {
+		comment := Comment{}
+		err = rows.Scan(&comment.CommentID, &comment.ThreadID, &comment.Author, &comment.Body, &comment.CreationDate)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, nil
+}
+
+// MySQLCommentAnchorUpdate moves the anchor of the thread with the given threadID, if it is still at fromVersion.
+// Returns ErrNoDbChange if it has already been moved.
+func (di *DatabaseImpl) MySQLCommentAnchorUpdate(threadID int64, fromVersion int64, anchor CommentAnchor) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL comment_anchor_update(?, ?, ?, ?, ?, ?)", threadID, fromVersion,
+		anchor.StartIndex, anchor.EndIndex, anchor.FileVersion, anchor.Orphaned)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLSuggestionCreate stores the suggestion as pending review
+func (di *DatabaseImpl) MySQLSuggestionCreate(suggestion Suggestion) (int64, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return -1, err
+	}
+
+	var suggestionID int64
+	err = mysqlConn.db.QueryRow("CALL suggestion_create(?, ?, ?, ?)", suggestion.FileID, suggestion.Author,
+		suggestion.Patch, suggestion.FileVersion).Scan(&suggestionID)
+	if err != nil {
+		return -1, err
+	}
+	return suggestionID, nil
+}
+
+// MySQLSuggestionGet returns the suggestion with the given suggestionID.
+// Returns ErrNoData if the suggestion does not exist.
+func (di *DatabaseImpl) MySQLSuggestionGet(suggestionID int64) (Suggestion, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return Suggestion{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL suggestion_get(?)", suggestionID)
+	if err != nil {
+		return Suggestion{}, err
+	}
+
+	suggestions, err := scanSuggestions(rows)
+	if err != nil {
+		return Suggestion{}, err
+	}
+	if len(suggestions) == 0 {
+		return Suggestion{}, ErrNoData
+	}
+	return suggestions[0], nil
+}
+
+// MySQLSuggestionsGet returns the pending suggestions for the file with the given fileID, oldest first
+func (di *DatabaseImpl) MySQLSuggestionsGet(fileID int64) ([]Suggestion, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL suggestion_get_file(?)", fileID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSuggestions(rows)
+}
+
+// scanSuggestions reads the suggestions returned by the suggestion_get and suggestion_get_file procedures
+func scanSuggestions(rows *sql.Rows) ([]Suggestion, error) {
+	suggestions := []Suggestion{}
+	for rows.Next() {
+		suggestion := Suggestion{}
+		err := rows.Scan(&suggestion.SuggestionID, &suggestion.FileID, &suggestion.Author, &suggestion.Patch,
+			&suggestion.FileVersion, &suggestion.CreationDate)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
+
+// MySQLSuggestionRebase replaces the patch of the suggestion with the given suggestionID, if it is still based on
+// fromVersion. Returns ErrNoDbChange if it has already been rebased.
+func (di *DatabaseImpl) MySQLSuggestionRebase(suggestionID int64, fromVersion int64, patch string, version int64) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL suggestion_rebase(?, ?, ?, ?)", suggestionID, fromVersion, patch, version)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLSuggestionDelete removes the suggestion with the given suggestionID once it has been accepted or rejected.
+// Returns ErrNoDbChange if the suggestion does not exist.
+func (di *DatabaseImpl) MySQLSuggestionDelete(suggestionID int64) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL suggestion_delete(?)", suggestionID)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLFileLockAcquire locks the file with the given lock.FileID for lock.Username, unless another user holds an
+// unexpired lock on it; locks already held by lock.Username are renewed. Returns the lock held on the file
+// afterwards, which is the other user's if the file could not be locked.
+func (di *DatabaseImpl) MySQLFileLockAcquire(lock FileLock, ttl time.Duration) (FileLock, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return FileLock{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL file_lock_acquire(?, ?, ?, ?, ?)", lock.FileID, lock.Username, lock.Socket,
+		lock.Enforced, int64(ttl/time.Second))
+	if err != nil {
+		return FileLock{}, err
+	}
+
+	locks, err := scanFileLocks(rows)
+	if err != nil {
+		return FileLock{}, err
+	}
+	if len(locks) == 0 {
+		return FileLock{}, ErrNoData
+	}
+	return locks[0], nil
+}
+
+// MySQLFileLockGet returns the unexpired lock on the file with the given fileID.
+// Returns ErrNoData if the file is not locked.
+func (di *DatabaseImpl) MySQLFileLockGet(fileID int64) (FileLock, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return FileLock{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL file_lock_get(?)", fileID)
+	if err != nil {
+		return FileLock{}, err
+	}
+
+	locks, err := scanFileLocks(rows)
+	if err != nil {
+		return FileLock{}, err
+	}
+	if len(locks) == 0 {
+		return FileLock{}, ErrNoData
+	}
+	return locks[0], nil
+}
+
+// MySQLFileLockRelease releases the lock on the file with the given fileID, if it is held by `username` or force is
+// set. Returns ErrNoDbChange if there was no such lock.
+func (di *DatabaseImpl) MySQLFileLockRelease(fileID int64, username string, force bool) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL file_lock_release(?, ?, ?)", fileID, username, force)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLFileLocksReleaseSocket releases all locks held by the websocket with the given queue name, returning the
+// unexpired locks which were released
+func (di *DatabaseImpl) MySQLFileLocksReleaseSocket(socket string) ([]FileLock, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL file_lock_release_socket(?)", socket)
+	if err != nil {
+		return nil, err
+	}
+	return scanFileLocks(rows)
+}
+
+// scanFileLocks reads the locks returned by the file_lock procedures
+func scanFileLocks(rows *sql.Rows) ([]FileLock, error) {
+	defer rows.Close()
+
+	locks := []FileLock{}
+	for rows.Next() {
+		lock := FileLock{}
+		err := rows.Scan(&lock.FileID, &lock.Username, &lock.Socket, &lock.Enforced, &lock.ExpiryDate)
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+// MySQLWebhookCreate registers a webhook for the project with the given webhook.ProjectID
+func (di *DatabaseImpl) MySQLWebhookCreate(webhook Webhook) (int64, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return -1, err
+	}
+
+	var webhookID int64
+	err = mysqlConn.db.QueryRow("CALL webhook_create(?, ?, ?, ?, ?)", webhook.ProjectID, webhook.URL, webhook.Secret,
+		strings.Join(webhook.Events, " "), webhook.CreatedBy).Scan(&webhookID)
+	if err != nil {
+		return -1, err
+	}
+	return webhookID, nil
+}
+
+// MySQLWebhooksGet returns the webhooks of the project with the given projectID, including their secrets
+func (di *DatabaseImpl) MySQLWebhooksGet(projectID int64) ([]Webhook, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL webhook_get_project(?)", projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook := Webhook{ProjectID: projectID}
+		var events string
+		err = rows.Scan(&webhook.WebhookID, &webhook.URL, &webhook.Secret, &events, &webhook.CreatedBy, &webhook.CreationDate)
+		if err != nil {
+			return nil, err
+		}
+		webhook.Events = strings.Fields(events)
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+// MySQLWebhookDelete deletes the webhook with the given webhookID from the project with the given projectID.
+// Returns ErrNoDbChange if the project has no such webhook.
+func (di *DatabaseImpl) MySQLWebhookDelete(projectID int64, webhookID int64) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL webhook_delete(?, ?)", projectID, webhookID)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLWebhookProjectIDs returns the IDs of the projects with webhooks
+func (di *DatabaseImpl) MySQLWebhookProjectIDs() ([]int64, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL webhook_project_ids()")
+	if err != nil {
+		return nil, err
+	}
+
+	projectIDs := []int64{}
+	for rows.Next() {
+		var projectID int64
+		if err = rows.Scan(&projectID); err != nil {
+			return nil, err
+		}
+		projectIDs = append(projectIDs, projectID)
+	}
+
+	return projectIDs, nil
+}
+
+// MySQLWebhookDeliveryInsert records the outcome of a delivery. Only the latest WebhookDeliveriesKept deliveries of
+// each webhook are kept.
+func (di *DatabaseImpl) MySQLWebhookDeliveryInsert(delivery WebhookDelivery) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	_, err = mysqlConn.db.Exec("CALL webhook_delivery_insert(?, ?, ?, ?, ?, ?, ?)", delivery.WebhookID, delivery.Event,
+		delivery.Attempts, delivery.StatusCode, delivery.Error, delivery.Success, WebhookDeliveriesKept)
+	return err
+}
+
+// MySQLWebhookDeliveriesGet returns the latest deliveries to the webhook with the given webhookID, newest first
+func (di *DatabaseImpl) MySQLWebhookDeliveriesGet(webhookID int64, limit int) ([]WebhookDelivery, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL webhook_delivery_get(?, ?)", webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery := WebhookDelivery{WebhookID: webhookID}
+		err = rows.Scan(&delivery.DeliveryID, &delivery.Event, &delivery.Attempts, &delivery.StatusCode, &delivery.Error,
+			&delivery.Success, &delivery.CompletionDate)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// MySQLExternalIdentityLookup returns the user linked to the given subject of an identity provider.
+// Returns ErrNoData if the identity is not linked to any user.
+func (di *DatabaseImpl) MySQLExternalIdentityLookup(provider string, subject string) (username string, err error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL external_identity_lookup(?, ?)", provider, subject)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", ErrNoData
+	}
+	err = rows.Scan(&username)
+	return username, err
+}
+
+// MySQLExternalIdentityLink links the given subject of an identity provider to the user `username`
+func (di *DatabaseImpl) MySQLExternalIdentityLink(provider string, subject string, username string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL external_identity_link(?, ?, ?)", provider, subject, username)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLShareLinkCreate creates a share link to a project, which expires after the given duration
+func (di *DatabaseImpl) MySQLShareLinkCreate(link ShareLink, tokenHash string, expiresIn time.Duration) (shareLinkID int64, err error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return -1, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL share_link_create(?, ?, ?, ?, ?)",
+		link.ProjectID, tokenHash, link.CreatedBy, int64(expiresIn/time.Second), link.MaxUses)
+	if err != nil {
+		return -1, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		err = rows.Scan(&shareLinkID)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	return shareLinkID, nil
+}
+
+// scanShareLinks reads the share links returned by the share_link_get and project_share_links procedures
+func scanShareLinks(rows *sql.Rows) ([]ShareLink, error) {
+	defer rows.Close()
+
+	links := []ShareLink{}
+	for rows.Next() {
+		link := ShareLink{}
+		err := rows.Scan(&link.ShareLinkID, &link.ProjectID, &link.ProjectName, &link.CreatedBy,
+			&link.CreationDate, &link.ExpiryDate, &link.MaxUses, &link.Uses)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// MySQLShareLinkGet returns the share link with the given shareLinkID, whether or not it has expired.
+// Links to projects in the trash are not returned.
+func (di *DatabaseImpl) MySQLShareLinkGet(shareLinkID int64) (ShareLink, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return ShareLink{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL share_link_get(?)", shareLinkID)
+	if err != nil {
+		return ShareLink{}, err
+	}
+
+	links, err := scanShareLinks(rows)
+	if err != nil {
+		return ShareLink{}, err
+	}
+	if len(links) == 0 {
+		return ShareLink{}, ErrNoData
+	}
+	return links[0], nil
+}
+
+// MySQLShareLinkRedeem counts a use of the share link with the given token hash, returning its shareLinkID.
+// Returns ErrNoData if there is no such link, or if it has expired or been used up.
+func (di *DatabaseImpl) MySQLShareLinkRedeem(tokenHash string) (shareLinkID int64, err error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return -1, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL share_link_redeem(?)", tokenHash)
+	if err != nil {
+		return -1, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return -1, ErrNoData
+	}
+	err = rows.Scan(&shareLinkID)
+	if err != nil {
+		return -1, err
+	}
+
+	return shareLinkID, nil
+}
+
+// MySQLShareLinkDelete deletes the share link with the given shareLinkID
+func (di *DatabaseImpl) MySQLShareLinkDelete(shareLinkID int64) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL share_link_delete(?)", shareLinkID)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLProjectGetShareLinks returns the share links to the project with the given projectID, newest first
+func (di *DatabaseImpl) MySQLProjectGetShareLinks(projectID int64) ([]ShareLink, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL project_share_links(?)", projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanShareLinks(rows)
+}
+
+// MySQLAccessTokenCreate creates an access token for token.Username, which expires after the given duration,
+// or never if it is 0
+func (di *DatabaseImpl) MySQLAccessTokenCreate(token AccessToken, tokenHash string, expiresIn time.Duration) (tokenID int64, err error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return -1, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL access_token_create(?, ?, ?, ?, ?)",
+		token.Username, token.Name, tokenHash, strings.Join(token.Scopes, " "), int64(expiresIn/time.Second))
+	if err != nil {
+		return -1, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		err = rows.Scan(&tokenID)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	return tokenID, nil
+}
+
+// scanAccessTokens reads the access tokens returned by the access_token_get, access_token_authenticate and
+// user_access_tokens procedures
+func scanAccessTokens(rows *sql.Rows) ([]AccessToken, error) {
+	defer rows.Close()
+
+	tokens := []AccessToken{}
+	for rows.Next() {
+		token := AccessToken{}
+		scopes := ""
+		expiryDate := mysql.NullTime{}
+		err := rows.Scan(&token.TokenID, &token.Username, &token.Name, &scopes, &token.CreationDate, &expiryDate)
+		if err != nil {
+			return nil, err
+		}
+		token.Scopes = strings.Fields(scopes)
+		token.ExpiryDate = expiryDate.Time
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// MySQLAccessTokenGet returns the access token with the given tokenID, whether or not it has expired.
+// Returns ErrNoData if there is no such token.
+func (di *DatabaseImpl) MySQLAccessTokenGet(tokenID int64) (AccessToken, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL access_token_get(?)", tokenID)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	tokens, err := scanAccessTokens(rows)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	if len(tokens) == 0 {
+		return AccessToken{}, ErrNoData
+	}
+	return tokens[0], nil
+}
+
+// MySQLAccessTokenAuthenticate returns the unexpired access token with the given token hash.
+// Returns ErrNoData if there is no such token.
+func (di *DatabaseImpl) MySQLAccessTokenAuthenticate(tokenHash string) (AccessToken, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL access_token_authenticate(?)", tokenHash)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	tokens, err := scanAccessTokens(rows)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	if len(tokens) == 0 {
+		return AccessToken{}, ErrNoData
+	}
+	return tokens[0], nil
+}
+
+// MySQLAccessTokenDelete deletes the access token with the given tokenID
+func (di *DatabaseImpl) MySQLAccessTokenDelete(tokenID int64) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL access_token_delete(?)", tokenID)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLUserGetAccessTokens returns the access tokens of the given user, newest first
+func (di *DatabaseImpl) MySQLUserGetAccessTokens(username string) ([]AccessToken, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL user_access_tokens(?)", username)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAccessTokens(rows)
+}
+
+// MySQLAuditLogInsert appends an entry to the audit log
+func (di *DatabaseImpl) MySQLAuditLogInsert(entry AuditEntry) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	projectID := sql.NullInt64{Int64: entry.ProjectID, Valid: entry.ProjectID != 0}
+	result, err := mysqlConn.db.Exec("CALL audit_log_insert(?, ?, ?, ?, ?, ?)",
+		entry.Actor, entry.Action, projectID, entry.Target, entry.Detail, entry.SourceIP)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLProjectGetAuditLog returns the audit log entries for the project with the given projectID, newest first
+func (di *DatabaseImpl) MySQLProjectGetAuditLog(projectID int64, filter AuditFilter) ([]AuditEntry, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var since, until interface{}
+	if !filter.Since.IsZero() {
+		since = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		until = filter.Until
+	}
+
+	rows, err := mysqlConn.db.Query("CALL project_get_audit_log(?, ?, ?, ?, ?, ?, ?)",
+		projectID, filter.Action, filter.Actor, since, until, filter.Offset, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		entry := AuditEntry{ProjectID: projectID}
+		err = rows.Scan(&entry.AuditID, &entry.Timestamp, &entry.Actor, &entry.Action, &entry.Target, &entry.Detail, &entry.SourceIP)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// MySQLFileCreate create a new file in MySQL
+func (di *DatabaseImpl) MySQLFileCreate(username string, filename string, relativePath string, projectID int64) (int64, error) {
+	filename = filepath.Clean(filename)
+	if strings.Contains(filename, filePathSeparator) || strings.Contains(filename, "..") {
+		return -1, ErrMaliciousRequest
+	}
+
+	relativePath = filepath.Clean(relativePath)
+	if strings.HasPrefix(relativePath, "..") {
+		return -1, ErrMaliciousRequest
+	}
+
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return -1, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL file_create(?,?,?,?)", username, filename, relativePath, projectID)
+	if err != nil {
+		return -1, err
+	}
+
+	var fileID int64
+	for rows.Next() {
+		err = rows.Scan(&fileID)
+		if err != nil {
+			return -1, ErrNoDbChange
+		}
+	}
+
+	return fileID, nil
+}
+
+// MySQLFileDelete permanently deletes a file from the MySQL database
+// this does not delete the actual file
+func (di *DatabaseImpl) MySQLFileDelete(fileID int64) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL file_delete(?)", fileID)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLFileTrash moves a file to the trash, hiding it from its project
+func (di *DatabaseImpl) MySQLFileTrash(fileID int64, deletedBy string) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL file_trash(?, ?)", fileID, deletedBy)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLFileRestore moves a file out of the trash
+func (di *DatabaseImpl) MySQLFileRestore(fileID int64) error {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return err
+	}
+
+	result, err := mysqlConn.db.Exec("CALL file_restore(?)", fileID)
+	if err != nil {
+		return err
+	}
+	numrows, err := result.RowsAffected()
+
+	if err != nil || numrows == 0 {
+		return ErrNoDbChange
+	}
+	return nil
+}
+
+// MySQLFileGetTrashedInfo returns the meta data about the given file, if it is in the trash
+func (di *DatabaseImpl) MySQLFileGetTrashedInfo(fileID int64) (TrashedFile, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return TrashedFile{}, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL file_get_trashed_info(?)", fileID)
+	if err != nil {
+		return TrashedFile{}, err
+	}
+
+	files, err := scanTrashedFiles(rows)
+	if err != nil {
+		return TrashedFile{}, err
+	}
+	if len(files) == 0 {
+		return TrashedFile{}, ErrNoData
+	}
+	return files[0], nil
+}
+
+// MySQLProjectGetTrashedFiles returns the files from the project which are in the trash, most recent first
+func (di *DatabaseImpl) MySQLProjectGetTrashedFiles(projectID int64) ([]TrashedFile, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL project_get_trashed_files(?)", projectID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTrashedFiles(rows)
+}
+
+// MySQLExpiredTrashedFiles returns the files which were moved to the trash before the given time, excluding
+// those in projects which are themselves in the trash
+func (di *DatabaseImpl) MySQLExpiredTrashedFiles(deletedBefore time.Time) ([]TrashedFile, error) {
+	mysqlConn, err := di.getMySQLConn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mysqlConn.db.Query("CALL trash_expired_files(?)", deletedBefore.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanTrashedFiles(rows)
+}
+
+// scanTrashedFiles reads the rows returned by the procedures which list trashed files
+func scanTrashedFiles(rows *sql.Rows) ([]TrashedFile, error) {
+	defer rows.Close()
+
+	files := []TrashedFile{}
+	for rows.Next() {
+		file := TrashedFile{}
+		err := rows.Scan(&file.FileID, &file.Creator, &file.CreationDate, &file.RelativePath, &file.ProjectID,
+			&file.Filename, &file.DeletedBy, &file.DeletedDate)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
 // MySQLFileMove updates MySQL with the  new path of the file with FileID == 'fileID'
 func (di *DatabaseImpl) MySQLFileMove(fileID int64, newPath string) error {
 	newPathClean := filepath.Clean(newPath)
@@ -1,6 +1,7 @@
 package dbfs
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -514,7 +515,7 @@ func TestDatabaseImpl_MySQLProjectRename(t *testing.T) {
 	}
 }
 
-func TestDatabaseImpl_MySQLFileCreate(t *testing.T) {
+func TestDatabaseImpl_MySQLProjectRoles(t *testing.T) {
 	testConfigSetup(t)
 	di := new(DatabaseImpl)
 
@@ -522,31 +523,40 @@ func TestDatabaseImpl_MySQLFileCreate(t *testing.T) {
 	if erro != nil {
 		t.Fatal(erro)
 	}
-	filename := "file-y"
+	erro = di.MySQLUserRegister(userTwo)
+	if erro != nil {
+		t.Fatal(erro)
+	}
 
-	projectID, _ := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
-	fileID, err := di.MySQLFileCreate(userOne.Username, filename, ".", projectID)
+	projectID, err := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	files, _ := di.MySQLProjectGetFiles(projectID)
+	reviewer := config.Role{Name: "reviewer", Level: 20, Capabilities: []string{config.CapabilityRead, config.CapabilityComment}}
+	assert.NoError(t, di.MySQLProjectSetRole(projectID, reviewer))
 
-	defer di.MySQLUserDelete(userOne.Username)
-	defer di.MySQLProjectDelete(projectID, userOne.Username)
+	roles, err := di.MySQLProjectGetRoles(projectID)
+	assert.NoError(t, err)
+	assert.Equal(t, []config.Role{reviewer}, roles)
 
-	assert.NoError(t, err, "mysql error")
-	assert.Equal(t, 1, len(files), "Project incorrect file count")
-	assert.Equal(t, fileID, files[0].FileID, "incorrect fileID")
-	assert.Equal(t, userOne.Username, files[0].Creator, "incorrect creator")
-	assert.Equal(t, ".", files[0].RelativePath, "incorrect relative path")
-	assert.Equal(t, filename, files[0].Filename, "incorrect filename")
-	assert.Equal(t, projectID, files[0].ProjectID, "incorrect projectID")
+	// roles held by users cannot be deleted
+	assert.NoError(t, di.MySQLProjectGrantPermission(projectID, userTwo.Username, reviewer.Level, userOne.Username))
+	assert.Equal(t, ErrNoDbChange, di.MySQLProjectDeleteRole(projectID, reviewer.Level))
 
-	// should fail b/c location is already in use
-	fileIDNew, err := di.MySQLFileCreate(userOne.Username, filename, ".", projectID)
-	assert.EqualValues(t, -1, fileIDNew, "Expected invalid FileID to be returned")
-	assert.Error(t, err, "expected duplicate insertion to fail")
+	assert.NoError(t, di.MySQLProjectRevokePermission(projectID, userTwo.Username, userOne.Username))
+	assert.NoError(t, di.MySQLProjectDeleteRole(projectID, reviewer.Level))
+
+	roles, err = di.MySQLProjectGetRoles(projectID)
+	assert.NoError(t, err)
+	assert.Empty(t, roles)
+
+	_ = di.MySQLProjectDelete(projectID, userOne.Username)
+	_, _ = di.MySQLUserDelete(userOne.Username)
+	_, _ = di.MySQLUserDelete(userTwo.Username)
 }
 
-func TestDatabaseImpl_MySQLFileDelete(t *testing.T) {
+func TestDatabaseImpl_MySQLProjectPathPermissions(t *testing.T) {
 	testConfigSetup(t)
 	di := new(DatabaseImpl)
 
@@ -554,24 +564,121 @@ func TestDatabaseImpl_MySQLFileDelete(t *testing.T) {
 	if erro != nil {
 		t.Fatal(erro)
 	}
+	erro = di.MySQLUserRegister(userTwo)
+	if erro != nil {
+		t.Fatal(erro)
+	}
 
-	projectID, _ := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
-	fileID, _ := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
-	err := di.MySQLFileDelete(fileID)
+	projectID, err := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []PathPermission{
+		{PathPrefix: "frontend", Username: userTwo.Username, PermissionLevel: config.PermissionsByLabel["write"]},
+		{PathPrefix: "backend", Username: userTwo.Username, PermissionLevel: 0},
+	}
+	assert.NoError(t, di.MySQLProjectSetPathPermissions(projectID, rules, userOne.Username))
+
+	stored, err := di.MySQLProjectGetPathPermissions(projectID)
+	assert.NoError(t, err)
+	if assert.Len(t, stored, 2) {
+		// rules are ordered by prefix
+		assert.Equal(t, "backend", stored[0].PathPrefix)
+		assert.Equal(t, int8(0), stored[0].PermissionLevel)
+		assert.Equal(t, "frontend", stored[1].PathPrefix)
+		assert.Equal(t, userOne.Username, stored[1].GrantedBy)
+	}
+
+	// setting the rules replaces the existing ones
+	assert.NoError(t, di.MySQLProjectSetPathPermissions(projectID, rules[:1], userOne.Username))
+	stored, err = di.MySQLProjectGetPathPermissions(projectID)
+	assert.NoError(t, err)
+	assert.Len(t, stored, 1)
+
+	assert.NoError(t, di.MySQLProjectSetPathPermissions(projectID, []PathPermission{}, userOne.Username))
+	stored, err = di.MySQLProjectGetPathPermissions(projectID)
+	assert.NoError(t, err)
+	assert.Empty(t, stored)
 
-	files, _ := di.MySQLProjectGetFiles(projectID)
 	_ = di.MySQLProjectDelete(projectID, userOne.Username)
-	di.MySQLUserDelete(userOne.Username)
+	_, _ = di.MySQLUserDelete(userOne.Username)
+	_, _ = di.MySQLUserDelete(userTwo.Username)
+}
+
+func TestDatabaseImpl_MySQLGroups(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	erro = di.MySQLUserRegister(userTwo)
+	if erro != nil {
+		t.Fatal(erro)
+	}
 
+	projectID, err := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(files) != 0 {
-		t.Fatalf("Project %v returned not the correct length, expected: 0, actual: %v", projectID, len(files))
+
+	groupID, err := di.MySQLGroupCreate(userOne.Username, "backend team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = di.MySQLGroupCreate(userOne.Username, "backend team")
+	assert.Error(t, err, "group names should be unique for each owner")
+
+	group, err := di.MySQLGroupGetInfo(groupID)
+	assert.NoError(t, err)
+	assert.Equal(t, "backend team", group.Name)
+	assert.Equal(t, userOne.Username, group.Owner)
+
+	assert.NoError(t, di.MySQLGroupAddMember(groupID, userTwo.Username, userOne.Username))
+	members, err := di.MySQLGroupGetMembers(groupID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{userOne.Username, userTwo.Username}, members)
+
+	// direct and group grants combine to the highest level
+	assert.NoError(t, di.MySQLProjectGrantPermission(projectID, userTwo.Username, config.PermissionsByLabel["read"], userOne.Username))
+	assert.NoError(t, di.MySQLProjectGrantGroupPermission(projectID, groupID, config.PermissionsByLabel["write"], userOne.Username))
+	level, err := di.MySQLUserProjectPermissionLookup(projectID, userTwo.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["write"], level)
+	level, err = di.MySQLUserProjectPermissionLookup(projectID, userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, config.OwnerLevel, level)
+
+	projects, err := di.MySQLUserProjects(userTwo.Username)
+	assert.NoError(t, err)
+	if assert.Len(t, projects, 1) {
+		assert.Equal(t, config.PermissionsByLabel["write"], projects[0].PermissionLevel)
 	}
+
+	projectIDs, err := di.MySQLGroupGetProjects(groupID)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{projectID}, projectIDs)
+
+	groups, err := di.MySQLUserGroups(userTwo.Username)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 1)
+
+	assert.NoError(t, di.MySQLProjectRevokePermission(projectID, userTwo.Username, userOne.Username))
+	assert.NoError(t, di.MySQLGroupRemoveMember(groupID, userTwo.Username))
+	_, err = di.MySQLUserProjectPermissionLookup(projectID, userTwo.Username)
+	assert.Equal(t, ErrNoData, err)
+
+	assert.NoError(t, di.MySQLProjectRevokeGroupPermission(projectID, groupID, userOne.Username))
+	assert.Equal(t, ErrNoDbChange, di.MySQLProjectRevokeGroupPermission(projectID, groupID, userOne.Username))
+
+	_ = di.MySQLProjectDelete(projectID, userOne.Username)
+	_, _ = di.MySQLUserDelete(userOne.Username)
+	_, _ = di.MySQLUserDelete(userTwo.Username)
 }
 
-func TestDatabaseImpl_MySQLFileMove(t *testing.T) {
+func TestDatabaseImpl_MySQLInvites(t *testing.T) {
 	testConfigSetup(t)
 	di := new(DatabaseImpl)
 
@@ -580,27 +687,114 @@ func TestDatabaseImpl_MySQLFileMove(t *testing.T) {
 		t.Fatal(erro)
 	}
 
-	projectID, _ := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
-	fileID, _ := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+	projectID, err := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	err := di.MySQLFileMove(fileID, "cc")
+	inviteID, err := di.MySQLInviteCreate(Invite{
+		ProjectID:       projectID,
+		Email:           userTwo.Email,
+		PermissionLevel: config.PermissionsByLabel["write"],
+		InvitedBy:       userOne.Username,
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invite, err := di.MySQLInviteGet(inviteID)
+	assert.NoError(t, err)
+	assert.Equal(t, "codecollabcore", invite.ProjectName)
+	assert.Equal(t, "", invite.Username)
+	assert.Equal(t, userTwo.Email, invite.Email)
+	assert.WithinDuration(t, invite.CreationDate.Add(time.Hour), invite.ExpiryDate, time.Minute)
+
+	// email invites are bound to the user once they register
+	erro = di.MySQLUserRegister(userTwo)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	assert.NoError(t, di.MySQLInviteBindEmail(userTwo.Email, userTwo.Username))
+	invites, err := di.MySQLUserGetInvites(userTwo.Username)
+	assert.NoError(t, err)
+	if assert.Len(t, invites, 1) {
+		assert.Equal(t, inviteID, invites[0].InviteID)
+	}
+
+	assert.NoError(t, di.MySQLInviteDelete(inviteID))
+	assert.Equal(t, ErrNoDbChange, di.MySQLInviteDelete(inviteID))
+	_, err = di.MySQLInviteGet(inviteID)
+	assert.Equal(t, ErrNoData, err)
 
-	files, _ := di.MySQLProjectGetFiles(projectID)
 	_ = di.MySQLProjectDelete(projectID, userOne.Username)
-	di.MySQLUserDelete(userOne.Username)
+	_, _ = di.MySQLUserDelete(userOne.Username)
+	_, _ = di.MySQLUserDelete(userTwo.Username)
+}
+
+func TestDatabaseImpl_MySQLProjectTransferOwnership(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	erro = di.MySQLUserRegister(userTwo)
+	if erro != nil {
+		t.Fatal(erro)
+	}
 
+	projectID, err := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(files) != 1 {
-		t.Fatalf("Project %v returned not the correct length, expected: 1, actual: %v", projectID, len(files))
-	}
-	if files[0].FileID != fileID || files[0].RelativePath != "cc" || files[0].ProjectID != projectID {
-		t.Fatalf("Wrong return, got project: %v", files[0])
+	otherProjectID, err := di.MySQLProjectCreate(userTwo.Username, "codecollabcore")
+	if err != nil {
+		t.Fatal(err)
 	}
+	assert.NoError(t, di.MySQLProjectGrantPermission(projectID, userTwo.Username, config.PermissionsByLabel["read"], userOne.Username))
+
+	assert.Equal(t, ErrNoData, di.MySQLProjectTransferOwnership(projectID, "codecollabcore"))
+
+	assert.NoError(t, di.MySQLProjectRequestTransfer(OwnershipTransfer{
+		ProjectID:        projectID,
+		FormerOwner:      userOne.Username,
+		NewOwner:         userTwo.Username,
+		FormerOwnerLevel: config.PermissionsByLabel["write"],
+	}))
+	transfer, err := di.MySQLProjectGetTransfer(projectID)
+	assert.NoError(t, err)
+	assert.Equal(t, "codecollabcore", transfer.ProjectName)
+	assert.Equal(t, userTwo.Username, transfer.NewOwner)
+
+	// the new owner already has a project with the same name
+	assert.Equal(t, ErrNameConflict, di.MySQLProjectTransferOwnership(projectID, "codecollabcore"))
+	level, err := di.MySQLUserProjectPermissionLookup(projectID, userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, config.OwnerLevel, level, "a failed transfer should not change the owner")
+
+	assert.NoError(t, di.MySQLProjectTransferOwnership(projectID, "codecollabcore-2"))
+	level, err = di.MySQLUserProjectPermissionLookup(projectID, userTwo.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, config.OwnerLevel, level)
+	level, err = di.MySQLUserProjectPermissionLookup(projectID, userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["write"], level)
+	name, _, err := di.MySQLProjectLookup(projectID, userTwo.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, "codecollabcore-2", name)
+
+	_, err = di.MySQLProjectGetTransfer(projectID)
+	assert.Equal(t, ErrNoData, err, "the transfer should be removed once applied")
+	assert.Equal(t, ErrNoDbChange, di.MySQLProjectCancelTransfer(projectID))
+
+	_ = di.MySQLProjectDelete(projectID, userTwo.Username)
+	_ = di.MySQLProjectDelete(otherProjectID, userTwo.Username)
+	_, _ = di.MySQLUserDelete(userOne.Username)
+	_, _ = di.MySQLUserDelete(userTwo.Username)
 }
 
-func TestDatabaseImpl_MySQLRenameFile(t *testing.T) {
+func TestDatabaseImpl_MySQLProjectTrash(t *testing.T) {
 	testConfigSetup(t)
 	di := new(DatabaseImpl)
 
@@ -608,28 +802,102 @@ func TestDatabaseImpl_MySQLRenameFile(t *testing.T) {
 	if erro != nil {
 		t.Fatal(erro)
 	}
+	erro = di.MySQLUserRegister(userTwo)
+	if erro != nil {
+		t.Fatal(erro)
+	}
 
-	projectID, _ := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
-	fileID, _ := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+	projectID, err := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, di.MySQLProjectGrantPermission(projectID, userTwo.Username, config.PermissionsByLabel["write"], userOne.Username))
 
-	err := di.MySQLFileRename(fileID, "file-z")
+	assert.Equal(t, ErrNoDbChange, di.MySQLProjectTrash(projectID, userTwo.Username), "only the owner can delete the project")
+	assert.NoError(t, di.MySQLProjectTrash(projectID, userOne.Username))
+
+	projects, err := di.MySQLUserProjects(userTwo.Username)
+	assert.NoError(t, err)
+	assert.Empty(t, projects, "trashed projects should be hidden")
+	_, err = di.MySQLUserProjectPermissionLookup(projectID, userTwo.Username)
+	assert.Error(t, err, "trashed projects should not be accessible")
+
+	trashed, err := di.MySQLUserTrashedProjects(userOne.Username)
+	assert.NoError(t, err)
+	if assert.Len(t, trashed, 1) {
+		assert.Equal(t, projectID, trashed[0].ProjectID)
+		assert.Equal(t, "codecollabcore", trashed[0].Name)
+	}
+	expired, err := di.MySQLExpiredTrashedProjects(time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, expired)
+	expired, err = di.MySQLExpiredTrashedProjects(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, expired, 1)
+
+	assert.NoError(t, di.MySQLProjectRestore(projectID, userOne.Username))
+	assert.Equal(t, ErrNoDbChange, di.MySQLProjectRestore(projectID, userOne.Username))
+	level, err := di.MySQLUserProjectPermissionLookup(projectID, userTwo.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, config.PermissionsByLabel["write"], level, "permissions should be kept through the trash")
 
-	files, _ := di.MySQLProjectGetFiles(projectID)
 	_ = di.MySQLProjectDelete(projectID, userOne.Username)
-	di.MySQLUserDelete(userOne.Username)
+	_, _ = di.MySQLUserDelete(userOne.Username)
+	_, _ = di.MySQLUserDelete(userTwo.Username)
+}
+
+func TestDatabaseImpl_MySQLProjectGetAuditLog(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
 
+	projectID, err := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(files) != 1 {
-		t.Fatalf("Project %v returned not the correct length, expected: 1, actual: %v", projectID, len(files))
+
+	for _, action := range []string{"Project.Rename", "Project.GrantPermissions", "Project.Rename"} {
+		err = di.MySQLAuditLogInsert(AuditEntry{
+			Actor:     userOne.Username,
+			Action:    action,
+			ProjectID: projectID,
+			SourceIP:  "127.0.0.1",
+		})
+		assert.NoError(t, err)
 	}
-	if files[0].FileID != fileID || files[0].Filename != "file-z" || files[0].ProjectID != projectID {
-		t.Fatalf("Wrong return, got project: %v", files[0])
+
+	entries, err := di.MySQLProjectGetAuditLog(projectID, AuditFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	entries, err = di.MySQLProjectGetAuditLog(projectID, AuditFilter{Action: "Project.Rename", Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	entries, err = di.MySQLProjectGetAuditLog(projectID, AuditFilter{Offset: 1, Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "Project.GrantPermissions", entries[0].Action, "Entries should be returned newest first")
+	assert.Equal(t, "127.0.0.1", entries[0].SourceIP)
+
+	// entries outlive the project
+	err = di.MySQLProjectDelete(projectID, userOne.Username)
+	assert.NoError(t, err)
+	entries, err = di.MySQLProjectGetAuditLog(projectID, AuditFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	_, err = di.MySQLUserDelete(userOne.Username)
+	if err != nil {
+		t.Fatal(err)
 	}
 }
 
-func TestDatabaseImpl_MySQLFileGetInfo(t *testing.T) {
+func TestDatabaseImpl_MySQLFileCreate(t *testing.T) {
 	testConfigSetup(t)
 	di := new(DatabaseImpl)
 
@@ -637,24 +905,858 @@ func TestDatabaseImpl_MySQLFileGetInfo(t *testing.T) {
 	if erro != nil {
 		t.Fatal(erro)
 	}
+	filename := "file-y"
 
 	projectID, _ := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
-	fileID, _ := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+	fileID, err := di.MySQLFileCreate(userOne.Username, filename, ".", projectID)
 
-	filebefore, err := di.MySQLFileGetInfo(fileID)
-	_ = di.MySQLFileMove(fileID, "cc")
-	fileafter, err := di.MySQLFileGetInfo(fileID)
+	files, _ := di.MySQLProjectGetFiles(projectID)
+
+	defer di.MySQLUserDelete(userOne.Username)
+	defer di.MySQLProjectDelete(projectID, userOne.Username)
+
+	assert.NoError(t, err, "mysql error")
+	assert.Equal(t, 1, len(files), "Project incorrect file count")
+	assert.Equal(t, fileID, files[0].FileID, "incorrect fileID")
+	assert.Equal(t, userOne.Username, files[0].Creator, "incorrect creator")
+	assert.Equal(t, ".", files[0].RelativePath, "incorrect relative path")
+	assert.Equal(t, filename, files[0].Filename, "incorrect filename")
+	assert.Equal(t, projectID, files[0].ProjectID, "incorrect projectID")
+
+	// should fail b/c location is already in use
+	fileIDNew, err := di.MySQLFileCreate(userOne.Username, filename, ".", projectID)
+	assert.EqualValues(t, -1, fileIDNew, "Expected invalid FileID to be returned")
+	assert.Error(t, err, "expected duplicate insertion to fail")
+}
+
+func TestDatabaseImpl_MySQLFileDelete(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+
+	projectID, _ := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
+	fileID, _ := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+	err := di.MySQLFileDelete(fileID)
 
+	files, _ := di.MySQLProjectGetFiles(projectID)
 	_ = di.MySQLProjectDelete(projectID, userOne.Username)
 	di.MySQLUserDelete(userOne.Username)
 
 	if err != nil {
 		t.Fatal(err)
 	}
-	if filebefore.FileID != fileID || filebefore.RelativePath != "." || filebefore.ProjectID != projectID {
-		t.Fatalf("Wrong return, got project: %v", filebefore)
+	if len(files) != 0 {
+		t.Fatalf("Project %v returned not the correct length, expected: 0, actual: %v", projectID, len(files))
 	}
-	if fileafter.FileID != fileID || fileafter.RelativePath != "cc" || fileafter.ProjectID != projectID {
-		t.Fatalf("Wrong return, got project: %v", filebefore)
+}
+
+func TestDatabaseImpl_MySQLFileTrash(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
 	}
+
+	projectID, _ := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
+	fileID, _ := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+	defer di.MySQLUserDelete(userOne.Username)
+	defer di.MySQLProjectDelete(projectID, userOne.Username)
+
+	assert.NoError(t, di.MySQLFileTrash(fileID, userOne.Username))
+	assert.Equal(t, ErrNoDbChange, di.MySQLFileTrash(fileID, userOne.Username))
+
+	files, err := di.MySQLProjectGetFiles(projectID)
+	assert.NoError(t, err)
+	assert.Empty(t, files, "trashed files should be hidden")
+	_, err = di.MySQLFileGetInfo(fileID)
+	assert.Error(t, err)
+
+	trashed, err := di.MySQLFileGetTrashedInfo(fileID)
+	assert.NoError(t, err)
+	assert.Equal(t, "file-y", trashed.Filename)
+	assert.Equal(t, userOne.Username, trashed.DeletedBy)
+	trashedFiles, err := di.MySQLProjectGetTrashedFiles(projectID)
+	assert.NoError(t, err)
+	assert.Len(t, trashedFiles, 1)
+	expired, err := di.MySQLExpiredTrashedFiles(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, expired, 1)
+
+	// a new file can be created at the path of a trashed one
+	newFileID, err := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+	assert.NoError(t, err)
+	assert.NoError(t, di.MySQLFileDelete(newFileID))
+
+	assert.NoError(t, di.MySQLFileRestore(fileID))
+	_, err = di.MySQLFileGetTrashedInfo(fileID)
+	assert.Equal(t, ErrNoData, err)
+	files, err = di.MySQLProjectGetFiles(projectID)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestDatabaseImpl_MySQLShareLinks(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+
+	projectID, _ := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
+	defer di.MySQLUserDelete(userOne.Username)
+	defer di.MySQLProjectDelete(projectID, userOne.Username)
+
+	shareLinkID, err := di.MySQLShareLinkCreate(ShareLink{
+		ProjectID: projectID,
+		CreatedBy: userOne.Username,
+		MaxUses:   1,
+	}, "hash", time.Hour)
+	assert.NoError(t, err)
+	expiredID, err := di.MySQLShareLinkCreate(ShareLink{
+		ProjectID: projectID,
+		CreatedBy: userOne.Username,
+	}, "expired", -time.Hour)
+	assert.NoError(t, err)
+
+	link, err := di.MySQLShareLinkGet(shareLinkID)
+	assert.NoError(t, err)
+	assert.Equal(t, "codecollabcore", link.ProjectName)
+	links, err := di.MySQLProjectGetShareLinks(projectID)
+	assert.NoError(t, err)
+	assert.Len(t, links, 2)
+
+	_, err = di.MySQLShareLinkRedeem("expired")
+	assert.Equal(t, ErrNoData, err, "expired links should not be redeemed")
+	redeemedID, err := di.MySQLShareLinkRedeem("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, shareLinkID, redeemedID)
+	_, err = di.MySQLShareLinkRedeem("hash")
+	assert.Equal(t, ErrNoData, err, "links should not be redeemed more than MaxUses times")
+
+	assert.NoError(t, di.MySQLShareLinkDelete(shareLinkID))
+	assert.Equal(t, ErrNoDbChange, di.MySQLShareLinkDelete(shareLinkID))
+	_, err = di.MySQLShareLinkGet(shareLinkID)
+	assert.Equal(t, ErrNoData, err)
+	assert.NoError(t, di.MySQLShareLinkDelete(expiredID))
+}
+
+func TestDatabaseImpl_MySQLAccessTokens(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userOne.Username)
+
+	bot := UserMeta{Username: "_test_bot1", FirstName: "Format", LastName: "Bot", BotOwner: userOne.Username}
+	assert.NoError(t, di.MySQLUserRegisterBot(bot))
+	returnedBot, err := di.MySQLUserLookup(bot.Username)
+	assert.NoError(t, err)
+	assert.True(t, returnedBot.Bot)
+	assert.Equal(t, userOne.Username, returnedBot.BotOwner)
+	password, err := di.MySQLUserGetPass(bot.Username)
+	assert.NoError(t, err)
+	assert.Empty(t, password, "bots should not have a password")
+
+	tokenID, err := di.MySQLAccessTokenCreate(AccessToken{
+		Username: bot.Username,
+		Name:     "ci",
+		Scopes:   []string{"read", "project:1"},
+	}, "hash", 0)
+	assert.NoError(t, err)
+
+	token, err := di.MySQLAccessTokenAuthenticate("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, tokenID, token.TokenID)
+	assert.Equal(t, []string{"read", "project:1"}, token.Scopes)
+	assert.True(t, token.ExpiryDate.IsZero(), "tokens created without an expiry should not expire")
+	tokens, err := di.MySQLUserGetAccessTokens(bot.Username)
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 1)
+
+	assert.NoError(t, di.MySQLAccessTokenDelete(tokenID))
+	assert.Equal(t, ErrNoDbChange, di.MySQLAccessTokenDelete(tokenID))
+	_, err = di.MySQLAccessTokenAuthenticate("hash")
+	assert.Equal(t, ErrNoData, err)
+
+	// bots are deleted along with their owner
+	di.MySQLUserDelete(userOne.Username)
+	_, err = di.MySQLUserLookup(bot.Username)
+	assert.Equal(t, ErrNoData, err)
+}
+
+func TestDatabaseImpl_MySQLFileMove(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+
+	projectID, _ := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
+	fileID, _ := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+
+	err := di.MySQLFileMove(fileID, "cc")
+
+	files, _ := di.MySQLProjectGetFiles(projectID)
+	_ = di.MySQLProjectDelete(projectID, userOne.Username)
+	di.MySQLUserDelete(userOne.Username)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Project %v returned not the correct length, expected: 1, actual: %v", projectID, len(files))
+	}
+	if files[0].FileID != fileID || files[0].RelativePath != "cc" || files[0].ProjectID != projectID {
+		t.Fatalf("Wrong return, got project: %v", files[0])
+	}
+}
+
+func TestDatabaseImpl_MySQLRenameFile(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+
+	projectID, _ := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
+	fileID, _ := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+
+	err := di.MySQLFileRename(fileID, "file-z")
+
+	files, _ := di.MySQLProjectGetFiles(projectID)
+	_ = di.MySQLProjectDelete(projectID, userOne.Username)
+	di.MySQLUserDelete(userOne.Username)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Project %v returned not the correct length, expected: 1, actual: %v", projectID, len(files))
+	}
+	if files[0].FileID != fileID || files[0].Filename != "file-z" || files[0].ProjectID != projectID {
+		t.Fatalf("Wrong return, got project: %v", files[0])
+	}
+}
+
+func TestDatabaseImpl_MySQLFileGetInfo(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+
+	projectID, _ := di.MySQLProjectCreate(userOne.Username, "codecollabcore")
+	fileID, _ := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+
+	filebefore, err := di.MySQLFileGetInfo(fileID)
+	_ = di.MySQLFileMove(fileID, "cc")
+	fileafter, err := di.MySQLFileGetInfo(fileID)
+
+	_ = di.MySQLProjectDelete(projectID, userOne.Username)
+	di.MySQLUserDelete(userOne.Username)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filebefore.FileID != fileID || filebefore.RelativePath != "." || filebefore.ProjectID != projectID {
+		t.Fatalf("Wrong return, got project: %v", filebefore)
+	}
+	if fileafter.FileID != fileID || fileafter.RelativePath != "cc" || fileafter.ProjectID != projectID {
+		t.Fatalf("Wrong return, got project: %v", filebefore)
+	}
+}
+
+func TestDatabaseImpl_MySQLExternalIdentities(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userOne.Username)
+
+	user, err := di.MySQLUserLookupEmail(userOne.Email)
+	assert.NoError(t, err)
+	assert.Equal(t, userOne.Username, user.Username)
+	_, err = di.MySQLUserLookupEmail("nobody@example.com")
+	assert.Equal(t, ErrNoData, err)
+
+	_, err = di.MySQLExternalIdentityLookup("idp", "sub-1")
+	assert.Equal(t, ErrNoData, err)
+	assert.NoError(t, di.MySQLExternalIdentityLink("idp", "sub-1", userOne.Username))
+	assert.Error(t, di.MySQLExternalIdentityLink("idp", "sub-1", userOne.Username), "identities should only be linked once")
+
+	username, err := di.MySQLExternalIdentityLookup("idp", "sub-1")
+	assert.NoError(t, err)
+	assert.Equal(t, userOne.Username, username)
+	_, err = di.MySQLExternalIdentityLookup("other", "sub-1")
+	assert.Equal(t, ErrNoData, err, "subjects should be scoped to their provider")
+
+	// identities are deleted along with their user
+	di.MySQLUserDelete(userOne.Username)
+	_, err = di.MySQLExternalIdentityLookup("idp", "sub-1")
+	assert.Equal(t, ErrNoData, err)
+}
+
+func TestDatabaseImpl_MySQLTwoFactor(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userOne.Username)
+
+	_, err := di.MySQLUserGet2FA(userOne.Username)
+	assert.Equal(t, ErrNoData, err)
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserEnable2FA(userOne.Username, 1, nil), "a secret must be set first")
+
+	// unconfirmed secrets can be replaced
+	assert.NoError(t, di.MySQLUserSet2FASecret(userOne.Username, "FIRST"))
+	assert.NoError(t, di.MySQLUserSet2FASecret(userOne.Username, "SECOND"))
+	twoFactor, err := di.MySQLUserGet2FA(userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, TwoFactor{Secret: "SECOND"}, twoFactor)
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserUse2FAStep(userOne.Username, 5), "steps should not be used before 2FA is enabled")
+
+	assert.NoError(t, di.MySQLUserEnable2FA(userOne.Username, 10, []string{"hash1", "hash2"}))
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserSet2FASecret(userOne.Username, "THIRD"), "enabled secrets cannot be replaced")
+	twoFactor, err = di.MySQLUserGet2FA(userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, TwoFactor{Secret: "SECOND", Enabled: true, LastUsedStep: 10}, twoFactor)
+
+	// steps can only be used once, in order
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserUse2FAStep(userOne.Username, 10))
+	assert.NoError(t, di.MySQLUserUse2FAStep(userOne.Username, 11))
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserUse2FAStep(userOne.Username, 9))
+
+	assert.NoError(t, di.MySQLUserUseRecoveryCode(userOne.Username, "hash1"))
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserUseRecoveryCode(userOne.Username, "hash1"))
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserUseRecoveryCode(userOne.Username, "unknown"))
+
+	assert.NoError(t, di.MySQLUserDisable2FA(userOne.Username))
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserDisable2FA(userOne.Username))
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserUseRecoveryCode(userOne.Username, "hash2"), "recovery codes should be cleared")
+	_, err = di.MySQLUserGet2FA(userOne.Username)
+	assert.Equal(t, ErrNoData, err)
+}
+
+func TestDatabaseImpl_MySQLEmailVerification(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	pending := userOne
+	pending.Status = UserStatusPending
+	erro := di.MySQLUserRegister(pending)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userOne.Username)
+
+	user, err := di.MySQLUserLookup(userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, UserStatusPending, user.Status)
+
+	_, err = di.MySQLEmailVerificationRedeem("unknown")
+	assert.Equal(t, ErrNoData, err)
+	assert.NoError(t, di.MySQLEmailVerificationCreate(userOne.Username, "expired", -time.Minute))
+	_, err = di.MySQLEmailVerificationRedeem("expired")
+	assert.Equal(t, ErrNoData, err, "expired tokens should not be redeemable")
+
+	// creating a token replaces the last one
+	assert.NoError(t, di.MySQLEmailVerificationCreate(userOne.Username, "hash", time.Hour))
+	username, err := di.MySQLEmailVerificationRedeem("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, userOne.Username, username)
+	user, err = di.MySQLUserLookup(userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, UserStatusActive, user.Status)
+
+	_, err = di.MySQLEmailVerificationRedeem("hash")
+	assert.Equal(t, ErrNoData, err, "tokens should only be redeemable once")
+	assert.Equal(t, ErrNoDbChange, di.MySQLEmailVerificationCreate(userOne.Username, "hash", time.Hour),
+		"active users should not be sent tokens")
+}
+
+func TestDatabaseImpl_MySQLLoginFailures(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+	defer di.MySQLLoginFailuresClear(LoginSubjectIP, "10.0.0.1")
+
+	_, err := di.MySQLLoginFailuresGet(LoginSubjectIP, "10.0.0.1")
+	assert.Equal(t, ErrNoData, err)
+	assert.Equal(t, ErrNoDbChange, di.MySQLLoginFailuresClear(LoginSubjectIP, "10.0.0.1"))
+
+	for i := 1; i <= 3; i++ {
+		failures, err := di.MySQLLoginFailureRecord(LoginSubjectIP, "10.0.0.1", time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, i, failures)
+	}
+	failures, err := di.MySQLLoginFailureRecord(LoginSubjectIP, "10.0.0.1", -time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures, "stale failures should be reset")
+
+	assert.NoError(t, di.MySQLLoginFailuresLock(LoginSubjectIP, "10.0.0.1", time.Hour))
+	locked, err := di.MySQLLoginFailuresGet(LoginSubjectIP, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, locked.Failures)
+	assert.True(t, locked.LockedUntil.After(time.Now()))
+
+	_, err = di.MySQLLoginFailuresGet(LoginSubjectUser, "10.0.0.1")
+	assert.Equal(t, ErrNoData, err, "failures should be kept separately for each kind")
+
+	assert.NoError(t, di.MySQLLoginFailuresClear(LoginSubjectIP, "10.0.0.1"))
+	_, err = di.MySQLLoginFailuresGet(LoginSubjectIP, "10.0.0.1")
+	assert.Equal(t, ErrNoData, err)
+}
+
+func TestDatabaseImpl_MySQLUserUpdate(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userOne.Username)
+
+	assert.NoError(t, di.MySQLUserUpdateProfile(userOne.Username, "Joe", "Shapiro"))
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserUpdateProfile(userOne.Username, "Joe", "Shapiro"))
+	assert.NoError(t, di.MySQLEmailChangeCreate(userOne.Username, "_test_email3@codecollab.cc", "hash", time.Hour))
+
+	user, err := di.MySQLUserLookup(userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, "Joe", user.FirstName)
+	assert.Equal(t, userOne.Email, user.Email, "the email address should only change once verified")
+
+	username, err := di.MySQLEmailVerificationRedeem("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, userOne.Username, username)
+	user, err = di.MySQLUserLookup(userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, "_test_email3@codecollab.cc", user.Email)
+}
+
+func TestDatabaseImpl_MySQLUserSearch(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userOne.Username)
+	erro = di.MySQLUserRegister(userTwo)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userTwo.Username)
+	projectID, err := di.MySQLProjectCreate(userOne.Username, "_test_project_1")
+	assert.NoError(t, err)
+	defer di.MySQLProjectDelete(projectID, userOne.Username)
+
+	// the underscores are matched literally, rather than as wildcards
+	results, err := di.MySQLUserSearch("_test_user", projectID, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []UserSearchResult{
+		{Username: userOne.Username, FirstName: userOne.FirstName, LastName: userOne.LastName, HasAccess: true},
+		{Username: userTwo.Username, FirstName: userTwo.FirstName, LastName: userTwo.LastName},
+	}, results)
+
+	results, err = di.MySQLUserSearch("_test_user", projectID, 1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, userTwo.Username, results[0].Username)
+	}
+
+	results, err = di.MySQLUserSearch("_test_email2@", 0, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, userTwo.Username, results[0].Username)
+	}
+
+	results, err = di.MySQLUserSearch("Fasl", 0, 0, 100)
+	assert.NoError(t, err)
+	assert.Contains(t, results, UserSearchResult{Username: userTwo.Username, FirstName: userTwo.FirstName, LastName: userTwo.LastName},
+		"names which sound alike should match")
+
+	assert.NoError(t, di.MySQLUserSetHiddenFromSearch(userTwo.Username, true))
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserSetHiddenFromSearch(userTwo.Username, true))
+	user, err := di.MySQLUserLookup(userTwo.Username)
+	assert.NoError(t, err)
+	assert.True(t, user.HiddenFromSearch)
+	results, err = di.MySQLUserSearch("_test_user", projectID, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+func TestDatabaseImpl_MySQLNotifications(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userOne.Username)
+
+	assert.NoError(t, di.MySQLNotificationInsert(userOne.Username,
+		Notification{Resource: "Project", Method: "GrantPermissions", ResourceID: 1, Data: json.RawMessage(`{"PermissionLevel":5}`)}))
+	assert.NoError(t, di.MySQLNotificationInsert(userOne.Username,
+		Notification{Resource: "Project", Method: "Delete", ResourceID: 1, Data: json.RawMessage(`{"Name":"_test_project_1"}`)}))
+	assert.Error(t, di.MySQLNotificationInsert(userTwo.Username, Notification{Resource: "Project", Method: "Delete", Data: json.RawMessage(`{}`)}),
+		"notifications should only be stored for users who exist")
+
+	notifications, err := di.MySQLNotificationsGet(userOne.Username, true, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, notifications, 2) {
+		assert.Equal(t, "Delete", notifications[0].Method)
+		assert.JSONEq(t, `{"PermissionLevel":5}`, string(notifications[1].Data))
+		assert.False(t, notifications[1].Read)
+	}
+	unread, err := di.MySQLNotificationsCountUnread(userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, unread)
+
+	marked, err := di.MySQLNotificationsMarkRead(userOne.Username, []int64{notifications[1].NotificationID})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, marked)
+	marked, err = di.MySQLNotificationsMarkRead(userTwo.Username, []int64{notifications[0].NotificationID})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, marked, "users should not be able to mark the notifications of others")
+
+	notifications, err = di.MySQLNotificationsGet(userOne.Username, true, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, notifications, 1)
+	notifications, err = di.MySQLNotificationsGet(userOne.Username, false, 1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, notifications, 1) {
+		assert.True(t, notifications[0].Read)
+	}
+
+	marked, err = di.MySQLNotificationsMarkRead(userOne.Username, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, marked)
+	unread, err = di.MySQLNotificationsCountUnread(userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, unread)
+}
+
+func TestDatabaseImpl_MySQLWebhooks(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userOne.Username)
+	projectID, erro := di.MySQLProjectCreate(userOne.Username, "_test_project_1")
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLProjectDelete(projectID, userOne.Username)
+
+	webhookID, err := di.MySQLWebhookCreate(Webhook{
+		ProjectID: projectID,
+		URL:       "https://example.com/hook",
+		Secret:    "secret",
+		Events:    []string{"File.Create", "File.Delete"},
+		CreatedBy: userOne.Username,
+	})
+	assert.NoError(t, err)
+	_, err = di.MySQLWebhookCreate(Webhook{ProjectID: projectID, URL: "https://example.com/all", Secret: "secret", CreatedBy: userOne.Username})
+	assert.NoError(t, err)
+
+	webhooks, err := di.MySQLWebhooksGet(projectID)
+	assert.NoError(t, err)
+	if assert.Len(t, webhooks, 2) {
+		assert.Equal(t, webhookID, webhooks[0].WebhookID)
+		assert.Equal(t, "secret", webhooks[0].Secret)
+		assert.Equal(t, []string{"File.Create", "File.Delete"}, webhooks[0].Events)
+		assert.Empty(t, webhooks[1].Events)
+	}
+	projectIDs, err := di.MySQLWebhookProjectIDs()
+	assert.NoError(t, err)
+	assert.Contains(t, projectIDs, projectID)
+
+	for i := 0; i < WebhookDeliveriesKept+1; i++ {
+		assert.NoError(t, di.MySQLWebhookDeliveryInsert(WebhookDelivery{
+			WebhookID:  webhookID,
+			Event:      "File.Create",
+			Attempts:   i,
+			StatusCode: 500,
+			Error:      "Unexpected response status: 500 Internal Server Error",
+		}))
+	}
+	deliveries, err := di.MySQLWebhookDeliveriesGet(webhookID, WebhookDeliveriesKept+10)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, WebhookDeliveriesKept, "only the latest deliveries should be kept") {
+		assert.Equal(t, WebhookDeliveriesKept, deliveries[0].Attempts)
+		assert.False(t, deliveries[0].Success)
+	}
+
+	assert.Equal(t, ErrNoDbChange, di.MySQLWebhookDelete(projectID+1, webhookID),
+		"webhooks should only be deleted from their own project")
+	assert.NoError(t, di.MySQLWebhookDelete(projectID, webhookID))
+	assert.Equal(t, ErrNoDbChange, di.MySQLWebhookDelete(projectID, webhookID))
+	deliveries, err = di.MySQLWebhookDeliveriesGet(webhookID, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func TestDatabaseImpl_MySQLComments(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userOne.Username)
+	projectID, erro := di.MySQLProjectCreate(userOne.Username, "_test_project_1")
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLProjectDelete(projectID, userOne.Username)
+	fileID, erro := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+
+	threadID, commentID, err := di.MySQLCommentThreadCreate(CommentThread{
+		FileID:        fileID,
+		CommentAnchor: CommentAnchor{StartIndex: 5, EndIndex: 10, FileVersion: 2},
+		CreatedBy:     userOne.Username,
+	}, "first")
+	assert.NoError(t, err)
+	replyID, err := di.MySQLCommentReply(threadID, userOne.Username, "second")
+	assert.NoError(t, err)
+	assert.NotEqual(t, commentID, replyID)
+
+	thread, err := di.MySQLCommentThreadGet(threadID)
+	assert.NoError(t, err)
+	assert.Equal(t, fileID, thread.FileID)
+	assert.Equal(t, CommentAnchor{StartIndex: 5, EndIndex: 10, FileVersion: 2}, thread.CommentAnchor)
+	_, err = di.MySQLCommentThreadGet(threadID + 1)
+	assert.Equal(t, ErrNoData, err)
+
+	comments, err := di.MySQLCommentsGet(fileID)
+	assert.NoError(t, err)
+	if assert.Len(t, comments, 2) {
+		assert.Equal(t, "first", comments[0].Body)
+		assert.Equal(t, replyID, comments[1].CommentID)
+	}
+
+	// anchors are only moved from the version they were read at
+	moved := CommentAnchor{StartIndex: 7, EndIndex: 12, FileVersion: 3}
+	assert.NoError(t, di.MySQLCommentAnchorUpdate(threadID, 2, moved))
+	assert.Equal(t, ErrNoDbChange, di.MySQLCommentAnchorUpdate(threadID, 2, CommentAnchor{FileVersion: 3, Orphaned: true}))
+
+	assert.NoError(t, di.MySQLCommentThreadResolve(threadID, true, userOne.Username))
+	assert.Equal(t, ErrNoDbChange, di.MySQLCommentThreadResolve(threadID, true, userOne.Username))
+
+	threads, err := di.MySQLCommentThreadsGet(fileID, false)
+	assert.NoError(t, err)
+	assert.Empty(t, threads, "resolved threads should be excluded")
+	threads, err = di.MySQLCommentThreadsGet(fileID, true)
+	assert.NoError(t, err)
+	if assert.Len(t, threads, 1) {
+		assert.Equal(t, moved, threads[0].CommentAnchor)
+		assert.True(t, threads[0].Resolved)
+		assert.Equal(t, userOne.Username, threads[0].ResolvedBy)
+	}
+}
+
+func TestDatabaseImpl_MySQLSuggestions(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userOne.Username)
+	projectID, erro := di.MySQLProjectCreate(userOne.Username, "_test_project_1")
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLProjectDelete(projectID, userOne.Username)
+	fileID, erro := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+
+	suggestionID, err := di.MySQLSuggestionCreate(Suggestion{
+		FileID:      fileID,
+		Author:      userOne.Username,
+		Patch:       "v2:\n5:+2:xy:\n20",
+		FileVersion: 2,
+	})
+	assert.NoError(t, err)
+	_, err = di.MySQLSuggestionCreate(Suggestion{FileID: fileID, Author: userOne.Username, Patch: "v2:\n0:-1:a:\n20", FileVersion: 2})
+	assert.NoError(t, err)
+
+	suggestion, err := di.MySQLSuggestionGet(suggestionID)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2:\n5:+2:xy:\n20", suggestion.Patch)
+	assert.Equal(t, userOne.Username, suggestion.Author)
+	_, err = di.MySQLSuggestionGet(suggestionID + 2)
+	assert.Equal(t, ErrNoData, err)
+
+	// suggestions are only rebased from the version they were read at
+	assert.NoError(t, di.MySQLSuggestionRebase(suggestionID, 2, "v3:\n8:+2:xy:\n23", 3))
+	assert.Equal(t, ErrNoDbChange, di.MySQLSuggestionRebase(suggestionID, 2, "v3:\n5:+2:xy:\n23", 3))
+
+	suggestions, err := di.MySQLSuggestionsGet(fileID)
+	assert.NoError(t, err)
+	if assert.Len(t, suggestions, 2) {
+		assert.Equal(t, suggestionID, suggestions[0].SuggestionID)
+		assert.Equal(t, "v3:\n8:+2:xy:\n23", suggestions[0].Patch)
+		assert.EqualValues(t, 3, suggestions[0].FileVersion)
+	}
+
+	assert.NoError(t, di.MySQLSuggestionDelete(suggestionID))
+	assert.Equal(t, ErrNoDbChange, di.MySQLSuggestionDelete(suggestionID))
+	suggestions, err = di.MySQLSuggestionsGet(fileID)
+	assert.NoError(t, err)
+	assert.Len(t, suggestions, 1)
+}
+
+func TestDatabaseImpl_MySQLFileLocks(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userOne.Username)
+	erro = di.MySQLUserRegister(userTwo)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userTwo.Username)
+	projectID, erro := di.MySQLProjectCreate(userOne.Username, "_test_project_1")
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLProjectDelete(projectID, userOne.Username)
+	fileID, erro := di.MySQLFileCreate(userOne.Username, "file-y", ".", projectID)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+
+	_, err := di.MySQLFileLockGet(fileID)
+	assert.Equal(t, ErrNoData, err)
+
+	lock, err := di.MySQLFileLockAcquire(FileLock{FileID: fileID, Username: userOne.Username, Socket: "WS-test-1", Enforced: true}, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, userOne.Username, lock.Username)
+	assert.True(t, lock.Enforced)
+
+	// the lock is held until it expires or is released
+	lock, err = di.MySQLFileLockAcquire(FileLock{FileID: fileID, Username: userTwo.Username, Socket: "WS-test-2"}, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, userOne.Username, lock.Username)
+	assert.Equal(t, ErrNoDbChange, di.MySQLFileLockRelease(fileID, userTwo.Username, false))
+
+	lock, err = di.MySQLFileLockAcquire(FileLock{FileID: fileID, Username: userOne.Username, Socket: "WS-test-3"}, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "WS-test-1", lock.Socket, "the lock should only be renewed by the websocket holding it")
+	assert.True(t, lock.Enforced)
+
+	lock, err = di.MySQLFileLockGet(fileID)
+	assert.NoError(t, err)
+	assert.Equal(t, "WS-test-1", lock.Socket)
+
+	locks, err := di.MySQLFileLocksReleaseSocket("WS-test-2")
+	assert.NoError(t, err)
+	assert.Empty(t, locks)
+	locks, err = di.MySQLFileLocksReleaseSocket("WS-test-1")
+	assert.NoError(t, err)
+	if assert.Len(t, locks, 1) {
+		assert.Equal(t, fileID, locks[0].FileID)
+	}
+
+	// expired locks are taken over
+	_, err = di.MySQLFileLockAcquire(FileLock{FileID: fileID, Username: userOne.Username, Socket: "WS-test-1"}, -time.Minute)
+	assert.NoError(t, err)
+	lock, err = di.MySQLFileLockAcquire(FileLock{FileID: fileID, Username: userTwo.Username, Socket: "WS-test-2"}, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, userTwo.Username, lock.Username)
+
+	assert.NoError(t, di.MySQLFileLockRelease(fileID, userOne.Username, true))
+	assert.Equal(t, ErrNoDbChange, di.MySQLFileLockRelease(fileID, userOne.Username, true))
+}
+
+func TestDatabaseImpl_MySQLUserRename(t *testing.T) {
+	testConfigSetup(t)
+	di := new(DatabaseImpl)
+	renamed := "_test_renamed"
+
+	erro := di.MySQLUserRegister(userOne)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(renamed)
+	defer di.MySQLUserDelete(userOne.Username)
+	erro = di.MySQLUserRegister(userTwo)
+	if erro != nil {
+		t.Fatal(erro)
+	}
+	defer di.MySQLUserDelete(userTwo.Username)
+	erro = di.MySQLUserRegisterBot(UserMeta{Username: "_test_bot", FirstName: "Bot", BotOwner: userOne.Username})
+	if erro != nil {
+		t.Fatal(erro)
+	}
+
+	projectID, err := di.MySQLProjectCreate(userOne.Username, "_test_project_1")
+	assert.NoError(t, err)
+	writePerm, _ := config.PermissionByLabel("write")
+	assert.NoError(t, di.MySQLProjectGrantPermission(projectID, userTwo.Username, writePerm.Level, userOne.Username))
+
+	assert.Equal(t, ErrNoDbChange, di.MySQLUserRename("_test_nobody", renamed))
+	assert.Error(t, di.MySQLUserRename(userOne.Username, userTwo.Username), "taken usernames should be rejected")
+	assert.NoError(t, di.MySQLUserRename(userOne.Username, renamed))
+
+	user, err := di.MySQLUserLookup(renamed)
+	assert.NoError(t, err)
+	assert.Equal(t, userOne.Email, user.Email)
+	bot, err := di.MySQLUserLookup("_test_bot")
+	assert.NoError(t, err)
+	assert.Equal(t, renamed, bot.BotOwner, "bots should follow their owner")
+
+	projects, err := di.MySQLUserProjects(renamed)
+	assert.NoError(t, err)
+	assert.Len(t, projects, 1)
+	_, permissions, err := di.MySQLProjectLookup(projectID, renamed)
+	assert.NoError(t, err)
+	assert.Equal(t, renamed, permissions[userTwo.Username].GrantedBy)
+
+	tombstone, err := di.MySQLUsernameTombstoneGet(userOne.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, renamed, tombstone.RenamedTo)
+	assert.WithinDuration(t, time.Now(), tombstone.RenamedDate, time.Minute)
+	_, err = di.MySQLUsernameTombstoneGet(renamed)
+	assert.Equal(t, ErrNoData, err)
 }
@@ -0,0 +1,164 @@
+package dbfs
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+)
+
+// CleanPathPrefix normalizes the path prefix of a path permission rule, returning ErrMaliciousRequest if it points
+// outside of the project. The whole project is represented by "."
+func CleanPathPrefix(prefix string) (string, error) {
+	cleaned := filepath.Clean(prefix)
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+filePathSeparator) {
+		return "", ErrMaliciousRequest
+	}
+	return cleaned, nil
+}
+
+// filePath returns the path of the file relative to the root of its project
+func filePath(file FileMeta) string {
+	return filepath.Clean(filepath.Join(file.RelativePath, file.Filename))
+}
+
+// pathHasPrefix returns true if the path is the prefix itself, or is inside the directory it names
+func pathHasPrefix(path string, prefix string) bool {
+	return prefix == "." || path == prefix || strings.HasPrefix(path, prefix+filePathSeparator)
+}
+
+// FileAccess resolves the roles users hold on the individual files of a project. Path permission rules override
+// the project-level role of the user they name for every file under their prefix; where several rules apply, the
+// one with the longest prefix wins. The project's owner is never restricted.
+type FileAccess struct {
+	Username  string
+	ProjectID int64
+	// Role is the project-level role of the user
+	Role config.Role
+
+	rules []PathPermission
+	db    DBFS
+	// guest is set for users accessing the project through a share link, rather than as a member
+	guest bool
+}
+
+// ShareLinkRole is the role held by guests using a share link. It only allows the project to be read.
+var ShareLinkRole = config.Role{Name: "guest", Capabilities: []string{config.CapabilityRead}}
+
+// NewShareLinkFileAccess returns the access of a guest using a share link to the given project. Guests can only read
+// the files no path permission rule applies to, since notifications about the others are only sent to their readers.
+func NewShareLinkFileAccess(username string, projectID int64, db DBFS) (*FileAccess, error) {
+	rules, err := db.MySQLProjectGetPathPermissions(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileAccess{
+		Username:  username,
+		ProjectID: projectID,
+		Role:      ShareLinkRole,
+		rules:     rules,
+		db:        db,
+		guest:     true,
+	}, nil
+}
+
+// NewFileAccess looks up the user's role and the path permission rules for the given project
+func NewFileAccess(username string, projectID int64, db DBFS) (*FileAccess, error) {
+	role, err := UserRole(username, projectID, db)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := db.MySQLProjectGetPathPermissions(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileAccess{
+		Username:  username,
+		ProjectID: projectID,
+		Role:      role,
+		rules:     rules,
+		db:        db,
+	}, nil
+}
+
+// roleFor applies the rules for the given user to the path, returning the role they hold on it
+func (fa *FileAccess) roleFor(username string, projectRole config.Role, path string) (config.Role, error) {
+	if projectRole.Level == config.OwnerLevel {
+		return projectRole, nil
+	}
+
+	var match *PathPermission
+	for i, rule := range fa.rules {
+		if rule.Username != username || !pathHasPrefix(path, rule.PathPrefix) {
+			continue
+		}
+		if match == nil || len(rule.PathPrefix) > len(match.PathPrefix) {
+			match = &fa.rules[i]
+		}
+	}
+
+	if match == nil {
+		return projectRole, nil
+	}
+	if match.PermissionLevel == 0 {
+		return config.Role{}, nil
+	}
+	return ProjectRole(fa.ProjectID, match.PermissionLevel, fa.db)
+}
+
+// FileRole returns the role the user holds on the given file
+func (fa *FileAccess) FileRole(file FileMeta) (config.Role, error) {
+	if fa.guest && fa.Restricted(file) {
+		return config.Role{}, nil
+	}
+	return fa.roleFor(fa.Username, fa.Role, filePath(file))
+}
+
+// Can returns true if the user's role on the given file grants the given capability
+func (fa *FileAccess) Can(file FileMeta, capability string) bool {
+	role, err := fa.FileRole(file)
+	if err != nil {
+		return false
+	}
+	return role.Has(capability)
+}
+
+// Restricted returns true if any rule applies to the given file, in which case the users that can read it may
+// differ from the members of the project
+func (fa *FileAccess) Restricted(file FileMeta) bool {
+	path := filePath(file)
+	for _, rule := range fa.rules {
+		if pathHasPrefix(path, rule.PathPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Readers returns the sorted usernames of the project members that can read the given file
+func (fa *FileAccess) Readers(file FileMeta) ([]string, error) {
+	_, permissions, err := fa.db.MySQLProjectLookup(fa.ProjectID, fa.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filePath(file)
+	readers := []string{}
+	for username, permission := range permissions {
+		projectRole, err := ProjectRole(fa.ProjectID, permission.PermissionLevel, fa.db)
+		if err != nil {
+			continue
+		}
+		role, err := fa.roleFor(username, projectRole, path)
+		if err == nil && role.Has(config.CapabilityRead) {
+			readers = append(readers, username)
+		}
+	}
+	sort.Strings(readers)
+
+	return readers, nil
+}
@@ -0,0 +1,84 @@
+package dbfs
+
+import (
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanPathPrefix(t *testing.T) {
+	cases := map[string]string{
+		"":            ".",
+		"./":          ".",
+		"frontend/":   "frontend",
+		"a/../b/c.go": "b/c.go",
+	}
+	for prefix, expected := range cases {
+		cleaned, err := CleanPathPrefix(prefix)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, cleaned)
+	}
+
+	for _, prefix := range []string{"..", "../secrets", "a/../../b", "/etc"} {
+		_, err := CleanPathPrefix(prefix)
+		assert.Equal(t, ErrMaliciousRequest, err, "prefixes outside of the project should be rejected: %q", prefix)
+	}
+}
+
+func TestFileAccess(t *testing.T) {
+	testConfigSetup(t)
+	db := NewDBMock()
+	projectID, _ := db.MySQLProjectCreate("owner", "project")
+	db.MySQLProjectGrantPermission(projectID, "contractor", config.PermissionsByLabel["read"], "owner")
+	db.MySQLProjectGrantPermission(projectID, "developer", config.PermissionsByLabel["write"], "owner")
+	db.PathPermissions[projectID] = []PathPermission{
+		{PathPrefix: "frontend", Username: "contractor", PermissionLevel: config.PermissionsByLabel["write"]},
+		{PathPrefix: "frontend/secrets", Username: "contractor", PermissionLevel: 0},
+		{PathPrefix: ".", Username: "owner", PermissionLevel: 0},
+	}
+
+	frontendFile := FileMeta{ProjectID: projectID, RelativePath: "frontend/src", Filename: "app.js"}
+	secretFile := FileMeta{ProjectID: projectID, RelativePath: "frontend/secrets", Filename: "keys.json"}
+	lookalikeFile := FileMeta{ProjectID: projectID, RelativePath: "frontend-old", Filename: "app.js"}
+	backendFile := FileMeta{ProjectID: projectID, RelativePath: "backend", Filename: "main.go"}
+
+	access, err := NewFileAccess("contractor", projectID, db)
+	assert.NoError(t, err)
+	assert.True(t, access.Can(frontendFile, config.CapabilityWrite), "rules should override the project role")
+	assert.False(t, access.Can(secretFile, config.CapabilityRead), "the longest matching prefix should win")
+	assert.False(t, access.Can(lookalikeFile, config.CapabilityWrite), "prefixes should only match whole path segments")
+	assert.True(t, access.Can(backendFile, config.CapabilityRead))
+	assert.False(t, access.Can(backendFile, config.CapabilityWrite))
+
+	access, err = NewFileAccess("owner", projectID, db)
+	assert.NoError(t, err)
+	assert.True(t, access.Can(secretFile, config.CapabilityWrite), "the owner should never be restricted")
+
+	assert.True(t, access.Restricted(secretFile))
+
+	readers, err := access.Readers(secretFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"developer", "owner"}, readers)
+
+	_, err = NewFileAccess("stranger", projectID, db)
+	assert.Error(t, err, "users without a role on the project should not have any access")
+}
+
+func TestShareLinkFileAccess(t *testing.T) {
+	testConfigSetup(t)
+	db := NewDBMock()
+	projectID, _ := db.MySQLProjectCreate("owner", "project")
+	db.PathPermissions[projectID] = []PathPermission{
+		{PathPrefix: "frontend", Username: "contractor", PermissionLevel: config.PermissionsByLabel["write"]},
+	}
+
+	frontendFile := FileMeta{ProjectID: projectID, RelativePath: "frontend/src", Filename: "app.js"}
+	backendFile := FileMeta{ProjectID: projectID, RelativePath: "backend", Filename: "main.go"}
+
+	access, err := NewShareLinkFileAccess("guest", projectID, db)
+	assert.NoError(t, err)
+	assert.True(t, access.Can(backendFile, config.CapabilityRead))
+	assert.False(t, access.Can(backendFile, config.CapabilityWrite), "guests should only be able to read")
+	assert.False(t, access.Can(frontendFile, config.CapabilityRead), "guests should not read files with rules applied")
+}
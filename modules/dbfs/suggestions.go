@@ -0,0 +1,72 @@
+package dbfs
+
+import (
+	"github.com/CodeCollaborate/Server/modules/patching"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+// RebaseSuggestions rebases the file's pending suggestions onto the changes made to the file since they were last
+// rebased. Suggestions which can no longer be rebased, because the changes they need have been scrunched, are left
+// as they are; they can only be rejected.
+func RebaseSuggestions(db DBFS, meta FileMeta) error {
+	suggestions, err := db.MySQLSuggestionsGet(meta.FileID)
+	if err != nil || len(suggestions) == 0 {
+		return err
+	}
+
+	changes, _, version, _, err := db.PullChanges(meta)
+	if err != nil {
+		return err
+	}
+	patches, err := patching.GetPatches(changes)
+	if err != nil {
+		return err
+	}
+
+	for _, suggestion := range suggestions {
+		if suggestion.FileVersion >= version {
+			continue
+		}
+
+		patch, err := patching.NewPatchFromString(suggestion.Patch)
+		if err != nil {
+			return err
+		}
+		rebased, err := RebaseSuggestion(patch, patches)
+		if err != nil {
+			utils.LogError("Failed to rebase suggestion", err, utils.LogFields{
+				"SuggestionID": suggestion.SuggestionID,
+				"FileVersion":  suggestion.FileVersion,
+			})
+			continue
+		}
+
+		err = db.MySQLSuggestionRebase(suggestion.SuggestionID, suggestion.FileVersion, rebased.String(), rebased.BaseVersion)
+		if err != nil && err != ErrNoDbChange {
+			// ErrNoDbChange means another request has already rebased it
+			return err
+		}
+	}
+	return nil
+}
+
+// RebaseSuggestion transforms the suggested patch against the patches applied to the file since its base version, in
+// order, in the same way as conflicting changes are. Returns ErrVersionOutOfDate if the patches it needs have already
+// been scrunched.
+func RebaseSuggestion(suggestion *patching.Patch, patches []*patching.Patch) (*patching.Patch, error) {
+	for _, patch := range patches {
+		if patch.BaseVersion < suggestion.BaseVersion {
+			continue
+		}
+		if patch.BaseVersion > suggestion.BaseVersion {
+			return nil, ErrVersionOutOfDate
+		}
+
+		result, err := patching.TransformPatches(suggestion, patch)
+		if err != nil {
+			return nil, err
+		}
+		suggestion = result.PatchXPrime
+	}
+	return suggestion, nil
+}
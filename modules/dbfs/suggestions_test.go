@@ -0,0 +1,86 @@
+package dbfs
+
+import (
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/patching"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebaseSuggestion(t *testing.T) {
+	tests := []struct {
+		desc     string
+		patches  []string
+		expected string
+		err      error
+	}{
+		{
+			desc:     "insertion before the suggestion",
+			patches:  []string{"v1:\n0:+3:abc:\n20"},
+			expected: "v2:\n8:+2:xy:\n23",
+		},
+		{
+			desc:     "deletion before the suggestion",
+			patches:  []string{"v1:\n0:-2:aa:\n20"},
+			expected: "v2:\n3:+2:xy:\n18",
+		},
+		{
+			desc:     "changes after the suggestion",
+			patches:  []string{"v1:\n12:-4:abcd:\n20", "v2:\n0:+1:a:\n16"},
+			expected: "v3:\n6:+2:xy:\n17",
+		},
+		{
+			desc:     "patches before the suggestion's version are skipped",
+			patches:  []string{"v0:\n0:+20:aaaaaaaaaaaaaaaaaaaa:\n0", "v1:\n0:+1:a:\n20"},
+			expected: "v2:\n6:+2:xy:\n21",
+		},
+		{
+			desc:    "scrunched patches",
+			patches: []string{"v2:\n0:+1:a:\n20"},
+			err:     ErrVersionOutOfDate,
+		},
+		{
+			desc:    "patch for a different document",
+			patches: []string{"v1:\n0:+1:a:\n8"},
+			err:     patching.ErrorBaseDocumentLengthsDifferent,
+		},
+	}
+
+	for _, test := range tests {
+		patches, err := patching.GetPatches(test.patches)
+		require.NoError(t, err, test.desc)
+		suggestion, err := patching.NewPatchFromString("v1:\n5:+2:xy:\n20")
+		require.NoError(t, err, test.desc)
+
+		rebased, err := RebaseSuggestion(suggestion, patches)
+		if test.err != nil {
+			assert.Equal(t, test.err, err, test.desc)
+			continue
+		}
+		if assert.NoError(t, err, test.desc) {
+			assert.Equal(t, test.expected, rebased.String(), test.desc)
+		}
+	}
+}
+
+func TestRebaseSuggestions(t *testing.T) {
+	db := NewDBMock()
+	meta := FileMeta{FileID: 7}
+	stale, _ := db.MySQLSuggestionCreate(Suggestion{FileID: meta.FileID, Author: "loganga",
+		Patch: "v1:\n5:+2:xy:\n20", FileVersion: 1})
+	current, _ := db.MySQLSuggestionCreate(Suggestion{FileID: meta.FileID, Author: "loganga",
+		Patch: "v3:\n0:-1:a:\n21", FileVersion: 3})
+
+	db.FileChanges[meta.FileID] = []string{"v1:\n0:+2:ab:\n20", "v2:\n0:-1:a:\n22"}
+	db.FileVersion[meta.FileID] = 3
+
+	assert.NoError(t, RebaseSuggestions(db, meta))
+	assert.Equal(t, "v3:\n6:+2:xy:\n21", db.Suggestions[stale].Patch)
+	assert.EqualValues(t, 3, db.Suggestions[stale].FileVersion)
+	assert.Equal(t, "v3:\n0:-1:a:\n21", db.Suggestions[current].Patch)
+
+	// suggestions are only rebased once
+	assert.NoError(t, RebaseSuggestions(db, meta))
+	assert.Equal(t, "v3:\n6:+2:xy:\n21", db.Suggestions[stale].Patch)
+}
@@ -0,0 +1,95 @@
+package dbfs
+
+import (
+	"time"
+
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+// PurgeTrash permanently deletes the files and projects which were moved to the trash before the given time.
+// Failures to purge individual items are logged, and retried on the next purge.
+func PurgeTrash(db DBFS, deletedBefore time.Time) error {
+	files, err := db.MySQLExpiredTrashedFiles(deletedBefore)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		err := purgeFile(db, file.FileMeta)
+		utils.LogError("Failed to purge trashed file", err, utils.LogFields{
+			"FileID":    file.FileID,
+			"ProjectID": file.ProjectID,
+		})
+	}
+
+	projects, err := db.MySQLExpiredTrashedProjects(deletedBefore)
+	if err != nil {
+		return err
+	}
+	for _, project := range projects {
+		err := purgeProject(db, project)
+		utils.LogError("Failed to purge trashed project", err, utils.LogFields{
+			"ProjectID": project.ProjectID,
+			"Owner":     project.Owner,
+		})
+	}
+	return nil
+}
+
+// purgeFile deletes a trashed file from Couchbase, the file system and MySQL, in that order, so that a failure
+// leaves the file in the trash to be retried.
+func purgeFile(db DBFS, meta FileMeta) error {
+	err := db.CBDeleteFile(meta.FileID)
+	if err != nil && err != ErrResourceNotFound {
+		return err
+	}
+	err = db.FilePurge(meta)
+	if err != nil {
+		return err
+	}
+	return db.MySQLFileDelete(meta.FileID)
+}
+
+// purgeProject deletes a trashed project and all of its files, trashed or not.
+func purgeProject(db DBFS, project TrashedProject) error {
+	files, err := db.MySQLProjectGetFiles(project.ProjectID)
+	if err != nil {
+		return err
+	}
+	trashedFiles, err := db.MySQLProjectGetTrashedFiles(project.ProjectID)
+	if err != nil {
+		return err
+	}
+	for _, file := range trashedFiles {
+		files = append(files, file.FileMeta)
+	}
+
+	for _, file := range files {
+		err = db.CBDeleteFile(file.FileID)
+		if err != nil && err != ErrResourceNotFound {
+			return err
+		}
+	}
+	err = db.FileDeleteProject(project.ProjectID)
+	if err != nil {
+		return err
+	}
+	return db.MySQLProjectDelete(project.ProjectID, project.Owner)
+}
+
+// PurgeTrashPeriodically purges items which have been in the trash for longer than the retention period, every
+// interval, until stop is closed.
+func PurgeTrashPeriodically(db DBFS, retention time.Duration, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := PurgeTrash(db, time.Now().Add(-retention))
+		utils.LogError("Failed to purge trash", err, nil)
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
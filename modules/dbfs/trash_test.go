@@ -0,0 +1,43 @@
+package dbfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeTrash(t *testing.T) {
+	testConfigSetup(t)
+	db := NewDBMock()
+	db.ProjectIDCounter = 1
+	projectID, _ := db.MySQLProjectCreate("owner", "project")
+	trashedProjectID, _ := db.MySQLProjectCreate("owner", "old project")
+	oldFileID, _ := db.MySQLFileCreate("owner", "old.go", ".", projectID)
+	newFileID, _ := db.MySQLFileCreate("owner", "new.go", ".", projectID)
+	liveFileID, _ := db.MySQLFileCreate("owner", "live.go", ".", projectID)
+	db.MySQLFileCreate("owner", "main.go", ".", trashedProjectID)
+
+	assert.NoError(t, db.MySQLFileTrash(oldFileID, "owner"))
+	assert.NoError(t, db.MySQLFileTrash(newFileID, "owner"))
+	assert.NoError(t, db.MySQLProjectTrash(trashedProjectID, "owner"))
+
+	old := db.TrashedFiles[oldFileID]
+	old.DeletedDate = time.Now().Add(-48 * time.Hour)
+	db.TrashedFiles[oldFileID] = old
+
+	err := PurgeTrash(db, time.Now().Add(-24*time.Hour))
+	assert.NoError(t, err)
+	assert.NotContains(t, db.TrashedFiles, oldFileID, "expired files should be purged")
+	assert.Contains(t, db.TrashedFiles, newFileID, "files within the retention period should be kept")
+	assert.Contains(t, db.TrashedProjects, trashedProjectID, "projects within the retention period should be kept")
+
+	err = PurgeTrash(db, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, db.TrashedProjects)
+	assert.Empty(t, db.TrashedFiles)
+	assert.Empty(t, db.Files[trashedProjectID], "files of purged projects should be deleted")
+	if assert.Len(t, db.Files[projectID], 1) {
+		assert.Equal(t, liveFileID, db.Files[projectID][0].FileID, "files which are not in the trash should be kept")
+	}
+}
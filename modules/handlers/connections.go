@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+/**
+ * Connections tracks the open websocket connections.
+ */
+
+// wsConnection tracks an open websocket connection, so that it can be drained at shutdown.
+type wsConnection struct {
+	wsID   uint64
+	wsConn *websocket.Conn
+
+	// writeMutex serializes writes to the websocket
+	writeMutex sync.Mutex
+
+	// closed is closed once the connection's handlers have completed, and its publisher has been flushed
+	closed chan struct{}
+}
+
+var connections = struct {
+	sync.Mutex
+	conns map[uint64]*wsConnection
+}{conns: make(map[uint64]*wsConnection)}
+
+func registerConnection(wsID uint64, wsConn *websocket.Conn) *wsConnection {
+	conn := &wsConnection{
+		wsID:   wsID,
+		wsConn: wsConn,
+		closed: make(chan struct{}),
+	}
+
+	connections.Lock()
+	defer connections.Unlock()
+	connections.conns[wsID] = conn
+
+	return conn
+}
+
+func deregisterConnection(conn *wsConnection) {
+	connections.Lock()
+	defer connections.Unlock()
+	delete(connections.conns, conn.wsID)
+
+	close(conn.closed)
+}
+
+// stopReading causes any pending and future reads to fail, terminating the connection's read loop.
+// Requests that have already been read continue to be processed.
+func (conn *wsConnection) stopReading() error {
+	return conn.wsConn.SetReadDeadline(time.Now())
+}
+
+// disconnect sends a close frame with the given code and reason to the client, and stops reading from the connection.
+func (conn *wsConnection) disconnect(closeCode int, reason string) error {
+	err := conn.wsConn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, reason), time.Now().Add(time.Second))
+	if err != nil {
+		return err
+	}
+	return conn.stopReading()
+}
+
+// remoteIP returns the IP address of the client that sent the request. If trustForwardedFor is set, the first
+// address in the X-Forwarded-For header is used, if present.
+func remoteIP(request *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwardedFor := request.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+			return strings.TrimSpace(strings.Split(forwardedFor, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteIP(t *testing.T) {
+	request := httptest.NewRequest("GET", "/ws/", nil)
+	request.RemoteAddr = "10.0.0.1:51234"
+	request.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+
+	assert.Equal(t, "10.0.0.1", remoteIP(request, false), "X-Forwarded-For should be ignored unless trusted")
+	assert.Equal(t, "203.0.113.7", remoteIP(request, true))
+
+	request.Header.Del("X-Forwarded-For")
+	assert.Equal(t, "10.0.0.1", remoteIP(request, true))
+
+	request.RemoteAddr = "10.0.0.2"
+	assert.Equal(t, "10.0.0.2", remoteIP(request, false), "Addresses without ports should be used as-is")
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+/**
+ * Health provides the liveness and readiness endpoints used by load balancers and orchestrators.
+ */
+
+// readinessTimeout is the maximum time a single dependency check may take before it is reported as failed
+const readinessTimeout = 5 * time.Second
+
+// DependencyStatus is the result of checking a single dependency
+type DependencyStatus struct {
+	Healthy   bool
+	LatencyMs float64
+	Error     string `json:",omitempty"`
+}
+
+// ReadinessStatus is the result of checking all the server's dependencies
+type ReadinessStatus struct {
+	Ready        bool
+	Dependencies map[string]DependencyStatus
+}
+
+// dependencyCheck returns nil if the dependency is available, or an error describing why it is not
+type dependencyCheck func() error
+
+// readinessChecks builds the checks performed for the given DBFS
+func readinessChecks(db dbfs.DBFS) map[string]dependencyCheck {
+	return map[string]dependencyCheck{
+		"MySQL":       db.MySQLPing,
+		"Couchbase":   db.CBPing,
+		"ProjectPath": db.FileCheckWritable,
+		"RabbitMQ": func() error {
+			return rabbitmq.CheckChannel(readinessTimeout)
+		},
+	}
+}
+
+// checkReadiness runs all the given checks concurrently, returning the status of each.
+// Checks that do not complete within the readiness timeout are reported as failed.
+func checkReadiness(checks map[string]dependencyCheck) ReadinessStatus {
+	status := ReadinessStatus{
+		Ready:        true,
+		Dependencies: make(map[string]DependencyStatus),
+	}
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check dependencyCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			result := make(chan error, 1)
+			go func() {
+				result <- check()
+			}()
+
+			var err error
+			select {
+			case err = <-result:
+			case <-time.After(readinessTimeout):
+				err = errors.New("Timed out")
+			}
+
+			depStatus := DependencyStatus{
+				Healthy:   err == nil,
+				LatencyMs: float64(time.Since(start)) / float64(time.Millisecond),
+			}
+			if err != nil {
+				depStatus.Error = err.Error()
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			status.Dependencies[name] = depStatus
+			if err != nil {
+				status.Ready = false
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return status
+}
+
+// Healthz reports that the process is alive and serving HTTP requests. It does not check any dependencies.
+func Healthz(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.Write([]byte("{\"Healthy\":true}"))
+}
+
+// Readyz reports whether all of the server's dependencies are available, along with the status and
+// latency of each. Responds with 503 Service Unavailable if any dependency is down.
+func Readyz(responseWriter http.ResponseWriter, request *http.Request) {
+	writeReadiness(responseWriter, checkReadiness(readinessChecks(dbfs.Dbfs)))
+}
+
+func writeReadiness(responseWriter http.ResponseWriter, status ReadinessStatus) {
+	body, err := json.Marshal(status)
+	if err != nil {
+		utils.LogError("Failed to marshal readiness status", err, nil)
+		http.Error(responseWriter, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	if !status.Ready {
+		responseWriter.WriteHeader(http.StatusServiceUnavailable)
+	}
+	responseWriter.Write(body)
+}
+
+// LogReadiness checks all dependencies, logging any that are unavailable.
+func LogReadiness() {
+	status := checkReadiness(readinessChecks(dbfs.Dbfs))
+	for name, depStatus := range status.Dependencies {
+		if !depStatus.Healthy {
+			utils.LogWarn("Dependency unavailable at startup", utils.LogFields{
+				"Dependency": name,
+				"Error":      depStatus.Error,
+			})
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessChecks(t *testing.T) {
+	db := dbfs.NewDBMock()
+	checks := readinessChecks(db)
+	assert.Len(t, checks, 4)
+
+	// DatabaseMock is not safe for concurrent use, so call the database checks one at a time here
+	for _, name := range []string{"MySQL", "Couchbase", "ProjectPath"} {
+		assert.NoError(t, checks[name]())
+	}
+	assert.Equal(t, 3, db.FunctionCallCount, "Each database dependency should be checked once")
+	assert.Error(t, checks["RabbitMQ"](), "RabbitMQ check should fail if the exchange was never set up")
+}
+
+func TestCheckReadiness(t *testing.T) {
+	checks := map[string]dependencyCheck{
+		"MySQL":     func() error { return nil },
+		"Couchbase": func() error { return nil },
+		"RabbitMQ": func() error {
+			return errors.New("Rabbit Exchange not initialized")
+		},
+	}
+
+	status := checkReadiness(checks)
+	assert.False(t, status.Ready, "Readiness should fail if any dependency is down")
+	assert.Len(t, status.Dependencies, 3)
+	assert.True(t, status.Dependencies["MySQL"].Healthy)
+	assert.True(t, status.Dependencies["Couchbase"].Healthy)
+	assert.False(t, status.Dependencies["RabbitMQ"].Healthy)
+	assert.Equal(t, "Rabbit Exchange not initialized", status.Dependencies["RabbitMQ"].Error)
+
+	delete(checks, "RabbitMQ")
+	status = checkReadiness(checks)
+	assert.True(t, status.Ready)
+}
+
+func TestWriteReadiness(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writeReadiness(recorder, ReadinessStatus{
+		Ready: false,
+		Dependencies: map[string]DependencyStatus{
+			"MySQL": {Healthy: false, LatencyMs: 1, Error: "connection refused"},
+		},
+	})
+	assert.Equal(t, 503, recorder.Code)
+
+	status := ReadinessStatus{}
+	err := json.Unmarshal(recorder.Body.Bytes(), &status)
+	assert.NoError(t, err)
+	assert.False(t, status.Ready)
+	assert.Equal(t, "connection refused", status.Dependencies["MySQL"].Error)
+
+	recorder = httptest.NewRecorder()
+	writeReadiness(recorder, ReadinessStatus{Ready: true})
+	assert.Equal(t, 200, recorder.Code)
+}
+
+func TestHealthz(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Healthz(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "{\"Healthy\":true}", recorder.Body.String())
+}
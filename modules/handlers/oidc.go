@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/oidc"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+/**
+ * OIDC serves the OpenID Connect login flow. /oidc/<provider>/login redirects the user to the identity provider,
+ * which redirects them back to /oidc/<provider>/callback, which responds with a token for the websocket API.
+ */
+
+// oidcStateCookie holds the state and nonce of a login between the redirect to the provider and the callback
+const oidcStateCookie = "cc_oidc_state"
+
+// oidcStateMaxAge is the time, in seconds, the user has to log in with the provider
+const oidcStateMaxAge = 10 * 60
+
+// maxUsernameLength is the length of the User.Username column
+const maxUsernameLength = 25
+
+var errIdentityNotLinked = errors.New("The identity is not linked to any user")
+var errRegistrationFailed = errors.New("Could not register a user for the identity")
+
+var invalidUsernameChars = regexp.MustCompile("[^a-z0-9._-]")
+
+// oidcProvider is implemented by oidc.Provider
+type oidcProvider interface {
+	AuthCodeURL(state string, nonce string) (string, error)
+	Exchange(code string) (string, error)
+	Verify(rawIDToken string, nonce string) (oidc.Identity, error)
+	Config() config.OIDCProviderCfg
+}
+
+// OIDCHandler serves the login flow of each configured OpenID Connect provider
+type OIDCHandler struct {
+	providers         map[string]oidcProvider
+	db                dbfs.DBFS
+	secureCookies     bool
+	trustForwardedFor bool
+	// issueToken returns the token users are given once logged in
+	issueToken func(username string) (string, error)
+}
+
+// NewOIDCHandler creates an OIDCHandler for the providers in the server configuration
+func NewOIDCHandler(cfg config.ServerCfg, db dbfs.DBFS) *OIDCHandler {
+	handler := &OIDCHandler{
+		providers:         make(map[string]oidcProvider),
+		db:                db,
+		secureCookies:     cfg.UseTLS,
+		trustForwardedFor: cfg.TrustForwardedFor,
+		issueToken:        datahandling.NewAuthToken,
+	}
+	for name, providerCfg := range cfg.OIDCProviders {
+		handler.providers[name] = oidc.NewProvider(name, providerCfg)
+	}
+	return handler
+}
+
+func (handler *OIDCHandler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(request.URL.Path, "/oidc/"), "/"), "/")
+	if len(parts) != 2 || request.Method != "GET" {
+		http.NotFound(responseWriter, request)
+		return
+	}
+	provider, ok := handler.providers[parts[0]]
+	if !ok {
+		http.NotFound(responseWriter, request)
+		return
+	}
+
+	switch parts[1] {
+	case "login":
+		handler.login(responseWriter, request, parts[0], provider)
+	case "callback":
+		handler.callback(responseWriter, request, parts[0], provider)
+	default:
+		http.NotFound(responseWriter, request)
+	}
+}
+
+// login redirects the user to the provider, remembering the login's state and nonce in a cookie
+func (handler *OIDCHandler) login(responseWriter http.ResponseWriter, request *http.Request, name string, provider oidcProvider) {
+	state, err := randomString()
+	if err != nil {
+		http.Error(responseWriter, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomString()
+	if err != nil {
+		http.Error(responseWriter, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce)
+	if err != nil {
+		utils.LogError("Failed to build OIDC authorization URL", err, utils.LogFields{
+			"Provider": name,
+		})
+		http.Error(responseWriter, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	http.SetCookie(responseWriter, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state + "." + nonce,
+		Path:     "/oidc/" + name + "/",
+		MaxAge:   oidcStateMaxAge,
+		HttpOnly: true,
+		Secure:   request.TLS != nil || handler.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(responseWriter, request, authURL, http.StatusFound)
+}
+
+// callback completes a login, responding with the username and token of the user the identity is linked to
+func (handler *OIDCHandler) callback(responseWriter http.ResponseWriter, request *http.Request, name string, provider oidcProvider) {
+	query := request.URL.Query()
+	if query.Get("error") != "" {
+		http.Error(responseWriter, "Login failed: "+query.Get("error"), http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := request.Cookie(oidcStateCookie)
+	if err != nil {
+		http.Error(responseWriter, "Login expired", http.StatusBadRequest)
+		return
+	}
+	// the state can only be used once
+	http.SetCookie(responseWriter, &http.Cookie{Name: oidcStateCookie, Path: "/oidc/" + name + "/", MaxAge: -1})
+
+	stored := strings.SplitN(cookie.Value, ".", 2)
+	if len(stored) != 2 || subtle.ConstantTimeCompare([]byte(stored[0]), []byte(query.Get("state"))) != 1 {
+		http.Error(responseWriter, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	rawIDToken, err := provider.Exchange(query.Get("code"))
+	if err != nil {
+		utils.LogError("Failed to exchange OIDC authorization code", err, utils.LogFields{
+			"Provider": name,
+		})
+		http.Error(responseWriter, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	identity, err := provider.Verify(rawIDToken, stored[1])
+	if err != nil {
+		utils.LogError("Failed to verify OIDC ID token", err, utils.LogFields{
+			"Provider": name,
+		})
+		http.Error(responseWriter, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	username, err := linkIdentity(handler.db, name, provider.Config(), identity)
+	switch err {
+	case nil:
+	case errIdentityNotLinked:
+		http.Error(responseWriter, "No user is linked to this identity", http.StatusForbidden)
+		return
+	case errRegistrationFailed:
+		http.Error(responseWriter, "Could not register a user for this identity", http.StatusConflict)
+		return
+	default:
+		utils.LogError("Failed to look up OIDC identity", err, utils.LogFields{
+			"Provider": name,
+			"Subject":  identity.Subject,
+		})
+		http.Error(responseWriter, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := handler.issueToken(username)
+	if err != nil {
+		utils.LogError("Failed to sign token", err, utils.LogFields{
+			"Username": username,
+		})
+		http.Error(responseWriter, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = handler.db.MySQLAuditLogInsert(dbfs.AuditEntry{
+		Actor:    username,
+		Action:   "User.Login",
+		Target:   username,
+		Detail:   "oidc:" + name,
+		SourceIP: remoteIP(request, handler.trustForwardedFor),
+	})
+	utils.LogError("Failed to write audit log", err, utils.LogFields{
+		"Username": username,
+	})
+
+	body, err := json.Marshal(struct {
+		Username string
+		Token    string
+	}{
+		Username: username,
+		Token:    token,
+	})
+	if err != nil {
+		http.Error(responseWriter, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	responseWriter.Write(body)
+}
+
+// linkIdentity returns the user the identity is linked to. Unknown identities are linked to the user with the
+// same verified email address, or to a newly registered user, if the provider allows it.
+func linkIdentity(db dbfs.DBFS, provider string, cfg config.OIDCProviderCfg, identity oidc.Identity) (string, error) {
+	username, err := db.MySQLExternalIdentityLookup(provider, identity.Subject)
+	if err == nil {
+		return username, nil
+	} else if err != dbfs.ErrNoData {
+		return "", err
+	}
+
+	email := ""
+	if identity.EmailVerified {
+		email = identity.Email
+	}
+
+	if cfg.LinkVerifiedEmail && email != "" {
+		user, err := db.MySQLUserLookupEmail(email)
+		if err == nil && !user.Bot {
+			return user.Username, db.MySQLExternalIdentityLink(provider, identity.Subject, user.Username)
+		}
+	}
+
+	if !cfg.AutoRegister {
+		return "", errIdentityNotLinked
+	}
+
+	for _, candidate := range usernameCandidates(identity) {
+		if existing, err := db.MySQLUserLookup(candidate); err == nil && existing.Username != "" {
+			continue
+		}
+
+		// users registered through a provider have no password, and can only log in through it
+		err := db.MySQLUserRegister(dbfs.UserMeta{
+			Username:  candidate,
+			Email:     email,
+			FirstName: identity.GivenName,
+			LastName:  identity.FamilyName,
+		})
+		if err != nil {
+			utils.LogError("Failed to register user for OIDC identity", err, utils.LogFields{
+				"Provider": provider,
+				"Subject":  identity.Subject,
+				"Username": candidate,
+			})
+			return "", errRegistrationFailed
+		}
+
+		if email != "" {
+			err := db.MySQLInviteBindEmail(email, candidate)
+			utils.LogError("Failed to bind invites to new user", err, utils.LogFields{
+				"Username": candidate,
+			})
+		}
+		return candidate, db.MySQLExternalIdentityLink(provider, identity.Subject, candidate)
+	}
+	return "", errRegistrationFailed
+}
+
+// usernameCandidates returns the usernames to try registering for an identity, in order of preference
+func usernameCandidates(identity oidc.Identity) []string {
+	base := identity.PreferredUsername
+	if base == "" {
+		base = strings.Split(identity.Email, "@")[0]
+	}
+	base = invalidUsernameChars.ReplaceAllString(strings.ToLower(base), "")
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > maxUsernameLength {
+		base = base[:maxUsernameLength]
+	}
+
+	candidates := []string{base}
+	for i := 2; i < 10; i++ {
+		suffix := strconv.Itoa(i)
+		if len(base)+len(suffix) > maxUsernameLength {
+			candidates = append(candidates, base[:maxUsernameLength-len(suffix)]+suffix)
+		} else {
+			candidates = append(candidates, base+suffix)
+		}
+	}
+	return candidates
+}
+
+func randomString() (string, error) {
+	bytes := make([]byte, 24)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
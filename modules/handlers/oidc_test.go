@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/oidc"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProvider issues the identity in `identities` for each code, for the nonce of the last login
+type fakeProvider struct {
+	cfg        config.OIDCProviderCfg
+	identities map[string]oidc.Identity
+	nonce      string
+}
+
+func (fake *fakeProvider) AuthCodeURL(state string, nonce string) (string, error) {
+	fake.nonce = nonce
+	return "https://idp.example.com/authorize?state=" + url.QueryEscape(state), nil
+}
+
+func (fake *fakeProvider) Exchange(code string) (string, error) {
+	if _, ok := fake.identities[code]; !ok {
+		return "", errors.New("invalid_grant")
+	}
+	return code, nil
+}
+
+func (fake *fakeProvider) Verify(rawIDToken string, nonce string) (oidc.Identity, error) {
+	if nonce != fake.nonce {
+		return oidc.Identity{}, oidc.ErrInvalidToken
+	}
+	return fake.identities[rawIDToken], nil
+}
+
+func (fake *fakeProvider) Config() config.OIDCProviderCfg {
+	return fake.cfg
+}
+
+func newTestOIDCHandler(db dbfs.DBFS, provider *fakeProvider) *OIDCHandler {
+	return &OIDCHandler{
+		providers: map[string]oidcProvider{"idp": provider},
+		db:        db,
+		issueToken: func(username string) (string, error) {
+			return "token-" + username, nil
+		},
+	}
+}
+
+// login runs the login flow up to the callback, returning the state cookie and the state the provider returns
+func login(t *testing.T, handler *OIDCHandler) (*http.Cookie, string) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/oidc/idp/login", nil))
+	assert.Equal(t, http.StatusFound, recorder.Code)
+
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	assert.NoError(t, err)
+	cookies := recorder.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, oidcStateCookie, cookies[0].Name)
+		assert.True(t, cookies[0].HttpOnly)
+		return cookies[0], location.Query().Get("state")
+	}
+	return nil, ""
+}
+
+func callback(handler *OIDCHandler, cookie *http.Cookie, state string, code string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("GET", "/oidc/idp/callback?"+url.Values{
+		"state": {state},
+		"code":  {code},
+	}.Encode(), nil)
+	if cookie != nil {
+		request.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestOIDCHandler_UnknownProvider(t *testing.T) {
+	handler := newTestOIDCHandler(dbfs.NewDBMock(), &fakeProvider{})
+	for _, path := range []string{"/oidc/other/login", "/oidc/idp/other", "/oidc/idp"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusNotFound, recorder.Code, path)
+	}
+}
+
+func TestOIDCHandler_Callback(t *testing.T) {
+	db := dbfs.NewDBMock()
+	provider := &fakeProvider{
+		cfg: config.OIDCProviderCfg{AutoRegister: true},
+		identities: map[string]oidc.Identity{
+			"code": {Subject: "sub-1", Email: "jane@example.com", EmailVerified: true, PreferredUsername: "Jane Doe",
+				GivenName: "Jane", FamilyName: "Doe"},
+		},
+	}
+	handler := newTestOIDCHandler(db, provider)
+
+	cookie, state := login(t, handler)
+	recorder := callback(handler, cookie, state, "code")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	response := struct {
+		Username string
+		Token    string
+	}{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "janedoe", response.Username)
+	assert.Equal(t, "token-janedoe", response.Token)
+
+	assert.Equal(t, "jane@example.com", db.Users["janedoe"].Email)
+	assert.Equal(t, "Jane", db.Users["janedoe"].FirstName)
+	assert.Equal(t, "janedoe", db.ExternalIdentities["idp"]["sub-1"])
+	if assert.Len(t, db.AuditLog, 1) {
+		assert.Equal(t, "User.Login", db.AuditLog[0].Action)
+		assert.Equal(t, "oidc:idp", db.AuditLog[0].Detail)
+	}
+
+	// logging in again uses the linked user
+	cookie, state = login(t, handler)
+	recorder = callback(handler, cookie, state, "code")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Len(t, db.Users, 1)
+}
+
+func TestOIDCHandler_CallbackState(t *testing.T) {
+	db := dbfs.NewDBMock()
+	provider := &fakeProvider{
+		cfg:        config.OIDCProviderCfg{AutoRegister: true},
+		identities: map[string]oidc.Identity{"code": {Subject: "sub-1", PreferredUsername: "jane"}},
+	}
+	handler := newTestOIDCHandler(db, provider)
+
+	cookie, state := login(t, handler)
+	assert.Equal(t, http.StatusBadRequest, callback(handler, nil, state, "code").Code, "callback without a login should fail")
+	assert.Equal(t, http.StatusBadRequest, callback(handler, cookie, "forged", "code").Code, "state should be checked")
+	assert.Equal(t, http.StatusUnauthorized, callback(handler, cookie, state, "bad").Code, "bad codes should fail")
+
+	// a cookie from an earlier login carries a stale nonce
+	login(t, handler)
+	assert.Equal(t, http.StatusUnauthorized, callback(handler, cookie, state, "code").Code, "nonce should be checked")
+	assert.Empty(t, db.Users)
+
+	request := httptest.NewRequest("GET", "/oidc/idp/callback?error=access_denied", nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestLinkIdentity(t *testing.T) {
+	identity := oidc.Identity{Subject: "sub-1", Email: "jane@example.com", EmailVerified: true, PreferredUsername: "jane"}
+
+	// unknown identities are rejected unless registration is enabled
+	db := dbfs.NewDBMock()
+	_, err := linkIdentity(db, "idp", config.OIDCProviderCfg{}, identity)
+	assert.Equal(t, errIdentityNotLinked, err)
+	assert.Empty(t, db.Users)
+
+	// verified emails link to the existing user if enabled
+	db = dbfs.NewDBMock()
+	db.Users["jdoe"] = dbfs.UserMeta{Username: "jdoe", Email: "jane@example.com"}
+	username, err := linkIdentity(db, "idp", config.OIDCProviderCfg{LinkVerifiedEmail: true}, identity)
+	assert.NoError(t, err)
+	assert.Equal(t, "jdoe", username)
+	assert.Equal(t, "jdoe", db.ExternalIdentities["idp"]["sub-1"])
+
+	// unverified emails are never trusted
+	db = dbfs.NewDBMock()
+	db.Users["jdoe"] = dbfs.UserMeta{Username: "jdoe", Email: "jane@example.com"}
+	unverified := identity
+	unverified.EmailVerified = false
+	_, err = linkIdentity(db, "idp", config.OIDCProviderCfg{LinkVerifiedEmail: true}, unverified)
+	assert.Equal(t, errIdentityNotLinked, err)
+
+	// registered usernames are not reused
+	db = dbfs.NewDBMock()
+	db.Users["jane"] = dbfs.UserMeta{Username: "jane"}
+	username, err = linkIdentity(db, "idp", config.OIDCProviderCfg{AutoRegister: true}, unverified)
+	assert.NoError(t, err)
+	assert.Equal(t, "jane2", username)
+	assert.Equal(t, "", db.Users["jane2"].Email, "unverified emails should not be registered")
+	assert.Equal(t, "", db.Users["jane2"].Password)
+}
+
+func TestUsernameCandidates(t *testing.T) {
+	candidates := usernameCandidates(oidc.Identity{Email: "Jane.Doe+cc@example.com"})
+	assert.Equal(t, "jane.doecc", candidates[0])
+	assert.Equal(t, "jane.doecc2", candidates[1])
+
+	candidates = usernameCandidates(oidc.Identity{PreferredUsername: "abcdefghijklmnopqrstuvwxyz"})
+	for _, candidate := range candidates {
+		assert.True(t, len(candidate) <= maxUsernameLength, candidate)
+	}
+	assert.Equal(t, "abcdefghijklmnopqrstuvwx2", candidates[1])
+
+	assert.Equal(t, "user", usernameCandidates(oidc.Identity{PreferredUsername: "名前"})[0])
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/utils"
+	"github.com/gorilla/websocket"
+)
+
+/**
+ * Shutdown handles draining the open websocket connections when the server is stopped.
+ */
+
+// shutdownNotificationData is the data sent with the Server.ShuttingDown notification
+type shutdownNotificationData struct {
+	// ReconnectDelay is the number of milliseconds clients should wait before reconnecting
+	ReconnectDelay int64
+}
+
+// notifyShutdown sends the Server.ShuttingDown notification directly to the client.
+func (conn *wsConnection) notifyShutdown(reconnectDelay time.Duration) error {
+	msg, err := json.Marshal(messages.Notification{
+		Resource:   "Server",
+		Method:     "ShuttingDown",
+		ResourceID: 0,
+		Data: shutdownNotificationData{
+			ReconnectDelay: int64(reconnectDelay / time.Millisecond),
+		},
+	}.Wrap())
+	if err != nil {
+		return err
+	}
+
+	conn.writeMutex.Lock()
+	defer conn.writeMutex.Unlock()
+
+	conn.wsConn.SetWriteDeadline(time.Now().Add(time.Second))
+	return conn.wsConn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// Shutdown drains all open websocket connections. New connections are rejected, clients are sent a
+// Server.ShuttingDown notification with a hint of how long to wait before reconnecting, and each connection
+// stops reading new requests. It then waits for the in-flight requests to complete, and for the publishers
+// to be flushed, returning an error if this did not happen before the timeout.
+func Shutdown(reconnectDelay time.Duration, timeout time.Duration) error {
+	atomic.StoreInt32(&draining, 1)
+	deadline := time.After(timeout)
+
+	connections.Lock()
+	conns := make([]*wsConnection, 0, len(connections.conns))
+	for _, conn := range connections.conns {
+		conns = append(conns, conn)
+	}
+	connections.Unlock()
+
+	utils.LogInfo("Draining websocket connections", utils.LogFields{
+		"NumConnections": len(conns),
+	})
+
+	for _, conn := range conns {
+		err := conn.notifyShutdown(reconnectDelay)
+		utils.LogError("Failed to send shutdown notification", err, utils.LogFields{
+			"WebsocketID": conn.wsID,
+		})
+
+		err = conn.stopReading()
+		utils.LogError("Failed to stop reading from websocket", err, utils.LogFields{
+			"WebsocketID": conn.wsID,
+		})
+	}
+
+	for _, conn := range conns {
+		select {
+		case <-conn.closed:
+		case <-deadline:
+			return errors.New("Timed out waiting for websocket connections to drain")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown_RefusesNewConnections(t *testing.T) {
+	defer atomic.StoreInt32(&draining, 0)
+
+	err := Shutdown(time.Second, time.Second)
+	assert.NoError(t, err, "Shutdown with no open connections should complete immediately")
+
+	recorder := httptest.NewRecorder()
+	NewWSConn(recorder, httptest.NewRequest("GET", "/ws/", nil))
+	assert.Equal(t, 503, recorder.Code, "New connections should be refused after shutdown has started")
+}
+
+func TestShutdown_NotifiesAndDrains(t *testing.T) {
+	defer atomic.StoreInt32(&draining, 0)
+
+	// Server side of the websocket; registers the connection, and deregisters once reading fails, like NewWSConn
+	readStopped := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		wsConn, err := upgrader.Upgrade(responseWriter, request, nil)
+		if err != nil {
+			return
+		}
+		defer wsConn.Close()
+
+		conn := registerConnection(1, wsConn)
+		defer deregisterConnection(conn)
+
+		for {
+			if _, _, err := wsConn.ReadMessage(); err != nil {
+				close(readStopped)
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// wait for the connection to be registered
+	for i := 0; i < 100; i++ {
+		connections.Lock()
+		numConns := len(connections.conns)
+		connections.Unlock()
+		if numConns > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err = Shutdown(3*time.Second, time.Second)
+	assert.NoError(t, err)
+
+	select {
+	case <-readStopped:
+	default:
+		t.Fatal("Connection should have stopped reading")
+	}
+
+	_, msg, err := client.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notification := struct {
+		Type          string
+		ServerMessage struct {
+			Resource string
+			Method   string
+			Data     shutdownNotificationData
+		}
+	}{}
+	err = json.Unmarshal(msg, &notification)
+	assert.NoError(t, err)
+	assert.Equal(t, "Notification", notification.Type)
+	assert.Equal(t, "Server", notification.ServerMessage.Resource)
+	assert.Equal(t, "ShuttingDown", notification.ServerMessage.Method)
+	assert.Equal(t, int64(3000), notification.ServerMessage.Data.ReconnectDelay)
+}
+
+func TestShutdown_Timeout(t *testing.T) {
+	defer atomic.StoreInt32(&draining, 0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		wsConn, err := upgrader.Upgrade(responseWriter, request, nil)
+		if err != nil {
+			return
+		}
+		// Register the connection, but never deregister it
+		registerConnection(2, wsConn)
+	}))
+	defer server.Close()
+	defer func() {
+		connections.Lock()
+		delete(connections.conns, 2)
+		connections.Unlock()
+	}()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 100; i++ {
+		connections.Lock()
+		_, ok := connections.conns[2]
+		connections.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err = Shutdown(time.Second, 50*time.Millisecond)
+	assert.Error(t, err, "Shutdown should time out if connections do not drain")
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/CodeCollaborate/Server/modules/config"
 	"github.com/CodeCollaborate/Server/modules/datahandling"
 	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/metrics"
 	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/modules/ratelimit"
 	"github.com/CodeCollaborate/Server/utils"
 	"github.com/gorilla/websocket"
 	"github.com/kr/pretty"
@@ -24,6 +26,12 @@ const outboundMessageQueueBufferSize = 32
 // Counter for unique ID of WebSockets Connections. Unique to hostname.
 var atomicIDCounter uint64
 
+// requestLimiter rate limits requests across all websockets on this server
+var requestLimiter = ratelimit.NewLimiter()
+
+// draining is set to 1 once the server has started shutting down; no new connections are accepted after that.
+var draining int32
+
 // Define WebSocket Upgrader that ignores origin; there is never going to be a referral source.
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
@@ -44,6 +52,10 @@ func NewWSConn(responseWriter http.ResponseWriter, request *http.Request) {
 		http.Error(responseWriter, "Method not allowed", 405)
 		return
 	}
+	if atomic.LoadInt32(&draining) != 0 {
+		http.Error(responseWriter, "Server shutting down", 503)
+		return
+	}
 	wsConn, err := upgrader.Upgrade(responseWriter, request, nil)
 	if err != nil {
 		utils.LogError("Failed to upgrade connection", err, nil)
@@ -52,11 +64,17 @@ func NewWSConn(responseWriter http.ResponseWriter, request *http.Request) {
 	defer wsConn.Close()
 	cfg := config.GetConfig()
 
+	metrics.OpenWebsockets.Inc()
+	defer metrics.OpenWebsockets.Dec()
+
 	// TODO: Send data blob
 
 	// Generate unique ID for this websocket
 	wsID := atomic.AddUint64(&atomicIDCounter, 1)
 
+	conn := registerConnection(wsID, wsConn)
+	defer deregisterConnection(conn)
+
 	pubCfg := rabbitmq.NewPubConfig(func(msg rabbitmq.AMQPMessage) {
 		// TODO(wongb): Do we need to send errors back to the client on publishing fail? Can we just kill the socket?
 		msg.ErrHandler()
@@ -70,9 +88,11 @@ func NewWSConn(responseWriter http.ResponseWriter, request *http.Request) {
 
 	pubSubCfg := rabbitmq.NewAMQPPubSubCfg(cfg.ServerConfig.Name, pubCfg, subCfg)
 
-	subCfg.HandleMessageFunc = newAMQPMessageHandler(wsID, pubSubCfg, wsConn)
+	subCfg.HandleMessageFunc = newAMQPMessageHandler(wsID, pubSubCfg, conn)
 
+	publisherDone := make(chan struct{})
 	go func() {
+		defer close(publisherDone)
 		err := rabbitmq.RunPublisher(pubSubCfg)
 		if err != nil {
 			utils.LogError("Publisher error encountered. Exiting", err, nil)
@@ -90,11 +110,19 @@ func NewWSConn(responseWriter http.ResponseWriter, request *http.Request) {
 	pubSubCfg.Control.Ready.Wait()
 
 	// we don't actually need more than 1 datahandler per websocket
-	dh := datahandling.DataHandler{
+	dh := datahandling.WithMetrics(datahandling.DataHandler{
 		MessageChan: pubCfg.Messages,
 		WebsocketID: wsID,
 		Db:          dbfs.Dbfs,
-	}
+		RemoteAddr:  remoteIP(request, cfg.ServerConfig.TrustForwardedFor),
+		Limiter:     requestLimiter,
+		Disconnect: func() {
+			err := conn.disconnect(websocket.ClosePolicyViolation, "Too many requests")
+			utils.LogError("Failed to disconnect websocket", err, utils.LogFields{
+				"WebsocketID": wsID,
+			})
+		},
+	})
 
 	// Waitgroup to make sure channel is closed at appropriate time.
 	dhCompleted := &sync.WaitGroup{}
@@ -107,8 +135,13 @@ loop:
 		default:
 			messageType, message, err := wsConn.ReadMessage()
 			if err != nil {
-				utils.LogError("Failed to read message, terminating connection", err, nil)
-				pubSubCfg.Control.Shutdown()
+				if atomic.LoadInt32(&draining) != 0 {
+					utils.LogInfo("Stopped reading from connection for shutdown", utils.LogFields{
+						"WebsocketID": wsID,
+					})
+				} else {
+					utils.LogError("Failed to read message, terminating connection", err, nil)
+				}
 				break loop
 			}
 
@@ -119,13 +152,24 @@ loop:
 
 	// Wait for all datahandlers to complete before closing channel
 	dhCompleted.Wait()
+	dh.ReleaseLocks()
 	close(pubCfg.Messages)
+
+	// Closing the channel lets the publisher flush the remaining messages before exiting,
+	// after which the subscriber is shut down as well.
+	<-publisherDone
+	pubSubCfg.Control.Shutdown()
 }
 
-func newAMQPMessageHandler(websocketID uint64, cfg *rabbitmq.AMQPPubSubCfg, wsConn *websocket.Conn) func(rabbitmq.AMQPMessage) error {
+func newAMQPMessageHandler(websocketID uint64, cfg *rabbitmq.AMQPPubSubCfg, conn *wsConnection) func(rabbitmq.AMQPMessage) error {
 	queueName := rabbitmq.RabbitWebsocketQueueName(websocketID)
+	wsConn := conn.wsConn
 
 	return func(msg rabbitmq.AMQPMessage) error {
+		// Writes to the websocket must not happen concurrently; shutdown notifications are written from another goroutine.
+		conn.writeMutex.Lock()
+		defer conn.writeMutex.Unlock()
+
 		switch msg.ContentType {
 		case rabbitmq.ContentTypeMsg:
 			// If notification with self as origin, early-out; ignore our own notifications.
@@ -0,0 +1,169 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER tags of the LDAP message elements we send and receive. Only the definite length, low tag number form of BER
+// used by LDAP (RFC 4511, section 5.1) is supported.
+const (
+	tagBoolean     byte = 0x01
+	tagInteger     byte = 0x02
+	tagOctetString byte = 0x04
+	tagEnumerated  byte = 0x0a
+	tagSequence    byte = 0x30
+	tagSet         byte = 0x31
+
+	tagBindRequest           byte = 0x60
+	tagBindResponse          byte = 0x61
+	tagUnbindRequest         byte = 0x42
+	tagSearchRequest         byte = 0x63
+	tagSearchResultEntry     byte = 0x64
+	tagSearchResultDone      byte = 0x65
+	tagSearchResultReference byte = 0x73
+	tagExtendedResponse      byte = 0x78
+
+	// tagSimpleAuth is the context specific tag of the password in a simple BindRequest
+	tagSimpleAuth byte = 0x80
+	// tagFilterPresent is the context specific tag of a "present" search filter, e.g. (objectClass=*)
+	tagFilterPresent byte = 0x87
+)
+
+// maxPacketSize is the largest message we accept from a server, so that a bad length cannot exhaust our memory
+const maxPacketSize = 1 << 20
+
+var errMalformedPacket = errors.New("ldap: malformed packet")
+
+// packet is a single BER encoded element. Constructed elements hold their encoded children in value.
+type packet struct {
+	tag   byte
+	value []byte
+}
+
+// encode returns the BER encoding of an element with the given tag and contents
+func encode(tag byte, value []byte) []byte {
+	length := len(value)
+	var header []byte
+	if length < 0x80 {
+		header = []byte{tag, byte(length)}
+	} else {
+		var lengthBytes []byte
+		for ; length > 0; length >>= 8 {
+			lengthBytes = append([]byte{byte(length)}, lengthBytes...)
+		}
+		header = append([]byte{tag, 0x80 | byte(len(lengthBytes))}, lengthBytes...)
+	}
+	return append(header, value...)
+}
+
+// encodeConstructed returns the BER encoding of an element holding the given encoded children
+func encodeConstructed(tag byte, children ...[]byte) []byte {
+	var value []byte
+	for _, child := range children {
+		value = append(value, child...)
+	}
+	return encode(tag, value)
+}
+
+func encodeString(tag byte, value string) []byte {
+	return encode(tag, []byte(value))
+}
+
+// encodeInt returns the BER encoding of an integer, in the fewest bytes of two's complement
+func encodeInt(tag byte, value int64) []byte {
+	bytes := []byte{byte(value)}
+	for value > 0x7f || value < -0x80 {
+		value >>= 8
+		bytes = append([]byte{byte(value)}, bytes...)
+	}
+	return encode(tag, bytes)
+}
+
+func encodeBool(value bool) []byte {
+	if value {
+		return encode(tagBoolean, []byte{0xff})
+	}
+	return encode(tagBoolean, []byte{0x00})
+}
+
+// readPacket reads a single element from the reader
+func readPacket(reader io.Reader) (packet, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return packet{}, err
+	}
+	if header[0]&0x1f == 0x1f {
+		return packet{}, errMalformedPacket
+	}
+
+	length := int(header[1])
+	if length >= 0x80 {
+		lengthBytes := make([]byte, length&0x7f)
+		// indefinite lengths are not allowed in LDAP
+		if len(lengthBytes) == 0 || len(lengthBytes) > 4 {
+			return packet{}, errMalformedPacket
+		}
+		if _, err := io.ReadFull(reader, lengthBytes); err != nil {
+			return packet{}, err
+		}
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxPacketSize {
+		return packet{}, fmt.Errorf("ldap: packet of %d bytes is too large", length)
+	}
+
+	value := make([]byte, length)
+	if _, err := io.ReadFull(reader, value); err != nil {
+		return packet{}, err
+	}
+	return packet{tag: header[0], value: value}, nil
+}
+
+// children parses the contents of a constructed element
+func (p packet) children() ([]packet, error) {
+	var children []packet
+	data := p.value
+	for len(data) > 0 {
+		if len(data) < 2 || data[0]&0x1f == 0x1f {
+			return nil, errMalformedPacket
+		}
+
+		length, offset := int(data[1]), 2
+		if length >= 0x80 {
+			numBytes := length & 0x7f
+			if numBytes == 0 || numBytes > 4 || len(data) < 2+numBytes {
+				return nil, errMalformedPacket
+			}
+			length = 0
+			for _, b := range data[2 : 2+numBytes] {
+				length = length<<8 | int(b)
+			}
+			offset += numBytes
+		}
+		if length < 0 || len(data)-offset < length {
+			return nil, errMalformedPacket
+		}
+
+		children = append(children, packet{tag: data[0], value: data[offset : offset+length]})
+		data = data[offset+length:]
+	}
+	return children, nil
+}
+
+// int parses the contents of an INTEGER or ENUMERATED element
+func (p packet) int() (int64, error) {
+	if len(p.value) == 0 || len(p.value) > 8 {
+		return 0, errMalformedPacket
+	}
+	// sign extend from the first byte
+	value := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		value = value<<8 | int64(b)
+	}
+	return value, nil
+}
@@ -0,0 +1,369 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+)
+
+/**
+ * LDAP implements the subset of LDAPv3 (RFC 4511) needed to authenticate users against a directory server: a
+ * simple bind as the user, followed by a read of their own entry.
+ */
+
+// result codes, from RFC 4511 appendix A
+const (
+	resultSuccess            = 0
+	resultInvalidCredentials = 49
+)
+
+// ErrInvalidCredentials is returned when the directory rejects a user's password
+var ErrInvalidCredentials = errors.New("Invalid credentials")
+
+// User is a directory user's entry, read after they have authenticated
+type User struct {
+	DN        string
+	Email     string
+	FirstName string
+	LastName  string
+	// Groups are the DNs of the groups the user is a member of
+	Groups []string
+}
+
+// Directory authenticates users against an LDAP server. A connection is opened for each login.
+type Directory struct {
+	cfg     config.LDAPCfg
+	timeout time.Duration
+}
+
+// NewDirectory checks the configuration, and creates a Directory for it
+func NewDirectory(cfg config.LDAPCfg) (*Directory, error) {
+	serverURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if (serverURL.Scheme != "ldap" && serverURL.Scheme != "ldaps") || serverURL.Host == "" {
+		return nil, fmt.Errorf("ldap: URL must be ldap://host:port or ldaps://host:port, got %q", cfg.URL)
+	}
+	if strings.Count(cfg.UserDNTemplate, "%s") != 1 || strings.Count(cfg.UserDNTemplate, "%") != 1 {
+		return nil, fmt.Errorf("ldap: UserDNTemplate must contain %%s exactly once, got %q", cfg.UserDNTemplate)
+	}
+
+	timeout, err := cfg.TimeoutDuration()
+	if err != nil {
+		return nil, err
+	}
+	return &Directory{cfg: cfg, timeout: timeout}, nil
+}
+
+// Authenticate binds to the directory as the user, returning their entry, or ErrInvalidCredentials if the directory
+// rejects their password.
+func (d *Directory) Authenticate(username string, password string) (User, error) {
+	// a simple bind without a password is an anonymous bind (RFC 4513, section 5.1.2), which would always succeed
+	if username == "" || password == "" {
+		return User{}, ErrInvalidCredentials
+	}
+	dn := fmt.Sprintf(d.cfg.UserDNTemplate, EscapeDN(username))
+
+	conn, err := dial(d.cfg.URL, d.timeout)
+	if err != nil {
+		return User{}, err
+	}
+	defer conn.close()
+
+	if err := conn.bind(dn, password); err != nil {
+		return User{}, err
+	}
+
+	emailAttr := attribute(d.cfg.EmailAttribute, "mail")
+	firstNameAttr := attribute(d.cfg.FirstNameAttribute, "givenName")
+	lastNameAttr := attribute(d.cfg.LastNameAttribute, "sn")
+	groupAttr := attribute(d.cfg.GroupAttribute, "memberOf")
+	entry, err := conn.readEntry(dn, []string{emailAttr, firstNameAttr, lastNameAttr, groupAttr})
+	if err != nil {
+		return User{}, err
+	}
+
+	return User{
+		DN:        dn,
+		Email:     entry.first(emailAttr),
+		FirstName: entry.first(firstNameAttr),
+		LastName:  entry.first(lastNameAttr),
+		Groups:    entry[strings.ToLower(groupAttr)],
+	}, nil
+}
+
+func attribute(configured string, fallback string) string {
+	if configured == "" {
+		return fallback
+	}
+	return configured
+}
+
+// EscapeDN escapes a value for use in a DN, as described in RFC 4514, section 2.4
+func EscapeDN(value string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case strings.IndexByte(",+\"\\<>;=", c) >= 0,
+			c == '#' && i == 0,
+			c == ' ' && (i == 0 || i == len(value)-1):
+			escaped.WriteByte('\\')
+			escaped.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&escaped, "\\%02x", c)
+		default:
+			escaped.WriteByte(c)
+		}
+	}
+	return escaped.String()
+}
+
+// entry holds the attributes of a directory entry, keyed on their lowercased names
+type entry map[string][]string
+
+func (e entry) first(attr string) string {
+	values := e[strings.ToLower(attr)]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// resultError is an LDAPResult other than success
+type resultError struct {
+	code    int64
+	message string
+}
+
+func (err resultError) Error() string {
+	return fmt.Sprintf("ldap: result code %d: %s", err.code, err.message)
+}
+
+// conn is a connection to a directory server
+type conn struct {
+	net.Conn
+	lastMessageID int64
+}
+
+// dial connects to the server at the given ldap:// or ldaps:// URL. The timeout bounds the whole exchange.
+func dial(rawURL string, timeout time.Duration) (*conn, error) {
+	serverURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var netConn net.Conn
+	switch serverURL.Scheme {
+	case "ldap":
+		netConn, err = dialer.Dial("tcp", hostPort(serverURL, "389"))
+	case "ldaps":
+		netConn, err = tls.DialWithDialer(dialer, "tcp", hostPort(serverURL, "636"), &tls.Config{
+			ServerName: serverURL.Hostname(),
+		})
+	default:
+		return nil, fmt.Errorf("ldap: unsupported scheme %q", serverURL.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := netConn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return &conn{Conn: netConn}, nil
+}
+
+func hostPort(serverURL *url.URL, defaultPort string) string {
+	if serverURL.Port() == "" {
+		return net.JoinHostPort(serverURL.Hostname(), defaultPort)
+	}
+	return serverURL.Host
+}
+
+// send wraps the protocol operation in an LDAPMessage and sends it, returning the message's ID
+func (c *conn) send(op []byte) (int64, error) {
+	c.lastMessageID++
+	_, err := c.Write(encodeConstructed(tagSequence, encodeInt(tagInteger, c.lastMessageID), op))
+	return c.lastMessageID, err
+}
+
+// receive reads the next LDAPMessage, returning its protocol operation, which must be a response to messageID
+func (c *conn) receive(messageID int64) (packet, error) {
+	message, err := readPacket(c)
+	if err != nil {
+		return packet{}, err
+	}
+	if message.tag != tagSequence {
+		return packet{}, errMalformedPacket
+	}
+	elements, err := message.children()
+	if err != nil {
+		return packet{}, err
+	}
+	if len(elements) < 2 || elements[0].tag != tagInteger {
+		return packet{}, errMalformedPacket
+	}
+
+	id, err := elements[0].int()
+	if err != nil {
+		return packet{}, err
+	}
+	// the server sends a notice of disconnection with ID 0 before closing the connection
+	if id == 0 && elements[1].tag == tagExtendedResponse {
+		return packet{}, parseResult(elements[1])
+	}
+	if id != messageID {
+		return packet{}, fmt.Errorf("ldap: received response to message %d, expected %d", id, messageID)
+	}
+	return elements[1], nil
+}
+
+// parseResult returns the error held by an LDAPResult, or nil if it was successful
+func parseResult(op packet) error {
+	elements, err := op.children()
+	if err != nil {
+		return err
+	}
+	if len(elements) < 3 || elements[0].tag != tagEnumerated {
+		return errMalformedPacket
+	}
+	code, err := elements[0].int()
+	if err != nil {
+		return err
+	}
+
+	switch code {
+	case resultSuccess:
+		return nil
+	case resultInvalidCredentials:
+		return ErrInvalidCredentials
+	default:
+		return resultError{code: code, message: string(elements[2].value)}
+	}
+}
+
+// bind performs a simple bind as the given DN
+func (c *conn) bind(dn string, password string) error {
+	id, err := c.send(encodeConstructed(tagBindRequest,
+		encodeInt(tagInteger, 3),
+		encodeString(tagOctetString, dn),
+		encodeString(tagSimpleAuth, password),
+	))
+	if err != nil {
+		return err
+	}
+
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op.tag != tagBindResponse {
+		return fmt.Errorf("ldap: unexpected response to bind, with tag %#x", op.tag)
+	}
+	return parseResult(op)
+}
+
+// readEntry reads the given attributes of the entry with the given DN
+func (c *conn) readEntry(dn string, attributes []string) (entry, error) {
+	var attributeList [][]byte
+	for _, attr := range attributes {
+		attributeList = append(attributeList, encodeString(tagOctetString, attr))
+	}
+
+	id, err := c.send(encodeConstructed(tagSearchRequest,
+		encodeString(tagOctetString, dn),
+		encodeInt(tagEnumerated, 0), // scope: baseObject
+		encodeInt(tagEnumerated, 0), // derefAliases: neverDerefAliases
+		encodeInt(tagInteger, 1),    // sizeLimit
+		encodeInt(tagInteger, 0),    // timeLimit: none, since the connection has a deadline
+		encodeBool(false),           // typesOnly
+		encodeString(tagFilterPresent, "objectClass"),
+		encodeConstructed(tagSequence, attributeList...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var result entry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.tag {
+		case tagSearchResultEntry:
+			if result != nil {
+				return nil, errors.New("ldap: received more than one entry")
+			}
+			result, err = parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+		case tagSearchResultReference:
+			// referrals are not followed
+		case tagSearchResultDone:
+			if err := parseResult(op); err != nil {
+				return nil, err
+			}
+			if result == nil {
+				return nil, fmt.Errorf("ldap: no entry found for %q", dn)
+			}
+			return result, nil
+		default:
+			return nil, fmt.Errorf("ldap: unexpected response to search, with tag %#x", op.tag)
+		}
+	}
+}
+
+// parseEntry parses the attributes of a SearchResultEntry
+func parseEntry(op packet) (entry, error) {
+	elements, err := op.children()
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) != 2 || elements[1].tag != tagSequence {
+		return nil, errMalformedPacket
+	}
+	attributes, err := elements[1].children()
+	if err != nil {
+		return nil, err
+	}
+
+	result := entry{}
+	for _, attr := range attributes {
+		parts, err := attr.children()
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) != 2 || parts[0].tag != tagOctetString || parts[1].tag != tagSet {
+			return nil, errMalformedPacket
+		}
+		values, err := parts[1].children()
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.ToLower(string(parts[0].value))
+		for _, value := range values {
+			result[name] = append(result[name], string(value.value))
+		}
+	}
+	return result, nil
+}
+
+// close sends an UnbindRequest, and closes the connection
+func (c *conn) close() error {
+	c.send(encode(tagUnbindRequest, nil))
+	return c.Close()
+}
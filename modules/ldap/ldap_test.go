@@ -0,0 +1,279 @@
+package ldap
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubEntry is an entry in the stub directory
+type stubEntry struct {
+	password   string
+	attributes map[string][]string
+}
+
+// stubServer is an in-process directory server, which answers simple binds and base object searches
+type stubServer struct {
+	listener net.Listener
+	entries  map[string]stubEntry
+
+	mutex sync.Mutex
+	binds []string
+}
+
+func newStubServer(t *testing.T, entries map[string]stubEntry) *stubServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &stubServer{listener: listener, entries: entries}
+	go func() {
+		for {
+			netConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(netConn)
+		}
+	}()
+	return stub
+}
+
+func (stub *stubServer) url() string {
+	return "ldap://" + stub.listener.Addr().String()
+}
+
+// bound returns the DNs bound as since the last call
+func (stub *stubServer) bound() []string {
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	binds := stub.binds
+	stub.binds = nil
+	return binds
+}
+
+func (stub *stubServer) close() {
+	stub.listener.Close()
+}
+
+func (stub *stubServer) serve(netConn net.Conn) {
+	defer netConn.Close()
+
+	boundDN := ""
+	for {
+		message, err := readPacket(netConn)
+		if err != nil {
+			return
+		}
+		elements, err := message.children()
+		if err != nil || len(elements) < 2 {
+			return
+		}
+		id, _ := elements[0].int()
+		op := elements[1]
+		fields, _ := op.children()
+
+		respond := func(op []byte) {
+			netConn.Write(encodeConstructed(tagSequence, encodeInt(tagInteger, id), op))
+		}
+		result := func(tag byte, code int64) {
+			respond(encodeConstructed(tag,
+				encodeInt(tagEnumerated, code), encodeString(tagOctetString, ""), encodeString(tagOctetString, "")))
+		}
+
+		switch op.tag {
+		case tagBindRequest:
+			dn, password := string(fields[1].value), string(fields[2].value)
+			stub.mutex.Lock()
+			stub.binds = append(stub.binds, dn)
+			stub.mutex.Unlock()
+
+			entry, ok := stub.entries[strings.ToLower(dn)]
+			if !ok || entry.password == "" || entry.password != password {
+				boundDN = ""
+				result(tagBindResponse, resultInvalidCredentials)
+				continue
+			}
+			boundDN = strings.ToLower(dn)
+			result(tagBindResponse, resultSuccess)
+		case tagSearchRequest:
+			dn := strings.ToLower(string(fields[0].value))
+			entry, ok := stub.entries[dn]
+			if boundDN == "" {
+				result(tagSearchResultDone, 50) // insufficientAccessRights
+				continue
+			}
+			if !ok {
+				result(tagSearchResultDone, 32) // noSuchObject
+				continue
+			}
+
+			requested, _ := fields[7].children()
+			var attributes [][]byte
+			for _, attr := range requested {
+				for name, values := range entry.attributes {
+					if !strings.EqualFold(name, string(attr.value)) {
+						continue
+					}
+					var encodedValues [][]byte
+					for _, value := range values {
+						encodedValues = append(encodedValues, encodeString(tagOctetString, value))
+					}
+					attributes = append(attributes, encodeConstructed(tagSequence,
+						encodeString(tagOctetString, name), encodeConstructed(tagSet, encodedValues...)))
+				}
+			}
+			respond(encodeConstructed(tagSearchResultEntry,
+				encodeString(tagOctetString, string(fields[0].value)), encodeConstructed(tagSequence, attributes...)))
+			result(tagSearchResultDone, resultSuccess)
+		case tagUnbindRequest:
+			return
+		}
+	}
+}
+
+func newTestDirectory(t *testing.T, stub *stubServer) *Directory {
+	directory, err := NewDirectory(config.LDAPCfg{
+		URL:            stub.url(),
+		UserDNTemplate: "uid=%s,ou=people,dc=example,dc=com",
+		Timeout:        "5s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return directory
+}
+
+func TestDirectory_Authenticate(t *testing.T) {
+	stub := newStubServer(t, map[string]stubEntry{
+		"uid=jane,ou=people,dc=example,dc=com": {
+			password: "correct horse",
+			attributes: map[string][]string{
+				"mail":      {"jane@example.com"},
+				"givenName": {"Jane"},
+				"sn":        {"Doe"},
+				"memberOf":  {"cn=developers,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"},
+			},
+		},
+	})
+	defer stub.close()
+	directory := newTestDirectory(t, stub)
+
+	user, err := directory.Authenticate("jane", "correct horse")
+	assert.NoError(t, err)
+	assert.Equal(t, User{
+		DN:        "uid=jane,ou=people,dc=example,dc=com",
+		Email:     "jane@example.com",
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Groups:    []string{"cn=developers,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"},
+	}, user)
+
+	_, err = directory.Authenticate("jane", "wrong")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = directory.Authenticate("john", "correct horse")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	// an empty password would be an anonymous bind, so it must never reach the server
+	stub.bound()
+	_, err = directory.Authenticate("jane", "")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	assert.Empty(t, stub.bound())
+
+	// usernames cannot change the DN's structure
+	_, err = directory.Authenticate("x,ou=admins", "correct horse")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	assert.Equal(t, []string{`uid=x\,ou\=admins,ou=people,dc=example,dc=com`}, stub.bound())
+}
+
+func TestDirectory_AuthenticateTimeout(t *testing.T) {
+	// a server which accepts connections, but never responds
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			netConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer netConn.Close()
+		}
+	}()
+
+	directory, err := NewDirectory(config.LDAPCfg{
+		URL:            "ldap://" + listener.Addr().String(),
+		UserDNTemplate: "uid=%s,dc=example,dc=com",
+		Timeout:        "100ms",
+	})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = directory.Authenticate("jane", "correct horse")
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "authentication should time out")
+}
+
+func TestNewDirectory(t *testing.T) {
+	tests := []struct {
+		desc  string
+		cfg   config.LDAPCfg
+		valid bool
+	}{
+		{desc: "ldap URL", cfg: config.LDAPCfg{URL: "ldap://localhost:389", UserDNTemplate: "uid=%s,dc=example"}, valid: true},
+		{desc: "ldaps URL", cfg: config.LDAPCfg{URL: "ldaps://localhost", UserDNTemplate: "uid=%s,dc=example"}, valid: true},
+		{desc: "Other scheme", cfg: config.LDAPCfg{URL: "http://localhost", UserDNTemplate: "uid=%s,dc=example"}},
+		{desc: "No host", cfg: config.LDAPCfg{URL: "ldap://", UserDNTemplate: "uid=%s,dc=example"}},
+		{desc: "Template without username", cfg: config.LDAPCfg{URL: "ldap://localhost", UserDNTemplate: "dc=example"}},
+		{desc: "Template with other verbs", cfg: config.LDAPCfg{URL: "ldap://localhost", UserDNTemplate: "uid=%s,dc=%d"}},
+		{desc: "Invalid timeout", cfg: config.LDAPCfg{URL: "ldap://localhost", UserDNTemplate: "uid=%s", Timeout: "soon"}},
+	}
+
+	for _, test := range tests {
+		_, err := NewDirectory(test.cfg)
+		if test.valid {
+			assert.NoError(t, err, test.desc)
+		} else {
+			assert.Error(t, err, test.desc)
+		}
+	}
+}
+
+func TestEscapeDN(t *testing.T) {
+	assert.Equal(t, "jane", EscapeDN("jane"))
+	assert.Equal(t, `doe\, jane`, EscapeDN("doe, jane"))
+	assert.Equal(t, `\#1\+2\=3`, EscapeDN("#1+2=3"))
+	assert.Equal(t, `\ a b\ `, EscapeDN(" a b "))
+	assert.Equal(t, `a\00b`, EscapeDN("a\x00b"))
+}
+
+func TestBER(t *testing.T) {
+	for _, value := range []int64{0, 1, 127, 128, 255, 256, -1, -128, -129, 1 << 40} {
+		encoded := encodeInt(tagInteger, value)
+		p, err := readPacket(strings.NewReader(string(encoded)))
+		assert.NoError(t, err)
+		decoded, err := p.int()
+		assert.NoError(t, err)
+		assert.Equal(t, value, decoded)
+	}
+
+	// long form lengths
+	long := strings.Repeat("a", 300)
+	p, err := readPacket(strings.NewReader(string(encodeString(tagOctetString, long))))
+	assert.NoError(t, err)
+	assert.Equal(t, long, string(p.value))
+
+	_, err = readPacket(strings.NewReader("\x30\x80"))
+	assert.Error(t, err, "indefinite lengths should be rejected")
+	_, err = readPacket(strings.NewReader("\x30\x84\x7f\xff\xff\xff"))
+	assert.Error(t, err, "oversized packets should be rejected")
+	_, err = packet{tag: tagSequence, value: []byte{0x04, 0x05, 'a'}}.children()
+	assert.Equal(t, errMalformedPacket, err, "truncated children should be rejected")
+}
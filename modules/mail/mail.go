@@ -0,0 +1,185 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+/**
+ * Mail sends plain text emails, such as email verification links, through the backend configured in server.cfg.
+ */
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// NewMailer returns a Mailer for the configured backend
+func NewMailer(cfg config.MailCfg) (Mailer, error) {
+	switch cfg.Backend {
+	case "", config.MailBackendLog:
+		return LogMailer{}, nil
+	case config.MailBackendSMTP:
+		return NewSMTPMailer(cfg)
+	default:
+		return nil, fmt.Errorf("Unknown mail backend %q", cfg.Backend)
+	}
+}
+
+// LogMailer logs emails instead of sending them, for development
+type LogMailer struct{}
+
+// Send logs the email
+func (LogMailer) Send(msg Message) error {
+	utils.LogInfo("Email not sent; the log mail backend is configured", utils.LogFields{
+		"To":      msg.To,
+		"Subject": msg.Subject,
+		"Body":    msg.Body,
+	})
+	return nil
+}
+
+// SMTPMailer sends emails through an SMTP server, upgrading the connection with STARTTLS if the server supports it
+type SMTPMailer struct {
+	addr     string
+	host     string
+	from     string
+	username string
+	password string
+	timeout  time.Duration
+}
+
+// NewSMTPMailer checks the configuration, and creates an SMTPMailer for it
+func NewSMTPMailer(cfg config.MailCfg) (*SMTPMailer, error) {
+	if cfg.SMTP.Host == "" {
+		return nil, errors.New("mail: SMTP host must be set")
+	}
+	from, err := netmail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("mail: invalid From address %q: %s", cfg.From, err)
+	}
+	timeout, err := cfg.SMTP.TimeoutDuration()
+	if err != nil {
+		return nil, err
+	}
+
+	port := cfg.SMTP.Port
+	if port == 0 {
+		port = 587
+	}
+	return &SMTPMailer{
+		addr:     net.JoinHostPort(cfg.SMTP.Host, strconv.Itoa(int(port))),
+		host:     cfg.SMTP.Host,
+		from:     from.Address,
+		username: cfg.SMTP.Username,
+		password: cfg.SMTP.Password,
+		timeout:  timeout,
+	}, nil
+}
+
+// Send sends the email. The timeout bounds the whole exchange with the server.
+func (m *SMTPMailer) Send(msg Message) error {
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("mail: invalid recipient %q: %s", msg.To, err)
+	}
+	data, err := format(m.from, to.Address, msg)
+	if err != nil {
+		return err
+	}
+
+	netConn, err := net.DialTimeout("tcp", m.addr, m.timeout)
+	if err != nil {
+		return err
+	}
+	defer netConn.Close()
+	if err := netConn.SetDeadline(time.Now().Add(m.timeout)); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(netConn, m.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	tlsActive := false
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+		tlsActive = true
+	}
+	if m.username != "" {
+		if !tlsActive {
+			return errors.New("mail: the SMTP server does not support STARTTLS; refusing to send credentials")
+		}
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(m.from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// format returns the message as sent in the DATA command, with a quoted-printable body
+func format(from string, to string, msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errors.New("mail: subject cannot contain line breaks")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	// the writer converts line breaks to CRLF
+	body := quotedprintable.NewWriter(&buf)
+	if _, err := body.Write([]byte(msg.Body)); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,139 @@
+package mail
+
+import (
+	"bufio"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"strings"
+	"testing"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubSMTPServer accepts a single email, without TLS or authentication, and sends it on received
+type stubSMTPServer struct {
+	listener net.Listener
+	received chan string
+}
+
+func newStubSMTPServer(t *testing.T) *stubSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &stubSMTPServer{listener: listener, received: make(chan string, 1)}
+	go stub.serve()
+	return stub
+}
+
+func (stub *stubSMTPServer) serve() {
+	netConn, err := stub.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer netConn.Close()
+
+	reader := bufio.NewReader(netConn)
+	reply := func(line string) { netConn.Write([]byte(line + "\r\n")) }
+	reply("220 stub ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250 stub")
+		case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			stub.received <- data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	stub := newStubSMTPServer(t)
+	defer stub.listener.Close()
+
+	host, port, _ := net.SplitHostPort(stub.listener.Addr().String())
+	portNum, _ := net.LookupPort("tcp", port)
+	mailer, err := NewMailer(config.MailCfg{
+		Backend: config.MailBackendSMTP,
+		From:    "CodeCollaborate <noreply@example.com>",
+		SMTP:    config.SMTPCfg{Host: host, Port: uint16(portNum), Timeout: "5s"},
+	})
+	assert.NoError(t, err)
+
+	err = mailer.Send(Message{To: "jane@example.com", Subject: "Verify your email – now", Body: "Hello,\nyour token is abc"})
+	assert.NoError(t, err)
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(<-stub.received))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "noreply@example.com", parsed.Header.Get("From"))
+	assert.Equal(t, "jane@example.com", parsed.Header.Get("To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Verify your email – now", subject)
+	body, err := ioutil.ReadAll(quotedprintable.NewReader(parsed.Body))
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello,\r\nyour token is abc", strings.TrimRight(string(body), "\r\n"))
+}
+
+func TestSMTPMailer_SendCredentialsWithoutTLS(t *testing.T) {
+	stub := newStubSMTPServer(t)
+	defer stub.listener.Close()
+
+	host, port, _ := net.SplitHostPort(stub.listener.Addr().String())
+	portNum, _ := net.LookupPort("tcp", port)
+	mailer, err := NewSMTPMailer(config.MailCfg{
+		From: "noreply@example.com",
+		SMTP: config.SMTPCfg{Host: host, Port: uint16(portNum), Username: "user", Password: "secret"},
+	})
+	assert.NoError(t, err)
+	assert.Error(t, mailer.Send(Message{To: "jane@example.com", Subject: "Hi"}),
+		"credentials should not be sent over plain text connections")
+}
+
+func TestNewMailer(t *testing.T) {
+	mailer, err := NewMailer(config.MailCfg{})
+	assert.NoError(t, err)
+	assert.IsType(t, LogMailer{}, mailer)
+
+	_, err = NewMailer(config.MailCfg{Backend: "carrier-pigeon"})
+	assert.Error(t, err)
+	_, err = NewMailer(config.MailCfg{Backend: config.MailBackendSMTP, From: "noreply@example.com"})
+	assert.Error(t, err, "the SMTP host should be required")
+	_, err = NewMailer(config.MailCfg{Backend: config.MailBackendSMTP, SMTP: config.SMTPCfg{Host: "localhost"}})
+	assert.Error(t, err, "the From address should be required")
+}
+
+func TestFormat(t *testing.T) {
+	_, err := format("noreply@example.com", "jane@example.com", Message{Subject: "Hi\r\nBcc: john@example.com"})
+	assert.Error(t, err, "headers should not be injectable through the subject")
+}
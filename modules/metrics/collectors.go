@@ -0,0 +1,48 @@
+package metrics
+
+/**
+ * Collectors defines the metrics exported by the server.
+ */
+
+var (
+	// RequestsTotal counts processed requests, by Resource.Method and response status
+	RequestsTotal = NewCounterVec("codecollaborate_requests_total",
+		"Number of requests processed, by Resource.Method and response status.",
+		"method", "status")
+
+	// RequestDuration tracks the time taken to process a request, by Resource.Method and response status
+	RequestDuration = NewHistogramVec("codecollaborate_request_duration_seconds",
+		"Time taken to process a request, by Resource.Method and response status.",
+		DefaultBuckets, "method", "status")
+
+	// OpenWebsockets tracks the number of currently open websocket connections
+	OpenWebsockets = NewGauge("codecollaborate_open_websockets",
+		"Number of currently open websocket connections.")
+
+	// AMQPPublishFailures counts messages that could not be published to RabbitMQ
+	AMQPPublishFailures = NewCounterVec("codecollaborate_amqp_publish_failures_total",
+		"Number of messages that failed to publish to RabbitMQ.")
+
+	// AMQPBufferFullDrops counts messages dropped because the publisher's message queue was full, by closure type
+	AMQPBufferFullDrops = NewCounterVec("codecollaborate_amqp_buffer_full_drops_total",
+		"Number of messages dropped because the AMQP publisher message queue was full.",
+		"closure")
+
+	// FileChangeTransforms counts file changes that had to be transformed against missing patches
+	FileChangeTransforms = NewCounterVec("codecollaborate_file_change_transforms_total",
+		"Number of file changes that were transformed against concurrent patches.")
+
+	// FileChangeConflicts counts file changes rejected because they were based on an out-of-date version
+	FileChangeConflicts = NewCounterVec("codecollaborate_file_change_conflicts_total",
+		"Number of file changes rejected as out of date.")
+
+	// ScrunchDuration tracks the time taken to scrunch a file
+	ScrunchDuration = NewHistogramVec("codecollaborate_scrunch_duration_seconds",
+		"Time taken to scrunch a file.",
+		[]float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60})
+
+	// PatchesPerFile tracks the number of patches stored for a file after each change
+	PatchesPerFile = NewHistogramVec("codecollaborate_patches_per_file",
+		"Number of patches tracked for a file after each change.",
+		[]float64{1, 5, 10, 25, 50, 100, 250, 500, 1000})
+)
@@ -0,0 +1,345 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * Metrics provides Prometheus-compatible counters, gauges and histograms, exposed over HTTP in the
+ * Prometheus text exposition format.
+ */
+
+// DefaultBuckets are the default histogram buckets, in seconds; suitable for request latencies.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector is implemented by every metric type, allowing the registry to write them out.
+type collector interface {
+	name() string
+	write(buf *bytes.Buffer)
+}
+
+// Registry holds a set of metrics, and writes them out in the Prometheus text format.
+type Registry struct {
+	mutex      sync.RWMutex
+	collectors map[string]collector
+}
+
+// NewRegistry creates a new, empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+// DefaultRegistry is the registry that all metrics created by the package-level constructors are added to.
+var DefaultRegistry = NewRegistry()
+
+func (reg *Registry) register(c collector) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	if _, exists := reg.collectors[c.name()]; exists {
+		panic(fmt.Sprintf("metrics: metric %s registered twice", c.name()))
+	}
+	reg.collectors[c.name()] = c
+}
+
+func (reg *Registry) unregister(name string) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	delete(reg.collectors, name)
+}
+
+// WriteTo writes all registered metrics to the given buffer, sorted by name.
+func (reg *Registry) WriteTo(buf *bytes.Buffer) {
+	reg.mutex.RLock()
+	names := make([]string, 0, len(reg.collectors))
+	for name := range reg.collectors {
+		names = append(names, name)
+	}
+	reg.mutex.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		reg.mutex.RLock()
+		c := reg.collectors[name]
+		reg.mutex.RUnlock()
+		c.write(buf)
+	}
+}
+
+// ServeHTTP writes out all metrics in the registry, allowing the registry to be mounted as a http.Handler
+func (reg *Registry) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	buf := &bytes.Buffer{}
+	reg.WriteTo(buf)
+
+	responseWriter.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	responseWriter.Write(buf.Bytes())
+}
+
+// Handler serves the metrics in the default registry.
+func Handler(responseWriter http.ResponseWriter, request *http.Request) {
+	DefaultRegistry.ServeHTTP(responseWriter, request)
+}
+
+// vec holds the shared label handling for all metric types.
+type vec struct {
+	metricName string
+	help       string
+	labelNames []string
+	mutex      sync.RWMutex
+}
+
+func (v *vec) name() string {
+	return v.metricName
+}
+
+func (v *vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.metricName, len(v.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (v *vec) writeHeader(buf *bytes.Buffer, metricType string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", v.metricName, strings.Replace(v.help, "\n", " ", -1))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", v.metricName, metricType)
+}
+
+// labels formats the given label values (plus any extra name/value pairs) as a Prometheus label set.
+func (v *vec) labels(labelValues []string, extra ...string) string {
+	if len(labelValues) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	pairs := []string{}
+	for i, labelName := range v.labelNames {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", labelName, strconv.Quote(labelValues[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", extra[i], strconv.Quote(extra[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// CounterVec is a set of monotonically increasing counters, partitioned by label values.
+type CounterVec struct {
+	vec
+	values      map[string]float64
+	labelValues map[string][]string
+}
+
+// NewCounterVec creates a new CounterVec and adds it to the default registry.
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	counter := &CounterVec{
+		vec:         vec{metricName: name, help: help, labelNames: labelNames},
+		values:      make(map[string]float64),
+		labelValues: make(map[string][]string),
+	}
+	DefaultRegistry.register(counter)
+	return counter
+}
+
+// Inc increments the counter for the given label values by 1.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by the given, non-negative, amount.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.metricName))
+	}
+	key := c.key(labelValues)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.labelValues[key]; !ok {
+		c.labelValues[key] = append([]string{}, labelValues...)
+	}
+	c.values[key] += delta
+}
+
+// Value returns the current value of the counter for the given label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.values[key]
+}
+
+func (c *CounterVec) write(buf *bytes.Buffer) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	c.writeHeader(buf, "counter")
+	for _, key := range sortedKeys(c.labelValues) {
+		fmt.Fprintf(buf, "%s%s %s\n", c.metricName, c.labels(c.labelValues[key]), formatFloat(c.values[key]))
+	}
+}
+
+// Gauge is a single value that can go up and down.
+type Gauge struct {
+	vec
+	value float64
+}
+
+// NewGauge creates a new Gauge and adds it to the default registry.
+func NewGauge(name, help string) *Gauge {
+	gauge := &Gauge{
+		vec: vec{metricName: name, help: help},
+	}
+	DefaultRegistry.register(gauge)
+	return gauge
+}
+
+// Inc increments the gauge by 1.
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec decrements the gauge by 1.
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// Add adds the given delta to the gauge.
+func (g *Gauge) Add(delta float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.value += delta
+}
+
+// Set sets the gauge to the given value.
+func (g *Gauge) Set(value float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.value = value
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() float64 {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.value
+}
+
+func (g *Gauge) write(buf *bytes.Buffer) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	g.writeHeader(buf, "gauge")
+	fmt.Fprintf(buf, "%s %s\n", g.metricName, formatFloat(g.value))
+}
+
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec is a set of histograms with fixed buckets, partitioned by label values.
+type HistogramVec struct {
+	vec
+	buckets     []float64
+	values      map[string]*histogramValue
+	labelValues map[string][]string
+}
+
+// NewHistogramVec creates a new HistogramVec with the given upper bucket bounds, and adds it to the default registry.
+// If no buckets are given, DefaultBuckets are used.
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	sortedBuckets := append([]float64{}, buckets...)
+	sort.Float64s(sortedBuckets)
+
+	histogram := &HistogramVec{
+		vec:         vec{metricName: name, help: help, labelNames: labelNames},
+		buckets:     sortedBuckets,
+		values:      make(map[string]*histogramValue),
+		labelValues: make(map[string][]string),
+	}
+	DefaultRegistry.register(histogram)
+	return histogram
+}
+
+// Observe adds a single observation to the histogram for the given label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+		h.labelValues[key] = append([]string{}, labelValues...)
+	}
+
+	for i, upperBound := range h.buckets {
+		if value <= upperBound {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += value
+}
+
+// Count returns the number of observations made for the given label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if hv, ok := h.values[key]; ok {
+		return hv.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(buf *bytes.Buffer) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	h.writeHeader(buf, "histogram")
+	for _, key := range sortedKeys(h.labelValues) {
+		hv := h.values[key]
+		labelValues := h.labelValues[key]
+
+		for i, upperBound := range h.buckets {
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", h.metricName, h.labels(labelValues, "le", formatFloat(upperBound)), hv.counts[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", h.metricName, h.labels(labelValues, "le", "+Inf"), hv.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", h.metricName, h.labels(labelValues), formatFloat(hv.sum))
+		fmt.Fprintf(buf, "%s_count%s %d\n", h.metricName, h.labels(labelValues), hv.count)
+	}
+}
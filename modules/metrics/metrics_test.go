@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterVec(t *testing.T) {
+	counter := NewCounterVec("test_counter_total", "A test counter.", "method", "status")
+	defer DefaultRegistry.unregister("test_counter_total")
+
+	counter.Inc("Project.Create", "200")
+	counter.Inc("Project.Create", "200")
+	counter.Add(3, "File.Change", "409")
+
+	assert.Equal(t, float64(2), counter.Value("Project.Create", "200"))
+	assert.Equal(t, float64(3), counter.Value("File.Change", "409"))
+	assert.Equal(t, float64(0), counter.Value("File.Change", "200"))
+
+	buf := &bytes.Buffer{}
+	counter.write(buf)
+	expected := "# HELP test_counter_total A test counter.\n" +
+		"# TYPE test_counter_total counter\n" +
+		"test_counter_total{method=\"File.Change\",status=\"409\"} 3\n" +
+		"test_counter_total{method=\"Project.Create\",status=\"200\"} 2\n"
+	assert.Equal(t, expected, buf.String())
+
+	assert.Panics(t, func() { counter.Inc("Project.Create") }, "Wrong number of label values should panic")
+	assert.Panics(t, func() { counter.Add(-1, "Project.Create", "200") }, "Counters should not be able to decrease")
+}
+
+func TestGauge(t *testing.T) {
+	gauge := NewGauge("test_gauge", "A test gauge.")
+	defer DefaultRegistry.unregister("test_gauge")
+
+	gauge.Inc()
+	gauge.Inc()
+	gauge.Dec()
+	assert.Equal(t, float64(1), gauge.Value())
+
+	gauge.Set(10)
+	buf := &bytes.Buffer{}
+	gauge.write(buf)
+	assert.Equal(t, "# HELP test_gauge A test gauge.\n# TYPE test_gauge gauge\ntest_gauge 10\n", buf.String())
+}
+
+func TestHistogramVec(t *testing.T) {
+	histogram := NewHistogramVec("test_histogram_seconds", "A test histogram.", []float64{1, 0.1}, "method")
+	defer DefaultRegistry.unregister("test_histogram_seconds")
+
+	histogram.Observe(0.05, "User.Login")
+	histogram.Observe(0.5, "User.Login")
+	histogram.Observe(5, "User.Login")
+
+	assert.Equal(t, uint64(3), histogram.Count("User.Login"))
+	assert.Equal(t, uint64(0), histogram.Count("User.Register"))
+
+	buf := &bytes.Buffer{}
+	histogram.write(buf)
+	expected := "# HELP test_histogram_seconds A test histogram.\n" +
+		"# TYPE test_histogram_seconds histogram\n" +
+		"test_histogram_seconds_bucket{method=\"User.Login\",le=\"0.1\"} 1\n" +
+		"test_histogram_seconds_bucket{method=\"User.Login\",le=\"1\"} 2\n" +
+		"test_histogram_seconds_bucket{method=\"User.Login\",le=\"+Inf\"} 3\n" +
+		"test_histogram_seconds_sum{method=\"User.Login\"} 5.55\n" +
+		"test_histogram_seconds_count{method=\"User.Login\"} 3\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestHandler(t *testing.T) {
+	OpenWebsockets.Set(4)
+
+	recorder := httptest.NewRecorder()
+	Handler(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, recorder.Code)
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain"))
+	assert.Contains(t, recorder.Body.String(), "codecollaborate_open_websockets 4\n")
+	assert.Contains(t, recorder.Body.String(), "# TYPE codecollaborate_request_duration_seconds histogram\n")
+}
+
+func TestRegisterTwice(t *testing.T) {
+	NewCounterVec("test_duplicate_total", "A duplicated counter.")
+	defer DefaultRegistry.unregister("test_duplicate_total")
+	assert.Panics(t, func() { NewCounterVec("test_duplicate_total", "A duplicated counter.") })
+}
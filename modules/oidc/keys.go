@@ -0,0 +1,111 @@
+package oidc
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// keyRefreshInterval is the minimum time between fetches of a provider's keys, so that tokens with unknown key IDs
+// cannot be used to flood the provider with requests
+const keyRefreshInterval = time.Minute
+
+// keySet holds a provider's signing keys, keyed on their key IDs
+type keySet map[string]*rsa.PublicKey
+
+// jsonWebKey is the subset of an RFC 7517 JSON Web Key we use
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// key returns the provider's signing key with the given ID, fetching the provider's keys again if it is not known,
+// since providers rotate their keys. Tokens without a key ID can only be verified if the provider has a single key.
+func (p *Provider) key(kid string) (*rsa.PublicKey, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if key, ok := p.keys.find(kid); ok {
+		return key, nil
+	}
+	if p.discovery == nil {
+		return nil, errors.New("oidc: discovery document has not been fetched")
+	}
+	if !p.lastKeyFetch.IsZero() && p.now().Sub(p.lastKeyFetch) < keyRefreshInterval {
+		return nil, fmt.Errorf("oidc: unknown key %q", kid)
+	}
+
+	keys, err := p.fetchKeys(p.discovery.JWKSURI)
+	p.lastKeyFetch = p.now()
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+
+	if key, ok := p.keys.find(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("oidc: unknown key %q", kid)
+}
+
+func (keys keySet) find(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	key, ok := keys[kid]
+	return key, ok
+}
+
+// fetchKeys fetches the RSA signing keys from the given JWKS URI
+func (p *Provider) fetchKeys(jwksURI string) (keySet, error) {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err := p.getJSON(jwksURI, &jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := keySet{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := parseRSAKey(jwk)
+		if err != nil {
+			return nil, err
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// parseRSAKey builds an RSA public key from the base64url encoded modulus and exponent of a JSON Web Key
+func parseRSAKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.N, "="))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid modulus for key %q: %s", jwk.Kid, err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.E, "="))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid exponent for key %q: %s", jwk.Kid, err)
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("oidc: invalid key %q", jwk.Kid)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
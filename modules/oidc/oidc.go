@@ -0,0 +1,292 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/dgrijalva/jwt-go"
+)
+
+/**
+ * OIDC implements the client side of the OpenID Connect authorization code flow. Users are sent to their identity
+ * provider to log in, and the ID token it returns for them is verified against the provider's published keys.
+ */
+
+// requestTimeout is the maximum time a request to an identity provider may take
+const requestTimeout = 10 * time.Second
+
+// clockSkew is the difference tolerated between our clock and the identity provider's
+const clockSkew = time.Minute
+
+// defaultScopes are requested in addition to "openid" if a provider does not configure any
+var defaultScopes = []string{"email", "profile"}
+
+// ErrInvalidToken is returned when an ID token fails verification
+var ErrInvalidToken = errors.New("The ID token is invalid")
+
+// Identity is the verified identity of a user, taken from the claims of their ID token
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	GivenName         string
+	FamilyName        string
+}
+
+// discovery is the subset of a provider's discovery document we use
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an OpenID Connect identity provider. Its discovery document and keys are fetched when first needed.
+// It is safe for concurrent use.
+type Provider struct {
+	Name   string
+	cfg    config.OIDCProviderCfg
+	client *http.Client
+
+	mutex        sync.Mutex
+	discovery    *discovery
+	keys         keySet
+	lastKeyFetch time.Time
+
+	// now is replaced in tests to control the passage of time
+	now func() time.Time
+}
+
+// NewProvider creates a Provider with the given name and configuration
+func NewProvider(name string, cfg config.OIDCProviderCfg) *Provider {
+	return &Provider{
+		Name:   name,
+		cfg:    cfg,
+		client: &http.Client{Timeout: requestTimeout},
+		now:    time.Now,
+	}
+}
+
+// Config returns the provider's configuration
+func (p *Provider) Config() config.OIDCProviderCfg {
+	return p.cfg
+}
+
+// getDiscovery returns the provider's discovery document, fetching it if it has not been yet
+func (p *Provider) getDiscovery() (*discovery, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	doc := &discovery{}
+	err := p.getJSON(strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", doc)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("oidc: discovery document is for issuer %q, expected %q", doc.Issuer, p.cfg.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+
+	p.discovery = doc
+	return doc, nil
+}
+
+func (p *Provider) getJSON(url string, result interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s responded with status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// AuthCodeURL returns the URL to send the user to, to log in with the provider. The state and nonce are returned
+// in the redirect back to us, and in the ID token, respectively.
+func (p *Provider) AuthCodeURL(state string, nonce string) (string, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.cfg.ClientID},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"scope":         {strings.Join(append([]string{"openid"}, scopes...), " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// tokenResponse is the subset of the token endpoint's response we use
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems an authorization code at the provider's token endpoint, returning the unverified ID token
+func (p *Provider) Exchange(code string) (string, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.cfg.RedirectURL},
+	}
+	req, err := http.NewRequest("POST", doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// client credentials are form encoded before being used for basic auth, as required by RFC 6749
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	token := tokenResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to parse token response with status %d: %s", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return "", fmt.Errorf("oidc: token request failed with status %d: %s %s",
+			resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return "", errors.New("oidc: token response did not contain an ID token")
+	}
+	return token.IDToken, nil
+}
+
+// audience is the "aud" claim, which may be a single string or a list of them
+type audience []string
+
+func (aud *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*aud = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*aud = audience(list)
+	return nil
+}
+
+func (aud audience) contains(clientID string) bool {
+	for _, entry := range aud {
+		if entry == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+type idTokenClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	Expiry            int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	PreferredUsername string   `json:"preferred_username"`
+	GivenName         string   `json:"given_name"`
+	FamilyName        string   `json:"family_name"`
+}
+
+// Valid is unused; the claims are checked against the provider's configuration in Verify instead. This is here for
+// conformance to the jwt.Claims interface.
+func (idTokenClaims) Valid() error {
+	return nil
+}
+
+// Verify checks the signature and claims of an ID token returned by Exchange, and that it was issued for the login
+// with the given nonce, returning the identity it holds.
+func (p *Provider) Verify(rawIDToken string, nonce string) (Identity, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return Identity{}, err
+	}
+
+	claims := &idTokenClaims{}
+	token, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		// only accept RSA signatures, so that the public key can never be used as an HMAC secret
+		if method, ok := token.Method.(*jwt.SigningMethodRSA); !ok || method.Alg() != "RS256" {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(kid)
+	})
+	if err != nil || !token.Valid {
+		return Identity{}, fmt.Errorf("oidc: failed to verify ID token: %v", err)
+	}
+
+	now := p.now()
+	switch {
+	case claims.Issuer != doc.Issuer:
+		return Identity{}, ErrInvalidToken
+	case !claims.Audience.contains(p.cfg.ClientID):
+		return Identity{}, ErrInvalidToken
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID:
+		return Identity{}, ErrInvalidToken
+	case !time.Unix(claims.Expiry, 0).After(now.Add(-clockSkew)):
+		return Identity{}, ErrInvalidToken
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return Identity{}, ErrInvalidToken
+	case nonce == "" || claims.Nonce != nonce:
+		return Identity{}, ErrInvalidToken
+	case claims.Subject == "":
+		return Identity{}, ErrInvalidToken
+	}
+
+	return Identity{
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified,
+		PreferredUsername: claims.PreferredUsername,
+		GivenName:         claims.GivenName,
+		FamilyName:        claims.FamilyName,
+	}, nil
+}
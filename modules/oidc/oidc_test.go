@@ -0,0 +1,248 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubProvider is a local identity provider, which issues ID tokens for the codes in `codes`
+type stubProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+	codes  map[string]jwt.MapClaims
+
+	keyFetches int
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	stub := &stubProvider{
+		key:   newRSAKey(t),
+		kid:   "key-1",
+		codes: make(map[string]jwt.MapClaims),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 stub.server.URL,
+			"authorization_endpoint": stub.server.URL + "/authorize",
+			"token_endpoint":         stub.server.URL + "/token",
+			"jwks_uri":               stub.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		stub.keyFetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": stub.kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(stub.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(stub.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, _ := r.BasicAuth()
+		claims, ok := stub.codes[r.PostFormValue("code")]
+		if clientID != "client" || clientSecret != "s3cret" || r.PostFormValue("grant_type") != "authorization_code" || !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": stub.sign(t, claims, stub.key, stub.kid)})
+	})
+	stub.server = httptest.NewServer(mux)
+	return stub
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func (stub *stubProvider) sign(t *testing.T, claims jwt.MapClaims, key *rsa.PrivateKey, kid string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (stub *stubProvider) claims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                stub.server.URL,
+		"sub":                "248289761001",
+		"aud":                "client",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              nonce,
+		"email":              "jane@example.com",
+		"email_verified":     true,
+		"preferred_username": "jane",
+	}
+}
+
+func (stub *stubProvider) provider() *Provider {
+	return NewProvider("stub", config.OIDCProviderCfg{
+		Issuer:       stub.server.URL,
+		ClientID:     "client",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://codecollaborate.com/oidc/stub/callback",
+	})
+}
+
+func TestProvider_AuthCodeURL(t *testing.T) {
+	stub := newStubProvider(t)
+	defer stub.server.Close()
+
+	authURL, err := stub.provider().AuthCodeURL("state", "nonce")
+	assert.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "/authorize", parsed.Path)
+	params := parsed.Query()
+	assert.Equal(t, "code", params.Get("response_type"))
+	assert.Equal(t, "client", params.Get("client_id"))
+	assert.Equal(t, "https://codecollaborate.com/oidc/stub/callback", params.Get("redirect_uri"))
+	assert.Equal(t, "openid email profile", params.Get("scope"))
+	assert.Equal(t, "state", params.Get("state"))
+	assert.Equal(t, "nonce", params.Get("nonce"))
+}
+
+func TestProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	stub := newStubProvider(t)
+	defer stub.server.Close()
+
+	provider := NewProvider("stub", config.OIDCProviderCfg{Issuer: stub.server.URL + "/other"})
+	_, err := provider.AuthCodeURL("state", "nonce")
+	assert.Error(t, err)
+}
+
+func TestProvider_ExchangeAndVerify(t *testing.T) {
+	stub := newStubProvider(t)
+	defer stub.server.Close()
+	provider := stub.provider()
+
+	stub.codes["good"] = stub.claims("nonce")
+	rawIDToken, err := provider.Exchange("good")
+	assert.NoError(t, err)
+
+	identity, err := provider.Verify(rawIDToken, "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, Identity{
+		Subject:           "248289761001",
+		Email:             "jane@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "jane",
+	}, identity)
+
+	_, err = provider.Verify(rawIDToken, "other nonce")
+	assert.Equal(t, ErrInvalidToken, err, "tokens should only be accepted for the login they were issued for")
+
+	_, err = provider.Exchange("bad")
+	assert.Error(t, err)
+}
+
+func TestProvider_Verify(t *testing.T) {
+	stub := newStubProvider(t)
+	defer stub.server.Close()
+	provider := stub.provider()
+	_, err := provider.AuthCodeURL("state", "nonce")
+	assert.NoError(t, err)
+
+	otherKey := newRSAKey(t)
+	tests := []struct {
+		desc   string
+		modify func(jwt.MapClaims)
+		key    *rsa.PrivateKey
+		valid  bool
+	}{
+		{desc: "Valid token", valid: true},
+		{desc: "Audience list including the client", modify: func(c jwt.MapClaims) {
+			c["aud"] = []string{"client", "other"}
+			c["azp"] = "client"
+		}, valid: true},
+		{desc: "Wrong issuer", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{desc: "Wrong audience", modify: func(c jwt.MapClaims) { c["aud"] = "other" }},
+		{desc: "Audience list for another party", modify: func(c jwt.MapClaims) {
+			c["aud"] = []string{"client", "other"}
+			c["azp"] = "other"
+		}},
+		{desc: "Expired token", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{desc: "Token issued in the future", modify: func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() }},
+		{desc: "Missing subject", modify: func(c jwt.MapClaims) { delete(c, "sub") }},
+		{desc: "Signed with another key", key: otherKey},
+	}
+
+	for _, test := range tests {
+		claims := stub.claims("nonce")
+		if test.modify != nil {
+			test.modify(claims)
+		}
+		key := stub.key
+		if test.key != nil {
+			key = test.key
+		}
+
+		_, err := provider.Verify(stub.sign(t, claims, key, stub.kid), "nonce")
+		if test.valid {
+			assert.NoError(t, err, test.desc)
+		} else {
+			assert.Error(t, err, test.desc)
+		}
+	}
+
+	// the public key must not be usable as an HMAC secret
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, stub.claims("nonce"))
+	hmacToken.Header["kid"] = stub.kid
+	signed, err := hmacToken.SignedString(stub.key.N.Bytes())
+	assert.NoError(t, err)
+	_, err = provider.Verify(signed, "nonce")
+	assert.Error(t, err, "HMAC signed tokens should be rejected")
+}
+
+func TestProvider_KeyRotation(t *testing.T) {
+	stub := newStubProvider(t)
+	defer stub.server.Close()
+	provider := stub.provider()
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+	_, err := provider.AuthCodeURL("state", "nonce")
+	assert.NoError(t, err)
+
+	_, err = provider.Verify(stub.sign(t, stub.claims("nonce"), stub.key, stub.kid), "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stub.keyFetches)
+
+	// the provider rotates its key; tokens signed with it are verified once the keys are fetched again
+	stub.key = newRSAKey(t)
+	stub.kid = "key-2"
+	rotated := stub.sign(t, stub.claims("nonce"), stub.key, stub.kid)
+	_, err = provider.Verify(rotated, "nonce")
+	assert.Error(t, err, "keys should not be fetched more than once per refresh interval")
+	assert.Equal(t, 1, stub.keyFetches)
+
+	now = now.Add(keyRefreshInterval)
+	_, err = provider.Verify(rotated, "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stub.keyFetches)
+}
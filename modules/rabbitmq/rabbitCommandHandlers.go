@@ -14,6 +14,15 @@ type RabbitCommandHandler struct {
 	WSConn       *websocket.Conn
 	WSID         uint64
 	ExchangeName string
+	// QueueName is the queue bound by the commands; defaults to the websocket's queue
+	QueueName string
+}
+
+func (r RabbitCommandHandler) queueName() string {
+	if r.QueueName != "" {
+		return r.QueueName
+	}
+	return RabbitWebsocketQueueName(r.WSID)
 }
 
 // HandleCommand handles an individual command
@@ -52,7 +61,7 @@ func (r RabbitCommandHandler) handleSubscribe(cmd RabbitCommandJSON) error {
 	}
 
 	msg := messages.NewEmptyResponse(messages.StatusSuccess, cmd.Tag)
-	err = BindQueue(ch, RabbitWebsocketQueueName(r.WSID), data.Key, r.ExchangeName)
+	err = BindQueue(ch, r.queueName(), data.Key, r.ExchangeName)
 	if err != nil {
 		msg = messages.NewEmptyResponse(messages.StatusFail, cmd.Tag)
 	}
@@ -84,7 +93,7 @@ func (r RabbitCommandHandler) handleUnsubscribe(cmd RabbitCommandJSON) error {
 	}
 
 	msg := messages.NewEmptyResponse(messages.StatusSuccess, cmd.Tag)
-	err = UnbindQueue(ch, RabbitWebsocketQueueName(r.WSID), data.Key, r.ExchangeName)
+	err = UnbindQueue(ch, r.queueName(), data.Key, r.ExchangeName)
 	if err != nil {
 		msg = messages.NewEmptyResponse(messages.StatusFail, cmd.Tag)
 	}
@@ -59,7 +59,9 @@ func NewAMQPPubSubCfg(exchangeName string, pubCfg *AMQPPubCfg, subCfg *AMQPSubCf
 
 // AMQPSubCfg represents the settings needed to create a new subscriber, including the queues and key bindings
 type AMQPSubCfg struct {
-	QueueID           uint64
+	QueueID uint64
+	// Name overrides the name of the queue, for queues which are not tied to a websocket
+	Name              string
 	Keys              []string
 	IsWorkQueue       bool
 	HandleMessageFunc func(AMQPMessage) error
@@ -67,9 +69,15 @@ type AMQPSubCfg struct {
 
 // QueueName generates the Queue
 func (cfg AMQPSubCfg) QueueName() string {
+	if cfg.Name != "" {
+		return cfg.Name
+	}
 	return RabbitWebsocketQueueName(cfg.QueueID)
 }
 
+// RabbitWebhookQueueName is the name of the work queue shared by the webhook workers of all servers
+const RabbitWebhookQueueName = "Webhooks"
+
 // RabbitUserQueueName returns the name of the Queue a websocket for the given user would have
 func RabbitUserQueueName(username string) string {
 	return fmt.Sprintf("User-%s", username)
@@ -65,3 +65,15 @@ func TestQueueName(t *testing.T) {
 		}
 	}
 }
+
+func TestQueueName_Named(t *testing.T) {
+	queueCfg := AMQPSubCfg{
+		QueueID:     1,
+		Name:        RabbitWebhookQueueName,
+		IsWorkQueue: true,
+	}
+
+	if queueCfg.QueueName() != RabbitWebhookQueueName {
+		t.Fatalf("QueueName incorrect; expected [%s], got [%s]", RabbitWebhookQueueName, queueCfg.QueueName())
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/CodeCollaborate/Server/modules/metrics"
 	"github.com/CodeCollaborate/Server/utils"
 	"github.com/kr/pretty"
 	"github.com/streadway/amqp"
@@ -24,6 +25,10 @@ const (
 var channelQueueCreationMutex = sync.Mutex{}
 var channelQueue chan *amqp.Channel
 
+// exchangeStopped is closed once the connection created by the latest call to SetupRabbitExchange has been closed.
+// It is replaced, while holding channelQueueCreationMutex, each time a new exchange is set up.
+var exchangeStopped chan struct{}
+
 // GetChannel gets a new RabbitMQ Channel. This function requires that SetupRabbitExchange has been
 // previously called. The function will throw an error if the SetupRabbitExchange has not been called.
 func GetChannel() (*amqp.Channel, error) {
@@ -33,6 +38,45 @@ func GetChannel() (*amqp.Channel, error) {
 	return <-channelQueue, nil
 }
 
+// CheckChannel checks that a RabbitMQ Channel can be retrieved from the channel queue within the given timeout.
+// The retrieved channel is closed immediately.
+func CheckChannel(timeout time.Duration) error {
+	queue := channelQueue
+	if queue == nil {
+		return errors.New("Rabbit Exchange not initialized")
+	}
+
+	select {
+	case ch := <-queue:
+		if ch == nil {
+			return errors.New("Rabbit Exchange failed to connect")
+		}
+		return ch.Close()
+	case <-time.After(timeout):
+		return errors.New("Timed out waiting for RabbitMQ channel")
+	}
+}
+
+// ShutdownRabbitExchange signals the exchange set up by SetupRabbitExchange to stop creating channels,
+// and waits for its connection to be closed, returning an error if this did not happen within the given timeout.
+func ShutdownRabbitExchange(control *utils.Control, timeout time.Duration) error {
+	control.Shutdown()
+
+	channelQueueCreationMutex.Lock()
+	stopped := exchangeStopped
+	channelQueueCreationMutex.Unlock()
+	if stopped == nil {
+		return nil
+	}
+
+	select {
+	case <-stopped:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("Timed out waiting for RabbitMQ connection to close")
+	}
+}
+
 // SetupRabbitExchange sets up the RabbitMq exchange, initializing connections, and starting to push RabbitMQ Channels
 // into the ChannelQueue. The generation of channels will be done on a new GoRoutine, avoiding blocking, or having
 // to pass the RabbitMQ Connection around. This method will also attempt to auto-reconnect if the critical setup steps
@@ -49,7 +93,11 @@ func SetupRabbitExchange(cfg *AMQPConnCfg) error {
 		if channelQueue == nil {
 
 			ready := make(chan bool)
+			stopped := make(chan struct{})
+			exchangeStopped = stopped
 			go func() {
+				defer close(stopped)
+
 				// Loop; if connection drops, we should try to restore connection before creating new channels.
 				retries := uint16(0)
 
@@ -127,7 +175,9 @@ func SetupRabbitExchange(cfg *AMQPConnCfg) error {
 
 						select {
 						case <-cfg.Control.Exit:
-							break
+							ch.Close()
+							conn.Close()
+							return
 						case channelQueue <- ch:
 						}
 					}
@@ -290,7 +340,11 @@ func RunPublisher(cfg *AMQPPubSubCfg) error {
 		select {
 		case <-cfg.Control.Exit:
 			return nil
-		case message := <-cfg.PubCfg.Messages:
+		case message, ok := <-cfg.PubCfg.Messages:
+			if !ok {
+				// The message channel was closed; every message sent on it has now been published.
+				return nil
+			}
 
 			deliveryMode := uint8(0)
 			if message.Persistent {
@@ -310,6 +364,7 @@ func RunPublisher(cfg *AMQPPubSubCfg) error {
 				})
 
 			if err != nil {
+				metrics.AMQPPublishFailures.Inc()
 				utils.LogError("Failed to publish AMQPMessage", err, utils.LogFields{
 					"RoutingKey": message.RoutingKey,
 					"Body":       string(message.Message),
@@ -94,6 +94,44 @@ func TestSetupRabbitExchangeInvalidAddress(t *testing.T) {
 	}
 }
 
+func TestSetupRabbitExchangeRetryAfterFailure(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		// the previous exchange clears channelQueue as it stops, so wait for it before resetting the queue
+		waitForExchangeStop(t)
+		channelQueue = nil
+		err := SetupRabbitExchange(
+			&AMQPConnCfg{
+				ConnCfg: config.ConnCfg{},
+			},
+		)
+		if err == nil {
+			t.Fatal("Should have failed to setup exchange")
+		}
+	}
+
+	// the failed exchange stops on its own, so shutting it down shouldn't block
+	err := ShutdownRabbitExchange(utils.NewControl(1), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForExchangeStop waits for the connection created by the last call to SetupRabbitExchange to be closed
+func waitForExchangeStop(t *testing.T) {
+	channelQueueCreationMutex.Lock()
+	stopped := exchangeStopped
+	channelQueueCreationMutex.Unlock()
+	if stopped == nil {
+		return
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Exchange did not stop")
+	}
+}
+
 func TestSetupRabbitExchangeFailConnection(t *testing.T) {
 	channelQueue = nil
 
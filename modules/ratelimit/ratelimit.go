@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+/**
+ * Ratelimit provides token-bucket rate limiting, keyed by arbitrary strings.
+ */
+
+// sweepInterval is how often buckets that have refilled completely are discarded
+const sweepInterval = time.Minute
+
+// Budget describes a token bucket. Requests are allowed at Rate per second on average, in bursts of up to Burst
+// requests. A Budget with a non-positive Rate is unlimited.
+type Budget struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited returns true if this budget does not limit requests at all
+func (budget Budget) Unlimited() bool {
+	return budget.Rate <= 0
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	budget Budget
+}
+
+// refill adds the tokens accumulated since the bucket was last used, up to its burst size
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.budget.Burst), b.tokens+elapsed*b.budget.Rate)
+	}
+	b.last = now
+}
+
+// Limiter tracks a set of token buckets. It is safe for concurrent use.
+type Limiter struct {
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// now is replaced in tests to control the passage of time
+	now func() time.Time
+}
+
+// NewLimiter creates a new Limiter with no buckets.
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token from the bucket for the given key, creating a full bucket with the given budget if needed.
+// If the bucket is empty, the request is not allowed, and the time until the next token is available is returned.
+func (l *Limiter) Allow(key string, budget Budget) (bool, time.Duration) {
+	return l.AllowAll(map[string]Budget{key: budget})
+}
+
+// AllowAll takes a token from each of the buckets for the given keys, but only if every one of them has a token; a
+// request rejected by one bucket does not use up the others. If any are empty, the time until they all have a token
+// available is returned.
+func (l *Limiter) AllowAll(budgets map[string]Budget) (bool, time.Duration) {
+	return l.take(budgets, true)
+}
+
+// Check reports whether AllowAll would allow a request against the given buckets, without taking any tokens
+func (l *Limiter) Check(budgets map[string]Budget) (bool, time.Duration) {
+	return l.take(budgets, false)
+}
+
+// take checks the buckets for the given keys, taking a token from each if consume is set and they all have one
+func (l *Limiter) take(budgets map[string]Budget, consume bool) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	allowed := true
+	var retryAfter time.Duration
+	buckets := make([]*bucket, 0, len(budgets))
+	for key, budget := range budgets {
+		if budget.Unlimited() {
+			continue
+		}
+
+		b, ok := l.buckets[key]
+		if !ok || b.budget != budget {
+			if !consume {
+				// a new bucket would be full
+				continue
+			}
+			b = &bucket{
+				tokens: float64(budget.Burst),
+				last:   now,
+				budget: budget,
+			}
+			l.buckets[key] = b
+		}
+		b.refill(now)
+
+		if b.tokens < 1 {
+			allowed = false
+			if wait := time.Duration((1 - b.tokens) / budget.Rate * float64(time.Second)); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+		buckets = append(buckets, b)
+	}
+
+	if allowed && consume {
+		for _, b := range buckets {
+			b.tokens--
+		}
+	}
+	return allowed, retryAfter
+}
+
+// sweep discards buckets that have refilled completely, since they are equivalent to new ones.
+// Must be called with the mutex held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.budget.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
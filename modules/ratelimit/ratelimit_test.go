@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Unix(1000, 0)
+	limiter := NewLimiter()
+	limiter.lastSweep = now
+	limiter.now = func() time.Time {
+		return now
+	}
+	return limiter, &now
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	limiter, now := newTestLimiter()
+	budget := Budget{Rate: 2, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := limiter.Allow("WS-1", budget)
+		assert.True(t, allowed, "Requests within the burst should be allowed")
+	}
+
+	allowed, retryAfter := limiter.Allow("WS-1", budget)
+	assert.False(t, allowed, "Requests over the burst should be throttled")
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	allowed, _ = limiter.Allow("WS-2", budget)
+	assert.True(t, allowed, "Buckets should be independent per key")
+
+	*now = now.Add(500 * time.Millisecond)
+	allowed, _ = limiter.Allow("WS-1", budget)
+	assert.True(t, allowed, "Bucket should refill over time")
+	allowed, _ = limiter.Allow("WS-1", budget)
+	assert.False(t, allowed)
+
+	*now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		allowed, _ := limiter.Allow("WS-1", budget)
+		assert.True(t, allowed, "Bucket should not refill past its burst size")
+	}
+	allowed, _ = limiter.Allow("WS-1", budget)
+	assert.False(t, allowed)
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	limiter, _ := newTestLimiter()
+
+	for i := 0; i < 100; i++ {
+		allowed, _ := limiter.Allow("WS-1", Budget{})
+		assert.True(t, allowed)
+	}
+	assert.Empty(t, limiter.buckets, "Unlimited budgets should not create buckets")
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	limiter, now := newTestLimiter()
+	budget := Budget{Rate: 1, Burst: 1}
+
+	limiter.Allow("WS-1", budget)
+	limiter.Allow("WS-2", budget)
+	assert.Len(t, limiter.buckets, 2)
+
+	*now = now.Add(2 * sweepInterval)
+	limiter.Allow("WS-3", budget)
+	assert.Len(t, limiter.buckets, 1, "Refilled buckets should be discarded")
+}
+
+func TestLimiter_AllowAll(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	budget := Budget{Rate: 1, Burst: 2}
+	small := Budget{Rate: 1, Burst: 1}
+
+	allowed, _ := limiter.AllowAll(map[string]Budget{"WS-1": budget, "IP-1": small})
+	assert.True(t, allowed)
+
+	allowed, retryAfter := limiter.AllowAll(map[string]Budget{"WS-1": budget, "IP-1": small})
+	assert.False(t, allowed, "Requests should be throttled if any bucket is empty")
+	assert.Equal(t, time.Second, retryAfter)
+
+	allowed, _ = limiter.Allow("WS-1", budget)
+	assert.True(t, allowed, "Throttled requests should not use up the other buckets")
+}
+
+func TestLimiter_Check(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	budget := Budget{Rate: 1, Burst: 1}
+
+	allowed, _ := limiter.Check(map[string]Budget{"WS-1": budget})
+	assert.True(t, allowed)
+	assert.Empty(t, limiter.buckets, "Checking should not create buckets")
+
+	limiter.Allow("WS-1", budget)
+	allowed, retryAfter := limiter.Check(map[string]Budget{"WS-1": budget, "WS-2": budget})
+	assert.False(t, allowed)
+	assert.Equal(t, time.Second, retryAfter)
+
+	allowed, _ = limiter.Allow("WS-2", budget)
+	assert.True(t, allowed, "Checking should not take tokens")
+}
@@ -0,0 +1,103 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/**
+ * TOTP implements time-based one-time passwords (RFC 6238), as generated by authenticator apps, using the defaults
+ * they all support: HMAC-SHA1, 6 digits and a 30 second period.
+ */
+
+// Period is the time each code is valid for
+const Period = 30 * time.Second
+
+// Digits is the length of each code
+const Digits = 6
+
+// modulus is 10^Digits
+const modulus = 1000000
+
+// Skew is the number of periods either side of the current one whose codes are accepted, to allow for clock drift
+const Skew = 1
+
+// secretSize is the size of generated secrets, in bytes; RFC 4226 recommends 160 bits
+const secretSize = 20
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret generates a random secret, base32 encoded as expected by authenticator apps
+func NewSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// URI returns the otpauth:// provisioning URI for the secret, which authenticator apps accept as a QR code
+func URI(issuer string, account string, secret string) string {
+	params := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(Digits)},
+		"period":    {fmt.Sprint(int(Period / time.Second))},
+	}
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Step returns the time step the given time is in
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code for the secret at the given time step
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation, from RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%modulus), nil
+}
+
+// Validate checks the code against the secret at the given time, returning the time step it was generated for.
+// Callers should reject codes for steps at or before the last one used, so that each code can only be used once.
+func Validate(secret string, code string, t time.Time) (int64, bool) {
+	code = strings.Replace(code, " ", "", -1)
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	current := Step(t)
+	for step := current - Skew; step <= current+Skew; step++ {
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,82 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 test secret from RFC 6238 appendix B, "12345678901234567890", base32 encoded
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// the last 6 digits of the RFC 6238 test vectors
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		code, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+
+	_, err := Code("not base32!", 1)
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	current, _ := Code(rfcSecret, step)
+	previous, _ := Code(rfcSecret, step-1)
+	old, _ := Code(rfcSecret, step-2)
+
+	validStep, ok := Validate(rfcSecret, current, now)
+	assert.True(t, ok)
+	assert.Equal(t, step, validStep)
+
+	validStep, ok = Validate(rfcSecret, previous, now)
+	assert.True(t, ok, "codes from the previous period should be accepted")
+	assert.Equal(t, step-1, validStep)
+
+	_, ok = Validate(rfcSecret, old, now)
+	assert.False(t, ok)
+	_, ok = Validate(rfcSecret, current[:3]+" "+current[3:], now)
+	assert.True(t, ok, "spaces should be ignored")
+	_, ok = Validate(rfcSecret, "12345", now)
+	assert.False(t, ok)
+	_, ok = Validate(rfcSecret, "", now)
+	assert.False(t, ok)
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, err := NewSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+	other, err := NewSecret()
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+
+	_, err = Code(secret, 1)
+	assert.NoError(t, err)
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("CodeCollaborate", "jane doe", rfcSecret))
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/CodeCollaborate:jane doe", uri.Path)
+	assert.Equal(t, rfcSecret, uri.Query().Get("secret"))
+	assert.Equal(t, "CodeCollaborate", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+	assert.Equal(t, "30", uri.Query().Get("period"))
+}
@@ -0,0 +1,468 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/utils"
+)
+
+/**
+ * Webhooks delivers the events of projects to the webhooks registered for them, as signed JSON POST requests.
+ */
+
+// Events are the events which can be delivered to webhooks, as Resource.Method
+var Events = []string{
+	"File.Create",
+	"File.Rename",
+	"File.Move",
+	"File.Delete",
+	"File.Restore",
+	"File.Change",
+	"Project.Rename",
+	"Project.GrantPermissions",
+	"Project.RevokePermissions",
+	"Project.Delete",
+	"Project.Restore",
+}
+
+// Headers sent with each delivery
+const (
+	EventHeader     = "X-CodeCollaborate-Event"
+	DeliveryHeader  = "X-CodeCollaborate-Delivery"
+	SignatureHeader = "X-CodeCollaborate-Signature"
+)
+
+// changeEvent is debounced, since a File.Change is sent for every edit
+const changeEvent = "File.Change"
+
+// maxDebounceFactor bounds how many debounce periods a file's changes can be held back for, so that a file which is
+// continuously edited still has its changes delivered
+const maxDebounceFactor = 6
+
+// maxErrorLength is the length of the WebhookDeliveries.Error column
+const maxErrorLength = 1000
+
+// IsEvent returns true if the event can be delivered to webhooks
+func IsEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload is the JSON body POSTed to webhooks
+type Payload struct {
+	Event      string
+	ProjectID  int64
+	ResourceID int64
+	Timestamp  int64
+	Data       json.RawMessage
+}
+
+// Sign returns the value of the signature header for the body: the hex encoded HMAC-SHA256 of the body, keyed with
+// the webhook's secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notification is the part of a messages.Notification wrapper needed to build a Payload
+type notification struct {
+	Type          string
+	Timestamp     int64
+	ServerMessage struct {
+		Resource   string
+		Method     string
+		ResourceID int64
+		Data       json.RawMessage
+	}
+}
+
+// pendingChange is a file's File.Change events which are yet to be delivered
+type pendingChange struct {
+	latest   Payload
+	changes  int
+	deadline time.Time
+	// maxDeadline is the latest the changes can be held back until
+	maxDeadline time.Time
+}
+
+// Worker consumes the notifications sent to the channels of projects with webhooks, and delivers them. Failed
+// deliveries are retried with exponential backoff, and the outcome of each delivery is recorded.
+type Worker struct {
+	db           dbfs.DBFS
+	client       *http.Client
+	exchangeName string
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	debounce     time.Duration
+
+	mutex   sync.Mutex
+	pending map[int64]*pendingChange
+	stopped bool
+
+	stop       chan struct{}
+	deliveries sync.WaitGroup
+}
+
+// NewWorker creates a Worker delivering events as configured; exchangeName is the exchange the project channels are on
+func NewWorker(db dbfs.DBFS, cfg config.WebhooksCfg, exchangeName string) (*Worker, error) {
+	backoff, err := cfg.BackoffDuration()
+	if err != nil {
+		return nil, fmt.Errorf("webhooks: invalid backoff: %s", err)
+	}
+	maxBackoff, err := cfg.MaxBackoffDuration()
+	if err != nil {
+		return nil, fmt.Errorf("webhooks: invalid max backoff: %s", err)
+	}
+	timeout, err := cfg.TimeoutDuration()
+	if err != nil {
+		return nil, fmt.Errorf("webhooks: invalid timeout: %s", err)
+	}
+	debounce, err := cfg.ChangeDebounceDuration()
+	if err != nil {
+		return nil, fmt.Errorf("webhooks: invalid change debounce: %s", err)
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = config.DefaultWebhookMaxAttempts
+	}
+
+	return &Worker{
+		db:           db,
+		client:       &http.Client{Timeout: timeout},
+		exchangeName: exchangeName,
+		maxAttempts:  maxAttempts,
+		backoff:      backoff,
+		maxBackoff:   maxBackoff,
+		debounce:     debounce,
+		pending:      make(map[int64]*pendingChange),
+		stop:         make(chan struct{}),
+	}, nil
+}
+
+// SubCfg returns the configuration of the subscriber consuming the webhooks queue. The queue is bound to the channels
+// of all projects which currently have webhooks.
+func (w *Worker) SubCfg() (*rabbitmq.AMQPSubCfg, error) {
+	projectIDs, err := w.db.MySQLWebhookProjectIDs()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(projectIDs))
+	for i, projectID := range projectIDs {
+		keys[i] = rabbitmq.RabbitProjectQueueName(projectID)
+	}
+
+	return &rabbitmq.AMQPSubCfg{
+		Name:              rabbitmq.RabbitWebhookQueueName,
+		Keys:              keys,
+		IsWorkQueue:       true,
+		HandleMessageFunc: w.HandleMessage,
+	}, nil
+}
+
+// HandleMessage handles a message from the webhooks queue. Commands bind or unbind the queue from a project's
+// channel; notifications on a project's channel are delivered to its webhooks.
+func (w *Worker) HandleMessage(msg rabbitmq.AMQPMessage) error {
+	switch msg.ContentType {
+	case rabbitmq.ContentTypeCmd:
+		return rabbitmq.RabbitCommandHandler{
+			ExchangeName: w.exchangeName,
+			QueueName:    rabbitmq.RabbitWebhookQueueName,
+		}.HandleCommand(msg)
+	case rabbitmq.ContentTypeMsg:
+		projectID, ok := parseProjectKey(msg.RoutingKey)
+		if !ok {
+			return nil
+		}
+
+		var not notification
+		if err := json.Unmarshal(msg.Message, &not); err != nil {
+			return err
+		}
+		if not.Type != "Notification" {
+			return nil
+		}
+
+		payload := Payload{
+			Event:      not.ServerMessage.Resource + "." + not.ServerMessage.Method,
+			ProjectID:  projectID,
+			ResourceID: not.ServerMessage.ResourceID,
+			Timestamp:  not.Timestamp,
+			Data:       not.ServerMessage.Data,
+		}
+		if !IsEvent(payload.Event) {
+			return nil
+		}
+		if payload.Event == changeEvent {
+			w.debounceChange(payload)
+			return nil
+		}
+		return w.dispatch(payload)
+	default:
+		return fmt.Errorf("webhooks: unknown content type %d", msg.ContentType)
+	}
+}
+
+// Stop delivers any debounced changes, and waits for the deliveries in progress to complete. Deliveries waiting to be
+// retried are abandoned.
+func (w *Worker) Stop() {
+	w.mutex.Lock()
+	if w.stopped {
+		w.mutex.Unlock()
+		return
+	}
+	w.stopped = true
+	close(w.stop)
+	pending := w.pending
+	w.pending = make(map[int64]*pendingChange)
+	w.mutex.Unlock()
+
+	for _, change := range pending {
+		err := w.dispatch(change.payload())
+		utils.LogError("Failed to deliver debounced changes", err, utils.LogFields{
+			"ProjectID": change.latest.ProjectID,
+			"FileID":    change.latest.ResourceID,
+		})
+	}
+
+	w.deliveries.Wait()
+}
+
+// parseProjectKey returns the ID of the project whose channel has the given routing key
+func parseProjectKey(key string) (int64, bool) {
+	projectID, err := strconv.ParseInt(strings.TrimPrefix(key, "Project-"), 10, 64)
+	// the ID is formatted back into a key, so that only keys generated by RabbitProjectQueueName are accepted
+	if err != nil || rabbitmq.RabbitProjectQueueName(projectID) != key {
+		return 0, false
+	}
+	return projectID, true
+}
+
+// debounceChange holds back a File.Change until the file has gone the debounce period without changes, so that its
+// changes are delivered as a single event
+func (w *Worker) debounceChange(payload Payload) {
+	w.mutex.Lock()
+	if w.stopped {
+		w.mutex.Unlock()
+		w.dispatchLogged((&pendingChange{latest: payload, changes: 1}).payload())
+		return
+	}
+	defer w.mutex.Unlock()
+
+	now := time.Now()
+
+	change, ok := w.pending[payload.ResourceID]
+	if ok {
+		change.latest = payload
+		change.changes++
+		change.deadline = now.Add(w.debounce)
+		if change.deadline.After(change.maxDeadline) {
+			change.deadline = change.maxDeadline
+		}
+		return
+	}
+
+	change = &pendingChange{
+		latest:      payload,
+		changes:     1,
+		deadline:    now.Add(w.debounce),
+		maxDeadline: now.Add(maxDebounceFactor * w.debounce),
+	}
+	w.pending[payload.ResourceID] = change
+	time.AfterFunc(w.debounce, func() {
+		w.flushChange(payload.ResourceID, change)
+	})
+}
+
+// flushChange delivers the file's pending changes once their deadline has passed, or waits until it has
+func (w *Worker) flushChange(fileID int64, change *pendingChange) {
+	w.mutex.Lock()
+	if w.pending[fileID] != change {
+		// already delivered by Stop
+		w.mutex.Unlock()
+		return
+	}
+	if wait := time.Until(change.deadline); wait > 0 {
+		time.AfterFunc(wait, func() {
+			w.flushChange(fileID, change)
+		})
+		w.mutex.Unlock()
+		return
+	}
+	delete(w.pending, fileID)
+	w.mutex.Unlock()
+
+	w.dispatchLogged(change.payload())
+}
+
+// payload returns the single event delivered for the changes, with the latest file version and the number of changes
+func (change *pendingChange) payload() Payload {
+	var latest struct {
+		FileVersion int64
+	}
+	// the version is left out if the notification cannot be parsed
+	json.Unmarshal(change.latest.Data, &latest)
+
+	data, _ := json.Marshal(struct {
+		FileVersion int64
+		Changes     int
+	}{
+		FileVersion: latest.FileVersion,
+		Changes:     change.changes,
+	})
+
+	payload := change.latest
+	payload.Data = data
+	return payload
+}
+
+func (w *Worker) dispatchLogged(payload Payload) {
+	err := w.dispatch(payload)
+	utils.LogError("Failed to deliver webhook event", err, utils.LogFields{
+		"ProjectID": payload.ProjectID,
+		"Event":     payload.Event,
+	})
+}
+
+// dispatch starts delivering the event to each of the project's webhooks which are subscribed to it
+func (w *Worker) dispatch(payload Payload) error {
+	webhooks, err := w.db.MySQLWebhooksGet(payload.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if !subscribed(webhook, payload.Event) {
+			continue
+		}
+		w.deliveries.Add(1)
+		go func(webhook dbfs.Webhook) {
+			defer w.deliveries.Done()
+			w.deliver(webhook, payload.Event, body)
+		}(webhook)
+	}
+	return nil
+}
+
+// subscribed returns true if the webhook receives the event; webhooks without events receive all of them
+func subscribed(webhook dbfs.Webhook, event string) bool {
+	if len(webhook.Events) == 0 {
+		return true
+	}
+	for _, e := range webhook.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver POSTs the body to the webhook until it responds with a 2xx status, or the attempts run out, and records the
+// outcome
+func (w *Worker) deliver(webhook dbfs.Webhook, event string, body []byte) {
+	deliveryID, err := newDeliveryID()
+	if err != nil {
+		utils.LogError("Failed to generate delivery ID", err, nil)
+		return
+	}
+
+	delivery := dbfs.WebhookDelivery{
+		WebhookID: webhook.WebhookID,
+		Event:     event,
+	}
+	delay := w.backoff
+
+attempts:
+	for {
+		delivery.Attempts++
+		delivery.StatusCode, err = w.post(webhook, event, deliveryID, body)
+		if err == nil {
+			delivery.Success = true
+			delivery.Error = ""
+			break
+		}
+		delivery.Error = err.Error()
+
+		if delivery.Attempts >= w.maxAttempts {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-w.stop:
+			delivery.Error += "; retries abandoned on shutdown"
+			break attempts
+		}
+		delay *= 2
+		if delay > w.maxBackoff {
+			delay = w.maxBackoff
+		}
+	}
+
+	if len(delivery.Error) > maxErrorLength {
+		delivery.Error = delivery.Error[:maxErrorLength]
+	}
+	err = w.db.MySQLWebhookDeliveryInsert(delivery)
+	utils.LogError("Failed to record webhook delivery", err, utils.LogFields{
+		"WebhookID": webhook.WebhookID,
+		"Event":     event,
+		"Success":   delivery.Success,
+	})
+}
+
+// post makes a single delivery attempt, returning the response status, and an error if it was not 2xx
+func (w *Worker) post(webhook dbfs.Webhook, event, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.New("Unexpected response status: " + resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// newDeliveryID returns a random ID, which is sent with every attempt of a delivery so that receivers can detect
+// retries of a delivery they have already handled
+func newDeliveryID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,270 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling/messages"
+	"github.com/CodeCollaborate/Server/modules/dbfs"
+	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// received is a request made to the stub webhook
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// stubWebhook records the requests made to it, responding with the next of the given statuses, or 200 once they
+// run out
+type stubWebhook struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	statuses []int
+	requests []received
+}
+
+func newStubWebhook(statuses ...int) *stubWebhook {
+	stub := &stubWebhook{statuses: statuses}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+
+		stub.mutex.Lock()
+		defer stub.mutex.Unlock()
+		stub.requests = append(stub.requests, received{header: req.Header, body: body})
+		status := http.StatusOK
+		if len(stub.statuses) > 0 {
+			status, stub.statuses = stub.statuses[0], stub.statuses[1:]
+		}
+		rw.WriteHeader(status)
+	}))
+	return stub
+}
+
+func (stub *stubWebhook) received() []received {
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	return append([]received{}, stub.requests...)
+}
+
+func newTestWorker(t *testing.T, db dbfs.DBFS) *Worker {
+	worker, err := NewWorker(db, config.WebhooksCfg{
+		MaxAttempts:    3,
+		Backoff:        "1ms",
+		MaxBackoff:     "2ms",
+		Timeout:        "1s",
+		ChangeDebounce: "50ms",
+	}, "CodeCollaborate")
+	require.Nil(t, err)
+	return worker
+}
+
+func notificationMessage(t *testing.T, projectID int64, not messages.Notification) rabbitmq.AMQPMessage {
+	msgJSON, err := json.Marshal(not.Wrap())
+	require.Nil(t, err)
+	return rabbitmq.AMQPMessage{
+		RoutingKey:  rabbitmq.RabbitProjectQueueName(projectID),
+		ContentType: rabbitmq.ContentTypeMsg,
+		Message:     msgJSON,
+	}
+}
+
+func TestWorker_Deliver(t *testing.T) {
+	stub := newStubWebhook()
+	defer stub.Close()
+
+	db := dbfs.NewDBMock()
+	webhookID, _ := db.MySQLWebhookCreate(dbfs.Webhook{ProjectID: 7, URL: stub.URL, Secret: "secret"})
+	// other projects' webhooks, and those subscribed to other events, are not sent the event
+	db.MySQLWebhookCreate(dbfs.Webhook{ProjectID: 8, URL: stub.URL, Secret: "secret"})
+	db.MySQLWebhookCreate(dbfs.Webhook{ProjectID: 7, URL: stub.URL, Secret: "secret", Events: []string{"File.Delete"}})
+
+	worker := newTestWorker(t, db)
+	err := worker.HandleMessage(notificationMessage(t, 7, messages.Notification{
+		Resource:   "Project",
+		Method:     "Rename",
+		ResourceID: 7,
+		Data:       map[string]string{"NewName": "renamed"},
+	}))
+	require.Nil(t, err)
+	worker.Stop()
+
+	requests := stub.received()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "Project.Rename", requests[0].header.Get(EventHeader))
+	assert.Equal(t, "application/json", requests[0].header.Get("Content-Type"))
+	assert.NotEmpty(t, requests[0].header.Get(DeliveryHeader))
+	assert.Equal(t, Sign("secret", requests[0].body), requests[0].header.Get(SignatureHeader))
+
+	var payload Payload
+	require.Nil(t, json.Unmarshal(requests[0].body, &payload))
+	assert.Equal(t, "Project.Rename", payload.Event)
+	assert.EqualValues(t, 7, payload.ProjectID)
+	assert.EqualValues(t, 7, payload.ResourceID)
+	assert.JSONEq(t, `{"NewName": "renamed"}`, string(payload.Data))
+
+	deliveries, _ := db.MySQLWebhookDeliveriesGet(webhookID, 10)
+	require.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].Success)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+}
+
+func TestWorker_DeliverRetries(t *testing.T) {
+	stub := newStubWebhook(http.StatusInternalServerError, http.StatusBadGateway)
+	defer stub.Close()
+
+	db := dbfs.NewDBMock()
+	webhookID, _ := db.MySQLWebhookCreate(dbfs.Webhook{ProjectID: 7, URL: stub.URL, Secret: "secret"})
+
+	worker := newTestWorker(t, db)
+	notify := func() {
+		err := worker.HandleMessage(notificationMessage(t, 7, messages.Notification{
+			Resource:   "File",
+			Method:     "Delete",
+			ResourceID: 12,
+		}))
+		require.Nil(t, err)
+		worker.deliveries.Wait()
+	}
+
+	// succeeds on the third attempt
+	notify()
+	requests := stub.received()
+	require.Len(t, requests, 3)
+	// every attempt is the same delivery
+	assert.Equal(t, requests[0].header.Get(DeliveryHeader), requests[2].header.Get(DeliveryHeader))
+
+	deliveries, _ := db.MySQLWebhookDeliveriesGet(webhookID, 10)
+	require.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].Success)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Empty(t, deliveries[0].Error)
+
+	// fails once the attempts run out
+	stub.mutex.Lock()
+	stub.statuses = []int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound}
+	stub.mutex.Unlock()
+	notify()
+	assert.Len(t, stub.received(), 6)
+
+	deliveries, _ = db.MySQLWebhookDeliveriesGet(webhookID, 10)
+	require.Len(t, deliveries, 2)
+	assert.False(t, deliveries[0].Success)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusNotFound, deliveries[0].StatusCode)
+	assert.Contains(t, deliveries[0].Error, "404")
+
+	worker.Stop()
+}
+
+func TestWorker_DebounceChanges(t *testing.T) {
+	stub := newStubWebhook()
+	defer stub.Close()
+
+	db := dbfs.NewDBMock()
+	db.MySQLWebhookCreate(dbfs.Webhook{ProjectID: 7, URL: stub.URL, Secret: "secret", Events: []string{"File.Change"}})
+
+	worker := newTestWorker(t, db)
+	for version := int64(1); version <= 3; version++ {
+		err := worker.HandleMessage(notificationMessage(t, 7, messages.Notification{
+			Resource:   "File",
+			Method:     "Change",
+			ResourceID: 12,
+			Data: map[string]interface{}{
+				"FileVersion": version,
+				"Changes":     "v0:\n0:+1:a",
+			},
+		}))
+		require.Nil(t, err)
+	}
+	assert.Empty(t, stub.received())
+
+	// delivered as a single event once the file stops changing
+	time.Sleep(200 * time.Millisecond)
+	worker.Stop()
+
+	requests := stub.received()
+	require.Len(t, requests, 1)
+	var payload Payload
+	require.Nil(t, json.Unmarshal(requests[0].body, &payload))
+	assert.Equal(t, "File.Change", payload.Event)
+	assert.EqualValues(t, 12, payload.ResourceID)
+	assert.JSONEq(t, `{"FileVersion": 3, "Changes": 3}`, string(payload.Data))
+}
+
+func TestWorker_StopDeliversPendingChanges(t *testing.T) {
+	stub := newStubWebhook()
+	defer stub.Close()
+
+	db := dbfs.NewDBMock()
+	db.MySQLWebhookCreate(dbfs.Webhook{ProjectID: 7, URL: stub.URL, Secret: "secret"})
+
+	worker, err := NewWorker(db, config.WebhooksCfg{ChangeDebounce: "1h"}, "CodeCollaborate")
+	require.Nil(t, err)
+	err = worker.HandleMessage(notificationMessage(t, 7, messages.Notification{
+		Resource:   "File",
+		Method:     "Change",
+		ResourceID: 12,
+		Data:       map[string]interface{}{"FileVersion": 2},
+	}))
+	require.Nil(t, err)
+
+	worker.Stop()
+	assert.Len(t, stub.received(), 1)
+}
+
+func TestWorker_HandleMessageIgnored(t *testing.T) {
+	stub := newStubWebhook()
+	defer stub.Close()
+
+	db := dbfs.NewDBMock()
+	db.MySQLWebhookCreate(dbfs.Webhook{ProjectID: 7, URL: stub.URL, Secret: "secret"})
+	worker := newTestWorker(t, db)
+
+	// not a project channel
+	msg := notificationMessage(t, 7, messages.Notification{Resource: "File", Method: "Delete", ResourceID: 12})
+	msg.RoutingKey = rabbitmq.RabbitUserQueueName("7")
+	assert.Nil(t, worker.HandleMessage(msg))
+
+	// not an event webhooks are sent
+	assert.Nil(t, worker.HandleMessage(notificationMessage(t, 7, messages.Notification{
+		Resource: "Project",
+		Method:   "Unsubscribe",
+	})))
+
+	// not a notification
+	msgJSON, _ := json.Marshal(messages.NewEmptyResponse(messages.StatusSuccess, 1))
+	assert.Nil(t, worker.HandleMessage(rabbitmq.AMQPMessage{
+		RoutingKey:  rabbitmq.RabbitProjectQueueName(7),
+		ContentType: rabbitmq.ContentTypeMsg,
+		Message:     msgJSON,
+	}))
+
+	worker.Stop()
+	assert.Empty(t, stub.received())
+}
+
+func TestNewWorker_InvalidConfig(t *testing.T) {
+	_, err := NewWorker(dbfs.NewDBMock(), config.WebhooksCfg{Backoff: "soon"}, "CodeCollaborate")
+	assert.NotNil(t, err)
+}
+
+func TestParseProjectKey(t *testing.T) {
+	projectID, ok := parseProjectKey(rabbitmq.RabbitProjectQueueName(42))
+	assert.True(t, ok)
+	assert.EqualValues(t, 42, projectID)
+
+	for _, key := range []string{"Project-", "Project-+42", "Project-042", "User-42", "Webhooks"} {
+		_, ok := parseProjectKey(key)
+		assert.False(t, ok, key)
+	}
+}
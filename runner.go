@@ -1,17 +1,24 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/CodeCollaborate/Server/modules/config"
+	"github.com/CodeCollaborate/Server/modules/datahandling"
 	"github.com/CodeCollaborate/Server/modules/dbfs"
 	"github.com/CodeCollaborate/Server/modules/handlers"
+	"github.com/CodeCollaborate/Server/modules/metrics"
 	"github.com/CodeCollaborate/Server/modules/rabbitmq"
+	"github.com/CodeCollaborate/Server/modules/webhooks"
 	"github.com/CodeCollaborate/Server/utils"
 	"golang.org/x/crypto/acme/autocert"
 )
@@ -22,6 +29,9 @@ import (
 
 var logDir = flag.String("log_dir", "./data/logs/", "log file location")
 
+// trashPurgeInterval is how often projects and files past the trash retention period are purged
+const trashPurgeInterval = time.Hour
+
 func main() {
 	flag.Parse()
 
@@ -32,6 +42,21 @@ func main() {
 	}
 	cfg := config.GetConfig()
 
+	err = datahandling.ConfigureAuthenticator(cfg.ServerConfig.Authentication)
+	if err != nil {
+		utils.LogFatal("Invalid authentication configuration", err, nil)
+	}
+
+	err = datahandling.ConfigureRegistration(cfg.ServerConfig)
+	if err != nil {
+		utils.LogFatal("Invalid registration configuration", err, nil)
+	}
+
+	err = datahandling.ConfigureLoginProtection(cfg.ServerConfig.LoginProtection)
+	if err != nil {
+		utils.LogFatal("Invalid login protection configuration", err, nil)
+	}
+
 	// Get working directory
 	dir, err := os.Getwd()
 	utils.LogFatal("Could not get working directory", err, nil)
@@ -57,9 +82,31 @@ func main() {
 		},
 	)
 
-	dbfs.Dbfs = new(dbfs.DatabaseImpl)
+	dbfs.Dbfs = dbfs.WithMetrics(new(dbfs.DatabaseImpl))
+
+	trashRetention, err := cfg.ServerConfig.TrashRetentionDuration()
+	if err != nil {
+		utils.LogError("Invalid trash retention; using default", err, nil)
+		trashRetention = config.DefaultTrashRetention
+	}
+	stopPurging := make(chan struct{})
+	go dbfs.PurgeTrashPeriodically(dbfs.Dbfs, trashRetention, trashPurgeInterval, stopPurging)
+
+	webhookWorker, err := webhooks.NewWorker(dbfs.Dbfs, cfg.ServerConfig.Webhooks, cfg.ServerConfig.Name)
+	if err != nil {
+		utils.LogFatal("Invalid webhook configuration", err, nil)
+	}
+	webhookControl := utils.NewControl(1)
+	go runWebhookWorker(webhookWorker, webhookControl)
 
 	http.HandleFunc("/ws/", handlers.NewWSConn)
+	http.HandleFunc("/metrics", metrics.Handler)
+	http.HandleFunc("/healthz", handlers.Healthz)
+	http.HandleFunc("/readyz", handlers.Readyz)
+	http.Handle("/oidc/", handlers.NewOIDCHandler(cfg.ServerConfig, dbfs.Dbfs))
+
+	// Log any dependencies that are not yet available; the server continues to start regardless.
+	handlers.LogReadiness()
 
 	addr := fmt.Sprintf(":%d", cfg.ServerConfig.Port)
 
@@ -87,6 +134,28 @@ func main() {
 		}
 	}()
 
+	server := &http.Server{
+		Addr: addr,
+	}
+
+	// Shut down gracefully on SIGTERM or SIGINT
+	stopped := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+		sig := <-signals
+
+		utils.LogInfo("Received signal, shutting down", utils.LogFields{
+			"Signal": sig.String(),
+		})
+		close(stopPurging)
+		shutdown(server, AMQPControl, func() {
+			webhookControl.Shutdown()
+			webhookWorker.Stop()
+		})
+		close(stopped)
+	}()
+
 	if cfg.ServerConfig.UseTLS {
 		dirCache := autocert.DirCache("certs")
 		certManager := autocert.Manager{
@@ -95,22 +164,92 @@ func main() {
 			Cache:      dirCache,                                      //folder for storing certificates
 		}
 
-		server := &http.Server{
-			Addr: addr,
-			TLSConfig: &tls.Config{
-				GetCertificate: certManager.GetCertificate,
-			},
+		server.TLSConfig = &tls.Config{
+			GetCertificate: certManager.GetCertificate,
 		}
 
-		server.ListenAndServeTLS("", "") //key and cert are comming from Let's Encrypt
+		err = server.ListenAndServeTLS("", "") //key and cert are comming from Let's Encrypt
 	} else {
-		err = http.ListenAndServe(addr, nil)
+		err = server.ListenAndServe()
 	}
 
+	if err == http.ErrServerClosed {
+		// Wait for the graceful shutdown to complete before exiting
+		<-stopped
+		return
+	}
 	utils.LogError("Could not bind to port", err, nil)
 
 	// Kill the SetupRabbitExchange thread (Multithreading control)
-	defer func() {
-		AMQPControl.Exit <- true
-	}()
+	AMQPControl.Shutdown()
+}
+
+// runWebhookWorker consumes the webhooks queue, which is shared with the other servers, until the control is shut down
+func runWebhookWorker(worker *webhooks.Worker, control *utils.Control) {
+	subCfg, err := worker.SubCfg()
+	if err != nil {
+		utils.LogError("Failed to look up projects with webhooks; webhooks will not be delivered by this server", err, nil)
+		return
+	}
+
+	err = rabbitmq.RunSubscriber(&rabbitmq.AMQPPubSubCfg{
+		ExchangeName: config.GetConfig().ServerConfig.Name,
+		SubCfg:       subCfg,
+		Control:      control,
+	})
+	utils.LogError("Webhook subscriber error encountered. Exiting", err, nil)
+}
+
+// shutdown stops the server gracefully: new websocket upgrades are refused, clients are told to reconnect,
+// and in-flight requests and scrunching are given until the configured timeout to complete. The publishers are
+// then flushed, webhook deliveries in progress are completed, and the Couchbase, MySQL and AMQP connections are
+// closed, in that order.
+func shutdown(server *http.Server, amqpControl *utils.Control, stopWebhooks func()) {
+	cfg := config.GetConfig()
+
+	timeout, err := cfg.ServerConfig.ShutdownTimeoutDuration()
+	if err != nil {
+		utils.LogError("Invalid shutdown timeout; using default", err, nil)
+		timeout = config.DefaultShutdownTimeout
+	}
+	reconnectDelay, err := cfg.ServerConfig.ReconnectDelayDuration()
+	if err != nil {
+		utils.LogError("Invalid reconnect delay; using default", err, nil)
+		reconnectDelay = config.DefaultReconnectDelay
+	}
+	deadline := time.Now().Add(timeout)
+
+	// Stop accepting new connections. Hijacked websocket connections are not affected, and are drained separately.
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	err = server.Shutdown(ctx)
+	utils.LogError("Failed to stop HTTP server", err, nil)
+
+	// Notify clients, wait for in-flight requests, and flush each connection's publisher.
+	err = handlers.Shutdown(reconnectDelay, time.Until(deadline))
+	utils.LogError("Failed to drain websocket connections", err, nil)
+
+	err = dbfs.WaitForScrunching(time.Until(deadline))
+	utils.LogError("Failed to wait for scrunching to complete", err, nil)
+
+	stopWebhooks()
+
+	err = dbfs.Dbfs.CloseCouchbase()
+	if err != dbfs.ErrDbNotInitialized {
+		utils.LogError("Failed to close Couchbase connection", err, nil)
+	}
+	err = dbfs.Dbfs.CloseMySQL()
+	if err != dbfs.ErrDbNotInitialized {
+		utils.LogError("Failed to close MySQL connection", err, nil)
+	}
+
+	// Give the AMQP connection at least a second to close, even if the deadline has passed.
+	amqpTimeout := time.Until(deadline)
+	if amqpTimeout < time.Second {
+		amqpTimeout = time.Second
+	}
+	err = rabbitmq.ShutdownRabbitExchange(amqpControl, amqpTimeout)
+	utils.LogError("Failed to close AMQP connection", err, nil)
+
+	utils.LogInfo("Shutdown complete", nil)
 }